@@ -0,0 +1,136 @@
+// Package assets serves the static files embedded in the binary, e.g. the
+// coffee images, under content addressed URLs. The URL of a file holds a hash
+// of its content, /static/<hash>/packer.png, so it is cached forever and a new
+// build changing the file changes its URL. The manifest maps the names of the
+// files to their current URLs.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Prefix is the URL prefix the assets are served under
+const Prefix = "/static/"
+
+// ManifestName is the name of the manifest under Prefix
+const ManifestName = "manifest.json"
+
+// hashLength is the number of hex digits of the content hash in the URLs
+const hashLength = 12
+
+//go:embed static
+var embedded embed.FS
+
+// asset is an embedded file
+type asset struct {
+	hash string
+	data []byte
+}
+
+// Catalog serves the embedded assets
+type Catalog struct {
+	assets   map[string]asset
+	manifest []byte
+	etag     string
+}
+
+// New reads the embedded assets and hashes their content
+func New() (*Catalog, error) {
+	return newCatalog(embedded, "static")
+}
+
+// newCatalog reads the assets of fsys under root
+func newCatalog(fsys fs.FS, root string) (*Catalog, error) {
+	c := &Catalog{assets: map[string]asset{}}
+	err := fs.WalkDir(fsys, root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		c.assets[strings.TrimPrefix(file, root+"/")] = asset{hash: hex.EncodeToString(sum[:])[:hashLength], data: data}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.manifest, err = json.Marshal(c.Manifest()); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(c.manifest)
+	c.etag = `"` + hex.EncodeToString(sum[:])[:hashLength] + `"`
+	return c, nil
+}
+
+// URL returns the content addressed URL of the asset name, e.g. packer.png,
+// false when there is no such asset
+func (c *Catalog) URL(name string) (string, bool) {
+	a, ok := c.assets[name]
+	if !ok {
+		return "", false
+	}
+	return Prefix + a.hash + "/" + name, true
+}
+
+// Manifest returns the URL of every asset by name
+func (c *Catalog) Manifest() map[string]string {
+	manifest := make(map[string]string, len(c.assets))
+	for name := range c.assets {
+		manifest[name], _ = c.URL(name)
+	}
+	return manifest
+}
+
+// ServeHTTP serves the manifest and the assets under Prefix. Assets at their
+// current URL are cached forever, while the manifest and the redirects of
+// unhashed or outdated URLs to the current ones are revalidated on every use.
+func (c *Catalog) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(path.Clean(r.URL.Path), strings.TrimSuffix(Prefix, "/"))
+	rest = strings.TrimPrefix(rest, "/")
+
+	if rest == ManifestName {
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("ETag", c.etag)
+		http.ServeContent(rw, r, ManifestName, time.Time{}, bytes.NewReader(c.manifest))
+		return
+	}
+
+	name := rest
+	hash := ""
+	if slash := strings.Index(rest, "/"); slash == hashLength {
+		hash, name = rest[:slash], rest[slash+1:]
+	}
+	a, ok := c.assets[name]
+	if !ok {
+		// the hash may be the first directory of an unhashed name
+		if a, ok = c.assets[rest]; !ok {
+			http.NotFound(rw, r)
+			return
+		}
+		hash, name = "", rest
+	}
+
+	if hash != a.hash {
+		url, _ := c.URL(name)
+		rw.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(rw, r, url, http.StatusFound)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	rw.Header().Set("ETag", `"`+a.hash+`"`)
+	http.ServeContent(rw, r, name, time.Time{}, bytes.NewReader(a.data))
+}
@@ -0,0 +1,97 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCatalog(t *testing.T) *Catalog {
+	c, err := newCatalog(fstest.MapFS{
+		"static/packer.png":      {Data: []byte("packer")},
+		"static/icons/vault.png": {Data: []byte("vault")},
+	}, "static")
+	require.NoError(t, err)
+	return c
+}
+
+func get(c *Catalog, url string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", url, nil)
+	for n := 0; n+1 < len(headers); n += 2 {
+		r.Header.Set(headers[n], headers[n+1])
+	}
+	rw := httptest.NewRecorder()
+	c.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestAssetsAreServedAtTheirHashedURL(t *testing.T) {
+	c := setupCatalog(t)
+
+	url, ok := c.URL("packer.png")
+	require.True(t, ok)
+	assert.Regexp(t, `^/static/[0-9a-f]{12}/packer\.png$`, url)
+
+	rw := get(c, url)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "packer", rw.Body.String())
+	assert.Equal(t, "image/png", rw.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=31536000, immutable", rw.Header().Get("Cache-Control"))
+
+	rw = get(c, url, "If-None-Match", rw.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+
+	url, _ = c.URL("icons/vault.png")
+	rw = get(c, url)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "vault", rw.Body.String())
+}
+
+func TestStaleAndUnhashedURLsRedirect(t *testing.T) {
+	c := setupCatalog(t)
+	url, _ := c.URL("icons/vault.png")
+
+	for _, path := range []string{"/static/0123456789ab/icons/vault.png", "/static/icons/vault.png"} {
+		rw := get(c, path)
+		assert.Equal(t, http.StatusFound, rw.Code, path)
+		assert.Equal(t, url, rw.Header().Get("Location"), path)
+		assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"), path)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get(c, "/static/missing.png").Code)
+	assert.Equal(t, http.StatusNotFound, get(c, "/static/0123456789ab/missing.png").Code)
+}
+
+func TestManifestListsTheCurrentURLs(t *testing.T) {
+	c := setupCatalog(t)
+
+	rw := get(c, Prefix+ManifestName)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.True(t, strings.HasPrefix(rw.Header().Get("Content-Type"), "application/json"))
+	assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"))
+
+	manifest := map[string]string{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &manifest))
+	assert.Equal(t, c.Manifest(), manifest)
+	assert.Len(t, manifest, 2)
+
+	rw = get(c, Prefix+ManifestName, "If-None-Match", rw.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+}
+
+func TestEmbeddedAssetsHaveTheCoffeeImages(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+
+	for _, name := range []string{"packer.png", "vault.png", "nomad.png", "terraform.png", "vagrant.png", "consul.png"} {
+		url, ok := c.URL(name)
+		require.True(t, ok, name)
+		assert.Equal(t, http.StatusOK, get(c, url).Code, name)
+	}
+}
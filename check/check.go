@@ -0,0 +1,203 @@
+// Package check implements the preflight checks run by `coffee-service check`,
+// validating the configuration and the connectivity to the services the
+// coffee-service depends on.
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// Timeout bounds every connectivity check
+const Timeout = 5 * time.Second
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	// Pass means the check succeeded
+	Pass Status = "pass"
+	// Fail means the check failed and the service will not work
+	Fail Status = "fail"
+	// Skip means the check does not apply to this configuration
+	Skip Status = "skip"
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name       string  `json:"name"`
+	Status     Status  `json:"status"`
+	Message    string  `json:"message,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of every check
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+// ToJSON converts the report to json
+func (r *Report) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// check is a named check. run reports whether the check was skipped, a
+// message describing the outcome and an error when the check failed.
+type check struct {
+	name string
+	run  func(ctx context.Context) (skipped bool, message string, err error)
+}
+
+// Run validates cfg, checks the connectivity to the database, Vault and
+// Consul, and that the database schema is at the version of the code.
+// Connectivity checks which do not apply to cfg are skipped.
+func Run(ctx context.Context, cfg *config.Config, client *http.Client) Report {
+	checks := []check{
+		{name: "config", run: func(ctx context.Context) (bool, string, error) {
+			return false, "", validate(cfg)
+		}},
+		{name: "database", run: func(ctx context.Context) (bool, string, error) {
+			if !cfg.UsesBackend(config.PostgresBackend) {
+				return true, fmt.Sprintf("no backend is %s", config.PostgresBackend), nil
+			}
+			return false, "", data.CheckConnection(ctx, cfg.ConnectionString)
+		}},
+		{name: "schema", run: func(ctx context.Context) (bool, string, error) {
+			if !cfg.UsesBackend(config.PostgresBackend) {
+				return true, fmt.Sprintf("no backend is %s", config.PostgresBackend), nil
+			}
+			version, err := data.CheckSchema(ctx, cfg.ConnectionString, cfg.DBMigrate)
+			return false, fmt.Sprintf("version %d, the code expects %d", version, data.SchemaVersion), err
+		}},
+		{name: "vault", run: func(ctx context.Context) (bool, string, error) {
+			if cfg.VaultAddress == "" {
+				return true, fmt.Sprintf("%s is not set", config.VaultAddress), nil
+			}
+			return false, cfg.VaultAddress, VaultHealth(ctx, client, cfg.VaultAddress)
+		}},
+		{name: "consul", run: func(ctx context.Context) (bool, string, error) {
+			if cfg.ConsulAddress == "" {
+				return true, fmt.Sprintf("%s is not set", config.ConsulAddress), nil
+			}
+			return false, cfg.ConsulAddress, ConsulLeader(ctx, client, cfg.ConsulAddress)
+		}},
+	}
+
+	report := Report{OK: true, Checks: make([]Result, 0, len(checks))}
+	for _, c := range checks {
+		result := runCheck(ctx, c)
+		if result.Status == Fail {
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+// runCheck runs a single check bounded by Timeout
+func runCheck(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	start := time.Now()
+	skipped, message, err := c.run(ctx)
+	result := Result{
+		Name:       c.name,
+		Status:     Pass,
+		Message:    message,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	switch {
+	case err != nil:
+		result.Status = Fail
+		result.Message = err.Error()
+	case skipped:
+		result.Status = Skip
+	}
+
+	return result
+}
+
+// validate reports every configuration error at once
+func validate(cfg *config.Config) error {
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return &config.ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+// VaultHealth fails unless Vault reports itself initialized and unsealed.
+// Standby and performance standby nodes are healthy.
+func VaultHealth(ctx context.Context, client *http.Client, address string) error {
+	status, _, err := get(ctx, client, address, "/v1/sys/health")
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case http.StatusOK, http.StatusTooManyRequests, 472, 473:
+		return nil
+	case http.StatusNotImplemented:
+		return fmt.Errorf("vault is not initialized")
+	case http.StatusServiceUnavailable:
+		return fmt.Errorf("vault is sealed")
+	}
+	return fmt.Errorf("unexpected vault health status %d", status)
+}
+
+// ConsulLeader fails unless the Consul cluster has elected a leader
+func ConsulLeader(ctx context.Context, client *http.Client, address string) error {
+	status, body, err := get(ctx, client, address, "/v1/status/leader")
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("unexpected consul status %d", status)
+	}
+
+	var leader string
+	if err := json.Unmarshal(body, &leader); err != nil {
+		return fmt.Errorf("unable to parse consul leader: %w", err)
+	}
+	if leader == "" {
+		return fmt.Errorf("consul has no leader")
+	}
+	return nil
+}
+
+// get requests path from address, which like VAULT_ADDR and CONSUL_HTTP_ADDR
+// may omit the scheme
+func get(ctx context.Context, client *http.Client, address, path string) (int, []byte, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
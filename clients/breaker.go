@@ -0,0 +1,102 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// ErrCircuitOpen is returned for requests sent while the circuit of their
+// client is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breaker is a circuit breaker failing requests without sending them after a
+// number of consecutive failures, so a struggling upstream gets time to
+// recover and callers do not wait for requests which are likely to fail.
+// Once the cooldown has passed a single trial request is let through, its
+// outcome closes or reopens the circuit.
+type breaker struct {
+	next    http.RoundTripper
+	options Options
+	now     func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	// opened is when the circuit opened, zero while it is closed
+	opened time.Time
+	trial  bool
+}
+
+// newBreaker wraps next with a circuit breaker
+func newBreaker(next http.RoundTripper, options Options) *breaker {
+	return &breaker{next: next, options: options, now: time.Now}
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *breaker) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !b.allow() {
+		b.options.Metrics.IncrCounter("clients.breaker.rejected", 1, metrics.Label{Name: "client", Value: b.options.Name})
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := b.next.RoundTrip(r)
+	switch {
+	case err != nil && r.Context().Err() != nil:
+		// requests cancelled by the caller say nothing about the upstream
+		b.release()
+	default:
+		b.record(!failed(resp, err))
+	}
+	return resp, err
+}
+
+// allow reports whether a request may be sent, it lets a single trial
+// request through once the cooldown of an open circuit has passed
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.opened.IsZero() {
+		return true
+	}
+	if b.trial || b.now().Sub(b.opened) < b.options.BreakerCooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// release lets another trial request through after one was cancelled
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// record counts the outcome of a request, opening the circuit after enough
+// consecutive failures or a failed trial and closing it after a success
+func (b *breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	trial := b.trial
+	b.trial = false
+	if ok {
+		b.failures = 0
+		if !b.opened.IsZero() {
+			b.opened = time.Time{}
+			b.options.Metrics.SetGauge("clients.breaker.open", 0, metrics.Label{Name: "client", Value: b.options.Name})
+		}
+		return
+	}
+
+	b.failures++
+	if trial || b.failures >= b.options.BreakerFailures {
+		b.opened = b.now()
+		b.options.Metrics.SetGauge("clients.breaker.open", 1, metrics.Label{Name: "client", Value: b.options.Name})
+	}
+}
@@ -0,0 +1,61 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	server, requests := failingServer(t, 3)
+	c, err := New(Options{BreakerFailures: 3, BreakerCooldown: time.Minute})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	_, err = c.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+}
+
+func TestBreakerClosesAfterASuccessfulTrial(t *testing.T) {
+	server, requests := failingServer(t, 2)
+	b := newBreaker(http.DefaultTransport, Options{BreakerFailures: 1, BreakerCooldown: time.Minute, Metrics: metrics.FanoutSink{}})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	c := &http.Client{Transport: b}
+
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = c.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// the failed trial reopens the circuit
+	now = now.Add(time.Minute)
+	resp, err = c.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = c.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		resp, err = c.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+}
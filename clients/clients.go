@@ -0,0 +1,137 @@
+// Package clients builds the HTTP clients the coffee-service calls other
+// services with, e.g. the product-api, the ingredients service, Vault and
+// Consul. Every client gets the same timeouts, retry policy, circuit breaker
+// and TLS settings, configured once with the HTTP_CLIENT variables.
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// defaultRetryDelay is the delay before the first retry when Options do not
+// set one
+const defaultRetryDelay = 100 * time.Millisecond
+
+// Options configure a client
+type Options struct {
+	// Name identifies the client in metrics, e.g. product-api
+	Name string
+	// Timeout limits every attempt, including reading the response body,
+	// disabled when 0
+	Timeout time.Duration
+	// Retries is the number of times safe requests, e.g. GET or DELETE, are
+	// retried after network errors and server errors. Other requests are only
+	// retried with an Idempotency-Key header, they may not be idempotent.
+	Retries int
+	// RetryDelay is the delay before the first retry, it doubles with every
+	// further retry
+	RetryDelay time.Duration
+	// BreakerFailures is the number of consecutive failures which open the
+	// circuit, disabled when 0
+	BreakerFailures int
+	// BreakerCooldown is the time an open circuit fails requests before a
+	// single trial request is let through
+	BreakerCooldown time.Duration
+	TLS             TLSOptions
+	Metrics         metrics.Sink
+}
+
+// TLSOptions configure the TLS connections of a client
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the CAs server certificates are verified
+	// with, the system roots when empty
+	CAFile string
+	// CertFile and KeyFile are the PEM encoded certificate and key presented
+	// to servers requiring mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables the verification of server certificates,
+	// only meant for demos with self signed certificates
+	InsecureSkipVerify bool
+}
+
+// FromConfig returns the options of the client name from the shared
+// HTTP_CLIENT configuration, callers override the settings their upstream
+// needs, e.g. a shorter timeout
+func FromConfig(cfg *config.Config, name string) Options {
+	return Options{
+		Name:            name,
+		Timeout:         cfg.HTTPClientTimeout,
+		Retries:         cfg.HTTPClientRetries,
+		BreakerFailures: cfg.HTTPBreakerFailures,
+		BreakerCooldown: cfg.HTTPBreakerCooldown,
+		TLS: TLSOptions{
+			CAFile:             cfg.HTTPClientCAFile,
+			CertFile:           cfg.HTTPClientCertFile,
+			KeyFile:            cfg.HTTPClientKeyFile,
+			InsecureSkipVerify: cfg.HTTPClientInsecure,
+		},
+		Metrics: cfg.Metrics,
+	}
+}
+
+// New creates a client, it fails when the TLS files can not be loaded
+func New(options Options) (*http.Client, error) {
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = defaultRetryDelay
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+
+	tlsConfig, err := options.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = &retryTransport{next: transport, options: options}
+	if options.BreakerFailures > 0 {
+		rt = newBreaker(rt, options)
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// Default returns a client with the default options, used by the clients of
+// other packages when they are not given one
+func Default() *http.Client {
+	// the default options load no TLS files so New can not fail
+	c, _ := New(Options{Name: "default"})
+	return c
+}
+
+// Config returns the tls.Config of the options
+func (o TLSOptions) Config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,114 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM writes the PEM block to a temporary file and returns its path
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	dir, err := ioutil.TempDir("", "clients")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "file.pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// clientCertificate creates a self signed client certificate, it returns the
+// paths of the certificate and key files and the parsed certificate
+func clientCertificate(t *testing.T) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "coffee-service"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, "CERTIFICATE", der), writePEM(t, "EC PRIVATE KEY", keyDER), cert
+}
+
+func TestNewVerifiesServersWithTheCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c, err := New(Options{})
+	require.NoError(t, err)
+	_, err = c.Get(server.URL)
+	assert.Error(t, err, "the test server certificate is not trusted by the system roots")
+
+	c, err = New(Options{TLS: TLSOptions{CAFile: writePEM(t, "CERTIFICATE", server.Certificate().Raw)}})
+	require.NoError(t, err)
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewPresentsTheClientCertificate(t *testing.T) {
+	certFile, keyFile, cert := clientCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: roots}
+	server.StartTLS()
+	defer server.Close()
+
+	c, err := New(Options{TLS: TLSOptions{
+		CAFile:   writePEM(t, "CERTIFICATE", server.Certificate().Raw),
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}})
+	require.NoError(t, err)
+
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "coffee-service", string(body))
+}
+
+func TestNewFailsForInvalidTLSFiles(t *testing.T) {
+	_, err := New(Options{TLS: TLSOptions{CAFile: "/does/not/exist.pem"}})
+	assert.Error(t, err)
+
+	_, err = New(Options{TLS: TLSOptions{CAFile: writePEM(t, "NOTHING", []byte("garbage"))}})
+	assert.Error(t, err)
+
+	certFile, _, _ := clientCertificate(t)
+	_, err = New(Options{TLS: TLSOptions{CertFile: certFile}})
+	assert.Error(t, err)
+}
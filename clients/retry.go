@@ -0,0 +1,107 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// IdempotencyKeyHeader marks a write the upstream applies at most once per
+// key, so that it can be retried like a safe request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// retryTransport bounds every attempt by the timeout and retries safe
+// requests which failed with a network error or a server error
+type retryTransport struct {
+	next    http.RoundTripper
+	options Options
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	attempts := 1
+	if safe(r) {
+		attempts += t.options.Retries
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && r.GetBody != nil {
+			// every attempt sends the body from the start
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.Body = body
+		}
+		resp, err := t.attempt(r)
+		if attempt >= attempts || r.Context().Err() != nil || !failed(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// drained so the connection can be reused
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		t.options.Metrics.IncrCounter("clients.retries", 1, metrics.Label{Name: "client", Value: t.options.Name})
+		select {
+		case <-time.After(t.options.RetryDelay << uint(attempt-1)):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+// attempt sends the request once, the timeout keeps running until the
+// response body is closed
+func (t *retryTransport) attempt(r *http.Request) (*http.Response, error) {
+	if t.options.Timeout <= 0 {
+		return t.next.RoundTrip(r)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), t.options.Timeout)
+	resp, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// safe reports whether r can be sent again, safe methods and DELETE without a
+// body, and writes with an idempotency key whose body can be sent again
+func safe(r *http.Request) bool {
+	noBody := r.Body == nil || r.Body == http.NoBody
+	if r.Header.Get(IdempotencyKeyHeader) != "" {
+		return noBody || r.GetBody != nil
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return noBody
+	}
+	return false
+}
+
+// failed reports whether an attempt failed with a network error or a server
+// error
+func failed(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// cancelBody releases the timeout of an attempt once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the timeout
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,126 @@
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingServer responds with a server error to the first failures requests
+func failingServer(t *testing.T, failures int32) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryTransportRetriesSafeRequests(t *testing.T) {
+	server, requests := failingServer(t, 2)
+	c, err := New(Options{Retries: 2, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+}
+
+func TestRetryTransportReturnsTheLastFailure(t *testing.T) {
+	server, requests := failingServer(t, 5)
+	c, err := New(Options{Retries: 1, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+}
+
+func TestRetryTransportDoesNotRetryWrites(t *testing.T) {
+	server, requests := failingServer(t, 1)
+	c, err := New(Options{Retries: 2, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Post(server.URL, "text/plain", strings.NewReader("order"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestRetryTransportRetriesWritesWithAnIdempotencyKey(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "order", string(body))
+		assert.Equal(t, "order-7", r.Header.Get(IdempotencyKeyHeader))
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	c, err := New(Options{Retries: 2, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+
+	r, err := http.NewRequest("POST", server.URL, strings.NewReader("order"))
+	require.NoError(t, err)
+	r.Header.Set(IdempotencyKeyHeader, "order-7")
+	resp, err := c.Do(r)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRetryTransportTimesOutEveryAttempt(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c, err := New(Options{Timeout: 50 * time.Millisecond, Retries: 1, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRetryTransportStopsWhenCancelled(t *testing.T) {
+	server, requests := failingServer(t, 5)
+	c, err := New(Options{Retries: 5, RetryDelay: time.Hour})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	_, err = c.Do(r)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
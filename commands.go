@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/check"
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// preflight implements `coffee-service check`. It prints the report of the
+// preflight checks to stdout and returns the process exit code, non zero when
+// any check failed.
+func preflight(path string) int {
+	// Lifecycle event
+	hclog.Default().Info("Loading configuration", "file", path)
+	cfg, err := config.NewFromFileAndEnv(path)
+
+	var report check.Report
+	if err != nil {
+		// the remaining checks need a valid configuration
+		report = check.Report{Checks: []check.Result{{Name: "config", Status: check.Fail, Message: err.Error()}}}
+	} else {
+		// Lifecycle event
+		cfg.Logger.Info("Running preflight checks")
+		options := clients.FromConfig(cfg, "check")
+		options.Timeout = check.Timeout
+		if client, err := clients.New(options); err != nil {
+			// the connectivity checks need the TLS settings
+			report = check.Report{Checks: []check.Result{{Name: "client", Status: check.Fail, Message: err.Error()}}}
+		} else {
+			report = check.Run(context.Background(), cfg, client)
+		}
+	}
+
+	d, err := report.ToJSON()
+	if err != nil {
+		// Unrecoverable error
+		hclog.Default().Error("Unable to encode preflight report", "error", err)
+		return 1
+	}
+	fmt.Println(string(d))
+
+	if !report.OK {
+		// Lifecycle event
+		hclog.Default().Error("Preflight checks failed")
+		return 1
+	}
+
+	// Lifecycle event
+	hclog.Default().Info("Preflight checks passed")
+	return 0
+}
+
+// describeConfig implements `coffee-service config`. It prints the effective
+// configuration with secrets masked, or documents every environment variable
+// with --describe, and returns the process exit code.
+func describeConfig(path string, args []string) int {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	describe := flags.Bool("describe", false, "document every environment variable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *describe {
+		fmt.Print(config.Describe())
+		return 0
+	}
+
+	lookup, err := config.Lookup(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	values, errs := config.Resolve(lookup)
+	effective := config.Effective(values)
+	for i := 0; i < len(effective); i += 2 {
+		fmt.Printf("%s=%s\n", effective[i], effective[i+1])
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, (&config.ValidationError{Errors: errs}).Error())
+		return 1
+	}
+	return 0
+}
@@ -2,11 +2,12 @@ package config
 
 import (
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
 )
 
 // VersionKey supports a type safe string discriminator for service version.
@@ -42,6 +43,49 @@ func VersionKeyFromString(key string) VersionKey {
 	return VUnknown
 }
 
+// Backends storing the catalogue
+const (
+	// PostgresBackend stores the catalogue in Postgres, used by v1 and v2
+	PostgresBackend = "postgres"
+	// MemoryBackend stores the catalogue in memory, used by v3
+	MemoryBackend = "memory"
+)
+
+// Modes of seeding the generated coffees
+const (
+	// SeedAlways generates the coffees at every start
+	SeedAlways = "always"
+	// SeedSkip generates the coffees unless generated coffees exist
+	SeedSkip = "skip"
+	// SeedMerge generates the coffees whose slugs are not taken yet
+	SeedMerge = "merge"
+	// SeedForce deletes the generated coffees before generating them again
+	SeedForce = "force"
+)
+
+// Identifiers of the new coupons, order records and points entries
+const (
+	// SerialIDs identifies them by their keys only, the serial ID of a points
+	// entry, the product-api ID of an order and the code of a coupon
+	SerialIDs = "serial"
+	// UUIDv7IDs also assigns them a time ordered UUIDv7 on insert
+	UUIDv7IDs = "uuidv7"
+)
+
+// Backend returns the backend of the configured version
+func (c *Config) Backend() string {
+	if c.Version == V3 {
+		return MemoryBackend
+	}
+	return PostgresBackend
+}
+
+// UsesBackend reports whether name is the backend of the configured version,
+// the migration backend or the shadow backend
+func (c *Config) UsesBackend(name string) bool {
+	return c.Backend() == name || c.MigrationBackend == name || c.ShadowBackend == name
+}
+
 // EnvVarKey supports a type safe string discriminator for environment variables.
 type EnvVarKey string
 
@@ -50,25 +94,12 @@ func (e EnvVarKey) String() string {
 	return string(e)
 }
 
-// EnvVarKeyFromString casts a string to an EnvVarKey
+// EnvVarKeyFromString casts a string to an EnvVarKey declared in the Schema
 func EnvVarKeyFromString(key string) EnvVarKey {
-	switch key {
-	case Username.String():
-		return Username
-	case Password.String():
-		return Password
-	case LogFormat.String():
-		return LogFormat
-	case LogLevel.String():
-		return LogLevel
-	case BindAddress.String():
-		return BindAddress
-	case MetricsAddress.String():
-		return MetricsAddress
-	case DBTraceEnabled.String():
-		return DBTraceEnabled
-	case Version.String():
-		return Version
+	for _, v := range Schema {
+		if v.Key.String() == key {
+			return v.Key
+		}
 	}
 
 	return Unknown
@@ -87,8 +118,260 @@ const (
 	BindAddress EnvVarKey = "BIND_ADDRESS"
 	//MetricsAddress EnvVarKey
 	MetricsAddress EnvVarKey = "METRICS_ADDRESS"
+	// GRPCAddress EnvVarKey
+	GRPCAddress EnvVarKey = "GRPC_ADDRESS"
 	// DBTraceEnabled EnvVarKey
 	DBTraceEnabled EnvVarKey = "DB_TRACE_ENABLED"
+	// ResponseEnvelope EnvVarKey
+	ResponseEnvelope EnvVarKey = "RESPONSE_ENVELOPE"
+	// Compression EnvVarKey
+	Compression EnvVarKey = "COMPRESSION"
+	// BrotliQuality EnvVarKey
+	BrotliQuality EnvVarKey = "BROTLI_QUALITY"
+	// PopularityFile EnvVarKey
+	PopularityFile EnvVarKey = "POPULARITY_FILE"
+	// ImageDir EnvVarKey
+	ImageDir EnvVarKey = "IMAGE_DIR"
+	// DBPrepareStatements EnvVarKey
+	DBPrepareStatements EnvVarKey = "DB_PREPARE_STATEMENTS"
+	// DBPostGIS EnvVarKey
+	DBPostGIS EnvVarKey = "DB_POSTGIS"
+	// DBReconnectBackoff EnvVarKey
+	DBReconnectBackoff EnvVarKey = "DB_RECONNECT_BACKOFF"
+	// DBMigrate EnvVarKey
+	DBMigrate EnvVarKey = "DB_MIGRATE"
+	// DBReadRetries EnvVarKey
+	DBReadRetries EnvVarKey = "DB_READ_RETRIES"
+	// DBWriteRetries EnvVarKey
+	DBWriteRetries EnvVarKey = "DB_WRITE_RETRIES"
+	// DBRetryBackoff EnvVarKey
+	DBRetryBackoff EnvVarKey = "DB_RETRY_BACKOFF"
+	// DBRetryMaxBackoff EnvVarKey
+	DBRetryMaxBackoff EnvVarKey = "DB_RETRY_MAX_BACKOFF"
+	// DBRetryBudget EnvVarKey
+	DBRetryBudget EnvVarKey = "DB_RETRY_BUDGET"
+	// DBStatsHeaders EnvVarKey
+	DBStatsHeaders EnvVarKey = "DB_STATS_HEADERS"
+	// DebugRequests EnvVarKey
+	DebugRequests EnvVarKey = "DEBUG_REQUESTS"
+	// SlowQueryThreshold EnvVarKey
+	SlowQueryThreshold EnvVarKey = "SLOW_QUERY_THRESHOLD"
+	// SlowQueryLimit EnvVarKey
+	SlowQueryLimit EnvVarKey = "SLOW_QUERY_LIMIT"
+	// SeedScale EnvVarKey
+	SeedScale EnvVarKey = "SEED_SCALE"
+	// SeedRandom EnvVarKey
+	SeedRandom EnvVarKey = "SEED_RANDOM"
+	// SeedMode EnvVarKey
+	SeedMode EnvVarKey = "SEED_MODE"
+	// WatchdogLimit EnvVarKey
+	WatchdogLimit EnvVarKey = "WATCHDOG_LIMIT"
+	// FastJSON EnvVarKey
+	FastJSON EnvVarKey = "FAST_JSON"
+	// StrictJSON EnvVarKey
+	StrictJSON EnvVarKey = "STRICT_JSON"
+	// SessionTokens EnvVarKey
+	SessionTokens EnvVarKey = "SESSION_TOKENS"
+	// MirrorURL EnvVarKey
+	MirrorURL EnvVarKey = "MIRROR_URL"
+	// MirrorPercent EnvVarKey
+	MirrorPercent EnvVarKey = "MIRROR_PERCENT"
+	// RequestTimeout EnvVarKey
+	RequestTimeout EnvVarKey = "REQUEST_TIMEOUT"
+	// LatencyRules EnvVarKey
+	LatencyRules EnvVarKey = "LATENCY_RULES"
+	// MemoryPressureLimit EnvVarKey
+	MemoryPressureLimit EnvVarKey = "MEMORY_PRESSURE_LIMIT"
+	// CPUBurnCores EnvVarKey
+	CPUBurnCores EnvVarKey = "CPU_BURN_CORES"
+	// CPUBurnMaxDuration EnvVarKey
+	CPUBurnMaxDuration EnvVarKey = "CPU_BURN_MAX_DURATION"
+	// MiddlewareHealth EnvVarKey
+	MiddlewareHealth EnvVarKey = "MIDDLEWARE_HEALTH"
+	// MiddlewareCoffees EnvVarKey
+	MiddlewareCoffees EnvVarKey = "MIDDLEWARE_COFFEES"
+	// MiddlewareSearch EnvVarKey
+	MiddlewareSearch EnvVarKey = "MIDDLEWARE_SEARCH"
+	// MiddlewareAdmin EnvVarKey
+	MiddlewareAdmin EnvVarKey = "MIDDLEWARE_ADMIN"
+	// MiddlewareOrders EnvVarKey
+	MiddlewareOrders EnvVarKey = "MIDDLEWARE_ORDERS"
+	// AuthToken EnvVarKey
+	AuthToken EnvVarKey = "AUTH_TOKEN"
+	// RateLimit EnvVarKey
+	RateLimit EnvVarKey = "RATE_LIMIT"
+	// CacheTTL EnvVarKey
+	CacheTTL EnvVarKey = "CACHE_TTL"
+	// CacheStale EnvVarKey
+	CacheStale EnvVarKey = "CACHE_STALE"
+	// CacheTTLHealth EnvVarKey
+	CacheTTLHealth EnvVarKey = "CACHE_TTL_HEALTH"
+	// CacheStaleHealth EnvVarKey
+	CacheStaleHealth EnvVarKey = "CACHE_STALE_HEALTH"
+	// CacheTTLCoffees EnvVarKey
+	CacheTTLCoffees EnvVarKey = "CACHE_TTL_COFFEES"
+	// CacheStaleCoffees EnvVarKey
+	CacheStaleCoffees EnvVarKey = "CACHE_STALE_COFFEES"
+	// CacheTTLSearch EnvVarKey
+	CacheTTLSearch EnvVarKey = "CACHE_TTL_SEARCH"
+	// CacheStaleSearch EnvVarKey
+	CacheStaleSearch EnvVarKey = "CACHE_STALE_SEARCH"
+	// CacheTTLAdmin EnvVarKey
+	CacheTTLAdmin EnvVarKey = "CACHE_TTL_ADMIN"
+	// CacheStaleAdmin EnvVarKey
+	CacheStaleAdmin EnvVarKey = "CACHE_STALE_ADMIN"
+	// AccessLogFormat EnvVarKey
+	AccessLogFormat EnvVarKey = "ACCESS_LOG_FORMAT"
+	// AccessLogFile EnvVarKey
+	AccessLogFile EnvVarKey = "ACCESS_LOG_FILE"
+	// AccessLogMaxSize EnvVarKey
+	AccessLogMaxSize EnvVarKey = "ACCESS_LOG_MAX_SIZE"
+	// AccessLogMaxBackups EnvVarKey
+	AccessLogMaxBackups EnvVarKey = "ACCESS_LOG_MAX_BACKUPS"
+	// LogShipFormat EnvVarKey
+	LogShipFormat EnvVarKey = "LOG_SHIP_FORMAT"
+	// LogShipEndpoint EnvVarKey
+	LogShipEndpoint EnvVarKey = "LOG_SHIP_ENDPOINT"
+	// LogShipBatchSize EnvVarKey
+	LogShipBatchSize EnvVarKey = "LOG_SHIP_BATCH_SIZE"
+	// LogShipInterval EnvVarKey
+	LogShipInterval EnvVarKey = "LOG_SHIP_INTERVAL"
+	// LogShipBuffer EnvVarKey
+	LogShipBuffer EnvVarKey = "LOG_SHIP_BUFFER"
+	// CrashReportDSN EnvVarKey
+	CrashReportDSN EnvVarKey = "CRASH_REPORT_DSN"
+	// StatsdAddress EnvVarKey
+	StatsdAddress EnvVarKey = "STATSD_ADDRESS"
+	// StatsdFormat EnvVarKey
+	StatsdFormat EnvVarKey = "STATSD_FORMAT"
+	// GCMetricsInterval EnvVarKey
+	GCMetricsInterval EnvVarKey = "GC_METRICS_INTERVAL"
+	// MigrationBackend EnvVarKey
+	MigrationBackend EnvVarKey = "MIGRATION_BACKEND"
+	// MigrationRead EnvVarKey
+	MigrationRead EnvVarKey = "MIGRATION_READ"
+	// MemoryShards EnvVarKey
+	MemoryShards EnvVarKey = "MEMORY_SHARDS"
+	// ShadowBackend EnvVarKey
+	ShadowBackend EnvVarKey = "SHADOW_BACKEND"
+	// ShadowSample EnvVarKey
+	ShadowSample EnvVarKey = "SHADOW_SAMPLE"
+	// SnapshotTTL EnvVarKey
+	SnapshotTTL EnvVarKey = "SNAPSHOT_TTL"
+	// ChangesRetention EnvVarKey
+	ChangesRetention EnvVarKey = "CHANGES_RETENTION"
+	// EntityIDs EnvVarKey
+	EntityIDs EnvVarKey = "ENTITY_IDS"
+	// DuplicateSimilarity EnvVarKey
+	DuplicateSimilarity EnvVarKey = "DUPLICATE_SIMILARITY"
+	// RaftNodeID EnvVarKey
+	RaftNodeID EnvVarKey = "RAFT_NODE_ID"
+	// RaftBindAddress EnvVarKey
+	RaftBindAddress EnvVarKey = "RAFT_BIND_ADDRESS"
+	// RaftPeers EnvVarKey
+	RaftPeers EnvVarKey = "RAFT_PEERS"
+	// TLSCertFile EnvVarKey
+	TLSCertFile EnvVarKey = "TLS_CERT_FILE"
+	// TLSKeyFile EnvVarKey
+	TLSKeyFile EnvVarKey = "TLS_KEY_FILE"
+	// TLSClientCAFile EnvVarKey
+	TLSClientCAFile EnvVarKey = "TLS_CLIENT_CA_FILE"
+	// SPIFFETrustDomain EnvVarKey
+	SPIFFETrustDomain EnvVarKey = "SPIFFE_TRUST_DOMAIN"
+	// SPIFFEAllowedIDs EnvVarKey
+	SPIFFEAllowedIDs EnvVarKey = "SPIFFE_ALLOWED_IDS"
+	// HTTPClientTimeout EnvVarKey
+	HTTPClientTimeout EnvVarKey = "HTTP_CLIENT_TIMEOUT"
+	// HTTPClientRetries EnvVarKey
+	HTTPClientRetries EnvVarKey = "HTTP_CLIENT_RETRIES"
+	// HTTPBreakerFailures EnvVarKey
+	HTTPBreakerFailures EnvVarKey = "HTTP_CLIENT_BREAKER_FAILURES"
+	// HTTPBreakerCooldown EnvVarKey
+	HTTPBreakerCooldown EnvVarKey = "HTTP_CLIENT_BREAKER_COOLDOWN"
+	// HTTPClientCAFile EnvVarKey
+	HTTPClientCAFile EnvVarKey = "HTTP_CLIENT_CA_FILE"
+	// HTTPClientCertFile EnvVarKey
+	HTTPClientCertFile EnvVarKey = "HTTP_CLIENT_CERT_FILE"
+	// HTTPClientKeyFile EnvVarKey
+	HTTPClientKeyFile EnvVarKey = "HTTP_CLIENT_KEY_FILE"
+	// HTTPClientInsecure EnvVarKey
+	HTTPClientInsecure EnvVarKey = "HTTP_CLIENT_INSECURE_SKIP_VERIFY"
+	// ProductAPIAddress EnvVarKey
+	ProductAPIAddress EnvVarKey = "PRODUCT_API_ADDRESS"
+	// ProductAPITimeout EnvVarKey
+	ProductAPITimeout EnvVarKey = "PRODUCT_API_TIMEOUT"
+	// ProductAPIRetries EnvVarKey
+	ProductAPIRetries EnvVarKey = "PRODUCT_API_RETRIES"
+	// PaymentsAddress EnvVarKey
+	PaymentsAddress EnvVarKey = "PAYMENTS_ADDRESS"
+	// PaymentsTimeout EnvVarKey
+	PaymentsTimeout EnvVarKey = "PAYMENTS_TIMEOUT"
+	// PaymentsRetries EnvVarKey
+	PaymentsRetries EnvVarKey = "PAYMENTS_RETRIES"
+	// ReceiptTemplates EnvVarKey
+	ReceiptTemplates EnvVarKey = "RECEIPT_TEMPLATES"
+	// NotificationsProvider EnvVarKey
+	NotificationsProvider EnvVarKey = "NOTIFICATIONS_PROVIDER"
+	// NotificationsWebhookURL EnvVarKey
+	NotificationsWebhookURL EnvVarKey = "NOTIFICATIONS_WEBHOOK_URL"
+	// NotificationsSMTPAddress EnvVarKey
+	NotificationsSMTPAddress EnvVarKey = "NOTIFICATIONS_SMTP_ADDRESS"
+	// NotificationsSMTPFrom EnvVarKey
+	NotificationsSMTPFrom EnvVarKey = "NOTIFICATIONS_SMTP_FROM"
+	// NotificationsSMTPUsername EnvVarKey
+	NotificationsSMTPUsername EnvVarKey = "NOTIFICATIONS_SMTP_USERNAME"
+	// NotificationsSMTPPassword EnvVarKey
+	NotificationsSMTPPassword EnvVarKey = "NOTIFICATIONS_SMTP_PASSWORD"
+	// NotificationsRetries EnvVarKey
+	NotificationsRetries EnvVarKey = "NOTIFICATIONS_RETRIES"
+	// NotificationsBuffer EnvVarKey
+	NotificationsBuffer EnvVarKey = "NOTIFICATIONS_BUFFER"
+	// NotificationsDeadLetter EnvVarKey
+	NotificationsDeadLetter EnvVarKey = "NOTIFICATIONS_DEAD_LETTER"
+	// JobQueue EnvVarKey
+	JobQueue EnvVarKey = "JOB_QUEUE"
+	// JobPollInterval EnvVarKey
+	JobPollInterval EnvVarKey = "JOB_POLL_INTERVAL"
+	// ScheduleReseed EnvVarKey
+	ScheduleReseed EnvVarKey = "SCHEDULE_RESEED"
+	// ScheduleCacheWarm EnvVarKey
+	ScheduleCacheWarm EnvVarKey = "SCHEDULE_CACHE_WARM"
+	// CacheWarmPaths EnvVarKey
+	CacheWarmPaths EnvVarKey = "CACHE_WARM_PATHS"
+	// SchedulerLock EnvVarKey
+	SchedulerLock EnvVarKey = "SCHEDULER_LOCK"
+	// SchedulerLockTTL EnvVarKey
+	SchedulerLockTTL EnvVarKey = "SCHEDULER_LOCK_TTL"
+	// ProductAPITokenSecret EnvVarKey
+	ProductAPITokenSecret EnvVarKey = "PRODUCT_API_TOKEN_SECRET"
+	// LoyaltyEarnRate EnvVarKey
+	LoyaltyEarnRate EnvVarKey = "LOYALTY_EARN_RATE"
+	// LoyaltyPointValue EnvVarKey
+	LoyaltyPointValue EnvVarKey = "LOYALTY_POINT_VALUE"
+	// Baristas EnvVarKey
+	Baristas EnvVarKey = "BARISTAS"
+	// IngredientsAddress EnvVarKey
+	IngredientsAddress EnvVarKey = "INGREDIENTS_ADDRESS"
+	// IngredientsTimeout EnvVarKey
+	IngredientsTimeout EnvVarKey = "INGREDIENTS_TIMEOUT"
+	// IngredientsHedge EnvVarKey
+	IngredientsHedge EnvVarKey = "INGREDIENTS_HEDGE"
+	// IngredientsRetries EnvVarKey
+	IngredientsRetries EnvVarKey = "INGREDIENTS_RETRIES"
+	// IngredientsBudget EnvVarKey
+	IngredientsBudget EnvVarKey = "INGREDIENTS_BUDGET"
+	// SLOAvailability EnvVarKey
+	SLOAvailability EnvVarKey = "SLO_AVAILABILITY"
+	// SLOLatency EnvVarKey
+	SLOLatency EnvVarKey = "SLO_LATENCY"
+	// SLOLatencyTarget EnvVarKey
+	SLOLatencyTarget EnvVarKey = "SLO_LATENCY_TARGET"
+	// SLOWindow EnvVarKey
+	SLOWindow EnvVarKey = "SLO_WINDOW"
+	// VaultAddress EnvVarKey
+	VaultAddress EnvVarKey = "VAULT_ADDR"
+	// ConsulAddress EnvVarKey
+	ConsulAddress EnvVarKey = "CONSUL_HTTP_ADDR"
 	// Version EnvVarKey
 	Version EnvVarKey = "VERSION"
 	// Unknown EnvVarKey
@@ -97,51 +380,323 @@ const (
 
 // Config defines the service runtime configuration
 type Config struct {
-	ConnectionString string
-	BindAddress      string
-	MetricsAddress   string
-	DBTraceEnabled   bool
+	ConnectionString    string
+	BindAddress         string
+	MetricsAddress      string
+	GRPCAddress         string
+	DBTraceEnabled      bool
+	ResponseEnvelope    bool
+	FastJSON            bool
+	StrictJSON          bool
+	SessionTokens       bool
+	MirrorURL           string
+	MirrorPercent       float64
+	Compression         bool
+	BrotliQuality       int
+	PopularityFile      string
+	ImageDir            string
+	DBStatsHeaders      bool
+	DebugRequests       bool
+	SlowQueryThreshold  time.Duration
+	SlowQueryLimit      int
+	DBPrepareStatements bool
+	DBPostGIS           bool
+	DBReconnectBackoff  time.Duration
+	DBMigrate           bool
+	DBReadRetries       int
+	DBWriteRetries      int
+	DBRetryBackoff      time.Duration
+	DBRetryMaxBackoff   time.Duration
+	DBRetryBudget       float64
+	SeedScale           int
+	SeedRandom          int64
+	SeedMode            string
+	WatchdogLimit       time.Duration
+	RequestTimeout      time.Duration
+	LatencyRules        string
+	MemoryPressureLimit int
+	CPUBurnCores        int
+	CPUBurnMaxDuration  time.Duration
+	RouteMiddleware     map[string][]string
+	AuthToken           string
+	RateLimit           int
+	CacheTTL            time.Duration
+	CacheStale          time.Duration
+	CachePolicies       map[string]CachePolicy
+	AccessLogFormat     string
+	AccessLogFile       string
+	// AccessLogMaxSize is the size in megabytes the access log file is
+	// rotated at
+	AccessLogMaxSize    int
+	AccessLogMaxBackups int
+	LogShipFormat       string
+	LogShipEndpoint     string
+	LogShipBatchSize    int
+	LogShipInterval     time.Duration
+	LogShipBuffer       int
+	CrashReportDSN      string
+	LogLevel            hclog.Level
+	StatsdAddress       string
+	StatsdFormat        string
+	GCMetricsInterval   time.Duration
+	MigrationBackend    string
+	MigrationRead       string
+	MemoryShards        int
+	ShadowBackend       string
+	ShadowSample        float64
+	SnapshotTTL         time.Duration
+	ChangesRetention    int
+	EntityIDs           string
+	DuplicateSimilarity float64
+	RaftNodeID          string
+	RaftBindAddress     string
+	RaftPeers           []string
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSClientCAFile     string
+	SPIFFETrustDomain   string
+	SPIFFEAllowedIDs    []string
+	HTTPClientTimeout   time.Duration
+	HTTPClientRetries   int
+	HTTPBreakerFailures int
+	HTTPBreakerCooldown time.Duration
+	HTTPClientCAFile    string
+	HTTPClientCertFile  string
+	HTTPClientKeyFile   string
+	HTTPClientInsecure  bool
+	ProductAPIAddress   string
+	ProductAPITimeout   time.Duration
+	ProductAPIRetries   int
+	PaymentsAddress     string
+	PaymentsTimeout     time.Duration
+	PaymentsRetries     int
+	ReceiptTemplates    string
+	Baristas            int
+	IngredientsAddress  string
+	IngredientsTimeout  time.Duration
+	IngredientsHedge    time.Duration
+	IngredientsRetries  int
+	IngredientsBudget   float64
+	// NotificationsProvider is smtp or webhook, the confirmations of orders
+	// are not sent when empty
+	NotificationsProvider     string
+	NotificationsWebhookURL   string
+	NotificationsSMTPAddress  string
+	NotificationsSMTPFrom     string
+	NotificationsSMTPUsername string
+	NotificationsSMTPPassword string
+	NotificationsRetries      int
+	NotificationsBuffer       int
+	NotificationsDeadLetter   string
+	// JobQueue keeps the background jobs, memory or postgres
+	JobQueue        string
+	JobPollInterval time.Duration
+	// ScheduleReseed and ScheduleCacheWarm are cron expressions, the jobs are
+	// not scheduled when empty
+	ScheduleReseed    string
+	ScheduleCacheWarm string
+	CacheWarmPaths    []string
+	// SchedulerLock keeps the locks of the scheduled jobs, postgres or
+	// consul, every instance runs the jobs when empty
+	SchedulerLock    string
+	SchedulerLockTTL time.Duration
+	// ProductAPITokenSecret verifies the product-api tokens identifying
+	// users, the /me routes and loyalty points are disabled when empty
+	ProductAPITokenSecret string
+	LoyaltyEarnRate       float64
+	LoyaltyPointValue     float64
+	// SLOAvailability and SLOLatencyTarget are percentages
+	SLOAvailability  float64
+	SLOLatency       time.Duration
+	SLOLatencyTarget float64
+	SLOWindow        time.Duration
+	VaultAddress     string
+	ConsulAddress    string
 	Logger           hclog.Logger
-	Version          VersionKey
+	// Metrics receives the metrics of every component, it discards them
+	// until sinks are configured
+	Metrics metrics.Sink
+	Version VersionKey
 }
 
-// NewFromEnv aggregates the environment variables to a datastructure.
+// NewFromEnv aggregates the environment variables declared in the Schema to
+// a datastructure. It fails with every missing or invalid value at once.
 func NewFromEnv() (*Config, error) {
-	// TODO: error handling
-	username := os.Getenv(Username.String())
-	password := os.Getenv(Password.String())
+	return NewFromFileAndEnv("")
+}
+
+// NewFromFileAndEnv is NewFromEnv with the values of the configuration file
+// at path used for every variable missing from the environment. An empty path
+// only reads the environment.
+func NewFromFileAndEnv(path string) (*Config, error) {
+	lookup, err := Lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	values, errs := Resolve(lookup)
+
 	formatString := "host=localhost port=5432 user=%s password=%s dbname=products sslmode=disable"
-	bindAddress := os.Getenv(BindAddress.String())
-	metricsAddress := os.Getenv(MetricsAddress.String())
 	// TODO: Think about moving towards opentelemetry interfaces.
 	// Output: *env.String("LOG_OUTPUT", false, "stdout", "Location to write log output, default is stdout, e.g. /var/log/web.log"),
-	logLevel := os.Getenv(LogLevel.String())
-	isJSONFormat := strings.ToLower(os.Getenv(LogFormat.String())) == "json"
-	logger := hclog.New(&hclog.LoggerOptions{
+	// an intercept logger so log shipping can register itself as a sink
+	logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{
 		Name:       "coffee-service",
-		JSONFormat: isJSONFormat,
-		Level:      hclog.LevelFromString(logLevel),
+		JSONFormat: strings.ToLower(values[LogFormat]) == "json",
+		Level:      hclog.LevelFromString(values[LogLevel]),
 	})
 
-	dbTraceEnabled := false
-	var err error
+	cfg := &Config{
+		ConnectionString:    fmt.Sprintf(formatString, values[Username], values[Password]),
+		BindAddress:         values[BindAddress],
+		MetricsAddress:      values[MetricsAddress],
+		GRPCAddress:         values[GRPCAddress],
+		DBTraceEnabled:      values.Bool(DBTraceEnabled),
+		ResponseEnvelope:    values.Bool(ResponseEnvelope),
+		FastJSON:            values.Bool(FastJSON),
+		StrictJSON:          values.Bool(StrictJSON),
+		SessionTokens:       values.Bool(SessionTokens),
+		MirrorURL:           values[MirrorURL],
+		MirrorPercent:       values.Float(MirrorPercent),
+		Compression:         values.Bool(Compression),
+		BrotliQuality:       int(values.Int(BrotliQuality)),
+		PopularityFile:      values[PopularityFile],
+		ImageDir:            values[ImageDir],
+		DBStatsHeaders:      values.Bool(DBStatsHeaders),
+		DebugRequests:       values.Bool(DebugRequests),
+		SlowQueryThreshold:  values.Duration(SlowQueryThreshold),
+		SlowQueryLimit:      int(values.Int(SlowQueryLimit)),
+		DBPrepareStatements: values.Bool(DBPrepareStatements),
+		DBPostGIS:           values.Bool(DBPostGIS),
+		DBReconnectBackoff:  values.Duration(DBReconnectBackoff),
+		DBMigrate:           values.Bool(DBMigrate),
+		DBReadRetries:       int(values.Int(DBReadRetries)),
+		DBWriteRetries:      int(values.Int(DBWriteRetries)),
+		DBRetryBackoff:      values.Duration(DBRetryBackoff),
+		DBRetryMaxBackoff:   values.Duration(DBRetryMaxBackoff),
+		DBRetryBudget:       values.Float(DBRetryBudget),
+		SeedScale:           int(values.Int(SeedScale)),
+		SeedRandom:          values.Int(SeedRandom),
+		SeedMode:            values[SeedMode],
+		WatchdogLimit:       values.Duration(WatchdogLimit),
+		RequestTimeout:      values.Duration(RequestTimeout),
+		LatencyRules:        values[LatencyRules],
+		MemoryPressureLimit: int(values.Int(MemoryPressureLimit)),
+		CPUBurnCores:        int(values.Int(CPUBurnCores)),
+		CPUBurnMaxDuration:  values.Duration(CPUBurnMaxDuration),
+		RouteMiddleware:     routeMiddleware(values),
+		AuthToken:           values[AuthToken],
+		RateLimit:           int(values.Int(RateLimit)),
+		CacheTTL:            values.Duration(CacheTTL),
+		CacheStale:          values.Duration(CacheStale),
+		CachePolicies:       cachePolicies(values),
+		AccessLogFormat:     strings.ToLower(values[AccessLogFormat]),
+		AccessLogFile:       values[AccessLogFile],
+		AccessLogMaxSize:    int(values.Int(AccessLogMaxSize)),
+		AccessLogMaxBackups: int(values.Int(AccessLogMaxBackups)),
+		LogShipFormat:       strings.ToLower(values[LogShipFormat]),
+		LogShipEndpoint:     values[LogShipEndpoint],
+		LogShipBatchSize:    int(values.Int(LogShipBatchSize)),
+		LogShipInterval:     values.Duration(LogShipInterval),
+		LogShipBuffer:       int(values.Int(LogShipBuffer)),
+		CrashReportDSN:      values[CrashReportDSN],
+		LogLevel:            hclog.LevelFromString(values[LogLevel]),
+		StatsdAddress:       values[StatsdAddress],
+		StatsdFormat:        strings.ToLower(values[StatsdFormat]),
+		GCMetricsInterval:   values.Duration(GCMetricsInterval),
+		MigrationBackend:    strings.ToLower(values[MigrationBackend]),
+		MigrationRead:       strings.ToLower(values[MigrationRead]),
+		MemoryShards:        int(values.Int(MemoryShards)),
+		ShadowBackend:       strings.ToLower(values[ShadowBackend]),
+		ShadowSample:        values.Float(ShadowSample),
+		SnapshotTTL:         values.Duration(SnapshotTTL),
+		ChangesRetention:    int(values.Int(ChangesRetention)),
+		EntityIDs:           strings.ToLower(values[EntityIDs]),
+		DuplicateSimilarity: values.Float(DuplicateSimilarity),
+		RaftNodeID:          values[RaftNodeID],
+		RaftBindAddress:     values[RaftBindAddress],
+		RaftPeers:           values.List(RaftPeers),
+		TLSCertFile:         values[TLSCertFile],
+		TLSKeyFile:          values[TLSKeyFile],
+		TLSClientCAFile:     values[TLSClientCAFile],
+		SPIFFETrustDomain:   values[SPIFFETrustDomain],
+		SPIFFEAllowedIDs:    values.List(SPIFFEAllowedIDs),
+		HTTPClientTimeout:   values.Duration(HTTPClientTimeout),
+		HTTPClientRetries:   int(values.Int(HTTPClientRetries)),
+		HTTPBreakerFailures: int(values.Int(HTTPBreakerFailures)),
+		HTTPBreakerCooldown: values.Duration(HTTPBreakerCooldown),
+		HTTPClientCAFile:    values[HTTPClientCAFile],
+		HTTPClientCertFile:  values[HTTPClientCertFile],
+		HTTPClientKeyFile:   values[HTTPClientKeyFile],
+		HTTPClientInsecure:  values.Bool(HTTPClientInsecure),
+		ProductAPIAddress:   values[ProductAPIAddress],
+		ProductAPITimeout:   values.Duration(ProductAPITimeout),
+		ProductAPIRetries:   int(values.Int(ProductAPIRetries)),
+		PaymentsAddress:     values[PaymentsAddress],
+		PaymentsTimeout:     values.Duration(PaymentsTimeout),
+		PaymentsRetries:     int(values.Int(PaymentsRetries)),
+		ReceiptTemplates:    values[ReceiptTemplates],
+		Baristas:            int(values.Int(Baristas)),
+		IngredientsAddress:  values[IngredientsAddress],
+		IngredientsTimeout:  values.Duration(IngredientsTimeout),
+		IngredientsHedge:    values.Duration(IngredientsHedge),
+		IngredientsRetries:  int(values.Int(IngredientsRetries)),
+		IngredientsBudget:   values.Float(IngredientsBudget),
+		SLOAvailability:     values.Float(SLOAvailability),
+		SLOLatency:          values.Duration(SLOLatency),
+		SLOLatencyTarget:    values.Float(SLOLatencyTarget),
+		SLOWindow:           values.Duration(SLOWindow),
+		VaultAddress:        values[VaultAddress],
+		ConsulAddress:       values[ConsulAddress],
+		Logger:              logger,
+		Metrics:             metrics.FanoutSink{},
+		Version:             VersionKeyFromString(values[Version]),
 
-	dteRaw := os.Getenv(DBTraceEnabled.String())
-	if len(dteRaw) < 4 {
-		dbTraceEnabled = false
-	} else {
-		if dbTraceEnabled, err = strconv.ParseBool(os.Getenv(DBTraceEnabled.String())); err != nil {
-			logger.Error(fmt.Sprintf("Unable to parse %s", DBTraceEnabled.String()), "error", err)
-		}
+		NotificationsProvider:     strings.ToLower(values[NotificationsProvider]),
+		NotificationsWebhookURL:   values[NotificationsWebhookURL],
+		NotificationsSMTPAddress:  values[NotificationsSMTPAddress],
+		NotificationsSMTPFrom:     values[NotificationsSMTPFrom],
+		NotificationsSMTPUsername: values[NotificationsSMTPUsername],
+		NotificationsSMTPPassword: values[NotificationsSMTPPassword],
+		NotificationsRetries:      int(values.Int(NotificationsRetries)),
+		NotificationsBuffer:       int(values.Int(NotificationsBuffer)),
+		NotificationsDeadLetter:   values[NotificationsDeadLetter],
+
+		JobQueue:        strings.ToLower(values[JobQueue]),
+		JobPollInterval: values.Duration(JobPollInterval),
+
+		ScheduleReseed:    values[ScheduleReseed],
+		ScheduleCacheWarm: values[ScheduleCacheWarm],
+		CacheWarmPaths:    values.List(CacheWarmPaths),
+		SchedulerLock:     strings.ToLower(values[SchedulerLock]),
+		SchedulerLockTTL:  values.Duration(SchedulerLockTTL),
+
+		ProductAPITokenSecret: values[ProductAPITokenSecret],
+		LoyaltyEarnRate:       values.Float(LoyaltyEarnRate),
+		LoyaltyPointValue:     values.Float(LoyaltyPointValue),
+	}
+
+	if len(errs) == 0 {
+		errs = cfg.Validate()
 	}
-	versionKey := VersionKeyFromString(os.Getenv(Version.String()))
-
-	return &Config{
-		ConnectionString: fmt.Sprintf(formatString, username, password),
-		BindAddress:      bindAddress,
-		MetricsAddress:   metricsAddress,
-		DBTraceEnabled:   dbTraceEnabled,
-		Logger:           logger,
-		Version:          versionKey,
-	}, nil
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	logger.Info("Effective configuration", Effective(values)...)
+	return cfg, nil
+}
+
+// ValidationError reports every invalid configuration value
+type ValidationError struct {
+	Errors []error
+}
+
+// Error joins the messages of every error
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "invalid configuration: " + strings.Join(messages, "; ")
 }
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v2"
+)
+
+// ReadFile reads a configuration file holding the variables of the Schema as
+// top level attributes named in lower case, e.g. bind_address. The format is
+// picked from the extension, .hcl or .json for HCL and .yaml or .yml for YAML.
+func ReadFile(path string) (Values, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hcl", ".json":
+		err = hcl.Unmarshal(d, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(d, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, use .hcl, .json, .yaml or .yml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	values := Values{}
+	for name, value := range raw {
+		key := EnvVarKeyFromString(strings.ToUpper(name))
+		if key == Unknown {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+
+		switch value.(type) {
+		case string, bool, int, int64, float64:
+			values[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or bool", path, name)
+		}
+	}
+
+	return values, nil
+}
+
+// Lookup returns a lookup function for Resolve. Environment variables take
+// precedence over the configuration file at path, which takes precedence over
+// the defaults of the Schema. An empty path only reads the environment.
+func Lookup(path string) (func(string) (string, bool), error) {
+	if path == "" {
+		return os.LookupEnv, nil
+	}
+
+	file, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			return value, true
+		}
+
+		value, ok := file[EnvVarKey(key)]
+		return value, ok
+	}, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	expected := Values{
+		Version:          "v3",
+		BindAddress:      "localhost:9090",
+		LogLevel:         "debug",
+		ResponseEnvelope: "true",
+		SeedScale:        "100",
+	}
+
+	for _, path := range []string{"testdata/coffee-service.hcl", "testdata/coffee-service.yaml"} {
+		t.Run(path, func(t *testing.T) {
+			values, err := ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, values)
+		})
+	}
+}
+
+func TestReadFileRejectsUnknownSettings(t *testing.T) {
+	_, err := ReadFile("testdata/unknown.yaml")
+	assert.EqualError(t, err, `testdata/unknown.yaml: unknown setting "bind_adress"`)
+}
+
+func TestLookupPrefersEnvironment(t *testing.T) {
+	os.Setenv(LogLevel.String(), "trace")
+	defer os.Unsetenv(LogLevel.String())
+
+	lookup, err := Lookup("testdata/coffee-service.hcl")
+	require.NoError(t, err)
+
+	values, errs := Resolve(lookup)
+	assert.Empty(t, errs)
+	assert.Equal(t, "trace", values[LogLevel])
+	assert.Equal(t, "localhost:9090", values[BindAddress])
+	assert.Equal(t, "text", values[LogFormat])
+}
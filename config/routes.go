@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Route groups middleware can be enabled for
+const (
+	// HealthRoutes are /health and /health/live
+	HealthRoutes = "health"
+	// CoffeesRoutes are /coffees and every route below it
+	CoffeesRoutes = "coffees"
+	// SearchRoutes is /search
+	SearchRoutes = "search"
+	// AdminRoutes are /admin and every route below it
+	AdminRoutes = "admin"
+	// OrdersRoutes are /orders and every route below it, and the /me routes
+	// of the user
+	OrdersRoutes = "orders"
+)
+
+// Middleware which can be enabled per route group
+const (
+	// AuthMiddleware requires the AUTH_TOKEN bearer token
+	AuthMiddleware = "auth"
+	// RateLimitMiddleware limits requests to RATE_LIMIT per second
+	RateLimitMiddleware = "ratelimit"
+	// CacheMiddleware caches responses for CACHE_TTL and serves them for
+	// CACHE_STALE more while they are refreshed
+	CacheMiddleware = "cache"
+	// TracingMiddleware starts an OpenTracing span per request
+	TracingMiddleware = "tracing"
+	// SPIFFEMiddleware requires a client certificate with a SPIFFE ID in
+	// SPIFFE_ALLOWED_IDS
+	SPIFFEMiddleware = "spiffe"
+)
+
+// routeGroups maps every route group to the variable configuring it
+var routeGroups = map[string]EnvVarKey{
+	HealthRoutes:  MiddlewareHealth,
+	CoffeesRoutes: MiddlewareCoffees,
+	SearchRoutes:  MiddlewareSearch,
+	AdminRoutes:   MiddlewareAdmin,
+	OrdersRoutes:  MiddlewareOrders,
+}
+
+// cachePolicyKeys maps every route group to the variables overriding its
+// cache policy
+var cachePolicyKeys = map[string]struct{ ttl, stale EnvVarKey }{
+	HealthRoutes:  {ttl: CacheTTLHealth, stale: CacheStaleHealth},
+	CoffeesRoutes: {ttl: CacheTTLCoffees, stale: CacheStaleCoffees},
+	SearchRoutes:  {ttl: CacheTTLSearch, stale: CacheStaleSearch},
+	AdminRoutes:   {ttl: CacheTTLAdmin, stale: CacheStaleAdmin},
+}
+
+// CachePolicy is the time the cache middleware of a route group keeps
+// responses fresh, and serves them stale while they are refreshed
+type CachePolicy struct {
+	TTL   time.Duration
+	Stale time.Duration
+}
+
+// CachePolicy returns the cache policy of a route group, which is CACHE_TTL
+// and CACHE_STALE unless the group overrides them
+func (c *Config) CachePolicy(group string) CachePolicy {
+	if policy, ok := c.CachePolicies[group]; ok {
+		return policy
+	}
+	return CachePolicy{TTL: c.CacheTTL, Stale: c.CacheStale}
+}
+
+// cachePolicies reads the cache policy of every route group, falling back to
+// CACHE_TTL and CACHE_STALE for the values a group does not override
+func cachePolicies(values Values) map[string]CachePolicy {
+	policies := map[string]CachePolicy{}
+	for group, keys := range cachePolicyKeys {
+		policy := CachePolicy{TTL: values.Duration(CacheTTL), Stale: values.Duration(CacheStale)}
+		if values[keys.ttl] != "" {
+			policy.TTL = values.Duration(keys.ttl)
+		}
+		if values[keys.stale] != "" {
+			policy.Stale = values.Duration(keys.stale)
+		}
+		policies[group] = policy
+	}
+
+	return policies
+}
+
+// routeMiddleware reads the middleware enabled for every route group from
+// the comma separated lists in values
+func routeMiddleware(values Values) map[string][]string {
+	groups := map[string][]string{}
+	for group, key := range routeGroups {
+		names := make([]string, 0)
+		for _, name := range strings.Split(values[key], ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+		groups[group] = names
+	}
+
+	return groups
+}
+
+// validateRouteMiddleware reports unknown route groups and middleware, and
+// middleware enabled without the settings it needs
+func (c *Config) validateRouteMiddleware() []error {
+	errs := make([]error, 0)
+
+	groups := make([]string, 0, len(c.RouteMiddleware))
+	for group := range c.RouteMiddleware {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		names := c.RouteMiddleware[group]
+		key, ok := routeGroups[group]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown route group %q", group))
+			continue
+		}
+
+		for _, name := range names {
+			switch name {
+			case AuthMiddleware:
+				if c.AuthToken == "" {
+					errs = append(errs, fmt.Errorf("%s enables %s which requires %s", key, name, AuthToken))
+				}
+			case RateLimitMiddleware:
+				if c.RateLimit <= 0 {
+					errs = append(errs, fmt.Errorf("%s enables %s which requires a positive %s", key, name, RateLimit))
+				}
+			case CacheMiddleware:
+				// the cache is shared by every user, orders are private
+				if group == OrdersRoutes {
+					errs = append(errs, fmt.Errorf("%s enables %s which would share orders between users", key, name))
+					continue
+				}
+				// the cache is keyed by URL, it would serve list reads without
+				// pinning a snapshot and ignore the token of detail reads
+				if group == CoffeesRoutes && c.SnapshotTTL > 0 {
+					errs = append(errs, fmt.Errorf("%s enables %s which can not be combined with %s", key, name, SnapshotTTL))
+				}
+				policy := c.CachePolicy(group)
+				if policy.TTL <= 0 {
+					errs = append(errs, fmt.Errorf("%s enables %s which requires a positive %s", key, name, CacheTTL))
+				}
+				if policy.Stale < 0 {
+					errs = append(errs, fmt.Errorf("%s enables %s which requires a %s which is not negative", key, name, CacheStale))
+				}
+			case SPIFFEMiddleware:
+				if c.TLSClientCAFile == "" || len(c.SPIFFEAllowedIDs) == 0 {
+					errs = append(errs, fmt.Errorf("%s enables %s which requires %s and %s", key, name, TLSClientCAFile, SPIFFEAllowedIDs))
+				}
+			case TracingMiddleware:
+			default:
+				errs = append(errs, fmt.Errorf("%s enables unknown middleware %q", key, name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// authenticates reports whether a route group enables middleware
+// authenticating its clients
+func (c *Config) authenticates(group string) bool {
+	for _, name := range c.RouteMiddleware[group] {
+		if name == AuthMiddleware || name == SPIFFEMiddleware {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// VarType is the type of an environment variable value
+type VarType string
+
+const (
+	// String values are used as is
+	String VarType = "string"
+	// Bool values are parsed with strconv.ParseBool
+	Bool VarType = "bool"
+	// Int values are parsed as base 10 integers
+	Int VarType = "int"
+	// Float values are parsed as 64 bit floating point numbers
+	Float VarType = "float"
+	// Duration values are parsed with time.ParseDuration
+	Duration VarType = "duration"
+)
+
+// masked replaces the value of secrets in the effective configuration
+const masked = "********"
+
+// Var declares an environment variable read by the service
+type Var struct {
+	Key         EnvVarKey
+	Type        VarType
+	Default     string
+	Required    bool
+	Secret      bool
+	Allowed     []string
+	Description string
+}
+
+// Schema declares every environment variable read by the service
+var Schema = []Var{
+	{Key: Version, Type: String, Required: true, Allowed: []string{V1.String(), V2.String(), V3.String()}, Description: "API version to serve, v1 and v2 use Postgres, v3 the in memory database"},
+	{Key: BindAddress, Type: String, Required: true, Description: "host:port the HTTP API listens on"},
+	{Key: MetricsAddress, Type: String, Description: "host:port serving Prometheus metrics on /metrics, disabled when empty"},
+	{Key: GRPCAddress, Type: String, Description: "host:port of the gRPC health server, disabled when empty"},
+	{Key: Username, Type: String, Description: "Postgres user name"},
+	{Key: Password, Type: String, Secret: true, Description: "Postgres password"},
+	{Key: LogFormat, Type: String, Default: "text", Allowed: []string{"text", "json"}, Description: "log output format"},
+	{Key: LogLevel, Type: String, Default: "info", Allowed: []string{"trace", "debug", "info", "warn", "error"}, Description: "minimum level of the logs written"},
+	{Key: DBTraceEnabled, Type: Bool, Default: "false", Description: "trace database queries with OpenCensus"},
+	{Key: ResponseEnvelope, Type: Bool, Default: "false", Description: "wrap v2 and v3 responses in an envelope"},
+	{Key: FastJSON, Type: Bool, Default: "false", Description: "encode coffee lists with the hand written JSON encoder instead of encoding/json"},
+	{Key: StrictJSON, Type: Bool, Default: "false", Description: "reject write payloads holding unknown fields with 400 instead of ignoring the fields"},
+	{Key: SessionTokens, Type: Bool, Default: "false", Description: "answer successful writes with a session token, reads passing it see the write even on a lagging Raft replica or through the response cache"},
+	{Key: MirrorURL, Type: String, Description: "base URL of a shadow instance a copy of incoming requests is sent to in the background, disabled when empty"},
+	{Key: MirrorPercent, Type: Float, Default: "100", Description: "percentage of incoming requests copied to MIRROR_URL"},
+	{Key: Compression, Type: Bool, Default: "false", Description: "compress JSON responses with brotli or gzip when the client accepts either, brotli first"},
+	{Key: BrotliQuality, Type: Int, Default: "5", Description: "brotli quality of compressed responses, from 0, the fastest, to 11, the smallest"},
+	{Key: PopularityFile, Type: String, Description: "file the popularity counters are persisted to, kept in memory when empty"},
+	{Key: ImageDir, Type: String, Description: "directory the uploaded coffee images and their resized variants are stored in and served from under /images/, uploads are disabled when empty"},
+	{Key: DBPrepareStatements, Type: Bool, Default: "true", Description: "prepare repository queries once and reuse the statements, disable behind transaction pooling proxies"},
+	{Key: DBPostGIS, Type: Bool, Default: "false", Description: "compute store distances with PostGIS instead of the haversine formula, needs the postgis extension"},
+	{Key: DBMigrate, Type: Bool, Default: "false", Description: "apply the pending migrations of data/migrations at startup when the Postgres schema is older than the code, instead of refusing to start"},
+	{Key: DBReconnectBackoff, Type: Duration, Default: "10s", Description: "longest delay between attempts to re-establish the database connections once they are lost, e.g. after a failover, never re-established when 0"},
+	{Key: DBReadRetries, Type: Int, Default: "2", Description: "most extra attempts of a repository read failing with a serialization failure, a deadlock or a lost connection"},
+	{Key: DBWriteRetries, Type: Int, Default: "1", Description: "most extra attempts of a repository write failing with a serialization failure, a deadlock or a lost connection, a write whose connection was lost after the commit is applied twice"},
+	{Key: DBRetryBackoff, Type: Duration, Default: "20ms", Description: "delay before the first retry of a repository call, doubled before every further retry"},
+	{Key: DBRetryMaxBackoff, Type: Duration, Default: "500ms", Description: "longest delay between retries of a repository call"},
+	{Key: DBRetryBudget, Type: Float, Default: "10", Description: "percentage of repository reads, and separately of writes, which may be retried"},
+	{Key: DBStatsHeaders, Type: Bool, Default: "false", Description: "report database statistics in response headers"},
+	{Key: DebugRequests, Type: Bool, Default: "false", Description: "add the queries and cache lookups of requests passing ?debug=true with AUTH_TOKEN to a _debug section of their JSON responses"},
+	{Key: SlowQueryThreshold, Type: Duration, Default: "0s", Description: "duration from which repository calls are kept for GET /admin/slow-queries, disabled when 0"},
+	{Key: SlowQueryLimit, Type: Int, Default: "100", Description: "number of slow repository calls kept, the oldest are dropped first"},
+	{Key: SeedScale, Type: Int, Default: "0", Description: "number of coffees generated at startup"},
+	{Key: SeedRandom, Type: Int, Default: "1", Description: "seed of the coffee generator"},
+	{Key: SeedMode, Type: String, Default: SeedAlways, Allowed: []string{SeedAlways, SeedSkip, SeedMerge, SeedForce}, Description: "whether the coffees are generated at every start, skipped once generated coffees exist, merged by slug or generated again after deleting the generated coffees"},
+	{Key: WatchdogLimit, Type: Duration, Default: "0s", Description: "time after which a request is considered stuck and /health/live fails, disabled when 0"},
+	{Key: RequestTimeout, Type: Duration, Default: "0s", Description: "deadline of every request, Postgres statements time out with it, disabled when 0"},
+	{Key: LatencyRules, Type: String, Description: "comma separated latency injected into routes and repository methods for demos, e.g. /coffees=800ms,repository.FindByID=50ms~20ms"},
+	{Key: MemoryPressureLimit, Type: Int, Default: "0", Description: "most megabytes POST /admin/memory retains to simulate memory pressure, needs the auth or spiffe middleware on the admin routes, disabled when 0"},
+	{Key: CPUBurnCores, Type: Int, Default: "0", Description: "most cores POST /admin/burn keeps busy to simulate CPU load, needs the auth or spiffe middleware on the admin routes, disabled when 0"},
+	{Key: CPUBurnMaxDuration, Type: Duration, Default: "5m", Description: "longest a CPU burn runs"},
+	{Key: MiddlewareHealth, Type: String, Description: "comma separated middleware enabled for the health routes"},
+	{Key: MiddlewareCoffees, Type: String, Description: "comma separated middleware enabled for the /coffees routes"},
+	{Key: MiddlewareSearch, Type: String, Description: "comma separated middleware enabled for the /search route"},
+	{Key: MiddlewareAdmin, Type: String, Description: "comma separated middleware enabled for the /admin routes"},
+	{Key: MiddlewareOrders, Type: String, Description: "comma separated middleware enabled for the /orders routes"},
+	{Key: AuthToken, Type: String, Secret: true, Description: "bearer token required by the auth middleware"},
+	{Key: RateLimit, Type: Int, Default: "10", Description: "requests per second allowed by the ratelimit middleware"},
+	{Key: CacheTTL, Type: Duration, Default: "5s", Description: "time responses are kept by the cache middleware"},
+	{Key: CacheStale, Type: Duration, Default: "0s", Description: "time responses older than CACHE_TTL are served while they are refreshed in the background, disabled when 0"},
+	{Key: CacheTTLHealth, Type: Duration, Description: "CACHE_TTL of the health routes, CACHE_TTL when empty"},
+	{Key: CacheStaleHealth, Type: Duration, Description: "CACHE_STALE of the health routes, CACHE_STALE when empty"},
+	{Key: CacheTTLCoffees, Type: Duration, Description: "CACHE_TTL of the /coffees routes, CACHE_TTL when empty"},
+	{Key: CacheStaleCoffees, Type: Duration, Description: "CACHE_STALE of the /coffees routes, CACHE_STALE when empty"},
+	{Key: CacheTTLSearch, Type: Duration, Description: "CACHE_TTL of the /search route, CACHE_TTL when empty"},
+	{Key: CacheStaleSearch, Type: Duration, Description: "CACHE_STALE of the /search route, CACHE_STALE when empty"},
+	{Key: CacheTTLAdmin, Type: Duration, Description: "CACHE_TTL of the /admin routes, CACHE_TTL when empty"},
+	{Key: CacheStaleAdmin, Type: Duration, Description: "CACHE_STALE of the /admin routes, CACHE_STALE when empty"},
+	{Key: AccessLogFormat, Type: String, Allowed: []string{"combined", "json"}, Description: "format of the access log, disabled when empty"},
+	{Key: AccessLogFile, Type: String, Description: "file the access log is written to, stdout when empty"},
+	{Key: AccessLogMaxSize, Type: Int, Default: "100", Description: "size in megabytes the access log file is rotated at"},
+	{Key: AccessLogMaxBackups, Type: Int, Default: "5", Description: "number of rotated access log files kept"},
+	{Key: LogShipFormat, Type: String, Allowed: []string{"loki", "otlp"}, Description: "protocol logs are shipped with, disabled when empty"},
+	{Key: LogShipEndpoint, Type: String, Description: "URL logs are pushed to, e.g. http://loki:3100/loki/api/v1/push"},
+	{Key: LogShipBatchSize, Type: Int, Default: "100", Description: "most log entries pushed in a single request"},
+	{Key: LogShipInterval, Type: Duration, Default: "1s", Description: "longest a log entry waits before it is pushed"},
+	{Key: LogShipBuffer, Type: Int, Default: "1000", Description: "log entries held while pushing, further entries are dropped"},
+	{Key: CrashReportDSN, Type: String, Secret: true, Description: "Sentry compatible DSN the reports of recovered panics are sent to, e.g. https://key@sentry.example.com/42, disabled when empty"},
+	{Key: StatsdAddress, Type: String, Description: "host:port of a StatsD or DogStatsD agent metrics are pushed to, disabled when empty"},
+	{Key: StatsdFormat, Type: String, Default: "statsd", Allowed: []string{"statsd", "dogstatsd"}, Description: "statsd folds labels into metric names, dogstatsd sends them as tags"},
+	{Key: MigrationBackend, Type: String, Allowed: []string{MemoryBackend, PostgresBackend}, Description: "backend the catalogue is migrated to, writes go to both backends, disabled when empty"},
+	{Key: MigrationRead, Type: String, Default: "old", Allowed: []string{"old", "new"}, Description: "backend serving reads while migrating, the other one is compared with it"},
+	{Key: MemoryShards, Type: Int, Default: "1", Description: "number of in memory instances the coffees of v3 are partitioned across by ID, unsharded when 0 or 1"},
+	{Key: ShadowBackend, Type: String, Allowed: []string{MemoryBackend, PostgresBackend}, Description: "backend reads are repeated against and compared with in the background, disabled when empty"},
+	{Key: ShadowSample, Type: Float, Default: "100", Description: "percentage of reads repeated against SHADOW_BACKEND"},
+	{Key: SnapshotTTL, Type: Duration, Default: "0s", Description: "time the in memory snapshot of a list read is kept for the reads passing its X-Snapshot-Token, disabled when 0"},
+	{Key: EntityIDs, Type: String, Default: SerialIDs, Allowed: []string{SerialIDs, UUIDv7IDs}, Description: "uuidv7 assigns new coupons, order records and points entries a time ordered UUIDv7 next to their keys"},
+	{Key: ChangesRetention, Type: Int, Default: "10000", Description: "number of writes kept for the GET /changes feed, the feed is disabled when 0"},
+	{Key: DuplicateSimilarity, Type: Float, Default: "0.6", Description: "trigram similarity from which POST /coffees rejects a name as a duplicate of an existing coffee, disabled when 0"},
+	{Key: RaftNodeID, Type: String, Description: "ID of this replica in RAFT_PEERS, replicates the in memory backend of v3 with Raft, disabled when empty"},
+	{Key: RaftBindAddress, Type: String, Default: "0.0.0.0:7000", Description: "host:port the Raft transport listens on"},
+	{Key: RaftPeers, Type: String, Description: "comma separated replicas formatted as <id>=<raft host:port>=<http base URL>, including this one"},
+	{Key: TLSCertFile, Type: String, Description: "PEM certificate the HTTP API is served with over TLS, plain HTTP when empty"},
+	{Key: TLSKeyFile, Type: String, Description: "PEM key of TLS_CERT_FILE"},
+	{Key: TLSClientCAFile, Type: String, Description: "PEM bundle of the CAs client certificates are verified with, e.g. the Consul Connect CA or SPIRE bundle, client certificates are not requested when empty"},
+	{Key: SPIFFETrustDomain, Type: String, Description: "trust domain client certificates must be SPIFFE X509-SVIDs of, e.g. example.org"},
+	{Key: SPIFFEAllowedIDs, Type: String, Description: "comma separated SPIFFE IDs allowed by the spiffe middleware, * matches a single segment"},
+	{Key: HTTPClientTimeout, Type: Duration, Default: "10s", Description: "timeout of every attempt of outbound requests, e.g. to Vault, Consul and the log shipping endpoint, disabled when 0"},
+	{Key: HTTPClientRetries, Type: Int, Default: "2", Description: "number of times failed outbound GET requests are retried"},
+	{Key: HTTPBreakerFailures, Type: Int, Default: "5", Description: "consecutive failures of an upstream which open its circuit breaker, disabled when 0"},
+	{Key: HTTPBreakerCooldown, Type: Duration, Default: "30s", Description: "time an open circuit breaker fails requests before a trial request is sent"},
+	{Key: HTTPClientCAFile, Type: String, Description: "PEM bundle of the CAs outbound TLS connections are verified with, the system roots when empty"},
+	{Key: HTTPClientCertFile, Type: String, Description: "PEM client certificate presented on outbound TLS connections for mutual TLS"},
+	{Key: HTTPClientKeyFile, Type: String, Description: "PEM key of HTTP_CLIENT_CERT_FILE"},
+	{Key: HTTPClientInsecure, Type: Bool, Default: "false", Description: "skips the verification of server certificates, for demos with self signed certificates only"},
+	{Key: ProductAPIAddress, Type: String, Description: "base URL of the product-api orders are delegated to, e.g. http://product-api:9090, the /orders routes are disabled when empty"},
+	{Key: ProductAPITimeout, Type: Duration, Default: "5s", Description: "timeout of every request to the product-api"},
+	{Key: ProductAPIRetries, Type: Int, Default: "2", Description: "number of times failed reads from the product-api are retried"},
+	{Key: ProductAPITokenSecret, Type: String, Secret: true, Description: "HMAC secret of the product-api tokens identifying users, the /me routes and loyalty points are disabled when empty"},
+	{Key: PaymentsAddress, Type: String, Description: "base URL of the payments service created orders are paid with, e.g. http://payments:8080, orders are not paid when empty"},
+	{Key: PaymentsTimeout, Type: Duration, Default: "5s", Description: "timeout of every attempt to authorize a payment"},
+	{Key: PaymentsRetries, Type: Int, Default: "2", Description: "number of times failed payment authorizations are retried with the same idempotency key"},
+	{Key: ReceiptTemplates, Type: String, Description: "directory whose receipt.html and receipt.txt replace the embedded templates of /orders/{id}/receipt"},
+	{Key: NotificationsProvider, Type: String, Allowed: []string{"smtp", "webhook"}, Description: "provider the confirmations of paid orders are sent with, disabled when empty"},
+	{Key: NotificationsWebhookURL, Type: String, Description: "URL the webhook provider posts confirmations to as JSON"},
+	{Key: NotificationsSMTPAddress, Type: String, Description: "host:port of the SMTP server the smtp provider emails confirmations through"},
+	{Key: NotificationsSMTPFrom, Type: String, Default: "orders@hashicups.example", Description: "sender address of confirmation emails"},
+	{Key: NotificationsSMTPUsername, Type: String, Description: "username of the SMTP server, not authenticated when empty"},
+	{Key: NotificationsSMTPPassword, Type: String, Secret: true, Description: "password of NOTIFICATIONS_SMTP_USERNAME"},
+	{Key: NotificationsRetries, Type: Int, Default: "3", Description: "number of times a failed confirmation is sent again, with a doubling delay from 1s"},
+	{Key: NotificationsBuffer, Type: Int, Default: "100", Description: "number of confirmations waiting to be sent with the memory JOB_QUEUE, confirmations are dead lettered while it is full"},
+	{Key: NotificationsDeadLetter, Type: String, Description: "file confirmations which could not be sent are appended to as JSON lines, only logged when empty"},
+	{Key: JobQueue, Type: String, Default: "memory", Allowed: []string{"memory", "postgres"}, Description: "queue of the background jobs like order confirmations, postgres keeps them in the job table across restarts and shares them between instances"},
+	{Key: JobPollInterval, Type: Duration, Default: "1s", Description: "delay between two looks for due jobs in the postgres job queue while there are none"},
+	{Key: ScheduleReseed, Type: String, Description: "cron expression, e.g. 0 3 * * *, generating the SEED_SCALE coffees again in SEED_MODE, disabled when empty"},
+	{Key: ScheduleCacheWarm, Type: String, Description: "cron expression, e.g. */5 * * * *, requesting the CACHE_WARM_PATHS to fill the response cache, disabled when empty"},
+	{Key: CacheWarmPaths, Type: String, Default: "/coffees", Description: "comma separated paths requested by the SCHEDULE_CACHE_WARM job"},
+	{Key: SchedulerLock, Type: String, Allowed: []string{"postgres", "consul"}, Description: "locks the scheduled jobs with Postgres advisory locks or Consul sessions, so each run happens on a single instance, every instance runs them when empty"},
+	{Key: SchedulerLockTTL, Type: Duration, Default: "15s", Description: "time a scheduler lock outlives an instance which stopped renewing it, locks are checked every half of it"},
+	{Key: LoyaltyEarnRate, Type: Float, Default: "1", Description: "loyalty points earned per unit of currency paid"},
+	{Key: LoyaltyPointValue, Type: Float, Default: "0.01", Description: "amount of currency a loyalty point takes off an order"},
+	{Key: Baristas, Type: Int, Default: "2", Description: "number of orders the simulated barista queue of /queue prepares at once, disabled when 0"},
+	{Key: IngredientsAddress, Type: String, Description: "base URL of a remote ingredients service ingredients are read from, e.g. http://ingredients:9090, local when empty"},
+	{Key: IngredientsTimeout, Type: Duration, Default: "1s", Description: "timeout of every attempt to read the remote ingredients"},
+	{Key: IngredientsHedge, Type: Duration, Default: "50ms", Description: "time after which a slow read of the remote ingredients is hedged with a second attempt, disabled when 0"},
+	{Key: IngredientsRetries, Type: Int, Default: "2", Description: "most extra attempts to read the remote ingredients, for failures and hedging"},
+	{Key: IngredientsBudget, Type: Float, Default: "10", Description: "percentage of remote ingredients reads which may make extra attempts"},
+	{Key: GCMetricsInterval, Type: Duration, Default: "10s", Description: "interval heap and garbage collector metrics are reported at, disabled when 0"},
+	{Key: SLOAvailability, Type: Float, Default: "99.9", Description: "percentage of requests per endpoint which must not fail with a server error"},
+	{Key: SLOLatency, Type: Duration, Default: "300ms", Description: "latency requests must complete within"},
+	{Key: SLOLatencyTarget, Type: Float, Default: "99", Description: "percentage of requests per endpoint which must complete within SLO_LATENCY"},
+	{Key: SLOWindow, Type: Duration, Default: "1h", Description: "rolling window the SLOs are measured over, SLO tracking is disabled when 0"},
+	{Key: VaultAddress, Type: String, Description: "Vault address checked by the check command"},
+	{Key: ConsulAddress, Type: String, Description: "Consul address checked by the check command, and keeping the consul SCHEDULER_LOCK"},
+}
+
+// Values are the resolved environment variables, keyed by name
+type Values map[EnvVarKey]string
+
+// Bool returns the value of a Bool variable
+func (v Values) Bool(key EnvVarKey) bool {
+	b, _ := strconv.ParseBool(v[key])
+	return b
+}
+
+// Int returns the value of an Int variable
+func (v Values) Int(key EnvVarKey) int64 {
+	i, _ := strconv.ParseInt(v[key], 10, 64)
+	return i
+}
+
+// Float returns the value of a Float variable
+func (v Values) Float(key EnvVarKey) float64 {
+	f, _ := strconv.ParseFloat(v[key], 64)
+	return f
+}
+
+// Duration returns the value of a Duration variable
+func (v Values) Duration(key EnvVarKey) time.Duration {
+	d, _ := time.ParseDuration(v[key])
+	return d
+}
+
+// List returns the comma separated values of a String variable, without
+// blanks
+func (v Values) List(key EnvVarKey) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(v[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Resolve reads every variable in the schema with lookup, applying defaults,
+// and reports every missing or invalid value. Values of valid variables are
+// returned even when others are invalid.
+func Resolve(lookup func(string) (string, bool)) (Values, []error) {
+	values := Values{}
+	errs := make([]error, 0)
+
+	for _, v := range Schema {
+		raw, ok := lookup(v.Key.String())
+		if !ok || raw == "" {
+			if v.Required {
+				errs = append(errs, fmt.Errorf("%s is required", v.Key))
+				continue
+			}
+			raw = v.Default
+		}
+
+		if err := v.validate(raw); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[v.Key] = raw
+	}
+
+	return values, errs
+}
+
+// validate checks a raw value against the type and allowed values of v. An
+// empty value is left unset.
+func (v Var) validate(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	switch v.Type {
+	case Bool:
+		if _, err := strconv.ParseBool(raw); err != nil {
+			return fmt.Errorf("%s must be a bool, got %q", v.Key, raw)
+		}
+	case Int:
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return fmt.Errorf("%s must be an int, got %q", v.Key, raw)
+		}
+	case Float:
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return fmt.Errorf("%s must be a float, got %q", v.Key, raw)
+		}
+	case Duration:
+		if _, err := time.ParseDuration(raw); err != nil {
+			return fmt.Errorf("%s must be a duration, got %q", v.Key, raw)
+		}
+	}
+
+	if len(v.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range v.Allowed {
+		if strings.EqualFold(raw, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %s, got %q", v.Key, strings.Join(v.Allowed, ", "), raw)
+}
+
+// Effective returns the resolved values as sorted key value pairs suitable for
+// hclog, with secrets masked
+func Effective(values Values) []interface{} {
+	secrets := map[EnvVarKey]bool{}
+	for _, v := range Schema {
+		secrets[v.Key] = v.Secret
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	pairs := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		value := values[EnvVarKey(key)]
+		if secrets[EnvVarKey(key)] && value != "" {
+			value = masked
+		}
+		pairs = append(pairs, key, value)
+	}
+
+	return pairs
+}
+
+// Describe documents every variable in the schema as a table
+func Describe() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tTYPE\tDEFAULT\tREQUIRED\tSECRET\tDESCRIPTION")
+	for _, v := range Schema {
+		description := v.Description
+		if len(v.Allowed) > 0 {
+			description = fmt.Sprintf("%s (%s)", description, strings.Join(v.Allowed, ", "))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\n", v.Key, v.Type, v.Default, v.Required, v.Secret, description)
+	}
+	w.Flush()
+
+	return b.String()
+}
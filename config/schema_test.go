@@ -0,0 +1,501 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lookupFrom returns a lookup function reading from env
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestResolveAppliesDefaults(t *testing.T) {
+	values, errs := Resolve(lookupFrom(map[string]string{"VERSION": "v3", "BIND_ADDRESS": ":9090"}))
+
+	assert.Empty(t, errs)
+	assert.Equal(t, "v3", values[Version])
+	assert.Equal(t, "info", values[LogLevel])
+	assert.False(t, values.Bool(ResponseEnvelope))
+	assert.Equal(t, int64(1), values.Int(SeedRandom))
+}
+
+func TestResolveReportsEveryInvalidValue(t *testing.T) {
+	values, errs := Resolve(lookupFrom(map[string]string{
+		"VERSION":          "v4",
+		"DB_STATS_HEADERS": "yes please",
+		"SEED_SCALE":       "ten",
+		"LOG_LEVEL":        "DEBUG",
+	}))
+
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], `VERSION must be one of v1, v2, v3, got "v4"`)
+	assert.EqualError(t, errs[1], "BIND_ADDRESS is required")
+	assert.Equal(t, "DEBUG", values[LogLevel])
+}
+
+func TestEffectiveMasksSecrets(t *testing.T) {
+	effective := Effective(Values{Password: "s3cret", Username: "postgres", Version: "v1"})
+
+	assert.Equal(t, []interface{}{"PASSWORD", "********", "USERNAME", "postgres", "VERSION", "v1"}, effective)
+}
+
+func TestEnvVarKeyFromString(t *testing.T) {
+	assert.Equal(t, SeedScale, EnvVarKeyFromString("SEED_SCALE"))
+	assert.Equal(t, Unknown, EnvVarKeyFromString("NOT_A_VARIABLE"))
+}
+
+func TestValidateRouteMiddleware(t *testing.T) {
+	cfg := &Config{
+		Version:     V3,
+		BindAddress: ":9090",
+		RouteMiddleware: map[string][]string{
+			CoffeesRoutes: {CacheMiddleware, "gzip"},
+			SearchRoutes:  {AuthMiddleware},
+		},
+		CacheTTL: 0,
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], "MIDDLEWARE_COFFEES enables cache which requires a positive CACHE_TTL")
+	assert.EqualError(t, errs[1], `MIDDLEWARE_COFFEES enables unknown middleware "gzip"`)
+	assert.EqualError(t, errs[2], "MIDDLEWARE_SEARCH enables auth which requires AUTH_TOKEN")
+}
+
+func TestCachePoliciesOverrideDefaultsPerGroup(t *testing.T) {
+	values, errs := Resolve(lookupFrom(map[string]string{
+		"VERSION":            "v3",
+		"BIND_ADDRESS":       ":9090",
+		"CACHE_STALE":        "30s",
+		"CACHE_TTL_COFFEES":  "1m",
+		"CACHE_STALE_SEARCH": "0s",
+		"CACHE_TTL_ADMIN":    "",
+		"CACHE_STALE_HEALTH": "soon",
+	}))
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], `CACHE_STALE_HEALTH must be a duration, got "soon"`)
+
+	cfg := &Config{CacheTTL: values.Duration(CacheTTL), CacheStale: values.Duration(CacheStale), CachePolicies: cachePolicies(values)}
+	assert.Equal(t, CachePolicy{TTL: time.Minute, Stale: 30 * time.Second}, cfg.CachePolicy(CoffeesRoutes))
+	assert.Equal(t, CachePolicy{TTL: 5 * time.Second, Stale: 0}, cfg.CachePolicy(SearchRoutes))
+	assert.Equal(t, CachePolicy{TTL: 5 * time.Second, Stale: 30 * time.Second}, cfg.CachePolicy(AdminRoutes))
+
+	// configurations built without policies use the defaults
+	assert.Equal(t, CachePolicy{TTL: time.Second}, (&Config{CacheTTL: time.Second}).CachePolicy(CoffeesRoutes))
+}
+
+func TestValidateIngredientsService(t *testing.T) {
+	cfg := &Config{
+		Version:            V3,
+		BindAddress:        ":9090",
+		IngredientsAddress: "ingredients:9090",
+		IngredientsRetries: -1,
+		IngredientsBudget:  150,
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], "INGREDIENTS_ADDRESS must be an http or https URL")
+	assert.EqualError(t, errs[1], "INGREDIENTS_TIMEOUT must be positive")
+	assert.EqualError(t, errs[2], "INGREDIENTS_HEDGE and INGREDIENTS_RETRIES must not be negative")
+	assert.EqualError(t, errs[3], "INGREDIENTS_BUDGET must be a percentage between 0 and 100")
+}
+
+func TestValidatePayments(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", PaymentsAddress: "payments:8080", PaymentsRetries: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], "PAYMENTS_ADDRESS must be an http or https URL")
+	assert.EqualError(t, errs[1], "PAYMENTS_ADDRESS requires PRODUCT_API_ADDRESS")
+	assert.EqualError(t, errs[2], "PAYMENTS_TIMEOUT must be positive")
+	assert.EqualError(t, errs[3], "PAYMENTS_RETRIES must not be negative")
+
+	cfg.PaymentsAddress, cfg.PaymentsTimeout, cfg.PaymentsRetries = "http://payments:8080", time.Second, 2
+	cfg.ProductAPIAddress, cfg.ProductAPITimeout = "http://product-api:9090", time.Second
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateNotifications(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", NotificationsProvider: "webhook", NotificationsRetries: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], "NOTIFICATIONS_PROVIDER requires PRODUCT_API_ADDRESS")
+	assert.EqualError(t, errs[1], "NOTIFICATIONS_WEBHOOK_URL must be an http or https URL for the webhook provider")
+	assert.EqualError(t, errs[2], "NOTIFICATIONS_RETRIES must not be negative")
+	assert.EqualError(t, errs[3], "NOTIFICATIONS_BUFFER must be positive")
+
+	cfg.NotificationsProvider, cfg.NotificationsRetries, cfg.NotificationsBuffer = "smtp", 3, 100
+	cfg.ProductAPIAddress, cfg.ProductAPITimeout = "http://product-api:9090", time.Second
+	errs = cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "NOTIFICATIONS_SMTP_ADDRESS must be a host:port for the smtp provider")
+	assert.EqualError(t, errs[1], "NOTIFICATIONS_SMTP_FROM is required by the smtp provider")
+
+	cfg.NotificationsSMTPAddress, cfg.NotificationsSMTPFrom = "mail:25", "orders@hashicups.example"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateLoyalty(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", ProductAPITokenSecret: "secret", LoyaltyEarnRate: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "LOYALTY_EARN_RATE must not be negative")
+	assert.EqualError(t, errs[1], "LOYALTY_POINT_VALUE must be positive")
+
+	cfg.LoyaltyEarnRate, cfg.LoyaltyPointValue = 1, 0.01
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateLatencyRules(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", LatencyRules: "/coffees=800ms,coffees=1s"}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "LATENCY_RULES is invalid: invalid latency rule coffees")
+
+	cfg.LatencyRules = "/coffees=800ms, repository.FindByID=50ms~20ms"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateMemoryPressureLimit(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", MemoryPressureLimit: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "MEMORY_PRESSURE_LIMIT must not be negative")
+
+	cfg.MemoryPressureLimit = 512
+	errs = cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "MEMORY_PRESSURE_LIMIT requires the auth or spiffe middleware in MIDDLEWARE_ADMIN")
+
+	cfg.RouteMiddleware = map[string][]string{AdminRoutes: {AuthMiddleware}}
+	cfg.AuthToken = "s3cret"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateCPUBurn(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", CPUBurnCores: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "CPU_BURN_CORES must not be negative")
+
+	cfg.CPUBurnCores = 2
+	errs = cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "CPU_BURN_MAX_DURATION must be positive")
+	assert.EqualError(t, errs[1], "CPU_BURN_CORES requires the auth or spiffe middleware in MIDDLEWARE_ADMIN")
+
+	cfg.CPUBurnMaxDuration = 5 * time.Minute
+	cfg.RouteMiddleware = map[string][]string{AdminRoutes: {AuthMiddleware}}
+	cfg.AuthToken = "s3cret"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateSlowQueries(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", SlowQueryThreshold: -time.Second}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "SLOW_QUERY_THRESHOLD must not be negative")
+
+	cfg.SlowQueryThreshold = 100 * time.Millisecond
+	errs = cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "SLOW_QUERY_LIMIT must be positive")
+
+	cfg.SlowQueryLimit = 100
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateDBReconnectBackoff(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DBReconnectBackoff: -time.Second}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "DB_RECONNECT_BACKOFF must not be negative")
+
+	cfg.DBReconnectBackoff = 10 * time.Second
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateDBRetries(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DBWriteRetries: -1, DBRetryBudget: 150}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "DB_READ_RETRIES, DB_WRITE_RETRIES, DB_RETRY_BACKOFF and DB_RETRY_MAX_BACKOFF must not be negative")
+	assert.EqualError(t, errs[1], "DB_RETRY_BUDGET must be a percentage between 0 and 100")
+
+	cfg.DBWriteRetries, cfg.DBRetryBudget = 1, 10
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateBaristas(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", Baristas: -1}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "BARISTAS must not be negative")
+
+	cfg.Baristas = 0
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateHTTPClients(t *testing.T) {
+	cfg := &Config{
+		Version:             V3,
+		BindAddress:         ":9090",
+		HTTPClientRetries:   -1,
+		HTTPBreakerFailures: 5,
+		HTTPClientCertFile:  "client.pem",
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], "HTTP_CLIENT_TIMEOUT, HTTP_CLIENT_RETRIES and HTTP_CLIENT_BREAKER_FAILURES must not be negative")
+	assert.EqualError(t, errs[1], "HTTP_CLIENT_BREAKER_COOLDOWN must be positive")
+	assert.EqualError(t, errs[2], "HTTP_CLIENT_CERT_FILE and HTTP_CLIENT_KEY_FILE must be set together")
+}
+
+func TestValidateSPIFFE(t *testing.T) {
+	cfg := &Config{
+		Version:           V3,
+		BindAddress:       ":9090",
+		TLSClientCAFile:   "ca.pem",
+		SPIFFETrustDomain: "Example.org",
+		SPIFFEAllowedIDs:  []string{"spiffe://example.org/[web"},
+		RouteMiddleware:   map[string][]string{CoffeesRoutes: {SPIFFEMiddleware}},
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], "TLS_CLIENT_CA_FILE requires TLS_CERT_FILE")
+	assert.EqualError(t, errs[1], "SPIFFE_TRUST_DOMAIN is not a valid trust domain")
+	assert.EqualError(t, errs[2], `SPIFFE_ALLOWED_IDS contains "spiffe://example.org/[web" which is not a SPIFFE ID pattern`)
+
+	cfg.TLSClientCAFile, cfg.SPIFFEAllowedIDs = "", nil
+	errs = cfg.Validate()
+	assert.EqualError(t, errs[len(errs)-1], "MIDDLEWARE_COFFEES enables spiffe which requires TLS_CLIENT_CA_FILE and SPIFFE_ALLOWED_IDS")
+}
+
+func TestValidateMigrationBackend(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", MigrationBackend: MemoryBackend}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "MIGRATION_BACKEND must differ from the memory backend of VERSION v3")
+
+	cfg.MigrationBackend = PostgresBackend
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateMemoryShards(t *testing.T) {
+	cfg := &Config{Version: V2, BindAddress: ":9090", MemoryShards: 4}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "MEMORY_SHARDS requires the memory backend of VERSION v3")
+
+	cfg.Version = V3
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidatePostGIS(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DBPostGIS: true}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "DB_POSTGIS requires a postgres backend")
+
+	cfg.ShadowBackend, cfg.ShadowSample = PostgresBackend, 100
+	assert.Empty(t, cfg.Validate())
+	cfg.Version, cfg.ShadowBackend = V2, ""
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateRaft(t *testing.T) {
+	cfg := &Config{
+		Version:      V2,
+		BindAddress:  ":9090",
+		RaftNodeID:   "coffee-0",
+		RaftPeers:    []string{"coffee-1=coffee-1:7000=http://coffee-1:9090", "coffee-2=coffee-2:7000"},
+		MemoryShards: 1,
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], "RAFT_NODE_ID requires the memory backend of VERSION v3")
+	assert.EqualError(t, errs[1], `RAFT_PEERS contains "coffee-2=coffee-2:7000" which is not formatted as <id>=<raft host:port>=<http base URL>`)
+	assert.EqualError(t, errs[2], "RAFT_PEERS must contain RAFT_NODE_ID coffee-0")
+
+	cfg.Version = V3
+	cfg.RaftPeers = []string{"coffee-0=coffee-0:7000=http://coffee-0:9090", "coffee-1=coffee-1:7000=http://coffee-1:9090"}
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateSnapshotTTL(t *testing.T) {
+	cfg := &Config{
+		Version:         V3,
+		BindAddress:     ":9090",
+		SnapshotTTL:     time.Minute,
+		MemoryShards:    2,
+		RouteMiddleware: map[string][]string{CoffeesRoutes: {CacheMiddleware}},
+		CacheTTL:        time.Second,
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "SNAPSHOT_TTL can not be combined with MEMORY_SHARDS")
+	assert.EqualError(t, errs[1], "MIDDLEWARE_COFFEES enables cache which can not be combined with SNAPSHOT_TTL")
+
+	cfg.MemoryShards, cfg.RouteMiddleware = 1, nil
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateDuplicateSimilarity(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DuplicateSimilarity: 1.5}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "DUPLICATE_SIMILARITY must be between 0 and 1")
+
+	cfg.DuplicateSimilarity = 0
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateShadowBackend(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", ShadowBackend: MemoryBackend}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "SHADOW_BACKEND must differ from the memory backend of VERSION v3")
+	assert.EqualError(t, errs[1], "SHADOW_SAMPLE must be a percentage above 0 and up to 100")
+
+	cfg.ShadowBackend, cfg.ShadowSample = PostgresBackend, 100
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateDebugRequests(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DebugRequests: true}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "DEBUG_REQUESTS requires AUTH_TOKEN")
+
+	cfg.AuthToken = "secret"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateMirror(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", MirrorURL: "shadow:9090", MirrorPercent: 150}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "MIRROR_URL must be an http or https URL")
+	assert.EqualError(t, errs[1], "MIRROR_PERCENT must be a percentage above 0 and up to 100")
+
+	cfg.MirrorURL, cfg.MirrorPercent = "http://shadow:9090", 10
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateBrotliQuality(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", Compression: true, BrotliQuality: 12}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "BROTLI_QUALITY must be between 0 and 11")
+
+	cfg.BrotliQuality = 11
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateJobPollInterval(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", JobQueue: "postgres"}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "JOB_POLL_INTERVAL must be positive")
+
+	cfg.JobPollInterval = time.Second
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateSchedules(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", ScheduleReseed: "0 3 * *", ScheduleCacheWarm: "*/5 * * * *", CacheWarmPaths: []string{"coffees"}}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], `SCHEDULE_RESEED is invalid: invalid cron expression "0 3 * *": expected 5 fields, got 4`)
+	assert.EqualError(t, errs[1], "SCHEDULE_RESEED requires SEED_SCALE")
+	assert.EqualError(t, errs[2], `CACHE_WARM_PATHS contains "coffees" which is not an absolute path`)
+
+	cfg.ScheduleReseed = "0 3 * * *"
+	cfg.SeedScale = 100
+	cfg.CacheWarmPaths = []string{"/coffees", "/search?q=latte"}
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateSchedulerLock(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", SchedulerLock: "consul", SchedulerLockTTL: 15 * time.Second}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "SCHEDULER_LOCK consul requires CONSUL_HTTP_ADDR")
+
+	cfg.ConsulAddress = "localhost:8500"
+	cfg.SchedulerLockTTL = 5 * time.Second
+	errs = cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "SCHEDULER_LOCK_TTL must be at least 10s with consul")
+
+	cfg.SchedulerLock = "postgres"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateRejectsCachedOrders(t *testing.T) {
+	cfg := &Config{
+		Version:         V3,
+		BindAddress:     ":9090",
+		RouteMiddleware: map[string][]string{OrdersRoutes: {CacheMiddleware}},
+		CacheTTL:        time.Second,
+	}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "MIDDLEWARE_ORDERS enables cache which would share orders between users")
+}
+
+func TestValidateCrashReportDSN(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", CrashReportDSN: "https://sentry.example.com/42"}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "CRASH_REPORT_DSN is invalid: DSN has no public key")
+
+	cfg.CrashReportDSN = "https://abc123@sentry.example.com/42"
+	assert.Empty(t, cfg.Validate())
+}
+
+func TestValidateDBMigrate(t *testing.T) {
+	cfg := &Config{Version: V3, BindAddress: ":9090", DBMigrate: true}
+
+	errs := cfg.Validate()
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "DB_MIGRATE requires a postgres backend")
+
+	cfg.MigrationBackend = PostgresBackend
+	assert.Empty(t, cfg.Validate())
+	cfg.Version, cfg.MigrationBackend = V1, ""
+	assert.Empty(t, cfg.Validate())
+}
@@ -0,0 +1,5 @@
+version           = "v3"
+bind_address      = "localhost:9090"
+log_level         = "debug"
+response_envelope = true
+seed_scale        = 100
@@ -0,0 +1,386 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/crashreport"
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+	"github.com/hashicorp-demoapp/coffee-service/scheduler"
+	"github.com/hashicorp-demoapp/coffee-service/spiffe"
+)
+
+// Validate reports every configuration value that would prevent the service
+// from starting. It returns no errors for a valid configuration.
+func (c *Config) Validate() []error {
+	errs := make([]error, 0)
+
+	if c.Version == VUnknown || c.Version == "" {
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s or %s", Version, V1, V2, V3))
+	}
+
+	if c.BindAddress == "" {
+		errs = append(errs, fmt.Errorf("%s is required", BindAddress))
+	}
+
+	addresses := []struct {
+		key   EnvVarKey
+		value string
+	}{
+		{key: BindAddress, value: c.BindAddress},
+		{key: MetricsAddress, value: c.MetricsAddress},
+		{key: GRPCAddress, value: c.GRPCAddress},
+		{key: StatsdAddress, value: c.StatsdAddress},
+	}
+	for _, address := range addresses {
+		if address.value == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(address.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not a valid host:port: %w", address.key, err))
+		}
+	}
+
+	if c.SeedScale < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", SeedScale))
+	}
+
+	if c.WatchdogLimit < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", WatchdogLimit))
+	}
+
+	if c.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", RequestTimeout))
+	}
+
+	if _, err := latency.Parse(c.LatencyRules); err != nil {
+		errs = append(errs, fmt.Errorf("%s is invalid: %w", LatencyRules, err))
+	}
+
+	if c.MemoryPressureLimit < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", MemoryPressureLimit))
+	}
+	if c.MemoryPressureLimit > 0 && !c.authenticates(AdminRoutes) {
+		errs = append(errs, fmt.Errorf("%s requires the %s or %s middleware in %s", MemoryPressureLimit, AuthMiddleware, SPIFFEMiddleware, MiddlewareAdmin))
+	}
+
+	if c.CPUBurnCores < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", CPUBurnCores))
+	}
+	if c.CPUBurnCores > 0 {
+		if c.CPUBurnMaxDuration <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", CPUBurnMaxDuration))
+		}
+		if !c.authenticates(AdminRoutes) {
+			errs = append(errs, fmt.Errorf("%s requires the %s or %s middleware in %s", CPUBurnCores, AuthMiddleware, SPIFFEMiddleware, MiddlewareAdmin))
+		}
+	}
+
+	if c.SlowQueryThreshold < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", SlowQueryThreshold))
+	}
+	if c.SlowQueryThreshold > 0 && c.SlowQueryLimit <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", SlowQueryLimit))
+	}
+
+	if c.AccessLogFile != "" && c.AccessLogMaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", AccessLogMaxSize))
+	}
+	if c.AccessLogMaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", AccessLogMaxBackups))
+	}
+
+	if c.LogShipFormat != "" {
+		if c.LogShipEndpoint == "" {
+			errs = append(errs, fmt.Errorf("%s is required by %s", LogShipEndpoint, LogShipFormat))
+		}
+		if c.LogShipBatchSize <= 0 || c.LogShipBuffer <= 0 || c.LogShipInterval <= 0 {
+			errs = append(errs, fmt.Errorf("%s, %s and %s must be positive", LogShipBatchSize, LogShipBuffer, LogShipInterval))
+		}
+	}
+
+	if c.CrashReportDSN != "" {
+		if _, err := crashreport.ParseDSN(c.CrashReportDSN); err != nil {
+			errs = append(errs, fmt.Errorf("%s is invalid: %w", CrashReportDSN, err))
+		}
+	}
+
+	if c.MigrationBackend != "" && c.MigrationBackend == c.Backend() {
+		errs = append(errs, fmt.Errorf("%s must differ from the %s backend of %s %s", MigrationBackend, c.Backend(), Version, c.Version))
+	}
+	if c.DBPostGIS && !c.UsesBackend(PostgresBackend) {
+		errs = append(errs, fmt.Errorf("%s requires a %s backend", DBPostGIS, PostgresBackend))
+	}
+	if c.DBMigrate && !c.UsesBackend(PostgresBackend) {
+		errs = append(errs, fmt.Errorf("%s requires a %s backend", DBMigrate, PostgresBackend))
+	}
+	if c.DBReconnectBackoff < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", DBReconnectBackoff))
+	}
+	if c.DBReadRetries < 0 || c.DBWriteRetries < 0 || c.DBRetryBackoff < 0 || c.DBRetryMaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("%s, %s, %s and %s must not be negative", DBReadRetries, DBWriteRetries, DBRetryBackoff, DBRetryMaxBackoff))
+	}
+	if c.DBRetryBudget < 0 || c.DBRetryBudget > 100 {
+		errs = append(errs, fmt.Errorf("%s must be a percentage between 0 and 100", DBRetryBudget))
+	}
+	if c.MemoryShards < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", MemoryShards))
+	}
+	if c.MemoryShards > 1 && c.Backend() != MemoryBackend {
+		errs = append(errs, fmt.Errorf("%s requires the %s backend of %s %s", MemoryShards, MemoryBackend, Version, V3))
+	}
+	if c.SnapshotTTL < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", SnapshotTTL))
+	}
+	if c.SnapshotTTL > 0 {
+		if c.Backend() != MemoryBackend {
+			errs = append(errs, fmt.Errorf("%s requires the %s backend of %s %s", SnapshotTTL, MemoryBackend, Version, V3))
+		}
+		// every shard would pin a snapshot of its own
+		if c.MemoryShards > 1 {
+			errs = append(errs, fmt.Errorf("%s can not be combined with %s", SnapshotTTL, MemoryShards))
+		}
+	}
+	if c.ChangesRetention < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", ChangesRetention))
+	}
+	if c.DuplicateSimilarity < 0 || c.DuplicateSimilarity > 1 {
+		errs = append(errs, fmt.Errorf("%s must be between 0 and 1", DuplicateSimilarity))
+	}
+	if c.RaftNodeID != "" {
+		errs = append(errs, c.validateRaft()...)
+	}
+	if c.ShadowBackend != "" {
+		if c.ShadowBackend == c.Backend() {
+			errs = append(errs, fmt.Errorf("%s must differ from the %s backend of %s %s", ShadowBackend, c.Backend(), Version, c.Version))
+		}
+		if c.ShadowSample <= 0 || c.ShadowSample > 100 {
+			errs = append(errs, fmt.Errorf("%s must be a percentage above 0 and up to 100", ShadowSample))
+		}
+	}
+	if c.DebugRequests && c.AuthToken == "" {
+		errs = append(errs, fmt.Errorf("%s requires %s", DebugRequests, AuthToken))
+	}
+	if c.MirrorURL != "" {
+		if !isHTTPURL(c.MirrorURL) {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", MirrorURL))
+		}
+		if c.MirrorPercent <= 0 || c.MirrorPercent > 100 {
+			errs = append(errs, fmt.Errorf("%s must be a percentage above 0 and up to 100", MirrorPercent))
+		}
+	}
+	if c.Compression && (c.BrotliQuality < 0 || c.BrotliQuality > 11) {
+		errs = append(errs, fmt.Errorf("%s must be between 0 and 11", BrotliQuality))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s and %s must be set together", TLSCertFile, TLSKeyFile))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, fmt.Errorf("%s requires %s", TLSClientCAFile, TLSCertFile))
+	}
+	if c.SPIFFETrustDomain != "" {
+		if c.TLSClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("%s requires %s", SPIFFETrustDomain, TLSClientCAFile))
+		}
+		if id, err := spiffe.ParseID("spiffe://" + c.SPIFFETrustDomain); err != nil || id.Path != "" {
+			errs = append(errs, fmt.Errorf("%s is not a valid trust domain", SPIFFETrustDomain))
+		}
+	}
+	for _, pattern := range c.SPIFFEAllowedIDs {
+		if _, err := path.Match(pattern, ""); err != nil || !strings.HasPrefix(pattern, "spiffe://") {
+			errs = append(errs, fmt.Errorf("%s contains %q which is not a SPIFFE ID pattern", SPIFFEAllowedIDs, pattern))
+		}
+	}
+
+	if c.HTTPClientTimeout < 0 || c.HTTPClientRetries < 0 || c.HTTPBreakerFailures < 0 {
+		errs = append(errs, fmt.Errorf("%s, %s and %s must not be negative", HTTPClientTimeout, HTTPClientRetries, HTTPBreakerFailures))
+	}
+	if c.HTTPBreakerFailures > 0 && c.HTTPBreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", HTTPBreakerCooldown))
+	}
+	if (c.HTTPClientCertFile == "") != (c.HTTPClientKeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s and %s must be set together", HTTPClientCertFile, HTTPClientKeyFile))
+	}
+
+	if c.ProductAPIAddress != "" {
+		if !isHTTPURL(c.ProductAPIAddress) {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", ProductAPIAddress))
+		}
+		if c.ProductAPITimeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", ProductAPITimeout))
+		}
+		if c.ProductAPIRetries < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", ProductAPIRetries))
+		}
+	}
+
+	if c.PaymentsAddress != "" {
+		if !isHTTPURL(c.PaymentsAddress) {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", PaymentsAddress))
+		}
+		if c.ProductAPIAddress == "" {
+			errs = append(errs, fmt.Errorf("%s requires %s", PaymentsAddress, ProductAPIAddress))
+		}
+		if c.PaymentsTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", PaymentsTimeout))
+		}
+		if c.PaymentsRetries < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", PaymentsRetries))
+		}
+	}
+
+	if c.NotificationsProvider != "" {
+		if c.ProductAPIAddress == "" {
+			errs = append(errs, fmt.Errorf("%s requires %s", NotificationsProvider, ProductAPIAddress))
+		}
+		if c.NotificationsProvider == "webhook" && !isHTTPURL(c.NotificationsWebhookURL) {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL for the webhook provider", NotificationsWebhookURL))
+		}
+		if c.NotificationsProvider == "smtp" {
+			if _, _, err := net.SplitHostPort(c.NotificationsSMTPAddress); err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a host:port for the smtp provider", NotificationsSMTPAddress))
+			}
+			if c.NotificationsSMTPFrom == "" {
+				errs = append(errs, fmt.Errorf("%s is required by the smtp provider", NotificationsSMTPFrom))
+			}
+		}
+		if c.NotificationsRetries < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", NotificationsRetries))
+		}
+		if c.NotificationsBuffer <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", NotificationsBuffer))
+		}
+	}
+
+	if c.JobQueue == "postgres" && c.JobPollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("%s must be positive", JobPollInterval))
+	}
+
+	for _, schedule := range []struct {
+		key   EnvVarKey
+		value string
+	}{{ScheduleReseed, c.ScheduleReseed}, {ScheduleCacheWarm, c.ScheduleCacheWarm}} {
+		if schedule.value == "" {
+			continue
+		}
+		if _, err := scheduler.ParseCron(schedule.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s is invalid: %w", schedule.key, err))
+		}
+	}
+	if c.ScheduleReseed != "" && c.SeedScale == 0 {
+		errs = append(errs, fmt.Errorf("%s requires %s", ScheduleReseed, SeedScale))
+	}
+	switch {
+	case c.SchedulerLock == "consul" && c.ConsulAddress == "":
+		errs = append(errs, fmt.Errorf("%s consul requires %s", SchedulerLock, ConsulAddress))
+	case c.SchedulerLock == "consul" && c.SchedulerLockTTL < 10*time.Second:
+		errs = append(errs, fmt.Errorf("%s must be at least 10s with consul", SchedulerLockTTL))
+	case c.SchedulerLock != "" && c.SchedulerLockTTL <= 0:
+		errs = append(errs, fmt.Errorf("%s must be positive", SchedulerLockTTL))
+	}
+	if c.ScheduleCacheWarm != "" {
+		if len(c.CacheWarmPaths) == 0 {
+			errs = append(errs, fmt.Errorf("%s requires %s", ScheduleCacheWarm, CacheWarmPaths))
+		}
+		for _, p := range c.CacheWarmPaths {
+			if !strings.HasPrefix(p, "/") {
+				errs = append(errs, fmt.Errorf("%s contains %q which is not an absolute path", CacheWarmPaths, p))
+			}
+		}
+	}
+
+	if c.ProductAPITokenSecret != "" {
+		if c.LoyaltyEarnRate < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", LoyaltyEarnRate))
+		}
+		if c.LoyaltyPointValue <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", LoyaltyPointValue))
+		}
+	}
+
+	if c.Baristas < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", Baristas))
+	}
+
+	if c.IngredientsAddress != "" {
+		if !isHTTPURL(c.IngredientsAddress) {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", IngredientsAddress))
+		}
+		if c.IngredientsTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", IngredientsTimeout))
+		}
+		if c.IngredientsHedge < 0 || c.IngredientsRetries < 0 {
+			errs = append(errs, fmt.Errorf("%s and %s must not be negative", IngredientsHedge, IngredientsRetries))
+		}
+		if c.IngredientsBudget < 0 || c.IngredientsBudget > 100 {
+			errs = append(errs, fmt.Errorf("%s must be a percentage between 0 and 100", IngredientsBudget))
+		}
+	}
+
+	if c.GCMetricsInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", GCMetricsInterval))
+	}
+
+	if c.SLOWindow > 0 {
+		targets := []struct {
+			key   EnvVarKey
+			value float64
+		}{
+			{key: SLOAvailability, value: c.SLOAvailability},
+			{key: SLOLatencyTarget, value: c.SLOLatencyTarget},
+		}
+		for _, target := range targets {
+			if target.value <= 0 || target.value >= 100 {
+				errs = append(errs, fmt.Errorf("%s must be a percentage between 0 and 100, exclusive", target.key))
+			}
+		}
+		if c.SLOLatency <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", SLOLatency))
+		}
+	}
+	if c.SLOWindow < 0 {
+		errs = append(errs, fmt.Errorf("%s must not be negative", SLOWindow))
+	}
+
+	return append(errs, c.validateRouteMiddleware()...)
+}
+
+// isHTTPURL reports whether address is an absolute http or https URL
+func isHTTPURL(address string) bool {
+	u, err := url.Parse(address)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validateRaft validates the replication of the in memory backend
+func (c *Config) validateRaft() []error {
+	errs := []error{}
+	if c.Backend() != MemoryBackend {
+		errs = append(errs, fmt.Errorf("%s requires the %s backend of %s %s", RaftNodeID, MemoryBackend, Version, V3))
+	}
+	if c.MemoryShards > 1 {
+		errs = append(errs, fmt.Errorf("%s can not be combined with %s", RaftNodeID, MemoryShards))
+	}
+	// every replica would generate its own coffees before a leader is elected
+	if c.SeedScale > 0 {
+		errs = append(errs, fmt.Errorf("%s can not be combined with %s", RaftNodeID, SeedScale))
+	}
+
+	self := false
+	for _, peer := range c.RaftPeers {
+		parts := strings.SplitN(peer, "=", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !isHTTPURL(parts[2]) {
+			errs = append(errs, fmt.Errorf("%s contains %q which is not formatted as <id>=<raft host:port>=<http base URL>", RaftPeers, peer))
+			continue
+		}
+		self = self || parts[0] == c.RaftNodeID
+	}
+	if !self {
+		errs = append(errs, fmt.Errorf("%s must contain %s %s", RaftPeers, RaftNodeID, c.RaftNodeID))
+	}
+	return errs
+}
@@ -0,0 +1,217 @@
+// Package crashreport sends reports of the panics recovered by the
+// coffee-service to a Sentry compatible endpoint, e.g. Sentry or GlitchTip,
+// using the store API so no SDK is needed.
+package crashreport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// reportBuffer is the number of reports held while one is sent, further
+// reports are dropped rather than blocking the recovering request
+const reportBuffer = 16
+
+// DSN is a parsed Sentry DSN, e.g. https://key@sentry.example.com/42
+type DSN struct {
+	// Endpoint is the URL of the store API of the project
+	Endpoint  string
+	PublicKey string
+	// SecretKey is only set by DSNs of older Sentry versions
+	SecretKey string
+	ProjectID string
+}
+
+// ParseDSN parses a Sentry DSN. Its errors do not quote the DSN, which holds
+// the key of the project.
+func ParseDSN(raw string) (*DSN, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("DSN is not a URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("DSN scheme must be http or https")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("DSN has no public key")
+	}
+
+	i := strings.LastIndexByte(u.Path, '/')
+	if i < 0 || u.Path[i+1:] == "" {
+		return nil, fmt.Errorf("DSN has no project ID")
+	}
+
+	dsn := &DSN{PublicKey: u.User.Username(), ProjectID: u.Path[i+1:]}
+	dsn.SecretKey, _ = u.User.Password()
+	dsn.Endpoint = fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:i], dsn.ProjectID)
+	return dsn, nil
+}
+
+// auth returns the X-Sentry-Auth header authenticating the reports
+func (d *DSN) auth() string {
+	auth := "Sentry sentry_version=7, sentry_client=coffee-service/1.0, sentry_key=" + d.PublicKey
+	if d.SecretKey != "" {
+		auth += ", sentry_secret=" + d.SecretKey
+	}
+	return auth
+}
+
+// Frame is a frame of the stack of a panic
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Report describes a panic recovered while handling a request
+type Report struct {
+	Time time.Time
+	// Type is the type of the panic value, e.g. runtime.Error
+	Type string
+	// Value is the panic value formatted with %v
+	Value string
+	// Frames is the stack of the panic, the innermost frame first
+	Frames    []Frame
+	Method    string
+	URL       string
+	Route     string
+	RequestID string
+	TraceID   string
+}
+
+// Reporter sends reports to a Sentry compatible endpoint in the background
+type Reporter struct {
+	dsn     *DSN
+	client  *http.Client
+	server  string
+	reports chan Report
+	dropped uint64
+}
+
+// NewReporter creates a Reporter sending to dsn with client, reports are only
+// sent once Run is called
+func NewReporter(dsn string, client *http.Client) (*Reporter, error) {
+	parsed, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	server, _ := os.Hostname()
+	return &Reporter{dsn: parsed, client: client, server: server, reports: make(chan Report, reportBuffer)}, nil
+}
+
+// Report queues a report without blocking, dropping it when the buffer is
+// full
+func (r *Reporter) Report(report Report) {
+	select {
+	case r.reports <- report:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+// Dropped returns the number of reports dropped so far, because the buffer
+// was full or the endpoint refused them
+func (r *Reporter) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// Run sends the queued reports until done is closed, then sends the reports
+// still queued
+func (r *Reporter) Run(done <-chan struct{}) {
+	for {
+		select {
+		case report := <-r.reports:
+			r.send(report)
+		case <-done:
+			for {
+				select {
+				case report := <-r.reports:
+					r.send(report)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a report once, retries are left to the client
+func (r *Reporter) send(report Report) {
+	body, err := encodeEvent(report, r.server)
+	if err == nil {
+		var req *http.Request
+		if req, err = http.NewRequest(http.MethodPost, r.dsn.Endpoint, bytes.NewReader(body)); err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Sentry-Auth", r.dsn.auth())
+
+			var resp *http.Response
+			if resp, err = r.client.Do(req); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode < 300 {
+					return
+				}
+			}
+		}
+	}
+
+	atomic.AddUint64(&r.dropped, 1)
+}
+
+// encodeEvent encodes a report as a Sentry event of an unhandled exception
+func encodeEvent(report Report, server string) ([]byte, error) {
+	type frame struct {
+		Function string `json:"function"`
+		AbsPath  string `json:"abs_path"`
+		Line     int    `json:"lineno"`
+	}
+
+	// Sentry lists the outermost frame first
+	frames := make([]frame, 0, len(report.Frames))
+	for i := len(report.Frames) - 1; i >= 0; i-- {
+		f := report.Frames[i]
+		frames = append(frames, frame{Function: f.Function, AbsPath: f.File, Line: f.Line})
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for name, value := range map[string]string{"route": report.Route, "request_id": report.RequestID, "trace_id": report.TraceID} {
+		if value != "" {
+			tags[name] = value
+		}
+	}
+
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   report.Time.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      "coffee-service",
+		"server_name": server,
+		"exception": map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{
+				"type":       report.Type,
+				"value":      report.Value,
+				"mechanism":  map[string]interface{}{"type": "recovery", "handled": true},
+				"stacktrace": map[string]interface{}{"frames": frames},
+			}},
+		},
+		"request": map[string]string{"method": report.Method, "url": report.URL},
+		"tags":    tags,
+	}
+
+	return json.Marshal(event)
+}
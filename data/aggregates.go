@@ -0,0 +1,124 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+var (
+	// ErrStatsUnsupported is returned when recording orders in, or
+	// aggregating the statistics of, a repository which does not keep them
+	ErrStatsUnsupported = errors.New("statistics are not supported by this backend")
+	// ErrInvalidOrderRecord is returned for an order record breaking the
+	// invariants of OrderRecord.Validate, e.g. without an ID or with an
+	// unknown status
+	ErrInvalidOrderRecord = errors.New("invalid order record")
+	// ErrInvalidSalesRange is returned for a sales time series ending before
+	// it starts, with a granularity other than an hour or a day, or with more
+	// than maxSalesBuckets buckets
+	ErrInvalidSalesRange = errors.New("invalid sales range")
+)
+
+// maxSalesBuckets bounds a sales time series to about three months of hours
+const maxSalesBuckets = 24 * 92
+
+// StatsAggregator is implemented by repositories recording the orders
+// created through the coffee-service and aggregating them in the database
+type StatsAggregator interface {
+	// RecordOrder inserts an order record, or replaces the record of the same
+	// order
+	RecordOrder(ctx context.Context, order *entities.OrderRecord) error
+	// AggregateStats counts the coffees and the orders per status, ranks the
+	// top coffees sold and sums the revenue of the orders created since
+	AggregateStats(ctx context.Context, since time.Time, top int) (*entities.Stats, error)
+	// SalesByHour returns the orders and revenue, but cancelled orders, of
+	// the hours starting from from until to which had any. Hours start on the
+	// hour UTC.
+	SalesByHour(ctx context.Context, from, to time.Time) ([]entities.SalesBucket, error)
+}
+
+// RecordOrder validates an order record and records it in a StatsAggregator,
+// or returns ErrStatsUnsupported
+func RecordOrder(ctx context.Context, r Repository, order *entities.OrderRecord) error {
+	var aggregator interface {
+		RecordOrder(ctx context.Context, order *entities.OrderRecord) error
+	}
+	if !As(r, &aggregator) {
+		return ErrStatsUnsupported
+	}
+	if order.Validate() != nil {
+		return ErrInvalidOrderRecord
+	}
+	return aggregator.RecordOrder(ctx, order)
+}
+
+// AggregateStats returns the statistics of a StatsAggregator with the top
+// sellers, and the revenue of the orders created since
+func AggregateStats(ctx context.Context, r Repository, since time.Time, top int) (*entities.Stats, error) {
+	var aggregator interface {
+		AggregateStats(ctx context.Context, since time.Time, top int) (*entities.Stats, error)
+	}
+	if !As(r, &aggregator) {
+		return nil, ErrStatsUnsupported
+	}
+	return aggregator.AggregateStats(ctx, since, top)
+}
+
+// salesByHour returns the hourly sales of a StatsAggregator, for the wrappers
+// passing them through
+func salesByHour(ctx context.Context, r Repository, from, to time.Time) ([]entities.SalesBucket, error) {
+	var aggregator interface {
+		SalesByHour(ctx context.Context, from, to time.Time) ([]entities.SalesBucket, error)
+	}
+	if !As(r, &aggregator) {
+		return nil, ErrStatsUnsupported
+	}
+	return aggregator.SalesByHour(ctx, from, to)
+}
+
+// SalesSeries returns the orders and revenue of a StatsAggregator in buckets
+// of granularity, an hour or a day, from from until to. Every bucket is
+// returned, with no orders when none were created, so that charts need not
+// fill the gaps. Buckets start on the hour, or at midnight, UTC.
+func SalesSeries(ctx context.Context, r Repository, from, to time.Time, granularity time.Duration) ([]entities.SalesBucket, error) {
+	var aggregator interface {
+		SalesByHour(ctx context.Context, from, to time.Time) ([]entities.SalesBucket, error)
+	}
+	if !As(r, &aggregator) {
+		return nil, ErrStatsUnsupported
+	}
+	if granularity != time.Hour && granularity != 24*time.Hour {
+		return nil, ErrInvalidSalesRange
+	}
+	from = from.UTC().Truncate(granularity)
+	to = to.UTC()
+	if !from.Before(to) || to.Sub(from) > maxSalesBuckets*granularity {
+		return nil, ErrInvalidSalesRange
+	}
+
+	hours, err := aggregator.SalesByHour(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := []entities.SalesBucket{}
+	for t := from; t.Before(to); t = t.Add(granularity) {
+		series = append(series, entities.SalesBucket{Time: t})
+	}
+	for _, hour := range hours {
+		n := int(hour.Time.UTC().Sub(from) / granularity)
+		if n < 0 || n >= len(series) {
+			continue
+		}
+		series[n].Orders += hour.Orders
+		series[n].Revenue += hour.Revenue
+	}
+	for n := range series {
+		series[n].Revenue = math.Round(series[n].Revenue*100) / 100
+	}
+	return series, nil
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testStats verifies a Repository holding the seed coffees aggregates the
+// orders recorded in it
+func testStats(t *testing.T, r Repository) {
+	ctx := context.Background()
+	midnight := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	stats, err := AggregateStats(ctx, r, midnight, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Coffees)
+	assert.Empty(t, stats.OrdersByStatus)
+	assert.Empty(t, stats.TopSellers)
+	assert.Equal(t, 0.0, stats.RevenueToday)
+
+	for _, order := range []entities.OrderRecord{
+		// yesterday
+		{ID: 1, Status: entities.OrderPaid, Total: 100, CreatedAt: midnight.Add(-time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 1, Name: "Packer Spiced Latte", Quantity: 1, Price: 100}}},
+		{ID: 2, Status: entities.OrderPaid, Total: 350, CreatedAt: midnight.Add(time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 2, Name: "Vaulatte", Quantity: 1, Price: 200}, {CoffeeID: 3, Name: "Nomadicano", Quantity: 1, Price: 150}}},
+		{ID: 3, Status: entities.OrderCreated, Total: 300, CreatedAt: midnight.Add(2 * time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 3, Name: "Nomadicano", Quantity: 2, Price: 150}}},
+		{ID: 4, Status: entities.OrderCancelled, Total: 1000, CreatedAt: midnight.Add(3 * time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 2, Name: "Vaulatte", Quantity: 5, Price: 200}}},
+	} {
+		order := order
+		require.NoError(t, RecordOrder(ctx, r, &order))
+	}
+	assert.Equal(t, ErrInvalidOrderRecord, RecordOrder(ctx, r, &entities.OrderRecord{ID: 5, Status: "lost"}))
+	assert.Equal(t, ErrInvalidOrderRecord, RecordOrder(ctx, r, &entities.OrderRecord{Status: entities.OrderPaid}))
+
+	stats, err = AggregateStats(ctx, r, midnight, 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{entities.OrderPaid: 2, entities.OrderCreated: 1, entities.OrderCancelled: 1}, stats.OrdersByStatus)
+	assert.Equal(t, []entities.TopSeller{{CoffeeID: 3, Name: "Nomadicano", Quantity: 3}, {CoffeeID: 1, Name: "Packer Spiced Latte", Quantity: 1}}, stats.TopSellers)
+	assert.Equal(t, 650.0, stats.RevenueToday)
+
+	// recording an order again replaces it
+	require.NoError(t, RecordOrder(ctx, r, &entities.OrderRecord{ID: 3, Status: entities.OrderCancelled, Total: 300, CreatedAt: midnight.Add(2 * time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 3, Name: "Nomadicano", Quantity: 2, Price: 150}}}))
+	stats, err = AggregateStats(ctx, r, midnight, 5)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{entities.OrderPaid: 2, entities.OrderCancelled: 2}, stats.OrdersByStatus)
+	assert.Len(t, stats.TopSellers, 3)
+	assert.Equal(t, 350.0, stats.RevenueToday)
+}
+
+// testSales verifies a Repository buckets the orders recorded in it in a
+// sales time series
+func testSales(t *testing.T, r Repository) {
+	ctx := context.Background()
+	midnight := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, order := range []entities.OrderRecord{
+		{ID: 1, Status: entities.OrderPaid, Total: 100, CreatedAt: midnight.Add(-time.Minute)},
+		{ID: 2, Status: entities.OrderPaid, Total: 150.5, CreatedAt: midnight.Add(10 * time.Minute)},
+		{ID: 3, Status: entities.OrderCreated, Total: 200, CreatedAt: midnight.Add(50 * time.Minute)},
+		{ID: 4, Status: entities.OrderCancelled, Total: 1000, CreatedAt: midnight.Add(30 * time.Minute)},
+		{ID: 5, Status: entities.OrderPaid, Total: 300, CreatedAt: midnight.Add(2*time.Hour + time.Minute)},
+	} {
+		order := order
+		require.NoError(t, RecordOrder(ctx, r, &order))
+	}
+
+	series, err := SalesSeries(ctx, r, midnight.Add(30*time.Minute), midnight.Add(3*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.SalesBucket{
+		{Time: midnight, Orders: 2, Revenue: 350.5},
+		{Time: midnight.Add(time.Hour)},
+		{Time: midnight.Add(2 * time.Hour), Orders: 1, Revenue: 300},
+	}, series)
+
+	// cancelling an order takes it out of its hour
+	require.NoError(t, RecordOrder(ctx, r, &entities.OrderRecord{ID: 2, Status: entities.OrderCancelled, Total: 150.5, CreatedAt: midnight.Add(10 * time.Minute)}))
+	series, err = SalesSeries(ctx, r, midnight.Add(-24*time.Hour), midnight.Add(24*time.Hour), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.SalesBucket{
+		{Time: midnight.Add(-24 * time.Hour), Orders: 1, Revenue: 100},
+		{Time: midnight, Orders: 2, Revenue: 500},
+	}, series)
+
+	for _, invalid := range []struct {
+		from, to    time.Time
+		granularity time.Duration
+	}{
+		{midnight, midnight, time.Hour},
+		{midnight, midnight.Add(-time.Hour), time.Hour},
+		{midnight, midnight.Add(time.Hour), time.Minute},
+		{midnight, midnight.Add(365 * 24 * time.Hour), time.Hour},
+	} {
+		_, err := SalesSeries(ctx, r, invalid.from, invalid.to, invalid.granularity)
+		assert.Equal(t, ErrInvalidSalesRange, err, invalid)
+	}
+}
+
+func TestInMemoryStats(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testStats(t, r)
+}
+
+func TestInMemorySales(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testSales(t, r)
+}
+
+func TestStatsPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testStats(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+
+	r, err = NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	testSales(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+}
+
+func TestStatsUnsupported(t *testing.T) {
+	_, err := AggregateStats(context.Background(), &MockRepository{}, time.Now(), 5)
+	assert.Equal(t, ErrStatsUnsupported, err)
+	assert.Equal(t, ErrStatsUnsupported, RecordOrder(context.Background(), &MockRepository{}, &entities.OrderRecord{}))
+	_, err = SalesSeries(context.Background(), &MockRepository{}, time.Now().Add(-time.Hour), time.Now(), time.Hour)
+	assert.Equal(t, ErrStatsUnsupported, err)
+}
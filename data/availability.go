@@ -0,0 +1,270 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// weekdays are the days of an availability rule, indexed by time.Weekday
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ErrAvailabilityUnsupported is returned when managing the availability of a
+// repository which does not store it
+var ErrAvailabilityUnsupported = errors.New("availability rules are not supported by this backend")
+
+// ErrInvalidAvailability is returned for an availability rule with an unknown
+// day, a malformed time or date, or only one end of a window
+var ErrInvalidAvailability = errors.New("invalid availability rule")
+
+// Scheduler is implemented by repositories storing availability rules, the
+// windows in which a coffee is on the menu
+type Scheduler interface {
+	// FindAvailability returns the availability rules of the coffees in
+	// coffeeIDs
+	FindAvailability(ctx context.Context, coffeeIDs []int) (entities.AvailabilityRules, error)
+	// SetAvailability replaces the availability rules of a coffee, ErrNotFound
+	// when the coffee does not exist
+	SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error
+}
+
+// FindAvailability returns the availability rules of the coffees in coffeeIDs
+// from a Scheduler, or ErrAvailabilityUnsupported
+func FindAvailability(ctx context.Context, r Repository, coffeeIDs []int) (entities.AvailabilityRules, error) {
+	var scheduler interface {
+		FindAvailability(ctx context.Context, coffeeIDs []int) (entities.AvailabilityRules, error)
+	}
+	if !As(r, &scheduler) {
+		return nil, ErrAvailabilityUnsupported
+	}
+	return scheduler.FindAvailability(ctx, coffeeIDs)
+}
+
+// SetAvailability validates the availability rules of a coffee and stores them
+// in a Scheduler, replacing the previous ones. A coffee without rules is always
+// available. Days are lower cased first, e.g. Sat becomes sat.
+func SetAvailability(ctx context.Context, r Repository, coffeeID int, rules entities.AvailabilityRules) error {
+	var scheduler interface {
+		SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error
+	}
+	if !As(r, &scheduler) {
+		return ErrAvailabilityUnsupported
+	}
+
+	for n := range rules {
+		if err := validAvailability(&rules[n]); err != nil {
+			return err
+		}
+		rules[n].CoffeeID = coffeeID
+	}
+	return scheduler.SetAvailability(ctx, coffeeID, rules)
+}
+
+// validAvailability checks an availability rule, lower casing its days
+func validAvailability(rule *entities.AvailabilityRule) error {
+	for n, day := range rule.Days {
+		rule.Days[n] = strings.ToLower(day)
+		if weekday(rule.Days[n]) < 0 {
+			return ErrInvalidAvailability
+		}
+	}
+	if !validWindow(rule.From, rule.To, "15:04") || !validWindow(rule.Start, rule.End, "01-02") {
+		return ErrInvalidAvailability
+	}
+	return nil
+}
+
+// validWindow reports whether both ends of a window are empty, or both are
+// distinct values in layout
+func validWindow(from, to, layout string) bool {
+	if from == "" && to == "" {
+		return true
+	}
+	if from == to {
+		return false
+	}
+	_, fromErr := time.Parse(layout, from)
+	_, toErr := time.Parse(layout, to)
+	return fromErr == nil && toErr == nil
+}
+
+// weekday returns the index of a day in weekdays, or -1
+func weekday(day string) int {
+	for n, d := range weekdays {
+		if d == day {
+			return n
+		}
+	}
+	return -1
+}
+
+// Available reports whether a coffee with rules is on the menu at t, which is
+// when any of its rules holds. A coffee without rules is always available.
+func Available(rules entities.AvailabilityRules, t time.Time) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	for _, rule := range rules {
+		if availableAt(rule, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableAt reports whether a rule holds at t, in the location of t. Times
+// and dates are compared as zero padded strings, a window ending before it
+// starts wraps past midnight or the end of the year.
+func availableAt(rule entities.AvailabilityRule, t time.Time) bool {
+	if len(rule.Days) > 0 {
+		today := weekdays[t.Weekday()]
+		found := false
+		for _, day := range rule.Days {
+			found = found || day == today
+		}
+		if !found {
+			return false
+		}
+	}
+	// To is exclusive so that back to back windows do not overlap, End is
+	// inclusive so that a season names its last day
+	return inWindow(t.Format("15:04"), rule.From, rule.To, false) && inWindow(t.Format("01-02"), rule.Start, rule.End, true)
+}
+
+// inWindow reports whether value lies between from and to, wrapping when to
+// is before from. An empty window holds everything.
+func inWindow(value, from, to string, inclusive bool) bool {
+	if from == "" {
+		return true
+	}
+	beforeEnd := value < to || (inclusive && value == to)
+	if from < to {
+		return value >= from && beforeEnd
+	}
+	return value >= from || beforeEnd
+}
+
+// ScheduledRepository is a Repository reading only the coffees available now
+// under their availability rules, evaluated on every read against its clock.
+// Repositories without availability rules are read unchanged.
+type ScheduledRepository struct {
+	Repository
+	now func() time.Time
+}
+
+// NewScheduled wraps repository to hide the coffees outside their availability
+func NewScheduled(repository Repository) *ScheduledRepository {
+	return &ScheduledRepository{Repository: repository, now: time.Now}
+}
+
+// Unwrap returns the wrapped repository
+func (r *ScheduledRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Find returns the available coffees
+func (r *ScheduledRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	coffees, err := r.Repository.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.available(ctx, coffees)
+}
+
+// FindWhere returns the available coffees matching expr
+func (r *ScheduledRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindWhere(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	return r.available(ctx, coffees)
+}
+
+// FindByID returns an available coffee, or ErrNotFound
+func (r *ScheduledRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	coffee, err := r.Repository.FindByID(ctx, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	return r.availableCoffee(ctx, coffee)
+}
+
+// FindBySlug returns the available coffee with the slug, or ErrNotFound
+func (r *ScheduledRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	coffee, err := FindBySlug(ctx, r.Repository, slug)
+	if err != nil {
+		return nil, err
+	}
+	return r.availableCoffee(ctx, coffee)
+}
+
+// FindRelated returns up to limit available coffees related to an available
+// one. Every related coffee is ranked, so hidden ones never shorten the list.
+func (r *ScheduledRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	if _, err := r.FindByID(ctx, coffeeID); err != nil {
+		return nil, err
+	}
+
+	ranked, err := r.Repository.FindRelated(ctx, coffeeID, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	related, err := r.available(ctx, ranked)
+	if err != nil {
+		return nil, err
+	}
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related, nil
+}
+
+// available keeps the coffees available now, loading the rules of all of them
+// at once
+func (r *ScheduledRepository) available(ctx context.Context, coffees entities.Coffees) (entities.Coffees, error) {
+	if len(coffees) == 0 {
+		return coffees, nil
+	}
+
+	ids := make([]int, len(coffees))
+	for n := range coffees {
+		ids[n] = coffees[n].ID
+	}
+	all, err := FindAvailability(ctx, r.Repository, ids)
+	if err == ErrAvailabilityUnsupported {
+		return coffees, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules := map[int]entities.AvailabilityRules{}
+	for _, rule := range all {
+		rules[rule.CoffeeID] = append(rules[rule.CoffeeID], rule)
+	}
+
+	now := r.now()
+	available := coffees[:0]
+	for _, coffee := range coffees {
+		if Available(rules[coffee.ID], now) {
+			available = append(available, coffee)
+		}
+	}
+	return available, nil
+}
+
+// availableCoffee hides a coffee which is not available now
+func (r *ScheduledRepository) availableCoffee(ctx context.Context, coffee *entities.Coffee) (*entities.Coffee, error) {
+	available, err := r.available(ctx, entities.Coffees{*coffee})
+	if err != nil {
+		return nil, err
+	}
+	if len(available) == 0 {
+		return nil, ErrNotFound
+	}
+	return coffee, nil
+}
@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func TestAvailable(t *testing.T) {
+	// a Thursday in autumn
+	thursday := time.Date(2026, 10, 15, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rules entities.AvailabilityRules
+		at    time.Time
+		want  bool
+	}{
+		{"no rules", nil, thursday, true},
+		{"weekday", entities.AvailabilityRules{{Days: []string{"mon", "thu"}}}, thursday, true},
+		{"other weekday", entities.AvailabilityRules{{Days: []string{"sat", "sun"}}}, thursday, false},
+		{"time of day", entities.AvailabilityRules{{From: "07:00", To: "11:00"}}, thursday, true},
+		{"before opening", entities.AvailabilityRules{{From: "09:00", To: "11:00"}}, thursday, false},
+		{"closing time is excluded", entities.AvailabilityRules{{From: "07:00", To: "08:30"}}, thursday, false},
+		{"past midnight", entities.AvailabilityRules{{From: "22:00", To: "02:00"}}, thursday.Add(17 * time.Hour), true},
+		{"outside a window past midnight", entities.AvailabilityRules{{From: "22:00", To: "02:00"}}, thursday, false},
+		{"season", entities.AvailabilityRules{{Start: "09-22", End: "12-20"}}, thursday, true},
+		{"out of season", entities.AvailabilityRules{{Start: "06-21", End: "09-21"}}, thursday, false},
+		{"last day of the season is included", entities.AvailabilityRules{{Start: "09-22", End: "10-15"}}, thursday, true},
+		{"season over the new year", entities.AvailabilityRules{{Start: "12-01", End: "02-28"}}, time.Date(2027, 1, 10, 0, 0, 0, 0, time.UTC), true},
+		{"every condition of a rule", entities.AvailabilityRules{{Days: []string{"thu"}, From: "12:00", To: "14:00", Start: "09-22", End: "12-20"}}, thursday, false},
+		{"any rule", entities.AvailabilityRules{{Days: []string{"sat"}}, {From: "08:00", To: "09:00"}}, thursday, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Available(tt.rules, tt.at))
+		})
+	}
+}
+
+func TestValidAvailability(t *testing.T) {
+	rule := entities.AvailabilityRule{Days: []string{"Sat", "SUN"}, From: "07:00", To: "11:00"}
+	require.NoError(t, validAvailability(&rule))
+	assert.Equal(t, []string{"sat", "sun"}, rule.Days)
+
+	for _, invalid := range []entities.AvailabilityRule{
+		{Days: []string{"someday"}},
+		{From: "07:00"},
+		{From: "7am", To: "11am"},
+		{From: "25:00", To: "26:00"},
+		{From: "07:00", To: "07:00"},
+		{Start: "09-22"},
+		{Start: "22-09", End: "12-20"},
+		{Start: "02-30", End: "03-01"},
+	} {
+		invalid := invalid
+		assert.Equal(t, ErrInvalidAvailability, validAvailability(&invalid), "%+v", invalid)
+	}
+}
+
+// testAvailability verifies a Repository holding the seed data stores the
+// availability rules of coffees, replacing them on every write
+func testAvailability(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	autumn := entities.AvailabilityRules{{Start: "09-22", End: "12-20"}}
+	require.NoError(t, SetAvailability(ctx, r, 1, autumn))
+	mornings := entities.AvailabilityRules{
+		{Days: []string{"Sat", "sun"}, From: "07:00", To: "11:00"},
+		{Days: []string{"mon"}},
+	}
+	require.NoError(t, SetAvailability(ctx, r, 2, mornings))
+
+	assert.Equal(t, ErrNotFound, SetAvailability(ctx, r, 42, autumn))
+	assert.Equal(t, ErrInvalidAvailability, SetAvailability(ctx, r, 1, entities.AvailabilityRules{{From: "07:00"}}))
+
+	rules, err := FindAvailability(ctx, r, []int{1, 2, 3})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, 1, rules[0].CoffeeID)
+	assert.Equal(t, "09-22", rules[0].Start)
+	assert.Equal(t, "12-20", rules[0].End)
+	assert.Equal(t, 2, rules[1].CoffeeID)
+	assert.Equal(t, []string{"sat", "sun"}, rules[1].Days)
+	assert.Equal(t, "07:00", rules[1].From)
+	assert.Equal(t, []string{"mon"}, rules[2].Days)
+
+	// setting the rules again replaces them, no rules make a coffee always
+	// available
+	require.NoError(t, SetAvailability(ctx, r, 2, entities.AvailabilityRules{}))
+	rules, err = FindAvailability(ctx, r, []int{2})
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	// the rules go with their coffee
+	require.NoError(t, r.DeleteCoffee(ctx, 1))
+	rules, err = FindAvailability(ctx, r, []int{1})
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestInMemoryAvailability(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testAvailability(t, r)
+}
+
+func TestAvailabilityPassesThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testAvailability(t, NewPublished(NewChanges(r, 10)))
+}
+
+func TestScheduledHidesUnavailableCoffees(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	menu := NewScheduled(NewPublished(r))
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	menu.now = func() time.Time { return now }
+
+	require.NoError(t, SetAvailability(ctx, r, 1, entities.AvailabilityRules{{Start: "09-22", End: "12-20"}}))
+	require.NoError(t, SetAvailability(ctx, r, 3, entities.AvailabilityRules{{From: "06:00", To: "12:00"}}))
+
+	// summer
+	coffees, err := menu.Find(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4, 5, 6}, coffeeIDs(coffees))
+
+	_, err = menu.FindByID(ctx, 1)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = menu.FindBySlug(ctx, "packer-spiced-latte")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = menu.FindRelated(ctx, 1, 3)
+	assert.Equal(t, ErrNotFound, err)
+
+	related, err := menu.FindRelated(ctx, 2, 10)
+	require.NoError(t, err)
+	assert.NotContains(t, coffeeIDs(related), 1)
+
+	// an autumn evening, evaluated on the next read
+	now = time.Date(2026, 10, 15, 18, 0, 0, 0, time.UTC)
+	expr, err := filter.Parse("price<=150")
+	require.NoError(t, err)
+	coffees, err = menu.FindWhere(ctx, expr)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, coffeeIDs(coffees))
+
+	coffee, err := menu.FindBySlug(ctx, "packer-spiced-latte")
+	require.NoError(t, err)
+	assert.Equal(t, 1, coffee.ID)
+
+	related, err = menu.FindRelated(ctx, 2, 2)
+	require.NoError(t, err)
+	assert.Len(t, related, 2)
+	assert.NotContains(t, coffeeIDs(related), 3)
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// Backend creates the Repository of a backend from the configuration
+type Backend func(cfg *config.Config) (Repository, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{}
+)
+
+// RegisterBackend makes a backend available by name, e.g. for the version
+// backends, MIGRATION_BACKEND and SHADOW_BACKEND. Backends register themselves
+// from an init function of the file implementing them, adding one needs no
+// change to the callers. It panics when name is already registered.
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if backend == nil {
+		panic("data: RegisterBackend backend is nil")
+	}
+	if _, ok := backends[name]; ok {
+		panic("data: RegisterBackend called twice for backend " + name)
+	}
+	backends[name] = backend
+}
+
+// Backends returns the names of the registered backends, sorted
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend creates the Repository of the backend registered as name
+func NewBackend(cfg *config.Config, name string) (Repository, error) {
+	backendsMu.RLock()
+	backend, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, registered backends are %v", name, Backends())
+	}
+	return backend(cfg)
+}
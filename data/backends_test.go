@@ -0,0 +1,46 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func TestBuiltinBackendsAreRegistered(t *testing.T) {
+	assert.Subset(t, Backends(), []string{config.MemoryBackend, config.PostgresBackend})
+}
+
+func TestNewBackendCreatesRegisteredBackend(t *testing.T) {
+	mock := &MockRepository{}
+	RegisterBackend("mock", func(cfg *config.Config) (Repository, error) {
+		return mock, nil
+	})
+
+	r, err := NewBackend(&config.Config{Logger: hclog.NewNullLogger()}, "mock")
+	require.NoError(t, err)
+	assert.Same(t, mock, r)
+
+	assert.Panics(t, func() {
+		RegisterBackend("mock", func(cfg *config.Config) (Repository, error) { return nil, nil })
+	})
+}
+
+func TestNewBackendShardsMemory(t *testing.T) {
+	r, err := NewBackend(&config.Config{Logger: hclog.NewNullLogger(), MemoryShards: 2}, config.MemoryBackend)
+	require.NoError(t, err)
+	assert.IsType(t, &ShardedRepository{}, r)
+
+	r, err = NewBackend(&config.Config{Logger: hclog.NewNullLogger()}, config.MemoryBackend)
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryRepository{}, r)
+}
+
+func TestNewBackendFailsForUnknownBackend(t *testing.T) {
+	_, err := NewBackend(&config.Config{Logger: hclog.NewNullLogger()}, "cassandra")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown backend "cassandra"`)
+}
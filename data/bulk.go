@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// BulkDeleter is implemented by repositories deleting every coffee matching a
+// filter in a single transaction
+type BulkDeleter interface {
+	DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) ([]int, error)
+}
+
+// DeleteWhere deletes the coffees matching expr and returns their IDs in
+// order. With dryRun nothing is deleted and the IDs are the ones a delete
+// would remove. A BulkDeleter deletes them in one transaction, other
+// repositories one by one, so a failed delete leaves the coffees deleted up to
+// then, which are returned with the error.
+func DeleteWhere(ctx context.Context, r Repository, expr filter.Expr, dryRun bool) ([]int, error) {
+	if deleter, ok := r.(BulkDeleter); ok {
+		return deleter.DeleteWhere(ctx, expr, dryRun)
+	}
+
+	coffees, err := r.FindWhere(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(coffees))
+	for _, c := range coffees {
+		ids = append(ids, c.ID)
+	}
+	entities.PutCoffees(coffees)
+	if dryRun {
+		return ids, nil
+	}
+
+	deleted := make([]int, 0, len(ids))
+	for _, id := range ids {
+		// deleted concurrently, which is the outcome asked for
+		if err := r.DeleteCoffee(ctx, id); err != nil && err != ErrNotFound {
+			return deleted, fmt.Errorf("unable to delete coffee %d: %w", id, err)
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// testDeleteWhere verifies a Repository holding the seed data deletes the
+// coffees matching a filter, and nothing on a dry run
+func testDeleteWhere(t *testing.T, r Repository) {
+	ctx := context.Background()
+	expr, err := filter.Parse("price<=150")
+	require.NoError(t, err)
+
+	ids, err := DeleteWhere(ctx, r, expr, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, ids)
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, len(seededIngredients))
+
+	ids, err = DeleteWhere(ctx, r, expr, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, ids)
+	coffees, err = r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, len(seededIngredients)-2)
+	_, err = r.FindByID(ctx, 3)
+	assert.Equal(t, ErrNotFound, err)
+
+	// the ingredients of the deleted coffees are gone too, so they can be
+	// deleted without dangling references
+	require.NoError(t, r.DeleteIngredient(ctx, 3))
+
+	ids, err = DeleteWhere(ctx, r, expr, false)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+// oneByOne hides the BulkDeleter of a repository
+type oneByOne struct {
+	Repository
+}
+
+func TestInMemoryDeleteWhere(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testDeleteWhere(t, r)
+}
+
+func TestDeleteWhereOneByOne(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testDeleteWhere(t, oneByOne{r})
+}
+
+func TestChangesRecordDeleteWhere(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	changes := NewChanges(r, 100)
+
+	expr, err := filter.Parse(`teaser~"nothing"`)
+	require.NoError(t, err)
+
+	_, err = DeleteWhere(ctx, changes, expr, true)
+	require.NoError(t, err)
+	recorded, _, err := changes.Since(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, recorded)
+
+	ids, err := DeleteWhere(ctx, changes, expr, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 4}, ids)
+	recorded, _, err = changes.Since(0, 10)
+	require.NoError(t, err)
+	require.Len(t, recorded, 2)
+	for n, change := range recorded {
+		assert.Equal(t, ChangeDelete, change.Op)
+		assert.Equal(t, ids[n], change.ID)
+	}
+}
@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// ErrCursorExpired is returned for a cursor older than the retained changes,
+// the consumer has to resync from a full read
+var ErrCursorExpired = errors.New("cursor is older than the retained changes")
+
+// The entities of a Change
+const (
+	ChangeCoffee     = "coffee"
+	ChangeIngredient = "ingredient"
+)
+
+// The operations of a Change
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Change is a mutation of an entity. The payload is the entity after an
+// insert or update and empty for a delete.
+type Change struct {
+	Cursor  uint64      `json:"cursor"`
+	Time    time.Time   `json:"time"`
+	Entity  string      `json:"entity"`
+	Op      string      `json:"op"`
+	ID      int         `json:"id"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// ChangesRepository is a Repository recording every successful write in an
+// ordered log, so downstream caches can sync incrementally instead of reading
+// the whole catalogue. Writes are serialized so the log orders them as they
+// were applied. Only the latest retention changes are kept.
+//
+// Ingredient names are part of every coffee using them, so updating or
+// deleting an ingredient also records an update of those coffees.
+//
+// The optional capabilities are reached through Unwrap. Their writes, e.g.
+// translations, coupons or orders, are not recorded: the log carries the
+// stored coffees and ingredients, the menu the caches sync.
+type ChangesRepository struct {
+	Repository
+	retention int
+
+	// writes serializes the writes and their changes
+	writes sync.Mutex
+
+	mu          sync.RWMutex
+	changes     []Change
+	last        uint64
+	subscribers []func(Change)
+}
+
+// NewChanges wraps repository to record its writes, keeping the latest
+// retention changes. A retention of 0 keeps none, the changes are only
+// passed to the OnChange subscribers.
+func NewChanges(repository Repository, retention int) *ChangesRepository {
+	return &ChangesRepository{Repository: repository, retention: retention}
+}
+
+// Unwrap returns the wrapped repository
+func (r *ChangesRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// OnChange registers fn to be called with every change once its write
+// succeeded. Calls are made in the order of the changes, before the write
+// returns, so fn must not write to the repository.
+func (r *ChangesRepository) OnChange(fn func(Change)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Since returns up to limit changes after cursor in order, and the cursor of
+// the last one returned. A cursor of 0 starts from the oldest retained change.
+func (r *ChangesRepository) Since(cursor uint64, limit int) ([]Change, uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if cursor > r.last {
+		cursor = r.last
+	}
+	// the changes after cursor are contiguous from first
+	first := r.last - uint64(len(r.changes)) + 1
+	if cursor+1 < first {
+		if cursor > 0 {
+			return nil, 0, ErrCursorExpired
+		}
+		cursor = first - 1
+	}
+
+	changes := r.changes[cursor+1-first:]
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	next := cursor
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Cursor
+	}
+	return append([]Change{}, changes...), next, nil
+}
+
+// CreateCoffee creates the coffee and records its insert
+func (r *ChangesRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.Repository.CreateCoffee(ctx, coffee); err != nil {
+		return err
+	}
+	r.record(ChangeCoffee, ChangeInsert, coffee.ID, copyCoffee(coffee))
+	return nil
+}
+
+// UpdateCoffee updates the coffee and records its update
+func (r *ChangesRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.Repository.UpdateCoffee(ctx, coffee); err != nil {
+		return err
+	}
+	r.record(ChangeCoffee, ChangeUpdate, coffee.ID, copyCoffee(coffee))
+	return nil
+}
+
+// DeleteCoffee deletes the coffee and records its delete
+func (r *ChangesRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.Repository.DeleteCoffee(ctx, coffeeID); err != nil {
+		return err
+	}
+	r.record(ChangeCoffee, ChangeDelete, coffeeID, nil)
+	return nil
+}
+
+// DeleteWhere deletes the coffees matching expr and records their deletes. A
+// dry run records nothing.
+func (r *ChangesRepository) DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) ([]int, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	// coffees deleted before a failure are recorded too
+	ids, err := DeleteWhere(ctx, r.Repository, expr, dryRun)
+	if !dryRun {
+		for _, id := range ids {
+			r.record(ChangeCoffee, ChangeDelete, id, nil)
+		}
+	}
+	return ids, err
+}
+
+// CreateIngredient creates the ingredient and records its insert
+func (r *ChangesRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.Repository.CreateIngredient(ctx, ingredient); err != nil {
+		return err
+	}
+	created := *ingredient
+	r.record(ChangeIngredient, ChangeInsert, ingredient.ID, &created)
+	return nil
+}
+
+// UpdateIngredient updates the ingredient and records its update, followed by
+// the updates of the coffees using it
+func (r *ChangesRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	using, err := r.coffeesUsing(ctx, ingredient.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.UpdateIngredient(ctx, ingredient); err != nil {
+		return err
+	}
+	updated := *ingredient
+	r.record(ChangeIngredient, ChangeUpdate, ingredient.ID, &updated)
+	return r.recordCoffees(ctx, using)
+}
+
+// DeleteIngredient deletes the ingredient and records its delete, followed by
+// the updates of the coffees which used it
+func (r *ChangesRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	using, err := r.coffeesUsing(ctx, ingredientID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.DeleteIngredient(ctx, ingredientID); err != nil {
+		return err
+	}
+	r.record(ChangeIngredient, ChangeDelete, ingredientID, nil)
+	return r.recordCoffees(ctx, using)
+}
+
+// coffeesUsing returns the IDs of the coffees using an ingredient
+func (r *ChangesRepository) coffeesUsing(ctx context.Context, ingredientID int) ([]int, error) {
+	coffees, err := r.Repository.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer entities.PutCoffees(coffees)
+
+	ids := []int{}
+	for _, c := range coffees {
+		for _, i := range c.Ingredients {
+			if i.IngredientID == ingredientID {
+				ids = append(ids, c.ID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// recordCoffees records the update of every coffee in ids. The write already
+// succeeded, so a coffee deleted meanwhile is skipped.
+func (r *ChangesRepository) recordCoffees(ctx context.Context, ids []int) error {
+	for _, id := range ids {
+		coffee, err := r.Repository.FindByID(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.record(ChangeCoffee, ChangeUpdate, id, copyCoffee(coffee))
+	}
+	return nil
+}
+
+// record appends a change to the log, dropping the oldest one beyond the
+// retention, and passes it to the subscribers
+func (r *ChangesRepository) record(entity, op string, id int, payload interface{}) {
+	r.mu.Lock()
+	r.last++
+	change := Change{Cursor: r.last, Time: time.Now().UTC(), Entity: entity, Op: op, ID: id, Payload: payload}
+	r.changes = append(r.changes, change)
+	if len(r.changes) > r.retention {
+		r.changes = r.changes[len(r.changes)-r.retention:]
+	}
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	// the writes are serialized, so are the calls
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// copyCoffee copies a coffee the caller keeps, without its stats
+func copyCoffee(coffee *entities.Coffee) *entities.Coffee {
+	c := *coffee
+	c.Ingredients = append([]entities.CoffeeIngredients{}, coffee.Ingredients...)
+	c.Stats = nil
+	return &c
+}
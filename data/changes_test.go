@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupChanges(t *testing.T, retention int) *ChangesRepository {
+	repository, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	return NewChanges(repository, retention)
+}
+
+func TestChangesRecordsWritesInOrder(t *testing.T) {
+	ctx := context.Background()
+	r := setupChanges(t, 100)
+
+	changes, cursor, err := r.Since(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, uint64(0), cursor)
+
+	coffee := &entities.Coffee{Name: "Changelog Chai", Price: 200, Ingredients: []entities.CoffeeIngredients{{IngredientID: 5, Quantity: 100, Unit: "ml"}}}
+	require.NoError(t, r.CreateCoffee(ctx, coffee))
+	coffee.Price = 250
+	require.NoError(t, r.UpdateCoffee(ctx, coffee))
+	require.NoError(t, r.DeleteCoffee(ctx, coffee.ID))
+	assert.Equal(t, ErrNotFound, r.DeleteCoffee(ctx, coffee.ID))
+
+	changes, cursor, err = r.Since(0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, uint64(3), cursor)
+	assert.Equal(t, []string{ChangeInsert, ChangeUpdate, ChangeDelete}, []string{changes[0].Op, changes[1].Op, changes[2].Op})
+	assert.Equal(t, 200.0, changes[0].Payload.(*entities.Coffee).Price)
+	assert.Equal(t, 250.0, changes[1].Payload.(*entities.Coffee).Price)
+	assert.Nil(t, changes[2].Payload)
+
+	changes, cursor, err = r.Since(1, 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, uint64(2), changes[0].Cursor)
+	assert.Equal(t, uint64(2), cursor)
+
+	changes, cursor, err = r.Since(3, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, uint64(3), cursor)
+}
+
+func TestChangesCallsSubscribersWithoutRetention(t *testing.T) {
+	ctx := context.Background()
+	r := setupChanges(t, 0)
+	seen := []string{}
+	r.OnChange(func(change Change) { seen = append(seen, change.Op) })
+
+	coffee := &entities.Coffee{Name: "Changelog Chai", Price: 200}
+	require.NoError(t, r.CreateCoffee(ctx, coffee))
+	require.NoError(t, r.UpdateCoffee(ctx, coffee))
+	require.NoError(t, r.DeleteCoffee(ctx, coffee.ID))
+	assert.Equal(t, ErrNotFound, r.DeleteCoffee(ctx, coffee.ID))
+
+	assert.Equal(t, []string{ChangeInsert, ChangeUpdate, ChangeDelete}, seen)
+	changes, _, err := r.Since(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestChangesRecordsCoffeesUsingAnIngredient(t *testing.T) {
+	ctx := context.Background()
+	r := setupChanges(t, 100)
+
+	require.NoError(t, r.DeleteIngredient(ctx, 5))
+
+	changes, _, err := r.Since(0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, Change{Cursor: 1, Time: changes[0].Time, Entity: ChangeIngredient, Op: ChangeDelete, ID: 5}, changes[0])
+	assert.Equal(t, ChangeCoffee, changes[1].Entity)
+	assert.Equal(t, 6, changes[1].ID)
+	assert.Len(t, changes[1].Payload.(*entities.Coffee).Ingredients, 1)
+}
+
+func TestChangesExpireBeyondTheRetention(t *testing.T) {
+	ctx := context.Background()
+	r := setupChanges(t, 2)
+
+	for n := 0; n < 4; n++ {
+		require.NoError(t, r.CreateIngredient(ctx, &entities.Ingredient{Name: "Syrup"}))
+	}
+
+	_, _, err := r.Since(1, 10)
+	assert.Equal(t, ErrCursorExpired, err)
+
+	changes, cursor, err := r.Since(0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, uint64(3), changes[0].Cursor)
+	assert.Equal(t, uint64(4), cursor)
+
+	changes, _, err = r.Since(2, 10)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// compareTimeout bounds the read of the secondary backend made to compare it
+// with the primary
+const compareTimeout = 5 * time.Second
+
+// comparator compares reads of a secondary backend with the primary in the
+// background, counting the comparisons and divergences as <prefix>.comparisons
+// and <prefix>.divergences
+type comparator struct {
+	prefix  string
+	logger  hclog.Logger
+	metrics metrics.Sink
+}
+
+// coffees compares the coffees read from the primary backend with the ones
+// read returns
+func (c *comparator) coffees(method string, coffees entities.Coffees, read func(ctx context.Context) (entities.Coffees, error)) {
+	// normalized now, the caller may return the coffees to the pool
+	expected := normalizeCoffees(coffees)
+	c.compare(method, func(ctx context.Context) ([]string, error) {
+		// a coffee missing from the secondary is a divergence, not a failure
+		other, err := read(ctx)
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+		defer entities.PutCoffees(other)
+		return diff(expected, normalizeCoffees(other)), nil
+	})
+}
+
+// ingredients compares the ingredients read from the primary backend with the
+// ones read returns
+func (c *comparator) ingredients(method string, ingredients entities.Ingredients, read func(ctx context.Context) (entities.Ingredients, error)) {
+	expected := normalizeIngredients(ingredients)
+	c.compare(method, func(ctx context.Context) ([]string, error) {
+		other, err := read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return diff(expected, normalizeIngredients(other)), nil
+	})
+}
+
+// compare runs read in the background, logging and counting the differences
+// it finds between the backends
+func (c *comparator) compare(method string, read func(ctx context.Context) ([]string, error)) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), compareTimeout)
+		defer cancel()
+
+		label := metrics.Label{Name: "method", Value: method}
+		c.metrics.IncrCounter(c.prefix+".comparisons", 1, label)
+		differences, err := read(ctx)
+		if err != nil {
+			c.metrics.IncrCounter(c.prefix+".errors", 1, label)
+			c.logger.Warn("Unable to compare backends", "method", method, "error", err)
+			return
+		}
+		if len(differences) > 0 {
+			c.metrics.IncrCounter(c.prefix+".divergences", 1, label)
+			c.logger.Warn("Backends diverge", "method", method, "differences", differences)
+		}
+	}()
+}
+
+// normalizeCoffees describes every coffee by name without the attributes the
+// backends assign themselves, e.g. IDs and timestamps
+func normalizeCoffees(coffees entities.Coffees) map[string]string {
+	normalized := make(map[string]string, len(coffees))
+	for _, c := range coffees {
+		ingredients := make([]string, 0, len(c.Ingredients))
+		for _, i := range c.Ingredients {
+			ingredients = append(ingredients, fmt.Sprintf("%s %d%s", i.Name, i.Quantity, i.Unit))
+		}
+		sort.Strings(ingredients)
+		normalized[c.Name] = fmt.Sprintf("teaser=%q description=%q price=%v image=%q ingredients=[%s]",
+			c.Teaser, c.Description, c.Price, c.Image, strings.Join(ingredients, ", "))
+	}
+	return normalized
+}
+
+// normalizeIngredients describes every ingredient by name
+func normalizeIngredients(ingredients entities.Ingredients) map[string]string {
+	normalized := make(map[string]string, len(ingredients))
+	for _, i := range ingredients {
+		normalized[i.Name] = fmt.Sprintf("%d%s", i.Quantity, i.Unit)
+	}
+	return normalized
+}
+
+// diff describes the differences between the normalized entities of the
+// primary and the secondary backend, sorted by name
+func diff(primary, secondary map[string]string) []string {
+	differences := make([]string, 0)
+	for name, p := range primary {
+		s, ok := secondary[name]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("%q is missing from the secondary", name))
+		case s != p:
+			differences = append(differences, fmt.Sprintf("%q is %s in the primary and %s in the secondary", name, p, s))
+		}
+	}
+	for name := range secondary {
+		if _, ok := primary[name]; !ok {
+			differences = append(differences, fmt.Sprintf("%q is missing from the primary", name))
+		}
+	}
+
+	sort.Strings(differences)
+	return differences
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestDiffDescribesDifferences(t *testing.T) {
+	assert.Equal(t, []string{
+		`"Latte" is missing from the secondary`,
+		`"Mocha" is price=200 in the primary and price=250 in the secondary`,
+		`"Rogue" is missing from the primary`,
+	}, diff(
+		map[string]string{"Latte": "price=100", "Mocha": "price=200"},
+		map[string]string{"Mocha": "price=250", "Rogue": "price=0"},
+	))
+}
+
+func TestNormalizeCoffeesIgnoresIDsAndIngredientOrder(t *testing.T) {
+	a := entities.Coffees{{ID: 1, Name: "Latte", Price: 200, Ingredients: []entities.CoffeeIngredients{
+		{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"},
+		{IngredientID: 2, Name: "Steamed Milk", Quantity: 300, Unit: "ml"},
+	}}}
+	b := entities.Coffees{{ID: 7, Name: "Latte", Price: 200, Ingredients: []entities.CoffeeIngredients{
+		{IngredientID: 9, Name: "Steamed Milk", Quantity: 300, Unit: "ml"},
+		{IngredientID: 8, Name: "Espresso", Quantity: 40, Unit: "ml"},
+	}}}
+
+	assert.Empty(t, diff(normalizeCoffees(a), normalizeCoffees(b)))
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/jackc/pgconn"
+)
+
+// ErrConflict is returned when creating a row whose primary key, or another
+// unique key, is taken by an existing row, like a unique constraint of
+// Postgres
+var ErrConflict = errors.New("a row with this key already exists")
+
+// uniqueIndex is a unique memdb index and the fields of the rows it indexes
+type uniqueIndex struct {
+	name   string
+	fields []string
+	// allowMissing indexes skip the rows whose fields are empty
+	allowMissing bool
+}
+
+// uniqueIndexes are the unique indexes of every in memory table
+var uniqueIndexes = tableUniqueIndexes(createSchema())
+
+// tableUniqueIndexes returns the unique indexes of the tables of schema which
+// index fields, the only kind of index of the generated schema
+func tableUniqueIndexes(schema *memdb.DBSchema) map[TableNameKey][]uniqueIndex {
+	tables := map[TableNameKey][]uniqueIndex{}
+	for name, table := range schema.Tables {
+		for _, index := range table.Indexes {
+			if !index.Unique {
+				continue
+			}
+			if fields := indexedFields(index.Indexer); fields != nil {
+				tables[TableNameKey(name)] = append(tables[TableNameKey(name)], uniqueIndex{name: index.Name, fields: fields, allowMissing: index.AllowMissing})
+			}
+		}
+	}
+	return tables
+}
+
+// indexedFields returns the fields indexed by indexer, in the order of its
+// lookup arguments, or nil for indexers of anything else
+func indexedFields(indexer memdb.Indexer) []string {
+	switch i := indexer.(type) {
+	case *memdb.IntFieldIndex:
+		return []string{i.Field}
+	case *memdb.StringFieldIndex:
+		return []string{i.Field}
+	case *memdb.CompoundIndex:
+		var fields []string
+		for _, part := range i.Indexes {
+			partFields := indexedFields(part)
+			if partFields == nil {
+				return nil
+			}
+			fields = append(fields, partFields...)
+		}
+		return fields
+	}
+	return nil
+}
+
+// conflict returns ErrConflict when a row of table within txn has one of the
+// unique keys of row
+func conflict(txn *memdb.Txn, table TableNameKey, row interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(row))
+	for _, index := range uniqueIndexes[table] {
+		args := make([]interface{}, 0, len(index.fields))
+		missing := false
+		for _, field := range index.fields {
+			arg := value.FieldByName(field)
+			missing = missing || arg.IsZero()
+			args = append(args, arg.Interface())
+		}
+		if missing && index.allowMissing {
+			continue
+		}
+
+		existing, err := txn.First(table.String(), index.name, args...)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("%w: %s %s %v", ErrConflict, table, index.name, args)
+		}
+	}
+	return nil
+}
+
+// uniqueViolation returns ErrConflict for a unique_violation of Postgres, and
+// err otherwise
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%w: %s %s", ErrConflict, pgErr.TableName, pgErr.ConstraintName)
+	}
+	return err
+}
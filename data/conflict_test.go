@@ -0,0 +1,19 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueViolationsConflict(t *testing.T) {
+	err := uniqueViolation(&pgconn.PgError{Code: "23505", TableName: "coffee", ConstraintName: "coffee_slug"})
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.Contains(t, err.Error(), "coffee coffee_slug")
+
+	other := &pgconn.PgError{Code: "23503"}
+	assert.Equal(t, error(other), uniqueViolation(other))
+	assert.NoError(t, uniqueViolation(nil))
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// seededIngredients are the ingredient IDs of every coffee in the seed data
+// shared by NewInMemoryDB and the Postgres migrations
+var seededIngredients = map[int][]int{
+	1: {1, 2, 4},
+	2: {1, 2},
+	3: {1, 3},
+	4: {1},
+	5: {1},
+	6: {1, 5},
+}
+
+// testRepositoryConformance verifies a Repository holding the seed data
+// behaves the same as every other implementation
+func testRepositoryConformance(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	t.Run("IsConnected", func(t *testing.T) {
+		ok, err := r.IsConnected(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		coffees, err := r.Find(ctx)
+		require.NoError(t, err)
+		require.Len(t, coffees, len(seededIngredients))
+
+		for _, coffee := range coffees {
+			assert.Equal(t, seededIngredients[coffee.ID], ingredientIDs(coffee), coffee.Name)
+			assert.NotContains(t, ingredientNames(coffee), "", coffee.Name)
+		}
+	})
+
+	t.Run("FindByID", func(t *testing.T) {
+		coffee, err := r.FindByID(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "Vaulatte", coffee.Name)
+		assert.Equal(t, 200.0, coffee.Price)
+		assert.Equal(t, seededIngredients[2], ingredientIDs(*coffee))
+		assert.ElementsMatch(t, []string{"Espresso'", "Semi Skimmed Milk"}, ingredientNames(*coffee))
+		assert.Equal(t, 40, coffee.Ingredients[0].Quantity)
+		assert.Equal(t, "ml", coffee.Ingredients[0].Unit)
+
+		_, err = r.FindByID(ctx, 42)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("FindRelated", func(t *testing.T) {
+		coffees, err := r.FindRelated(ctx, 1, 3)
+		require.NoError(t, err)
+		require.Len(t, coffees, 3)
+		assert.Equal(t, []int{2, 4, 5}, []int{coffees[0].ID, coffees[1].ID, coffees[2].ID})
+		assert.Equal(t, seededIngredients[2], ingredientIDs(coffees[0]))
+
+		_, err = r.FindRelated(ctx, 42, 3)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("FindWhere", func(t *testing.T) {
+		expr, err := filter.Parse(`price<=200 AND (name~"ESPRESSO" OR teaser~"safe")`)
+		require.NoError(t, err)
+
+		coffees, err := r.FindWhere(ctx, expr)
+		require.NoError(t, err)
+
+		ids := make([]int, 0, len(coffees))
+		for _, coffee := range coffees {
+			ids = append(ids, coffee.ID)
+			assert.Equal(t, seededIngredients[coffee.ID], ingredientIDs(coffee), coffee.Name)
+		}
+		sort.Ints(ids)
+		assert.Equal(t, []int{2, 5}, ids)
+	})
+
+	// writes run last and remove everything they create
+	t.Run("Writes", func(t *testing.T) {
+		oat := &entities.Ingredient{Name: "Oat Milk", Quantity: 50, Unit: "ml"}
+		require.NoError(t, r.CreateIngredient(ctx, oat))
+		assert.Greater(t, oat.ID, 5)
+		assert.NotEmpty(t, oat.CreatedAt)
+
+		coffee := &entities.Coffee{
+			Name:   "Oatlatte",
+			Teaser: "Plant powered",
+			Price:  300,
+			Image:  "/oat.png",
+			Ingredients: []entities.CoffeeIngredients{
+				{IngredientID: 1, Quantity: 40, Unit: "ml"},
+				{IngredientID: oat.ID, Quantity: 250, Unit: "ml"},
+			},
+		}
+		require.NoError(t, r.CreateCoffee(ctx, coffee))
+		assert.Greater(t, coffee.ID, 6)
+		assert.NotEmpty(t, coffee.CreatedAt)
+
+		stored, err := r.FindByID(ctx, coffee.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Oatlatte", stored.Name)
+		assert.Equal(t, []int{1, oat.ID}, ingredientIDs(*stored))
+		assert.ElementsMatch(t, []string{"Espresso'", "Oat Milk"}, ingredientNames(*coffee))
+		assert.Equal(t, 250, stored.Ingredients[1].Quantity)
+
+		coffee.Price = 320
+		coffee.Ingredients = []entities.CoffeeIngredients{{IngredientID: oat.ID}}
+		require.NoError(t, r.UpdateCoffee(ctx, coffee))
+
+		stored, err = r.FindByID(ctx, coffee.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 320.0, stored.Price)
+		assert.Equal(t, []int{oat.ID}, ingredientIDs(*stored))
+
+		oat.Quantity = 60
+		require.NoError(t, r.UpdateIngredient(ctx, oat))
+		assert.Equal(t, ErrNotFound, r.UpdateIngredient(ctx, &entities.Ingredient{ID: 4242, Name: "Missing"}))
+		assert.Equal(t, ErrNotFound, r.UpdateCoffee(ctx, &entities.Coffee{ID: 4242, Name: "Missing", Price: 200}))
+
+		// the invariants of the entities hold on every write
+		assert.IsType(t, &entities.ValidationError{}, r.CreateCoffee(ctx, &entities.Coffee{Name: "Free Refill"}))
+		coffee.Price = 0
+		assert.IsType(t, &entities.ValidationError{}, r.UpdateCoffee(ctx, coffee))
+		assert.IsType(t, &entities.ValidationError{}, r.CreateIngredient(ctx, &entities.Ingredient{Name: " "}))
+		oat.Quantity = -60
+		assert.IsType(t, &entities.ValidationError{}, r.UpdateIngredient(ctx, oat))
+
+		require.NoError(t, r.DeleteIngredient(ctx, oat.ID))
+		stored, err = r.FindByID(ctx, coffee.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Ingredients)
+
+		require.NoError(t, r.DeleteCoffee(ctx, coffee.ID))
+		_, err = r.FindByID(ctx, coffee.ID)
+		assert.Equal(t, ErrNotFound, err)
+		assert.Equal(t, ErrNotFound, r.DeleteCoffee(ctx, coffee.ID))
+		assert.Equal(t, ErrNotFound, r.DeleteIngredient(ctx, oat.ID))
+
+		ingredients, err := r.FindIngredients(ctx)
+		require.NoError(t, err)
+		assert.Len(t, ingredients, 5)
+
+		// IDs are never reused
+		next := &entities.Coffee{Name: "Oatlatte", Teaser: "Plant powered", Price: 300, Image: "/oat.png"}
+		require.NoError(t, r.CreateCoffee(ctx, next))
+		assert.Greater(t, next.ID, coffee.ID)
+		require.NoError(t, r.DeleteCoffee(ctx, next.ID))
+	})
+}
+
+// ingredientIDs returns the sorted ingredient IDs of a coffee
+func ingredientIDs(coffee entities.Coffee) []int {
+	ids := make([]int, 0, len(coffee.Ingredients))
+	for _, ingredient := range coffee.Ingredients {
+		ids = append(ids, ingredient.IngredientID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// ingredientNames returns the ingredient names of a coffee
+func ingredientNames(coffee entities.Coffee) []string {
+	names := make([]string, 0, len(coffee.Ingredients))
+	for _, ingredient := range coffee.Ingredients {
+		names = append(names, ingredient.Name)
+	}
+	return names
+}
+
+func TestInMemoryRepositoryConformance(t *testing.T) {
+	testRepositoryConformance(t, setupInMemoryRepository(t))
+}
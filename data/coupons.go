@@ -0,0 +1,170 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// couponCode is the format of a coupon code, once upper cased
+var couponCode = regexp.MustCompile(`^[A-Z0-9_-]{1,32}$`)
+
+var (
+	// ErrCouponsUnsupported is returned when managing the coupons of a
+	// repository which does not store them
+	ErrCouponsUnsupported = errors.New("coupons are not supported by this backend")
+	// ErrInvalidCoupon is returned for a coupon with a malformed code, an
+	// unknown type or a value out of range
+	ErrInvalidCoupon = errors.New("invalid coupon")
+	// ErrCouponExists is returned when creating a coupon with the code of
+	// another
+	ErrCouponExists = errors.New("a coupon with this code already exists")
+	// ErrCouponExpired is returned when redeeming an expired coupon
+	ErrCouponExpired = errors.New("coupon has expired")
+	// ErrCouponExhausted is returned when redeeming a coupon which reached its
+	// usage limit
+	ErrCouponExhausted = errors.New("coupon has reached its usage limit")
+)
+
+// CouponStore is implemented by repositories storing discount coupons, keyed
+// by their code
+type CouponStore interface {
+	// FindCoupons returns every coupon
+	FindCoupons(ctx context.Context) (entities.Coupons, error)
+	// FindCoupon returns a coupon, or ErrNotFound
+	FindCoupon(ctx context.Context, code string) (*entities.Coupon, error)
+	// CreateCoupon inserts a coupon, ErrCouponExists when its code is taken
+	CreateCoupon(ctx context.Context, coupon *entities.Coupon) error
+	// UpdateCoupon replaces the type, value, expiry and usage limit of a
+	// coupon keeping its uses, ErrNotFound when it does not exist
+	UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error
+	// DeleteCoupon removes a coupon, ErrNotFound when it does not exist
+	DeleteCoupon(ctx context.Context, code string) error
+	// RedeemCoupon counts a use of a coupon which has neither expired at t
+	// nor reached its usage limit, checking and counting atomically so that
+	// concurrent orders never redeem it beyond its limit. It returns the
+	// redeemed coupon, ErrNotFound, ErrCouponExpired or ErrCouponExhausted.
+	RedeemCoupon(ctx context.Context, code string, t time.Time) (*entities.Coupon, error)
+	// ReleaseCoupon gives back a use of a coupon whose order failed
+	ReleaseCoupon(ctx context.Context, code string) error
+}
+
+// FindCoupons returns the coupons of a CouponStore, or ErrCouponsUnsupported
+func FindCoupons(ctx context.Context, r Repository) (entities.Coupons, error) {
+	var store interface {
+		FindCoupons(ctx context.Context) (entities.Coupons, error)
+	}
+	if !As(r, &store) {
+		return nil, ErrCouponsUnsupported
+	}
+	return store.FindCoupons(ctx)
+}
+
+// FindCoupon returns a coupon of a CouponStore, codes are matched ignoring
+// case
+func FindCoupon(ctx context.Context, r Repository, code string) (*entities.Coupon, error) {
+	var store interface {
+		FindCoupon(ctx context.Context, code string) (*entities.Coupon, error)
+	}
+	if !As(r, &store) {
+		return nil, ErrCouponsUnsupported
+	}
+	return store.FindCoupon(ctx, strings.ToUpper(code))
+}
+
+// CreateCoupon validates a coupon and inserts it in a CouponStore, unused.
+// Its code is upper cased first.
+func CreateCoupon(ctx context.Context, r Repository, coupon *entities.Coupon) error {
+	var store interface {
+		CreateCoupon(ctx context.Context, coupon *entities.Coupon) error
+	}
+	if !As(r, &store) {
+		return ErrCouponsUnsupported
+	}
+	if err := validCoupon(coupon); err != nil {
+		return err
+	}
+	coupon.Used = 0
+	return store.CreateCoupon(ctx, coupon)
+}
+
+// UpdateCoupon validates a coupon and replaces it in a CouponStore
+func UpdateCoupon(ctx context.Context, r Repository, coupon *entities.Coupon) error {
+	var store interface {
+		UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error
+	}
+	if !As(r, &store) {
+		return ErrCouponsUnsupported
+	}
+	if err := validCoupon(coupon); err != nil {
+		return err
+	}
+	return store.UpdateCoupon(ctx, coupon)
+}
+
+// DeleteCoupon removes a coupon from a CouponStore
+func DeleteCoupon(ctx context.Context, r Repository, code string) error {
+	var store interface {
+		DeleteCoupon(ctx context.Context, code string) error
+	}
+	if !As(r, &store) {
+		return ErrCouponsUnsupported
+	}
+	return store.DeleteCoupon(ctx, strings.ToUpper(code))
+}
+
+// RedeemCoupon counts a use of a coupon of a CouponStore redeemed at t
+func RedeemCoupon(ctx context.Context, r Repository, code string, t time.Time) (*entities.Coupon, error) {
+	var store interface {
+		RedeemCoupon(ctx context.Context, code string, t time.Time) (*entities.Coupon, error)
+	}
+	if !As(r, &store) {
+		return nil, ErrCouponsUnsupported
+	}
+	return store.RedeemCoupon(ctx, strings.ToUpper(code), t)
+}
+
+// ReleaseCoupon gives back a use of a coupon of a CouponStore
+func ReleaseCoupon(ctx context.Context, r Repository, code string) error {
+	var store interface {
+		ReleaseCoupon(ctx context.Context, code string) error
+	}
+	if !As(r, &store) {
+		return ErrCouponsUnsupported
+	}
+	return store.ReleaseCoupon(ctx, strings.ToUpper(code))
+}
+
+// validCoupon checks a coupon, upper casing its code and lower casing its type
+func validCoupon(coupon *entities.Coupon) error {
+	coupon.Code = strings.ToUpper(coupon.Code)
+	coupon.Type = strings.ToLower(coupon.Type)
+	if !couponCode.MatchString(coupon.Code) || coupon.Value <= 0 || coupon.UsageLimit < 0 {
+		return ErrInvalidCoupon
+	}
+	switch coupon.Type {
+	case entities.CouponPercent:
+		if coupon.Value > 100 {
+			return ErrInvalidCoupon
+		}
+	case entities.CouponFixed:
+	default:
+		return ErrInvalidCoupon
+	}
+	return nil
+}
+
+// redeemable returns why a coupon can not be redeemed at t, nil when it can
+func redeemable(coupon *entities.Coupon, t time.Time) error {
+	if coupon.Expired(t) {
+		return ErrCouponExpired
+	}
+	if coupon.Exhausted() {
+		return ErrCouponExhausted
+	}
+	return nil
+}
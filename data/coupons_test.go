@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testCoupons verifies a Repository holding the seed data manages and
+// redeems coupons
+func testCoupons(t *testing.T, r Repository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	coupons, err := FindCoupons(ctx, r)
+	require.NoError(t, err)
+	require.Len(t, coupons, 1)
+	assert.Equal(t, "WELCOME10", coupons[0].Code)
+	assert.Equal(t, entities.CouponPercent, coupons[0].Type)
+	assert.Equal(t, 10.0, coupons[0].Value)
+	assert.Nil(t, coupons[0].ExpiresAt)
+
+	// codes are upper cased and types lower cased
+	coupon := &entities.Coupon{Code: "spring-5", Type: "Fixed", Value: 5, UsageLimit: 2, Used: 7}
+	require.NoError(t, CreateCoupon(ctx, r, coupon))
+	assert.Equal(t, "SPRING-5", coupon.Code)
+	assert.Equal(t, entities.CouponFixed, coupon.Type)
+	assert.Equal(t, 0, coupon.Used)
+	assert.Equal(t, ErrCouponExists, CreateCoupon(ctx, r, &entities.Coupon{Code: "Spring-5", Type: "fixed", Value: 1}))
+	for _, invalid := range []entities.Coupon{
+		{Code: "", Type: "fixed", Value: 5},
+		{Code: "TWO WORDS", Type: "fixed", Value: 5},
+		{Code: "FREE", Type: "gift", Value: 5},
+		{Code: "FREE", Type: "percent", Value: 101},
+		{Code: "FREE", Type: "fixed", Value: 0},
+		{Code: "FREE", Type: "fixed", Value: 5, UsageLimit: -1},
+	} {
+		invalid := invalid
+		assert.Equal(t, ErrInvalidCoupon, CreateCoupon(ctx, r, &invalid), invalid)
+	}
+
+	redeemed, err := RedeemCoupon(ctx, r, "spring-5", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, redeemed.Used)
+	_, err = RedeemCoupon(ctx, r, "SPRING-5", now)
+	require.NoError(t, err)
+	_, err = RedeemCoupon(ctx, r, "SPRING-5", now)
+	assert.Equal(t, ErrCouponExhausted, err)
+	_, err = RedeemCoupon(ctx, r, "NOPE", now)
+	assert.Equal(t, ErrNotFound, err)
+
+	// a released use can be redeemed again
+	require.NoError(t, ReleaseCoupon(ctx, r, "SPRING-5"))
+	_, err = RedeemCoupon(ctx, r, "SPRING-5", now)
+	require.NoError(t, err)
+
+	// an update keeps the uses
+	expiresAt := now.Add(time.Hour).UTC().Truncate(time.Second)
+	coupon = &entities.Coupon{Code: "SPRING-5", Type: "percent", Value: 15, UsageLimit: 3, ExpiresAt: &expiresAt}
+	require.NoError(t, UpdateCoupon(ctx, r, coupon))
+	assert.Equal(t, 2, coupon.Used)
+	found, err := FindCoupon(ctx, r, "spring-5")
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, found.Value)
+	assert.True(t, expiresAt.Equal(*found.ExpiresAt))
+	assert.Equal(t, ErrNotFound, UpdateCoupon(ctx, r, &entities.Coupon{Code: "NOPE", Type: "fixed", Value: 1}))
+
+	_, err = RedeemCoupon(ctx, r, "SPRING-5", expiresAt)
+	assert.Equal(t, ErrCouponExpired, err)
+	_, err = RedeemCoupon(ctx, r, "SPRING-5", expiresAt.Add(-time.Second))
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteCoupon(ctx, r, "spring-5"))
+	_, err = FindCoupon(ctx, r, "SPRING-5")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, DeleteCoupon(ctx, r, "SPRING-5"))
+	assert.Equal(t, ErrNotFound, ReleaseCoupon(ctx, r, "SPRING-5"))
+}
+
+// testCouponsAreRedeemedAtomically verifies concurrent redemptions of a
+// coupon never exceed its usage limit
+func testCouponsAreRedeemedAtomically(t *testing.T, r Repository) {
+	ctx := context.Background()
+	require.NoError(t, CreateCoupon(ctx, r, &entities.Coupon{Code: "RUSH", Type: "fixed", Value: 1, UsageLimit: 5}))
+
+	var mu sync.Mutex
+	redeemed, exhausted := 0, 0
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := RedeemCoupon(ctx, r, "RUSH", time.Now())
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				redeemed++
+			case ErrCouponExhausted:
+				exhausted++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, redeemed)
+	assert.Equal(t, 15, exhausted)
+	coupon, err := FindCoupon(ctx, r, "RUSH")
+	require.NoError(t, err)
+	assert.Equal(t, 5, coupon.Used)
+}
+
+func TestInMemoryCoupons(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testCoupons(t, r)
+}
+
+func TestInMemoryCouponsAreRedeemedAtomically(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testCouponsAreRedeemedAtomically(t, r)
+}
+
+func TestInMemoryCouponsAreCopies(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, CreateCoupon(context.Background(), r, &entities.Coupon{Code: "LATER", Type: "fixed", Value: 1, ExpiresAt: &expiresAt}))
+	coupon, err := FindCoupon(context.Background(), r, "LATER")
+	require.NoError(t, err)
+	*coupon.ExpiresAt = time.Time{}
+
+	coupon, err = FindCoupon(context.Background(), r, "LATER")
+	require.NoError(t, err)
+	assert.True(t, expiresAt.Equal(*coupon.ExpiresAt))
+}
+
+func TestCouponsPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testCoupons(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+}
+
+func TestCouponsUnsupported(t *testing.T) {
+	_, err := FindCoupons(context.Background(), &MockRepository{})
+	assert.Equal(t, ErrCouponsUnsupported, err)
+	_, err = RedeemCoupon(context.Background(), &MockRepository{}, "WELCOME10", time.Now())
+	assert.Equal(t, ErrCouponsUnsupported, err)
+	assert.Equal(t, ErrCouponsUnsupported, CreateCoupon(context.Background(), &MockRepository{}, &entities.Coupon{}))
+}
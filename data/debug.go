@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type debugTraceKey struct{}
+
+// DebugTrace collects the details of how a single request was served, the
+// repository queries it made and the caches it looked up, to report them to
+// the client. It is safe for concurrent use.
+type DebugTrace struct {
+	mu      sync.Mutex
+	start   time.Time
+	queries []DebugQuery
+	caches  []DebugCacheLookup
+}
+
+// DebugQuery is a repository query made while serving a request, started
+// StartMs after the request. Arguments are sanitized like in the slow query
+// log.
+type DebugQuery struct {
+	Query      string   `json:"query"`
+	Args       []string `json:"args"`
+	StartMs    float64  `json:"start_ms"`
+	DurationMs float64  `json:"duration_ms"`
+}
+
+// DebugCacheLookup is the result of a cache lookup, e.g. hit or miss
+type DebugCacheLookup struct {
+	Cache  string `json:"cache"`
+	Key    string `json:"key,omitempty"`
+	Result string `json:"result"`
+}
+
+// DebugReport is what a DebugTrace collected
+type DebugReport struct {
+	DurationMs      float64            `json:"duration_ms"`
+	QueryCount      int                `json:"query_count"`
+	QueryDurationMs float64            `json:"query_duration_ms"`
+	Queries         []DebugQuery       `json:"queries"`
+	Caches          []DebugCacheLookup `json:"caches"`
+}
+
+// WithDebugTrace returns a context whose queries and cache lookups are
+// collected in a new DebugTrace, timed from now
+func WithDebugTrace(ctx context.Context) (context.Context, *DebugTrace) {
+	trace := &DebugTrace{start: time.Now(), queries: []DebugQuery{}, caches: []DebugCacheLookup{}}
+	return context.WithValue(ctx, debugTraceKey{}, trace), trace
+}
+
+// Report returns what the trace collected so far
+func (t *DebugTrace) Report() DebugReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := DebugReport{
+		DurationMs: milliseconds(time.Since(t.start)),
+		QueryCount: len(t.queries),
+		Queries:    append([]DebugQuery{}, t.queries...),
+		Caches:     append([]DebugCacheLookup{}, t.caches...),
+	}
+	for _, q := range t.queries {
+		report.QueryDurationMs += q.DurationMs
+	}
+	return report
+}
+
+// RecordCacheLookup records the result of looking key up in cache in the
+// debug trace of ctx, if any
+func RecordCacheLookup(ctx context.Context, cache, key, result string) {
+	trace, ok := ctx.Value(debugTraceKey{}).(*DebugTrace)
+	if !ok {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.caches = append(trace.caches, DebugCacheLookup{Cache: cache, Key: key, Result: result})
+}
+
+// recordDebugQuery records a query started at start which took elapsed in the
+// debug trace of ctx, if any
+func recordDebugQuery(ctx context.Context, start time.Time, elapsed time.Duration, query string, args []interface{}) {
+	trace, ok := ctx.Value(debugTraceKey{}).(*DebugTrace)
+	if !ok {
+		return
+	}
+
+	sanitized := make([]string, len(args))
+	for n, arg := range args {
+		sanitized[n] = sanitizeArg(arg)
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.queries = append(trace.queries, DebugQuery{
+		Query:      strings.Join(strings.Fields(query), " "),
+		Args:       sanitized,
+		StartMs:    milliseconds(start.Sub(trace.start)),
+		DurationMs: milliseconds(elapsed),
+	})
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugTraceCollectsQueriesAndCacheLookups(t *testing.T) {
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	ctx, trace := WithDebugTrace(context.Background())
+	_, err = r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	RecordCacheLookup(ctx, "response", "/coffees/1", "miss")
+
+	report := trace.Report()
+	require.NotEmpty(t, report.Queries)
+	assert.Equal(t, len(report.Queries), report.QueryCount)
+	assert.Equal(t, "first coffee by id", report.Queries[0].Query)
+	assert.Equal(t, []string{"1"}, report.Queries[0].Args)
+	assert.GreaterOrEqual(t, report.DurationMs, report.QueryDurationMs)
+	assert.Equal(t, []DebugCacheLookup{{Cache: "response", Key: "/coffees/1", Result: "miss"}}, report.Caches)
+
+	// without a trace nothing is collected
+	_, err = r.FindByID(context.Background(), 1)
+	require.NoError(t, err)
+	RecordCacheLookup(context.Background(), "response", "/coffees/1", "hit")
+	assert.Len(t, trace.Report().Queries, report.QueryCount)
+	assert.Len(t, trace.Report().Caches, 1)
+}
@@ -1,14 +1,10 @@
 package entities
 
 import (
-	"database/sql"
 	"encoding/json"
 	"io"
 )
 
-// Coffees is a list of Coffee
-type Coffees []Coffee
-
 // FromJSON serializes data from json
 func (c *Coffees) FromJSON(data io.Reader) error {
 	de := json.NewDecoder(data)
@@ -20,20 +16,6 @@ func (c *Coffees) ToJSON() ([]byte, error) {
 	return json.Marshal(c)
 }
 
-// Coffee defines a coffee in the database
-type Coffee struct {
-	ID          int                 `db:"id" json:"id"`
-	Name        string              `db:"name" json:"name"`
-	Teaser      string              `db:"teaser" json:"teaser"`
-	Description string              `db:"description" json:"description"`
-	Price       float64             `db:"price" json:"price"`
-	Image       string              `db:"image" json:"image"`
-	CreatedAt   string              `db:"created_at" json:"-"`
-	UpdatedAt   string              `db:"updated_at" json:"-"`
-	DeletedAt   sql.NullString      `db:"deleted_at" json:"-"`
-	Ingredients []CoffeeIngredients `json:"ingredients"`
-}
-
 func (c *Coffee) FromJSON(data io.Reader) error {
 	de := json.NewDecoder(data)
 	return de.Decode(c)
@@ -44,11 +26,26 @@ func (c *Coffee) ToJSON() ([]byte, error) {
 	return json.Marshal(c)
 }
 
-type CoffeeIngredients struct {
-	ID           int            `db:"id" json:"-"`
-	CoffeeID     int            `db:"coffee_id" json:"-"`
-	IngredientID int            `db:"ingredient_id" json:"ingredient_id"`
-	CreatedAt    string         `db:"created_at" json:"-"`
-	UpdatedAt    string         `db:"updated_at" json:"-"`
-	DeletedAt    sql.NullString `db:"deleted_at" json:"-"`
+// Validate checks the invariants of a coffee: a name, a positive price, an
+// image which is a path or a URL, and ingredients with a positive ID and no
+// negative quantity
+func (c *Coffee) Validate() error {
+	if !validName(c.Name) {
+		return &ValidationError{Entity: "coffee", Field: "name", Reason: "is required"}
+	}
+	if !validPrice(c.Price) {
+		return &ValidationError{Entity: "coffee", Field: "price", Reason: "must be positive"}
+	}
+	if !validImage(c.Image) {
+		return &ValidationError{Entity: "coffee", Field: "image", Reason: "must be a path such as /packer.png or an http URL"}
+	}
+	for _, i := range c.Ingredients {
+		if i.IngredientID <= 0 {
+			return &ValidationError{Entity: "coffee", Field: "ingredient_id", Reason: "must be positive"}
+		}
+		if i.Quantity < 0 {
+			return &ValidationError{Entity: "coffee", Field: "quantity", Reason: "must not be negative"}
+		}
+	}
+	return nil
 }
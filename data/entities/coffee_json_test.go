@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendJSONMatchesEncodingJSON(t *testing.T) {
+	c := Coffees{
+		Coffee{
+			ID:          1,
+			Name:        "Packer <Spiced> Latte & \"friends\"",
+			Teaser:      "Tabs\tnewlines\n, controls \x01\x1f and \b\f",
+			Description: "Unicode \u2615, separators \u2028 \u2029 and invalid \xff bytes",
+			Price:       350.5,
+			Image:       "/packer.png",
+			Ingredients: []CoffeeIngredients{{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}, {IngredientID: 4}},
+			Stats:       &CoffeeStats{Views: 3, Orders: 1, Score: 0.0000001},
+		},
+		Coffee{ID: 2, Name: "Vaulatte", Price: 1e21, Ingredients: []CoffeeIngredients{}},
+		Coffee{ID: 3, Price: -0.000002, Stats: &CoffeeStats{Score: 12345678.9}},
+	}
+
+	want, err := json.Marshal(&c)
+	require.NoError(t, err)
+
+	got, err := c.AppendJSON(nil)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestAppendJSONEncodesNilCollection(t *testing.T) {
+	var c Coffees
+
+	got, err := c.AppendJSON(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(got))
+}
+
+func TestAppendJSONRejectsNaN(t *testing.T) {
+	c := Coffees{Coffee{Price: math.NaN()}}
+
+	_, err := c.AppendJSON(nil)
+
+	assert.Error(t, err)
+}
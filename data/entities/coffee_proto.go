@@ -0,0 +1,262 @@
+package entities
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the messages defined in proto/coffee.proto
+const (
+	protoCoffeesCoffees protowire.Number = 1
+
+	protoCoffeeID          protowire.Number = 1
+	protoCoffeeName        protowire.Number = 2
+	protoCoffeeTeaser      protowire.Number = 3
+	protoCoffeeDescription protowire.Number = 4
+	protoCoffeePrice       protowire.Number = 5
+	protoCoffeeImage       protowire.Number = 6
+	protoCoffeeIngredients protowire.Number = 7
+	protoCoffeeSlug        protowire.Number = 8
+	protoCoffeeStatus      protowire.Number = 9
+	protoCoffeeImages      protowire.Number = 10
+
+	protoIngredientIngredientID protowire.Number = 1
+	protoIngredientName         protowire.Number = 2
+	protoIngredientQuantity     protowire.Number = 3
+	protoIngredientUnit         protowire.Number = 4
+
+	protoImageSetSrcset protowire.Number = 1
+	protoImageSetThumb  protowire.Number = 2
+	protoImageSetMedium protowire.Number = 3
+	protoImageSetFull   protowire.Number = 4
+)
+
+// ToProto converts the collection to the protobuf Coffees message
+func (c *Coffees) ToProto() ([]byte, error) {
+	b := make([]byte, 0)
+	var msg []byte
+	for _, coffee := range *c {
+		msg = coffee.appendProto(msg[:0])
+		b = protowire.AppendTag(b, protoCoffeesCoffees, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	return b, nil
+}
+
+// FromProto deserializes the protobuf Coffees message
+func (c *Coffees) FromProto(b []byte) error {
+	coffees := Coffees{}
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) (int, error) {
+		if num != protoCoffeesCoffees || typ != protowire.BytesType {
+			return skipField(num, typ, v)
+		}
+
+		msg, n := protowire.ConsumeBytes(v)
+		if n < 0 {
+			return n, protowire.ParseError(n)
+		}
+
+		coffee := Coffee{}
+		if err := coffee.FromProto(msg); err != nil {
+			return n, err
+		}
+		coffees = append(coffees, coffee)
+		return n, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*c = coffees
+	return nil
+}
+
+// ToProto converts the coffee to the protobuf Coffee message
+func (c *Coffee) ToProto() ([]byte, error) {
+	return c.appendProto(nil), nil
+}
+
+// FromProto deserializes the protobuf Coffee message
+func (c *Coffee) FromProto(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) (int, error) {
+		switch {
+		case num == protoCoffeeID && typ == protowire.VarintType:
+			id, n := protowire.ConsumeVarint(v)
+			c.ID = int(id)
+			return n, nil
+		case num == protoCoffeeName && typ == protowire.BytesType:
+			return consumeString(v, &c.Name)
+		case num == protoCoffeeTeaser && typ == protowire.BytesType:
+			return consumeString(v, &c.Teaser)
+		case num == protoCoffeeDescription && typ == protowire.BytesType:
+			return consumeString(v, &c.Description)
+		case num == protoCoffeePrice && typ == protowire.Fixed64Type:
+			price, n := protowire.ConsumeFixed64(v)
+			c.Price = math.Float64frombits(price)
+			return n, nil
+		case num == protoCoffeeImage && typ == protowire.BytesType:
+			return consumeString(v, &c.Image)
+		case num == protoCoffeeSlug && typ == protowire.BytesType:
+			return consumeString(v, &c.Slug)
+		case num == protoCoffeeStatus && typ == protowire.BytesType:
+			return consumeString(v, &c.Status)
+		case num == protoCoffeeIngredients && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(v)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+
+			ingredient := CoffeeIngredients{}
+			err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, v []byte) (int, error) {
+				switch {
+				case num == protoIngredientIngredientID && typ == protowire.VarintType:
+					id, n := protowire.ConsumeVarint(v)
+					ingredient.IngredientID = int(id)
+					return n, nil
+				case num == protoIngredientName && typ == protowire.BytesType:
+					return consumeString(v, &ingredient.Name)
+				case num == protoIngredientQuantity && typ == protowire.VarintType:
+					quantity, n := protowire.ConsumeVarint(v)
+					ingredient.Quantity = int(quantity)
+					return n, nil
+				case num == protoIngredientUnit && typ == protowire.BytesType:
+					return consumeString(v, &ingredient.Unit)
+				}
+				return skipField(num, typ, v)
+			})
+			c.Ingredients = append(c.Ingredients, ingredient)
+			return n, err
+		case num == protoCoffeeImages && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(v)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+
+			images := &CoffeeImageSet{}
+			err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, v []byte) (int, error) {
+				switch {
+				case num == protoImageSetSrcset && typ == protowire.BytesType:
+					return consumeString(v, &images.Srcset)
+				case num == protoImageSetThumb && typ == protowire.BytesType:
+					return consumeString(v, &images.Thumb)
+				case num == protoImageSetMedium && typ == protowire.BytesType:
+					return consumeString(v, &images.Medium)
+				case num == protoImageSetFull && typ == protowire.BytesType:
+					return consumeString(v, &images.Full)
+				}
+				return skipField(num, typ, v)
+			})
+			c.Images = images
+			return n, err
+		}
+
+		return skipField(num, typ, v)
+	})
+}
+
+// appendProto appends the protobuf encoding of the coffee to b, omitting
+// zero values as proto3 does
+func (c *Coffee) appendProto(b []byte) []byte {
+	if c.ID != 0 {
+		b = protowire.AppendTag(b, protoCoffeeID, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(c.ID))
+	}
+	b = appendString(b, protoCoffeeName, c.Name)
+	b = appendString(b, protoCoffeeTeaser, c.Teaser)
+	b = appendString(b, protoCoffeeDescription, c.Description)
+	if c.Price != 0 {
+		b = protowire.AppendTag(b, protoCoffeePrice, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(c.Price))
+	}
+	b = appendString(b, protoCoffeeImage, c.Image)
+	for _, ingredient := range c.Ingredients {
+		// the nested message is small and its size cheap to compute, write
+		// it in place rather than through a scratch buffer
+		size := 0
+		if ingredient.IngredientID != 0 {
+			size += protowire.SizeTag(protoIngredientIngredientID) + protowire.SizeVarint(uint64(ingredient.IngredientID))
+		}
+		if ingredient.Name != "" {
+			size += protowire.SizeTag(protoIngredientName) + protowire.SizeBytes(len(ingredient.Name))
+		}
+		if ingredient.Quantity != 0 {
+			size += protowire.SizeTag(protoIngredientQuantity) + protowire.SizeVarint(uint64(ingredient.Quantity))
+		}
+		if ingredient.Unit != "" {
+			size += protowire.SizeTag(protoIngredientUnit) + protowire.SizeBytes(len(ingredient.Unit))
+		}
+		b = protowire.AppendTag(b, protoCoffeeIngredients, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(size))
+		if ingredient.IngredientID != 0 {
+			b = protowire.AppendTag(b, protoIngredientIngredientID, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(ingredient.IngredientID))
+		}
+		b = appendString(b, protoIngredientName, ingredient.Name)
+		if ingredient.Quantity != 0 {
+			b = protowire.AppendTag(b, protoIngredientQuantity, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(ingredient.Quantity))
+		}
+		b = appendString(b, protoIngredientUnit, ingredient.Unit)
+	}
+	b = appendString(b, protoCoffeeSlug, c.Slug)
+	b = appendString(b, protoCoffeeStatus, c.Status)
+	if c.Images != nil {
+		var msg []byte
+		msg = appendString(msg, protoImageSetSrcset, c.Images.Srcset)
+		msg = appendString(msg, protoImageSetThumb, c.Images.Thumb)
+		msg = appendString(msg, protoImageSetMedium, c.Images.Medium)
+		msg = appendString(msg, protoImageSetFull, c.Images.Full)
+		b = protowire.AppendTag(b, protoCoffeeImages, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func consumeString(b []byte, v *string) (int, error) {
+	s, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	*v = s
+	return n, nil
+}
+
+func skipField(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	return n, nil
+}
+
+// consumeFields walks the fields of a message calling fn with the bytes
+// following each tag. fn returns how many of those bytes it consumed.
+func consumeFields(b []byte, fn func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := fn(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("invalid protobuf field")
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoffeesRoundTripsProto(t *testing.T) {
+	c := Coffees{
+		Coffee{
+			ID:          1,
+			Name:        "Packer Spiced Latte",
+			Slug:        "packer-spiced-latte",
+			Teaser:      "Packed with goodness to spice up your images",
+			Price:       350.5,
+			Image:       "/packer.png",
+			Status:      "published",
+			Ingredients: []CoffeeIngredients{{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}, {IngredientID: 4}},
+			Images:      &CoffeeImageSet{Srcset: "/images/1/thumb.png 200w", Thumb: "/images/1/thumb.png"},
+		},
+		Coffee{ID: 2, Name: "Vaulatte"},
+	}
+
+	d, err := c.ToProto()
+	assert.NoError(t, err)
+
+	rt := Coffees{}
+	err = rt.FromProto(d)
+	assert.NoError(t, err)
+
+	assert.Len(t, rt, 2)
+	assert.Equal(t, c[0].Name, rt[0].Name)
+	assert.Equal(t, c[0].Slug, rt[0].Slug)
+	assert.Equal(t, c[0].Teaser, rt[0].Teaser)
+	assert.Equal(t, c[0].Price, rt[0].Price)
+	assert.Equal(t, c[0].Image, rt[0].Image)
+	assert.Equal(t, c[0].Status, rt[0].Status)
+	assert.Equal(t, c[0].Ingredients[0], rt[0].Ingredients[0])
+	assert.Equal(t, 4, rt[0].Ingredients[1].IngredientID)
+	assert.Equal(t, c[0].Images, rt[0].Images)
+	assert.Equal(t, 2, rt[1].ID)
+	assert.Nil(t, rt[1].Images)
+}
+
+func TestCoffeesFromProtoRejectsTruncatedInput(t *testing.T) {
+	c := Coffees{Coffee{ID: 1, Name: "Latte"}}
+
+	d, err := c.ToProto()
+	assert.NoError(t, err)
+
+	rt := Coffees{}
+	assert.Error(t, rt.FromProto(d[:len(d)-2]))
+}
+
+func benchmarkCoffees(n int) Coffees {
+	c := make(Coffees, 0, n)
+	for i := 1; i <= n; i++ {
+		c = append(c, Coffee{
+			ID:          i,
+			Name:        fmt.Sprintf("Coffee %d", i),
+			Teaser:      "Nothing kickstarts your day like a provision of Terraspresso",
+			Price:       150,
+			Image:       "/terraform.png",
+			Ingredients: []CoffeeIngredients{{IngredientID: 1}, {IngredientID: 2}},
+		})
+	}
+	return c
+}
+
+func BenchmarkCoffeesToJSON(b *testing.B) {
+	c := benchmarkCoffees(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.ToJSON()
+	}
+}
+
+func BenchmarkCoffeesAppendJSON(b *testing.B) {
+	c := benchmarkCoffees(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.AppendJSON(nil)
+	}
+}
+
+func BenchmarkCoffeesToProto(b *testing.B) {
+	c := benchmarkCoffees(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.ToProto()
+	}
+}
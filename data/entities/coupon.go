@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// The coupon types
+const (
+	// CouponPercent takes a percentage off an order
+	CouponPercent = "percent"
+	// CouponFixed takes an amount off an order
+	CouponFixed = "fixed"
+)
+
+// Expired reports whether the coupon has expired at t
+func (c *Coupon) Expired(t time.Time) bool {
+	return c.ExpiresAt != nil && !t.Before(*c.ExpiresAt)
+}
+
+// Exhausted reports whether the coupon has reached its usage limit
+func (c *Coupon) Exhausted() bool {
+	return c.UsageLimit > 0 && c.Used >= c.UsageLimit
+}
+
+// Discount returns the amount the coupon takes off an order of subtotal,
+// rounded to cents and never more than the subtotal
+func (c *Coupon) Discount(subtotal float64) float64 {
+	discount := c.Value
+	if c.Type == CouponPercent {
+		discount = subtotal * c.Value / 100
+	}
+	return math.Round(math.Min(discount, subtotal)*100) / 100
+}
@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	percent := Coupon{Type: CouponPercent, Value: 15}
+	assert.Equal(t, 52.5, percent.Discount(350))
+	assert.Equal(t, 0.17, percent.Discount(1.1))
+
+	fixed := Coupon{Type: CouponFixed, Value: 100}
+	assert.Equal(t, 100.0, fixed.Discount(350))
+	// never more than the order
+	assert.Equal(t, 80.0, fixed.Discount(80))
+}
+
+func TestCouponExpiryAndUsageLimit(t *testing.T) {
+	now := time.Now()
+	coupon := Coupon{UsageLimit: 2, Used: 1}
+	assert.False(t, coupon.Expired(now))
+	assert.False(t, coupon.Exhausted())
+
+	coupon.ExpiresAt, coupon.Used = &now, 2
+	assert.True(t, coupon.Expired(now))
+	assert.False(t, coupon.Expired(now.Add(-time.Second)))
+	assert.True(t, coupon.Exhausted())
+
+	coupon.UsageLimit = 0
+	assert.False(t, coupon.Exhausted())
+}
@@ -0,0 +1,82 @@
+//go:build go1.18
+// +build go1.18
+
+package entities
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzCoffeesFromJSON(f *testing.F) {
+	f.Add([]byte(`[{"id":1,"name":"Vaulatte","price":200,"ingredients":[{"ingredient_id":1}]}]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[{"id":"1"}]`))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		c := Coffees{}
+		if err := c.FromJSON(bytes.NewReader(payload)); err != nil {
+			return
+		}
+
+		if _, err := c.ToJSON(); err != nil {
+			t.Fatalf("unable to encode decoded coffees: %v", err)
+		}
+	})
+}
+
+func FuzzCoffeesAppendJSON(f *testing.F) {
+	f.Add([]byte(`[{"id":1,"name":"Vaulatte <&>","price":200.5,"ingredients":[{"ingredient_id":1,"name":"Espresso"}]}]`))
+	f.Add([]byte(`[{"id":2,"teaser":"\u2028\t","price":1e-7,"stats":{"views":1,"score":1e21}}]`))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		c := Coffees{}
+		if err := c.FromJSON(bytes.NewReader(payload)); err != nil {
+			return
+		}
+
+		// the hand written encoding must match encoding/json exactly
+		want, err := c.ToJSON()
+		if err != nil {
+			t.Fatalf("unable to encode decoded coffees: %v", err)
+		}
+		got, err := c.AppendJSON(nil)
+		if err != nil {
+			t.Fatalf("unable to append decoded coffees: %v", err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Fatalf("AppendJSON returned %s, want %s", got, want)
+		}
+	})
+}
+
+func FuzzCoffeesFromProto(f *testing.F) {
+	c := benchmarkCoffees(2)
+	seed, err := c.ToProto()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		c := Coffees{}
+		if err := c.FromProto(payload); err != nil {
+			return
+		}
+
+		// whatever decodes must survive a round trip
+		b, err := c.ToProto()
+		if err != nil {
+			t.Fatalf("unable to encode decoded coffees: %v", err)
+		}
+
+		again := Coffees{}
+		if err := again.FromProto(b); err != nil {
+			t.Fatalf("unable to decode re-encoded coffees: %v", err)
+		}
+		if len(again) != len(c) {
+			t.Fatalf("round trip returned %d coffees, want %d", len(again), len(c))
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// The statuses of an order record
+const (
+	// OrderCreated is an order created while orders are not paid
+	OrderCreated = "created"
+	// OrderPaid is an order created and paid
+	OrderPaid = "paid"
+	// OrderCancelled is an order cancelled after its payment, points or
+	// coupon failed
+	OrderCancelled = "cancelled"
+)
+
+// Stats are the aggregates of the admin dashboard
+type Stats struct {
+	Coffees        int            `json:"coffees"`
+	OrdersByStatus map[string]int `json:"orders_by_status"`
+	TopSellers     []TopSeller    `json:"top_sellers"`
+	// RevenueToday is the total of the orders created since midnight UTC,
+	// but cancelled ones
+	RevenueToday float64 `json:"revenue_today"`
+}
+
+// TopSeller is a coffee with the quantity ordered of it, but in cancelled
+// orders
+type TopSeller struct {
+	CoffeeID int    `db:"coffee_id" json:"coffee_id"`
+	Name     string `db:"name" json:"name"`
+	Quantity int    `db:"quantity" json:"quantity"`
+}
+
+// SalesBucket is the number of orders created in a bucket of time starting at
+// Time, and their revenue, but cancelled orders
+type SalesBucket struct {
+	Time    time.Time `db:"hour" json:"time"`
+	Orders  int       `db:"orders" json:"orders"`
+	Revenue float64   `db:"revenue" json:"revenue"`
+}
+
+// Validate checks the invariants of an order record: an ID, a known status,
+// no negative total, and items of a coffee with a positive quantity and no
+// negative price
+func (o *OrderRecord) Validate() error {
+	if o.ID <= 0 {
+		return &ValidationError{Entity: "order record", Field: "id", Reason: "must be positive"}
+	}
+	switch o.Status {
+	case OrderCreated, OrderPaid, OrderCancelled:
+	default:
+		return &ValidationError{Entity: "order record", Field: "status", Reason: "must be created, paid or cancelled"}
+	}
+	if o.Total < 0 || math.IsNaN(o.Total) {
+		return &ValidationError{Entity: "order record", Field: "total", Reason: "must not be negative"}
+	}
+	for _, item := range o.Items {
+		if item.CoffeeID <= 0 {
+			return &ValidationError{Entity: "order record", Field: "coffee_id", Reason: "must be positive"}
+		}
+		if item.Quantity <= 0 {
+			return &ValidationError{Entity: "order record", Field: "quantity", Reason: "must be positive"}
+		}
+		if item.Price < 0 || math.IsNaN(item.Price) {
+			return &ValidationError{Entity: "order record", Field: "price", Reason: "must not be negative"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package entities
+
+// The reasons of a points entry
+const (
+	// PointsEarned credits the points earned on a paid order
+	PointsEarned = "earned"
+	// PointsRedeemed debits the points taken off an order
+	PointsRedeemed = "redeemed"
+	// PointsRefunded credits back the points redeemed on a failed order
+	PointsRefunded = "refunded"
+)
+
+// Points is the loyalty points balance of a user, the sum of its ledger
+type Points struct {
+	UserID  int           `json:"user_id"`
+	Balance int           `json:"balance"`
+	Ledger  []PointsEntry `json:"ledger"`
+}
@@ -0,0 +1,34 @@
+package entities
+
+import "sync"
+
+// coffeesPool recycles the backing arrays of coffee lists between requests,
+// it holds pointers so putting a slice back does not allocate
+var coffeesPool = sync.Pool{
+	New: func() interface{} {
+		c := make(Coffees, 0)
+		return &c
+	},
+}
+
+// GetCoffees returns an empty list from the pool, with the capacity of a
+// list put back earlier
+func GetCoffees() Coffees {
+	return (*coffeesPool.Get().(*Coffees))[:0]
+}
+
+// PutCoffees returns a list to the pool once nothing references it or its
+// coffees anymore, e.g. once it has been encoded. The coffees are cleared so
+// the pool does not keep their ingredients, stats and images alive.
+func PutCoffees(c Coffees) {
+	if cap(c) == 0 {
+		return
+	}
+
+	c = c[:cap(c)]
+	for n := range c {
+		c[n] = Coffee{}
+	}
+	c = c[:0]
+	coffeesPool.Put(&c)
+}
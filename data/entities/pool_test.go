@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutCoffeesClearsTheList(t *testing.T) {
+	c := append(GetCoffees(), Coffee{ID: 1, Name: "Terraspresso", Stats: &CoffeeStats{Views: 1}})
+	PutCoffees(c)
+
+	// the coffees must not be kept alive by the pool
+	assert.Equal(t, Coffee{}, c[0])
+	assert.Empty(t, GetCoffees())
+}
+
+func TestPutCoffeesIgnoresEmptyLists(t *testing.T) {
+	PutCoffees(nil)
+	assert.NotNil(t, GetCoffees())
+}
@@ -0,0 +1,11 @@
+package entities
+
+// Certified reports whether the supplier holds a certification
+func (s *Supplier) Certified(certification string) bool {
+	for _, c := range s.Certifications {
+		if c == certification {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// ValidationError is returned by the Validate methods for an entity breaking
+// one of its invariants, the handlers answer 400 with its message
+type ValidationError struct {
+	// Entity is the kind of the entity, e.g. coffee
+	Entity string
+	// Field is the JSON name of the invalid field
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s, %s %s", e.Entity, e.Field, e.Reason)
+}
+
+// validName reports whether name has a letter or a digit, so it is not lost
+// when names are normalized
+func validName(name string) bool {
+	return strings.IndexFunc(name, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// validPrice reports whether price is a finite amount above zero
+func validPrice(price float64) bool {
+	return price > 0 && !math.IsInf(price, 1)
+}
+
+// validImage reports whether image is empty, a path on the public API such as
+// /packer.png, or an http or https URL
+func validImage(image string) bool {
+	if image == "" {
+		return true
+	}
+	if strings.IndexFunc(image, unicode.IsSpace) >= 0 {
+		return false
+	}
+	if strings.HasPrefix(image, "/") {
+		return !strings.HasPrefix(image, "//") && !strings.Contains(image, "..")
+	}
+	u, err := url.Parse(image)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,101 @@
+package entities
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoffeeValidate(t *testing.T) {
+	valid := func() Coffee {
+		return Coffee{Name: "Vaulatte", Price: 200, Image: "/vault.png", Ingredients: []CoffeeIngredients{{IngredientID: 1, Quantity: 40}}}
+	}
+	tests := []struct {
+		name   string
+		change func(c *Coffee)
+		err    string
+	}{
+		{"valid", func(c *Coffee) {}, ""},
+		{"without image", func(c *Coffee) { c.Image = "" }, ""},
+		{"image URL", func(c *Coffee) { c.Image = "https://images.example.com/vault.png" }, ""},
+		{"empty name", func(c *Coffee) { c.Name = "" }, "invalid coffee, name is required"},
+		{"punctuation name", func(c *Coffee) { c.Name = " - " }, "invalid coffee, name is required"},
+		{"zero price", func(c *Coffee) { c.Price = 0 }, "invalid coffee, price must be positive"},
+		{"negative price", func(c *Coffee) { c.Price = -1 }, "invalid coffee, price must be positive"},
+		{"NaN price", func(c *Coffee) { c.Price = math.NaN() }, "invalid coffee, price must be positive"},
+		{"infinite price", func(c *Coffee) { c.Price = math.Inf(1) }, "invalid coffee, price must be positive"},
+		{"relative image", func(c *Coffee) { c.Image = "vault.png" }, "invalid coffee, image must be a path such as /packer.png or an http URL"},
+		{"parent image", func(c *Coffee) { c.Image = "/../etc/passwd" }, "invalid coffee, image must be a path such as /packer.png or an http URL"},
+		{"protocol relative image", func(c *Coffee) { c.Image = "//example.com/vault.png" }, "invalid coffee, image must be a path such as /packer.png or an http URL"},
+		{"javascript image", func(c *Coffee) { c.Image = "javascript:alert(1)" }, "invalid coffee, image must be a path such as /packer.png or an http URL"},
+		{"image with spaces", func(c *Coffee) { c.Image = "/vault latte.png" }, "invalid coffee, image must be a path such as /packer.png or an http URL"},
+		{"ingredient without ID", func(c *Coffee) { c.Ingredients[0].IngredientID = 0 }, "invalid coffee, ingredient_id must be positive"},
+		{"negative quantity", func(c *Coffee) { c.Ingredients[0].Quantity = -1 }, "invalid coffee, quantity must not be negative"},
+	}
+
+	for _, test := range tests {
+		coffee := valid()
+		test.change(&coffee)
+		err := coffee.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+		assert.EqualError(t, err, test.err, test.name)
+		assert.IsType(t, &ValidationError{}, err, test.name)
+	}
+}
+
+func TestIngredientValidate(t *testing.T) {
+	tests := []struct {
+		ingredient Ingredient
+		err        string
+	}{
+		{Ingredient{Name: "Espresso", Quantity: 40, Unit: "ml"}, ""},
+		{Ingredient{Name: "Hot Water"}, ""},
+		{Ingredient{Name: "  ", Quantity: 40}, "invalid ingredient, name is required"},
+		{Ingredient{Name: "Espresso", Quantity: -40}, "invalid ingredient, quantity must not be negative"},
+	}
+
+	for _, test := range tests {
+		err := test.ingredient.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, test.ingredient.Name)
+			continue
+		}
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestOrderRecordValidate(t *testing.T) {
+	valid := func() OrderRecord {
+		return OrderRecord{ID: 1, Status: OrderPaid, Total: 350, Items: []OrderRecordItem{{CoffeeID: 2, Name: "Vaulatte", Quantity: 1, Price: 200}}}
+	}
+	tests := []struct {
+		name   string
+		change func(o *OrderRecord)
+		err    string
+	}{
+		{"valid", func(o *OrderRecord) {}, ""},
+		{"cancelled without items", func(o *OrderRecord) { o.Status, o.Items = OrderCancelled, nil }, ""},
+		{"free", func(o *OrderRecord) { o.Total = 0 }, ""},
+		{"without ID", func(o *OrderRecord) { o.ID = 0 }, "invalid order record, id must be positive"},
+		{"unknown status", func(o *OrderRecord) { o.Status = "lost" }, "invalid order record, status must be created, paid or cancelled"},
+		{"negative total", func(o *OrderRecord) { o.Total = -1 }, "invalid order record, total must not be negative"},
+		{"item without coffee", func(o *OrderRecord) { o.Items[0].CoffeeID = 0 }, "invalid order record, coffee_id must be positive"},
+		{"item without quantity", func(o *OrderRecord) { o.Items[0].Quantity = 0 }, "invalid order record, quantity must be positive"},
+		{"negative item price", func(o *OrderRecord) { o.Items[0].Price = -200 }, "invalid order record, price must not be negative"},
+	}
+
+	for _, test := range tests {
+		order := valid()
+		test.change(&order)
+		err := order.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+		assert.EqualError(t, err, test.err, test.name)
+	}
+}
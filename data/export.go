@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Snapshot is the catalogue of a tenant in a form which can be imported into
+// any repository, so a tenant can be moved between the Postgres and in memory
+// backends. Coffees refer to the ingredients of the snapshot by ID.
+type Snapshot struct {
+	Tenant      string               `json:"tenant"`
+	Ingredients entities.Ingredients `json:"ingredients"`
+	Coffees     entities.Coffees     `json:"coffees"`
+}
+
+// ImportResult counts the entities an import created and updated
+type ImportResult struct {
+	IngredientsCreated int `json:"ingredients_created"`
+	IngredientsUpdated int `json:"ingredients_updated"`
+	CoffeesCreated     int `json:"coffees_created"`
+	CoffeesUpdated     int `json:"coffees_updated"`
+}
+
+// Export returns a snapshot of every coffee and ingredient of the repository
+func Export(ctx context.Context, r Repository, tenant string) (*Snapshot, error) {
+	ingredients, err := r.FindIngredients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coffees, err := r.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range coffees {
+		coffees[i].Stats = nil
+	}
+	return &Snapshot{Tenant: tenant, Ingredients: ingredients, Coffees: coffees}, nil
+}
+
+// Import writes a snapshot to the repository. Entities are matched by name,
+// existing ones are updated and missing ones created, so importing the same
+// snapshot again changes nothing. The IDs of the snapshot are not kept, the
+// repository assigns its own, and existing coffees keep their status. Entities
+// missing from the snapshot are left in place.
+func Import(ctx context.Context, r Repository, s *Snapshot) (ImportResult, error) {
+	result := ImportResult{}
+
+	existingIngredients, err := r.FindIngredients(ctx)
+	if err != nil {
+		return result, err
+	}
+	ingredientIDs := make(map[string]int, len(existingIngredients))
+	for _, i := range existingIngredients {
+		ingredientIDs[i.Name] = i.ID
+	}
+
+	// snapshot ingredient ID to repository ingredient ID
+	ids := make(map[int]int, len(s.Ingredients))
+	for _, i := range s.Ingredients {
+		ingredient := i
+		if id, ok := ingredientIDs[ingredient.Name]; ok {
+			ingredient.ID = id
+			if err := r.UpdateIngredient(ctx, &ingredient); err != nil {
+				return result, fmt.Errorf("unable to update ingredient %q: %w", ingredient.Name, err)
+			}
+			result.IngredientsUpdated++
+		} else {
+			if err := r.CreateIngredient(ctx, &ingredient); err != nil {
+				return result, fmt.Errorf("unable to create ingredient %q: %w", ingredient.Name, err)
+			}
+			result.IngredientsCreated++
+		}
+		ids[i.ID] = ingredient.ID
+	}
+
+	existingCoffees, err := r.Find(ctx)
+	if err != nil {
+		return result, err
+	}
+	coffeeIDs := make(map[string]int, len(existingCoffees))
+	for _, c := range existingCoffees {
+		coffeeIDs[c.Name] = c.ID
+	}
+	entities.PutCoffees(existingCoffees)
+
+	for _, c := range s.Coffees {
+		coffee := c
+		coffee.Stats = nil
+		coffee.Ingredients = make([]entities.CoffeeIngredients, 0, len(c.Ingredients))
+		for _, i := range c.Ingredients {
+			id, ok := ids[i.IngredientID]
+			if !ok {
+				return result, fmt.Errorf("coffee %q uses ingredient %d which is not in the snapshot", c.Name, i.IngredientID)
+			}
+			coffee.Ingredients = append(coffee.Ingredients, entities.CoffeeIngredients{IngredientID: id, Quantity: i.Quantity, Unit: i.Unit})
+		}
+
+		if id, ok := coffeeIDs[coffee.Name]; ok {
+			// existing coffees stay in their place of the publishing workflow
+			coffee.ID = id
+			coffee.Status = ""
+			if err := r.UpdateCoffee(ctx, &coffee); err != nil {
+				return result, fmt.Errorf("unable to update coffee %q: %w", coffee.Name, err)
+			}
+			result.CoffeesUpdated++
+			continue
+		}
+		// snapshots without statuses predate drafts, every coffee was on the menu
+		if coffee.Status == "" {
+			coffee.Status = StatusPublished
+		}
+		if err := r.CreateCoffee(ctx, &coffee); err != nil {
+			return result, fmt.Errorf("unable to create coffee %q: %w", coffee.Name, err)
+		}
+		result.CoffeesCreated++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func TestImportRestoresAnExportIntoAnotherRepository(t *testing.T) {
+	ctx := context.Background()
+	source, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	// IDs differ between the repositories once the source has used more
+	// sequence numbers
+	ingredient := &entities.Ingredient{Name: "Oat Milk", Quantity: 100, Unit: "ml"}
+	require.NoError(t, source.CreateIngredient(ctx, ingredient))
+	require.NoError(t, source.CreateCoffee(ctx, &entities.Coffee{
+		Name:        "Oat Latte",
+		Price:       250,
+		Ingredients: []entities.CoffeeIngredients{{IngredientID: ingredient.ID, Quantity: 200, Unit: "ml"}},
+	}))
+
+	snapshot, err := Export(ctx, source, "hashicups")
+	require.NoError(t, err)
+	assert.Equal(t, "hashicups", snapshot.Tenant)
+
+	target, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	require.NoError(t, target.CreateIngredient(ctx, &entities.Ingredient{Name: "Cinnamon", Quantity: 1, Unit: "g"}))
+
+	result, err := Import(ctx, target, snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.IngredientsCreated)
+	assert.Equal(t, 1, result.CoffeesCreated)
+	assert.Equal(t, len(snapshot.Coffees)-1, result.CoffeesUpdated)
+
+	expr, err := filter.Parse(`name="Oat Latte"`)
+	require.NoError(t, err)
+	coffees, err := target.FindWhere(ctx, expr)
+	require.NoError(t, err)
+	require.Len(t, coffees, 1)
+	assert.Equal(t, 250.0, coffees[0].Price)
+	require.Len(t, coffees[0].Ingredients, 1)
+	assert.Equal(t, "Oat Milk", coffees[0].Ingredients[0].Name)
+	assert.NotEqual(t, ingredient.ID, coffees[0].Ingredients[0].IngredientID)
+
+	// importing again only updates
+	result, err = Import(ctx, target, snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.IngredientsCreated+result.CoffeesCreated)
+}
+
+func TestImportRejectsUnknownIngredients(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	_, err = Import(context.Background(), r, &Snapshot{Coffees: entities.Coffees{{
+		Name:        "Mystery",
+		Ingredients: []entities.CoffeeIngredients{{IngredientID: 42}},
+	}}})
+	assert.EqualError(t, err, `coffee "Mystery" uses ingredient 42 which is not in the snapshot`)
+}
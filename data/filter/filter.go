@@ -0,0 +1,130 @@
+// Package filter implements the query language accepted by the ?filter=
+// parameter of the list endpoints, e.g.
+//
+//	price<300 AND name~latte
+//	(price>=200 OR id=1) AND NOT teaser~"spice"
+//
+// Expressions are parsed into an AST which the repositories either evaluate
+// directly (Match) or translate into parameterized SQL (ToSQL). Only the
+// fields declared in Fields can be referenced, so user input never reaches a
+// query as anything other than a bound parameter.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxLength is the longest filter string accepted
+	MaxLength = 512
+	// MaxComparisons is the largest number of comparisons in one filter
+	MaxComparisons = 16
+)
+
+// FieldType is the type of a filterable field
+type FieldType int
+
+const (
+	// Number fields support every comparison operator except ~
+	Number FieldType = iota
+	// String fields support =, != and ~
+	String
+)
+
+// Field describes a filterable coffee attribute
+type Field struct {
+	Name   string
+	Column string
+	Type   FieldType
+}
+
+// Fields are the coffee attributes a filter may reference
+var Fields = map[string]Field{
+	"id":          {"id", "id", Number},
+	"name":        {"name", "name", String},
+	"teaser":      {"teaser", "teaser", String},
+	"description": {"description", "description", String},
+	"price":       {"price", "price", Number},
+	"image":       {"image", "image", String},
+	"status":      {"status", "status", String},
+}
+
+// Operator is a comparison operator
+type Operator string
+
+const (
+	// Eq is =
+	Eq Operator = "="
+	// NotEq is !=
+	NotEq Operator = "!="
+	// Lt is <
+	Lt Operator = "<"
+	// LtEq is <=
+	LtEq Operator = "<="
+	// Gt is >
+	Gt Operator = ">"
+	// GtEq is >=
+	GtEq Operator = ">="
+	// Contains is ~, a case insensitive substring match
+	Contains Operator = "~"
+)
+
+// Expr is a node of the filter AST
+type Expr interface {
+	String() string
+}
+
+// Comparison compares a field against a literal value
+type Comparison struct {
+	Field Field
+	Op    Operator
+	// Value is a float64 for Number fields and a string for String fields
+	Value interface{}
+}
+
+// quoter escapes text values using the filter grammar's escapes
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (c *Comparison) String() string {
+	if s, ok := c.Value.(string); ok {
+		return fmt.Sprintf(`%s%s"%s"`, c.Field.Name, c.Op, quoter.Replace(s))
+	}
+	return c.Field.Name + string(c.Op) + strconv.FormatFloat(c.Value.(float64), 'f', -1, 64)
+}
+
+// And matches when both sides match
+type And struct {
+	Left, Right Expr
+}
+
+func (a *And) String() string {
+	return fmt.Sprintf("(%s AND %s)", a.Left, a.Right)
+}
+
+// Or matches when either side matches
+type Or struct {
+	Left, Right Expr
+}
+
+func (o *Or) String() string {
+	return fmt.Sprintf("(%s OR %s)", o.Left, o.Right)
+}
+
+// Not inverts an expression
+type Not struct {
+	Expr Expr
+}
+
+func (n *Not) String() string {
+	return fmt.Sprintf("NOT %s", n.Expr)
+}
+
+// Conjuncts flattens the top level AND chain of an expression
+func Conjuncts(e Expr) []Expr {
+	if a, ok := e.(*And); ok {
+		return append(Conjuncts(a.Left), Conjuncts(a.Right)...)
+	}
+	return []Expr{e}
+}
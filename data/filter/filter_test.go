@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestParseBuildsAST(t *testing.T) {
+	tt := map[string]string{
+		"price<300 AND name~latte":            `(price<300 AND name~"latte")`,
+		"price<300 and name~latte OR id=1":    `((price<300 AND name~"latte") OR id=1)`,
+		"price<300 AND (name~latte OR id!=1)": `(price<300 AND (name~"latte" OR id!=1))`,
+		`NOT teaser~"spice up"`:               `NOT teaser~"spice up"`,
+		"image=/vault.png":                    `image="/vault.png"`,
+		"price >= 1.5":                        `price>=1.5`,
+	}
+
+	for input, expected := range tt {
+		e, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, e.String(), input)
+	}
+}
+
+func TestParseRejectsInvalidFilters(t *testing.T) {
+	tt := []string{
+		"",
+		"price",
+		"price<",
+		"price<abc",
+		"name<latte",
+		"price~3",
+		"password=secret",
+		"name=latte AND",
+		"(name=latte",
+		"name=latte)",
+		`name="latte`,
+		"name=latte; DROP TABLE coffee",
+		"price ! 3",
+	}
+
+	for _, input := range tt {
+		_, err := Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseLimitsComplexity(t *testing.T) {
+	input := "id=1"
+	for i := 0; i < MaxComparisons; i++ {
+		input += " OR id=1"
+	}
+
+	_, err := Parse(input)
+	assert.Error(t, err)
+}
+
+func TestMatchEvaluatesExpression(t *testing.T) {
+	c := &entities.Coffee{ID: 1, Name: "Packer Spiced Latte", Price: 350}
+
+	tt := map[string]bool{
+		"price<300 AND name~latte":   false,
+		"price<400 AND name~LATTE":   true,
+		"price<300 OR name~latte":    true,
+		"NOT id=1":                   false,
+		"id!=2 AND (price=350)":      true,
+		`name="Packer Spiced Latte"`: true,
+	}
+
+	for input, expected := range tt {
+		e, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, Match(e, c), input)
+	}
+}
+
+func TestToSQLUsesBindParameters(t *testing.T) {
+	e, err := Parse(`price<300 AND (name~"50%_off" OR NOT id!=2)`)
+	require.NoError(t, err)
+
+	clause, args := ToSQL(e, 1)
+	assert.Equal(t, "(price < $2 AND (name ILIKE $3 OR (NOT id <> $4)))", clause)
+	assert.Equal(t, []interface{}{float64(300), `%50\%\_off%`, float64(2)}, args)
+}
@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"price<300 AND name~latte",
+		"price<300 and name~latte OR id=1",
+		`NOT teaser~"spice up"`,
+		"(name=latte OR id!=1) AND price>=1.5",
+		`name~"100%_off\\"`,
+		"name=latte; DROP TABLE coffee",
+	} {
+		f.Add(seed)
+	}
+
+	coffee := &entities.Coffee{ID: 1, Name: "Vaulatte", Teaser: "Nothing gives you a safe feeling", Price: 200}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		expr, err := Parse(input)
+		if err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("Parse(%q) returned %T, want *SyntaxError", input, err)
+			}
+			return
+		}
+
+		// the canonical form must parse back to itself
+		again, err := Parse(expr.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) failed on canonical form %q: %v", input, expr.String(), err)
+		}
+		if again.String() != expr.String() {
+			t.Fatalf("canonical form of %q changed from %q to %q", input, expr.String(), again.String())
+		}
+
+		clause, args := ToSQL(expr, 0)
+		if n := strings.Count(clause, "$"); n != len(args) {
+			t.Fatalf("ToSQL(%q) has %d placeholders for %d args", input, n, len(args))
+		}
+
+		Match(expr, coffee)
+	})
+}
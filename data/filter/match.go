@@ -0,0 +1,82 @@
+package filter
+
+import (
+	"strings"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Match evaluates the expression against a coffee
+func Match(e Expr, c *entities.Coffee) bool {
+	switch e := e.(type) {
+	case *And:
+		return Match(e.Left, c) && Match(e.Right, c)
+	case *Or:
+		return Match(e.Left, c) || Match(e.Right, c)
+	case *Not:
+		return !Match(e.Expr, c)
+	case *Comparison:
+		if e.Field.Type == Number {
+			return compareNumber(numberField(e.Field, c), e.Op, e.Value.(float64))
+		}
+		return compareString(stringField(e.Field, c), e.Op, e.Value.(string))
+	}
+
+	return false
+}
+
+func numberField(f Field, c *entities.Coffee) float64 {
+	switch f.Name {
+	case "id":
+		return float64(c.ID)
+	case "price":
+		return c.Price
+	}
+	return 0
+}
+
+func stringField(f Field, c *entities.Coffee) string {
+	switch f.Name {
+	case "name":
+		return c.Name
+	case "teaser":
+		return c.Teaser
+	case "description":
+		return c.Description
+	case "image":
+		return c.Image
+	case "status":
+		return c.Status
+	}
+	return ""
+}
+
+func compareNumber(actual float64, op Operator, expected float64) bool {
+	switch op {
+	case Eq:
+		return actual == expected
+	case NotEq:
+		return actual != expected
+	case Lt:
+		return actual < expected
+	case LtEq:
+		return actual <= expected
+	case Gt:
+		return actual > expected
+	case GtEq:
+		return actual >= expected
+	}
+	return false
+}
+
+func compareString(actual string, op Operator, expected string) bool {
+	switch op {
+	case Eq:
+		return actual == expected
+	case NotEq:
+		return actual != expected
+	case Contains:
+		return strings.Contains(strings.ToLower(actual), strings.ToLower(expected))
+	}
+	return false
+}
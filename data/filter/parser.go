@@ -0,0 +1,260 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError describes why a filter could not be parsed
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOperator
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits the input into tokens
+func lex(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case strings.ContainsRune("=!<>~", r):
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' && r != '=' && r != '~' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, &SyntaxError{start, "expected !="}
+			}
+			tokens = append(tokens, token{tokOperator, op, start})
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, &SyntaxError{start, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-' || r == '/':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_.-/", runes[i])) {
+				i++
+			}
+			text := string(runes[start:i])
+			kind := tokIdent
+			switch strings.ToUpper(text) {
+			case "AND":
+				kind = tokAnd
+			case "OR":
+				kind = tokOr
+			case "NOT":
+				kind = tokNot
+			default:
+				if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+					kind = tokNumber
+				}
+			}
+			tokens = append(tokens, token{kind, text, start})
+		default:
+			return nil, &SyntaxError{i, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+type parser struct {
+	tokens      []token
+	pos         int
+	comparisons int
+}
+
+// Parse parses and validates a filter expression
+//
+//	expr       := term { OR term }
+//	term       := factor { AND factor }
+//	factor     := NOT factor | "(" expr ")" | comparison
+//	comparison := field operator value
+func Parse(input string) (Expr, error) {
+	if len(input) > MaxLength {
+		return nil, &SyntaxError{MaxLength, fmt.Sprintf("filter longer than %d characters", MaxLength)}
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseFactor() (Expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNot:
+		e, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{e}, nil
+	case tokLParen:
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{closing.pos, "expected )"}
+		}
+		return e, nil
+	case tokIdent:
+		return p.parseComparison(t)
+	case tokEOF:
+		return nil, &SyntaxError{t.pos, "unexpected end of filter"}
+	}
+
+	return nil, &SyntaxError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+}
+
+func (p *parser) parseComparison(fieldToken token) (Expr, error) {
+	p.comparisons++
+	if p.comparisons > MaxComparisons {
+		return nil, &SyntaxError{fieldToken.pos, fmt.Sprintf("more than %d comparisons", MaxComparisons)}
+	}
+
+	field, ok := Fields[strings.ToLower(fieldToken.text)]
+	if !ok {
+		return nil, &SyntaxError{fieldToken.pos, fmt.Sprintf("unknown field %q", fieldToken.text)}
+	}
+
+	opToken := p.next()
+	if opToken.kind != tokOperator {
+		return nil, &SyntaxError{opToken.pos, "expected operator"}
+	}
+	op := Operator(opToken.text)
+
+	valueToken := p.next()
+	switch field.Type {
+	case Number:
+		if op == Contains {
+			return nil, &SyntaxError{opToken.pos, fmt.Sprintf("~ is not supported on numeric field %q", field.Name)}
+		}
+		if valueToken.kind != tokNumber {
+			return nil, &SyntaxError{valueToken.pos, fmt.Sprintf("expected number for field %q", field.Name)}
+		}
+		value, _ := strconv.ParseFloat(valueToken.text, 64)
+		return &Comparison{field, op, value}, nil
+	default:
+		if op != Eq && op != NotEq && op != Contains {
+			return nil, &SyntaxError{opToken.pos, fmt.Sprintf("%s is not supported on text field %q", op, field.Name)}
+		}
+		if valueToken.kind != tokString && valueToken.kind != tokIdent && valueToken.kind != tokNumber {
+			return nil, &SyntaxError{valueToken.pos, fmt.Sprintf("expected value for field %q", field.Name)}
+		}
+		return &Comparison{field, op, valueToken.text}, nil
+	}
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// likeEscaper escapes the LIKE wildcards in user supplied values
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ToSQL translates the expression into a Postgres WHERE clause. Values are
+// returned as bind parameters numbered from offset+1 so the clause can be
+// appended to a query which already has parameters.
+func ToSQL(e Expr, offset int) (string, []interface{}) {
+	args := make([]interface{}, 0)
+	clause := toSQL(e, offset, &args)
+	return clause, args
+}
+
+func toSQL(e Expr, offset int, args *[]interface{}) string {
+	switch e := e.(type) {
+	case *And:
+		return fmt.Sprintf("(%s AND %s)", toSQL(e.Left, offset, args), toSQL(e.Right, offset, args))
+	case *Or:
+		return fmt.Sprintf("(%s OR %s)", toSQL(e.Left, offset, args), toSQL(e.Right, offset, args))
+	case *Not:
+		return fmt.Sprintf("(NOT %s)", toSQL(e.Expr, offset, args))
+	case *Comparison:
+		value := e.Value
+		op := string(e.Op)
+		if e.Op == Contains {
+			value = "%" + likeEscaper.Replace(e.Value.(string)) + "%"
+			op = "ILIKE"
+		} else if e.Op == NotEq {
+			op = "<>"
+		}
+
+		*args = append(*args, value)
+		// the column comes from the Fields whitelist, never from the input
+		return fmt.Sprintf("%s %s $%d", e.Field.Column, op, offset+len(*args))
+	}
+
+	return "FALSE"
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Fixtures are rows seeded into an in memory repository with Seed. Rows
+// without an ID get the next ID of their table, which Seed writes back so
+// tests can refer to them.
+type Fixtures struct {
+	Ingredients []entities.Ingredient
+	// Coffees are published unless they have a status, their slug is
+	// generated from the name unless they have one, and their ingredients
+	// refer to ingredients by ID
+	Coffees []entities.Coffee
+	Stores  []entities.Store
+	// Menus are the coffee IDs served by each store ID
+	Menus map[int][]int
+	// Suppliers are linked to their IngredientIDs
+	Suppliers []entities.Supplier
+	Coupons   []entities.Coupon
+}
+
+// NewIsolatedInMemoryDB returns an in memory repository holding the static
+// catalogue of NewInMemoryDB. Every repository has a database of its own and
+// logs nowhere, so tests calling t.Parallel() can each create one and change
+// it without affecting the others.
+func NewIsolatedInMemoryDB() (*InMemoryRepository, error) {
+	repository, err := NewEmptyInMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.load(); err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// NewEmptyInMemoryDB returns an isolated in memory repository like
+// NewIsolatedInMemoryDB holding no rows, for tests seeding their own
+// Fixtures.
+func NewEmptyInMemoryDB() (*InMemoryRepository, error) {
+	return newInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+}
+
+// Seed inserts fixtures in a single transaction, nothing is inserted when a
+// row is rejected, e.g. a coffee named like an existing one or a row with the
+// ID of an existing one, which returns ErrConflict
+func (r *InMemoryRepository) Seed(f *Fixtures) error {
+	ctx := context.Background()
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	timestamp := time.Now().String()
+
+	for n := range f.Ingredients {
+		f.Ingredients[n].ID = r.fixtureID(Ingredient, f.Ingredients[n].ID)
+		f.Ingredients[n].CreatedAt, f.Ingredients[n].UpdatedAt = timestamp, timestamp
+		row := f.Ingredients[n]
+		if err := r.create(ctx, txn, Ingredient, &row); err != nil {
+			return err
+		}
+	}
+
+	for n := range f.Coffees {
+		coffee := &f.Coffees[n]
+		row := *coffee
+		row.ID = r.fixtureID(Coffee, row.ID)
+		row.CreatedAt, row.UpdatedAt = timestamp, timestamp
+		row.Ingredients = nil
+		row.Stats = nil
+		if row.Status == "" {
+			row.Status = StatusPublished
+		}
+		if _, err := initialStatus(row.Status); err != nil {
+			return err
+		}
+		if row.Slug == "" {
+			var err error
+			if row.Slug, err = slugFor(nil, row.Name, r.slugTaken(ctx, txn, row.ID)); err != nil {
+				return err
+			}
+		}
+		if err := r.create(ctx, txn, Coffee, &row); err != nil {
+			return err
+		}
+
+		ingredients, err := r.replaceCoffeeIngredients(ctx, txn, row.ID, coffee.Ingredients, timestamp)
+		if err != nil {
+			return err
+		}
+		*coffee = row
+		coffee.Ingredients = ingredients
+	}
+
+	for n := range f.Stores {
+		f.Stores[n].ID = r.fixtureID(Store, f.Stores[n].ID)
+		f.Stores[n].CreatedAt, f.Stores[n].UpdatedAt = timestamp, timestamp
+		row := f.Stores[n]
+		if err := r.create(ctx, txn, Store, &row); err != nil {
+			return err
+		}
+	}
+	for storeID, coffeeIDs := range f.Menus {
+		for _, coffeeID := range coffeeIDs {
+			if err := r.create(ctx, txn, StoreCoffee, &entities.StoreCoffee{StoreID: storeID, CoffeeID: coffeeID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n := range f.Suppliers {
+		f.Suppliers[n].ID = r.fixtureID(Supplier, f.Suppliers[n].ID)
+		f.Suppliers[n].CreatedAt, f.Suppliers[n].UpdatedAt = timestamp, timestamp
+		row := f.Suppliers[n]
+		// the links are rows of their own
+		row.IngredientIDs = nil
+		if err := r.create(ctx, txn, Supplier, &row); err != nil {
+			return err
+		}
+		for _, ingredientID := range f.Suppliers[n].IngredientIDs {
+			if err := r.create(ctx, txn, IngredientSupplier, &entities.IngredientSupplier{IngredientID: ingredientID, SupplierID: row.ID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n := range f.Coupons {
+		f.Coupons[n].CreatedAt, f.Coupons[n].UpdatedAt = timestamp, timestamp
+		row := f.Coupons[n]
+		if err := r.create(ctx, txn, Coupon, &row); err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// fixtureID returns the ID of a fixture row of table, the next ID of the
+// table when the row has none
+func (r *InMemoryRepository) fixtureID(table TableNameKey, id int) int {
+	if id == 0 {
+		return r.sequences.next(table)
+	}
+	r.sequences.observe(table, id)
+	return id
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestIsolatedInMemoryDBsDoNotShareRows(t *testing.T) {
+	for n := 0; n < 8; n++ {
+		n := n
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			r, err := NewIsolatedInMemoryDB()
+			require.NoError(t, err)
+
+			coffee := &entities.Coffee{Name: fmt.Sprintf("Parallel %d", n), Price: 200, Status: StatusPublished}
+			require.NoError(t, r.CreateCoffee(ctx, coffee))
+			assert.Equal(t, 7, coffee.ID)
+
+			coffees, err := r.Find(ctx)
+			require.NoError(t, err)
+			assert.Len(t, coffees, 7)
+		})
+	}
+}
+
+func TestSeedInsertsFixtures(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	r, err := NewEmptyInMemoryDB()
+	require.NoError(t, err)
+
+	fixtures := &Fixtures{
+		Ingredients: []entities.Ingredient{{Name: "Espresso"}, {ID: 10, Name: "Oat Milk"}},
+		Coffees: []entities.Coffee{{
+			Name:        "Oat Flat White",
+			Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Quantity: 40, Unit: "ml"}, {IngredientID: 10, Quantity: 120, Unit: "ml"}},
+		}},
+		Stores:    []entities.Store{{Name: "Harbour", Country: "NL"}},
+		Menus:     map[int][]int{1: {1}},
+		Suppliers: []entities.Supplier{{Name: "Oatly Farms", Country: "SE", Certifications: []string{}, IngredientIDs: []int{10}}},
+		Coupons:   []entities.Coupon{{Code: "FIXTURE", Type: entities.CouponPercent, Value: 5}},
+	}
+	require.NoError(t, r.Seed(fixtures))
+
+	assert.Equal(t, 1, fixtures.Ingredients[0].ID)
+	assert.Equal(t, 1, fixtures.Coffees[0].ID)
+	assert.Equal(t, "oat-flat-white", fixtures.Coffees[0].Slug)
+
+	coffee, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPublished, coffee.Status)
+	require.Len(t, coffee.Ingredients, 2)
+	assert.Equal(t, "Oat Milk", coffee.Ingredients[1].Name)
+
+	menu, err := r.FindStoreCoffees(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, menu)
+
+	suppliers, err := r.FindSuppliers(ctx)
+	require.NoError(t, err)
+	require.Len(t, suppliers, 1)
+	assert.Equal(t, []int{10}, suppliers[0].IngredientIDs)
+
+	coupon, err := r.FindCoupon(ctx, "FIXTURE")
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, coupon.Value)
+
+	// the next ID continues after the fixtures
+	ingredient := &entities.Ingredient{Name: "Cocoa"}
+	require.NoError(t, r.CreateIngredient(ctx, ingredient))
+	assert.Equal(t, 11, ingredient.ID)
+}
+
+func TestSeedInsertsNothingWhenARowIsRejected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	err = r.Seed(&Fixtures{Coffees: []entities.Coffee{{Name: "Valid"}, {Name: "Invalid", Status: "brewing"}}})
+	assert.Equal(t, ErrInvalidStatus, err)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+}
+
+func TestSeedRejectsRowsWithTakenIDs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	r, err := NewEmptyInMemoryDB()
+	require.NoError(t, err)
+	require.NoError(t, r.Seed(&Fixtures{Ingredients: []entities.Ingredient{{ID: 1, Name: "Espresso"}}}))
+
+	err = r.Seed(&Fixtures{Ingredients: []entities.Ingredient{{ID: 2, Name: "Oat Milk"}, {ID: 1, Name: "Decaf"}}})
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	ingredients, err := r.FindIngredients(ctx)
+	require.NoError(t, err)
+	require.Len(t, ingredients, 1)
+	assert.Equal(t, "Espresso", ingredients[0].Name)
+}
+
+func TestSeedRejectsMenusOfUnknownStores(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewEmptyInMemoryDB()
+	require.NoError(t, err)
+
+	err = r.Seed(&Fixtures{Coffees: []entities.Coffee{{ID: 1, Name: "Espresso"}}, Menus: map[int][]int{1: {1}}})
+	assert.True(t, errors.Is(err, ErrMissingReference))
+
+	coffees, err := r.Find(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, coffees)
+}
@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// maxGeneratedIngredients is the most ingredients a generated coffee has
+const maxGeneratedIngredients = 4
+
+// generatedImage is the image of every generated coffee, which tells them
+// from the rest of the catalogue
+const generatedImage = "/generated.png"
+
+var (
+	generatedStyles   = []string{"Latte", "Cappuccino", "Flat White", "Mocha", "Macchiato", "Cortado", "Americano", "Ristretto"}
+	generatedFlavours = []string{"Caramel", "Hazelnut", "Vanilla", "Cinnamon", "Maple", "Honey", "Coconut", "Toffee"}
+)
+
+// Importer is implemented by repositories creating many coffees at once
+// faster than one by one
+type Importer interface {
+	ImportCoffees(ctx context.Context, coffees entities.Coffees) error
+}
+
+// GenerateCoffees creates count synthetic coffees through the repository for
+// load testing, each made of a random selection of the existing ingredients.
+// The coffees only depend on seed, so two runs with the same seed against the
+// same ingredients generate the same catalogue. They are imported at once
+// when the repository is an Importer.
+func GenerateCoffees(ctx context.Context, r Repository, count int, seed int64) error {
+	coffees, err := generateCoffees(ctx, r, count, seed)
+	if err != nil {
+		return err
+	}
+	return createCoffees(ctx, r, coffees)
+}
+
+// SeedCoffees generates count coffees like GenerateCoffees in one of the
+// SEED_MODE modes, and returns the number of coffees created:
+//
+//   - config.SeedAlways generates them at every start
+//   - config.SeedSkip only generates them when the repository holds no
+//     generated coffee, e.g. on the first start against Postgres
+//   - config.SeedMerge only creates the generated coffees whose slug, their
+//     natural key, is not taken yet, so growing count adds the new ones
+//   - config.SeedForce deletes every generated coffee first
+//
+// Coffees which are not generated are never deleted.
+func SeedCoffees(ctx context.Context, r Repository, count int, seed int64, mode string) (int, error) {
+	coffees, err := generateCoffees(ctx, r, count, seed)
+	if err != nil {
+		return 0, err
+	}
+
+	switch mode {
+	case config.SeedSkip, config.SeedMerge:
+		existing, err := r.Find(ctx)
+		if err != nil {
+			return 0, err
+		}
+		slugs := make(map[string]bool, len(existing))
+		generated := false
+		for _, c := range existing {
+			slugs[c.Slug] = true
+			generated = generated || c.Image == generatedImage
+		}
+		entities.PutCoffees(existing)
+
+		if mode == config.SeedSkip && generated {
+			return 0, nil
+		}
+		if mode == config.SeedMerge {
+			missing := coffees[:0]
+			for _, c := range coffees {
+				if !slugs[Slugify(c.Name)] {
+					missing = append(missing, c)
+				}
+			}
+			coffees = missing
+		}
+	case config.SeedForce:
+		expr, err := filter.Parse(fmt.Sprintf("image=%q", generatedImage))
+		if err != nil {
+			return 0, err
+		}
+		if _, err := DeleteWhere(ctx, r, expr, false); err != nil {
+			return 0, fmt.Errorf("unable to delete generated coffees: %w", err)
+		}
+	}
+
+	if len(coffees) == 0 {
+		return 0, nil
+	}
+	return len(coffees), createCoffees(ctx, r, coffees)
+}
+
+// generateCoffees returns count generated coffees made of the ingredients of
+// the repository
+func generateCoffees(ctx context.Context, r Repository, count int, seed int64) (entities.Coffees, error) {
+	ingredients, err := r.FindIngredients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(ingredients) == 0 {
+		return nil, fmt.Errorf("unable to generate coffees without ingredients")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	coffees := make(entities.Coffees, 0, count)
+	for n := 1; n <= count; n++ {
+		coffees = append(coffees, generateCoffee(rng, n, ingredients))
+	}
+	return coffees, nil
+}
+
+// createCoffees creates generated coffees, at once when the repository is an
+// Importer
+func createCoffees(ctx context.Context, r Repository, coffees entities.Coffees) error {
+	if importer, ok := r.(Importer); ok {
+		if err := importer.ImportCoffees(ctx, coffees); err != nil {
+			return fmt.Errorf("unable to import generated coffees: %w", err)
+		}
+		return nil
+	}
+
+	for n := range coffees {
+		if err := r.CreateCoffee(ctx, &coffees[n]); err != nil {
+			return fmt.Errorf("unable to create generated coffee %s: %w", coffees[n].Name, err)
+		}
+	}
+
+	return nil
+}
+
+// generateCoffee returns the n-th generated coffee. The number is part of the
+// name so generated names never collide.
+func generateCoffee(rng *rand.Rand, n int, ingredients entities.Ingredients) entities.Coffee {
+	flavour := generatedFlavours[rng.Intn(len(generatedFlavours))]
+	style := generatedStyles[rng.Intn(len(generatedStyles))]
+
+	count := 1 + rng.Intn(maxGeneratedIngredients)
+	if count > len(ingredients) {
+		count = len(ingredients)
+	}
+
+	coffee := entities.Coffee{
+		Name:        fmt.Sprintf("%s %s %d", flavour, style, n),
+		Teaser:      fmt.Sprintf("A generated %s with a hint of %s", style, flavour),
+		Price:       float64(100 + 10*rng.Intn(30)),
+		Image:       generatedImage,
+		Status:      StatusPublished,
+		Ingredients: make([]entities.CoffeeIngredients, 0, count),
+	}
+	for _, i := range rng.Perm(len(ingredients))[:count] {
+		coffee.Ingredients = append(coffee.Ingredients, entities.CoffeeIngredients{
+			IngredientID: ingredients[i].ID,
+			Quantity:     5 * (1 + rng.Intn(60)),
+			Unit:         "ml",
+		})
+	}
+
+	return coffee
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestGenerateCoffeesIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+
+	generate := func(seed int64) entities.Coffees {
+		r := setupInMemoryRepository(t)
+		require.NoError(t, GenerateCoffees(ctx, r, 50, seed))
+
+		coffees, err := r.Find(ctx)
+		require.NoError(t, err)
+		require.Len(t, coffees, 56)
+
+		// timestamps differ between runs
+		for i := range coffees {
+			coffees[i].CreatedAt, coffees[i].UpdatedAt = "", ""
+			for n := range coffees[i].Ingredients {
+				coffees[i].Ingredients[n].CreatedAt, coffees[i].Ingredients[n].UpdatedAt = "", ""
+			}
+		}
+		return coffees
+	}
+
+	first := generate(42)
+	assert.Equal(t, first, generate(42))
+	assert.NotEqual(t, first, generate(7))
+
+	for _, coffee := range first[6:] {
+		assert.NotEmpty(t, coffee.Ingredients, coffee.Name)
+		assert.LessOrEqual(t, len(coffee.Ingredients), maxGeneratedIngredients, coffee.Name)
+		for _, ingredient := range coffee.Ingredients {
+			assert.NotEmpty(t, ingredient.Name, coffee.Name)
+			assert.Greater(t, ingredient.Quantity, 0, coffee.Name)
+		}
+	}
+}
+
+func TestSeedCoffeesSkipsWhenGeneratedCoffeesExist(t *testing.T) {
+	ctx := context.Background()
+	r := setupInMemoryRepository(t)
+
+	created, err := SeedCoffees(ctx, r, 10, 42, config.SeedSkip)
+	require.NoError(t, err)
+	assert.Equal(t, 10, created)
+
+	created, err = SeedCoffees(ctx, r, 20, 42, config.SeedSkip)
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, 16)
+}
+
+func TestSeedCoffeesMergesBySlug(t *testing.T) {
+	ctx := context.Background()
+	r := setupInMemoryRepository(t)
+
+	_, err := SeedCoffees(ctx, r, 10, 42, config.SeedMerge)
+	require.NoError(t, err)
+
+	created, err := SeedCoffees(ctx, r, 15, 42, config.SeedMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 5, created)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, 21)
+}
+
+func TestSeedCoffeesForceRegeneratesOnlyGeneratedCoffees(t *testing.T) {
+	ctx := context.Background()
+	r := setupInMemoryRepository(t)
+
+	_, err := SeedCoffees(ctx, r, 10, 42, config.SeedAlways)
+	require.NoError(t, err)
+
+	created, err := SeedCoffees(ctx, r, 5, 7, config.SeedForce)
+	require.NoError(t, err)
+	assert.Equal(t, 5, created)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, coffees, 11)
+	for _, coffee := range coffees[:6] {
+		assert.NotEqual(t, generatedImage, coffee.Image, coffee.Name)
+	}
+	for _, coffee := range coffees[6:] {
+		assert.Equal(t, generatedImage, coffee.Image, coffee.Name)
+	}
+}
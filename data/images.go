@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// The sizes of the image of a coffee
+const (
+	ImageThumb  = "thumb"
+	ImageMedium = "medium"
+	ImageFull   = "full"
+)
+
+// ErrImagesUnsupported is returned when managing the images of a repository
+// which does not store them
+var ErrImagesUnsupported = errors.New("images are not supported by this backend")
+
+// ErrInvalidImage is returned for an image of an unknown size, without a URL
+// or without dimensions
+var ErrInvalidImage = errors.New("invalid image")
+
+// ImageStore is implemented by repositories storing the sizes of the coffee
+// images, keyed by coffee and size
+type ImageStore interface {
+	// FindImages returns the images of the coffees in coffeeIDs
+	FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error)
+	// SetImage creates or replaces a size of the image of a coffee,
+	// ErrNotFound when the coffee does not exist. Setting the full image
+	// removes the other sizes, which were resized from the previous one.
+	SetImage(ctx context.Context, image *entities.CoffeeImage) error
+}
+
+// FindImages returns the images of the coffees in coffeeIDs from an
+// ImageStore, or ErrImagesUnsupported
+func FindImages(ctx context.Context, r Repository, coffeeIDs []int) (entities.CoffeeImages, error) {
+	var store interface {
+		FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error)
+	}
+	if !As(r, &store) {
+		return nil, ErrImagesUnsupported
+	}
+	return store.FindImages(ctx, coffeeIDs)
+}
+
+// SetImage validates an image and stores it in an ImageStore
+func SetImage(ctx context.Context, r Repository, image *entities.CoffeeImage) error {
+	var store interface {
+		SetImage(ctx context.Context, image *entities.CoffeeImage) error
+	}
+	if !As(r, &store) {
+		return ErrImagesUnsupported
+	}
+
+	if !imageSize(image.Size) || image.URL == "" || image.Width <= 0 || image.Height <= 0 {
+		return ErrInvalidImage
+	}
+	return store.SetImage(ctx, image)
+}
+
+// AttachImages sets the Images of the coffees which have any. Repositories
+// without images leave coffees unchanged.
+func AttachImages(ctx context.Context, r Repository, coffees entities.Coffees) error {
+	var store interface {
+		FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error)
+	}
+	if !As(r, &store) || len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(coffees))
+	for n := range coffees {
+		ids[n] = coffees[n].ID
+	}
+	images, err := store.FindImages(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byCoffee := map[int]entities.CoffeeImages{}
+	for _, image := range images {
+		byCoffee[image.CoffeeID] = append(byCoffee[image.CoffeeID], image)
+	}
+	for n := range coffees {
+		if images, ok := byCoffee[coffees[n].ID]; ok {
+			coffees[n].Images = ImageSet(images)
+		}
+	}
+	return nil
+}
+
+// ImageSet returns the URLs of the images of a coffee, with a srcset listing
+// them from the narrowest to the widest
+func ImageSet(images entities.CoffeeImages) *entities.CoffeeImageSet {
+	images = append(entities.CoffeeImages{}, images...)
+	sort.SliceStable(images, func(i, j int) bool { return images[i].Width < images[j].Width })
+
+	set := &entities.CoffeeImageSet{}
+	candidates := make([]string, 0, len(images))
+	for _, image := range images {
+		switch image.Size {
+		case ImageThumb:
+			set.Thumb = image.URL
+		case ImageMedium:
+			set.Medium = image.URL
+		case ImageFull:
+			set.Full = image.URL
+		}
+		candidates = append(candidates, image.URL+" "+strconv.Itoa(image.Width)+"w")
+	}
+	set.Srcset = strings.Join(candidates, ", ")
+	return set
+}
+
+// imageSize reports whether size is a size of a coffee image
+func imageSize(size string) bool {
+	return size == ImageThumb || size == ImageMedium || size == ImageFull
+}
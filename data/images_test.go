@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testImages verifies a Repository holding the seed data stores the sizes of
+// the coffee images and attaches them to coffees
+func testImages(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	for _, image := range []entities.CoffeeImage{
+		{CoffeeID: 1, Size: ImageFull, URL: "/images/coffees/1/a-full.png", Width: 1200, Height: 900},
+		{CoffeeID: 1, Size: ImageThumb, URL: "/images/coffees/1/a-thumb.png", Width: 160, Height: 120},
+		{CoffeeID: 1, Size: ImageMedium, URL: "/images/coffees/1/a-medium.png", Width: 480, Height: 360},
+		{CoffeeID: 2, Size: ImageFull, URL: "/images/coffees/2/b-full.jpg", Width: 300, Height: 300},
+	} {
+		image := image
+		require.NoError(t, SetImage(ctx, r, &image))
+		assert.NotEmpty(t, image.UpdatedAt)
+	}
+
+	assert.Equal(t, ErrNotFound, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: 42, Size: ImageFull, URL: "/missing.png", Width: 1, Height: 1}))
+	assert.Equal(t, ErrInvalidImage, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: 1, Size: "huge", URL: "/huge.png", Width: 1, Height: 1}))
+	assert.Equal(t, ErrInvalidImage, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: 1, Size: ImageThumb, URL: "/thumb.png"}))
+
+	images, err := FindImages(ctx, r, []int{1, 2})
+	require.NoError(t, err)
+	assert.Len(t, images, 4)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	defer entities.PutCoffees(coffees)
+	require.NoError(t, AttachImages(ctx, r, coffees))
+	assert.Equal(t, &entities.CoffeeImageSet{
+		Srcset: "/images/coffees/1/a-thumb.png 160w, /images/coffees/1/a-medium.png 480w, /images/coffees/1/a-full.png 1200w",
+		Thumb:  "/images/coffees/1/a-thumb.png",
+		Medium: "/images/coffees/1/a-medium.png",
+		Full:   "/images/coffees/1/a-full.png",
+	}, coffees[0].Images)
+	assert.Equal(t, &entities.CoffeeImageSet{Srcset: "/images/coffees/2/b-full.jpg 300w", Full: "/images/coffees/2/b-full.jpg"}, coffees[1].Images)
+	assert.Nil(t, coffees[2].Images)
+
+	// a new full image drops the variants of the previous one
+	require.NoError(t, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: 1, Size: ImageFull, URL: "/images/coffees/1/c-full.png", Width: 800, Height: 600}))
+	images, err = FindImages(ctx, r, []int{1})
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, "/images/coffees/1/c-full.png", images[0].URL)
+
+	// images are deleted with their coffee
+	require.NoError(t, r.DeleteCoffee(ctx, 2))
+	images, err = FindImages(ctx, r, []int{2})
+	require.NoError(t, err)
+	assert.Empty(t, images)
+}
+
+func TestInMemoryImages(t *testing.T) {
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	testImages(t, r)
+}
+
+func TestImagesPassThroughWrappers(t *testing.T) {
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	testImages(t, NewRetrying(NewChanges(r, 10), RetryingOptions{}))
+}
+
+// imageless hides the ImageStore of a repository
+type imageless struct {
+	Repository
+}
+
+func TestImagesNeedAnImageStore(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	image := &entities.CoffeeImage{CoffeeID: 1, Size: ImageFull, URL: "/images/coffees/1/a-full.png", Width: 1, Height: 1}
+	assert.Equal(t, ErrImagesUnsupported, SetImage(ctx, imageless{r}, image))
+	_, err = FindImages(ctx, imageless{r}, []int{1})
+	assert.Equal(t, ErrImagesUnsupported, err)
+
+	// coffees are left without images
+	require.NoError(t, SetImage(ctx, r, image))
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	defer entities.PutCoffees(coffees)
+	require.NoError(t, AttachImages(ctx, imageless{r}, coffees))
+	assert.Nil(t, coffees[0].Images)
+}
@@ -1,152 +1,1802 @@
 package data
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-memdb"
 
 	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
 )
 
-// TableNameKey is a typesafe discriminator for table names
+//go:generate go run ./internal/schemagen -spec schema.hcl
+
+// TableNameKey is a typesafe discriminator for table names, the constants of
+// the tables are generated from schema.hcl
 type TableNameKey string
 
-func (t TableNameKey) String() string {
-	return string(t)
+func (t TableNameKey) String() string {
+	return string(t)
+}
+
+// InMemoryRepository implements the coffee-service.data.Repository interface
+// uisng go-membdb instead of postgres.
+type InMemoryRepository struct {
+	db        *memdb.MemDB
+	config    *config.Config
+	sequences sequences
+	// pins keeps the snapshots of ReadSnapshots, nil when SNAPSHOT_TTL
+	// disables them
+	pins *snapshotPins
+	// slugTakenElsewhere reports whether a slug belongs to a coffee of
+	// another instance, the other shards of a ShardedRepository, nil for a
+	// standalone instance
+	slugTakenElsewhere func(ctx context.Context, slug string, coffeeID int) (bool, error)
+}
+
+// sequences hands out IDs per table the way Postgres SERIAL columns do, IDs
+// are never reused even after a delete or an aborted transaction.
+type sequences struct {
+	mu   sync.Mutex
+	last map[TableNameKey]int
+}
+
+// next returns the next ID of a table
+func (s *sequences) next(table TableNameKey) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		s.last = map[TableNameKey]int{}
+	}
+	s.last[table]++
+	return s.last[table]
+}
+
+// observe moves the sequence of a table past an ID inserted explicitly
+func (s *sequences) observe(table TableNameKey, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		s.last = map[TableNameKey]int{}
+	}
+	if id > s.last[table] {
+		s.last[table] = id
+	}
+}
+
+func init() {
+	RegisterBackend(config.MemoryBackend, newMemoryBackend)
+}
+
+// newMemoryBackend creates the memory backend, partitioned across
+// MEMORY_SHARDS instances when there are more than one
+func newMemoryBackend(cfg *config.Config) (Repository, error) {
+	if cfg.MemoryShards > 1 {
+		cfg.Logger.Debug("Loading sharded in memory db", "shards", cfg.MemoryShards)
+		sharded, err := NewSharded(cfg, cfg.MemoryShards)
+		if err != nil {
+			return nil, err
+		}
+		return sharded, nil
+	}
+
+	return NewInMemoryDB(cfg)
+}
+
+// NewInMemoryDB is the InMemoryRepository factory method. It fulfills the same
+// interface as Repository, but uses go-membdb internally to provide data.
+func NewInMemoryDB(config *config.Config) (Repository, error) {
+	repository, err := newInMemoryDB(config)
+	if err != nil {
+		return &InMemoryRepository{}, err
+	}
+
+	if err := repository.load(); err != nil {
+		return &InMemoryRepository{}, err
+	}
+	return repository, nil
+}
+
+// newInMemoryDB creates an InMemoryRepository holding no rows
+func newInMemoryDB(config *config.Config) (*InMemoryRepository, error) {
+	config.Logger.Debug("Attempting to load in memory db")
+	// Create a new data base
+	db, err := memdb.NewMemDB(createSchema())
+	if err != nil {
+		config.Logger.Debug(fmt.Sprintf("Failed to load in membory database with err %+v", err))
+		return nil, err
+	}
+
+	repository := &InMemoryRepository{db: db, config: config}
+	if config.SnapshotTTL > 0 {
+		repository.pins = newSnapshotPins(config.SnapshotTTL)
+	}
+	return repository, nil
+}
+
+// load inserts the static catalogue
+func (r *InMemoryRepository) load() error {
+	r.config.Logger.Debug("Loading Ingredients")
+	if err := r.loadIngredients(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load ingredients with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Loading coffees")
+	if err := r.loadCoffees(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load coffees with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Loading coffee ingredients")
+	if err := r.loadCoffeeIngredients(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load coffee ingredients with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Loading stores")
+	if err := r.loadStores(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load stores with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Loading suppliers")
+	if err := r.loadSuppliers(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load suppliers with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Loading coupons")
+	if err := r.loadCoupons(); err != nil {
+		r.config.Logger.Debug(fmt.Sprintf("Failed to load coupons with err %+v", err))
+		return err
+	}
+
+	r.config.Logger.Debug("Data loaded")
+	return nil
+}
+
+// IsConnected always succeeds once the in memory database has been loaded
+func (r *InMemoryRepository) IsConnected(ctx context.Context) (bool, error) {
+	return r.db != nil, nil
+}
+
+// Find returns all coffees from the database
+func (r *InMemoryRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	txn, err := r.readTxn(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	iter, err := r.get(ctx, txn, Coffee, "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load coffees", err)
+		return nil, err
+	}
+
+	coffees := entities.GetCoffees()
+
+	for coffee := iter.Next(); coffee != nil; coffee = iter.Next() {
+		coffees = append(coffees, *coffee.(*entities.Coffee))
+	}
+
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return coffees, nil
+}
+
+// FindByID returns a single coffee
+func (r *InMemoryRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindByID failed to load coffee", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	coffees := entities.Coffees{*raw.(*entities.Coffee)}
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindByID failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return &coffees[0], nil
+}
+
+// FindBySlug returns the coffee with the slug
+func (r *InMemoryRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "slug", slug)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindBySlug failed to load coffee", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	coffees := entities.Coffees{*raw.(*entities.Coffee)}
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindBySlug failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return &coffees[0], nil
+}
+
+// FindRelated returns up to limit coffees sharing the most ingredients with
+// coffeeID, ranked by the Jaccard similarity of their ingredient sets.
+func (r *InMemoryRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	source, err := r.first(ctx, txn, Coffee, "id", coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindRelated failed to load coffee", "error", err)
+		return nil, err
+	}
+	if source == nil {
+		return nil, ErrNotFound
+	}
+
+	ingredientsByCoffee, err := r.candidateIngredients(ctx, txn, coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindRelated failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	ranked := rankRelated(coffeeID, ingredientsByCoffee)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	coffees := make(entities.Coffees, 0, len(ranked))
+	for _, related := range ranked {
+		raw, err := r.first(ctx, txn, Coffee, "id", related.ID)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			continue
+		}
+
+		coffees = append(coffees, *raw.(*entities.Coffee))
+	}
+
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindRelated failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return coffees, nil
+}
+
+// FindWhere returns the coffees matching the filter expression. A top level
+// id=N or name=X comparison is served from the matching index rather than a
+// table scan.
+func (r *InMemoryRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	txn, err := r.readTxn(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	index, args := indexedLookup(expr)
+	iter, err := r.get(ctx, txn, Coffee, index, args...)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindWhere failed to load coffees", "error", err)
+		return nil, err
+	}
+
+	coffees := entities.GetCoffees()
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coffee := row.(*entities.Coffee)
+		if filter.Match(expr, coffee) {
+			coffees = append(coffees, *coffee)
+		}
+	}
+
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindWhere failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return coffees, nil
+}
+
+// FindSimilar returns the coffees whose name has a NameSimilarity of at least
+// threshold to name, most similar first. Only the coffees sharing a trigram
+// with name are compared, which are all the ones a positive threshold can
+// match.
+func (r *InMemoryRepository) FindSimilar(ctx context.Context, name string, threshold float64) (entities.Coffees, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	candidates := map[int]*entities.Coffee{}
+	for trigram := range nameTrigrams(name) {
+		iter, err := r.get(ctx, txn, Coffee, "name_trigram", trigram)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindSimilar failed to load coffees", "error", err)
+			return nil, err
+		}
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			coffee := row.(*entities.Coffee)
+			candidates[coffee.ID] = coffee
+		}
+	}
+
+	coffees := entities.GetCoffees()
+	for _, coffee := range candidates {
+		if NameSimilarity(name, coffee.Name) >= threshold {
+			coffees = append(coffees, *coffee)
+		}
+	}
+	sortBySimilarity(name, coffees)
+
+	if err := r.hydrate(ctx, txn, coffees); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindSimilar failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	return coffees, nil
+}
+
+// indexedLookup returns the coffee index and arguments narrowing the rows an
+// expression can match. A top level id=N comparison selects the id index, a
+// top level name=X comparison the name index and a top level status=X one the
+// status index, anything else scans every row.
+func indexedLookup(expr filter.Expr) (string, []interface{}) {
+	var name, status *filter.Comparison
+	for _, conjunct := range filter.Conjuncts(expr) {
+		c, ok := conjunct.(*filter.Comparison)
+		if !ok || c.Op != filter.Eq {
+			continue
+		}
+
+		switch c.Field.Name {
+		case "id":
+			id := c.Value.(float64)
+			if id == float64(int(id)) {
+				return "id", []interface{}{int(id)}
+			}
+		case "name":
+			name = c
+		case "status":
+			status = c
+		}
+	}
+
+	if name != nil {
+		return "name", []interface{}{name.Value}
+	}
+	if status != nil {
+		return "status", []interface{}{status.Value}
+	}
+
+	return "id", nil
+}
+
+// CreateCoffee inserts a coffee and its ingredients, assigning the next
+// coffee ID and the timestamps
+func (r *InMemoryRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	return r.createCoffee(ctx, coffee, r.sequences.next(Coffee), "")
+}
+
+// createCoffee inserts a coffee with an ID assigned by the caller, e.g. the
+// router of a ShardedRepository. The slug is generated from the name unless
+// the caller restores one.
+func (r *InMemoryRepository) createCoffee(ctx context.Context, coffee *entities.Coffee, id int, slug string) error {
+	if err := coffee.Validate(); err != nil {
+		return err
+	}
+
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	r.sequences.observe(Coffee, id)
+	timestamp := time.Now().String()
+	row := *coffee
+	row.ID = id
+	row.CreatedAt = timestamp
+	row.UpdatedAt = timestamp
+	row.Ingredients = nil
+	row.Stats = nil
+
+	var err error
+	if row.Status, err = initialStatus(row.Status); err != nil {
+		return err
+	}
+
+	row.Slug = slug
+	if row.Slug == "" {
+		if row.Slug, err = slugFor(nil, row.Name, r.slugTaken(ctx, txn, id)); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateCoffee failed to load slugs", "error", err)
+			return err
+		}
+	}
+
+	if err := r.create(ctx, txn, Coffee, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateCoffee failed to insert coffee", "error", err)
+		return err
+	}
+
+	ingredients, err := r.replaceCoffeeIngredients(ctx, txn, row.ID, coffee.Ingredients, timestamp)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateCoffee failed to insert ingredients", "error", err)
+		return err
+	}
+
+	txn.Commit()
+
+	*coffee = row
+	coffee.Ingredients = ingredients
+	return nil
+}
+
+// UpdateCoffee replaces the attributes and ingredients of an existing coffee
+func (r *InMemoryRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := coffee.Validate(); err != nil {
+		return err
+	}
+
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", coffee.ID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoffee failed to load coffee", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	timestamp := time.Now().String()
+	row := *coffee
+	row.CreatedAt = raw.(*entities.Coffee).CreatedAt
+	row.UpdatedAt = timestamp
+	row.Ingredients = nil
+	row.Stats = nil
+
+	if row.Status, err = nextStatus(raw.(*entities.Coffee).Status, row.Status); err != nil {
+		return err
+	}
+	if row.Slug, err = slugFor(raw.(*entities.Coffee), row.Name, r.slugTaken(ctx, txn, row.ID)); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoffee failed to load slugs", "error", err)
+		return err
+	}
+
+	if err := r.insert(ctx, txn, Coffee, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoffee failed to update coffee", "error", err)
+		return err
+	}
+
+	ingredients, err := r.replaceCoffeeIngredients(ctx, txn, row.ID, coffee.Ingredients, timestamp)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoffee failed to update ingredients", "error", err)
+		return err
+	}
+
+	txn.Commit()
+
+	*coffee = row
+	coffee.Ingredients = ingredients
+	return nil
+}
+
+// DeleteCoffee removes a coffee and its ingredients
+func (r *InMemoryRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteCoffee failed to load coffee", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	if err := r.deleteReferencing(ctx, txn, Coffee, coffeeID); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteCoffee failed to delete referencing rows", "error", err)
+		return err
+	}
+	if err := r.delete(ctx, txn, Coffee, raw); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteCoffee failed to delete coffee", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// DeleteWhere deletes the coffees matching expr and their ingredients in one
+// transaction, which a dry run aborts
+func (r *InMemoryRepository) DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) ([]int, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	index, args := indexedLookup(expr)
+	iter, err := r.get(ctx, txn, Coffee, index, args...)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteWhere failed to load coffees", "error", err)
+		return nil, err
+	}
+
+	// collected first, deleting invalidates the iterator
+	matched := []*entities.Coffee{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		if coffee := row.(*entities.Coffee); filter.Match(expr, coffee) {
+			matched = append(matched, coffee)
+		}
+	}
+
+	ids := make([]int, 0, len(matched))
+	for _, coffee := range matched {
+		ids = append(ids, coffee.ID)
+		if dryRun {
+			continue
+		}
+
+		if err := r.deleteReferencing(ctx, txn, Coffee, coffee.ID); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteWhere failed to delete referencing rows", "error", err)
+			return nil, err
+		}
+		if err := r.delete(ctx, txn, Coffee, coffee); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteWhere failed to delete coffee", "error", err)
+			return nil, err
+		}
+	}
+
+	if !dryRun {
+		txn.Commit()
+	}
+	return ids, nil
+}
+
+// FindTranslations returns the translations of the coffees in coffeeIDs into
+// locales, or into every locale when locales is empty. Like hydrate it looks
+// up a few coffees through the coffee_id index and scans the table for more.
+func (r *InMemoryRepository) FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	wanted := make(map[string]bool, len(locales))
+	for _, locale := range locales {
+		wanted[locale] = true
+	}
+	translations := entities.Translations{}
+	collect := func(iter memdb.ResultIterator, coffees map[int]bool) {
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			t := row.(*entities.Translation)
+			if (len(wanted) == 0 || wanted[t.Locale]) && (coffees == nil || coffees[t.CoffeeID]) {
+				translations = append(translations, *t)
+			}
+		}
+	}
+
+	if len(coffeeIDs) > indexedHydrationLimit {
+		coffees := make(map[int]bool, len(coffeeIDs))
+		for _, id := range coffeeIDs {
+			coffees[id] = true
+		}
+		iter, err := r.get(ctx, txn, CoffeeTranslation, "id")
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindTranslations failed to load translations", "error", err)
+			return nil, err
+		}
+		collect(iter, coffees)
+		return translations, nil
+	}
+
+	for _, id := range coffeeIDs {
+		iter, err := r.get(ctx, txn, CoffeeTranslation, "coffee_id", id)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindTranslations failed to load translations", "error", err)
+			return nil, err
+		}
+		collect(iter, nil)
+	}
+	return translations, nil
+}
+
+// SetTranslation creates or replaces the translation of a coffee field
+func (r *InMemoryRepository) SetTranslation(ctx context.Context, translation *entities.Translation) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", translation.CoffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetTranslation failed to load coffee", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	row := *translation
+	row.UpdatedAt = time.Now().String()
+	if err := r.insert(ctx, txn, CoffeeTranslation, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetTranslation failed to insert translation", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	*translation = row
+	return nil
+}
+
+// DeleteTranslation removes the translation of a coffee field
+func (r *InMemoryRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, CoffeeTranslation, "id", coffeeID, locale, field)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteTranslation failed to load translation", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+	if err := r.delete(ctx, txn, CoffeeTranslation, raw); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteTranslation failed to delete translation", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// FindImages returns the images of the coffees in coffeeIDs, looked up through
+// the coffee_id index
+func (r *InMemoryRepository) FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	images := entities.CoffeeImages{}
+	for _, id := range coffeeIDs {
+		iter, err := r.get(ctx, txn, CoffeeImage, "coffee_id", id)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindImages failed to load images", "error", err)
+			return nil, err
+		}
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			images = append(images, *row.(*entities.CoffeeImage))
+		}
+	}
+	return images, nil
+}
+
+// SetImage creates or replaces a size of the image of a coffee, the full image
+// replaces every size
+func (r *InMemoryRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", image.CoffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetImage failed to load coffee", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	if image.Size == ImageFull {
+		if err := r.deleteAll(ctx, txn, CoffeeImage, "coffee_id", image.CoffeeID); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetImage failed to delete images", "error", err)
+			return err
+		}
+	}
+	row := *image
+	row.UpdatedAt = time.Now().String()
+	if err := r.insert(ctx, txn, CoffeeImage, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetImage failed to insert image", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	*image = row
+	return nil
+}
+
+// FindAvailability returns the availability rules of the coffees in coffeeIDs
+func (r *InMemoryRepository) FindAvailability(ctx context.Context, coffeeIDs []int) (entities.AvailabilityRules, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	rules := entities.AvailabilityRules{}
+	for _, id := range coffeeIDs {
+		iter, err := r.get(ctx, txn, CoffeeAvailability, "coffee_id", id)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindAvailability failed to load availability", "error", err)
+			return nil, err
+		}
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			rules = append(rules, *row.(*entities.AvailabilityRule))
+		}
+	}
+	return rules, nil
+}
+
+// SetAvailability replaces the availability rules of a coffee
+func (r *InMemoryRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coffee, "id", coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetAvailability failed to load coffee", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	if err := r.deleteAll(ctx, txn, CoffeeAvailability, "coffee_id", coffeeID); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetAvailability failed to delete availability", "error", err)
+		return err
+	}
+	for n := range rules {
+		rules[n].ID = r.sequences.next(CoffeeAvailability)
+		rules[n].CoffeeID = coffeeID
+		row := rules[n]
+		row.Days = append([]string(nil), row.Days...)
+		if err := r.insert(ctx, txn, CoffeeAvailability, &row); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.SetAvailability failed to insert availability", "error", err)
+			return err
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// FindStores returns every store
+func (r *InMemoryRepository) FindStores(ctx context.Context) (entities.Stores, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	iter, err := r.get(ctx, txn, Store, "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindStores failed to load stores", "error", err)
+		return nil, err
+	}
+
+	stores := entities.Stores{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		stores = append(stores, *row.(*entities.Store))
+	}
+	return stores, nil
+}
+
+// FindStore returns a store, or ErrNotFound
+func (r *InMemoryRepository) FindStore(ctx context.Context, storeID int) (*entities.Store, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Store, "id", storeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindStore failed to load store", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	store := *raw.(*entities.Store)
+	return &store, nil
+}
+
+// FindStoreCoffees returns the IDs of the coffees on the menu of a store
+func (r *InMemoryRepository) FindStoreCoffees(ctx context.Context, storeID int) ([]int, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Store, "id", storeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindStoreCoffees failed to load store", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	iter, err := r.get(ctx, txn, StoreCoffee, "store_id", storeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindStoreCoffees failed to load store menu", "error", err)
+		return nil, err
+	}
+
+	ids := []int{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ids = append(ids, row.(*entities.StoreCoffee).CoffeeID)
+	}
+	return ids, nil
+}
+
+// FindStoresNear returns the stores within radiusKm of a point, nearest
+// first, measuring every store with the haversine formula
+func (r *InMemoryRepository) FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (entities.Stores, error) {
+	stores, err := r.FindStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nearest(stores, lat, lon, radiusKm), nil
+}
+
+// FindSuppliers returns every supplier with the ingredients it supplies
+func (r *InMemoryRepository) FindSuppliers(ctx context.Context) (entities.Suppliers, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	iter, err := r.get(ctx, txn, Supplier, "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindSuppliers failed to load suppliers", "error", err)
+		return nil, err
+	}
+
+	suppliers := entities.Suppliers{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		suppliers = append(suppliers, *row.(*entities.Supplier))
+	}
+	for n := range suppliers {
+		if err := r.supplied(ctx, txn, &suppliers[n]); err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindSuppliers failed to load supplied ingredients", "error", err)
+			return nil, err
+		}
+	}
+	return suppliers, nil
+}
+
+// FindIngredientSupplier returns the supplier of an ingredient, or ErrNotFound
+func (r *InMemoryRepository) FindIngredientSupplier(ctx context.Context, ingredientID int) (*entities.Supplier, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	link, err := r.first(ctx, txn, IngredientSupplier, "id", ingredientID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredientSupplier failed to load ingredient supplier", "error", err)
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrNotFound
+	}
+
+	raw, err := r.first(ctx, txn, Supplier, "id", link.(*entities.IngredientSupplier).SupplierID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredientSupplier failed to load supplier", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	supplier := *raw.(*entities.Supplier)
+	if err := r.supplied(ctx, txn, &supplier); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredientSupplier failed to load supplied ingredients", "error", err)
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// supplied sets the ingredients of a supplier, copying its certifications so
+// that callers never share the slice of the stored row
+func (r *InMemoryRepository) supplied(ctx context.Context, txn *memdb.Txn, supplier *entities.Supplier) error {
+	iter, err := r.get(ctx, txn, IngredientSupplier, "supplier_id", supplier.ID)
+	if err != nil {
+		return err
+	}
+
+	supplier.Certifications = append([]string{}, supplier.Certifications...)
+	supplier.IngredientIDs = []int{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		supplier.IngredientIDs = append(supplier.IngredientIDs, row.(*entities.IngredientSupplier).IngredientID)
+	}
+	return nil
+}
+
+// FindCoupons returns every coupon, ordered by code
+func (r *InMemoryRepository) FindCoupons(ctx context.Context) (entities.Coupons, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	iter, err := r.get(ctx, txn, Coupon, "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindCoupons failed to load coupons", "error", err)
+		return nil, err
+	}
+
+	coupons := entities.Coupons{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coupons = append(coupons, copyCoupon(row.(*entities.Coupon)))
+	}
+	return coupons, nil
+}
+
+// FindCoupon returns a coupon, or ErrNotFound
+func (r *InMemoryRepository) FindCoupon(ctx context.Context, code string) (*entities.Coupon, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coupon, "id", code)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindCoupon failed to load coupon", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	coupon := copyCoupon(raw.(*entities.Coupon))
+	return &coupon, nil
+}
+
+// CreateCoupon inserts a coupon
+func (r *InMemoryRepository) CreateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coupon, "id", coupon.Code)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateCoupon failed to load coupon", "error", err)
+		return err
+	}
+	if raw != nil {
+		return ErrCouponExists
+	}
+
+	row := copyCoupon(coupon)
+	if row.UUID, err = entityUUID(r.config.EntityIDs); err != nil {
+		return err
+	}
+	row.CreatedAt = time.Now().String()
+	row.UpdatedAt = row.CreatedAt
+	if err := r.create(ctx, txn, Coupon, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateCoupon failed to insert coupon", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	*coupon = copyCoupon(&row)
+	return nil
+}
+
+// UpdateCoupon replaces a coupon, keeping its uses
+func (r *InMemoryRepository) UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coupon, "id", coupon.Code)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoupon failed to load coupon", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	row := copyCoupon(coupon)
+	row.UUID = raw.(*entities.Coupon).UUID
+	row.Used = raw.(*entities.Coupon).Used
+	row.CreatedAt = raw.(*entities.Coupon).CreatedAt
+	row.UpdatedAt = time.Now().String()
+	if err := r.insert(ctx, txn, Coupon, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateCoupon failed to update coupon", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	*coupon = copyCoupon(&row)
+	return nil
+}
+
+// DeleteCoupon removes a coupon
+func (r *InMemoryRepository) DeleteCoupon(ctx context.Context, code string) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coupon, "id", code)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteCoupon failed to load coupon", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+	if err := r.delete(ctx, txn, Coupon, raw); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteCoupon failed to delete coupon", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// RedeemCoupon counts a use of a coupon. Write transactions are serialized,
+// so the check and the count are atomic.
+func (r *InMemoryRepository) RedeemCoupon(ctx context.Context, code string, t time.Time) (*entities.Coupon, error) {
+	return r.countCouponUse(ctx, code, 1, func(coupon *entities.Coupon) error {
+		return redeemable(coupon, t)
+	})
+}
+
+// ReleaseCoupon gives back a use of a coupon
+func (r *InMemoryRepository) ReleaseCoupon(ctx context.Context, code string) error {
+	_, err := r.countCouponUse(ctx, code, -1, func(coupon *entities.Coupon) error { return nil })
+	return err
+}
+
+// countCouponUse adds delta to the uses of a coupon once check allows it,
+// never counting below 0
+func (r *InMemoryRepository) countCouponUse(ctx context.Context, code string, delta int, check func(*entities.Coupon) error) (*entities.Coupon, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Coupon, "id", code)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.countCouponUse failed to load coupon", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	row := copyCoupon(raw.(*entities.Coupon))
+	if err := check(&row); err != nil {
+		return nil, err
+	}
+
+	if row.Used += delta; row.Used < 0 {
+		row.Used = 0
+	}
+	row.UpdatedAt = time.Now().String()
+	if err := r.insert(ctx, txn, Coupon, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.countCouponUse failed to update coupon", "error", err)
+		return nil, err
+	}
+
+	txn.Commit()
+	coupon := copyCoupon(&row)
+	return &coupon, nil
+}
+
+// copyCoupon copies a coupon so that callers never share the expiry of the
+// stored row
+func copyCoupon(coupon *entities.Coupon) entities.Coupon {
+	c := *coupon
+	if c.ExpiresAt != nil {
+		expiresAt := *c.ExpiresAt
+		c.ExpiresAt = &expiresAt
+	}
+	return c
+}
+
+// FindPoints returns the balance and the ledger of a user, oldest entry
+// first
+func (r *InMemoryRepository) FindPoints(ctx context.Context, userID int) (*entities.Points, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	points, err := r.points(ctx, txn, userID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindPoints failed to load points", "error", err)
+		return nil, err
+	}
+	return points, nil
+}
+
+// RecordPoints appends an entry to the ledger of a user. Write transactions
+// are serialized, so the balance checked is the balance updated.
+func (r *InMemoryRepository) RecordPoints(ctx context.Context, entry *entities.PointsEntry) (int, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	balance, err := r.recordPoints(ctx, txn, entry)
+	if err != nil {
+		return 0, err
+	}
+
+	txn.Commit()
+	return balance, nil
+}
+
+// recordPoints appends an entry to the ledger of a user within txn, returning
+// the new balance
+func (r *InMemoryRepository) recordPoints(ctx context.Context, txn *memdb.Txn, entry *entities.PointsEntry) (int, error) {
+	points, err := r.points(ctx, txn, entry.UserID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.RecordPoints failed to load points", "error", err)
+		return 0, err
+	}
+	if points.Balance+entry.Points < 0 {
+		return 0, ErrInsufficientPoints
+	}
+
+	if entry.UUID, err = entityUUID(r.config.EntityIDs); err != nil {
+		return 0, err
+	}
+	entry.ID = r.sequences.next(PointsEntry)
+	entry.CreatedAt = time.Now().UTC()
+	row := *entry
+	if err := r.create(ctx, txn, PointsEntry, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.RecordPoints failed to insert points", "error", err)
+		return 0, err
+	}
+	return points.Balance + entry.Points, nil
+}
+
+// points sums the ledger of a user
+func (r *InMemoryRepository) points(ctx context.Context, txn *memdb.Txn, userID int) (*entities.Points, error) {
+	iter, err := r.get(ctx, txn, PointsEntry, "user_id", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	points := &entities.Points{UserID: userID, Ledger: []entities.PointsEntry{}}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		entry := *row.(*entities.PointsEntry)
+		points.Balance += entry.Points
+		points.Ledger = append(points.Ledger, entry)
+	}
+	sort.Slice(points.Ledger, func(i, j int) bool { return points.Ledger[i].ID < points.Ledger[j].ID })
+	return points, nil
+}
+
+// FindUser returns the profile of a user, or ErrNotFound
+func (r *InMemoryRepository) FindUser(ctx context.Context, subject string) (*entities.User, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, User, "id", subject)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindUser failed to load user", "error", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	user := copyUser(raw.(*entities.User))
+	return &user, nil
+}
+
+// SaveUser inserts or replaces the profile of a user
+func (r *InMemoryRepository) SaveUser(ctx context.Context, user *entities.User) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, User, "id", user.Subject)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SaveUser failed to load user", "error", err)
+		return err
+	}
+
+	row := copyUser(user)
+	row.UpdatedAt = time.Now().String()
+	row.CreatedAt = row.UpdatedAt
+	if raw != nil {
+		row.CreatedAt = raw.(*entities.User).CreatedAt
+	}
+	if err := r.insert(ctx, txn, User, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SaveUser failed to save user", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	*user = copyUser(&row)
+	return nil
+}
+
+// copyUser copies a profile so that callers never share the default store of
+// the stored row
+func copyUser(user *entities.User) entities.User {
+	u := *user
+	if u.DefaultStoreID != nil {
+		storeID := *u.DefaultStoreID
+		u.DefaultStoreID = &storeID
+	}
+	return u
+}
+
+// RecordOrder inserts an order record, replacing the record of the same order
+func (r *InMemoryRepository) RecordOrder(ctx context.Context, order *entities.OrderRecord) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	if err := r.recordOrder(ctx, txn, order); err != nil {
+		return err
+	}
+
+	txn.Commit()
+	return nil
 }
 
-const (
-	// Ingredient is the ingredient table name
-	Ingredient TableNameKey = "ingredient"
-	// Coffee is the coffee table name
-	Coffee TableNameKey = "coffee"
-	// CoffeeIngredient is the coffee_ingredient table name
-	CoffeeIngredient TableNameKey = "coffee_ingredient"
-)
+// RecordOrderPoints records an order and appends its points entries in one
+// transaction
+func (r *InMemoryRepository) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
 
-// InMemoryRepository implements the coffee-service.data.Repository interface
-// uisng go-membdb instead of postgres.
-type InMemoryRepository struct {
-	db     *memdb.MemDB
-	config *config.Config
+	for _, entry := range entries {
+		if _, err := r.recordPoints(ctx, txn, entry); err != nil {
+			return err
+		}
+	}
+	if err := r.recordOrder(ctx, txn, order); err != nil {
+		return err
+	}
+
+	txn.Commit()
+	return nil
 }
 
-// NewInMemoryDB is the InMemoryRepository factory method. It fulfills the same
-// interface as Repository, but uses go-membdb internally to provide data. NOTE,
-// this interface requires build time tooling.
-func NewInMemoryDB(config *config.Config) (Repository, error) {
-	config.Logger.Debug("Attempting to load in memory db")
-	// Create a new data base
-	db, err := memdb.NewMemDB(createSchema())
+// recordOrder inserts an order record within txn, a replaced record keeps its
+// UUID
+func (r *InMemoryRepository) recordOrder(ctx context.Context, txn *memdb.Txn, order *entities.OrderRecord) error {
+	previous, err := r.first(ctx, txn, OrderRecord, "id", order.ID)
 	if err != nil {
-		config.Logger.Debug(fmt.Sprintf("Failed to load in membory database with err %+v", err))
-		return &InMemoryRepository{}, err
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.RecordOrder failed to load order", "error", err)
+		return err
+	}
+	if previous != nil {
+		order.UUID = previous.(*entities.OrderRecord).UUID
+	} else if order.UUID, err = entityUUID(r.config.EntityIDs); err != nil {
+		return err
 	}
 
-	repository := &InMemoryRepository{db, config}
+	row := *order
+	row.Items = append([]entities.OrderRecordItem(nil), order.Items...)
+	for n := range row.Items {
+		row.Items[n].OrderID = row.ID
+	}
+	if err := r.insert(ctx, txn, OrderRecord, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.RecordOrder failed to insert order", "error", err)
+		return err
+	}
+	return nil
+}
 
-	repository.config.Logger.Debug("Loading Ingredients")
-	err = repository.loadIngredients()
+// AggregateStats computes the statistics with a scan of the coffees and one of
+// the order records
+func (r *InMemoryRepository) AggregateStats(ctx context.Context, since time.Time, top int) (*entities.Stats, error) {
+	txn, err := r.readTxn(ctx, false)
 	if err != nil {
-		repository.config.Logger.Debug(fmt.Sprintf("Failed to load ingredients with err %+v", err))
-		return &InMemoryRepository{}, err
+		return nil, err
 	}
+	defer txn.Abort()
 
-	repository.config.Logger.Debug("Loading coffees")
-	err = repository.loadCoffees()
+	stats := &entities.Stats{OrdersByStatus: map[string]int{}, TopSellers: []entities.TopSeller{}}
+	iter, err := r.get(ctx, txn, Coffee, "id")
 	if err != nil {
-		repository.config.Logger.Debug(fmt.Sprintf("Failed to load coffees with err %+v", err))
-		return &InMemoryRepository{}, err
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.AggregateStats failed to load coffees", "error", err)
+		return nil, err
+	}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		stats.Coffees++
 	}
 
-	repository.config.Logger.Debug("Loading coffee ingredients")
-	err = repository.loadCoffeeIngredients()
+	iter, err = r.get(ctx, txn, OrderRecord, "id")
 	if err != nil {
-		repository.config.Logger.Debug(fmt.Sprintf("Failed to load coffee ingredients with err %+v", err))
-		return &InMemoryRepository{}, err
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.AggregateStats failed to load orders", "error", err)
+		return nil, err
 	}
+	sellers := map[int]*entities.TopSeller{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		order := row.(*entities.OrderRecord)
+		stats.OrdersByStatus[order.Status]++
+		if order.Status == entities.OrderCancelled {
+			continue
+		}
+		if !order.CreatedAt.Before(since) {
+			stats.RevenueToday += order.Total
+		}
+		for _, item := range order.Items {
+			seller, ok := sellers[item.CoffeeID]
+			if !ok {
+				seller = &entities.TopSeller{CoffeeID: item.CoffeeID}
+				sellers[item.CoffeeID] = seller
+			}
+			seller.Quantity += item.Quantity
+			if item.Name > seller.Name {
+				seller.Name = item.Name
+			}
+		}
+	}
+	stats.RevenueToday = math.Round(stats.RevenueToday*100) / 100
 
-	repository.config.Logger.Debug("Data loaded")
-	return repository, nil
+	for _, seller := range sellers {
+		stats.TopSellers = append(stats.TopSellers, *seller)
+	}
+	sort.Slice(stats.TopSellers, func(i, j int) bool {
+		if stats.TopSellers[i].Quantity != stats.TopSellers[j].Quantity {
+			return stats.TopSellers[i].Quantity > stats.TopSellers[j].Quantity
+		}
+		return stats.TopSellers[i].CoffeeID < stats.TopSellers[j].CoffeeID
+	})
+	if len(stats.TopSellers) > top {
+		stats.TopSellers = stats.TopSellers[:top]
+	}
+	return stats, nil
 }
 
-// Find returns all coffees from the database
-// Used to accept ctx opentracing.SpanContext
-func (r *InMemoryRepository) Find() (entities.Coffees, error) {
-	txn := r.db.Txn(false)
+// SalesByHour buckets the order records in hours with a scan, the way
+// Postgres keeps its hourly sales
+func (r *InMemoryRepository) SalesByHour(ctx context.Context, from, to time.Time) ([]entities.SalesBucket, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
 	defer txn.Abort()
 
-	iter, err := txn.Get(Coffee.String(), "id")
+	iter, err := r.get(ctx, txn, OrderRecord, "id")
 	if err != nil {
-		r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load coffees", err)
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.SalesByHour failed to load orders", "error", err)
 		return nil, err
 	}
+	hours := map[time.Time]*entities.SalesBucket{}
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		order := row.(*entities.OrderRecord)
+		hour := order.CreatedAt.UTC().Truncate(time.Hour)
+		if order.Status == entities.OrderCancelled || hour.Before(from) || !hour.Before(to) {
+			continue
+		}
+		bucket, ok := hours[hour]
+		if !ok {
+			bucket = &entities.SalesBucket{Time: hour}
+			hours[hour] = bucket
+		}
+		bucket.Orders++
+		bucket.Revenue += order.Total
+	}
 
-	coffees := make([]entities.Coffee, 0)
+	sales := []entities.SalesBucket{}
+	for _, bucket := range hours {
+		sales = append(sales, *bucket)
+	}
+	sort.Slice(sales, func(i, j int) bool { return sales[i].Time.Before(sales[j].Time) })
+	return sales, nil
+}
 
-	for coffee := iter.Next(); coffee != nil; coffee = iter.Next() {
-		coffees = append(coffees, *coffee.(*entities.Coffee))
+// FindIngredients returns all ingredients
+func (r *InMemoryRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	txn, err := r.readTxn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Abort()
+
+	iter, err := r.get(ctx, txn, Ingredient, "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredients failed to load ingredients", "error", err)
+		return nil, err
+	}
+
+	ingredients := make(entities.Ingredients, 0)
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ingredients = append(ingredients, *row.(*entities.Ingredient))
+	}
+
+	return ingredients, nil
+}
+
+// CreateIngredient inserts an ingredient, assigning the next ingredient ID
+// and the timestamps
+func (r *InMemoryRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	return r.createIngredient(ctx, ingredient, r.sequences.next(Ingredient))
+}
+
+// createIngredient inserts an ingredient with an ID assigned by the caller
+func (r *InMemoryRepository) createIngredient(ctx context.Context, ingredient *entities.Ingredient, id int) error {
+	if err := ingredient.Validate(); err != nil {
+		return err
+	}
+
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	r.sequences.observe(Ingredient, id)
+	timestamp := time.Now().String()
+	row := *ingredient
+	row.ID = id
+	row.CreatedAt = timestamp
+	row.UpdatedAt = timestamp
+
+	if err := r.create(ctx, txn, Ingredient, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.CreateIngredient failed to insert ingredient", "error", err)
+		return err
+	}
+
+	txn.Commit()
+
+	*ingredient = row
+	return nil
+}
+
+// UpdateIngredient replaces the attributes of an existing ingredient
+func (r *InMemoryRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := ingredient.Validate(); err != nil {
+		return err
+	}
+
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Ingredient, "id", ingredient.ID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateIngredient failed to load ingredient", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	row := *ingredient
+	row.CreatedAt = raw.(*entities.Ingredient).CreatedAt
+	row.UpdatedAt = time.Now().String()
+
+	if err := r.insert(ctx, txn, Ingredient, &row); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.UpdateIngredient failed to update ingredient", "error", err)
+		return err
+	}
+
+	txn.Commit()
+
+	*ingredient = row
+	return nil
+}
+
+// DeleteIngredient removes an ingredient and drops it from every coffee
+func (r *InMemoryRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := r.first(ctx, txn, Ingredient, "id", ingredientID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteIngredient failed to load ingredient", "error", err)
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	if err := r.deleteReferencing(ctx, txn, Ingredient, ingredientID); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteIngredient failed to delete referencing rows", "error", err)
+		return err
+	}
+	if err := r.delete(ctx, txn, Ingredient, raw); err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.DeleteIngredient failed to delete ingredient", "error", err)
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// replaceCoffeeIngredients deletes the coffee_ingredient rows of a coffee and
+// inserts one row per ingredient, returning the inserted rows
+func (r *InMemoryRepository) replaceCoffeeIngredients(ctx context.Context, txn *memdb.Txn, coffeeID int, ingredients []entities.CoffeeIngredients, timestamp string) ([]entities.CoffeeIngredients, error) {
+	if err := r.deleteAll(ctx, txn, CoffeeIngredient, "coffee_id", coffeeID); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]entities.CoffeeIngredients, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		row := entities.CoffeeIngredients{
+			ID:           r.sequences.next(CoffeeIngredient),
+			CoffeeID:     coffeeID,
+			IngredientID: ingredient.IngredientID,
+			Quantity:     ingredient.Quantity,
+			Unit:         ingredient.Unit,
+			CreatedAt:    timestamp,
+			UpdatedAt:    timestamp,
+		}
+		if err := r.create(ctx, txn, CoffeeIngredient, &row); err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, row)
 	}
 
-	for _, coffee := range coffees {
-		coffeeIngredients := make([]entities.CoffeeIngredients, 0)
+	names, err := r.ingredientNames(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	for n := range inserted {
+		inserted[n].Name = names[inserted[n].IngredientID]
+	}
+
+	return inserted, nil
+}
+
+// indexedHydrationLimit is the largest number of coffees hydrated through the
+// coffee_id index, larger sets are hydrated from a single pass over the join
+// table
+const indexedHydrationLimit = 16
+
+// hydrate sets the ingredients of every coffee, including the ingredient
+// names, in O(coffees + coffee_ingredient rows)
+func (r *InMemoryRepository) hydrate(ctx context.Context, txn *memdb.Txn, coffees entities.Coffees) error {
+	names, err := r.ingredientNames(ctx, txn)
+	if err != nil {
+		return err
+	}
+
+	var ingredientsByCoffee map[int][]entities.CoffeeIngredients
+	if len(coffees) > indexedHydrationLimit {
+		if ingredientsByCoffee, err = r.ingredientsByCoffee(ctx, txn); err != nil {
+			return err
+		}
+	} else {
+		ingredientsByCoffee = make(map[int][]entities.CoffeeIngredients, len(coffees))
+		for _, coffee := range coffees {
+			if ingredientsByCoffee[coffee.ID], err = r.coffeeIngredients(ctx, txn, coffee.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n := range coffees {
+		ingredients := ingredientsByCoffee[coffees[n].ID]
+		if ingredients == nil {
+			ingredients = make([]entities.CoffeeIngredients, 0)
+		}
+		for i := range ingredients {
+			ingredients[i].Name = names[ingredients[i].IngredientID]
+		}
+		coffees[n].Ingredients = ingredients
+	}
+
+	return nil
+}
+
+// ingredientNames loads the name of every ingredient keyed by ID
+func (r *InMemoryRepository) ingredientNames(ctx context.Context, txn *memdb.Txn) (map[int]string, error) {
+	iter, err := r.get(ctx, txn, Ingredient, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string)
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ingredient := row.(*entities.Ingredient)
+		names[ingredient.ID] = ingredient.Name
+	}
+
+	return names, nil
+}
+
+// ingredientsByCoffee loads every coffee_ingredient row once, keyed by coffee
+// ID
+func (r *InMemoryRepository) ingredientsByCoffee(ctx context.Context, txn *memdb.Txn) (map[int][]entities.CoffeeIngredients, error) {
+	iter, err := r.get(ctx, txn, CoffeeIngredient, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ingredientsByCoffee := make(map[int][]entities.CoffeeIngredients)
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ingredient := *row.(*entities.CoffeeIngredients)
+		ingredientsByCoffee[ingredient.CoffeeID] = append(ingredientsByCoffee[ingredient.CoffeeID], ingredient)
+	}
+
+	return ingredientsByCoffee, nil
+}
+
+// coffeeIngredients loads the coffee_ingredient rows of a coffee through the
+// coffee_id index
+func (r *InMemoryRepository) coffeeIngredients(ctx context.Context, txn *memdb.Txn, coffeeID int) ([]entities.CoffeeIngredients, error) {
+	iter, err := r.get(ctx, txn, CoffeeIngredient, "coffee_id", coffeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ingredients := make([]entities.CoffeeIngredients, 0)
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ingredients = append(ingredients, *row.(*entities.CoffeeIngredients))
+	}
+
+	return ingredients, nil
+}
+
+// candidateIngredients loads the ingredients of coffeeID and of every coffee
+// sharing at least one ingredient with it, keyed by coffee ID
+func (r *InMemoryRepository) candidateIngredients(ctx context.Context, txn *memdb.Txn, coffeeID int) (map[int][]entities.CoffeeIngredients, error) {
+	source, err := r.coffeeIngredients(ctx, txn, coffeeID)
+	if err != nil {
+		return nil, err
+	}
 
-		innerIter, err := txn.Get(CoffeeIngredient.String(), "id")
+	ingredientsByCoffee := map[int][]entities.CoffeeIngredients{coffeeID: source}
+	for _, ingredient := range source {
+		iter, err := r.get(ctx, txn, CoffeeIngredient, "ingredient_id", ingredient.IngredientID)
 		if err != nil {
-			r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load ingredients", err)
 			return nil, err
 		}
 
-		for ingredient := innerIter.Next(); ingredient != nil; ingredient = innerIter.Next() {
-			coffeeIngredients = append(coffeeIngredients, *ingredient.(*entities.CoffeeIngredients))
-			fmt.Printf("coffee-service.data.InMemoryRepository.Find loaded ingredients %s\n", coffeeIngredients)
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			candidate := row.(*entities.CoffeeIngredients).CoffeeID
+			if _, ok := ingredientsByCoffee[candidate]; ok {
+				continue
+			}
+
+			if ingredientsByCoffee[candidate], err = r.coffeeIngredients(ctx, txn, candidate); err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		coffee.Ingredients = coffeeIngredients
+	return ingredientsByCoffee, nil
+}
+
+// readTxn starts a read transaction, from the snapshot of the ReadSnapshot
+// in ctx if any. Without a token a list read, which pins, takes and pins a
+// snapshot of the database.
+func (r *InMemoryRepository) readTxn(ctx context.Context, pin bool) (*memdb.Txn, error) {
+	snapshot := ReadSnapshotFromContext(ctx)
+	if snapshot == nil || r.pins == nil {
+		return r.db.Txn(false), nil
 	}
 
-	return coffees, nil
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+
+	if snapshot.token != "" {
+		db, ok := r.pins.get(snapshot.token)
+		if !ok {
+			snapshot.expired = true
+			return nil, ErrSnapshotExpired
+		}
+		return db.Txn(false), nil
+	}
+	if !pin {
+		return r.db.Txn(false), nil
+	}
+
+	db := r.db.Snapshot()
+	token, err := r.pins.pin(db)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.token = token
+	return db.Txn(false), nil
 }
 
-func createSchema() *memdb.DBSchema {
-	// Create the DB schema
-	// TODO Update to this entities with tooling.
-	return &memdb.DBSchema{
-		Tables: map[string]*memdb.TableSchema{
-			Coffee.String(): {
-				Name: Coffee.String(),
-				Indexes: map[string]*memdb.IndexSchema{
-					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.IntFieldIndex{Field: "ID"},
-					},
-				},
-			},
-			Ingredient.String(): {
-				Name: Ingredient.String(),
-				Indexes: map[string]*memdb.IndexSchema{
-					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.IntFieldIndex{Field: "ID"},
-					},
-				},
-			},
-			CoffeeIngredient.String(): {
-				Name: CoffeeIngredient.String(),
-				Indexes: map[string]*memdb.IndexSchema{
-					"id": {
-						Name:    "id",
-						Unique:  true,
-						Indexer: &memdb.IntFieldIndex{Field: "ID"},
-					},
-				},
-			},
-		},
+// slugTaken returns a check whether a slug belongs to a coffee other than
+// coffeeID, within txn or in the other instances of slugTakenElsewhere
+func (r *InMemoryRepository) slugTaken(ctx context.Context, txn *memdb.Txn, coffeeID int) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		raw, err := r.first(ctx, txn, Coffee, "slug", slug)
+		if err != nil {
+			return false, err
+		}
+		if raw != nil {
+			return raw.(*entities.Coffee).ID != coffeeID, nil
+		}
+		if r.slugTakenElsewhere != nil {
+			return r.slugTakenElsewhere(ctx, slug, coffeeID)
+		}
+		return false, nil
+	}
+}
+
+// get runs a memdb lookup, recording it in the query statistics of the context
+func (r *InMemoryRepository) get(ctx context.Context, txn *memdb.Txn, table TableNameKey, index string, args ...interface{}) (memdb.ResultIterator, error) {
+	defer recordQuery(ctx, time.Now(), "get "+table.String()+" by "+index, args...)
+	return txn.Get(table.String(), index, args...)
+}
+
+// first runs a memdb lookup for a single row, recording it in the query
+// statistics of the context
+func (r *InMemoryRepository) first(ctx context.Context, txn *memdb.Txn, table TableNameKey, index string, args ...interface{}) (interface{}, error) {
+	defer recordQuery(ctx, time.Now(), "first "+table.String()+" by "+index, args...)
+	return txn.First(table.String(), index, args...)
+}
+
+// insert writes a row, ErrMissingReference when it references a row which
+// does not exist, recording it in the query statistics of the context
+func (r *InMemoryRepository) insert(ctx context.Context, txn *memdb.Txn, table TableNameKey, row interface{}) error {
+	defer recordQuery(ctx, time.Now(), "insert "+table.String())
+	if err := missingReference(txn, table, row); err != nil {
+		return err
+	}
+	return txn.Insert(table.String(), row)
+}
+
+// create writes a new row, returning ErrConflict rather than replacing a row
+// with one of its unique keys and ErrMissingReference when it references a
+// row which does not exist, recording it in the query statistics of the
+// context
+func (r *InMemoryRepository) create(ctx context.Context, txn *memdb.Txn, table TableNameKey, row interface{}) error {
+	defer recordQuery(ctx, time.Now(), "create "+table.String())
+	if err := conflict(txn, table, row); err != nil {
+		return err
 	}
+	if err := missingReference(txn, table, row); err != nil {
+		return err
+	}
+	return txn.Insert(table.String(), row)
+}
+
+// deleteAll removes every row matching an index lookup, recording it in the
+// query statistics of the context
+func (r *InMemoryRepository) deleteAll(ctx context.Context, txn *memdb.Txn, table TableNameKey, index string, args ...interface{}) error {
+	defer recordQuery(ctx, time.Now(), "delete all "+table.String()+" by "+index, args...)
+	_, err := txn.DeleteAll(table.String(), index, args...)
+	return err
+}
+
+// delete removes a row, recording it in the query statistics of the context
+func (r *InMemoryRepository) delete(ctx context.Context, txn *memdb.Txn, table TableNameKey, row interface{}) error {
+	defer recordQuery(ctx, time.Now(), "delete "+table.String())
+	return txn.Delete(table.String(), row)
 }
 
 func (r *InMemoryRepository) loadIngredients() error {
@@ -166,7 +1816,8 @@ func (r *InMemoryRepository) loadIngredients() error {
 		if err := txn.Insert(Ingredient.String(), row); err != nil {
 			return err
 		}
-		fmt.Printf("Loaded ingredient %+v\n", row)
+		r.sequences.observe(Ingredient, row.ID)
+		r.config.Logger.Trace("Loaded ingredient", "id", row.ID, "name", row.Name)
 	}
 
 	txn.Commit()
@@ -181,60 +1832,72 @@ func (r *InMemoryRepository) loadCoffees() error {
 		{
 			ID:          1,
 			Name:        "Packer Spiced Latte",
+			Slug:        "packer-spiced-latte",
 			Teaser:      "Packed with goodness to spice up your images",
 			Description: "",
 			Price:       350,
 			Image:       "/packer.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
 		{
 			ID:          2,
 			Name:        "Vaulatte",
+			Slug:        "vaulatte",
 			Teaser:      "Nothing gives you a safe and secure feeling like a Vaulatte",
 			Description: "",
 			Price:       200,
 			Image:       "/vault.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
 		{
 			ID:          3,
 			Name:        "Nomadicano",
+			Slug:        "nomadicano",
 			Teaser:      "Drink one today and you will want to schedule another",
 			Description: "",
 			Price:       150,
 			Image:       "/nomad.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
 		{
 			ID:          4,
 			Name:        "Terraspresso",
+			Slug:        "terraspresso",
 			Teaser:      "Nothing kickstarts your day like a provision of Terraspresso",
 			Description: "",
 			Price:       150,
 			Image:       "/terraform.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
 		{
 			ID:          5,
 			Name:        "Vagrante espresso",
+			Slug:        "vagrante-espresso",
 			Teaser:      "Stdin is not a tty",
 			Description: "",
 			Price:       200,
 			Image:       "/vagrant.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
 		{
 			ID:          6,
 			Name:        "Connectaccino",
+			Slug:        "connectaccino",
 			Teaser:      "Discover the wonders of our meshy service",
 			Description: "",
 			Price:       250,
 			Image:       "/consul.png",
+			Status:      StatusPublished,
 			CreatedAt:   timestamp,
 			UpdatedAt:   timestamp,
 		},
@@ -244,6 +1907,7 @@ func (r *InMemoryRepository) loadCoffees() error {
 		if err := txn.Insert(Coffee.String(), c); err != nil {
 			return err
 		}
+		r.sequences.observe(Coffee, c.ID)
 	}
 
 	txn.Commit()
@@ -259,6 +1923,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           1,
 			CoffeeID:     1,
 			IngredientID: 1,
+			Quantity:     40,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -266,6 +1932,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           2,
 			CoffeeID:     1,
 			IngredientID: 2,
+			Quantity:     300,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -273,6 +1941,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           3,
 			CoffeeID:     1,
 			IngredientID: 4,
+			Quantity:     5,
+			Unit:         "g",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -280,6 +1950,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           4,
 			CoffeeID:     2,
 			IngredientID: 1,
+			Quantity:     40,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -287,6 +1959,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           5,
 			CoffeeID:     2,
 			IngredientID: 2,
+			Quantity:     300,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -294,6 +1968,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           6,
 			CoffeeID:     3,
 			IngredientID: 1,
+			Quantity:     20,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -301,6 +1977,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           7,
 			CoffeeID:     3,
 			IngredientID: 3,
+			Quantity:     100,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -308,6 +1986,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           8,
 			CoffeeID:     4,
 			IngredientID: 1,
+			Quantity:     40,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -315,6 +1995,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           9,
 			CoffeeID:     5,
 			IngredientID: 1,
+			Quantity:     40,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -322,6 +2004,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           10,
 			CoffeeID:     6,
 			IngredientID: 1,
+			Quantity:     40,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -329,6 +2013,8 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 			ID:           11,
 			CoffeeID:     6,
 			IngredientID: 5,
+			Quantity:     100,
+			Unit:         "ml",
 			CreatedAt:    timestamp,
 			UpdatedAt:    timestamp,
 		},
@@ -338,6 +2024,91 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 		if err := txn.Insert(CoffeeIngredient.String(), ci); err != nil {
 			return err
 		}
+		r.sequences.observe(CoffeeIngredient, ci.ID)
+	}
+
+	txn.Commit()
+	return nil
+}
+
+func (r *InMemoryRepository) loadStores() error {
+	timestamp := time.Now().String()
+	txn := r.db.Txn(true)
+
+	stores := []*entities.Store{
+		{ID: 1, Name: "Market Street", Address: "101 Market Street", City: "San Francisco", Country: "US", Latitude: 37.7925, Longitude: -122.3966, CreatedAt: timestamp, UpdatedAt: timestamp},
+		{ID: 2, Name: "Prinsengracht", Address: "Prinsengracht 263", City: "Amsterdam", Country: "NL", Latitude: 52.3752, Longitude: 4.8840, CreatedAt: timestamp, UpdatedAt: timestamp},
+		{ID: 3, Name: "Shoreditch", Address: "1 Curtain Road", City: "London", Country: "GB", Latitude: 51.5226, Longitude: -0.08, CreatedAt: timestamp, UpdatedAt: timestamp},
+	}
+	for _, row := range stores {
+		if err := txn.Insert(Store.String(), row); err != nil {
+			return err
+		}
+		r.sequences.observe(Store, row.ID)
+	}
+
+	// the flagship serves every coffee
+	menus := map[int][]int{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {2, 3, 4, 5},
+		3: {1, 3, 6},
+	}
+	for storeID, coffeeIDs := range menus {
+		for _, coffeeID := range coffeeIDs {
+			if err := txn.Insert(StoreCoffee.String(), &entities.StoreCoffee{StoreID: storeID, CoffeeID: coffeeID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+func (r *InMemoryRepository) loadSuppliers() error {
+	timestamp := time.Now().String()
+	txn := r.db.Txn(true)
+
+	suppliers := []*entities.Supplier{
+		{ID: 1, Name: "Finca La Esperanza", Country: "CO", Certifications: []string{"organic", "fairtrade"}, CreatedAt: timestamp, UpdatedAt: timestamp},
+		{ID: 2, Name: "Meadowbank Dairy", Country: "GB", Certifications: []string{"organic"}, CreatedAt: timestamp, UpdatedAt: timestamp},
+		{ID: 3, Name: "Malabar Spice Traders", Country: "IN", Certifications: []string{}, CreatedAt: timestamp, UpdatedAt: timestamp},
+	}
+	for _, row := range suppliers {
+		if err := txn.Insert(Supplier.String(), row); err != nil {
+			return err
+		}
+		r.sequences.observe(Supplier, row.ID)
+	}
+
+	// hot water has no supplier
+	links := []*entities.IngredientSupplier{
+		{IngredientID: 1, SupplierID: 1},
+		{IngredientID: 2, SupplierID: 2},
+		{IngredientID: 4, SupplierID: 3},
+		{IngredientID: 5, SupplierID: 2},
+	}
+	for _, row := range links {
+		if err := txn.Insert(IngredientSupplier.String(), row); err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+func (r *InMemoryRepository) loadCoupons() error {
+	timestamp := time.Now().String()
+	txn := r.db.Txn(true)
+
+	coupons := []*entities.Coupon{
+		{Code: "WELCOME10", Type: entities.CouponPercent, Value: 10, CreatedAt: timestamp, UpdatedAt: timestamp},
+	}
+	for _, row := range coupons {
+		if err := txn.Insert(Coupon.String(), row); err != nil {
+			return err
+		}
 	}
 
 	txn.Commit()
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func setupInMemoryRepository(t *testing.T) Repository {
+	r, err := NewIsolatedInMemoryDB()
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestInMemoryFindLoadsIngredientsPerCoffee(t *testing.T) {
+	r := setupInMemoryRepository(t)
+
+	coffees, err := r.Find(context.Background())
+	require.NoError(t, err)
+	require.Len(t, coffees, 6)
+
+	for _, coffee := range coffees {
+		assert.NotEmpty(t, coffee.Ingredients, coffee.Name)
+		for _, ingredient := range coffee.Ingredients {
+			assert.Equal(t, coffee.ID, ingredient.CoffeeID, coffee.Name)
+		}
+	}
+}
+
+func TestInMemoryFindWhereFiltersCoffees(t *testing.T) {
+	r := setupInMemoryRepository(t)
+
+	expr, err := filter.Parse("price<300 AND name~latte")
+	require.NoError(t, err)
+
+	coffees, err := r.FindWhere(context.Background(), expr)
+	assert.NoError(t, err)
+	assert.Len(t, coffees, 1)
+	assert.Equal(t, "Vaulatte", coffees[0].Name)
+	assert.Len(t, coffees[0].Ingredients, 2)
+}
+
+func TestInMemoryFindWhereUsesIDIndex(t *testing.T) {
+	r := setupInMemoryRepository(t)
+
+	expr, err := filter.Parse("id=3 AND price=150")
+	require.NoError(t, err)
+
+	coffees, err := r.FindWhere(context.Background(), expr)
+	assert.NoError(t, err)
+	assert.Len(t, coffees, 1)
+	assert.Equal(t, "Nomadicano", coffees[0].Name)
+}
+
+func TestInMemoryFindWhereUsesNameIndex(t *testing.T) {
+	r := setupInMemoryRepository(t)
+
+	expr, err := filter.Parse(`name="Vaulatte" OR name="Nomadicano"`)
+	require.NoError(t, err)
+	index, _ := indexedLookup(expr)
+	assert.Equal(t, "id", index)
+
+	expr, err = filter.Parse(`price>100 AND name="Vaulatte"`)
+	require.NoError(t, err)
+	index, args := indexedLookup(expr)
+	assert.Equal(t, "name", index)
+	assert.Equal(t, []interface{}{"Vaulatte"}, args)
+
+	coffees, err := r.FindWhere(context.Background(), expr)
+	assert.NoError(t, err)
+	assert.Len(t, coffees, 1)
+	assert.Equal(t, 2, coffees[0].ID)
+	assert.Len(t, coffees[0].Ingredients, 2)
+}
+
+func TestInMemoryDeleteCoffeeRemovesIngredientRows(t *testing.T) {
+	r := setupInMemoryRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, r.DeleteCoffee(ctx, 1))
+
+	txn := r.(*InMemoryRepository).db.Txn(false)
+	defer txn.Abort()
+	row, err := txn.First(CoffeeIngredient.String(), "coffee_id", 1)
+	require.NoError(t, err)
+	assert.Nil(t, row)
+
+	// coffee 2 shares every ingredient with coffee 1 and keeps them
+	coffee, err := r.FindByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, ingredientIDs(*coffee))
+}
+
+func TestInMemoryFindRelatedRanksBySharedIngredients(t *testing.T) {
+	r := setupInMemoryRepository(t)
+
+	coffees, err := r.FindRelated(context.Background(), 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, coffees, 2)
+	assert.Equal(t, "Vaulatte", coffees[0].Name)
+
+	_, err = r.FindRelated(context.Background(), 42, 2)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestInMemoryCreateCoffeeRejectsTakenKeys(t *testing.T) {
+	r := setupInMemoryRepository(t).(*InMemoryRepository)
+	ctx := context.Background()
+
+	existing, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+
+	err = r.createCoffee(ctx, &entities.Coffee{Name: "Replacement", Price: 200}, 1, "")
+	assert.True(t, errors.Is(err, ErrConflict))
+	err = r.createCoffee(ctx, &entities.Coffee{Name: "Replacement", Price: 200}, 100, existing.Slug)
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	coffee, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, existing.Name, coffee.Name)
+	_, err = r.FindByID(ctx, 100)
+	assert.Error(t, err)
+}
+
+func TestInMemoryCreateCoffeeRejectsUnknownIngredients(t *testing.T) {
+	r := setupInMemoryRepository(t)
+	ctx := context.Background()
+
+	before, err := r.Find(ctx)
+	require.NoError(t, err)
+
+	err = r.CreateCoffee(ctx, &entities.Coffee{Name: "Mystery", Price: 200, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1}, {IngredientID: 100}}})
+	assert.True(t, errors.Is(err, ErrMissingReference))
+
+	after, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, after, len(before))
+}
+
+func TestInMemoryDeleteIngredientCascadesToReferencingRows(t *testing.T) {
+	r, err := NewEmptyInMemoryDB()
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, r.Seed(&Fixtures{
+		Ingredients: []entities.Ingredient{{ID: 1, Name: "Espresso"}, {ID: 2, Name: "Oat Milk"}},
+		Coffees:     []entities.Coffee{{ID: 1, Name: "Flat White", Price: 200, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1}, {IngredientID: 2}}}},
+		Suppliers:   []entities.Supplier{{ID: 1, Name: "Oatly", IngredientIDs: []int{2}}},
+	}))
+
+	require.NoError(t, r.DeleteIngredient(ctx, 2))
+
+	coffee, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, ingredientIDs(*coffee))
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+	row, err := txn.First(IngredientSupplier.String(), "supplier_id", 1)
+	require.NoError(t, err)
+	assert.Nil(t, row)
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// ErrMissingReference is returned when writing a row which references a row
+// that does not exist, like a foreign key constraint of Postgres
+var ErrMissingReference = errors.New("a referenced row does not exist")
+
+// reference is a field of the rows of table holding the ID of a row of
+// another table, a foreign key of the Postgres schema
+type reference struct {
+	table TableNameKey
+	field string
+	// index looks up the rows of table by the field
+	index      string
+	references TableNameKey
+}
+
+// references are the foreign keys of the in memory tables. Deleting a row
+// deletes the rows referencing it, like ON DELETE CASCADE.
+var references = []reference{
+	{table: CoffeeIngredient, field: "CoffeeID", index: "coffee_id", references: Coffee},
+	{table: CoffeeIngredient, field: "IngredientID", index: "ingredient_id", references: Ingredient},
+	{table: CoffeeTranslation, field: "CoffeeID", index: "coffee_id", references: Coffee},
+	{table: CoffeeImage, field: "CoffeeID", index: "coffee_id", references: Coffee},
+	{table: CoffeeAvailability, field: "CoffeeID", index: "coffee_id", references: Coffee},
+	{table: StoreCoffee, field: "StoreID", index: "store_id", references: Store},
+	{table: StoreCoffee, field: "CoffeeID", index: "coffee_id", references: Coffee},
+	{table: IngredientSupplier, field: "IngredientID", index: "id", references: Ingredient},
+	{table: IngredientSupplier, field: "SupplierID", index: "supplier_id", references: Supplier},
+}
+
+// missingReference returns ErrMissingReference when row references a row
+// which does not exist within txn
+func missingReference(txn *memdb.Txn, table TableNameKey, row interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(row))
+	for _, ref := range references {
+		if ref.table != table {
+			continue
+		}
+		// like a NULL column, an unset ID references nothing
+		id := int(value.FieldByName(ref.field).Int())
+		if id == 0 {
+			continue
+		}
+
+		referenced, err := txn.First(ref.references.String(), "id", id)
+		if err != nil {
+			return err
+		}
+		if referenced == nil {
+			return fmt.Errorf("%w: %s %s %d", ErrMissingReference, table, ref.references, id)
+		}
+	}
+	return nil
+}
+
+// deleteReferencing deletes the rows referencing the row of table with id,
+// before the row itself is deleted
+func (r *InMemoryRepository) deleteReferencing(ctx context.Context, txn *memdb.Txn, table TableNameKey, id int) error {
+	for _, ref := range references {
+		if ref.references != table {
+			continue
+		}
+		if err := r.deleteAll(ctx, txn, ref.table, ref.index, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// header marks the generated files
+const header = "// Code generated by schemagen from data/schema.hcl. DO NOT EDIT.\n\n"
+
+// Entities generates the entity structs and their collections
+func Entities(spec *Spec) ([]byte, error) {
+	body := &bytes.Buffer{}
+	imports := map[string]bool{}
+
+	for _, e := range spec.Entities {
+		if e.Collection != "" {
+			fmt.Fprintf(body, "// %s is a collection of %s\n", e.Collection, e.Name)
+			fmt.Fprintf(body, "type %s []%s\n\n", e.Collection, e.Name)
+		}
+
+		writeComment(body, "", e.Doc)
+		fmt.Fprintf(body, "type %s struct {\n", e.Name)
+		for _, f := range e.Fields {
+			writeComment(body, "\t", f.Doc)
+			fmt.Fprintf(body, "\t%s %s %s\n", f.Name, f.Type, f.tags())
+
+			if strings.Contains(f.Type, "time.") {
+				imports["time"] = true
+			}
+			if strings.Contains(f.Type, "sql.") {
+				imports["database/sql"] = true
+			}
+		}
+		body.WriteString("}\n\n")
+	}
+
+	return source("entities", imports, body)
+}
+
+// JSON generates the AppendJSON encoders of the entities asking for one. They
+// rely on the appendJSONString and appendJSONFloat helpers of the entities
+// package.
+func JSON(spec *Spec) ([]byte, error) {
+	body := &bytes.Buffer{}
+	imports := map[string]bool{}
+
+	for _, e := range spec.Entities {
+		if !e.AppendJSON {
+			continue
+		}
+		recv := strings.ToLower(e.Name[:1])
+		size := lowerFirst(e.Name) + "JSONSize"
+
+		if e.JSONSize > 0 {
+			fmt.Fprintf(body, "// %s is the typical size of an encoded %s, buffers are\n", size, e.Name)
+			fmt.Fprintf(body, "// allocated for it to avoid growing them while appending\n")
+			fmt.Fprintf(body, "const %s = %d\n\n", size, e.JSONSize)
+		}
+
+		if e.Collection != "" {
+			fmt.Fprintf(body, "// AppendJSON appends the collection as JSON to b without reflection, like\n")
+			fmt.Fprintf(body, "// %s.AppendJSON.", e.Name)
+			if e.JSONSize > 0 {
+				fmt.Fprintf(body, " A nil b is allocated with room for the whole collection.")
+			}
+			body.WriteString("\n")
+			fmt.Fprintf(body, "func (%s *%s) AppendJSON(b []byte) ([]byte, error) {\n", recv, e.Collection)
+			fmt.Fprintf(body, "if *%s == nil {\nreturn append(b, \"null\"...), nil\n}\n", recv)
+			if e.JSONSize > 0 {
+				fmt.Fprintf(body, "if b == nil {\nb = make([]byte, 0, 2+len(*%s)*%s)\n}\n", recv, size)
+			}
+			fmt.Fprintf(body, "\nb = append(b, '[')\nfor n := range *%s {\nif n > 0 {\nb = append(b, ',')\n}\n\n", recv)
+			fmt.Fprintf(body, "var err error\nif b, err = (*%s)[n].AppendJSON(b); err != nil {\nreturn nil, err\n}\n}\n", recv)
+			body.WriteString("return append(b, ']'), nil\n}\n\n")
+		}
+
+		fields := &bytes.Buffer{}
+		needsErr := false
+		sep := "{"
+		for _, f := range e.Fields {
+			name, omitEmpty := f.jsonName()
+			if name == "" {
+				continue
+			}
+			value := recv + "." + f.Name
+
+			var encode string
+			switch f.Type {
+			case "int":
+				encode = fmt.Sprintf("b = strconv.AppendInt(b, int64(%s), 10)\n", value)
+				imports["strconv"] = true
+			case "int64":
+				encode = fmt.Sprintf("b = strconv.AppendInt(b, %s, 10)\n", value)
+				imports["strconv"] = true
+			case "string":
+				encode = fmt.Sprintf("b = appendJSONString(b, %s)\n", value)
+			case "float64":
+				encode = fmt.Sprintf("if b, err = appendJSONFloat(b, %s); err != nil {\nreturn nil, err\n}\n", value)
+				needsErr = true
+			default:
+				needsErr = true
+				if strings.HasPrefix(f.Type, "[]") {
+					encode = fmt.Sprintf("if %[1]s == nil {\nb = append(b, \"null\"...)\n} else {\nb = append(b, '[')\n"+
+						"for n := range %[1]s {\nif n > 0 {\nb = append(b, ',')\n}\n"+
+						"if b, err = %[1]s[n].AppendJSON(b); err != nil {\nreturn nil, err\n}\n}\nb = append(b, ']')\n}\n", value)
+				} else if omitEmpty {
+					encode = fmt.Sprintf("if b, err = %s.AppendJSON(b); err != nil {\nreturn nil, err\n}\n", value)
+				} else {
+					encode = fmt.Sprintf("if %[1]s == nil {\nb = append(b, \"null\"...)\n} else if b, err = %[1]s.AppendJSON(b); err != nil {\nreturn nil, err\n}\n", value)
+				}
+			}
+
+			key := fmt.Sprintf("b = append(b, `%s%q:`...)\n", sep, name)
+			sep = ","
+			if omitEmpty && strings.HasPrefix(f.Type, "[]") {
+				fmt.Fprintf(fields, "if len(%s) != 0 {\n%s%s}\n", value, key, encode)
+			} else if omitEmpty {
+				fmt.Fprintf(fields, "if %s != %s {\n%s%s}\n", value, zero(f.Type), key, encode)
+			} else {
+				fields.WriteString(key + encode)
+			}
+		}
+
+		fmt.Fprintf(body, "// AppendJSON appends the %s as JSON to b without reflection, byte for\n", e.Name)
+		fmt.Fprintf(body, "// byte like encoding/json, which stays the reference encoding\n")
+		fmt.Fprintf(body, "func (%s *%s) AppendJSON(b []byte) ([]byte, error) {\n", recv, e.Name)
+		if needsErr {
+			body.WriteString("var err error\n")
+		}
+		if e.JSONSize > 0 {
+			fmt.Fprintf(body, "if b == nil {\nb = make([]byte, 0, %s)\n}\n", size)
+		}
+		if needsErr || e.JSONSize > 0 {
+			body.WriteString("\n")
+		}
+		body.Write(fields.Bytes())
+		body.WriteString("return append(b, '}'), nil\n}\n\n")
+	}
+
+	return source("entities", imports, body)
+}
+
+// XML generates the MarshalXML and UnmarshalXML methods naming the elements
+// of the entities and collections asking for one, so XML responses have a
+// single root element named like the JSON resources
+func XML(spec *Spec) ([]byte, error) {
+	body := &bytes.Buffer{}
+	imports := map[string]bool{"encoding/xml": true}
+
+	for _, e := range spec.Entities {
+		if e.XML == "" {
+			continue
+		}
+		recv := strings.ToLower(e.Name[:1])
+		alias := lowerFirst(e.Name)
+
+		fmt.Fprintf(body, "// MarshalXML encodes the %s as a %s element, unless it is the value of a\n", e.Name, e.XML)
+		fmt.Fprintf(body, "// field naming its own element\n")
+		fmt.Fprintf(body, "func (%s %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n", recv, e.Name)
+		fmt.Fprintf(body, "if start.Name.Local == %q {\nstart.Name.Local = %q\n}\n\n", e.Name, e.XML)
+		fmt.Fprintf(body, "// %s has the fields of %s without its methods\n", alias, e.Name)
+		fmt.Fprintf(body, "type %s %s\nreturn e.EncodeElement(%s(%s), start)\n}\n\n", alias, e.Name, alias, recv)
+
+		if e.XMLCollection == "" {
+			continue
+		}
+		items := fmt.Sprintf("struct {\nItems []%s `xml:%q`\n}", e.Name, e.XML)
+
+		fmt.Fprintf(body, "// MarshalXML encodes the collection as a %s element holding a %s element\n", e.XMLCollection, e.XML)
+		fmt.Fprintf(body, "// per %s\n", e.Name)
+		fmt.Fprintf(body, "func (%s %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n", recv, e.Collection)
+		fmt.Fprintf(body, "if start.Name.Local == %q {\nstart.Name.Local = %q\n}\n", e.Collection, e.XMLCollection)
+		fmt.Fprintf(body, "return e.EncodeElement(%s{%s}, start)\n}\n\n", items, recv)
+
+		fmt.Fprintf(body, "// UnmarshalXML decodes a collection encoded by MarshalXML\n")
+		fmt.Fprintf(body, "func (%s *%s) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {\n", recv, e.Collection)
+		fmt.Fprintf(body, "items := %s{}\n", items)
+		body.WriteString("if err := d.DecodeElement(&items, &start); err != nil {\nreturn err\n}\n\n")
+		fmt.Fprintf(body, "*%s = items.Items\nreturn nil\n}\n\n", recv)
+	}
+
+	return source("entities", imports, body)
+}
+
+// MemDB generates the TableNameKey of every in memory table and the schema of
+// the in memory database
+func MemDB(spec *Spec) ([]byte, error) {
+	body := &bytes.Buffer{}
+	imports := map[string]bool{"github.com/hashicorp/go-memdb": true}
+
+	body.WriteString("const (\n")
+	for _, e := range spec.Entities {
+		if e.MemDB == "" {
+			continue
+		}
+		fmt.Fprintf(body, "// %s is the %s table name\n", e.MemDB, e.Table)
+		fmt.Fprintf(body, "%s TableNameKey = %q\n", e.MemDB, e.Table)
+	}
+	body.WriteString(")\n\n")
+
+	body.WriteString("// createSchema returns the schema of the in memory database\n")
+	body.WriteString("func createSchema() *memdb.DBSchema {\n")
+	body.WriteString("return &memdb.DBSchema{\nTables: map[string]*memdb.TableSchema{\n")
+	for _, e := range spec.Entities {
+		if e.MemDB == "" {
+			continue
+		}
+		fmt.Fprintf(body, "%[1]s.String(): {\nName: %[1]s.String(),\nIndexes: map[string]*memdb.IndexSchema{\n", e.MemDB)
+		for _, i := range e.Indexes {
+			writeComment(body, "", i.Doc)
+			fmt.Fprintf(body, "%q: {\nName: %q,\n", i.Name, i.Name)
+			if i.Unique {
+				body.WriteString("Unique: true,\n")
+			}
+			if i.AllowMissing {
+				body.WriteString("AllowMissing: true,\n")
+			}
+			switch {
+			case i.Trigram:
+				fmt.Fprintf(body, "Indexer: &trigramIndex{Field: %q},\n", i.Fields[0])
+			case i.UUID:
+				fmt.Fprintf(body, "Indexer: &memdb.UUIDFieldIndex{Field: %q},\n", i.Fields[0])
+			case len(i.Fields) == 1:
+				fmt.Fprintf(body, "Indexer: %s,\n", fieldIndexer(e.field(i.Fields[0])))
+			default:
+				body.WriteString("Indexer: &memdb.CompoundIndex{Indexes: []memdb.Indexer{\n")
+				for _, name := range i.Fields {
+					fmt.Fprintf(body, "%s,\n", fieldIndexer(e.field(name)))
+				}
+				body.WriteString("}},\n")
+			}
+			body.WriteString("},\n")
+		}
+		body.WriteString("},\n},\n")
+	}
+	body.WriteString("},\n}\n}\n")
+
+	return source("data", imports, body)
+}
+
+// Migration generates the CREATE TABLE statements of tables, to start a new
+// migration adding them
+func Migration(spec *Spec, tables []string) ([]byte, error) {
+	b := &bytes.Buffer{}
+	for n, table := range tables {
+		var e *Entity
+		for _, candidate := range spec.Entities {
+			if candidate.Table == table {
+				e = candidate
+			}
+		}
+		if e == nil {
+			return nil, fmt.Errorf("no entity is stored in table %s", table)
+		}
+
+		var definitions []string
+		for _, f := range e.Fields {
+			if f.SQL != "" && f.Column != nil {
+				definitions = append(definitions, *f.Column+" "+f.SQL)
+			}
+		}
+		for _, c := range e.Columns {
+			definitions = append(definitions, c.Name+" "+c.SQL)
+		}
+		definitions = append(definitions, e.Constraints...)
+		if len(definitions) == 0 {
+			return nil, fmt.Errorf("table %s has no columns", table)
+		}
+
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "CREATE TABLE IF NOT EXISTS %s (\n  %s\n);\n", table, strings.Join(definitions, ",\n  "))
+		for _, statement := range e.SQLAfter {
+			fmt.Fprintf(b, "%s;\n", statement)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// fieldIndexer returns the memdb indexer of an int or string field
+func fieldIndexer(f *Field) string {
+	if f.Type == "int" {
+		return fmt.Sprintf("&memdb.IntFieldIndex{Field: %q}", f.Name)
+	}
+	return fmt.Sprintf("&memdb.StringFieldIndex{Field: %q}", f.Name)
+}
+
+// zero returns the zero value of an encodable type, compared with to omit
+// empty fields
+func zero(t string) string {
+	switch t {
+	case "int", "int64", "float64":
+		return "0"
+	case "string":
+		return `""`
+	}
+	return "nil"
+}
+
+// lowerFirst lower cases the first letter of an identifier
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// writeComment writes doc as a comment indented by indent
+func writeComment(b *bytes.Buffer, indent string, doc string) {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
+
+// source returns a gofmt formatted file of package pkg
+func source(pkg string, imports map[string]bool, body *bytes.Buffer) ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteString(header)
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		b.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(b, "%q\n", path)
+		}
+		b.WriteString(")\n\n")
+	}
+	b.Write(body.Bytes())
+
+	formatted, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to format the generated %s code: %w", pkg, err)
+	}
+	return formatted, nil
+}
@@ -0,0 +1,73 @@
+// Command schemagen generates the entity structs, their JSON encoders, the
+// names of their XML elements and the in memory database schema from the
+// schema definition in data/schema.hcl, so they stay consistent. Run it with
+// go generate ./data after changing the definition. With -migration it
+// instead writes the CREATE TABLE statements of the tables listed in -tables
+// to a new migration.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputs are the generated files relative to the directory of the spec
+var outputs = []struct {
+	path     string
+	generate func(*Spec) ([]byte, error)
+}{
+	{filepath.Join("entities", "entities_gen.go"), Entities},
+	{filepath.Join("entities", "json_gen.go"), JSON},
+	{filepath.Join("entities", "xml_gen.go"), XML},
+	{"schema_gen.go", MemDB},
+}
+
+func main() {
+	specPath := flag.String("spec", "schema.hcl", "schema definition")
+	migration := flag.String("migration", "", "migration file to write the CREATE TABLE statements of -tables to")
+	tables := flag.String("tables", "", "comma separated tables of -migration")
+	flag.Parse()
+
+	if err := run(*specPath, *migration, *tables); err != nil {
+		fmt.Fprintln(os.Stderr, "schemagen:", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the files of the spec, or the migration when one is named
+func run(specPath, migration, tables string) error {
+	spec, err := ParseSpec(specPath)
+	if err != nil {
+		return err
+	}
+
+	if migration != "" {
+		if tables == "" {
+			return fmt.Errorf("-migration needs the -tables to create")
+		}
+		if _, err := os.Stat(migration); err == nil {
+			return fmt.Errorf("migration %s already exists, migrations are never regenerated", migration)
+		}
+		d, err := Migration(spec, strings.Split(tables, ","))
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(migration, d, 0644)
+	}
+
+	dir := filepath.Dir(specPath)
+	for _, output := range outputs {
+		d, err := output.generate(spec)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, output.path), d, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const specPath = "../../schema.hcl"
+
+func TestGeneratedFilesAreUpToDate(t *testing.T) {
+	spec, err := ParseSpec(specPath)
+	require.NoError(t, err)
+
+	for _, output := range outputs {
+		d, err := output.generate(spec)
+		require.NoError(t, err)
+
+		committed, err := ioutil.ReadFile(filepath.Join(filepath.Dir(specPath), output.path))
+		require.NoError(t, err)
+		assert.Equal(t, string(committed), string(d), "%s is out of date, run go generate ./data", output.path)
+	}
+}
+
+func TestMigrationCreatesTheTablesWithTheirColumnsAndStatements(t *testing.T) {
+	spec, err := ParseSpec(specPath)
+	require.NoError(t, err)
+
+	d, err := Migration(spec, []string{"store_coffee", "coffee_availability"})
+	require.NoError(t, err)
+
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS store_coffee (
+  store_id INT NOT NULL REFERENCES store(id) ON DELETE CASCADE,
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  PRIMARY KEY (store_id, coffee_id)
+);
+CREATE INDEX IF NOT EXISTS store_coffee_coffee_id ON store_coffee (coffee_id);
+
+CREATE TABLE IF NOT EXISTS coffee_availability (
+  id SERIAL PRIMARY KEY,
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  from_time VARCHAR(5) NOT NULL DEFAULT '',
+  to_time VARCHAR(5) NOT NULL DEFAULT '',
+  start_date VARCHAR(5) NOT NULL DEFAULT '',
+  end_date VARCHAR(5) NOT NULL DEFAULT '',
+  days VARCHAR(27) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS coffee_availability_coffee_id ON coffee_availability (coffee_id);
+`, string(d))
+}
+
+func TestMigrationFailsForAnUnknownTable(t *testing.T) {
+	spec, err := ParseSpec(specPath)
+	require.NoError(t, err)
+
+	_, err = Migration(spec, []string{"espresso"})
+	assert.EqualError(t, err, "no entity is stored in table espresso")
+}
+
+func TestRunNeverOverwritesAMigration(t *testing.T) {
+	f, err := ioutil.TempFile("", "migration*.sql")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	err = run(specPath, f.Name(), "store")
+	assert.Error(t, err)
+}
+
+func TestParseSpecRejectsInvalidDefinitions(t *testing.T) {
+	tests := map[string]string{
+		"duplicate entity": `
+entity "Coffee" {}
+entity "Coffee" {}`,
+		"memdb without table": `
+entity "Coffee" {
+  memdb = "Coffee"
+}`,
+		"xml collection without an xml element": `
+entity "Coffee" {
+  collection     = "Coffees"
+  xml_collection = "coffees"
+}`,
+		"index of an unindexable field": `
+entity "Coffee" {
+  table = "coffee"
+  memdb = "Coffee"
+  field "Price" {
+    type = "float64"
+  }
+  index "price" {
+    fields = ["Price"]
+  }
+}`,
+		"trigram index of an int field": `
+entity "Coffee" {
+  table = "coffee"
+  memdb = "Coffee"
+  field "ID" {
+    type = "int"
+  }
+  index "id" {
+    fields  = ["ID"]
+    trigram = true
+  }
+}`,
+		"UUID index of an int field": `
+entity "Coffee" {
+  table = "coffee"
+  memdb = "Coffee"
+  field "ID" {
+    type = "int"
+  }
+  index "id" {
+    fields = ["ID"]
+    uuid   = true
+  }
+}`,
+		"first JSON field omitted when empty": `
+entity "Coffee" {
+  append_json = true
+  field "Name" {
+    type = "string"
+    json = "name,omitempty"
+  }
+}`,
+		"unencodable JSON field": `
+entity "Coffee" {
+  append_json = true
+  field "ID" {
+    type = "int"
+  }
+  field "CreatedAt" {
+    type = "time.Time"
+  }
+}`,
+	}
+
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "schema*.hcl")
+			require.NoError(t, err)
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(definition)
+			require.NoError(t, err)
+			f.Close()
+
+			_, err = ParseSpec(f.Name())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+)
+
+// Spec is the schema definition, every entity of the catalogue with its
+// fields, in memory indexes and SQL columns
+type Spec struct {
+	Entities []*Entity `hcl:"entity"`
+}
+
+// Entity is a struct of the entities package, stored in a table when Table is
+// set
+type Entity struct {
+	Name string `hcl:",key"`
+	Doc  string `hcl:"doc"`
+	// Collection names a slice type of the entity, none when empty
+	Collection string `hcl:"collection"`
+	// Table is the SQL table of the entity, also the name of its in memory
+	// table when MemDB is set
+	Table string `hcl:"table"`
+	// MemDB is the TableNameKey constant of the in memory table, the entity
+	// is not stored in memory when empty
+	MemDB string `hcl:"memdb"`
+	// AppendJSON generates a reflection free JSON encoder
+	AppendJSON bool `hcl:"append_json"`
+	// JSONSize is the typical size of the encoded entity, buffers for it are
+	// allocated with this capacity
+	JSONSize int `hcl:"json_size"`
+	// XML is the element of the entity in XML responses, it is named after
+	// the struct when empty
+	XML string `hcl:"xml"`
+	// XMLCollection is the element of the collection in XML responses, which
+	// holds an XML element per entity
+	XMLCollection string `hcl:"xml_collection"`
+
+	Fields  []*Field  `hcl:"field"`
+	Columns []*Column `hcl:"column"`
+	Indexes []*Index  `hcl:"index"`
+	// Constraints are the table constraints of CREATE TABLE, e.g. a compound
+	// primary key
+	Constraints []string `hcl:"constraints"`
+	// SQLAfter are statements run after CREATE TABLE, e.g. CREATE INDEX
+	SQLAfter []string `hcl:"sql_after"`
+}
+
+// Field is a field of an entity
+type Field struct {
+	Name string `hcl:",key"`
+	Doc  string `hcl:"doc"`
+	// Type is the Go type of the field, e.g. int, *time.Time or
+	// []CoffeeIngredients
+	Type string `hcl:"type"`
+	// Column is the db tag of the field, it has none when nil and - skips it
+	Column *string `hcl:"column"`
+	// JSON is the json tag of the field, e.g. - or name,omitempty
+	JSON string `hcl:"json"`
+	// XML is the xml tag of the field, e.g. ingredients>ingredient, the json
+	// tag is used when empty
+	XML string `hcl:"xml"`
+	// SQL is the definition of the column in CREATE TABLE, the field is not
+	// stored when empty
+	SQL string `hcl:"sql"`
+}
+
+// Column is a SQL column without a field, e.g. a list stored comma separated
+// and split into a field by the repository
+type Column struct {
+	Name string `hcl:",key"`
+	SQL  string `hcl:"sql"`
+}
+
+// Index is an index of the in memory table of an entity
+type Index struct {
+	Name string `hcl:",key"`
+	Doc  string `hcl:"doc"`
+	// Fields are the int or string fields indexed, compound when more than
+	// one
+	Fields       []string `hcl:"fields"`
+	Unique       bool     `hcl:"unique"`
+	AllowMissing bool     `hcl:"allow_missing"`
+	// Trigram indexes the trigrams of a single string field
+	Trigram bool `hcl:"trigram"`
+	// UUID indexes a single string field holding UUIDs in their 16 bytes
+	UUID bool `hcl:"uuid"`
+}
+
+// ParseSpec reads and validates the schema definition of a file
+func ParseSpec(path string) (*Spec, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{}
+	if err := hcl.Unmarshal(d, spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// entity returns the entity named name, nil when there is none
+func (s *Spec) entity(name string) *Entity {
+	for _, e := range s.Entities {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// validate checks that the entities are unique, that indexes refer to int or
+// string fields, trigram and UUID indexes to a single string field, and that
+// JSON encoders can encode every field
+func (s *Spec) validate() error {
+	names := map[string]bool{}
+	for _, e := range s.Entities {
+		if names[e.Name] {
+			return fmt.Errorf("entity %s is defined twice", e.Name)
+		}
+		names[e.Name] = true
+
+		if e.MemDB != "" && e.Table == "" {
+			return fmt.Errorf("entity %s has a memdb table but no table", e.Name)
+		}
+		if e.XMLCollection != "" && (e.Collection == "" || e.XML == "") {
+			return fmt.Errorf("entity %s has an xml collection but no collection or xml element", e.Name)
+		}
+
+		for _, i := range e.Indexes {
+			if len(i.Fields) == 0 || ((i.Trigram || i.UUID) && len(i.Fields) > 1) {
+				return fmt.Errorf("index %s of %s needs one field, or more when not a trigram or UUID index", i.Name, e.Name)
+			}
+			for _, name := range i.Fields {
+				f := e.field(name)
+				if f == nil || (f.Type != "int" && f.Type != "string") || ((i.Trigram || i.UUID) && f.Type != "string") {
+					return fmt.Errorf("index %s of %s refers to %s, which is not an indexable field", i.Name, e.Name, name)
+				}
+			}
+		}
+
+		if !e.AppendJSON {
+			continue
+		}
+		first := true
+		for _, f := range e.Fields {
+			name, omitEmpty := f.jsonName()
+			if name == "" {
+				continue
+			}
+			if first && omitEmpty {
+				return fmt.Errorf("the first JSON field of %s, %s, can not be omitted when empty", e.Name, f.Name)
+			}
+			first = false
+			if !s.encodable(f.Type) {
+				return fmt.Errorf("field %s of %s has type %s, which AppendJSON can not encode", f.Name, e.Name, f.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// encodable reports whether AppendJSON encodes a type
+func (s *Spec) encodable(t string) bool {
+	switch t {
+	case "int", "int64", "string", "float64":
+		return true
+	}
+	elem := strings.TrimPrefix(strings.TrimPrefix(t, "[]"), "*")
+	if e := s.entity(elem); elem != t && e != nil {
+		return e.AppendJSON
+	}
+	return false
+}
+
+// field returns the field named name, nil when there is none
+func (e *Entity) field(name string) *Field {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// jsonName returns the name of the field in JSON, empty when it is skipped,
+// and whether it is omitted when empty
+func (f *Field) jsonName() (string, bool) {
+	parts := strings.Split(f.JSON, ",")
+	if parts[0] == "-" {
+		return "", false
+	}
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// tags returns the struct tags of the field
+func (f *Field) tags() string {
+	var tags []string
+	if f.Column != nil {
+		tags = append(tags, fmt.Sprintf("db:%q", *f.Column))
+	}
+	if f.JSON != "" {
+		tags = append(tags, fmt.Sprintf("json:%q", f.JSON))
+	}
+	if xml := f.xmlTag(); xml != "" {
+		tags = append(tags, fmt.Sprintf("xml:%q", xml))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "`" + strings.Join(tags, " ") + "`"
+}
+
+// xmlTag returns the xml tag of the field, the json tag unless the field has
+// its own, so XML elements are named like the JSON fields
+func (f *Field) xmlTag() string {
+	if f.XML != "" || f.JSON == "" {
+		return f.XML
+	}
+
+	name, omitEmpty := f.jsonName()
+	switch {
+	case name == "":
+		return "-"
+	case omitEmpty:
+		return name + ",omitempty"
+	}
+	return name
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+type latencyKey struct{}
+
+// repositoryFrame starts the names of the methods of the repositories of
+// this package in stack frames, e.g.
+// github.com/hashicorp-demoapp/coffee-service/data.(*PostgresRepository).Find
+var repositoryFrame = reflect.TypeOf(latencyKey{}).PkgPath() + ".(*"
+
+// WithLatency returns a context delaying the repository calls made with it
+// by the rules of injector targeting repository methods
+func WithLatency(ctx context.Context, injector *latency.Injector) context.Context {
+	return context.WithValue(ctx, latencyKey{}, injector)
+}
+
+// injectLatency delays a query by the rule of the repository method making
+// it, if any. Every query of the method is delayed, by the rule of the
+// innermost method with one when repositories wrap each other.
+func injectLatency(ctx context.Context) {
+	injector, ok := ctx.Value(latencyKey{}).(*latency.Injector)
+	if !ok || !injector.Repository() {
+		return
+	}
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	targets := []string{}
+	for {
+		frame, more := frames.Next()
+		if method := repositoryMethod(frame.Function); method != "" {
+			targets = append(targets, latency.RepositoryPrefix+method)
+		}
+		if !more {
+			break
+		}
+	}
+	injector.Wait(ctx, targets...)
+}
+
+// repositoryMethod returns the exported repository method of a function name,
+// the method of the closures it runs included, or an empty string
+func repositoryMethod(function string) string {
+	if !strings.HasPrefix(function, repositoryFrame) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(function, repositoryFrame), ").", 2)
+	if len(parts) != 2 || !strings.HasSuffix(parts[0], "Repository") {
+		return ""
+	}
+	method := strings.SplitN(parts[1], ".", 2)[0]
+	if method == "" || !unicode.IsUpper([]rune(method)[0]) {
+		return ""
+	}
+	return method
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+func TestRepositoryMethod(t *testing.T) {
+	for function, expected := range map[string]string{
+		repositoryFrame + "PostgresRepository).FindByID":           "FindByID",
+		repositoryFrame + "PostgresRepository).RecordOrder.func1":  "RecordOrder",
+		repositoryFrame + "InMemoryRepository).get":                "",
+		repositoryFrame + "statementCache).get":                    "",
+		"github.com/hashicorp-demoapp/coffee-service/service.Find": "",
+	} {
+		assert.Equal(t, expected, repositoryMethod(function), function)
+	}
+}
+
+func TestLatencyDelaysRepositoryMethods(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	injector := latency.NewInjector(latency.Rules{"repository.FindByID": {Delay: 20 * time.Millisecond}})
+	ctx := WithLatency(context.Background(), injector)
+	ctx, stats := WithQueryStats(ctx)
+
+	start := time.Now()
+	_, err = NewPublished(r).FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(20*time.Millisecond))
+	// the latency counts as query time
+	assert.GreaterOrEqual(t, int64(stats.Duration()), int64(20*time.Millisecond))
+
+	start = time.Now()
+	_, err = r.FindIngredients(ctx)
+	require.NoError(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(20*time.Millisecond))
+}
@@ -0,0 +1,323 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// MeteredRepository is a Repository recording every call of the repository
+// it wraps. repository.calls counts the calls by method and result, which is
+// success, not_found when the call returned ErrNotFound, or error, so the
+// error rate of a method is the share of its calls with result error.
+// repository.latency samples their duration in milliseconds by method.
+//
+// The optional capabilities of the wrapped repository, e.g. stores or
+// coupons, are forwarded and recorded as well.
+type MeteredRepository struct {
+	Repository
+	sink metrics.Sink
+}
+
+// NewMetered wraps repository to record its calls in sink
+func NewMetered(repository Repository, sink metrics.Sink) *MeteredRepository {
+	return &MeteredRepository{Repository: repository, sink: sink}
+}
+
+// Unwrap returns the wrapped repository
+func (r *MeteredRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// IsConnected reports whether the wrapped repository is connected
+func (r *MeteredRepository) IsConnected(ctx context.Context) (connected bool, err error) {
+	defer r.observe("IsConnected", time.Now(), &err)
+	return r.Repository.IsConnected(ctx)
+}
+
+// Find returns all coffees of the wrapped repository
+func (r *MeteredRepository) Find(ctx context.Context) (coffees entities.Coffees, err error) {
+	defer r.observe("Find", time.Now(), &err)
+	return r.Repository.Find(ctx)
+}
+
+// FindByID returns a single coffee of the wrapped repository
+func (r *MeteredRepository) FindByID(ctx context.Context, coffeeID int) (coffee *entities.Coffee, err error) {
+	defer r.observe("FindByID", time.Now(), &err)
+	return r.Repository.FindByID(ctx, coffeeID)
+}
+
+// FindBySlug returns the coffee with the slug of the wrapped repository
+func (r *MeteredRepository) FindBySlug(ctx context.Context, slug string) (coffee *entities.Coffee, err error) {
+	defer r.observe("FindBySlug", time.Now(), &err)
+	return FindBySlug(ctx, r.Repository, slug)
+}
+
+// FindRelated returns the related coffees of the wrapped repository
+func (r *MeteredRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (coffees entities.Coffees, err error) {
+	defer r.observe("FindRelated", time.Now(), &err)
+	return r.Repository.FindRelated(ctx, coffeeID, limit)
+}
+
+// FindWhere returns the coffees of the wrapped repository matching expr
+func (r *MeteredRepository) FindWhere(ctx context.Context, expr filter.Expr) (coffees entities.Coffees, err error) {
+	defer r.observe("FindWhere", time.Now(), &err)
+	return r.Repository.FindWhere(ctx, expr)
+}
+
+// FindSimilar returns the coffees of the wrapped repository named like name
+func (r *MeteredRepository) FindSimilar(ctx context.Context, name string, threshold float64) (coffees entities.Coffees, err error) {
+	defer r.observe("FindSimilar", time.Now(), &err)
+	return FindSimilar(ctx, r.Repository, name, threshold)
+}
+
+// CreateCoffee inserts the coffee in the wrapped repository
+func (r *MeteredRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) (err error) {
+	defer r.observe("CreateCoffee", time.Now(), &err)
+	return r.Repository.CreateCoffee(ctx, coffee)
+}
+
+// UpdateCoffee replaces the coffee in the wrapped repository
+func (r *MeteredRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) (err error) {
+	defer r.observe("UpdateCoffee", time.Now(), &err)
+	return r.Repository.UpdateCoffee(ctx, coffee)
+}
+
+// DeleteCoffee removes the coffee from the wrapped repository
+func (r *MeteredRepository) DeleteCoffee(ctx context.Context, coffeeID int) (err error) {
+	defer r.observe("DeleteCoffee", time.Now(), &err)
+	return r.Repository.DeleteCoffee(ctx, coffeeID)
+}
+
+// DeleteWhere removes the coffees matching expr from the wrapped repository
+func (r *MeteredRepository) DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) (ids []int, err error) {
+	defer r.observe("DeleteWhere", time.Now(), &err)
+	return DeleteWhere(ctx, r.Repository, expr, dryRun)
+}
+
+// FindIngredients returns the ingredients of the wrapped repository
+func (r *MeteredRepository) FindIngredients(ctx context.Context) (ingredients entities.Ingredients, err error) {
+	defer r.observe("FindIngredients", time.Now(), &err)
+	return r.Repository.FindIngredients(ctx)
+}
+
+// CreateIngredient inserts the ingredient in the wrapped repository
+func (r *MeteredRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) (err error) {
+	defer r.observe("CreateIngredient", time.Now(), &err)
+	return r.Repository.CreateIngredient(ctx, ingredient)
+}
+
+// UpdateIngredient replaces the ingredient in the wrapped repository
+func (r *MeteredRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) (err error) {
+	defer r.observe("UpdateIngredient", time.Now(), &err)
+	return r.Repository.UpdateIngredient(ctx, ingredient)
+}
+
+// DeleteIngredient removes the ingredient from the wrapped repository
+func (r *MeteredRepository) DeleteIngredient(ctx context.Context, ingredientID int) (err error) {
+	defer r.observe("DeleteIngredient", time.Now(), &err)
+	return r.Repository.DeleteIngredient(ctx, ingredientID)
+}
+
+// FindTranslations returns the translations of the wrapped repository
+func (r *MeteredRepository) FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (translations entities.Translations, err error) {
+	defer r.observe("FindTranslations", time.Now(), &err)
+	return FindTranslations(ctx, r.Repository, coffeeIDs, locales)
+}
+
+// SetTranslation stores the translation in the wrapped repository
+func (r *MeteredRepository) SetTranslation(ctx context.Context, translation *entities.Translation) (err error) {
+	defer r.observe("SetTranslation", time.Now(), &err)
+	return SetTranslation(ctx, r.Repository, translation)
+}
+
+// DeleteTranslation removes the translation from the wrapped repository
+func (r *MeteredRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) (err error) {
+	defer r.observe("DeleteTranslation", time.Now(), &err)
+	return DeleteTranslation(ctx, r.Repository, coffeeID, locale, field)
+}
+
+// FindImages returns the images of the wrapped repository
+func (r *MeteredRepository) FindImages(ctx context.Context, coffeeIDs []int) (images entities.CoffeeImages, err error) {
+	defer r.observe("FindImages", time.Now(), &err)
+	return FindImages(ctx, r.Repository, coffeeIDs)
+}
+
+// SetImage stores the image in the wrapped repository
+func (r *MeteredRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) (err error) {
+	defer r.observe("SetImage", time.Now(), &err)
+	return SetImage(ctx, r.Repository, image)
+}
+
+// FindStores returns the stores of the wrapped repository
+func (r *MeteredRepository) FindStores(ctx context.Context) (stores entities.Stores, err error) {
+	defer r.observe("FindStores", time.Now(), &err)
+	return FindStores(ctx, r.Repository)
+}
+
+// FindStore returns a store of the wrapped repository
+func (r *MeteredRepository) FindStore(ctx context.Context, storeID int) (store *entities.Store, err error) {
+	defer r.observe("FindStore", time.Now(), &err)
+	return FindStore(ctx, r.Repository, storeID)
+}
+
+// FindStoreCoffees returns the coffees of a store of the wrapped repository
+func (r *MeteredRepository) FindStoreCoffees(ctx context.Context, storeID int) (ids []int, err error) {
+	defer r.observe("FindStoreCoffees", time.Now(), &err)
+	return FindStoreCoffees(ctx, r.Repository, storeID)
+}
+
+// FindStoresNear returns the stores of the wrapped repository near a point
+func (r *MeteredRepository) FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (stores entities.Stores, err error) {
+	defer r.observe("FindStoresNear", time.Now(), &err)
+	return FindStoresNear(ctx, r.Repository, lat, lon, radiusKm)
+}
+
+// FindSuppliers returns the suppliers of the wrapped repository
+func (r *MeteredRepository) FindSuppliers(ctx context.Context) (suppliers entities.Suppliers, err error) {
+	defer r.observe("FindSuppliers", time.Now(), &err)
+	return FindSuppliers(ctx, r.Repository, "")
+}
+
+// FindIngredientSupplier returns the supplier of an ingredient of the wrapped repository
+func (r *MeteredRepository) FindIngredientSupplier(ctx context.Context, ingredientID int) (supplier *entities.Supplier, err error) {
+	defer r.observe("FindIngredientSupplier", time.Now(), &err)
+	return FindIngredientSupplier(ctx, r.Repository, ingredientID)
+}
+
+// FindAvailability returns the availability rules of the wrapped repository
+func (r *MeteredRepository) FindAvailability(ctx context.Context, coffeeIDs []int) (rules entities.AvailabilityRules, err error) {
+	defer r.observe("FindAvailability", time.Now(), &err)
+	return FindAvailability(ctx, r.Repository, coffeeIDs)
+}
+
+// SetAvailability stores the availability rules in the wrapped repository
+func (r *MeteredRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) (err error) {
+	defer r.observe("SetAvailability", time.Now(), &err)
+	return SetAvailability(ctx, r.Repository, coffeeID, rules)
+}
+
+// FindCoupons returns the coupons of the wrapped repository
+func (r *MeteredRepository) FindCoupons(ctx context.Context) (coupons entities.Coupons, err error) {
+	defer r.observe("FindCoupons", time.Now(), &err)
+	return FindCoupons(ctx, r.Repository)
+}
+
+// FindCoupon returns a coupon of the wrapped repository
+func (r *MeteredRepository) FindCoupon(ctx context.Context, code string) (coupon *entities.Coupon, err error) {
+	defer r.observe("FindCoupon", time.Now(), &err)
+	return FindCoupon(ctx, r.Repository, code)
+}
+
+// CreateCoupon inserts the coupon in the wrapped repository
+func (r *MeteredRepository) CreateCoupon(ctx context.Context, coupon *entities.Coupon) (err error) {
+	defer r.observe("CreateCoupon", time.Now(), &err)
+	return CreateCoupon(ctx, r.Repository, coupon)
+}
+
+// UpdateCoupon replaces the coupon in the wrapped repository
+func (r *MeteredRepository) UpdateCoupon(ctx context.Context, coupon *entities.Coupon) (err error) {
+	defer r.observe("UpdateCoupon", time.Now(), &err)
+	return UpdateCoupon(ctx, r.Repository, coupon)
+}
+
+// DeleteCoupon removes the coupon from the wrapped repository
+func (r *MeteredRepository) DeleteCoupon(ctx context.Context, code string) (err error) {
+	defer r.observe("DeleteCoupon", time.Now(), &err)
+	return DeleteCoupon(ctx, r.Repository, code)
+}
+
+// RedeemCoupon counts a use of a coupon of the wrapped repository
+func (r *MeteredRepository) RedeemCoupon(ctx context.Context, code string, t time.Time) (coupon *entities.Coupon, err error) {
+	defer r.observe("RedeemCoupon", time.Now(), &err)
+	return RedeemCoupon(ctx, r.Repository, code, t)
+}
+
+// ReleaseCoupon gives back a use of a coupon of the wrapped repository
+func (r *MeteredRepository) ReleaseCoupon(ctx context.Context, code string) (err error) {
+	defer r.observe("ReleaseCoupon", time.Now(), &err)
+	return ReleaseCoupon(ctx, r.Repository, code)
+}
+
+// FindPoints returns the loyalty points of a user of the wrapped repository
+func (r *MeteredRepository) FindPoints(ctx context.Context, userID int) (points *entities.Points, err error) {
+	defer r.observe("FindPoints", time.Now(), &err)
+	return FindPoints(ctx, r.Repository, userID)
+}
+
+// RecordPoints records the points entry in the wrapped repository
+func (r *MeteredRepository) RecordPoints(ctx context.Context, entry *entities.PointsEntry) (balance int, err error) {
+	defer r.observe("RecordPoints", time.Now(), &err)
+	return RecordPoints(ctx, r.Repository, entry)
+}
+
+// FindUser returns the profile of a user of the wrapped repository
+func (r *MeteredRepository) FindUser(ctx context.Context, subject string) (user *entities.User, err error) {
+	defer r.observe("FindUser", time.Now(), &err)
+	return FindUser(ctx, r.Repository, subject)
+}
+
+// SaveUser saves the profile of a user in the wrapped repository
+func (r *MeteredRepository) SaveUser(ctx context.Context, user *entities.User) (err error) {
+	defer r.observe("SaveUser", time.Now(), &err)
+	return SaveUser(ctx, r.Repository, user)
+}
+
+// RecordOrder records the order in the wrapped repository
+func (r *MeteredRepository) RecordOrder(ctx context.Context, order *entities.OrderRecord) (err error) {
+	defer r.observe("RecordOrder", time.Now(), &err)
+	return RecordOrder(ctx, r.Repository, order)
+}
+
+// RecordOrderPoints records the order and its points entries in the wrapped
+// repository
+func (r *MeteredRepository) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) (err error) {
+	defer r.observe("RecordOrderPoints", time.Now(), &err)
+	return RecordOrderPoints(ctx, r.Repository, order, entries)
+}
+
+// AggregateStats returns the statistics of the wrapped repository
+func (r *MeteredRepository) AggregateStats(ctx context.Context, since time.Time, top int) (stats *entities.Stats, err error) {
+	defer r.observe("AggregateStats", time.Now(), &err)
+	return AggregateStats(ctx, r.Repository, since, top)
+}
+
+// SalesByHour returns the hourly sales of the wrapped repository
+func (r *MeteredRepository) SalesByHour(ctx context.Context, from, to time.Time) (buckets []entities.SalesBucket, err error) {
+	defer r.observe("SalesByHour", time.Now(), &err)
+	return salesByHour(ctx, r.Repository, from, to)
+}
+
+// ImportCoffees imports the coffees in the wrapped repository at once when
+// it is an Importer, otherwise creates them one by one
+func (r *MeteredRepository) ImportCoffees(ctx context.Context, coffees entities.Coffees) (err error) {
+	defer r.observe("ImportCoffees", time.Now(), &err)
+	if importer, ok := r.Repository.(Importer); ok {
+		return importer.ImportCoffees(ctx, coffees)
+	}
+	for n := range coffees {
+		if err := r.Repository.CreateCoffee(ctx, &coffees[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe records a call of method which started at start and returned *err
+func (r *MeteredRepository) observe(method string, start time.Time, err *error) {
+	result := "success"
+	switch {
+	case errors.Is(*err, ErrNotFound):
+		result = "not_found"
+	case *err != nil:
+		result = "error"
+	}
+
+	label := metrics.Label{Name: "method", Value: method}
+	r.sink.IncrCounter("repository.calls", 1, label, metrics.Label{Name: "result", Value: result})
+	metrics.MeasureSince(r.sink, "repository.latency", start, label)
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// failingFind fails every Find of the repository it wraps once failing is set
+type failingFind struct {
+	Repository
+	failing bool
+}
+
+func (f *failingFind) Find(ctx context.Context) (entities.Coffees, error) {
+	if f.failing {
+		return nil, errors.New("connection reset")
+	}
+	return f.Repository.Find(ctx)
+}
+
+func TestMeteredRepositoryRecordsCallsByMethodAndResult(t *testing.T) {
+	ctx := context.Background()
+	sink := metrics.NewPrometheusSink()
+
+	failing := &failingFind{Repository: setupInMemoryRepository(t)}
+	r := NewMetered(failing, sink)
+
+	_, err := r.Find(ctx)
+	require.NoError(t, err)
+	failing.failing = true
+	_, err = r.Find(ctx)
+	require.Error(t, err)
+	_, err = r.FindByID(ctx, 42)
+	assert.Equal(t, ErrNotFound, err)
+
+	rw := httptest.NewRecorder()
+	sink.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	body := rw.Body.String()
+
+	assert.Contains(t, body, `coffee_service_repository_calls_total{method="Find",result="success"} 1`)
+	assert.Contains(t, body, `coffee_service_repository_calls_total{method="Find",result="error"} 1`)
+	assert.Contains(t, body, `coffee_service_repository_calls_total{method="FindByID",result="not_found"} 1`)
+	assert.Contains(t, body, `coffee_service_repository_latency_count{method="Find"} 2`)
+}
+
+func TestMeteredRepositoryForwardsCapabilities(t *testing.T) {
+	ctx := context.Background()
+	sink := metrics.NewPrometheusSink()
+	r := NewMetered(setupInMemoryRepository(t), sink)
+
+	stores, err := FindStores(ctx, r)
+	require.NoError(t, err)
+	assert.Len(t, stores, 3)
+
+	coupon, err := FindCoupon(ctx, r, "WELCOME10")
+	require.NoError(t, err)
+	assert.Equal(t, "WELCOME10", coupon.Code)
+
+	require.NoError(t, GenerateCoffees(ctx, r, 3, 1))
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, 9)
+
+	rw := httptest.NewRecorder()
+	sink.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	for _, method := range []string{"FindStores", "FindCoupon", "ImportCoffees"} {
+		assert.True(t, strings.Contains(rw.Body.String(), `method="`+method+`",result="success"`), method)
+	}
+}
@@ -0,0 +1,408 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// MigratingOptions configure a MigratingRepository
+type MigratingOptions struct {
+	// ReadNew serves reads from the new backend instead of the old one
+	ReadNew bool
+	Logger  hclog.Logger
+	Metrics metrics.Sink
+}
+
+// MigratingRepository is a Repository moving the catalogue from an old
+// backend to a new one without downtime. Writes go to the old backend, which
+// stays the system of record, and then to the new one. Reads are served by
+// the primary backend, while the same read of the other backend is compared
+// with it in the background and divergences are logged.
+//
+// The backends assign their own IDs, so callers always see the IDs of the old
+// backend and the IDs of the new one are translated.
+type MigratingRepository struct {
+	from, to Repository
+	options  MigratingOptions
+	compare  *comparator
+
+	mu sync.RWMutex
+	// coffees and ingredients map the IDs of the old backend to the new one
+	coffees     map[int]int
+	ingredients map[int]int
+}
+
+// NewMigrating backfills the new backend with the catalogue of the old one
+// and returns a MigratingRepository over both
+func NewMigrating(ctx context.Context, from, to Repository, options MigratingOptions) (*MigratingRepository, error) {
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+	r := &MigratingRepository{
+		from:    from,
+		to:      to,
+		options: options,
+		compare: &comparator{prefix: "migration", logger: options.Logger, metrics: options.Metrics},
+	}
+
+	snapshot, err := Export(ctx, from, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to export the old backend: %w", err)
+	}
+	if _, err := Import(ctx, to, snapshot); err != nil {
+		return nil, fmt.Errorf("unable to backfill the new backend: %w", err)
+	}
+	if err := r.mapIDs(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// mapIDs matches the entities of the old backend in snapshot with the new
+// backend by name, as Import does
+func (r *MigratingRepository) mapIDs(ctx context.Context, snapshot *Snapshot) error {
+	ingredients, err := r.to.FindIngredients(ctx)
+	if err != nil {
+		return err
+	}
+	coffees, err := r.to.Find(ctx)
+	if err != nil {
+		return err
+	}
+	defer entities.PutCoffees(coffees)
+
+	ingredientIDs := make(map[string]int, len(ingredients))
+	for _, i := range ingredients {
+		ingredientIDs[i.Name] = i.ID
+	}
+	coffeeIDs := make(map[string]int, len(coffees))
+	for _, c := range coffees {
+		coffeeIDs[c.Name] = c.ID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ingredients = make(map[int]int, len(snapshot.Ingredients))
+	for _, i := range snapshot.Ingredients {
+		r.ingredients[i.ID] = ingredientIDs[i.Name]
+	}
+	r.coffees = make(map[int]int, len(snapshot.Coffees))
+	for _, c := range snapshot.Coffees {
+		r.coffees[c.ID] = coffeeIDs[c.Name]
+	}
+	return nil
+}
+
+// IsConnected reports whether both backends are connected
+func (r *MigratingRepository) IsConnected(ctx context.Context) (bool, error) {
+	if ok, err := r.from.IsConnected(ctx); !ok || err != nil {
+		return ok, err
+	}
+	return r.to.IsConnected(ctx)
+}
+
+// Find returns all coffees from the primary backend
+func (r *MigratingRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	return r.findCoffees(ctx, "Find", func(ctx context.Context, repository Repository) (entities.Coffees, error) {
+		return repository.Find(ctx)
+	})
+}
+
+// FindWhere returns the coffees matching expr from the primary backend. The
+// expression may refer to IDs, so the new backend is filtered after its IDs
+// are translated.
+func (r *MigratingRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	return r.findCoffees(ctx, "FindWhere", func(ctx context.Context, repository Repository) (entities.Coffees, error) {
+		if repository == r.from {
+			return repository.FindWhere(ctx, expr)
+		}
+
+		coffees, err := r.readNew(ctx)
+		if err != nil {
+			return nil, err
+		}
+		matches := coffees[:0]
+		for _, c := range coffees {
+			if filter.Match(expr, &c) {
+				matches = append(matches, c)
+			}
+		}
+		return matches, nil
+	})
+}
+
+// FindByID returns a single coffee from the primary backend
+func (r *MigratingRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	find := func(ctx context.Context, repository Repository) (entities.Coffees, error) {
+		if repository == r.from {
+			coffee, err := r.from.FindByID(ctx, coffeeID)
+			if err != nil {
+				return nil, err
+			}
+			return entities.Coffees{*coffee}, nil
+		}
+
+		id, ok := r.newID(r.coffees, coffeeID)
+		if !ok {
+			return nil, ErrNotFound
+		}
+		coffee, err := r.to.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		coffees := entities.Coffees{*coffee}
+		r.toOld(coffees)
+		return coffees, nil
+	}
+
+	coffees, err := r.findCoffees(ctx, "FindByID", find)
+	if err != nil {
+		return nil, err
+	}
+	return &coffees[0], nil
+}
+
+// FindRelated returns the related coffees from the old backend, the new
+// backend ranks them with its own IDs
+func (r *MigratingRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	return r.from.FindRelated(ctx, coffeeID, limit)
+}
+
+// FindIngredients returns the ingredients from the primary backend
+func (r *MigratingRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	find := func(ctx context.Context, repository Repository) (entities.Ingredients, error) {
+		ingredients, err := repository.FindIngredients(ctx)
+		if err != nil || repository == r.from {
+			return ingredients, err
+		}
+
+		old := r.reverse(r.ingredients)
+		translated := make(entities.Ingredients, 0, len(ingredients))
+		for _, i := range ingredients {
+			i.ID = old[i.ID]
+			translated = append(translated, i)
+		}
+		return translated, nil
+	}
+
+	primary, secondary := r.backends()
+	ingredients, err := find(ctx, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	r.compare.ingredients("FindIngredients", ingredients, func(ctx context.Context) (entities.Ingredients, error) {
+		return find(ctx, secondary)
+	})
+	return ingredients, nil
+}
+
+// CreateCoffee creates the coffee in the old backend and then in the new one
+func (r *MigratingRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := r.from.CreateCoffee(ctx, coffee); err != nil {
+		return err
+	}
+
+	created := r.toNew(*coffee)
+	if err := r.to.CreateCoffee(ctx, &created); err != nil {
+		r.writeFailed("CreateCoffee", err)
+		return nil
+	}
+	r.mu.Lock()
+	r.coffees[coffee.ID] = created.ID
+	r.mu.Unlock()
+	return nil
+}
+
+// UpdateCoffee updates the coffee in the old backend and then in the new one
+func (r *MigratingRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := r.from.UpdateCoffee(ctx, coffee); err != nil {
+		return err
+	}
+
+	updated := r.toNew(*coffee)
+	id, ok := r.newID(r.coffees, coffee.ID)
+	if !ok {
+		r.writeFailed("UpdateCoffee", ErrNotFound)
+		return nil
+	}
+	updated.ID = id
+	if err := r.to.UpdateCoffee(ctx, &updated); err != nil {
+		r.writeFailed("UpdateCoffee", err)
+	}
+	return nil
+}
+
+// DeleteCoffee deletes the coffee from the old backend and then from the new
+// one
+func (r *MigratingRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	if err := r.from.DeleteCoffee(ctx, coffeeID); err != nil {
+		return err
+	}
+
+	id, ok := r.newID(r.coffees, coffeeID)
+	if !ok {
+		r.writeFailed("DeleteCoffee", ErrNotFound)
+		return nil
+	}
+	if err := r.to.DeleteCoffee(ctx, id); err != nil {
+		r.writeFailed("DeleteCoffee", err)
+	}
+	return nil
+}
+
+// CreateIngredient creates the ingredient in the old backend and then in the
+// new one
+func (r *MigratingRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := r.from.CreateIngredient(ctx, ingredient); err != nil {
+		return err
+	}
+
+	created := *ingredient
+	if err := r.to.CreateIngredient(ctx, &created); err != nil {
+		r.writeFailed("CreateIngredient", err)
+		return nil
+	}
+	r.mu.Lock()
+	r.ingredients[ingredient.ID] = created.ID
+	r.mu.Unlock()
+	return nil
+}
+
+// UpdateIngredient updates the ingredient in the old backend and then in the
+// new one
+func (r *MigratingRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := r.from.UpdateIngredient(ctx, ingredient); err != nil {
+		return err
+	}
+
+	id, ok := r.newID(r.ingredients, ingredient.ID)
+	if !ok {
+		r.writeFailed("UpdateIngredient", ErrNotFound)
+		return nil
+	}
+	updated := *ingredient
+	updated.ID = id
+	if err := r.to.UpdateIngredient(ctx, &updated); err != nil {
+		r.writeFailed("UpdateIngredient", err)
+	}
+	return nil
+}
+
+// DeleteIngredient deletes the ingredient from the old backend and then from
+// the new one
+func (r *MigratingRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	if err := r.from.DeleteIngredient(ctx, ingredientID); err != nil {
+		return err
+	}
+
+	id, ok := r.newID(r.ingredients, ingredientID)
+	if !ok {
+		r.writeFailed("DeleteIngredient", ErrNotFound)
+		return nil
+	}
+	if err := r.to.DeleteIngredient(ctx, id); err != nil {
+		r.writeFailed("DeleteIngredient", err)
+	}
+	return nil
+}
+
+// Unwrap returns the old backend, the system of record whose coffee IDs the
+// callers see, which alone serves the optional capabilities until the
+// migration completes
+func (r *MigratingRepository) Unwrap() Repository {
+	return r.from
+}
+
+// backends returns the primary and the secondary backend
+func (r *MigratingRepository) backends() (Repository, Repository) {
+	if r.options.ReadNew {
+		return r.to, r.from
+	}
+	return r.from, r.to
+}
+
+// findCoffees reads coffees from the primary backend with find and compares
+// them with the secondary backend in the background
+func (r *MigratingRepository) findCoffees(ctx context.Context, method string, find func(context.Context, Repository) (entities.Coffees, error)) (entities.Coffees, error) {
+	primary, secondary := r.backends()
+	coffees, err := find(ctx, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	r.compare.coffees(method, coffees, func(ctx context.Context) (entities.Coffees, error) {
+		return find(ctx, secondary)
+	})
+	return coffees, nil
+}
+
+// readNew returns every coffee of the new backend with the IDs of the old one
+func (r *MigratingRepository) readNew(ctx context.Context) (entities.Coffees, error) {
+	coffees, err := r.to.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.toOld(coffees)
+	return coffees, nil
+}
+
+// writeFailed records a write the new backend missed, the old backend is the
+// system of record so the write still succeeds
+func (r *MigratingRepository) writeFailed(method string, err error) {
+	r.options.Metrics.IncrCounter("migration.write.failures", 1, metrics.Label{Name: "method", Value: method})
+	r.options.Logger.Error("Unable to write to the new backend", "method", method, "error", err)
+}
+
+// newID translates an ID of the old backend
+func (r *MigratingRepository) newID(ids map[int]int, id int) (int, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	newID, ok := ids[id]
+	return newID, ok
+}
+
+// reverse returns ids mapping the IDs of the new backend to the old one
+func (r *MigratingRepository) reverse(ids map[int]int) map[int]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reversed := make(map[int]int, len(ids))
+	for from, to := range ids {
+		reversed[to] = from
+	}
+	return reversed
+}
+
+// toNew returns a copy of coffee with the ingredient IDs of the new backend
+func (r *MigratingRepository) toNew(coffee entities.Coffee) entities.Coffee {
+	ingredients := make([]entities.CoffeeIngredients, 0, len(coffee.Ingredients))
+	for _, i := range coffee.Ingredients {
+		i.IngredientID, _ = r.newID(r.ingredients, i.IngredientID)
+		ingredients = append(ingredients, i)
+	}
+	coffee.Ingredients = ingredients
+	return coffee
+}
+
+// toOld translates the coffee and ingredient IDs of coffees read from the new
+// backend
+func (r *MigratingRepository) toOld(coffees entities.Coffees) {
+	coffeeIDs, ingredientIDs := r.reverse(r.coffees), r.reverse(r.ingredients)
+	for n := range coffees {
+		coffees[n].ID = coffeeIDs[coffees[n].ID]
+		for i := range coffees[n].Ingredients {
+			coffees[n].Ingredients[i].IngredientID = ingredientIDs[coffees[n].Ingredients[i].IngredientID]
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package data
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// setupMigrating migrates between two in memory repositories whose IDs
+// differ, as the old one has used more sequence numbers
+func setupMigrating(t *testing.T, readNew bool) (*MigratingRepository, Repository, Repository, *metrics.PrometheusSink) {
+	ctx := context.Background()
+	from, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	to, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	unused := &entities.Ingredient{Name: "Unused"}
+	require.NoError(t, from.CreateIngredient(ctx, unused))
+	require.NoError(t, from.DeleteIngredient(ctx, unused.ID))
+	require.NoError(t, from.CreateIngredient(ctx, &entities.Ingredient{Name: "Oat Milk", Quantity: 100, Unit: "ml"}))
+
+	sink := metrics.NewPrometheusSink()
+	r, err := NewMigrating(ctx, from, to, MigratingOptions{ReadNew: readNew, Logger: hclog.NewNullLogger(), Metrics: sink})
+	require.NoError(t, err)
+	return r, from, to, sink
+}
+
+// exported waits for the background comparisons to export a metric
+func exported(t *testing.T, sink *metrics.PrometheusSink, metric string) bool {
+	return assert.Eventually(t, func() bool {
+		rw := httptest.NewRecorder()
+		sink.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+		return strings.Contains(rw.Body.String(), metric)
+	}, time.Second, 10*time.Millisecond, metric)
+}
+
+func TestMigratingWritesToBothBackends(t *testing.T) {
+	ctx := context.Background()
+	r, from, to, _ := setupMigrating(t, false)
+
+	ingredients, err := r.FindIngredients(ctx)
+	require.NoError(t, err)
+	oat := ingredients[len(ingredients)-1]
+	require.Equal(t, "Oat Milk", oat.Name)
+
+	coffee := &entities.Coffee{Name: "Oat Latte", Price: 250, Ingredients: []entities.CoffeeIngredients{{IngredientID: oat.ID, Quantity: 200, Unit: "ml"}}}
+	require.NoError(t, r.CreateCoffee(ctx, coffee))
+
+	for _, repository := range []Repository{from, to} {
+		coffees, err := repository.Find(ctx)
+		require.NoError(t, err)
+		normalized := normalizeCoffees(coffees)
+		assert.Contains(t, normalized["Oat Latte"], "ingredients=[Oat Milk 200ml]")
+	}
+
+	coffee.Price = 300
+	require.NoError(t, r.UpdateCoffee(ctx, coffee))
+	require.NoError(t, r.DeleteIngredient(ctx, oat.ID))
+
+	fromCoffees, err := from.Find(ctx)
+	require.NoError(t, err)
+	toCoffees, err := to.Find(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, diff(normalizeCoffees(fromCoffees), normalizeCoffees(toCoffees)))
+}
+
+func TestMigratingReadsTheNewBackendWithOldIDs(t *testing.T) {
+	ctx := context.Background()
+	r, from, _, sink := setupMigrating(t, true)
+
+	ingredients, err := from.FindIngredients(ctx)
+	require.NoError(t, err)
+	oat := ingredients[len(ingredients)-1]
+
+	coffee := &entities.Coffee{Name: "Oat Latte", Price: 200, Ingredients: []entities.CoffeeIngredients{{IngredientID: oat.ID, Quantity: 200, Unit: "ml"}}}
+	require.NoError(t, r.CreateCoffee(ctx, coffee))
+
+	found, err := r.FindByID(ctx, coffee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, coffee.ID, found.ID)
+	assert.Equal(t, oat.ID, found.Ingredients[0].IngredientID)
+
+	migrated, err := r.FindIngredients(ctx)
+	require.NoError(t, err)
+	require.Len(t, migrated, len(ingredients))
+	for n := range ingredients {
+		assert.Equal(t, ingredients[n].ID, migrated[n].ID)
+		assert.Equal(t, ingredients[n].Name, migrated[n].Name)
+	}
+
+	exported(t, sink, `coffee_service_migration_comparisons_total{method="FindByID"} 1`)
+	exported(t, sink, `coffee_service_migration_comparisons_total{method="FindIngredients"} 1`)
+}
+
+func TestMigratingReportsDivergences(t *testing.T) {
+	ctx := context.Background()
+	r, _, to, sink := setupMigrating(t, false)
+
+	// a write the migration did not see
+	require.NoError(t, to.CreateCoffee(ctx, &entities.Coffee{Name: "Rogue", Price: 200}))
+
+	_, err := r.Find(ctx)
+	require.NoError(t, err)
+	exported(t, sink, `coffee_service_migration_divergences_total{method="Find"} 1`)
+}
+
+func TestMigratingServesTheCapabilitiesOfTheOldBackend(t *testing.T) {
+	ctx := context.Background()
+	r, from, to, _ := setupMigrating(t, true)
+
+	coupon := &entities.Coupon{Code: "MIGRATED", Type: entities.CouponFixed, Value: 1}
+	require.NoError(t, CreateCoupon(ctx, r, coupon))
+	_, err := FindCoupon(ctx, from, "MIGRATED")
+	require.NoError(t, err)
+	_, err = FindCoupon(ctx, to, "MIGRATED")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: TranslationName, Value: "Café"}))
+	translations, err := FindTranslations(ctx, r, []int{1}, nil)
+	require.NoError(t, err)
+	require.Len(t, translations, 1)
+	assert.Equal(t, "Café", translations[0].Value)
+}
@@ -0,0 +1,31 @@
+-- Schema of the coffee tables in the HashiCups products database
+CREATE TABLE IF NOT EXISTS coffee (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  teaser VARCHAR(255) NOT NULL,
+  description VARCHAR(255) NOT NULL,
+  price NUMERIC NOT NULL,
+  image VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ingredient (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  quantity INT NOT NULL DEFAULT 0,
+  unit VARCHAR(50) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coffee_ingredient (
+  id SERIAL PRIMARY KEY,
+  coffee_id INT REFERENCES coffee(id),
+  ingredient_id INT REFERENCES ingredient(id),
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP
+);
@@ -0,0 +1,32 @@
+-- Seed data matching the catalogue loaded by the in-memory repository
+INSERT INTO ingredient (id, name, created_at, updated_at) VALUES
+  (1, 'Espresso''', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (2, 'Semi Skimmed Milk', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (3, 'Hot Water', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (4, 'Pumpkin Spice', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (5, 'Steamed Milk', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+INSERT INTO coffee (id, name, teaser, description, price, image, created_at, updated_at) VALUES
+  (1, 'Packer Spiced Latte', 'Packed with goodness to spice up your images', '', 350, '/packer.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (2, 'Vaulatte', 'Nothing gives you a safe and secure feeling like a Vaulatte', '', 200, '/vault.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (3, 'Nomadicano', 'Drink one today and you will want to schedule another', '', 150, '/nomad.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (4, 'Terraspresso', 'Nothing kickstarts your day like a provision of Terraspresso', '', 150, '/terraform.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (5, 'Vagrante espresso', 'Stdin is not a tty', '', 200, '/vagrant.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (6, 'Connectaccino', 'Discover the wonders of our meshy service', '', 250, '/consul.png', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+INSERT INTO coffee_ingredient (id, coffee_id, ingredient_id, created_at, updated_at) VALUES
+  (1, 1, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (2, 1, 2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (3, 1, 4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (4, 2, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (5, 2, 2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (6, 3, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (7, 3, 3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (8, 4, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (9, 5, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (10, 6, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (11, 6, 5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+SELECT setval('ingredient_id_seq', (SELECT MAX(id) FROM ingredient));
+SELECT setval('coffee_id_seq', (SELECT MAX(id) FROM coffee));
+SELECT setval('coffee_ingredient_id_seq', (SELECT MAX(id) FROM coffee_ingredient));
@@ -0,0 +1,9 @@
+-- Quantity of every ingredient in a coffee, e.g. 40ml of espresso
+ALTER TABLE coffee_ingredient ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE coffee_ingredient ADD COLUMN IF NOT EXISTS unit VARCHAR(50) NOT NULL DEFAULT '';
+
+UPDATE coffee_ingredient SET quantity=40, unit='ml' WHERE id IN (1, 4, 8, 9, 10);
+UPDATE coffee_ingredient SET quantity=300, unit='ml' WHERE id IN (2, 5);
+UPDATE coffee_ingredient SET quantity=5, unit='g' WHERE id=3;
+UPDATE coffee_ingredient SET quantity=20, unit='ml' WHERE id=6;
+UPDATE coffee_ingredient SET quantity=100, unit='ml' WHERE id IN (7, 11);
@@ -0,0 +1,3 @@
+-- Trigram index of the coffee names, used to find near duplicate names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS coffee_name_trigrams ON coffee USING gin (name gin_trgm_ops);
@@ -0,0 +1,26 @@
+-- URL safe slug of every coffee, e.g. packer-spiced-latte for /coffees/{slug}.
+-- Existing coffees get the slug data.Slugify generates from their name, with
+-- -2, -3 and so on appended to repeated and reserved ones.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+ALTER TABLE coffee ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+
+WITH base AS (
+  SELECT id, trim(both '-' from regexp_replace(lower(unaccent(name)), '[^a-z0-9]+', '-', 'g')) AS slug
+  FROM coffee WHERE slug IS NULL
+), valid AS (
+  SELECT id, CASE
+    WHEN slug = '' THEN 'coffee'
+    WHEN slug ~ '^[0-9-]+$' THEN 'coffee-' || slug
+    ELSE slug
+  END AS slug
+  FROM base
+), ranked AS (
+  SELECT id, slug,
+    row_number() OVER (PARTITION BY slug ORDER BY id) + CASE WHEN slug IN ('trending', 'suggest') THEN 1 ELSE 0 END AS n
+  FROM valid
+)
+UPDATE coffee SET slug = CASE WHEN ranked.n = 1 THEN ranked.slug ELSE ranked.slug || '-' || ranked.n END
+FROM ranked WHERE coffee.id = ranked.id;
+
+ALTER TABLE coffee ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS coffee_slug ON coffee (slug);
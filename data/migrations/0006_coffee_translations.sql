@@ -0,0 +1,10 @@
+-- Translations of the coffee names and teasers, one row per coffee, locale
+-- and field. They are deleted with their coffee.
+CREATE TABLE IF NOT EXISTS coffee_translation (
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  locale VARCHAR(35) NOT NULL,
+  field VARCHAR(50) NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (coffee_id, locale, field)
+);
@@ -0,0 +1,6 @@
+-- Publishing state of every coffee. Existing coffees are on the menu, so they
+-- are published, while new ones start as drafts.
+ALTER TABLE coffee ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+  CHECK (status IN ('draft', 'published', 'retired'));
+ALTER TABLE coffee ALTER COLUMN status SET DEFAULT 'draft';
+CREATE INDEX IF NOT EXISTS coffee_status ON coffee (status);
@@ -0,0 +1,13 @@
+-- Windows in which a coffee is on the menu, any number per coffee. Days are
+-- comma separated, times are HH:MM and dates MM-DD, an empty pair leaves that
+-- condition out. They are deleted with their coffee.
+CREATE TABLE IF NOT EXISTS coffee_availability (
+  id SERIAL PRIMARY KEY,
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  days VARCHAR(27) NOT NULL DEFAULT '',
+  from_time VARCHAR(5) NOT NULL DEFAULT '',
+  to_time VARCHAR(5) NOT NULL DEFAULT '',
+  start_date VARCHAR(5) NOT NULL DEFAULT '',
+  end_date VARCHAR(5) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS coffee_availability_coffee_id ON coffee_availability (coffee_id);
@@ -0,0 +1,38 @@
+-- Coffee shops and the coffees on the menu of each, seeded with the demo
+-- stores loaded by the in-memory repository
+CREATE TABLE IF NOT EXISTS store (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  address VARCHAR(255) NOT NULL,
+  city VARCHAR(255) NOT NULL,
+  country VARCHAR(2) NOT NULL,
+  latitude DOUBLE PRECISION NOT NULL,
+  longitude DOUBLE PRECISION NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS store_coffee (
+  store_id INT NOT NULL REFERENCES store(id) ON DELETE CASCADE,
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  PRIMARY KEY (store_id, coffee_id)
+);
+CREATE INDEX IF NOT EXISTS store_coffee_coffee_id ON store_coffee (coffee_id);
+
+INSERT INTO store (id, name, address, city, country, latitude, longitude, created_at, updated_at) VALUES
+  (1, 'Market Street', '101 Market Street', 'San Francisco', 'US', 37.7925, -122.3966, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (2, 'Prinsengracht', 'Prinsengracht 263', 'Amsterdam', 'NL', 52.3752, 4.8840, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (3, 'Shoreditch', '1 Curtain Road', 'London', 'GB', 51.5226, -0.08, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval('store_id_seq', (SELECT MAX(id) FROM store));
+
+-- only the seeded coffees which still exist
+INSERT INTO store_coffee (store_id, coffee_id)
+SELECT m.store_id, m.coffee_id FROM (VALUES
+  (1, 1), (1, 2), (1, 3), (1, 4), (1, 5), (1, 6),
+  (2, 2), (2, 3), (2, 4), (2, 5),
+  (3, 1), (3, 3), (3, 6)
+) AS m (store_id, coffee_id)
+JOIN coffee c ON c.id = m.coffee_id
+ON CONFLICT DO NOTHING;
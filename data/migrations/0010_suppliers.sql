@@ -0,0 +1,33 @@
+-- Suppliers of the ingredients with their country of origin and comma
+-- separated certifications, seeded with the demo suppliers loaded by the
+-- in-memory repository. An ingredient has at most one supplier.
+CREATE TABLE IF NOT EXISTS supplier (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  country VARCHAR(2) NOT NULL,
+  certifications VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ingredient_supplier (
+  ingredient_id INT PRIMARY KEY REFERENCES ingredient(id) ON DELETE CASCADE,
+  supplier_id INT NOT NULL REFERENCES supplier(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS ingredient_supplier_supplier_id ON ingredient_supplier (supplier_id);
+
+INSERT INTO supplier (id, name, country, certifications, created_at, updated_at) VALUES
+  (1, 'Finca La Esperanza', 'CO', 'organic,fairtrade', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (2, 'Meadowbank Dairy', 'GB', 'organic', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+  (3, 'Malabar Spice Traders', 'IN', '', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval('supplier_id_seq', (SELECT MAX(id) FROM supplier));
+
+-- only the seeded ingredients which still exist, hot water has no supplier
+INSERT INTO ingredient_supplier (ingredient_id, supplier_id)
+SELECT m.ingredient_id, m.supplier_id FROM (VALUES
+  (1, 1), (2, 2), (4, 3), (5, 2)
+) AS m (ingredient_id, supplier_id)
+JOIN ingredient i ON i.id = m.ingredient_id
+ON CONFLICT DO NOTHING;
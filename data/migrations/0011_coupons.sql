@@ -0,0 +1,17 @@
+-- Discount coupons keyed by their upper case code, seeded with the demo
+-- coupon loaded by the in-memory repository. A usage_limit of 0 is
+-- unlimited and a NULL expires_at never expires.
+CREATE TABLE IF NOT EXISTS coupon (
+  code VARCHAR(32) PRIMARY KEY,
+  type VARCHAR(16) NOT NULL,
+  value NUMERIC(10,2) NOT NULL,
+  expires_at TIMESTAMPTZ,
+  usage_limit INT NOT NULL DEFAULT 0,
+  used INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO coupon (code, type, value, created_at, updated_at) VALUES
+  ('WELCOME10', 'percent', 10, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,19 @@
+-- The loyalty points ledger of the product-api users. points_balance keeps
+-- the sum of the entries of a user so that a debit is checked and applied
+-- with a single conditional update, the CHECK guarding against any other
+-- path below 0.
+CREATE TABLE IF NOT EXISTS points_balance (
+  user_id INT PRIMARY KEY,
+  balance INT NOT NULL DEFAULT 0 CHECK (balance >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS points_entry (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  order_id INT NOT NULL,
+  points INT NOT NULL,
+  reason VARCHAR(16) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS points_entry_user_id ON points_entry (user_id);
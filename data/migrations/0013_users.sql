@@ -0,0 +1,10 @@
+-- The profiles of users, keyed by the subject of their token. The default
+-- store is forgotten when the store is deleted.
+CREATE TABLE IF NOT EXISTS users (
+  subject VARCHAR(255) PRIMARY KEY,
+  display_name VARCHAR(64) NOT NULL,
+  favorite_milk VARCHAR(16) NOT NULL DEFAULT '',
+  default_store_id INT REFERENCES store(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,19 @@
+-- The orders created through the coffee-service, recorded for the admin
+-- statistics as the product-api owns the orders. Items keep the name and
+-- price of their coffee when ordered, so deleted coffees still rank.
+CREATE TABLE IF NOT EXISTS order_record (
+  id INT PRIMARY KEY,
+  status VARCHAR(16) NOT NULL,
+  total NUMERIC(10,2) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS order_record_created_at ON order_record (created_at);
+
+CREATE TABLE IF NOT EXISTS order_record_item (
+  order_id INT NOT NULL REFERENCES order_record(id) ON DELETE CASCADE,
+  coffee_id INT NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  quantity INT NOT NULL,
+  price NUMERIC(10,2) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS order_record_item_order_id ON order_record_item (order_id);
@@ -0,0 +1,14 @@
+-- The orders and revenue per hour, but cancelled orders, kept up to date with
+-- the order records for the sales time series. Hours start on the hour UTC.
+CREATE TABLE IF NOT EXISTS sales_hour (
+  hour TIMESTAMPTZ PRIMARY KEY,
+  orders INT NOT NULL,
+  revenue NUMERIC(12,2) NOT NULL
+);
+
+INSERT INTO sales_hour (hour, orders, revenue)
+  SELECT date_trunc('hour', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', COUNT(*), SUM(total)
+  FROM order_record
+  WHERE status<>'cancelled'
+  GROUP BY 1
+ON CONFLICT (hour) DO NOTHING;
@@ -0,0 +1,11 @@
+-- The sizes of the coffee images, the uploaded image and the variants resized
+-- from it, one row per coffee and size. They are deleted with their coffee.
+CREATE TABLE IF NOT EXISTS coffee_image (
+  coffee_id INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE,
+  size VARCHAR(20) NOT NULL,
+  url VARCHAR(255) NOT NULL,
+  width INT NOT NULL,
+  height INT NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (coffee_id, size)
+);
@@ -0,0 +1,13 @@
+-- The background jobs queued with JOB_QUEUE=postgres, see the jobs package.
+-- A job is due from run_at, which dequeueing pushes past the lease of the
+-- worker handling it, and is deleted once handled.
+CREATE TABLE IF NOT EXISTS job (
+  id BIGSERIAL PRIMARY KEY,
+  kind VARCHAR(64) NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS job_kind_run_at ON job (kind, run_at);
@@ -0,0 +1,11 @@
+-- The versions of data/migrations applied to the database, checked against
+-- the version the code expects at startup. Every migration from this one on
+-- records its own version last, so applying the files with psql or with
+-- DB_MIGRATE=true leaves the same rows. Databases migrated before this table
+-- existed are at the version of the latest migration whose objects they have.
+CREATE TABLE IF NOT EXISTS schema_version (
+  version INT PRIMARY KEY,
+  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_version (version) VALUES (18) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,8 @@
+-- The UUIDv7 identifiers of the coupons, order records and points entries
+-- inserted with ENTITY_IDS=uuidv7, NULL for the rows inserted without. They
+-- sit next to the keys, which the product-api and the ledger order depend on.
+ALTER TABLE coupon ADD COLUMN IF NOT EXISTS uuid UUID UNIQUE;
+ALTER TABLE order_record ADD COLUMN IF NOT EXISTS uuid UUID UNIQUE;
+ALTER TABLE points_entry ADD COLUMN IF NOT EXISTS uuid UUID UNIQUE;
+
+INSERT INTO schema_version (version) VALUES (19) ON CONFLICT (version) DO NOTHING;
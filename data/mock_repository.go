@@ -1,23 +1,124 @@
 package data
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
 )
 
-// MockRepository is a mock connection object for unit tests.
+// MockRepository is a mock connection object for unit tests. Contexts are
+// not recorded as call arguments so expectations can ignore them.
 type MockRepository struct {
 	mock.Mock
 }
 
 // Find mock stub
-func (r *MockRepository) Find() (entities.Coffees, error) {
+func (r *MockRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	args := r.Called()
+
+	// handlers return the lists to the pool, the expected list is copied so
+	// it is not cleared
+	if m, ok := args.Get(0).(entities.Coffees); ok {
+		return append(make(entities.Coffees, 0, len(m)), m...), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// FindRelated mock stub
+func (r *MockRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	args := r.Called(coffeeID, limit)
+
+	if m, ok := args.Get(0).(entities.Coffees); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// IsConnected mock stub
+func (r *MockRepository) IsConnected(ctx context.Context) (bool, error) {
 	args := r.Called()
 
+	return args.Bool(0), args.Error(1)
+}
+
+// FindWhere mock stub
+func (r *MockRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	args := r.Called(expr)
+
+	// handlers return the lists to the pool, the expected list is copied so
+	// it is not cleared
 	if m, ok := args.Get(0).(entities.Coffees); ok {
+		return append(make(entities.Coffees, 0, len(m)), m...), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// FindByID mock stub
+func (r *MockRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	args := r.Called(coffeeID)
+
+	if m, ok := args.Get(0).(*entities.Coffee); ok {
 		return m, args.Error(1)
 	}
 
 	return nil, args.Error(1)
 }
+
+// CreateCoffee mock stub
+func (r *MockRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	args := r.Called(coffee)
+
+	return args.Error(0)
+}
+
+// UpdateCoffee mock stub
+func (r *MockRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	args := r.Called(coffee)
+
+	return args.Error(0)
+}
+
+// DeleteCoffee mock stub
+func (r *MockRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	args := r.Called(coffeeID)
+
+	return args.Error(0)
+}
+
+// FindIngredients mock stub
+func (r *MockRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	args := r.Called()
+
+	if m, ok := args.Get(0).(entities.Ingredients); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// CreateIngredient mock stub
+func (r *MockRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	args := r.Called(ingredient)
+
+	return args.Error(0)
+}
+
+// UpdateIngredient mock stub
+func (r *MockRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	args := r.Called(ingredient)
+
+	return args.Error(0)
+}
+
+// DeleteIngredient mock stub
+func (r *MockRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	args := r.Called(ingredientID)
+
+	return args.Error(0)
+}
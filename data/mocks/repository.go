@@ -0,0 +1,297 @@
+// Package mocks provides test doubles of the data package for unit testing
+// handlers without a database or the in memory repository.
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// Call is a call made to a Repository, its method and arguments but the
+// context
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Repository is a fake data.Repository with scriptable responses. A method
+// returns the response of its Func when set, otherwise the response of Next
+// when set, otherwise zero values. Every call is recorded and errors can be
+// injected on chosen calls with FailOn and FailFrom, which take precedence
+// over the responses. The optional repository capabilities, e.g. stores or
+// coupons, are not implemented.
+type Repository struct {
+	FindFunc        func(ctx context.Context) (entities.Coffees, error)
+	FindByIDFunc    func(ctx context.Context, coffeeID int) (*entities.Coffee, error)
+	FindRelatedFunc func(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error)
+	FindWhereFunc   func(ctx context.Context, expr filter.Expr) (entities.Coffees, error)
+	IsConnectedFunc func(ctx context.Context) (bool, error)
+
+	CreateCoffeeFunc func(ctx context.Context, coffee *entities.Coffee) error
+	UpdateCoffeeFunc func(ctx context.Context, coffee *entities.Coffee) error
+	DeleteCoffeeFunc func(ctx context.Context, coffeeID int) error
+
+	FindIngredientsFunc  func(ctx context.Context) (entities.Ingredients, error)
+	CreateIngredientFunc func(ctx context.Context, ingredient *entities.Ingredient) error
+	UpdateIngredientFunc func(ctx context.Context, ingredient *entities.Ingredient) error
+	DeleteIngredientFunc func(ctx context.Context, ingredientID int) error
+
+	// Next answers the calls of methods without a Func, e.g. an in memory
+	// repository to inject failures into
+	Next data.Repository
+
+	mu       sync.Mutex
+	calls    []Call
+	failures map[string][]failure
+}
+
+// failure is an error injected on the calls of a method numbered from n,
+// only on the nth call unless repeat is set
+type failure struct {
+	n      int
+	repeat bool
+	err    error
+}
+
+// FailOn makes the nth call of method return err, counting calls from 1
+func (r *Repository) FailOn(method string, n int, err error) {
+	r.addFailure(method, failure{n: n, err: err})
+}
+
+// FailFrom makes every call of method from the nth one on return err,
+// counting calls from 1
+func (r *Repository) FailFrom(method string, n int, err error) {
+	r.addFailure(method, failure{n: n, repeat: true, err: err})
+}
+
+func (r *Repository) addFailure(method string, f failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures == nil {
+		r.failures = map[string][]failure{}
+	}
+	r.failures[method] = append(r.failures[method], f)
+}
+
+// Calls returns the calls made so far, in order
+func (r *Repository) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// CallCount returns how often method has been called
+func (r *Repository) CallCount(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.count(method)
+}
+
+func (r *Repository) count(method string) int {
+	n := 0
+	for _, c := range r.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset forgets the calls and the injected failures
+func (r *Repository) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = nil
+	r.failures = nil
+}
+
+// record records a call and returns the error injected on it, if any
+func (r *Repository) record(method string, args ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+	n := r.count(method)
+	for _, f := range r.failures[method] {
+		if n == f.n || (f.repeat && n > f.n) {
+			return f.err
+		}
+	}
+	return nil
+}
+
+// Find fake
+func (r *Repository) Find(ctx context.Context) (entities.Coffees, error) {
+	if err := r.record("Find"); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.FindFunc != nil:
+		return r.FindFunc(ctx)
+	case r.Next != nil:
+		return r.Next.Find(ctx)
+	}
+	return nil, nil
+}
+
+// FindByID fake
+func (r *Repository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	if err := r.record("FindByID", coffeeID); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.FindByIDFunc != nil:
+		return r.FindByIDFunc(ctx, coffeeID)
+	case r.Next != nil:
+		return r.Next.FindByID(ctx, coffeeID)
+	}
+	return nil, nil
+}
+
+// FindRelated fake
+func (r *Repository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	if err := r.record("FindRelated", coffeeID, limit); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.FindRelatedFunc != nil:
+		return r.FindRelatedFunc(ctx, coffeeID, limit)
+	case r.Next != nil:
+		return r.Next.FindRelated(ctx, coffeeID, limit)
+	}
+	return nil, nil
+}
+
+// FindWhere fake
+func (r *Repository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	if err := r.record("FindWhere", expr); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.FindWhereFunc != nil:
+		return r.FindWhereFunc(ctx, expr)
+	case r.Next != nil:
+		return r.Next.FindWhere(ctx, expr)
+	}
+	return nil, nil
+}
+
+// IsConnected fake, reporting a connection unless scripted otherwise
+func (r *Repository) IsConnected(ctx context.Context) (bool, error) {
+	if err := r.record("IsConnected"); err != nil {
+		return false, err
+	}
+	switch {
+	case r.IsConnectedFunc != nil:
+		return r.IsConnectedFunc(ctx)
+	case r.Next != nil:
+		return r.Next.IsConnected(ctx)
+	}
+	return true, nil
+}
+
+// CreateCoffee fake
+func (r *Repository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := r.record("CreateCoffee", coffee); err != nil {
+		return err
+	}
+	switch {
+	case r.CreateCoffeeFunc != nil:
+		return r.CreateCoffeeFunc(ctx, coffee)
+	case r.Next != nil:
+		return r.Next.CreateCoffee(ctx, coffee)
+	}
+	return nil
+}
+
+// UpdateCoffee fake
+func (r *Repository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := r.record("UpdateCoffee", coffee); err != nil {
+		return err
+	}
+	switch {
+	case r.UpdateCoffeeFunc != nil:
+		return r.UpdateCoffeeFunc(ctx, coffee)
+	case r.Next != nil:
+		return r.Next.UpdateCoffee(ctx, coffee)
+	}
+	return nil
+}
+
+// DeleteCoffee fake
+func (r *Repository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	if err := r.record("DeleteCoffee", coffeeID); err != nil {
+		return err
+	}
+	switch {
+	case r.DeleteCoffeeFunc != nil:
+		return r.DeleteCoffeeFunc(ctx, coffeeID)
+	case r.Next != nil:
+		return r.Next.DeleteCoffee(ctx, coffeeID)
+	}
+	return nil
+}
+
+// FindIngredients fake
+func (r *Repository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	if err := r.record("FindIngredients"); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.FindIngredientsFunc != nil:
+		return r.FindIngredientsFunc(ctx)
+	case r.Next != nil:
+		return r.Next.FindIngredients(ctx)
+	}
+	return nil, nil
+}
+
+// CreateIngredient fake
+func (r *Repository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := r.record("CreateIngredient", ingredient); err != nil {
+		return err
+	}
+	switch {
+	case r.CreateIngredientFunc != nil:
+		return r.CreateIngredientFunc(ctx, ingredient)
+	case r.Next != nil:
+		return r.Next.CreateIngredient(ctx, ingredient)
+	}
+	return nil
+}
+
+// UpdateIngredient fake
+func (r *Repository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := r.record("UpdateIngredient", ingredient); err != nil {
+		return err
+	}
+	switch {
+	case r.UpdateIngredientFunc != nil:
+		return r.UpdateIngredientFunc(ctx, ingredient)
+	case r.Next != nil:
+		return r.Next.UpdateIngredient(ctx, ingredient)
+	}
+	return nil
+}
+
+// DeleteIngredient fake
+func (r *Repository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	if err := r.record("DeleteIngredient", ingredientID); err != nil {
+		return err
+	}
+	switch {
+	case r.DeleteIngredientFunc != nil:
+		return r.DeleteIngredientFunc(ctx, ingredientID)
+	case r.Next != nil:
+		return r.Next.DeleteIngredient(ctx, ingredientID)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+var errInjected = errors.New("injected")
+
+func TestRepositoryReturnsZeroValuesWhenNotScripted(t *testing.T) {
+	r := &Repository{}
+
+	coffees, err := r.Find(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, coffees)
+
+	ok, err := r.IsConnected(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRepositoryReturnsScriptedResponses(t *testing.T) {
+	r := &Repository{
+		FindByIDFunc: func(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+			return &entities.Coffee{ID: coffeeID, Name: "Vaulatte"}, nil
+		},
+	}
+
+	coffee, err := r.FindByID(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Vaulatte", coffee.Name)
+}
+
+func TestRepositoryRecordsCalls(t *testing.T) {
+	r := &Repository{}
+	coffee := &entities.Coffee{Name: "Vaulatte"}
+
+	r.CreateCoffee(context.Background(), coffee)
+	r.FindRelated(context.Background(), 1, 3)
+	r.FindRelated(context.Background(), 2, 3)
+
+	assert.Equal(t, []Call{
+		{Method: "CreateCoffee", Args: []interface{}{coffee}},
+		{Method: "FindRelated", Args: []interface{}{1, 3}},
+		{Method: "FindRelated", Args: []interface{}{2, 3}},
+	}, r.Calls())
+	assert.Equal(t, 2, r.CallCount("FindRelated"))
+	assert.Equal(t, 0, r.CallCount("Find"))
+
+	r.Reset()
+	assert.Empty(t, r.Calls())
+}
+
+func TestRepositoryFailsOnTheNthCall(t *testing.T) {
+	r := &Repository{}
+	r.FailOn("DeleteCoffee", 2, errInjected)
+
+	assert.NoError(t, r.DeleteCoffee(context.Background(), 1))
+	assert.Equal(t, errInjected, r.DeleteCoffee(context.Background(), 2))
+	assert.NoError(t, r.DeleteCoffee(context.Background(), 3))
+	assert.NoError(t, r.DeleteIngredient(context.Background(), 2))
+}
+
+func TestRepositoryFailsFromTheNthCall(t *testing.T) {
+	r := &Repository{}
+	r.FailFrom("IsConnected", 2, errInjected)
+
+	_, err := r.IsConnected(context.Background())
+	assert.NoError(t, err)
+	for n := 0; n < 3; n++ {
+		ok, err := r.IsConnected(context.Background())
+		assert.Equal(t, errInjected, err)
+		assert.False(t, ok)
+	}
+}
+
+func TestRepositoryInjectsFailuresIntoNext(t *testing.T) {
+	next, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	r := &Repository{Next: next}
+	r.FailOn("Find", 1, errInjected)
+
+	_, err = r.Find(context.Background())
+	assert.Equal(t, errInjected, err)
+
+	coffees, err := r.Find(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, coffees)
+}
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// driverName is the database/sql driver of the pgx stdlib package
+const driverName = "pgx"
+
+// coffeeColumns are the columns of the coffee table in the order
+// scanCoffee reads them
+const coffeeColumns = "id, name, slug, teaser, description, price, image, status, created_at, updated_at, deleted_at"
+
+// errNoPgxConn is returned for connections not made by the pgx driver, e.g.
+// connections wrapped by the tracing driver
+var errNoPgxConn = errors.New("connection is not a pgx connection")
+
+// withPgxConn runs fn with a native pgx connection taken from the pool, for
+// the features database/sql lacks, such as batches and COPY
+func (r *PostgresRepository) withPgxConn(ctx context.Context, fn func(conn *pgx.Conn) error) (err error) {
+	defer func() { r.checkConnection(err) }()
+
+	db, _ := r.pool()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errNoPgxConn
+		}
+		return fn(c.Conn())
+	})
+}
+
+// findCoffeesBatch loads the coffees matching the where clause and their
+// ingredients in a single round trip. The ingredients are selected with the
+// same clause in a subquery, so both queries share the arguments. The batch
+// runs in an implicit transaction, which limits its statements to the
+// deadline of ctx.
+func (r *PostgresRepository) findCoffeesBatch(ctx context.Context, where string, args ...interface{}) (entities.Coffees, error) {
+	defer recordQuery(ctx, time.Now(), "SELECT "+coffeeColumns+" FROM coffee "+where, args...)
+
+	coffees := entities.GetCoffees()
+	err := r.withPgxConn(ctx, func(conn *pgx.Conn) error {
+		batch := &pgx.Batch{}
+		ms, timeout := statementTimeout(ctx)
+		if timeout {
+			batch.Queue("SELECT set_config('statement_timeout', $1, true)", strconv.FormatInt(ms, 10))
+		}
+		batch.Queue("SELECT "+coffeeColumns+" FROM coffee "+where, args...)
+		batch.Queue(coffeeIngredientsQuery+`
+		WHERE ci.coffee_id IN (SELECT id FROM coffee `+where+`)
+		ORDER BY ci.id`, args...)
+
+		results := conn.SendBatch(ctx, batch)
+		defer results.Close()
+
+		if timeout {
+			if _, err := results.Exec(); err != nil {
+				return err
+			}
+		}
+
+		rows, err := results.Query()
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			coffee, err := scanCoffee(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			coffees = append(coffees, coffee)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		ingredients := []entities.CoffeeIngredients{}
+		rows, err = results.Query()
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			i := entities.CoffeeIngredients{}
+			if err := rows.Scan(&i.CoffeeID, &i.IngredientID, &i.Quantity, &i.Unit, &i.Name); err != nil {
+				rows.Close()
+				return err
+			}
+			ingredients = append(ingredients, i)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		coffees = attachIngredients(coffees, ingredients)
+		return results.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return coffees, nil
+}
+
+// scanCoffee reads a row of coffeeColumns. Timestamps are formatted like
+// database/sql formats them for the string fields of the entities.
+func scanCoffee(rows pgx.Rows) (entities.Coffee, error) {
+	coffee := entities.Coffee{}
+	var createdAt, updatedAt time.Time
+
+	err := rows.Scan(&coffee.ID, &coffee.Name, &coffee.Slug, &coffee.Teaser, &coffee.Description, &coffee.Price, &coffee.Image, &coffee.Status,
+		&createdAt, &updatedAt, &coffee.DeletedAt)
+	if err != nil {
+		return coffee, err
+	}
+
+	coffee.CreatedAt = createdAt.Format(time.RFC3339Nano)
+	coffee.UpdatedAt = updatedAt.Format(time.RFC3339Nano)
+	return coffee, nil
+}
+
+// ImportCoffees creates many coffees and their ingredients at once with COPY,
+// assigning the coffee IDs and the timestamps. The ingredient names are not
+// loaded. It falls back to creating the coffees one by one when no native
+// pgx connection is available.
+func (r *PostgresRepository) ImportCoffees(ctx context.Context, coffees entities.Coffees) error {
+	for n := range coffees {
+		if err := coffees[n].Validate(); err != nil {
+			return err
+		}
+	}
+
+	err := r.withPgxConn(ctx, func(conn *pgx.Conn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		// COPY cannot return the IDs it assigns, so they are reserved first
+		rows, err := tx.Query(ctx, "SELECT nextval(pg_get_serial_sequence('coffee', 'id')) FROM generate_series(1, $1)", len(coffees))
+		if err != nil {
+			return err
+		}
+		ids := make([]int, 0, len(coffees))
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// the slugs are generated next to the ones already taken
+		rows, err = tx.Query(ctx, "SELECT slug FROM coffee")
+		if err != nil {
+			return err
+		}
+		taken := map[string]bool{}
+		for rows.Next() {
+			var slug string
+			if err := rows.Scan(&slug); err != nil {
+				rows.Close()
+				return err
+			}
+			taken[slug] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		coffeeRows := make([][]interface{}, 0, len(coffees))
+		ingredientRows := make([][]interface{}, 0)
+		for n := range coffees {
+			c := &coffees[n]
+			c.ID = ids[n]
+			c.CreatedAt = now.Format(time.RFC3339Nano)
+			c.UpdatedAt = c.CreatedAt
+			c.Slug, _ = slugFor(nil, c.Name, func(slug string) (bool, error) { return taken[slug], nil })
+			taken[c.Slug] = true
+			if c.Status, err = initialStatus(c.Status); err != nil {
+				return err
+			}
+			coffeeRows = append(coffeeRows, []interface{}{c.ID, c.Name, c.Slug, c.Teaser, c.Description, c.Price, c.Image, c.Status, now, now})
+
+			for i := range c.Ingredients {
+				ingredient := &c.Ingredients[i]
+				ingredient.CoffeeID = c.ID
+				ingredientRows = append(ingredientRows, []interface{}{c.ID, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit, now, now})
+			}
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"coffee"},
+			[]string{"id", "name", "slug", "teaser", "description", "price", "image", "status", "created_at", "updated_at"},
+			pgx.CopyFromRows(coffeeRows))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"coffee_ingredient"},
+			[]string{"coffee_id", "ingredient_id", "quantity", "unit", "created_at", "updated_at"},
+			pgx.CopyFromRows(ingredientRows))
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+	if err != errNoPgxConn {
+		return err
+	}
+
+	for n := range coffees {
+		if err := r.CreateCoffee(ctx, &coffees[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+var (
+	// ErrPointsUnsupported is returned when recording the loyalty points of
+	// a repository which does not store them
+	ErrPointsUnsupported = errors.New("loyalty points are not supported by this backend")
+	// ErrInvalidPoints is returned for a points entry without a user, or
+	// whose points do not match its reason
+	ErrInvalidPoints = errors.New("invalid points entry")
+	// ErrInsufficientPoints is returned when a debit exceeds the balance of
+	// the user
+	ErrInsufficientPoints = errors.New("insufficient loyalty points")
+)
+
+// PointsLedger is implemented by repositories storing the loyalty points
+// ledger of users
+type PointsLedger interface {
+	// FindPoints returns the balance and the ledger of a user, empty for a
+	// user without entries
+	FindPoints(ctx context.Context, userID int) (*entities.Points, error)
+	// RecordPoints appends an entry to the ledger of its user and applies it
+	// to the balance in a single transaction, so that concurrent debits never
+	// take the balance below 0. It assigns the ID and the time of the entry
+	// and returns the new balance, or ErrInsufficientPoints.
+	RecordPoints(ctx context.Context, entry *entities.PointsEntry) (int, error)
+}
+
+// OrderLedger is implemented by repositories recording the orders and the
+// loyalty points ledger in the same database
+type OrderLedger interface {
+	// RecordOrderPoints records an order like RecordOrder and appends the
+	// points entries of the order like RecordPoints, in a single
+	// transaction, so that no points are debited or credited for an order
+	// which is not recorded. Nothing is recorded when a debit exceeds the
+	// balance, it returns ErrInsufficientPoints.
+	RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error
+}
+
+// FindPoints returns the loyalty points of a user of a PointsLedger, or
+// ErrPointsUnsupported
+func FindPoints(ctx context.Context, r Repository, userID int) (*entities.Points, error) {
+	var ledger interface {
+		FindPoints(ctx context.Context, userID int) (*entities.Points, error)
+	}
+	if !As(r, &ledger) {
+		return nil, ErrPointsUnsupported
+	}
+	return ledger.FindPoints(ctx, userID)
+}
+
+// RecordPoints validates an entry and records it in a PointsLedger. Earned
+// and refunded points are credits, redeemed points debits.
+func RecordPoints(ctx context.Context, r Repository, entry *entities.PointsEntry) (int, error) {
+	var ledger interface {
+		RecordPoints(ctx context.Context, entry *entities.PointsEntry) (int, error)
+	}
+	if !As(r, &ledger) {
+		return 0, ErrPointsUnsupported
+	}
+	if err := validPoints(entry); err != nil {
+		return 0, err
+	}
+	return ledger.RecordPoints(ctx, entry)
+}
+
+// RecordOrderPoints validates an order record and its points entries and
+// records them in an OrderLedger. An order without entries is recorded with
+// RecordOrder.
+func RecordOrderPoints(ctx context.Context, r Repository, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	if len(entries) == 0 {
+		return RecordOrder(ctx, r, order)
+	}
+	var ledger interface {
+		RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error
+	}
+	if !As(r, &ledger) {
+		return ErrPointsUnsupported
+	}
+	if order.Validate() != nil {
+		return ErrInvalidOrderRecord
+	}
+	for _, entry := range entries {
+		if err := validPoints(entry); err != nil {
+			return err
+		}
+	}
+	return ledger.RecordOrderPoints(ctx, order, entries)
+}
+
+// validPoints checks the user of an entry and the sign of its points
+func validPoints(entry *entities.PointsEntry) error {
+	if entry.UserID <= 0 {
+		return ErrInvalidPoints
+	}
+	switch entry.Reason {
+	case entities.PointsEarned, entities.PointsRefunded:
+		if entry.Points <= 0 {
+			return ErrInvalidPoints
+		}
+	case entities.PointsRedeemed:
+		if entry.Points >= 0 {
+			return ErrInvalidPoints
+		}
+	default:
+		return ErrInvalidPoints
+	}
+	return nil
+}
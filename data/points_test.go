@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testPoints verifies a Repository keeps the loyalty points ledger of users
+func testPoints(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	points, err := FindPoints(ctx, r, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, points.Balance)
+	assert.Empty(t, points.Ledger)
+
+	entry := &entities.PointsEntry{UserID: 1, OrderID: 8, Points: 400, Reason: entities.PointsEarned}
+	balance, err := RecordPoints(ctx, r, entry)
+	require.NoError(t, err)
+	assert.Equal(t, 400, balance)
+	assert.NotZero(t, entry.ID)
+	assert.False(t, entry.CreatedAt.IsZero())
+
+	balance, err = RecordPoints(ctx, r, &entities.PointsEntry{UserID: 1, OrderID: 9, Points: -150, Reason: entities.PointsRedeemed})
+	require.NoError(t, err)
+	assert.Equal(t, 250, balance)
+	_, err = RecordPoints(ctx, r, &entities.PointsEntry{UserID: 1, OrderID: 10, Points: -251, Reason: entities.PointsRedeemed})
+	assert.Equal(t, ErrInsufficientPoints, err)
+	_, err = RecordPoints(ctx, r, &entities.PointsEntry{UserID: 2, OrderID: 10, Points: -1, Reason: entities.PointsRedeemed})
+	assert.Equal(t, ErrInsufficientPoints, err)
+	balance, err = RecordPoints(ctx, r, &entities.PointsEntry{UserID: 1, OrderID: 9, Points: 150, Reason: entities.PointsRefunded})
+	require.NoError(t, err)
+	assert.Equal(t, 400, balance)
+
+	for _, invalid := range []entities.PointsEntry{
+		{UserID: 0, Points: 1, Reason: entities.PointsEarned},
+		{UserID: 1, Points: -1, Reason: entities.PointsEarned},
+		{UserID: 1, Points: 1, Reason: entities.PointsRedeemed},
+		{UserID: 1, Points: 1, Reason: "gift"},
+	} {
+		invalid := invalid
+		_, err := RecordPoints(ctx, r, &invalid)
+		assert.Equal(t, ErrInvalidPoints, err, invalid)
+	}
+
+	points, err = FindPoints(ctx, r, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 400, points.Balance)
+	require.Len(t, points.Ledger, 3)
+	assert.Equal(t, entities.PointsEarned, points.Ledger[0].Reason)
+	assert.Equal(t, -150, points.Ledger[1].Points)
+	assert.Equal(t, entities.PointsRefunded, points.Ledger[2].Reason)
+
+	points, err = FindPoints(ctx, r, 2)
+	require.NoError(t, err)
+	assert.Empty(t, points.Ledger)
+}
+
+// testPointsAreDebitedAtomically verifies concurrent debits never take a
+// balance below 0
+func testPointsAreDebitedAtomically(t *testing.T, r Repository) {
+	ctx := context.Background()
+	_, err := RecordPoints(ctx, r, &entities.PointsEntry{UserID: 3, OrderID: 1, Points: 50, Reason: entities.PointsEarned})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	debited, insufficient := 0, 0
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(orderID int) {
+			defer wg.Done()
+			_, err := RecordPoints(ctx, r, &entities.PointsEntry{UserID: 3, OrderID: orderID, Points: -10, Reason: entities.PointsRedeemed})
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				debited++
+			case ErrInsufficientPoints:
+				insufficient++
+			default:
+				t.Error(err)
+			}
+		}(n + 2)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, debited)
+	assert.Equal(t, 15, insufficient)
+	points, err := FindPoints(ctx, r, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 0, points.Balance)
+	assert.Len(t, points.Ledger, 6)
+}
+
+// testOrderPoints verifies a Repository records an order and its points
+// entries together or not at all
+func testOrderPoints(t *testing.T, r Repository) {
+	ctx := context.Background()
+	_, err := RecordPoints(ctx, r, &entities.PointsEntry{UserID: 4, OrderID: 1, Points: 100, Reason: entities.PointsEarned})
+	require.NoError(t, err)
+	order := func(status string) *entities.OrderRecord {
+		return &entities.OrderRecord{ID: 20, Status: status, Total: 3, CreatedAt: time.Now(), Items: []entities.OrderRecordItem{{CoffeeID: 1, Name: "Packer Spiced Latte", Quantity: 1, Price: 3.5}}}
+	}
+	redeemed := func(points int) []*entities.PointsEntry {
+		return []*entities.PointsEntry{{UserID: 4, OrderID: 20, Points: -points, Reason: entities.PointsRedeemed}}
+	}
+	orders := func() map[string]int {
+		stats, err := AggregateStats(ctx, r, time.Time{}, 1)
+		require.NoError(t, err)
+		return stats.OrdersByStatus
+	}
+	balance := func() int {
+		points, err := FindPoints(ctx, r, 4)
+		require.NoError(t, err)
+		return points.Balance
+	}
+	before := orders()
+
+	// neither the order nor the debit is recorded
+	assert.Equal(t, ErrInsufficientPoints, RecordOrderPoints(ctx, r, order(entities.OrderCreated), redeemed(150)))
+	assert.Equal(t, before, orders())
+	assert.Equal(t, 100, balance())
+	assert.Equal(t, ErrInvalidOrderRecord, RecordOrderPoints(ctx, r, order("lost"), redeemed(50)))
+	assert.Equal(t, 100, balance())
+
+	require.NoError(t, RecordOrderPoints(ctx, r, order(entities.OrderCreated), redeemed(50)))
+	assert.Equal(t, before[entities.OrderCreated]+1, orders()[entities.OrderCreated])
+	assert.Equal(t, 50, balance())
+
+	// the cancelled order gets its points back
+	refunded := []*entities.PointsEntry{{UserID: 4, OrderID: 20, Points: 50, Reason: entities.PointsRefunded}}
+	require.NoError(t, RecordOrderPoints(ctx, r, order(entities.OrderCancelled), refunded))
+	assert.Equal(t, before[entities.OrderCreated], orders()[entities.OrderCreated])
+	assert.Equal(t, before[entities.OrderCancelled]+1, orders()[entities.OrderCancelled])
+	assert.Equal(t, 100, balance())
+	assert.NotZero(t, refunded[0].ID)
+}
+
+func TestInMemoryPoints(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testPoints(t, r)
+}
+
+func TestInMemoryOrderPoints(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testOrderPoints(t, r)
+}
+
+func TestInMemoryPointsAreDebitedAtomically(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testPointsAreDebitedAtomically(t, r)
+}
+
+func TestPointsPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testPoints(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+	testOrderPoints(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+}
+
+func TestPointsUnsupported(t *testing.T) {
+	_, err := FindPoints(context.Background(), &MockRepository{}, 1)
+	assert.Equal(t, ErrPointsUnsupported, err)
+	_, err = RecordPoints(context.Background(), &MockRepository{}, &entities.PointsEntry{})
+	assert.Equal(t, ErrPointsUnsupported, err)
+	err = RecordOrderPoints(context.Background(), &MockRepository{}, &entities.OrderRecord{}, []*entities.PointsEntry{{}})
+	assert.Equal(t, ErrPointsUnsupported, err)
+}
@@ -0,0 +1,286 @@
+// Package popularity counts coffee views and orders and ranks coffees by a
+// time decayed trending score.
+package popularity
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+const (
+	// HalfLife is how long it takes a trending score to decay by half
+	HalfLife = 24 * time.Hour
+	// ViewWeight is added to the trending score for every view
+	ViewWeight = 1.0
+	// OrderWeight is added to the trending score for every order
+	OrderWeight = 5.0
+	// FlushInterval is how often Run persists the counters
+	FlushInterval = 30 * time.Second
+)
+
+// counters hold the statistics of a single coffee. The totals are updated
+// atomically, the decayed score is guarded by mu.
+type counters struct {
+	views  int64
+	orders int64
+
+	mu      sync.Mutex
+	score   float64
+	updated time.Time
+}
+
+// record is the persisted form of counters
+type record struct {
+	Views   int64     `json:"views"`
+	Orders  int64     `json:"orders"`
+	Score   float64   `json:"score"`
+	Updated time.Time `json:"updated"`
+}
+
+// Tracker counts views and orders per coffee
+type Tracker struct {
+	mu       sync.RWMutex
+	counters map[int]*counters
+	path     string
+	logger   hclog.Logger
+	now      func() time.Time
+
+	listenersMu sync.RWMutex
+	listeners   []func(coffeeID int, stats entities.CoffeeStats)
+}
+
+// NewTracker creates a Tracker persisting its counters to path, loading any
+// previously persisted counters. An empty path keeps the counters in memory.
+func NewTracker(path string, l hclog.Logger) (*Tracker, error) {
+	t := &Tracker{
+		counters: map[int]*counters{},
+		path:     path,
+		logger:   l,
+		now:      time.Now,
+	}
+
+	if path == "" {
+		return t, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[int]record{}
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, err
+	}
+	for id, r := range records {
+		t.counters[id] = &counters{views: r.Views, orders: r.Orders, score: r.Score, updated: r.Updated}
+	}
+
+	return t, nil
+}
+
+// OnChange registers a listener called after every view or order
+func (t *Tracker) OnChange(listener func(coffeeID int, stats entities.CoffeeStats)) {
+	t.listenersMu.Lock()
+	defer t.listenersMu.Unlock()
+
+	t.listeners = append(t.listeners, listener)
+}
+
+// RecordView counts a view of a coffee
+func (t *Tracker) RecordView(coffeeID int) {
+	c := t.get(coffeeID)
+	atomic.AddInt64(&c.views, 1)
+	t.bump(coffeeID, c, ViewWeight)
+}
+
+// RecordOrder counts an order of a coffee
+func (t *Tracker) RecordOrder(coffeeID int) {
+	c := t.get(coffeeID)
+	atomic.AddInt64(&c.orders, 1)
+	t.bump(coffeeID, c, OrderWeight)
+}
+
+// Stats returns the statistics of a coffee
+func (t *Tracker) Stats(coffeeID int) entities.CoffeeStats {
+	t.mu.RLock()
+	c, ok := t.counters[coffeeID]
+	t.mu.RUnlock()
+
+	if !ok {
+		return entities.CoffeeStats{}
+	}
+
+	return t.stats(c)
+}
+
+// Annotate sets the Stats of each coffee
+func (t *Tracker) Annotate(coffees entities.Coffees) {
+	for n := range coffees {
+		stats := t.Stats(coffees[n].ID)
+		coffees[n].Stats = &stats
+	}
+}
+
+// Trending returns up to limit coffee IDs ordered by decayed score
+func (t *Tracker) Trending(limit int) []int {
+	t.mu.RLock()
+	type scored struct {
+		id    int
+		score float64
+	}
+	all := make([]scored, 0, len(t.counters))
+	for id, c := range t.counters {
+		all = append(all, scored{id, t.stats(c).Score})
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].id < all[j].id
+	})
+
+	ids := make([]int, 0, limit)
+	for _, s := range all {
+		if len(ids) == limit || s.score == 0 {
+			break
+		}
+		ids = append(ids, s.id)
+	}
+
+	return ids
+}
+
+// Flush persists the counters
+func (t *Tracker) Flush() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.mu.RLock()
+	records := make(map[int]record, len(t.counters))
+	for id, c := range t.counters {
+		c.mu.Lock()
+		records[id] = record{
+			Views:   atomic.LoadInt64(&c.views),
+			Orders:  atomic.LoadInt64(&c.orders),
+			Score:   c.score,
+			Updated: c.updated,
+		}
+		c.mu.Unlock()
+	}
+	t.mu.RUnlock()
+
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves partial data
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, t.path)
+}
+
+// Run flushes the counters every interval until done is closed, flushing a
+// final time before returning.
+func (t *Tracker) Run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			if err := t.Flush(); err != nil {
+				t.logger.Error("Unable to persist popularity counters", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				t.logger.Error("Unable to persist popularity counters", "error", err)
+			}
+		}
+	}
+}
+
+func (t *Tracker) get(coffeeID int) *counters {
+	t.mu.RLock()
+	c, ok := t.counters[coffeeID]
+	t.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c, ok = t.counters[coffeeID]; !ok {
+		c = &counters{}
+		t.counters[coffeeID] = c
+	}
+	return c
+}
+
+func (t *Tracker) bump(coffeeID int, c *counters, weight float64) {
+	now := t.now()
+
+	c.mu.Lock()
+	c.score = decay(c.score, c.updated, now) + weight
+	c.updated = now
+	c.mu.Unlock()
+
+	t.listenersMu.RLock()
+	defer t.listenersMu.RUnlock()
+
+	if len(t.listeners) == 0 {
+		return
+	}
+	stats := t.stats(c)
+	for _, listener := range t.listeners {
+		listener(coffeeID, stats)
+	}
+}
+
+func (t *Tracker) stats(c *counters) entities.CoffeeStats {
+	c.mu.Lock()
+	score := decay(c.score, c.updated, t.now())
+	c.mu.Unlock()
+
+	return entities.CoffeeStats{
+		Views:  atomic.LoadInt64(&c.views),
+		Orders: atomic.LoadInt64(&c.orders),
+		Score:  score,
+	}
+}
+
+// decay halves score for every HalfLife elapsed since updated
+func decay(score float64, updated, now time.Time) float64 {
+	if score == 0 || updated.IsZero() {
+		return score
+	}
+
+	elapsed := now.Sub(updated)
+	if elapsed <= 0 {
+		return score
+	}
+
+	return score * math.Pow(0.5, float64(elapsed)/float64(HalfLife))
+}
@@ -0,0 +1,83 @@
+package popularity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupTracker(t *testing.T, path string) (*Tracker, *time.Time) {
+	tracker, err := NewTracker(path, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	return tracker, &now
+}
+
+func TestTrackerCountsViewsAndOrders(t *testing.T) {
+	tracker, _ := setupTracker(t, "")
+
+	tracker.RecordView(1)
+	tracker.RecordView(1)
+	tracker.RecordOrder(1)
+
+	assert.Equal(t, entities.CoffeeStats{Views: 2, Orders: 1, Score: 2*ViewWeight + OrderWeight}, tracker.Stats(1))
+	assert.Equal(t, entities.CoffeeStats{}, tracker.Stats(2))
+}
+
+func TestTrackerDecaysScores(t *testing.T) {
+	tracker, now := setupTracker(t, "")
+
+	tracker.RecordOrder(1)
+	tracker.RecordView(2)
+	assert.Equal(t, []int{1, 2}, tracker.Trending(5))
+
+	// three half lives later the old order is outweighed by a fresh view
+	*now = now.Add(3 * HalfLife)
+	tracker.RecordView(2)
+
+	assert.InDelta(t, OrderWeight/8, tracker.Stats(1).Score, 0.0001)
+	assert.Equal(t, []int{2, 1}, tracker.Trending(5))
+	assert.Equal(t, []int{2}, tracker.Trending(1))
+}
+
+func TestTrackerNotifiesListeners(t *testing.T) {
+	tracker, _ := setupTracker(t, "")
+
+	orders := map[int]int64{}
+	tracker.OnChange(func(coffeeID int, stats entities.CoffeeStats) {
+		orders[coffeeID] = stats.Orders
+	})
+
+	tracker.RecordOrder(3)
+	tracker.RecordOrder(3)
+
+	assert.Equal(t, int64(2), orders[3])
+}
+
+func TestTrackerPersistsCounters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "popularity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "popularity.json")
+	tracker, _ := setupTracker(t, path)
+	tracker.RecordView(1)
+	tracker.RecordOrder(2)
+	require.NoError(t, tracker.Flush())
+
+	reloaded, _ := setupTracker(t, path)
+	assert.Equal(t, int64(1), reloaded.Stats(1).Views)
+	assert.Equal(t, int64(1), reloaded.Stats(2).Orders)
+	assert.Equal(t, OrderWeight, reloaded.Stats(2).Score)
+}
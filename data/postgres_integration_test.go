@@ -0,0 +1,437 @@
+//go:build integration
+// +build integration
+
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/jobs"
+	"github.com/hashicorp-demoapp/coffee-service/locks"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// postgresImage is the Postgres version the products database runs on
+const postgresImage = "postgres:11.6-alpine"
+
+// startPostgres runs a throwaway Postgres container, applies the migrations
+// and returns its connection string
+func startPostgres(t *testing.T) string {
+	ctx := context.Background()
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        postgresImage,
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "postgres",
+				"POSTGRES_PASSWORD": "password",
+				"POSTGRES_DB":       "products",
+			},
+			// the server restarts once after running the init scripts
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { container.Terminate(ctx) })
+
+	host, err := container.Host(ctx)
+	require.NoError(t, err)
+	port, err := container.MappedPort(ctx, "5432")
+	require.NoError(t, err)
+
+	connection := fmt.Sprintf("host=%s port=%s user=postgres password=password dbname=products sslmode=disable", host, port.Port())
+	migrate(t, connection)
+
+	return connection
+}
+
+// migrate applies the SQL files in the migrations directory in name order
+func migrate(t *testing.T, connection string) {
+	db, err := sqlx.Connect(driverName, connection)
+	require.NoError(t, err)
+	defer db.Close()
+
+	files, err := filepath.Glob(filepath.Join("migrations", "*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sort.Strings(files)
+
+	for _, file := range files {
+		statements, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+
+		_, err = db.Exec(string(statements))
+		require.NoError(t, err, file)
+	}
+}
+
+func TestPostgresRepositoryConformance(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testRepositoryConformance(t, r)
+}
+
+func TestPostgresStatementTimeoutFollowsDeadline(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	timeout := ""
+	require.NoError(t, r.getContext(ctx, &timeout, "SHOW statement_timeout"))
+	require.Regexp(t, `^\d+ms$|^10s$`, timeout)
+
+	// the timeout is local to the transaction of the query
+	require.NoError(t, r.getContext(context.Background(), &timeout, "SHOW statement_timeout"))
+	require.Equal(t, "0", timeout)
+}
+
+func TestPostgresUniqueViolationsConflict(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	ctx := context.Background()
+	err = r.inTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := txExec(ctx, tx, "INSERT INTO ingredient (id, name, created_at, updated_at) VALUES (1, 'Espresso', now(), now())")
+		return err
+	})
+	require.True(t, errors.Is(err, ErrConflict), "%v", err)
+}
+
+func TestPostgresRepositoryConformanceWithPreparedStatements(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	sink := metrics.NewPrometheusSink()
+	r.statements = newStatementCache(r.db, sink)
+	defer r.statements.close()
+
+	testRepositoryConformance(t, r)
+
+	rw := httptest.NewRecorder()
+	sink.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	require.Contains(t, rw.Body.String(), `coffee_service_db_statement_cache_total{result="hit"}`)
+	require.Contains(t, rw.Body.String(), `coffee_service_db_statement_execute_count{prepared="true"}`)
+}
+
+func TestPostgresRepositoryConformanceWithoutBatch(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	r.batch = false
+	testRepositoryConformance(t, r)
+}
+
+func TestPostgresImportCoffees(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, GenerateCoffees(ctx, r, 20, 1))
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+
+	imported := 0
+	for _, coffee := range coffees {
+		if coffee.Image != "/generated.png" {
+			continue
+		}
+		imported++
+		require.NotEmpty(t, coffee.Ingredients)
+		require.NotEmpty(t, coffee.Ingredients[0].Name)
+		require.Equal(t, "ml", coffee.Ingredients[0].Unit)
+	}
+	require.Equal(t, 20, imported)
+}
+
+func TestPostgresDeleteWhere(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testDeleteWhere(t, r)
+}
+
+func TestPostgresFindSimilar(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testFindSimilar(t, r)
+}
+
+func TestPostgresSlugs(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testSlugs(t, r)
+}
+
+func TestPostgresTranslations(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testTranslations(t, r)
+}
+
+func TestPostgresImages(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testImages(t, r)
+}
+
+func TestPostgresStatuses(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testStatuses(t, r)
+}
+
+func TestPostgresAvailability(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testAvailability(t, r)
+}
+
+func TestPostgresStores(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testStores(t, r)
+}
+
+func TestPostgresSuppliers(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testSuppliers(t, r)
+}
+
+func TestPostgresCoupons(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testCoupons(t, r)
+	testCouponsAreRedeemedAtomically(t, r)
+}
+
+func TestPostgresPoints(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testPoints(t, r)
+	testPointsAreDebitedAtomically(t, r)
+	testOrderPoints(t, r)
+}
+
+func TestPostgresEntityUUIDs(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+	r.entityIDs = config.UUIDv7IDs
+
+	testEntityUUIDs(t, r)
+}
+
+func TestPostgresUsers(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testUsers(t, r)
+}
+
+func TestPostgresStats(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testStats(t, r)
+}
+
+func TestPostgresSales(t *testing.T) {
+	r, err := newPostgres(startPostgres(t))
+	require.NoError(t, err)
+	defer r.db.Close()
+
+	testSales(t, r)
+}
+
+func TestPostgresJobQueue(t *testing.T) {
+	q, err := jobs.ConnectPostgres(startPostgres(t), jobs.PostgresOptions{PollInterval: 10 * time.Millisecond, Lease: time.Minute})
+	require.NoError(t, err)
+	defer q.Close()
+
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, "other", []byte(`{}`)))
+	require.NoError(t, q.Enqueue(ctx, "test", []byte(`{"n":1}`)))
+
+	job, err := q.Dequeue(ctx, []string{"test"})
+	require.NoError(t, err)
+	require.Equal(t, "test", job.Kind)
+	require.JSONEq(t, `{"n":1}`, string(job.Payload))
+	require.Equal(t, 1, job.Attempts)
+
+	// the lease hides the job from other workers
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = q.Dequeue(short, []string{"test"})
+	cancel()
+	require.Error(t, err)
+
+	require.NoError(t, q.Retry(ctx, job, 0))
+	retried, err := q.Dequeue(ctx, []string{"test"})
+	require.NoError(t, err)
+	require.Equal(t, job.ID, retried.ID)
+	require.Equal(t, 2, retried.Attempts)
+
+	require.NoError(t, q.Complete(ctx, retried))
+	short, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = q.Dequeue(short, []string{"test"})
+	cancel()
+	require.Error(t, err)
+
+	other, err := q.Dequeue(ctx, []string{"other", "test"})
+	require.NoError(t, err)
+	require.Equal(t, "other", other.Kind)
+}
+
+func TestPostgresLocker(t *testing.T) {
+	connection := startPostgres(t)
+	locker, err := locks.ConnectPostgres(connection, locks.PostgresOptions{TTL: 100 * time.Millisecond})
+	require.NoError(t, err)
+	defer locker.Close()
+
+	ctx := context.Background()
+	lock, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	_, err = locker.TryLock(ctx, "scheduler.reseed")
+	require.Equal(t, locks.ErrLocked, err)
+
+	// held past its TTL while its connection lives
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case <-lock.Lost():
+		t.Fatal("held lock is lost")
+	default:
+	}
+	require.NoError(t, lock.Unlock(ctx))
+
+	lock, err = locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	// the lock ends with the connection holding it
+	db, err := sqlx.Connect("pgx", connection)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("SELECT pg_terminate_backend(pid) FROM pg_locks WHERE locktype='advisory'")
+	require.NoError(t, err)
+	select {
+	case <-lock.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock of a terminated connection is not lost")
+	}
+	lock.Unlock(ctx)
+
+	lock, err = locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock(ctx))
+}
+
+func TestPostgresSchemaVersion(t *testing.T) {
+	connection := startPostgres(t)
+	ctx := context.Background()
+
+	// the files applied by hand record their versions
+	r, err := newPostgres(connection)
+	require.NoError(t, err)
+	version, err := r.SchemaVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion, version)
+	applied, err := r.checkSchema(ctx, true)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	// an empty database is migrated from scratch
+	_, err = r.db.Exec("CREATE DATABASE fresh")
+	require.NoError(t, err)
+	fresh, err := newPostgres(strings.Replace(connection, "dbname=products", "dbname=fresh", 1))
+	require.NoError(t, err)
+	_, err = fresh.checkSchema(ctx, false)
+	require.True(t, errors.Is(err, ErrSchemaMismatch))
+	applied, err = fresh.checkSchema(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, applied, SchemaVersion)
+	version, err = fresh.SchemaVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion, version)
+
+	// a database without schema_version is at the version of its objects
+	_, err = r.db.Exec("CREATE DATABASE partial")
+	require.NoError(t, err)
+	partial, err := newPostgres(strings.Replace(connection, "dbname=products", "dbname=partial", 1))
+	require.NoError(t, err)
+	for _, m := range migrations[:2] {
+		statements, err := migrationFS.ReadFile("migrations/" + m.name)
+		require.NoError(t, err)
+		_, err = partial.db.Exec(string(statements))
+		require.NoError(t, err)
+	}
+	version, err = partial.SchemaVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+	applied, err = partial.checkSchema(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, applied, SchemaVersion-2)
+
+	// and has none when the objects of a migration are missing
+	_, err = partial.db.Exec("DROP TABLE schema_version; DROP TABLE coffee_translation")
+	require.NoError(t, err)
+	_, err = partial.SchemaVersion(ctx)
+	require.True(t, errors.Is(err, ErrSchemaMismatch))
+
+	// a newer database is never migrated
+	_, err = r.db.Exec("INSERT INTO schema_version (version) VALUES ($1)", SchemaVersion+1)
+	require.NoError(t, err)
+	_, err = r.checkSchema(ctx, true)
+	require.True(t, errors.Is(err, ErrSchemaMismatch))
+	_, err = CheckSchema(ctx, connection, true)
+	require.True(t, errors.Is(err, ErrSchemaMismatch))
+}
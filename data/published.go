@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"math"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// PublishedRepository is a Repository reading only the published coffees,
+// the menu served by the public routes. Drafts and retired coffees are not
+// found, while writes and ingredients go to the wrapped repository unchanged.
+type PublishedRepository struct {
+	Repository
+}
+
+// NewPublished wraps repository to hide the coffees which are not published
+func NewPublished(repository Repository) *PublishedRepository {
+	return &PublishedRepository{Repository: repository}
+}
+
+// Unwrap returns the wrapped repository
+func (r *PublishedRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Find returns the published coffees
+func (r *PublishedRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	return r.Repository.FindWhere(ctx, publishedFilter)
+}
+
+// FindWhere returns the published coffees matching expr
+func (r *PublishedRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	return r.Repository.FindWhere(ctx, &filter.And{Left: expr, Right: publishedFilter})
+}
+
+// FindByID returns a published coffee, or ErrNotFound
+func (r *PublishedRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	return published(r.Repository.FindByID(ctx, coffeeID))
+}
+
+// FindBySlug returns the published coffee with the slug, or ErrNotFound
+func (r *PublishedRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	return published(FindBySlug(ctx, r.Repository, slug))
+}
+
+// FindRelated returns up to limit published coffees related to a published
+// one. Every related coffee is ranked, so hidden ones never shorten the list.
+func (r *PublishedRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	if _, err := r.FindByID(ctx, coffeeID); err != nil {
+		return nil, err
+	}
+
+	ranked, err := r.Repository.FindRelated(ctx, coffeeID, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	related := ranked[:0]
+	for _, c := range ranked {
+		if c.Status == StatusPublished && len(related) < limit {
+			related = append(related, c)
+		}
+	}
+	return related, nil
+}
+
+// published hides a coffee which is not published
+func published(coffee *entities.Coffee, err error) (*entities.Coffee, error) {
+	if err != nil {
+		return nil, err
+	}
+	if coffee.Status != StatusPublished {
+		return nil, ErrNotFound
+	}
+	return coffee, nil
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func TestPublishedHidesDraftsAndRetiredCoffees(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	menu := NewPublished(r)
+
+	// a draft sharing every ingredient of the first coffee
+	draft := &entities.Coffee{Name: "Packer Spiced Draft", Price: 350, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1}, {IngredientID: 2}, {IngredientID: 4}}}
+	require.NoError(t, r.CreateCoffee(ctx, draft))
+	retired, err := r.FindByID(ctx, 3)
+	require.NoError(t, err)
+	retired.Status = StatusRetired
+	require.NoError(t, r.UpdateCoffee(ctx, retired))
+
+	coffees, err := menu.Find(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4, 5, 6}, coffeeIDs(coffees))
+
+	expr, err := filter.Parse("price>=350")
+	require.NoError(t, err)
+	coffees, err = menu.FindWhere(ctx, expr)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, coffeeIDs(coffees))
+
+	_, err = menu.FindByID(ctx, draft.ID)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = menu.FindBySlug(ctx, "nomadicano")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = menu.FindRelated(ctx, draft.ID, 3)
+	assert.Equal(t, ErrNotFound, err)
+
+	related, err := menu.FindRelated(ctx, 1, 3)
+	require.NoError(t, err)
+	assert.Len(t, related, 3)
+	assert.NotContains(t, coffeeIDs(related), draft.ID)
+	assert.NotContains(t, coffeeIDs(related), 3)
+
+	// the whole catalogue is still there for the admin routes
+	coffees, err = r.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, coffees, 7)
+}
+
+// coffeeIDs returns the IDs of coffees in order
+func coffeeIDs(coffees entities.Coffees) []int {
+	ids := make([]int, len(coffees))
+	for n, c := range coffees {
+		ids[n] = c.ID
+	}
+	return ids
+}
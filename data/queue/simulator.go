@@ -0,0 +1,127 @@
+// Package queue simulates the baristas preparing the open orders, estimating
+// how long a new order waits before a barista starts it.
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ItemTime is how long a barista takes to prepare a single item
+	ItemTime = 90 * time.Second
+	// RecomputeInterval is how often Run recomputes the status of the queue
+	RecomputeInterval = 5 * time.Second
+)
+
+// Status is the state of the queue as of its last recomputation
+type Status struct {
+	OpenOrders  int       `json:"open_orders"`
+	Baristas    int       `json:"baristas"`
+	WaitSeconds int       `json:"wait_seconds"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Simulator is a queue of orders prepared first come first served by a fixed
+// number of baristas, each preparing one order at a time
+type Simulator struct {
+	mu sync.Mutex
+	// free is the time every barista finishes the orders assigned to them
+	free []time.Time
+	// done is the time every open order is ready, in the order they were
+	// placed
+	done   []time.Time
+	status Status
+	now    func() time.Time
+}
+
+// NewSimulator creates a Simulator with the given number of baristas, at
+// least one
+func NewSimulator(baristas int) *Simulator {
+	if baristas < 1 {
+		baristas = 1
+	}
+	s := &Simulator{free: make([]time.Time, baristas), now: time.Now}
+	s.Recompute()
+	return s
+}
+
+// RecordOrder queues an order of items with the barista free the soonest.
+// Recording on a nil Simulator does nothing.
+func (s *Simulator) RecordOrder(items int) {
+	if s == nil || items < 1 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	barista := s.soonest()
+	start := s.free[barista]
+	if start.Before(now) {
+		start = now
+	}
+	s.free[barista] = start.Add(time.Duration(items) * ItemTime)
+	s.done = append(s.done, s.free[barista])
+}
+
+// Status returns the status of the queue as of its last recomputation
+func (s *Simulator) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// Recompute drops the orders which are ready and updates the status
+func (s *Simulator) Recompute() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	open := s.done[:0]
+	for _, done := range s.done {
+		if done.After(now) {
+			open = append(open, done)
+		}
+	}
+	s.done = open
+
+	wait := s.free[s.soonest()].Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	s.status = Status{
+		OpenOrders:  len(s.done),
+		Baristas:    len(s.free),
+		WaitSeconds: int(wait.Round(time.Second) / time.Second),
+		UpdatedAt:   now,
+	}
+}
+
+// Run recomputes the status every interval until done is closed
+func (s *Simulator) Run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.Recompute()
+		}
+	}
+}
+
+// soonest returns the barista finishing their orders first, callers hold mu
+func (s *Simulator) soonest() int {
+	soonest := 0
+	for n := range s.free {
+		if s.free[n].Before(s.free[soonest]) {
+			soonest = n
+		}
+	}
+	return soonest
+}
@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSimulator(baristas int) (*Simulator, *time.Time) {
+	simulator := NewSimulator(baristas)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	simulator.now = func() time.Time { return now }
+	simulator.Recompute()
+
+	return simulator, &now
+}
+
+func TestSimulatorStartsEmpty(t *testing.T) {
+	simulator, now := setupSimulator(2)
+
+	assert.Equal(t, Status{Baristas: 2, UpdatedAt: *now}, simulator.Status())
+}
+
+func TestSimulatorWaitsForTheFirstFreeBarista(t *testing.T) {
+	simulator, now := setupSimulator(2)
+
+	// one barista is still free
+	simulator.RecordOrder(2)
+	simulator.Recompute()
+	assert.Equal(t, 1, simulator.Status().OpenOrders)
+	assert.Equal(t, 0, simulator.Status().WaitSeconds)
+
+	simulator.RecordOrder(1)
+	simulator.RecordOrder(1)
+	// the status only changes when recomputed
+	assert.Equal(t, 1, simulator.Status().OpenOrders)
+
+	simulator.Recompute()
+	status := simulator.Status()
+	assert.Equal(t, 3, status.OpenOrders)
+	// the second barista prepares both single items, ready after 3m like
+	// the first order
+	assert.Equal(t, int(2*ItemTime/time.Second), status.WaitSeconds)
+
+	*now = now.Add(2 * ItemTime)
+	simulator.Recompute()
+	assert.Equal(t, Status{Baristas: 2, UpdatedAt: *now}, simulator.Status())
+}
+
+func TestSimulatorDropsReadyOrders(t *testing.T) {
+	simulator, now := setupSimulator(1)
+
+	simulator.RecordOrder(1)
+	simulator.RecordOrder(1)
+	simulator.RecordOrder(0)
+
+	*now = now.Add(ItemTime + time.Second)
+	simulator.Recompute()
+	status := simulator.Status()
+	assert.Equal(t, 1, status.OpenOrders)
+	assert.Equal(t, int((ItemTime-time.Second)/time.Second), status.WaitSeconds)
+}
+
+func TestSimulatorRunRecomputes(t *testing.T) {
+	simulator, _ := setupSimulator(1)
+	simulator.RecordOrder(1)
+
+	done := make(chan struct{})
+	go simulator.Run(time.Millisecond, done)
+	defer close(done)
+
+	assert.Eventually(t, func() bool { return simulator.Status().OpenOrders == 1 }, time.Second, time.Millisecond)
+}
+
+func TestNilSimulatorIgnoresOrders(t *testing.T) {
+	var simulator *Simulator
+	simulator.RecordOrder(1)
+}
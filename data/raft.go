@@ -0,0 +1,690 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// raftApplyTimeout bounds how long a write waits to be committed by a quorum
+// of the replicas
+const raftApplyTimeout = 5 * time.Second
+
+// RaftApplyPath is the route of the leader accepting the writes forwarded by
+// the other replicas
+const RaftApplyPath = "/admin/raft/apply"
+
+// ErrNoLeader is returned for writes while the replicas have not elected a
+// leader
+var ErrNoLeader = errors.New("the raft cluster has no leader")
+
+// ErrNotLeader is returned for forwarded writes received by a replica which
+// is not the leader
+var ErrNotLeader = errors.New("this replica is not the raft leader")
+
+// ErrInvalidCommand is returned for forwarded writes which can not be
+// decoded, or whose operation is unknown or misses its entity or ID
+var ErrInvalidCommand = errors.New("invalid raft command")
+
+// RaftPeer is a replica of a RaftRepository
+type RaftPeer struct {
+	ID string `json:"id"`
+	// Address is the address of the Raft transport of the replica
+	Address string `json:"address"`
+	// HTTPAddress is the base URL of the replica writes are forwarded to
+	// while it leads
+	HTTPAddress string `json:"http_address"`
+}
+
+// ParseRaftPeers parses peers formatted as <id>=<raft address>=<http address>
+func ParseRaftPeers(peers []string) ([]RaftPeer, error) {
+	parsed := make([]RaftPeer, 0, len(peers))
+	for _, peer := range peers {
+		parts := strings.SplitN(peer, "=", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("raft peer %q must be formatted as <id>=<raft address>=<http address>", peer)
+		}
+		parsed = append(parsed, RaftPeer{ID: parts[0], Address: parts[1], HTTPAddress: strings.TrimSuffix(parts[2], "/")})
+	}
+	return parsed, nil
+}
+
+// RaftOptions configure a RaftRepository
+type RaftOptions struct {
+	// NodeID is the ID of this replica in Peers
+	NodeID      string
+	BindAddress string
+	Peers       []RaftPeer
+	// Client forwards writes to the leader, AuthToken authenticates them with
+	// the admin routes of the leader
+	Client    *http.Client
+	AuthToken string
+	Logger    hclog.Logger
+}
+
+// RaftStatus describes a replica of a RaftRepository
+type RaftStatus struct {
+	ID           string     `json:"id"`
+	State        string     `json:"state"`
+	Leader       string     `json:"leader"`
+	Term         uint64     `json:"term"`
+	LastIndex    uint64     `json:"last_index"`
+	AppliedIndex uint64     `json:"applied_index"`
+	Peers        []RaftPeer `json:"peers"`
+}
+
+// RaftRepository is an in memory Repository replicated with Raft, so writes
+// survive the failure of a replica. Writes are committed to the Raft log by
+// the leader, the other replicas forward them to it over HTTP. Every replica
+// applies the log to its own in memory database and serves reads from it, so
+// a read may briefly miss a write which was just committed.
+type RaftRepository struct {
+	// Repository serves the reads from the local replica
+	Repository
+	fsm     *raftFSM
+	raft    *raft.Raft
+	options RaftOptions
+}
+
+// NewRaft starts the replica options.NodeID of the peers, listening for the
+// Raft transport on options.BindAddress
+func NewRaft(cfg *config.Config, options RaftOptions) (*RaftRepository, error) {
+	self, ok := raftPeer(options.Peers, options.NodeID)
+	if !ok {
+		return nil, fmt.Errorf("raft node %q is not one of the peers", options.NodeID)
+	}
+	advertise, err := net.ResolveTCPAddr("tcp", self.Address)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := raft.NewTCPTransportWithLogger(options.BindAddress, advertise, 3, 10*time.Second, options.Logger.Named("raft"))
+	if err != nil {
+		return nil, err
+	}
+
+	return newRaft(cfg, options, transport)
+}
+
+// newRaft starts a replica communicating with the others over transport
+func newRaft(cfg *config.Config, options RaftOptions, transport raft.Transport) (*RaftRepository, error) {
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	// every replica starts from the same seed data, the log replays the
+	// writes on top of it
+	store, err := NewInMemoryDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fsm := &raftFSM{store: store.(*InMemoryRepository)}
+
+	rc := raft.DefaultConfig()
+	rc.LocalID = raft.ServerID(options.NodeID)
+	rc.Logger = options.Logger.Named("raft")
+	logs := raft.NewInmemStore()
+	node, err := raft.NewRaft(rc, fsm, logs, logs, raft.NewInmemSnapshotStore(), transport)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]raft.Server, 0, len(options.Peers))
+	for _, peer := range options.Peers {
+		servers = append(servers, raft.Server{ID: raft.ServerID(peer.ID), Address: raft.ServerAddress(peer.Address)})
+	}
+	// every replica bootstraps the same configuration, the ones which
+	// already joined the cluster refuse
+	if err := node.BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil && err != raft.ErrCantBootstrap {
+		node.Shutdown()
+		return nil, err
+	}
+
+	return &RaftRepository{Repository: fsm.store, fsm: fsm, raft: node, options: options}, nil
+}
+
+// Close stops the replica
+func (r *RaftRepository) Close() error {
+	return r.raft.Shutdown().Error()
+}
+
+// Status describes the replica and the leader it follows
+func (r *RaftRepository) Status() RaftStatus {
+	status := RaftStatus{
+		ID:           r.options.NodeID,
+		State:        r.raft.State().String(),
+		LastIndex:    r.raft.LastIndex(),
+		AppliedIndex: r.raft.AppliedIndex(),
+		Peers:        r.options.Peers,
+	}
+	status.Term, _ = strconv.ParseUint(r.raft.Stats()["term"], 10, 64)
+	if leader, ok := r.leader(); ok {
+		status.Leader = leader.ID
+	}
+	return status
+}
+
+// AppliedIndex returns the index of the last write of the log applied by this
+// replica
+func (r *RaftRepository) AppliedIndex() uint64 {
+	return r.raft.AppliedIndex()
+}
+
+// Primary returns the HTTP base URL of the leader, false when this replica
+// leads or no leader is elected
+func (r *RaftRepository) Primary() (string, bool) {
+	if r.raft.State() == raft.Leader {
+		return "", false
+	}
+	leader, ok := r.leader()
+	return leader.HTTPAddress, ok
+}
+
+// CreateCoffee creates the coffee on every replica
+func (r *RaftRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	result, err := r.write(ctx, &raftCommand{Op: opCreateCoffee, Coffee: coffee})
+	if err != nil {
+		return err
+	}
+	*coffee = *result.Coffee
+	return nil
+}
+
+// UpdateCoffee updates the coffee on every replica
+func (r *RaftRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	result, err := r.write(ctx, &raftCommand{Op: opUpdateCoffee, Coffee: coffee})
+	if err != nil {
+		return err
+	}
+	*coffee = *result.Coffee
+	return nil
+}
+
+// DeleteCoffee deletes the coffee from every replica
+func (r *RaftRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	_, err := r.write(ctx, &raftCommand{Op: opDeleteCoffee, ID: coffeeID})
+	return err
+}
+
+// CreateIngredient creates the ingredient on every replica
+func (r *RaftRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	result, err := r.write(ctx, &raftCommand{Op: opCreateIngredient, Ingredient: ingredient})
+	if err != nil {
+		return err
+	}
+	*ingredient = *result.Ingredient
+	return nil
+}
+
+// UpdateIngredient updates the ingredient on every replica
+func (r *RaftRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	result, err := r.write(ctx, &raftCommand{Op: opUpdateIngredient, Ingredient: ingredient})
+	if err != nil {
+		return err
+	}
+	*ingredient = *result.Ingredient
+	return nil
+}
+
+// DeleteIngredient deletes the ingredient from every replica
+func (r *RaftRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	_, err := r.write(ctx, &raftCommand{Op: opDeleteIngredient, ID: ingredientID})
+	return err
+}
+
+// Unwrap returns the local replica, which serves the reads of the optional
+// capabilities. Their writes are refused below, the log does not carry them
+// so they would only reach this replica.
+func (r *RaftRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// SetTranslation is not replicated, it returns ErrTranslationsUnsupported
+func (r *RaftRepository) SetTranslation(ctx context.Context, translation *entities.Translation) error {
+	return ErrTranslationsUnsupported
+}
+
+// DeleteTranslation is not replicated, it returns ErrTranslationsUnsupported
+func (r *RaftRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error {
+	return ErrTranslationsUnsupported
+}
+
+// SetImage is not replicated, it returns ErrImagesUnsupported
+func (r *RaftRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	return ErrImagesUnsupported
+}
+
+// SetAvailability is not replicated, it returns ErrAvailabilityUnsupported
+func (r *RaftRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error {
+	return ErrAvailabilityUnsupported
+}
+
+// CreateCoupon is not replicated, it returns ErrCouponsUnsupported
+func (r *RaftRepository) CreateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	return ErrCouponsUnsupported
+}
+
+// UpdateCoupon is not replicated, it returns ErrCouponsUnsupported
+func (r *RaftRepository) UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	return ErrCouponsUnsupported
+}
+
+// DeleteCoupon is not replicated, it returns ErrCouponsUnsupported
+func (r *RaftRepository) DeleteCoupon(ctx context.Context, code string) error {
+	return ErrCouponsUnsupported
+}
+
+// RedeemCoupon is not replicated, it returns ErrCouponsUnsupported
+func (r *RaftRepository) RedeemCoupon(ctx context.Context, code string, t time.Time) (*entities.Coupon, error) {
+	return nil, ErrCouponsUnsupported
+}
+
+// ReleaseCoupon is not replicated, it returns ErrCouponsUnsupported
+func (r *RaftRepository) ReleaseCoupon(ctx context.Context, code string) error {
+	return ErrCouponsUnsupported
+}
+
+// RecordPoints is not replicated, it returns ErrPointsUnsupported
+func (r *RaftRepository) RecordPoints(ctx context.Context, entry *entities.PointsEntry) (int, error) {
+	return 0, ErrPointsUnsupported
+}
+
+// RecordOrderPoints is not replicated, it returns ErrPointsUnsupported
+func (r *RaftRepository) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	return ErrPointsUnsupported
+}
+
+// RecordOrder is not replicated, it returns ErrStatsUnsupported
+func (r *RaftRepository) RecordOrder(ctx context.Context, order *entities.OrderRecord) error {
+	return ErrStatsUnsupported
+}
+
+// SaveUser is not replicated, it returns ErrUsersUnsupported
+func (r *RaftRepository) SaveUser(ctx context.Context, user *entities.User) error {
+	return ErrUsersUnsupported
+}
+
+// Forwarded applies a write another replica forwarded to the leader and
+// returns the encoded result. It returns ErrNotLeader when this replica does
+// not lead, and ErrInvalidCommand for a command no replica could apply.
+func (r *RaftRepository) Forwarded(command []byte) ([]byte, error) {
+	c := &raftCommand{}
+	if err := json.Unmarshal(command, c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+	}
+	result, err := r.apply(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+// write commits c to the log of the leader, forwarding it when another
+// replica leads
+func (r *RaftRepository) write(ctx context.Context, c *raftCommand) (*raftResult, error) {
+	result, err := r.apply(c)
+	if err == ErrNotLeader {
+		result, err = r.forward(ctx, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := result.err(); err != nil {
+		return nil, err
+	}
+	recordIndex(ctx, result.Index)
+	return result, nil
+}
+
+// apply commits c to the log and waits until it is applied locally. The
+// leader assigns the IDs of created entities, so every replica applies the
+// same ones.
+func (r *RaftRepository) apply(c *raftCommand) (*raftResult, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if r.raft.State() != raft.Leader {
+		return nil, ErrNotLeader
+	}
+
+	// copied, the caller sees the result once the write is committed
+	switch c.Op {
+	case opCreateCoffee:
+		coffee := *c.Coffee
+		coffee.ID = r.fsm.store.sequences.next(Coffee)
+		c.Coffee = &coffee
+	case opCreateIngredient:
+		ingredient := *c.Ingredient
+		ingredient.ID = r.fsm.store.sequences.next(Ingredient)
+		c.Ingredient = &ingredient
+	}
+
+	command, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	future := r.raft.Apply(command, raftApplyTimeout)
+	if err := future.Error(); err != nil {
+		if err == raft.ErrNotLeader || err == raft.ErrLeadershipLost {
+			return nil, ErrNotLeader
+		}
+		return nil, err
+	}
+	result := future.Response().(*raftResult)
+	result.Index = future.Index()
+	return result, nil
+}
+
+// forward sends c to the leader
+func (r *RaftRepository) forward(ctx context.Context, c *raftCommand) (*raftResult, error) {
+	leader, ok := r.leader()
+	if !ok {
+		return nil, ErrNoLeader
+	}
+
+	command, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leader.HTTPAddress+RaftApplyPath, bytes.NewReader(command))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.options.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.options.AuthToken)
+	}
+
+	resp, err := r.options.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to forward the write to leader %s: %w", leader.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("leader %s rejected the write with status %d", leader.ID, resp.StatusCode)
+	}
+
+	result := &raftResult{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// leader returns the peer currently leading
+func (r *RaftRepository) leader() (RaftPeer, bool) {
+	address := string(r.raft.Leader())
+	for _, peer := range r.options.Peers {
+		if peer.Address == address {
+			return peer, true
+		}
+	}
+	return RaftPeer{}, false
+}
+
+// raftPeer returns the peer with id
+func raftPeer(peers []RaftPeer, id string) (RaftPeer, bool) {
+	for _, peer := range peers {
+		if peer.ID == id {
+			return peer, true
+		}
+	}
+	return RaftPeer{}, false
+}
+
+// The operations of raftCommand
+const (
+	opCreateCoffee     = "CreateCoffee"
+	opUpdateCoffee     = "UpdateCoffee"
+	opDeleteCoffee     = "DeleteCoffee"
+	opCreateIngredient = "CreateIngredient"
+	opUpdateIngredient = "UpdateIngredient"
+	opDeleteIngredient = "DeleteIngredient"
+)
+
+// raftCommand is a write committed to the Raft log
+type raftCommand struct {
+	Op         string               `json:"op"`
+	ID         int                  `json:"id,omitempty"`
+	Coffee     *entities.Coffee     `json:"coffee,omitempty"`
+	Ingredient *entities.Ingredient `json:"ingredient,omitempty"`
+}
+
+// validate returns ErrInvalidCommand unless the operation of c is known and c
+// has the entity or the ID it applies to
+func (c *raftCommand) validate() error {
+	valid := false
+	switch c.Op {
+	case opCreateCoffee, opUpdateCoffee:
+		valid = c.Coffee != nil
+	case opCreateIngredient, opUpdateIngredient:
+		valid = c.Ingredient != nil
+	case opDeleteCoffee, opDeleteIngredient:
+		valid = c.ID > 0
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidCommand, c.Op)
+	}
+	if !valid {
+		return fmt.Errorf("%w: %s without its entity or ID", ErrInvalidCommand, c.Op)
+	}
+	return nil
+}
+
+// raftResult is the outcome of applying a raftCommand
+type raftResult struct {
+	Coffee     *entities.Coffee     `json:"coffee,omitempty"`
+	Ingredient *entities.Ingredient `json:"ingredient,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	// Code identifies the error of the repository Error wraps, see
+	// raftErrors, so a follower returns the same error as the leader
+	Code string `json:"code,omitempty"`
+	// Invalid is the invariant broken by the entity of the command, for the
+	// code invalid
+	Invalid *entities.ValidationError `json:"invalid,omitempty"`
+	// Index is the position of the command in the log
+	Index uint64 `json:"index,omitempty"`
+}
+
+// raftInvalid is the code of a ValidationError
+const raftInvalid = "invalid"
+
+// raftErrors are the errors of the repository a raftResult carries by code
+var raftErrors = []struct {
+	code string
+	err  error
+}{
+	{"not_found", ErrNotFound},
+	{"invalid_status", ErrInvalidStatus},
+	{"invalid_transition", ErrInvalidTransition},
+	{"conflict", ErrConflict},
+	{"missing_reference", ErrMissingReference},
+}
+
+// raftError is an error of the repository of the leader returned by a
+// follower, with the message of the leader
+type raftError struct {
+	message string
+	err     error
+}
+
+func (e *raftError) Error() string {
+	return e.message
+}
+
+func (e *raftError) Unwrap() error {
+	return e.err
+}
+
+// setErr records the error applying the command failed with, and its code
+func (r *raftResult) setErr(err error) {
+	r.Error = err.Error()
+
+	var invalid *entities.ValidationError
+	if errors.As(err, &invalid) {
+		r.Code, r.Invalid = raftInvalid, invalid
+		return
+	}
+	for _, e := range raftErrors {
+		if errors.Is(err, e.err) {
+			r.Code = e.code
+			return
+		}
+	}
+}
+
+// err returns the error applying the command failed with, rebuilt from its
+// code so that it is the error the repository returned
+func (r *raftResult) err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if r.Code == raftInvalid && r.Invalid != nil {
+		return r.Invalid
+	}
+	for _, e := range raftErrors {
+		switch {
+		case r.Error == e.err.Error():
+			return e.err
+		case r.Code == e.code:
+			return &raftError{message: r.Error, err: e.err}
+		}
+	}
+	return errors.New(r.Error)
+}
+
+// raftFSM applies the Raft log to the in memory database of a replica
+type raftFSM struct {
+	store *InMemoryRepository
+}
+
+// Apply applies a committed raftCommand. Commands are validated by the
+// leader, an invalid one still fails rather than stopping the replica.
+func (f *raftFSM) Apply(l *raft.Log) interface{} {
+	c := &raftCommand{}
+	err := json.Unmarshal(l.Data, c)
+	if err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		result := &raftResult{}
+		result.setErr(err)
+		return result
+	}
+
+	ctx := context.Background()
+	switch c.Op {
+	case opCreateCoffee:
+		err = f.store.createCoffee(ctx, c.Coffee, c.Coffee.ID, "")
+	case opUpdateCoffee:
+		err = f.store.UpdateCoffee(ctx, c.Coffee)
+	case opDeleteCoffee:
+		err = f.store.DeleteCoffee(ctx, c.ID)
+	case opCreateIngredient:
+		err = f.store.createIngredient(ctx, c.Ingredient, c.Ingredient.ID)
+	case opUpdateIngredient:
+		err = f.store.UpdateIngredient(ctx, c.Ingredient)
+	case opDeleteIngredient:
+		err = f.store.DeleteIngredient(ctx, c.ID)
+	}
+
+	result := &raftResult{Coffee: c.Coffee, Ingredient: c.Ingredient}
+	if err != nil {
+		result.setErr(err)
+	}
+	return result
+}
+
+// Snapshot captures the catalogue of the replica, Raft compacts the log up to
+// it
+func (f *raftFSM) Snapshot() (raft.FSMSnapshot, error) {
+	ctx := context.Background()
+	ingredients, err := f.store.FindIngredients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coffees, err := f.store.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// copied, the snapshot is persisted after the list goes back to the pool
+	snapshot := &raftSnapshot{Ingredients: ingredients, Coffees: append(entities.Coffees{}, coffees...)}
+	entities.PutCoffees(coffees)
+	return snapshot, nil
+}
+
+// Restore replaces the catalogue of the replica with a snapshot, keeping the
+// IDs and slugs of the leader
+func (f *raftFSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	snapshot := &raftSnapshot{}
+	if err := json.NewDecoder(rc).Decode(snapshot); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	coffees, err := f.store.Find(ctx)
+	if err != nil {
+		return err
+	}
+	defer entities.PutCoffees(coffees)
+	for _, c := range coffees {
+		if err := f.store.DeleteCoffee(ctx, c.ID); err != nil {
+			return err
+		}
+	}
+	ingredients, err := f.store.FindIngredients(ctx)
+	if err != nil {
+		return err
+	}
+	for _, i := range ingredients {
+		if err := f.store.DeleteIngredient(ctx, i.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range snapshot.Ingredients {
+		ingredient := i
+		if err := f.store.createIngredient(ctx, &ingredient, i.ID); err != nil {
+			return err
+		}
+	}
+	for _, c := range snapshot.Coffees {
+		coffee := c
+		if err := f.store.createCoffee(ctx, &coffee, c.ID, c.Slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raftSnapshot is the catalogue of a replica at a point of the log
+type raftSnapshot struct {
+	Ingredients entities.Ingredients `json:"ingredients"`
+	Coffees     entities.Coffees     `json:"coffees"`
+}
+
+// Persist writes the snapshot to sink
+func (s *raftSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+// Release is a no-op, the snapshot holds copies
+func (s *raftSnapshot) Release() {}
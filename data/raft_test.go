@@ -0,0 +1,292 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// setupRaft starts a cluster of count replicas connected by in memory
+// transports, each accepting forwarded writes over HTTP
+func setupRaft(t *testing.T, count int) []*RaftRepository {
+	replicas := make([]*RaftRepository, count)
+	servers := make([]*httptest.Server, count)
+	transports := make([]*raft.InmemTransport, count)
+	peers := make([]RaftPeer, count)
+
+	for n := range replicas {
+		n := n
+		servers[n] = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			command, _ := ioutil.ReadAll(r.Body)
+			result, err := replicas[n].Forwarded(command)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			rw.Write(result)
+		}))
+		t.Cleanup(servers[n].Close)
+
+		var address raft.ServerAddress
+		address, transports[n] = raft.NewInmemTransport(raft.ServerAddress(fmt.Sprintf("replica-%d", n)))
+		peers[n] = RaftPeer{ID: fmt.Sprintf("coffee-%d", n), Address: string(address), HTTPAddress: servers[n].URL}
+	}
+	for _, from := range transports {
+		for _, to := range transports {
+			from.Connect(to.LocalAddr(), to)
+		}
+	}
+
+	for n := range replicas {
+		replica, err := newRaft(&config.Config{Logger: hclog.NewNullLogger()}, RaftOptions{
+			NodeID: peers[n].ID,
+			Peers:  peers,
+			Logger: hclog.NewNullLogger(),
+		}, transports[n])
+		require.NoError(t, err)
+		replicas[n] = replica
+		t.Cleanup(func() { replica.Close() })
+	}
+	return replicas
+}
+
+// leader waits for the replicas to elect a leader, and for every replica to
+// know it so that followers can forward writes, and returns its index
+func leader(t *testing.T, replicas []*RaftRepository) int {
+	index := -1
+	require.Eventually(t, func() bool {
+		index = -1
+		for n, replica := range replicas {
+			if replica.raft.State() == raft.Leader {
+				index = n
+			}
+			if _, ok := replica.leader(); !ok {
+				return false
+			}
+		}
+		return index >= 0
+	}, 10*time.Second, 10*time.Millisecond)
+	return index
+}
+
+// replicated waits until every replica serves the coffee
+func replicated(t *testing.T, replicas []*RaftRepository, coffeeID int) {
+	for n, replica := range replicas {
+		assert.Eventually(t, func() bool {
+			_, err := replica.FindByID(context.Background(), coffeeID)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, "replica %d", n)
+	}
+}
+
+func TestRaftReplicatesWritesForwardedToTheLeader(t *testing.T) {
+	ctx := context.Background()
+	replicas := setupRaft(t, 3)
+	follower := replicas[(leader(t, replicas)+1)%len(replicas)]
+
+	coffee := &entities.Coffee{Name: "Raftuccino", Price: 300, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Quantity: 40, Unit: "ml"}}}
+	require.NoError(t, follower.CreateCoffee(ctx, coffee))
+	assert.Equal(t, 7, coffee.ID)
+	assert.Equal(t, "Espresso'", coffee.Ingredients[0].Name)
+	replicated(t, replicas, coffee.ID)
+
+	assert.Equal(t, ErrNotFound, follower.DeleteCoffee(ctx, 42))
+	// the errors of the leader reach the follower
+	coffee.Status = "archived"
+	assert.Equal(t, ErrInvalidStatus, follower.UpdateCoffee(ctx, coffee))
+	err := follower.CreateCoffee(ctx, &entities.Coffee{Name: "Mystery", Price: 300, Ingredients: []entities.CoffeeIngredients{{IngredientID: 42}}})
+	assert.True(t, errors.Is(err, ErrMissingReference), "%v", err)
+	var invalid *entities.ValidationError
+	require.True(t, errors.As(follower.CreateCoffee(ctx, &entities.Coffee{Name: "Freebie"}), &invalid))
+	assert.Equal(t, "price", invalid.Field)
+
+	status := follower.Status()
+	assert.Equal(t, "Follower", status.State)
+	assert.NotEmpty(t, status.Leader)
+	assert.Len(t, status.Peers, 3)
+}
+
+func TestRaftResultsKeepTheErrorsOfTheRepository(t *testing.T) {
+	invalid := &entities.ValidationError{Entity: "coffee", Field: "price", Reason: "must be positive"}
+	for _, test := range []struct {
+		err error
+		is  error
+	}{
+		{err: ErrNotFound, is: ErrNotFound},
+		{err: ErrInvalidTransition, is: ErrInvalidTransition},
+		{err: fmt.Errorf("%w: coffee slug [vaulatte]", ErrConflict), is: ErrConflict},
+		{err: invalid},
+		{err: errors.New("disk full")},
+	} {
+		result := &raftResult{}
+		result.setErr(test.err)
+		// as forwarded by the leader
+		encoded, err := json.Marshal(result)
+		require.NoError(t, err)
+		forwarded := &raftResult{}
+		require.NoError(t, json.Unmarshal(encoded, forwarded))
+
+		err = forwarded.err()
+		assert.Equal(t, test.err.Error(), err.Error())
+		if test.is != nil {
+			assert.True(t, errors.Is(err, test.is), test.err)
+		}
+		if test.err == invalid {
+			var forwardedInvalid *entities.ValidationError
+			require.True(t, errors.As(err, &forwardedInvalid))
+			assert.Equal(t, *invalid, *forwardedInvalid)
+		}
+	}
+
+	// handlers compare the bare errors
+	result := &raftResult{Error: ErrNotFound.Error(), Code: "not_found"}
+	assert.Equal(t, ErrNotFound, result.err())
+}
+
+func TestRaftFSMFailsInvalidCommands(t *testing.T) {
+	store, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	fsm := &raftFSM{store: store.(*InMemoryRepository)}
+
+	for _, command := range []string{`{"op":"UpdateCoffee"}`, `{"op":"CreateIngredient"}`, `{"op":"DeleteCoffee"}`, `{"op":"DropTables"}`, `{`} {
+		result := fsm.Apply(&raft.Log{Data: []byte(command)}).(*raftResult)
+		assert.NotEmpty(t, result.Error, command)
+	}
+}
+
+func TestRaftRecordsTheIndexOfWritesInTheSession(t *testing.T) {
+	replicas := setupRaft(t, 3)
+	follower := replicas[(leader(t, replicas)+1)%len(replicas)]
+
+	ctx, session := WithSession(context.Background())
+	require.NoError(t, follower.CreateCoffee(ctx, &entities.Coffee{Name: "Sessionato", Price: 200}))
+	index := session.Index()
+	assert.NotZero(t, index)
+
+	// the follower catches up with the write of the session
+	assert.Eventually(t, func() bool { return follower.AppliedIndex() >= index }, 5*time.Second, 10*time.Millisecond)
+	_, ok := follower.Primary()
+	assert.True(t, ok)
+	_, ok = replicas[leader(t, replicas)].Primary()
+	assert.False(t, ok)
+}
+
+func TestRaftSurvivesTheLossOfTheLeader(t *testing.T) {
+	ctx := context.Background()
+	replicas := setupRaft(t, 3)
+	lost := leader(t, replicas)
+
+	coffee := &entities.Coffee{Name: "Durable Doppio", Price: 250}
+	require.NoError(t, replicas[lost].CreateCoffee(ctx, coffee))
+	replicated(t, replicas, coffee.ID)
+	require.NoError(t, replicas[lost].Close())
+
+	remaining := append(append([]*RaftRepository{}, replicas[:lost]...), replicas[lost+1:]...)
+	next := remaining[leader(t, remaining)]
+
+	stored, err := next.FindByID(ctx, coffee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Durable Doppio", stored.Name)
+
+	another := &entities.Coffee{Name: "Quorum Cortado", Price: 200}
+	require.NoError(t, next.CreateCoffee(ctx, another))
+	assert.Greater(t, another.ID, coffee.ID)
+	replicated(t, remaining, another.ID)
+}
+
+// snapshotSink collects a persisted snapshot
+type snapshotSink struct {
+	bytes.Buffer
+}
+
+func (s *snapshotSink) ID() string    { return "test" }
+func (s *snapshotSink) Cancel() error { return nil }
+func (s *snapshotSink) Close() error  { return nil }
+
+func TestRaftSnapshotsRestoreTheCatalogue(t *testing.T) {
+	ctx := context.Background()
+	from, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	to, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	require.NoError(t, from.DeleteCoffee(ctx, 3))
+	require.NoError(t, from.CreateCoffee(ctx, &entities.Coffee{Name: "Snapshot Shot", Price: 200, Ingredients: []entities.CoffeeIngredients{{IngredientID: 5, Quantity: 10, Unit: "ml"}}}))
+
+	snapshot, err := (&raftFSM{store: from.(*InMemoryRepository)}).Snapshot()
+	require.NoError(t, err)
+	sink := &snapshotSink{}
+	require.NoError(t, snapshot.Persist(sink))
+	require.NoError(t, (&raftFSM{store: to.(*InMemoryRepository)}).Restore(ioutil.NopCloser(sink)))
+
+	expected, err := from.Find(ctx)
+	require.NoError(t, err)
+	restored, err := to.Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, restored, len(expected))
+	for n := range expected {
+		assert.Equal(t, expected[n].ID, restored[n].ID)
+	}
+	assert.Empty(t, diff(normalizeCoffees(expected), normalizeCoffees(restored)))
+}
+
+func TestRaftSnapshotsKeepSlugs(t *testing.T) {
+	ctx := context.Background()
+	from, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	to, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	// renamed to the name of a later coffee, which got the suffixed slug
+	renamed, err := from.FindByID(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, from.CreateCoffee(ctx, &entities.Coffee{Name: "Mocha", Price: 200}))
+	renamed.Name = "Mocha"
+	require.NoError(t, from.UpdateCoffee(ctx, renamed))
+	assert.Equal(t, "mocha-2", renamed.Slug)
+
+	snapshot, err := (&raftFSM{store: from.(*InMemoryRepository)}).Snapshot()
+	require.NoError(t, err)
+	sink := &snapshotSink{}
+	require.NoError(t, snapshot.Persist(sink))
+	require.NoError(t, (&raftFSM{store: to.(*InMemoryRepository)}).Restore(ioutil.NopCloser(sink)))
+
+	restored, err := to.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "mocha-2", restored.Slug)
+}
+
+func TestRaftRefusesTheCapabilityWritesItCanNotReplicate(t *testing.T) {
+	ctx := context.Background()
+	replicas := setupRaft(t, 1)
+	r := replicas[leader(t, replicas)]
+
+	coupons, err := FindCoupons(ctx, r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, coupons)
+	stores, err := FindStores(ctx, r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, stores)
+
+	assert.Equal(t, ErrCouponsUnsupported, CreateCoupon(ctx, r, &entities.Coupon{Code: "LOCAL", Type: entities.CouponFixed, Value: 1}))
+	assert.Equal(t, ErrTranslationsUnsupported, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: TranslationName, Value: "Café"}))
+	_, err = RecordPoints(ctx, r, &entities.PointsEntry{UserID: 7, OrderID: 30, Points: 10, Reason: entities.PointsEarned})
+	assert.Equal(t, ErrPointsUnsupported, err)
+	_, err = FindCoupon(ctx, r.Repository, "LOCAL")
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// minReconnectBackoff is the delay before the second attempt to re-establish
+// the connections, doubled after every failed attempt
+const minReconnectBackoff = 100 * time.Millisecond
+
+// ErrReconnecting is returned by IsConnected while the connections to the
+// database are re-established
+var ErrReconnecting = errors.New("re-establishing the database connections")
+
+// reconnector re-establishes the pool of a PostgresRepository once its
+// connections are lost, e.g. when Postgres restarts or fails over. Only one
+// attempt runs at a time, the repository reports itself as not connected
+// until it succeeds.
+type reconnector struct {
+	connection string
+	maxBackoff time.Duration
+	logger     hclog.Logger
+
+	// reconnecting is 1 while the pool is being re-established
+	reconnecting int32
+}
+
+// connector opens connections with the driver of an existing pool, so that
+// reconnecting does not register another tracing driver
+type connector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+// Connect opens a connection to the database
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the driver of the connector
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// connectionLost reports whether err means that the connection to the
+// database, rather than the query, failed. Read only transactions count as
+// lost connections as they are refused by a primary demoted by a failover.
+func connectionLost(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03", "25006":
+			// admin_shutdown, crash_shutdown, cannot_connect_now and
+			// read_only_sql_transaction
+			return true
+		}
+		// connection exceptions
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// pool returns the connection pool and statement cache of the repository
+func (r *PostgresRepository) pool() (*sqlx.DB, *statementCache) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.db, r.statements
+}
+
+// reconnecting reports whether the connections are being re-established
+func (r *PostgresRepository) reconnecting() bool {
+	return r.reconnect != nil && atomic.LoadInt32(&r.reconnect.reconnecting) == 1
+}
+
+// checkConnection starts re-establishing the connections when err shows they
+// were lost, it returns err unchanged
+func (r *PostgresRepository) checkConnection(err error) error {
+	if r.reconnect == nil || !connectionLost(err) {
+		return err
+	}
+	if !atomic.CompareAndSwapInt32(&r.reconnect.reconnecting, 0, 1) {
+		return err
+	}
+
+	r.reconnect.logger.Error("Lost the database connections, re-establishing them", "error", err)
+	go r.reestablish()
+
+	return err
+}
+
+// reestablish opens a new pool until one reaches the database, backing off
+// exponentially up to the maximum backoff between attempts. It then replaces
+// the pool and its prepared statements and closes the previous one, once the
+// connections in use have been returned.
+func (r *PostgresRepository) reestablish() {
+	previous, statements := r.pool()
+	backoff := minReconnectBackoff
+	if backoff > r.reconnect.maxBackoff {
+		backoff = r.reconnect.maxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		db := sqlx.NewDb(sql.OpenDB(connector{r.reconnect.connection, previous.Driver()}), previous.DriverName())
+		err := db.Ping()
+		if err == nil {
+			r.mu.Lock()
+			r.db = db
+			if statements != nil {
+				r.statements = newStatementCache(db, r.metrics)
+			}
+			r.mu.Unlock()
+			atomic.StoreInt32(&r.reconnect.reconnecting, 0)
+
+			statements.close()
+			previous.Close()
+			r.metrics.IncrCounter("db.reconnect", 1, metrics.Label{Name: "result", Value: "success"})
+			r.reconnect.logger.Info("Re-established the database connections", "attempts", attempt)
+			return
+		}
+
+		db.Close()
+		r.metrics.IncrCounter("db.reconnect", 1, metrics.Label{Name: "result", Value: "failure"})
+		r.reconnect.logger.Error("Unable to re-establish the database connections", "attempt", attempt, "retry_in", backoff, "error", err)
+
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > r.reconnect.maxBackoff {
+			backoff = r.reconnect.maxBackoff
+		}
+	}
+}
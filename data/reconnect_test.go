@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// flakyDriver refuses connections while the database is down
+type flakyDriver struct {
+	down int32
+}
+
+// Open connects unless the database is down
+func (d *flakyDriver) Open(string) (driver.Conn, error) {
+	if atomic.LoadInt32(&d.down) == 1 {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return flakyConn{}, nil
+}
+
+// flakyConn is a connection of the flakyDriver which cannot run queries
+type flakyConn struct{}
+
+func (flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (flakyConn) Close() error                        { return nil }
+func (flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func setupFlakyPostgres(d *flakyDriver, reconnect *reconnector) *PostgresRepository {
+	db := sqlx.NewDb(sql.OpenDB(connector{"flaky", d}), driverName)
+	return &PostgresRepository{db: db, reconnect: reconnect, metrics: metrics.FanoutSink{}}
+}
+
+func TestConnectionLost(t *testing.T) {
+	tests := []struct {
+		err  error
+		lost bool
+	}{
+		{nil, false},
+		{sql.ErrNoRows, false},
+		{context.DeadlineExceeded, false},
+		{fmt.Errorf("query: %w", context.Canceled), false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{fmt.Errorf("insert: %w", &pgconn.PgError{Code: "25006"}), true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, true},
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.lost, connectionLost(test.err), "%v", test.err)
+	}
+}
+
+func TestPostgresReestablishesLostConnections(t *testing.T) {
+	d := &flakyDriver{down: 1}
+	r := setupFlakyPostgres(d, &reconnector{connection: "flaky", maxBackoff: 10 * time.Millisecond, logger: hclog.NewNullLogger()})
+	previous := r.db
+
+	err := &pgconn.PgError{Code: "57P01"}
+	assert.Equal(t, err, r.checkConnection(err))
+
+	ok, connErr := r.IsConnected(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, ErrReconnecting, connErr)
+
+	atomic.StoreInt32(&d.down, 0)
+	assert.Eventually(t, func() bool {
+		ok, _ := r.IsConnected(context.Background())
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	db, _ := r.pool()
+	assert.NotSame(t, previous, db)
+	assert.Error(t, previous.Ping(), "the previous pool is closed")
+}
+
+func TestPostgresKeepsConnectionsOnQueryErrors(t *testing.T) {
+	r := setupFlakyPostgres(&flakyDriver{}, &reconnector{connection: "flaky", maxBackoff: time.Second, logger: hclog.NewNullLogger()})
+
+	r.checkConnection(context.DeadlineExceeded)
+	r.checkConnection(&pgconn.PgError{Code: "23505"})
+
+	ok, err := r.IsConnected(context.Background())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestPostgresWithoutReconnectorReportsLostConnections(t *testing.T) {
+	r := setupFlakyPostgres(&flakyDriver{down: 1}, nil)
+
+	ok, err := r.IsConnected(context.Background())
+	assert.False(t, ok)
+	assert.True(t, connectionLost(err))
+	assert.False(t, r.reconnecting())
+}
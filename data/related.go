@@ -0,0 +1,73 @@
+package data
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ErrNotFound is returned when the requested entity does not exist
+var ErrNotFound = errors.New("not found")
+
+// relatedCoffee pairs a coffee ID with its similarity to the source coffee
+type relatedCoffee struct {
+	ID         int
+	Similarity float64
+}
+
+// rankRelated ranks every other coffee by the Jaccard similarity of its
+// ingredient set against the ingredients of coffeeID. Coffees sharing no
+// ingredients are dropped, ties are broken by ascending ID so the order is
+// stable between calls.
+func rankRelated(coffeeID int, ingredientsByCoffee map[int][]entities.CoffeeIngredients) []relatedCoffee {
+	source := ingredientSet(ingredientsByCoffee[coffeeID])
+
+	ranked := make([]relatedCoffee, 0)
+	for id, ingredients := range ingredientsByCoffee {
+		if id == coffeeID {
+			continue
+		}
+
+		similarity := jaccard(source, ingredientSet(ingredients))
+		if similarity == 0 {
+			continue
+		}
+
+		ranked = append(ranked, relatedCoffee{ID: id, Similarity: similarity})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Similarity != ranked[j].Similarity {
+			return ranked[i].Similarity > ranked[j].Similarity
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	return ranked
+}
+
+// ingredientSet returns the distinct ingredient IDs of a coffee
+func ingredientSet(ingredients []entities.CoffeeIngredients) map[int]struct{} {
+	set := make(map[int]struct{}, len(ingredients))
+	for _, ingredient := range ingredients {
+		set[ingredient.IngredientID] = struct{}{}
+	}
+	return set
+}
+
+// jaccard computes |a ∩ b| / |a ∪ b|
+func jaccard(a, b map[int]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for id := range a {
+		if _, ok := b[id]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
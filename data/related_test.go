@@ -0,0 +1,42 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func coffeeIngredients(coffeeID int, ingredientIDs ...int) []entities.CoffeeIngredients {
+	ingredients := make([]entities.CoffeeIngredients, 0, len(ingredientIDs))
+	for _, id := range ingredientIDs {
+		ingredients = append(ingredients, entities.CoffeeIngredients{CoffeeID: coffeeID, IngredientID: id})
+	}
+	return ingredients
+}
+
+func TestRankRelatedOrdersBySimilarity(t *testing.T) {
+	ranked := rankRelated(1, map[int][]entities.CoffeeIngredients{
+		1: coffeeIngredients(1, 1, 2, 4),
+		2: coffeeIngredients(2, 1, 2),
+		3: coffeeIngredients(3, 2),
+		4: coffeeIngredients(4, 1),
+		5: coffeeIngredients(5, 5),
+	})
+
+	assert.Len(t, ranked, 3)
+	assert.Equal(t, 2, ranked[0].ID)
+	assert.InDelta(t, 2.0/3.0, ranked[0].Similarity, 0.0001)
+	// 3 and 4 tie on similarity, the lower ID wins
+	assert.Equal(t, 3, ranked[1].ID)
+	assert.Equal(t, 4, ranked[2].ID)
+}
+
+func TestRankRelatedIgnoresUnknownCoffee(t *testing.T) {
+	ranked := rankRelated(42, map[int][]entities.CoffeeIngredients{
+		1: coffeeIngredients(1, 1, 2),
+	})
+
+	assert.Empty(t, ranked)
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// ErrRemoteIngredients is returned for ingredient writes while ingredients
+// are owned by a remote service
+var ErrRemoteIngredients = errors.New("ingredients are managed by the ingredients service")
+
+// IngredientSource provides the ingredients owned outside of the repository,
+// e.g. the ingredients.Client of a remote ingredients service
+type IngredientSource interface {
+	Find(ctx context.Context) (entities.Ingredients, error)
+}
+
+// RemoteIngredientsRepository is a Repository reading ingredients from an
+// IngredientSource. Coffees and their ingredient quantities still come from
+// the wrapped repository, the ingredient names from the source.
+type RemoteIngredientsRepository struct {
+	Repository
+	source IngredientSource
+}
+
+// NewRemoteIngredients wraps repository to read ingredients from source
+func NewRemoteIngredients(repository Repository, source IngredientSource) *RemoteIngredientsRepository {
+	return &RemoteIngredientsRepository{Repository: repository, source: source}
+}
+
+// Unwrap returns the wrapped repository
+func (r *RemoteIngredientsRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Find returns all coffees with the ingredient names of the source
+func (r *RemoteIngredientsRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	coffees, err := r.Repository.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.name(ctx, coffees); err != nil {
+		return nil, err
+	}
+	return coffees, nil
+}
+
+// FindByID returns a single coffee with the ingredient names of the source
+func (r *RemoteIngredientsRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	coffee, err := r.Repository.FindByID(ctx, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	// the copy shares its ingredients with coffee
+	if err := r.name(ctx, entities.Coffees{*coffee}); err != nil {
+		return nil, err
+	}
+	return coffee, nil
+}
+
+// FindRelated returns the related coffees with the ingredient names of the
+// source
+func (r *RemoteIngredientsRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindRelated(ctx, coffeeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.name(ctx, coffees); err != nil {
+		return nil, err
+	}
+	return coffees, nil
+}
+
+// FindWhere returns the matching coffees with the ingredient names of the
+// source
+func (r *RemoteIngredientsRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindWhere(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.name(ctx, coffees); err != nil {
+		return nil, err
+	}
+	return coffees, nil
+}
+
+// FindIngredients returns the ingredients of the source
+func (r *RemoteIngredientsRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	return r.source.Find(ctx)
+}
+
+// CreateIngredient fails with ErrRemoteIngredients
+func (r *RemoteIngredientsRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	return ErrRemoteIngredients
+}
+
+// UpdateIngredient fails with ErrRemoteIngredients
+func (r *RemoteIngredientsRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	return ErrRemoteIngredients
+}
+
+// DeleteIngredient fails with ErrRemoteIngredients
+func (r *RemoteIngredientsRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	return ErrRemoteIngredients
+}
+
+// name sets the names of the ingredients of every coffee from the source.
+// Ingredients missing from the source keep their local name.
+func (r *RemoteIngredientsRepository) name(ctx context.Context, coffees entities.Coffees) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ingredients, err := r.source.Find(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[int]string, len(ingredients))
+	for _, i := range ingredients {
+		names[i.ID] = i.Name
+	}
+
+	for n := range coffees {
+		for i := range coffees[n].Ingredients {
+			if name, ok := names[coffees[n].Ingredients[i].IngredientID]; ok {
+				coffees[n].Ingredients[i].Name = name
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// staticIngredients is an IngredientSource returning fixed ingredients
+type staticIngredients struct {
+	ingredients entities.Ingredients
+	err         error
+}
+
+func (s staticIngredients) Find(ctx context.Context) (entities.Ingredients, error) {
+	return s.ingredients, s.err
+}
+
+func TestRemoteIngredientsNamesIngredients(t *testing.T) {
+	mr := &MockRepository{}
+	mr.On("Find").Return(entities.Coffees{{ID: 1, Ingredients: []entities.CoffeeIngredients{
+		{IngredientID: 1, Name: "Espresso"},
+		{IngredientID: 2, Name: "Milk"},
+	}}}, nil)
+	mr.On("FindByID", 1).Return(&entities.Coffee{ID: 1, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1}}}, nil)
+
+	r := NewRemoteIngredients(mr, staticIngredients{ingredients: entities.Ingredients{{ID: 1, Name: "Ristretto"}}})
+
+	coffees, err := r.Find(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Ristretto", coffees[0].Ingredients[0].Name)
+	// ingredients unknown to the source keep their name
+	assert.Equal(t, "Milk", coffees[0].Ingredients[1].Name)
+
+	coffee, err := r.FindByID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Ristretto", coffee.Ingredients[0].Name)
+
+	ingredients, err := r.FindIngredients(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, ingredients, 1)
+	assert.Equal(t, ErrRemoteIngredients, r.CreateIngredient(context.Background(), &entities.Ingredient{}))
+}
+
+func TestRemoteIngredientsFailsWithTheSource(t *testing.T) {
+	mr := &MockRepository{}
+	mr.On("Find").Return(entities.Coffees{{ID: 1}}, nil)
+
+	r := NewRemoteIngredients(mr, staticIngredients{err: errors.New("ingredients service returned 503 Service Unavailable")})
+
+	_, err := r.Find(context.Background())
+	assert.EqualError(t, err, "ingredients service returned 503 Service Unavailable")
+}
@@ -1,27 +1,71 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 
 	// otlog "github.com/opentracing/opentracing-go/log"
 	"contrib.go.opencensus.io/integrations/ocsql"
+	// registers the pgx database/sql driver
+	_ "github.com/jackc/pgx/v4/stdlib"
 
 	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
 )
 
-// Repository is the command/query interface this respository supports.
+// Repository is the command/query interface this respository supports. The
+// lists returned by Find and FindWhere belong to the caller, which can return
+// them to the pool with entities.PutCoffees once they are encoded.
 type Repository interface {
-	Find() (entities.Coffees, error)
+	Find(ctx context.Context) (entities.Coffees, error)
+	FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error)
+	FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error)
+	FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error)
+	IsConnected(ctx context.Context) (bool, error)
+
+	CreateCoffee(ctx context.Context, coffee *entities.Coffee) error
+	UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error
+	DeleteCoffee(ctx context.Context, coffeeID int) error
+
+	FindIngredients(ctx context.Context) (entities.Ingredients, error)
+	CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error
+	UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error
+	DeleteIngredient(ctx context.Context, ingredientID int) error
 }
 
 // PostgresRepository is a postgres implementation of the Repository interface.
 type PostgresRepository struct {
+	// mu guards db and statements, which are replaced when the connections
+	// are re-established
+	mu sync.RWMutex
 	db *sqlx.DB
+	// statements caches prepared statements, queries are not prepared when
+	// it is nil
+	statements *statementCache
+	// reconnect re-establishes the connections once they are lost, queries
+	// keep failing when it is nil
+	reconnect *reconnector
+	metrics   metrics.Sink
+	// batch loads coffees and their ingredients in a single round trip, it
+	// needs the native pgx connections the tracing driver hides
+	batch bool
+	// postgis measures store distances with PostGIS geography functions
+	// instead of the haversine formula
+	postgis bool
+	// entityIDs is the ENTITY_IDS setting, the UUIDs of new coupons, order
+	// records and points entries
+	entityIDs string
+}
+
+func init() {
+	RegisterBackend(config.PostgresBackend, NewFromConfig)
 }
 
 // NewFromConfig is the CoffeeRepository factory method. It encapsulates the Postgres DB.
@@ -30,7 +74,8 @@ type PostgresRepository struct {
 // is possible (likely) that the app will come up before the database, this can
 // cause the app to go into a CrashLoopBackoff cycle. By defining a retry loop,
 // we are implementing circuit breaker, rather than just crashing on startup
-// if the db is unavailable.
+// if the db is unavailable. Once connected, the schema of the database must be
+// at SchemaVersion, an older one is migrated first with DB_MIGRATE.
 // TODO: this whole thing needs to be addressed.  Probably we want to move
 // the circuit breaking back to the lifecycle in main, and have this just
 // test IsConnected() or just try to make the call.
@@ -49,6 +94,31 @@ func NewFromConfig(cfg *config.Config) (Repository, error) {
 			repository, err = newPostgres(cfg.ConnectionString)
 		}
 		if err == nil {
+			if cfg.Metrics != nil {
+				repository.metrics = cfg.Metrics
+			}
+			if cfg.DBPrepareStatements {
+				repository.statements = newStatementCache(repository.db, repository.metrics)
+			}
+			repository.postgis = cfg.DBPostGIS
+			repository.entityIDs = cfg.EntityIDs
+			if cfg.DBReconnectBackoff > 0 {
+				repository.reconnect = &reconnector{
+					connection: cfg.ConnectionString,
+					maxBackoff: cfg.DBReconnectBackoff,
+					logger:     cfg.Logger,
+				}
+			}
+
+			// a mismatched schema is not retried, waiting does not change it
+			applied, err := repository.checkSchema(context.Background(), cfg.DBMigrate)
+			if err != nil {
+				repository.db.Close()
+				return nil, err
+			}
+			for _, name := range applied {
+				cfg.Logger.Info("Applied migration", "migration", name, "schema_version", SchemaVersion)
+			}
 			return repository, nil
 		}
 
@@ -64,14 +134,25 @@ func NewFromConfig(cfg *config.Config) (Repository, error) {
 	}
 }
 
+// CheckConnection opens a single connection to the database and pings it,
+// without the retries of NewFromConfig
+func CheckConnection(ctx context.Context, connection string) error {
+	db, err := sqlx.ConnectContext(ctx, driverName, connection)
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
 // new creates a new connection to the database
 func newPostgres(connection string) (*PostgresRepository, error) {
-	db, err := sqlx.Connect("postgres", connection)
+	db, err := sqlx.Connect(driverName, connection)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PostgresRepository{db: db}, nil
+	return &PostgresRepository{db: db, metrics: metrics.FanoutSink{}, batch: true}, nil
 }
 
 // newWithTracing wraps the connection with OpenCensus instrumentation
@@ -79,7 +160,7 @@ func newPostgres(connection string) (*PostgresRepository, error) {
 func newPostgresWithTracing(connection string) (*PostgresRepository, error) {
 	// Lifted from here:  https://github.com/opencensus-integrations/ocsql#jmoironsqlx
 	// Register our ocsql wrapper for the provided Postgres driver.
-	driverName, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
+	tracingDriverName, err := ocsql.Register(driverName, ocsql.WithAllTraceOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -87,37 +168,1163 @@ func newPostgresWithTracing(connection string) (*PostgresRepository, error) {
 	// Connect to a Postgres database using the ocsql driver wrapper.
 	// TODO: Test this - might need to use url format conn string like so
 	// "postgres://localhost:5432/my_database"
-	db, err := sql.Open(driverName, connection)
+	db, err := sql.Open(tracingDriverName, connection)
 	if err != nil {
 		return nil, err
 	}
 
 	// Wrap our *sql.DB with sqlx. use the original db driver name!!!
-	dbx := sqlx.NewDb(db, "postgres")
+	dbx := sqlx.NewDb(db, driverName)
+
+	return &PostgresRepository{db: dbx, metrics: metrics.FanoutSink{}}, nil
+}
+
+// IsConnected checks the connection to the database, it fails with
+// ErrReconnecting while lost connections are re-established
+func (r *PostgresRepository) IsConnected(ctx context.Context) (bool, error) {
+	if r.reconnecting() {
+		return false, ErrReconnecting
+	}
+
+	db, _ := r.pool()
+	err := db.PingContext(ctx)
+	if err != nil {
+		return false, r.checkConnection(err)
+	}
 
-	return &PostgresRepository{dbx}, nil
+	return true, nil
 }
 
 // Find returns all products from the database
-// Used to accept ctx opentracing.SpanContext
-func (r *PostgresRepository) Find() (entities.Coffees, error) {
+func (r *PostgresRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	return r.findCoffees(ctx, "")
+}
+
+// FindByID returns a single coffee
+func (r *PostgresRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	coffees, err := r.findCoffees(ctx, "WHERE id=$1", coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(coffees) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &coffees[0], nil
+}
+
+// FindBySlug returns the coffee with the slug
+func (r *PostgresRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	coffees, err := r.findCoffees(ctx, "WHERE slug=$1", slug)
+	if err != nil {
+		return nil, err
+	}
+	if len(coffees) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &coffees[0], nil
+}
+
+// FindWhere returns the coffees matching the filter expression
+func (r *PostgresRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	clause, args := filter.ToSQL(expr, 0)
+	return r.findCoffees(ctx, "WHERE "+clause, args...)
+}
+
+// FindSimilar returns the coffees whose name has a similarity of at least
+// threshold to name, most similar first. The % operator selects the
+// candidates from the trigram index of the names, which holds every match
+// while threshold is above the pg_trgm.similarity_threshold of 0.3.
+func (r *PostgresRepository) FindSimilar(ctx context.Context, name string, threshold float64) (entities.Coffees, error) {
+	where := "WHERE name % $1 AND similarity(name, $1) >= $2"
+	if threshold < 0.3 {
+		where = "WHERE similarity(name, $1) >= $2"
+	}
+
+	coffees, err := r.findCoffees(ctx, where, name, threshold)
+	if err != nil {
+		return nil, err
+	}
+	sortBySimilarity(name, coffees)
+	return coffees, nil
+}
+
+// findCoffees returns the coffees matching the where clause, including their
+// ingredients. They are loaded with a single batch when possible, and with a
+// query for the coffees and one for their ingredients otherwise.
+func (r *PostgresRepository) findCoffees(ctx context.Context, where string, args ...interface{}) (entities.Coffees, error) {
+	if r.batch {
+		coffees, err := r.findCoffeesBatch(ctx, where, args...)
+		if err != errNoPgxConn {
+			return coffees, err
+		}
+	}
+
+	coffees := entities.GetCoffees()
+	err := r.selectContext(ctx, &coffees, "SELECT * FROM coffee "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.withIngredients(ctx, coffees)
+}
+
+// withIngredients loads the ingredients of every coffee, including the
+// ingredient names and quantities, with a single query
+func (r *PostgresRepository) withIngredients(ctx context.Context, coffees entities.Coffees) (entities.Coffees, error) {
+	if len(coffees) == 0 {
+		return coffees, nil
+	}
+
+	ids := make([]int64, 0, len(coffees))
+	for _, coffee := range coffees {
+		ids = append(ids, int64(coffee.ID))
+	}
+
+	rows := []entities.CoffeeIngredients{}
+	err := r.selectContext(ctx, &rows, coffeeIngredientsQuery+`
+		WHERE ci.coffee_id = ANY($1)
+		ORDER BY ci.id`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachIngredients(coffees, rows), nil
+}
+
+// coffeeIngredientsQuery selects the ingredients of coffees, including the
+// ingredient names and quantities
+const coffeeIngredientsQuery = `
+		SELECT ci.coffee_id, ci.ingredient_id, ci.quantity, ci.unit, i.name FROM coffee_ingredient ci
+		JOIN ingredient i ON i.id=ci.ingredient_id`
+
+// attachIngredients sets the ingredients of every coffee from rows of
+// coffeeIngredientsQuery
+func attachIngredients(coffees entities.Coffees, rows []entities.CoffeeIngredients) entities.Coffees {
+	ingredientsByCoffee := make(map[int][]entities.CoffeeIngredients, len(coffees))
+	for _, row := range rows {
+		ingredientsByCoffee[row.CoffeeID] = append(ingredientsByCoffee[row.CoffeeID], row)
+	}
+
+	for n := range coffees {
+		coffees[n].Ingredients = ingredientsByCoffee[coffees[n].ID]
+		if coffees[n].Ingredients == nil {
+			coffees[n].Ingredients = []entities.CoffeeIngredients{}
+		}
+	}
+
+	return coffees
+}
+
+// FindRelated returns up to limit coffees sharing the most ingredients with
+// coffeeID, ranked by the Jaccard similarity of their ingredient sets.
+func (r *PostgresRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	exists := 0
+	err := r.getContext(ctx, &exists, "SELECT COUNT(*) FROM coffee WHERE id=$1", coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, ErrNotFound
+	}
+
 	coffees := entities.Coffees{}
 
-	err := r.db.Select(&coffees, "SELECT * FROM coffee")
+	// |A ∩ B| / (|A| + |B| - |A ∩ B|) computed over the join table
+	err = r.selectContext(ctx, &coffees, `
+		SELECT c.* FROM coffee c
+		JOIN (
+			SELECT ci.coffee_id,
+				COUNT(*) FILTER (WHERE ci.ingredient_id IN (SELECT ingredient_id FROM coffee_ingredient WHERE coffee_id=$1)) AS shared,
+				COUNT(*) AS total
+			FROM coffee_ingredient ci
+			WHERE ci.coffee_id<>$1
+			GROUP BY ci.coffee_id
+		) s ON s.coffee_id=c.id
+		WHERE s.shared > 0
+		ORDER BY s.shared::float / (s.total + (SELECT COUNT(*) FROM coffee_ingredient WHERE coffee_id=$1) - s.shared) DESC, c.id
+		LIMIT $2`, coffeeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.withIngredients(ctx, coffees)
+}
+
+// CreateCoffee inserts a coffee and its ingredients, assigning the coffee ID
+// and the timestamps
+func (r *PostgresRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := coffee.Validate(); err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		status, err := initialStatus(coffee.Status)
+		if err != nil {
+			return err
+		}
+		slug, err := slugFor(nil, coffee.Name, slugTaken(ctx, tx, 0))
+		if err != nil {
+			return err
+		}
+
+		err = txGet(ctx, tx, coffee, `
+			INSERT INTO coffee (name, slug, teaser, description, price, image, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+			RETURNING id, slug, status, created_at, updated_at`,
+			coffee.Name, slug, coffee.Teaser, coffee.Description, coffee.Price, coffee.Image, status)
+		if err != nil {
+			return err
+		}
+
+		coffee.Ingredients, err = replaceCoffeeIngredients(ctx, tx, coffee.ID, coffee.Ingredients)
+		return err
+	})
+}
+
+// UpdateCoffee replaces the attributes and ingredients of an existing coffee
+func (r *PostgresRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := coffee.Validate(); err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		previous := &entities.Coffee{}
+		err := txGet(ctx, tx, previous, "SELECT name, slug, status FROM coffee WHERE id=$1 FOR UPDATE", coffee.ID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		status, err := nextStatus(previous.Status, coffee.Status)
+		if err != nil {
+			return err
+		}
+		slug, err := slugFor(previous, coffee.Name, slugTaken(ctx, tx, coffee.ID))
+		if err != nil {
+			return err
+		}
+
+		err = txGet(ctx, tx, coffee, `
+			UPDATE coffee SET name=$2, slug=$3, teaser=$4, description=$5, price=$6, image=$7, status=$8, updated_at=now()
+			WHERE id=$1
+			RETURNING id, slug, status, created_at, updated_at`,
+			coffee.ID, coffee.Name, slug, coffee.Teaser, coffee.Description, coffee.Price, coffee.Image, status)
+		if err != nil {
+			return err
+		}
+
+		coffee.Ingredients, err = replaceCoffeeIngredients(ctx, tx, coffee.ID, coffee.Ingredients)
+		return err
+	})
+}
+
+// DeleteCoffee removes a coffee and its ingredients
+func (r *PostgresRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := txExec(ctx, tx, "DELETE FROM coffee_ingredient WHERE coffee_id=$1", coffeeID); err != nil {
+			return err
+		}
+
+		return deleteByID(ctx, tx, "DELETE FROM coffee WHERE id=$1", coffeeID)
+	})
+}
+
+// DeleteWhere deletes the coffees matching expr and their ingredients in one
+// transaction. The matching rows are locked first, so the IDs returned are
+// exactly the ones deleted, and a dry run only reads them.
+func (r *PostgresRepository) DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) ([]int, error) {
+	clause, args := filter.ToSQL(expr, 0)
+
+	ids := []int64{}
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if err := txSelect(ctx, tx, &ids, "SELECT id FROM coffee WHERE "+clause+" ORDER BY id FOR UPDATE", args...); err != nil {
+			return err
+		}
+		if dryRun || len(ids) == 0 {
+			return nil
+		}
+
+		if _, err := txExec(ctx, tx, "DELETE FROM coffee_ingredient WHERE coffee_id = ANY($1)", ids); err != nil {
+			return err
+		}
+		_, err := txExec(ctx, tx, "DELETE FROM coffee WHERE id = ANY($1)", ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]int, 0, len(ids))
+	for _, id := range ids {
+		deleted = append(deleted, int(id))
+	}
+	return deleted, nil
+}
+
+// FindIngredients returns all ingredients
+func (r *PostgresRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	ingredients := entities.Ingredients{}
+
+	err := r.selectContext(ctx, &ingredients, "SELECT * FROM ingredient ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// CreateIngredient inserts an ingredient, assigning the ingredient ID and the
+// timestamps
+func (r *PostgresRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := ingredient.Validate(); err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		return txGet(ctx, tx, ingredient, `
+			INSERT INTO ingredient (name, quantity, unit, created_at, updated_at)
+			VALUES ($1, $2, $3, now(), now())
+			RETURNING id, created_at, updated_at`,
+			ingredient.Name, ingredient.Quantity, ingredient.Unit)
+	})
+}
+
+// UpdateIngredient replaces the attributes of an existing ingredient
+func (r *PostgresRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := ingredient.Validate(); err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := txGet(ctx, tx, ingredient, `
+			UPDATE ingredient SET name=$2, quantity=$3, unit=$4, updated_at=now()
+			WHERE id=$1
+			RETURNING id, created_at, updated_at`,
+			ingredient.ID, ingredient.Name, ingredient.Quantity, ingredient.Unit)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+// DeleteIngredient removes an ingredient and drops it from every coffee
+func (r *PostgresRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := txExec(ctx, tx, "DELETE FROM coffee_ingredient WHERE ingredient_id=$1", ingredientID); err != nil {
+			return err
+		}
+
+		return deleteByID(ctx, tx, "DELETE FROM ingredient WHERE id=$1", ingredientID)
+	})
+}
+
+// FindTranslations returns the translations of the coffees in coffeeIDs into
+// locales, or into every locale when locales is empty
+func (r *PostgresRepository) FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error) {
+	ids := make([]int64, len(coffeeIDs))
+	for n, id := range coffeeIDs {
+		ids[n] = int64(id)
+	}
+
+	translations := entities.Translations{}
+	var err error
+	if len(locales) == 0 {
+		err = r.selectContext(ctx, &translations, `
+			SELECT coffee_id, locale, field, value, updated_at FROM coffee_translation
+			WHERE coffee_id = ANY($1)
+			ORDER BY coffee_id, locale, field`, ids)
+	} else {
+		err = r.selectContext(ctx, &translations, `
+			SELECT coffee_id, locale, field, value, updated_at FROM coffee_translation
+			WHERE coffee_id = ANY($1) AND locale = ANY($2)
+			ORDER BY coffee_id, locale, field`, ids, locales)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// SetTranslation creates or replaces the translation of a coffee field
+func (r *PostgresRepository) SetTranslation(ctx context.Context, translation *entities.Translation) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		// nothing is inserted for a missing coffee
+		err := txGet(ctx, tx, &translation.UpdatedAt, `
+			INSERT INTO coffee_translation (coffee_id, locale, field, value, updated_at)
+			SELECT id, $2, $3, $4, now() FROM coffee WHERE id=$1
+			ON CONFLICT (coffee_id, locale, field) DO UPDATE SET value=EXCLUDED.value, updated_at=EXCLUDED.updated_at
+			RETURNING updated_at`,
+			translation.CoffeeID, translation.Locale, translation.Field, translation.Value)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+// DeleteTranslation removes the translation of a coffee field
+func (r *PostgresRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := txExec(ctx, tx, "DELETE FROM coffee_translation WHERE coffee_id=$1 AND locale=$2 AND field=$3", coffeeID, locale, field)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// FindImages returns the images of the coffees in coffeeIDs
+func (r *PostgresRepository) FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error) {
+	ids := make([]int64, len(coffeeIDs))
+	for n, id := range coffeeIDs {
+		ids[n] = int64(id)
+	}
+
+	images := entities.CoffeeImages{}
+	err := r.selectContext(ctx, &images, `
+		SELECT coffee_id, size, url, width, height, updated_at FROM coffee_image
+		WHERE coffee_id = ANY($1)
+		ORDER BY coffee_id, width`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// SetImage creates or replaces a size of the image of a coffee, the full image
+// replaces every size
+func (r *PostgresRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if image.Size == ImageFull {
+			if _, err := txExec(ctx, tx, "DELETE FROM coffee_image WHERE coffee_id=$1", image.CoffeeID); err != nil {
+				return err
+			}
+		}
+
+		// nothing is inserted for a missing coffee
+		err := txGet(ctx, tx, &image.UpdatedAt, `
+			INSERT INTO coffee_image (coffee_id, size, url, width, height, updated_at)
+			SELECT id, $2, $3, $4, $5, now() FROM coffee WHERE id=$1
+			ON CONFLICT (coffee_id, size) DO UPDATE SET url=EXCLUDED.url, width=EXCLUDED.width, height=EXCLUDED.height, updated_at=EXCLUDED.updated_at
+			RETURNING updated_at`,
+			image.CoffeeID, image.Size, image.URL, image.Width, image.Height)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+// FindStores returns every store
+func (r *PostgresRepository) FindStores(ctx context.Context) (entities.Stores, error) {
+	stores := entities.Stores{}
+	err := r.selectContext(ctx, &stores, "SELECT id, name, address, city, country, latitude, longitude, created_at, updated_at FROM store ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return stores, nil
+}
+
+// FindStore returns a store, or ErrNotFound
+func (r *PostgresRepository) FindStore(ctx context.Context, storeID int) (*entities.Store, error) {
+	store := entities.Store{}
+	err := r.getContext(ctx, &store, "SELECT id, name, address, city, country, latitude, longitude, created_at, updated_at FROM store WHERE id=$1", storeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// FindStoreCoffees returns the IDs of the coffees on the menu of a store
+func (r *PostgresRepository) FindStoreCoffees(ctx context.Context, storeID int) ([]int, error) {
+	if _, err := r.FindStore(ctx, storeID); err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	if err := r.selectContext(ctx, &ids, "SELECT coffee_id FROM store_coffee WHERE store_id=$1 ORDER BY coffee_id", storeID); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// haversineQuery selects the stores within $3 kilometres of the point at
+// latitude $1 and longitude $2 with the haversine formula, the radius of the
+// Earth matching earthRadiusKm
+const haversineQuery = `
+	SELECT * FROM (
+		SELECT id, name, address, city, country, latitude, longitude, created_at, updated_at,
+			2 * 6371.0088 * asin(least(1, sqrt(
+				power(sin(radians(latitude - $1) / 2), 2) +
+				cos(radians($1)) * cos(radians(latitude)) * power(sin(radians(longitude - $2) / 2), 2)
+			))) AS distance_km
+		FROM store
+	) s
+	WHERE distance_km <= $3
+	ORDER BY distance_km, id`
+
+// postgisQuery is haversineQuery with PostGIS, whose ST_DWithin can use a
+// GIST index on the geography of the stores. Distances are measured on the
+// same sphere so both queries agree.
+const postgisQuery = `
+	SELECT id, name, address, city, country, latitude, longitude, created_at, updated_at,
+		ST_Distance(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint($2, $1)::geography, false) / 1000 AS distance_km
+	FROM store
+	WHERE ST_DWithin(ST_MakePoint(longitude, latitude)::geography, ST_MakePoint($2, $1)::geography, $3::float8 * 1000, false)
+	ORDER BY distance_km, id`
+
+// FindStoresNear returns the stores within radiusKm of a point, nearest first
+func (r *PostgresRepository) FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (entities.Stores, error) {
+	query := haversineQuery
+	if r.postgis {
+		query = postgisQuery
+	}
+
+	stores := entities.Stores{}
+	if err := r.selectContext(ctx, &stores, query, lat, lon, radiusKm); err != nil {
+		return nil, err
+	}
+
+	return stores, nil
+}
+
+// supplierRow is a supplier as stored, with comma separated certifications
+type supplierRow struct {
+	entities.Supplier
+	StoredCertifications string `db:"certifications"`
+}
+
+// FindSuppliers returns every supplier with the ingredients it supplies
+func (r *PostgresRepository) FindSuppliers(ctx context.Context) (entities.Suppliers, error) {
+	rows := []supplierRow{}
+	if err := r.selectContext(ctx, &rows, "SELECT id, name, country, certifications, created_at, updated_at FROM supplier ORDER BY id"); err != nil {
+		return nil, err
+	}
+	links := []entities.IngredientSupplier{}
+	if err := r.selectContext(ctx, &links, "SELECT ingredient_id, supplier_id FROM ingredient_supplier ORDER BY ingredient_id"); err != nil {
+		return nil, err
+	}
+
+	supplied := map[int][]int{}
+	for _, link := range links {
+		supplied[link.SupplierID] = append(supplied[link.SupplierID], link.IngredientID)
+	}
+	suppliers := make(entities.Suppliers, len(rows))
+	for n, row := range rows {
+		suppliers[n] = row.supplier(supplied[row.ID])
+	}
+	return suppliers, nil
+}
+
+// FindIngredientSupplier returns the supplier of an ingredient, or ErrNotFound
+func (r *PostgresRepository) FindIngredientSupplier(ctx context.Context, ingredientID int) (*entities.Supplier, error) {
+	row := supplierRow{}
+	err := r.getContext(ctx, &row, `
+		SELECT s.id, s.name, s.country, s.certifications, s.created_at, s.updated_at FROM supplier s
+		JOIN ingredient_supplier i ON i.supplier_id = s.id
+		WHERE i.ingredient_id=$1`, ingredientID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	for n, coffee := range coffees {
-		coffeeIngredients := []entities.CoffeeIngredients{}
+	ids := []int{}
+	if err := r.selectContext(ctx, &ids, "SELECT ingredient_id FROM ingredient_supplier WHERE supplier_id=$1 ORDER BY ingredient_id", row.ID); err != nil {
+		return nil, err
+	}
+
+	supplier := row.supplier(ids)
+	return &supplier, nil
+}
+
+// supplier returns the stored supplier with its certifications split and the
+// ingredients it supplies
+func (row supplierRow) supplier(ingredientIDs []int) entities.Supplier {
+	supplier := row.Supplier
+	supplier.Certifications = []string{}
+	if row.StoredCertifications != "" {
+		supplier.Certifications = strings.Split(row.StoredCertifications, ",")
+	}
+	supplier.IngredientIDs = ingredientIDs
+	if supplier.IngredientIDs == nil {
+		supplier.IngredientIDs = []int{}
+	}
+	return supplier
+}
+
+// couponColumns are the columns a coupon is read from, uuid is NULL for the
+// coupons created without ENTITY_IDS=uuidv7
+const couponColumns = "code, COALESCE(uuid::text, '') AS uuid, type, value, expires_at, usage_limit, used, created_at, updated_at"
+
+// FindCoupons returns every coupon, ordered by code
+func (r *PostgresRepository) FindCoupons(ctx context.Context) (entities.Coupons, error) {
+	coupons := entities.Coupons{}
+	if err := r.selectContext(ctx, &coupons, "SELECT "+couponColumns+" FROM coupon ORDER BY code"); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// FindCoupon returns a coupon, or ErrNotFound
+func (r *PostgresRepository) FindCoupon(ctx context.Context, code string) (*entities.Coupon, error) {
+	coupon := &entities.Coupon{}
+	err := r.getContext(ctx, coupon, "SELECT "+couponColumns+" FROM coupon WHERE code=$1", code)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// CreateCoupon inserts a coupon, assigning the timestamps and the UUID
+func (r *PostgresRepository) CreateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	uuid, err := entityUUID(r.entityIDs)
+	if err != nil {
+		return err
+	}
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := txGet(ctx, tx, coupon, `
+			INSERT INTO coupon (code, uuid, type, value, expires_at, usage_limit, used, created_at, updated_at)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, 0, now(), now())
+			ON CONFLICT (code) DO NOTHING
+			RETURNING `+couponColumns,
+			coupon.Code, uuid, coupon.Type, coupon.Value, coupon.ExpiresAt, coupon.UsageLimit)
+		if err == sql.ErrNoRows {
+			return ErrCouponExists
+		}
+		return err
+	})
+}
+
+// UpdateCoupon replaces a coupon, keeping its uses
+func (r *PostgresRepository) UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := txGet(ctx, tx, coupon, `
+			UPDATE coupon SET type=$2, value=$3, expires_at=$4, usage_limit=$5, updated_at=now()
+			WHERE code=$1
+			RETURNING `+couponColumns,
+			coupon.Code, coupon.Type, coupon.Value, coupon.ExpiresAt, coupon.UsageLimit)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+// DeleteCoupon removes a coupon
+func (r *PostgresRepository) DeleteCoupon(ctx context.Context, code string) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := txExec(ctx, tx, "DELETE FROM coupon WHERE code=$1", code)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
 
-		err := r.db.Select(&coffeeIngredients, "SELECT ingredient_id FROM coffee_ingredient WHERE coffee_id=$1", coffee.ID)
+// RedeemCoupon counts a use of a coupon with a single conditional update, so
+// concurrent redemptions never exceed the usage limit. When nothing is
+// updated the coupon is read again to tell why.
+func (r *PostgresRepository) RedeemCoupon(ctx context.Context, code string, t time.Time) (*entities.Coupon, error) {
+	coupon := &entities.Coupon{}
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		return txGet(ctx, tx, coupon, `
+			UPDATE coupon SET used=used+1, updated_at=now()
+			WHERE code=$1 AND (expires_at IS NULL OR expires_at > $2) AND (usage_limit = 0 OR used < usage_limit)
+			RETURNING `+couponColumns,
+			code, t)
+	})
+	if err != sql.ErrNoRows {
 		if err != nil {
 			return nil, err
 		}
+		return coupon, nil
+	}
 
-		coffees[n].Ingredients = coffeeIngredients
+	if coupon, err = r.FindCoupon(ctx, code); err != nil {
+		return nil, err
+	}
+	if err := redeemable(coupon, t); err != nil {
+		return nil, err
 	}
+	// the coupon changed between the update and the read
+	return nil, ErrCouponExhausted
+}
 
-	return coffees, nil
+// ReleaseCoupon gives back a use of a coupon, never counting below 0
+func (r *PostgresRepository) ReleaseCoupon(ctx context.Context, code string) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := txExec(ctx, tx, "UPDATE coupon SET used=GREATEST(used-1, 0), updated_at=now() WHERE code=$1", code)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// pointsEntryColumns are the columns a points entry is read from, uuid is
+// NULL for the entries recorded without ENTITY_IDS=uuidv7
+const pointsEntryColumns = "id, COALESCE(uuid::text, '') AS uuid, user_id, order_id, points, reason, created_at"
+
+// FindPoints returns the balance and the ledger of a user, oldest entry
+// first. The balance is summed from the entries read, so the two agree.
+func (r *PostgresRepository) FindPoints(ctx context.Context, userID int) (*entities.Points, error) {
+	points := &entities.Points{UserID: userID, Ledger: []entities.PointsEntry{}}
+	if err := r.selectContext(ctx, &points.Ledger, "SELECT "+pointsEntryColumns+" FROM points_entry WHERE user_id=$1 ORDER BY id", userID); err != nil {
+		return nil, err
+	}
+	for _, entry := range points.Ledger {
+		points.Balance += entry.Points
+	}
+	return points, nil
+}
+
+// RecordPoints applies an entry to the balance of its user with a single
+// conditional update, and appends it to the ledger in the same transaction
+func (r *PostgresRepository) RecordPoints(ctx context.Context, entry *entities.PointsEntry) (int, error) {
+	if err := r.assignUUIDs(nil, []*entities.PointsEntry{entry}); err != nil {
+		return 0, err
+	}
+
+	balance := 0
+	err := r.inTx(ctx, func(tx *sqlx.Tx) (err error) {
+		balance, err = recordPoints(ctx, tx, entry)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// recordPoints applies an entry to the balance of its user and appends it to
+// the ledger within tx with the UUID it holds, returning the new balance
+func recordPoints(ctx context.Context, tx *sqlx.Tx, entry *entities.PointsEntry) (int, error) {
+	if _, err := txExec(ctx, tx, "INSERT INTO points_balance (user_id, balance) VALUES ($1, 0) ON CONFLICT (user_id) DO NOTHING", entry.UserID); err != nil {
+		return 0, err
+	}
+
+	balance := 0
+	err := txGet(ctx, tx, &balance, "UPDATE points_balance SET balance=balance+$2 WHERE user_id=$1 AND balance+$2 >= 0 RETURNING balance", entry.UserID, entry.Points)
+	if err == sql.ErrNoRows {
+		return 0, ErrInsufficientPoints
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = txGet(ctx, tx, entry, `
+		INSERT INTO points_entry (uuid, user_id, order_id, points, reason, created_at)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, now())
+		RETURNING `+pointsEntryColumns,
+		entry.UUID, entry.UserID, entry.OrderID, entry.Points, entry.Reason)
+	return balance, err
+}
+
+// userColumns are the columns a profile is read from
+const userColumns = "subject, display_name, favorite_milk, default_store_id, created_at, updated_at"
+
+// FindUser returns the profile of a user, or ErrNotFound
+func (r *PostgresRepository) FindUser(ctx context.Context, subject string) (*entities.User, error) {
+	user := &entities.User{}
+	err := r.getContext(ctx, user, "SELECT "+userColumns+" FROM users WHERE subject=$1", subject)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SaveUser inserts or replaces the profile of a user with a single upsert
+func (r *PostgresRepository) SaveUser(ctx context.Context, user *entities.User) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		return txGet(ctx, tx, user, `
+			INSERT INTO users (subject, display_name, favorite_milk, default_store_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, now(), now())
+			ON CONFLICT (subject) DO UPDATE SET
+				display_name=EXCLUDED.display_name,
+				favorite_milk=EXCLUDED.favorite_milk,
+				default_store_id=EXCLUDED.default_store_id,
+				updated_at=now()
+			RETURNING `+userColumns,
+			user.Subject, user.DisplayName, user.FavoriteMilk, user.DefaultStoreID)
+	})
+}
+
+// RecordOrder inserts an order record and its items, replacing the record of
+// the same order, and moves the order between the hourly sales in one
+// transaction
+func (r *PostgresRepository) RecordOrder(ctx context.Context, order *entities.OrderRecord) error {
+	if err := r.assignUUIDs(order, nil); err != nil {
+		return err
+	}
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		return recordOrder(ctx, tx, order)
+	})
+}
+
+// RecordOrderPoints records an order and applies its points entries in one
+// transaction
+func (r *PostgresRepository) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	if err := r.assignUUIDs(order, entries); err != nil {
+		return err
+	}
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		for _, entry := range entries {
+			if _, err := recordPoints(ctx, tx, entry); err != nil {
+				return err
+			}
+		}
+		return recordOrder(ctx, tx, order)
+	})
+}
+
+// assignUUIDs assigns the UUIDs of ENTITY_IDS to an order record, when not
+// nil, and to points entries before they are recorded
+func (r *PostgresRepository) assignUUIDs(order *entities.OrderRecord, entries []*entities.PointsEntry) (err error) {
+	if order != nil {
+		if order.UUID, err = entityUUID(r.entityIDs); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if entry.UUID, err = entityUUID(r.entityIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordOrder records an order and moves it between the hourly sales within
+// tx, a replaced record keeps its UUID
+func recordOrder(ctx context.Context, tx *sqlx.Tx, order *entities.OrderRecord) error {
+	// a replaced record keeps the time it was first created at
+	createdAt := order.CreatedAt
+	previous := entities.OrderRecord{}
+	err := txGet(ctx, tx, &previous, "SELECT id, status, total, created_at FROM order_record WHERE id=$1 FOR UPDATE", order.ID)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		createdAt = previous.CreatedAt
+		if previous.Status != entities.OrderCancelled {
+			if err := addSales(ctx, tx, createdAt, -1, -previous.Total); err != nil {
+				return err
+			}
+		}
+	}
+	if order.Status != entities.OrderCancelled {
+		if err := addSales(ctx, tx, createdAt, 1, order.Total); err != nil {
+			return err
+		}
+	}
+
+	err = txGet(ctx, tx, &order.UUID, `
+		INSERT INTO order_record (id, uuid, status, total, created_at) VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET status=EXCLUDED.status, total=EXCLUDED.total
+		RETURNING COALESCE(uuid::text, '')`,
+		order.ID, order.UUID, order.Status, order.Total, order.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := txExec(ctx, tx, "DELETE FROM order_record_item WHERE order_id=$1", order.ID); err != nil {
+		return err
+	}
+	for _, item := range order.Items {
+		_, err := txExec(ctx, tx, "INSERT INTO order_record_item (order_id, coffee_id, name, quantity, price) VALUES ($1, $2, $3, $4, $5)",
+			order.ID, item.CoffeeID, item.Name, item.Quantity, item.Price)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSales adds orders and revenue to the sales of the hour of t
+func addSales(ctx context.Context, tx *sqlx.Tx, t time.Time, orders int, revenue float64) error {
+	_, err := txExec(ctx, tx, `
+		INSERT INTO sales_hour (hour, orders, revenue) VALUES ($1, $2, $3)
+		ON CONFLICT (hour) DO UPDATE SET orders=sales_hour.orders+EXCLUDED.orders, revenue=sales_hour.revenue+EXCLUDED.revenue`,
+		t.UTC().Truncate(time.Hour), orders, revenue)
+	return err
+}
+
+// orderStatusCount is the number of order records of a status
+type orderStatusCount struct {
+	Status string `db:"status"`
+	Count  int    `db:"count"`
+}
+
+// AggregateStats computes the statistics with COUNT, GROUP BY and SUM queries
+// in one transaction
+func (r *PostgresRepository) AggregateStats(ctx context.Context, since time.Time, top int) (*entities.Stats, error) {
+	stats := &entities.Stats{OrdersByStatus: map[string]int{}, TopSellers: []entities.TopSeller{}}
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if err := txGet(ctx, tx, &stats.Coffees, "SELECT COUNT(*) FROM coffee"); err != nil {
+			return err
+		}
+
+		counts := []orderStatusCount{}
+		if err := txSelect(ctx, tx, &counts, "SELECT status, COUNT(*) AS count FROM order_record GROUP BY status"); err != nil {
+			return err
+		}
+		for _, count := range counts {
+			stats.OrdersByStatus[count.Status] = count.Count
+		}
+
+		err := txSelect(ctx, tx, &stats.TopSellers, `
+			SELECT i.coffee_id, MAX(i.name) AS name, SUM(i.quantity) AS quantity
+			FROM order_record_item i JOIN order_record o ON o.id=i.order_id
+			WHERE o.status<>$1
+			GROUP BY i.coffee_id
+			ORDER BY quantity DESC, i.coffee_id
+			LIMIT $2`,
+			entities.OrderCancelled, top)
+		if err != nil {
+			return err
+		}
+
+		return txGet(ctx, tx, &stats.RevenueToday, "SELECT COALESCE(SUM(total), 0) FROM order_record WHERE status<>$1 AND created_at>=$2", entities.OrderCancelled, since)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SalesByHour reads the hourly sales kept by RecordOrder
+func (r *PostgresRepository) SalesByHour(ctx context.Context, from, to time.Time) ([]entities.SalesBucket, error) {
+	sales := []entities.SalesBucket{}
+	err := r.selectContext(ctx, &sales, "SELECT hour, orders, revenue FROM sales_hour WHERE hour>=$1 AND hour<$2 AND orders>0 ORDER BY hour", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
+
+// availabilityRow is an availability rule as stored, with comma separated days
+type availabilityRow struct {
+	entities.AvailabilityRule
+	StoredDays string `db:"days"`
+}
+
+// FindAvailability returns the availability rules of the coffees in coffeeIDs
+func (r *PostgresRepository) FindAvailability(ctx context.Context, coffeeIDs []int) (entities.AvailabilityRules, error) {
+	ids := make([]int64, len(coffeeIDs))
+	for n, id := range coffeeIDs {
+		ids[n] = int64(id)
+	}
+
+	rows := []availabilityRow{}
+	err := r.selectContext(ctx, &rows, `
+		SELECT id, coffee_id, days, from_time, to_time, start_date, end_date FROM coffee_availability
+		WHERE coffee_id = ANY($1)
+		ORDER BY coffee_id, id`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(entities.AvailabilityRules, len(rows))
+	for n, row := range rows {
+		rules[n] = row.AvailabilityRule
+		if row.StoredDays != "" {
+			rules[n].Days = strings.Split(row.StoredDays, ",")
+		}
+	}
+	return rules, nil
+}
+
+// SetAvailability replaces the availability rules of a coffee
+func (r *PostgresRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		// locks the coffee against a concurrent delete
+		var id int
+		err := txGet(ctx, tx, &id, "SELECT id FROM coffee WHERE id=$1 FOR UPDATE", coffeeID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := txExec(ctx, tx, "DELETE FROM coffee_availability WHERE coffee_id=$1", coffeeID); err != nil {
+			return err
+		}
+		for n := range rules {
+			rules[n].CoffeeID = coffeeID
+			err := txGet(ctx, tx, &rules[n].ID, `
+				INSERT INTO coffee_availability (coffee_id, days, from_time, to_time, start_date, end_date)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id`,
+				coffeeID, strings.Join(rules[n].Days, ","), rules[n].From, rules[n].To, rules[n].Start, rules[n].End)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// slugTaken returns a check whether a slug belongs to a coffee other than
+// coffeeID, within tx
+func slugTaken(ctx context.Context, tx *sqlx.Tx, coffeeID int) func(string) (bool, error) {
+	return func(slug string) (bool, error) {
+		taken := false
+		err := txGet(ctx, tx, &taken, "SELECT EXISTS (SELECT 1 FROM coffee WHERE slug=$1 AND id<>$2)", slug, coffeeID)
+		return taken, err
+	}
+}
+
+// replaceCoffeeIngredients deletes the coffee_ingredient rows of a coffee and
+// inserts one row per ingredient, returning the inserted rows
+func replaceCoffeeIngredients(ctx context.Context, tx *sqlx.Tx, coffeeID int, ingredients []entities.CoffeeIngredients) ([]entities.CoffeeIngredients, error) {
+	if _, err := txExec(ctx, tx, "DELETE FROM coffee_ingredient WHERE coffee_id=$1", coffeeID); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]entities.CoffeeIngredients, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		row := entities.CoffeeIngredients{}
+		err := txGet(ctx, tx, &row, `
+			WITH ci AS (
+				INSERT INTO coffee_ingredient (coffee_id, ingredient_id, quantity, unit, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, now(), now())
+				RETURNING id, coffee_id, ingredient_id, quantity, unit, created_at, updated_at
+			)
+			SELECT ci.*, i.name FROM ci JOIN ingredient i ON i.id=ci.ingredient_id`,
+			coffeeID, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+		if err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, row)
+	}
+
+	return inserted, nil
+}
+
+// deleteByID runs a DELETE statement, returning ErrNotFound when no row was
+// deleted
+func deleteByID(ctx context.Context, tx *sqlx.Tx, query string, id int) error {
+	result, err := txExec(ctx, tx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, committing when it succeeds. Statements are
+// limited to the deadline of ctx, if any. A unique violation fails with
+// ErrConflict, like the in memory repository.
+func (r *PostgresRepository) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) (err error) {
+	defer func() { r.checkConnection(err) }()
+
+	db, _ := r.pool()
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := setStatementTimeout(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return uniqueViolation(err)
+	}
+
+	return uniqueViolation(tx.Commit())
+}
+
+// txGet runs a statement returning a single row in a transaction, recording
+// it in the query statistics of the context
+func txGet(ctx context.Context, tx *sqlx.Tx, dest interface{}, query string, args ...interface{}) error {
+	defer recordQuery(ctx, time.Now(), query, args...)
+	return tx.GetContext(ctx, dest, query, args...)
+}
+
+// txSelect runs a query returning rows in a transaction, recording it in the
+// query statistics of the context
+func txSelect(ctx context.Context, tx *sqlx.Tx, dest interface{}, query string, args ...interface{}) error {
+	defer recordQuery(ctx, time.Now(), query, args...)
+	return tx.SelectContext(ctx, dest, query, args...)
+}
+
+// txExec runs a statement in a transaction, recording it in the query
+// statistics of the context
+func txExec(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (sql.Result, error) {
+	defer recordQuery(ctx, time.Now(), query, args...)
+	return tx.ExecContext(ctx, query, args...)
+}
+
+// selectContext runs a query returning rows, recording it in the query
+// statistics of the context
+func (r *PostgresRepository) selectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer recordQuery(ctx, time.Now(), query, args...)
+	return r.withStatement(ctx, query, func(stmt statement) error {
+		return stmt.SelectContext(ctx, dest, args...)
+	})
+}
+
+// getContext runs a query returning a single row, recording it in the query
+// statistics of the context
+func (r *PostgresRepository) getContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer recordQuery(ctx, time.Now(), query, args...)
+	return r.withStatement(ctx, query, func(stmt statement) error {
+		return stmt.GetContext(ctx, dest, args...)
+	})
 }
@@ -0,0 +1,284 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-memdb"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// benchConnectionEnv names the environment variable holding the Postgres
+// connection string used by the Postgres benchmarks. They are skipped when it
+// is unset.
+const benchConnectionEnv = "BENCH_CONNECTION_STRING"
+
+// benchSchema isolates the benchmark tables from the products schema
+const benchSchema = "coffee_bench"
+
+// benchSizes are the catalogue sizes Find is benchmarked with
+var benchSizes = []int{10, 1000, 100000}
+
+// benchIngredientsPerCoffee is the number of coffee_ingredient rows seeded
+// for every coffee
+const benchIngredientsPerCoffee = 2
+
+func BenchmarkInMemoryFind(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("coffees=%d", size), func(b *testing.B) {
+			r := seedInMemory(b, size)
+
+			for _, pooled := range []bool{false, true} {
+				b.Run(fmt.Sprintf("pooled=%t", pooled), func(b *testing.B) {
+					benchmarkFind(b, r, pooled)
+				})
+			}
+		})
+	}
+}
+
+func BenchmarkPostgresFind(b *testing.B) {
+	connection := os.Getenv(benchConnectionEnv)
+	if connection == "" {
+		b.Skipf("%s is not set", benchConnectionEnv)
+	}
+
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("coffees=%d", size), func(b *testing.B) {
+			r := seedPostgres(b, connection, size).(*PostgresRepository)
+
+			for _, batch := range []bool{false, true} {
+				b.Run(fmt.Sprintf("batch=%t", batch), func(b *testing.B) {
+					r.batch = batch
+					benchmarkFind(b, r, true)
+				})
+			}
+		})
+	}
+}
+
+func BenchmarkPostgresImport(b *testing.B) {
+	connection := os.Getenv(benchConnectionEnv)
+	if connection == "" {
+		b.Skipf("%s is not set", benchConnectionEnv)
+	}
+
+	for _, useCopy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("copy=%t", useCopy), func(b *testing.B) {
+			r := seedPostgres(b, connection, 0).(*PostgresRepository)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				coffees := benchImportCoffees()
+				if useCopy {
+					if err := r.ImportCoffees(ctx, coffees); err != nil {
+						b.Fatal(err)
+					}
+					continue
+				}
+				for n := range coffees {
+					if err := r.CreateCoffee(ctx, &coffees[n]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// benchImportCoffees returns the coffees imported by every iteration of
+// BenchmarkPostgresImport
+func benchImportCoffees() entities.Coffees {
+	coffees := make(entities.Coffees, 0, 100)
+	for n := 1; n <= cap(coffees); n++ {
+		coffee := entities.Coffee{Name: fmt.Sprintf("Imported %d", n), Teaser: "Benchmark coffee", Price: 200, Image: "/bench.png"}
+		for i := 1; i <= benchIngredientsPerCoffee; i++ {
+			coffee.Ingredients = append(coffee.Ingredients, entities.CoffeeIngredients{IngredientID: i, Quantity: 40, Unit: "ml"})
+		}
+		coffees = append(coffees, coffee)
+	}
+	return coffees
+}
+
+func BenchmarkPostgresFindByID(b *testing.B) {
+	connection := os.Getenv(benchConnectionEnv)
+	if connection == "" {
+		b.Skipf("%s is not set", benchConnectionEnv)
+	}
+
+	for _, prepared := range []bool{false, true} {
+		b.Run(fmt.Sprintf("prepared=%t", prepared), func(b *testing.B) {
+			r := seedPostgres(b, connection, 1000).(*PostgresRepository)
+			if prepared {
+				r.statements = newStatementCache(r.db, r.metrics)
+				b.Cleanup(r.statements.close)
+			}
+
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.FindByID(ctx, i%1000+1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkFind(b *testing.B, r Repository, pooled bool) {
+	ctx := context.Background()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		coffees, err := r.Find(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if pooled {
+			entities.PutCoffees(coffees)
+		}
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+}
+
+// seedInMemory creates an InMemoryRepository holding size coffees
+func seedInMemory(b *testing.B, size int) Repository {
+	db, err := memdb.NewMemDB(createSchema())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	timestamp := time.Now().String()
+	txn := db.Txn(true)
+	for id := 1; id <= size; id++ {
+		err := txn.Insert(Coffee.String(), &entities.Coffee{
+			ID:        id,
+			Name:      fmt.Sprintf("Coffee %d", id),
+			Teaser:    "Benchmark coffee",
+			Price:     float64(100 + id%300),
+			Image:     "/bench.png",
+			CreatedAt: timestamp,
+			UpdatedAt: timestamp,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for n := 0; n < benchIngredientsPerCoffee; n++ {
+			err := txn.Insert(CoffeeIngredient.String(), &entities.CoffeeIngredients{
+				ID:           (id-1)*benchIngredientsPerCoffee + n + 1,
+				CoffeeID:     id,
+				IngredientID: n + 1,
+				CreatedAt:    timestamp,
+				UpdatedAt:    timestamp,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	txn.Commit()
+
+	return &InMemoryRepository{db: db, config: &config.Config{Logger: hclog.NewNullLogger()}}
+}
+
+// seedPostgres creates the benchmark schema holding size coffees and returns
+// a PostgresRepository reading from it. The schema is dropped when the
+// benchmark finishes.
+func seedPostgres(b *testing.B, connection string, size int) Repository {
+	admin, err := sqlx.Connect(driverName, connection)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer admin.Close()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{query: "DROP SCHEMA IF EXISTS " + benchSchema + " CASCADE"},
+		{query: "CREATE SCHEMA " + benchSchema},
+		{query: `CREATE TABLE ` + benchSchema + `.coffee (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) NOT NULL UNIQUE,
+			teaser VARCHAR(255) NOT NULL,
+			description VARCHAR(255) NOT NULL,
+			price NUMERIC NOT NULL,
+			image VARCHAR(255) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'published',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			deleted_at TIMESTAMP
+		)`},
+		{query: `CREATE TABLE ` + benchSchema + `.coffee_ingredient (
+			id SERIAL PRIMARY KEY,
+			coffee_id INT NOT NULL,
+			ingredient_id INT NOT NULL,
+			quantity INT NOT NULL DEFAULT 0,
+			unit VARCHAR(50) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			deleted_at TIMESTAMP
+		)`},
+		{query: `INSERT INTO ` + benchSchema + `.coffee (name, slug, teaser, description, price, image, created_at, updated_at)
+			SELECT 'Coffee ' || n, 'coffee-' || n, 'Benchmark coffee', '', 100 + n % 300, '/bench.png', now(), now()
+			FROM generate_series(1, $1) AS n`, args: []interface{}{size}},
+		{query: `CREATE TABLE ` + benchSchema + `.ingredient (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL
+		)`},
+		{query: `INSERT INTO ` + benchSchema + `.ingredient (name)
+			SELECT 'Ingredient ' || i FROM generate_series(1, $1) AS i`, args: []interface{}{benchIngredientsPerCoffee}},
+		{query: `INSERT INTO ` + benchSchema + `.coffee_ingredient (coffee_id, ingredient_id, created_at, updated_at)
+			SELECT c.id, i, now(), now()
+			FROM ` + benchSchema + `.coffee c, generate_series(1, $1) AS i`, args: []interface{}{benchIngredientsPerCoffee}},
+		{query: "CREATE INDEX ON " + benchSchema + ".coffee_ingredient (coffee_id)"},
+		{query: "ANALYZE " + benchSchema + ".coffee"},
+		{query: "ANALYZE " + benchSchema + ".coffee_ingredient"},
+	}
+	for _, statement := range statements {
+		if _, err := admin.Exec(statement.query, statement.args...); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Cleanup(func() {
+		admin, err := sqlx.Connect(driverName, connection)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		defer admin.Close()
+
+		if _, err := admin.Exec("DROP SCHEMA " + benchSchema + " CASCADE"); err != nil {
+			b.Error(err)
+		}
+	})
+
+	// pgx passes unknown connection parameters on as run-time settings
+	r, err := newPostgres(connection + " search_path=" + benchSchema)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { r.db.Close() })
+
+	return r
+}
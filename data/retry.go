@@ -0,0 +1,536 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jackc/pgconn"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// maxRetryBudget is the number of retries a budget starts with and can save
+// up, so quiet periods still allow a few retries
+const maxRetryBudget = 10
+
+// RetryPolicy configures the retries of a class of repository methods
+type RetryPolicy struct {
+	// Retries is the most extra attempts of a call after retryable errors, 0
+	// disables retries
+	Retries int
+	// Backoff is the delay before the first retry, doubled before every
+	// further retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Budget is the percentage of calls which may be retried, retries are
+	// skipped once it is spent
+	Budget float64
+}
+
+// RetryingOptions configure a RetryingRepository
+type RetryingOptions struct {
+	// Reads is the policy of the Find methods and the statistics
+	Reads RetryPolicy
+	// Writes is the policy of every other method. A write whose connection
+	// was lost after its commit is applied twice when it is retried.
+	Writes  RetryPolicy
+	Logger  hclog.Logger
+	Metrics metrics.Sink
+}
+
+// RetryingRepository is a Repository retrying the calls of the repository it
+// wraps which fail with a Retryable error. Retries back off exponentially and
+// are skipped once the budget of their class is spent, when the context of
+// the call is done or its deadline would pass during the backoff.
+// repository.retries counts the retries by method.
+//
+// The optional capabilities of the wrapped repository, e.g. stores or
+// coupons, are forwarded and retried as well. IsConnected is not retried, so
+// readiness reflects the state of the connections.
+type RetryingRepository struct {
+	Repository
+	options RetryingOptions
+	reads   *retryClass
+	writes  *retryClass
+}
+
+// retryClass is the policy and budget of a class of methods
+type retryClass struct {
+	name   string
+	policy RetryPolicy
+	budget *retryBudget
+}
+
+// NewRetrying wraps repository to retry its calls failing with a Retryable
+// error
+func NewRetrying(repository Repository, options RetryingOptions) *RetryingRepository {
+	if options.Logger == nil {
+		options.Logger = hclog.NewNullLogger()
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+
+	return &RetryingRepository{
+		Repository: repository,
+		options:    options,
+		reads:      &retryClass{name: "read", policy: options.Reads, budget: newRetryBudget(options.Reads.Budget)},
+		writes:     &retryClass{name: "write", policy: options.Writes, budget: newRetryBudget(options.Writes.Budget)},
+	}
+}
+
+// Unwrap returns the wrapped repository
+func (r *RetryingRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Retryable reports whether a repository call failing with err may succeed
+// when it is made again: Postgres serialization failures and deadlocks, and
+// lost connections, e.g. connection resets or a failover
+func Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01") {
+		// serialization_failure and deadlock_detected
+		return true
+	}
+	return connectionLost(err)
+}
+
+// Find returns all coffees of the wrapped repository
+func (r *RetryingRepository) Find(ctx context.Context) (coffees entities.Coffees, err error) {
+	err = r.retry(ctx, "Find", r.reads, func() (err error) {
+		coffees, err = r.Repository.Find(ctx)
+		return err
+	})
+	return coffees, err
+}
+
+// FindByID returns a single coffee of the wrapped repository
+func (r *RetryingRepository) FindByID(ctx context.Context, coffeeID int) (coffee *entities.Coffee, err error) {
+	err = r.retry(ctx, "FindByID", r.reads, func() (err error) {
+		coffee, err = r.Repository.FindByID(ctx, coffeeID)
+		return err
+	})
+	return coffee, err
+}
+
+// FindBySlug returns the coffee with the slug of the wrapped repository
+func (r *RetryingRepository) FindBySlug(ctx context.Context, slug string) (coffee *entities.Coffee, err error) {
+	err = r.retry(ctx, "FindBySlug", r.reads, func() (err error) {
+		coffee, err = FindBySlug(ctx, r.Repository, slug)
+		return err
+	})
+	return coffee, err
+}
+
+// FindRelated returns the related coffees of the wrapped repository
+func (r *RetryingRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (coffees entities.Coffees, err error) {
+	err = r.retry(ctx, "FindRelated", r.reads, func() (err error) {
+		coffees, err = r.Repository.FindRelated(ctx, coffeeID, limit)
+		return err
+	})
+	return coffees, err
+}
+
+// FindWhere returns the coffees of the wrapped repository matching expr
+func (r *RetryingRepository) FindWhere(ctx context.Context, expr filter.Expr) (coffees entities.Coffees, err error) {
+	err = r.retry(ctx, "FindWhere", r.reads, func() (err error) {
+		coffees, err = r.Repository.FindWhere(ctx, expr)
+		return err
+	})
+	return coffees, err
+}
+
+// FindSimilar returns the coffees of the wrapped repository named like name
+func (r *RetryingRepository) FindSimilar(ctx context.Context, name string, threshold float64) (coffees entities.Coffees, err error) {
+	err = r.retry(ctx, "FindSimilar", r.reads, func() (err error) {
+		coffees, err = FindSimilar(ctx, r.Repository, name, threshold)
+		return err
+	})
+	return coffees, err
+}
+
+// CreateCoffee inserts the coffee in the wrapped repository
+func (r *RetryingRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	return r.retry(ctx, "CreateCoffee", r.writes, func() error {
+		return r.Repository.CreateCoffee(ctx, coffee)
+	})
+}
+
+// UpdateCoffee replaces the coffee in the wrapped repository
+func (r *RetryingRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	return r.retry(ctx, "UpdateCoffee", r.writes, func() error {
+		return r.Repository.UpdateCoffee(ctx, coffee)
+	})
+}
+
+// DeleteCoffee removes the coffee from the wrapped repository
+func (r *RetryingRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	return r.retry(ctx, "DeleteCoffee", r.writes, func() error {
+		return r.Repository.DeleteCoffee(ctx, coffeeID)
+	})
+}
+
+// DeleteWhere removes the coffees matching expr from the wrapped repository
+func (r *RetryingRepository) DeleteWhere(ctx context.Context, expr filter.Expr, dryRun bool) (ids []int, err error) {
+	err = r.retry(ctx, "DeleteWhere", r.writes, func() (err error) {
+		ids, err = DeleteWhere(ctx, r.Repository, expr, dryRun)
+		return err
+	})
+	return ids, err
+}
+
+// FindIngredients returns the ingredients of the wrapped repository
+func (r *RetryingRepository) FindIngredients(ctx context.Context) (ingredients entities.Ingredients, err error) {
+	err = r.retry(ctx, "FindIngredients", r.reads, func() (err error) {
+		ingredients, err = r.Repository.FindIngredients(ctx)
+		return err
+	})
+	return ingredients, err
+}
+
+// CreateIngredient inserts the ingredient in the wrapped repository
+func (r *RetryingRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	return r.retry(ctx, "CreateIngredient", r.writes, func() error {
+		return r.Repository.CreateIngredient(ctx, ingredient)
+	})
+}
+
+// UpdateIngredient replaces the ingredient in the wrapped repository
+func (r *RetryingRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	return r.retry(ctx, "UpdateIngredient", r.writes, func() error {
+		return r.Repository.UpdateIngredient(ctx, ingredient)
+	})
+}
+
+// DeleteIngredient removes the ingredient from the wrapped repository
+func (r *RetryingRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	return r.retry(ctx, "DeleteIngredient", r.writes, func() error {
+		return r.Repository.DeleteIngredient(ctx, ingredientID)
+	})
+}
+
+// FindTranslations returns the translations of the wrapped repository
+func (r *RetryingRepository) FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (translations entities.Translations, err error) {
+	err = r.retry(ctx, "FindTranslations", r.reads, func() (err error) {
+		translations, err = FindTranslations(ctx, r.Repository, coffeeIDs, locales)
+		return err
+	})
+	return translations, err
+}
+
+// SetTranslation stores the translation in the wrapped repository
+func (r *RetryingRepository) SetTranslation(ctx context.Context, translation *entities.Translation) error {
+	return r.retry(ctx, "SetTranslation", r.writes, func() error {
+		return SetTranslation(ctx, r.Repository, translation)
+	})
+}
+
+// DeleteTranslation removes the translation from the wrapped repository
+func (r *RetryingRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error {
+	return r.retry(ctx, "DeleteTranslation", r.writes, func() error {
+		return DeleteTranslation(ctx, r.Repository, coffeeID, locale, field)
+	})
+}
+
+// FindImages returns the images of the wrapped repository
+func (r *RetryingRepository) FindImages(ctx context.Context, coffeeIDs []int) (images entities.CoffeeImages, err error) {
+	err = r.retry(ctx, "FindImages", r.reads, func() (err error) {
+		images, err = FindImages(ctx, r.Repository, coffeeIDs)
+		return err
+	})
+	return images, err
+}
+
+// SetImage stores the image in the wrapped repository
+func (r *RetryingRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	return r.retry(ctx, "SetImage", r.writes, func() error {
+		return SetImage(ctx, r.Repository, image)
+	})
+}
+
+// FindStores returns the stores of the wrapped repository
+func (r *RetryingRepository) FindStores(ctx context.Context) (stores entities.Stores, err error) {
+	err = r.retry(ctx, "FindStores", r.reads, func() (err error) {
+		stores, err = FindStores(ctx, r.Repository)
+		return err
+	})
+	return stores, err
+}
+
+// FindStore returns a store of the wrapped repository
+func (r *RetryingRepository) FindStore(ctx context.Context, storeID int) (store *entities.Store, err error) {
+	err = r.retry(ctx, "FindStore", r.reads, func() (err error) {
+		store, err = FindStore(ctx, r.Repository, storeID)
+		return err
+	})
+	return store, err
+}
+
+// FindStoreCoffees returns the coffees of a store of the wrapped repository
+func (r *RetryingRepository) FindStoreCoffees(ctx context.Context, storeID int) (ids []int, err error) {
+	err = r.retry(ctx, "FindStoreCoffees", r.reads, func() (err error) {
+		ids, err = FindStoreCoffees(ctx, r.Repository, storeID)
+		return err
+	})
+	return ids, err
+}
+
+// FindStoresNear returns the stores of the wrapped repository near a point
+func (r *RetryingRepository) FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (stores entities.Stores, err error) {
+	err = r.retry(ctx, "FindStoresNear", r.reads, func() (err error) {
+		stores, err = FindStoresNear(ctx, r.Repository, lat, lon, radiusKm)
+		return err
+	})
+	return stores, err
+}
+
+// FindSuppliers returns the suppliers of the wrapped repository
+func (r *RetryingRepository) FindSuppliers(ctx context.Context) (suppliers entities.Suppliers, err error) {
+	err = r.retry(ctx, "FindSuppliers", r.reads, func() (err error) {
+		suppliers, err = FindSuppliers(ctx, r.Repository, "")
+		return err
+	})
+	return suppliers, err
+}
+
+// FindIngredientSupplier returns the supplier of an ingredient of the wrapped repository
+func (r *RetryingRepository) FindIngredientSupplier(ctx context.Context, ingredientID int) (supplier *entities.Supplier, err error) {
+	err = r.retry(ctx, "FindIngredientSupplier", r.reads, func() (err error) {
+		supplier, err = FindIngredientSupplier(ctx, r.Repository, ingredientID)
+		return err
+	})
+	return supplier, err
+}
+
+// FindAvailability returns the availability rules of the wrapped repository
+func (r *RetryingRepository) FindAvailability(ctx context.Context, coffeeIDs []int) (rules entities.AvailabilityRules, err error) {
+	err = r.retry(ctx, "FindAvailability", r.reads, func() (err error) {
+		rules, err = FindAvailability(ctx, r.Repository, coffeeIDs)
+		return err
+	})
+	return rules, err
+}
+
+// SetAvailability stores the availability rules in the wrapped repository
+func (r *RetryingRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error {
+	return r.retry(ctx, "SetAvailability", r.writes, func() error {
+		return SetAvailability(ctx, r.Repository, coffeeID, rules)
+	})
+}
+
+// FindCoupons returns the coupons of the wrapped repository
+func (r *RetryingRepository) FindCoupons(ctx context.Context) (coupons entities.Coupons, err error) {
+	err = r.retry(ctx, "FindCoupons", r.reads, func() (err error) {
+		coupons, err = FindCoupons(ctx, r.Repository)
+		return err
+	})
+	return coupons, err
+}
+
+// FindCoupon returns a coupon of the wrapped repository
+func (r *RetryingRepository) FindCoupon(ctx context.Context, code string) (coupon *entities.Coupon, err error) {
+	err = r.retry(ctx, "FindCoupon", r.reads, func() (err error) {
+		coupon, err = FindCoupon(ctx, r.Repository, code)
+		return err
+	})
+	return coupon, err
+}
+
+// CreateCoupon inserts the coupon in the wrapped repository
+func (r *RetryingRepository) CreateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	return r.retry(ctx, "CreateCoupon", r.writes, func() error {
+		return CreateCoupon(ctx, r.Repository, coupon)
+	})
+}
+
+// UpdateCoupon replaces the coupon in the wrapped repository
+func (r *RetryingRepository) UpdateCoupon(ctx context.Context, coupon *entities.Coupon) error {
+	return r.retry(ctx, "UpdateCoupon", r.writes, func() error {
+		return UpdateCoupon(ctx, r.Repository, coupon)
+	})
+}
+
+// DeleteCoupon removes the coupon from the wrapped repository
+func (r *RetryingRepository) DeleteCoupon(ctx context.Context, code string) error {
+	return r.retry(ctx, "DeleteCoupon", r.writes, func() error {
+		return DeleteCoupon(ctx, r.Repository, code)
+	})
+}
+
+// RedeemCoupon counts a use of a coupon of the wrapped repository
+func (r *RetryingRepository) RedeemCoupon(ctx context.Context, code string, t time.Time) (coupon *entities.Coupon, err error) {
+	err = r.retry(ctx, "RedeemCoupon", r.writes, func() (err error) {
+		coupon, err = RedeemCoupon(ctx, r.Repository, code, t)
+		return err
+	})
+	return coupon, err
+}
+
+// ReleaseCoupon gives back a use of a coupon of the wrapped repository
+func (r *RetryingRepository) ReleaseCoupon(ctx context.Context, code string) error {
+	return r.retry(ctx, "ReleaseCoupon", r.writes, func() error {
+		return ReleaseCoupon(ctx, r.Repository, code)
+	})
+}
+
+// FindPoints returns the loyalty points of a user of the wrapped repository
+func (r *RetryingRepository) FindPoints(ctx context.Context, userID int) (points *entities.Points, err error) {
+	err = r.retry(ctx, "FindPoints", r.reads, func() (err error) {
+		points, err = FindPoints(ctx, r.Repository, userID)
+		return err
+	})
+	return points, err
+}
+
+// RecordPoints records the points entry in the wrapped repository
+func (r *RetryingRepository) RecordPoints(ctx context.Context, entry *entities.PointsEntry) (balance int, err error) {
+	err = r.retry(ctx, "RecordPoints", r.writes, func() (err error) {
+		balance, err = RecordPoints(ctx, r.Repository, entry)
+		return err
+	})
+	return balance, err
+}
+
+// FindUser returns the profile of a user of the wrapped repository
+func (r *RetryingRepository) FindUser(ctx context.Context, subject string) (user *entities.User, err error) {
+	err = r.retry(ctx, "FindUser", r.reads, func() (err error) {
+		user, err = FindUser(ctx, r.Repository, subject)
+		return err
+	})
+	return user, err
+}
+
+// SaveUser saves the profile of a user in the wrapped repository
+func (r *RetryingRepository) SaveUser(ctx context.Context, user *entities.User) error {
+	return r.retry(ctx, "SaveUser", r.writes, func() error {
+		return SaveUser(ctx, r.Repository, user)
+	})
+}
+
+// RecordOrder records the order in the wrapped repository
+func (r *RetryingRepository) RecordOrder(ctx context.Context, order *entities.OrderRecord) error {
+	return r.retry(ctx, "RecordOrder", r.writes, func() error {
+		return RecordOrder(ctx, r.Repository, order)
+	})
+}
+
+// RecordOrderPoints records the order and its points entries in the wrapped
+// repository
+func (r *RetryingRepository) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	return r.retry(ctx, "RecordOrderPoints", r.writes, func() error {
+		return RecordOrderPoints(ctx, r.Repository, order, entries)
+	})
+}
+
+// AggregateStats returns the statistics of the wrapped repository
+func (r *RetryingRepository) AggregateStats(ctx context.Context, since time.Time, top int) (stats *entities.Stats, err error) {
+	err = r.retry(ctx, "AggregateStats", r.reads, func() (err error) {
+		stats, err = AggregateStats(ctx, r.Repository, since, top)
+		return err
+	})
+	return stats, err
+}
+
+// SalesByHour returns the hourly sales of the wrapped repository
+func (r *RetryingRepository) SalesByHour(ctx context.Context, from, to time.Time) (buckets []entities.SalesBucket, err error) {
+	err = r.retry(ctx, "SalesByHour", r.reads, func() (err error) {
+		buckets, err = salesByHour(ctx, r.Repository, from, to)
+		return err
+	})
+	return buckets, err
+}
+
+// ImportCoffees imports the coffees in the wrapped repository, at once when it
+// is an Importer, retrying failed writes
+func (r *RetryingRepository) ImportCoffees(ctx context.Context, coffees entities.Coffees) error {
+	if importer, ok := r.Repository.(Importer); ok {
+		return r.retry(ctx, "ImportCoffees", r.writes, func() error {
+			return importer.ImportCoffees(ctx, coffees)
+		})
+	}
+	for n := range coffees {
+		if err := r.CreateCoffee(ctx, &coffees[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry makes a call of method until it succeeds, fails with an error which
+// is not Retryable or the policy of class allows no further retry
+func (r *RetryingRepository) retry(ctx context.Context, method string, class *retryClass, call func() error) error {
+	class.budget.deposit()
+	backoff := class.policy.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt > class.policy.Retries || ctx.Err() != nil || !Retryable(err) {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+		if !class.budget.withdraw() {
+			r.options.Metrics.IncrCounter("repository.budget.exhausted", 1, metrics.Label{Name: "class", Value: class.name})
+			return err
+		}
+
+		r.options.Metrics.IncrCounter("repository.retries", 1, metrics.Label{Name: "method", Value: method})
+		r.options.Logger.Debug("Retrying repository call", "method", method, "attempt", attempt, "retry_in", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		if backoff *= 2; class.policy.MaxBackoff > 0 && backoff > class.policy.MaxBackoff {
+			backoff = class.policy.MaxBackoff
+		}
+	}
+}
+
+// retryBudget limits retries to a percentage of the calls, so a struggling
+// database is not hit with a multiple of its usual load. Every call deposits
+// a fraction of a token and every retry withdraws a whole one.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+// newRetryBudget creates a budget allowing percent retries per call
+func newRetryBudget(percent float64) *retryBudget {
+	return &retryBudget{ratio: percent / 100, tokens: maxRetryBudget}
+}
+
+// deposit credits the budget for a call
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > maxRetryBudget {
+		b.tokens = maxRetryBudget
+	}
+}
+
+// withdraw takes a token for a retry, it returns false when the budget is
+// spent
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
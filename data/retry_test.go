@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// flakyRepository fails the first failures calls of Find and CreateCoffee
+// with err
+type flakyRepository struct {
+	Repository
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *flakyRepository) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.Repository.Find(ctx)
+}
+
+func (f *flakyRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Repository.CreateCoffee(ctx, coffee)
+}
+
+func setupRetrying(t *testing.T, err error, failures int, reads, writes RetryPolicy) (*RetryingRepository, *flakyRepository) {
+	flaky := &flakyRepository{Repository: setupInMemoryRepository(t), err: err, failures: failures}
+	return NewRetrying(flaky, RetryingOptions{Reads: reads, Writes: writes}), flaky
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, Retryable(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, Retryable(&pgconn.PgError{Code: "08006"}))
+	assert.True(t, Retryable(syscall.ECONNRESET))
+	assert.True(t, Retryable(io.ErrUnexpectedEOF))
+
+	assert.False(t, Retryable(nil))
+	assert.False(t, Retryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, Retryable(ErrNotFound))
+	assert.False(t, Retryable(context.DeadlineExceeded))
+}
+
+func TestRetryingRepositoryRetriesSerializationFailures(t *testing.T) {
+	r, flaky := setupRetrying(t, &pgconn.PgError{Code: "40001"}, 2, RetryPolicy{Retries: 2, Backoff: time.Millisecond, Budget: 10}, RetryPolicy{})
+
+	coffees, err := r.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+	assert.Equal(t, 3, flaky.calls)
+}
+
+func TestRetryingRepositoryStopsAfterTheRetriesOfTheClass(t *testing.T) {
+	serialization := &pgconn.PgError{Code: "40001"}
+	r, flaky := setupRetrying(t, serialization, 5, RetryPolicy{Retries: 2, Backoff: time.Millisecond, Budget: 10}, RetryPolicy{})
+
+	_, err := r.Find(context.Background())
+	assert.Equal(t, serialization, err)
+	assert.Equal(t, 3, flaky.calls)
+
+	// writes are not retried without retries of their own
+	flaky.calls = 0
+	err = r.CreateCoffee(context.Background(), &entities.Coffee{Name: "Retried", Price: 200})
+	assert.Equal(t, serialization, err)
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestRetryingRepositoryDoesNotRetryOtherErrors(t *testing.T) {
+	unique := &pgconn.PgError{Code: "23505"}
+	r, flaky := setupRetrying(t, unique, 1, RetryPolicy{}, RetryPolicy{Retries: 3, Backoff: time.Millisecond, Budget: 10})
+
+	err := r.CreateCoffee(context.Background(), &entities.Coffee{Name: "Duplicate", Price: 200})
+	assert.Equal(t, unique, err)
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestRetryingRepositorySkipsRetriesOnceTheBudgetIsSpent(t *testing.T) {
+	r, flaky := setupRetrying(t, syscall.ECONNRESET, 1000, RetryPolicy{Retries: 1, Budget: 0}, RetryPolicy{})
+
+	for n := 0; n < maxRetryBudget+5; n++ {
+		_, err := r.Find(context.Background())
+		assert.Error(t, err)
+	}
+	// every call made one attempt plus a retry while the budget lasted
+	assert.Equal(t, 2*maxRetryBudget+5, flaky.calls)
+}
+
+func TestRetryingRepositoryRespectsTheDeadline(t *testing.T) {
+	r, flaky := setupRetrying(t, syscall.ECONNRESET, 5, RetryPolicy{Retries: 5, Backoff: time.Second, Budget: 10}, RetryPolicy{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.Find(ctx)
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.Equal(t, 1, flaky.calls)
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+}
@@ -0,0 +1,998 @@
+# The entities of the catalogue, the single definition of their structs in
+# data/entities, their JSON encoders, the in memory tables of
+# InMemoryRepository and the columns of their SQL tables. Run go generate
+# ./data after changing it. Existing migrations are never regenerated, see
+# README.md for starting a migration from a new table.
+#
+# entity "Name" {
+#   doc         = "doc comment of the struct"
+#   collection  = "slice type of the entity, if any"
+#   table       = "SQL table, also the in memory table with memdb"
+#   memdb       = "TableNameKey constant of the in memory table, if any"
+#   append_json = "generate a reflection free AppendJSON"
+#   json_size   = "typical size of the encoded entity"
+#   xml         = "element of the entity in XML responses, if any"
+#   xml_collection = "element of the collection in XML responses, if any"
+#
+#   field "Name" { type, column (db tag), json (json tag), xml (xml tag,
+#                  the json tag by default), sql, doc }
+#   column "name" { sql }   # SQL column without a field
+#   index "name" { fields, unique, allow_missing, trigram, uuid, doc }
+#   constraints = ["table constraints of CREATE TABLE"]
+#   sql_after   = ["statements run after CREATE TABLE"]
+# }
+
+entity "Coffee" {
+  doc         = "Coffee defines a coffee in the database"
+  collection  = "Coffees"
+  table       = "coffee"
+  memdb       = "Coffee"
+  append_json = true
+  json_size   = 384
+  xml         = "coffee"
+
+  xml_collection = "coffees"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Slug" {
+    type   = "string"
+    column = "slug"
+    json   = "slug"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Teaser" {
+    type   = "string"
+    column = "teaser"
+    json   = "teaser"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Description" {
+    type   = "string"
+    column = "description"
+    json   = "description"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Price" {
+    type   = "float64"
+    column = "price"
+    json   = "price"
+    sql    = "NUMERIC NOT NULL"
+  }
+  field "Image" {
+    type   = "string"
+    column = "image"
+    json   = "image"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Status" {
+    type   = "string"
+    column = "status"
+    json   = "status"
+    sql    = "VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'retired'))"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "DeletedAt" {
+    type   = "sql.NullString"
+    column = "deleted_at"
+    json   = "-"
+    sql    = "TIMESTAMP"
+  }
+  field "Ingredients" {
+    type = "[]CoffeeIngredients"
+    json = "ingredients"
+    xml  = "ingredients>ingredient"
+  }
+  field "Stats" {
+    type   = "*CoffeeStats"
+    column = "-"
+    json   = "stats,omitempty"
+  }
+  field "Images" {
+    type   = "*CoffeeImageSet"
+    column = "-"
+    json   = "images,omitempty"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+  index "name" {
+    fields        = ["Name"]
+    allow_missing = true
+  }
+  index "slug" {
+    fields        = ["Slug"]
+    unique        = true
+    allow_missing = true
+  }
+  index "status" {
+    fields        = ["Status"]
+    allow_missing = true
+  }
+  index "name_trigram" {
+    fields        = ["Name"]
+    allow_missing = true
+    trigram       = true
+  }
+
+  sql_after = [
+    "CREATE INDEX IF NOT EXISTS coffee_name_trigrams ON coffee USING gin (name gin_trgm_ops)",
+    "CREATE UNIQUE INDEX IF NOT EXISTS coffee_slug ON coffee (slug)",
+    "CREATE INDEX IF NOT EXISTS coffee_status ON coffee (status)",
+  ]
+}
+
+entity "CoffeeStats" {
+  doc         = "CoffeeStats are the popularity counters of a coffee"
+  append_json = true
+
+  field "Views" {
+    type = "int64"
+    json = "views"
+  }
+  field "Orders" {
+    type = "int64"
+    json = "orders"
+  }
+  field "Score" {
+    type = "float64"
+    json = "score"
+  }
+}
+
+entity "CoffeeIngredients" {
+  doc = <<EOT
+CoffeeIngredients is a coffee_ingredient row, the quantity of an ingredient
+in a coffee. Name is hydrated from the ingredient table by the repositories,
+it is not stored on the row.
+EOT
+  table       = "coffee_ingredient"
+  memdb       = "CoffeeIngredient"
+  append_json = true
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "-"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "-"
+    sql    = "INT REFERENCES coffee(id)"
+  }
+  field "IngredientID" {
+    type   = "int"
+    column = "ingredient_id"
+    json   = "ingredient_id"
+    sql    = "INT REFERENCES ingredient(id)"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+  }
+  field "Quantity" {
+    type   = "int"
+    column = "quantity"
+    json   = "quantity"
+    sql    = "INT NOT NULL DEFAULT 0"
+  }
+  field "Unit" {
+    type   = "string"
+    column = "unit"
+    json   = "unit"
+    sql    = "VARCHAR(50) NOT NULL DEFAULT ''"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "DeletedAt" {
+    type   = "sql.NullString"
+    column = "deleted_at"
+    json   = "-"
+    sql    = "TIMESTAMP"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+  index "coffee_id" {
+    fields = ["CoffeeID"]
+  }
+  index "ingredient_id" {
+    fields = ["IngredientID"]
+  }
+}
+
+entity "Ingredient" {
+  doc        = "Ingredient defines an ingredient in the database"
+  collection = "Ingredients"
+  table      = "ingredient"
+  memdb      = "Ingredient"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Quantity" {
+    type   = "int"
+    column = "quantity"
+    json   = "quantity"
+    sql    = "INT NOT NULL DEFAULT 0"
+  }
+  field "Unit" {
+    type   = "string"
+    column = "unit"
+    json   = "unit"
+    sql    = "VARCHAR(50) NOT NULL DEFAULT ''"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "DeletedAt" {
+    type   = "sql.NullString"
+    column = "deleted_at"
+    json   = "-"
+    sql    = "TIMESTAMP"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+}
+
+entity "Translation" {
+  doc = <<EOT
+Translation is the value of a coffee field in a locale, keyed by the coffee,
+the locale and the field
+EOT
+  collection = "Translations"
+  table      = "coffee_translation"
+  memdb      = "CoffeeTranslation"
+
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "coffee_id"
+    sql    = "INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE"
+  }
+  field "Locale" {
+    type   = "string"
+    column = "locale"
+    json   = "locale"
+    sql    = "VARCHAR(35) NOT NULL"
+  }
+  field "Field" {
+    type   = "string"
+    column = "field"
+    json   = "field"
+    sql    = "VARCHAR(50) NOT NULL"
+  }
+  field "Value" {
+    type   = "string"
+    column = "value"
+    json   = "value"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "updated_at"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  index "id" {
+    fields = ["CoffeeID", "Locale", "Field"]
+    unique = true
+  }
+  index "coffee_id" {
+    fields = ["CoffeeID"]
+  }
+
+  constraints = ["PRIMARY KEY (coffee_id, locale, field)"]
+}
+
+entity "CoffeeImage" {
+  doc = <<EOT
+CoffeeImage is a size of the image of a coffee, the uploaded image or one of
+the variants resized from it, keyed by the coffee and the size
+EOT
+  collection = "CoffeeImages"
+  table      = "coffee_image"
+  memdb      = "CoffeeImage"
+
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "coffee_id"
+    sql    = "INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE"
+  }
+  field "Size" {
+    type   = "string"
+    column = "size"
+    json   = "size"
+    sql    = "VARCHAR(20) NOT NULL"
+  }
+  field "URL" {
+    type   = "string"
+    column = "url"
+    json   = "url"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Width" {
+    type   = "int"
+    column = "width"
+    json   = "width"
+    sql    = "INT NOT NULL"
+  }
+  field "Height" {
+    type   = "int"
+    column = "height"
+    json   = "height"
+    sql    = "INT NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "updated_at"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  index "id" {
+    fields = ["CoffeeID", "Size"]
+    unique = true
+  }
+  index "coffee_id" {
+    fields = ["CoffeeID"]
+  }
+
+  constraints = ["PRIMARY KEY (coffee_id, size)"]
+}
+
+entity "CoffeeImageSet" {
+  doc = <<EOT
+CoffeeImageSet are the URLs of the sizes of the image of a coffee, and a
+srcset attribute listing them with their widths for responsive pages
+EOT
+  append_json = true
+
+  field "Srcset" {
+    type = "string"
+    json = "srcset"
+  }
+  field "Thumb" {
+    type = "string"
+    json = "thumb,omitempty"
+  }
+  field "Medium" {
+    type = "string"
+    json = "medium,omitempty"
+  }
+  field "Full" {
+    type = "string"
+    json = "full,omitempty"
+  }
+}
+
+entity "AvailabilityRule" {
+  doc = <<EOT
+AvailabilityRule is a window in which a coffee is on the menu. Each condition
+which is set has to hold: the weekday is one of Days, the time of day is
+from From until To and the date, every year, from Start until End.
+EOT
+  collection = "AvailabilityRules"
+  table      = "coffee_availability"
+  memdb      = "CoffeeAvailability"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "-"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "-"
+    sql    = "INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE"
+  }
+  field "Days" {
+    type   = "[]string"
+    column = "-"
+    json   = "days,omitempty"
+  }
+  field "From" {
+    type   = "string"
+    column = "from_time"
+    json   = "from,omitempty"
+    sql    = "VARCHAR(5) NOT NULL DEFAULT ''"
+  }
+  field "To" {
+    type   = "string"
+    column = "to_time"
+    json   = "to,omitempty"
+    sql    = "VARCHAR(5) NOT NULL DEFAULT ''"
+  }
+  field "Start" {
+    type   = "string"
+    column = "start_date"
+    json   = "start,omitempty"
+    sql    = "VARCHAR(5) NOT NULL DEFAULT ''"
+  }
+  field "End" {
+    type   = "string"
+    column = "end_date"
+    json   = "end,omitempty"
+    sql    = "VARCHAR(5) NOT NULL DEFAULT ''"
+  }
+
+  # the days are stored comma separated
+  column "days" {
+    sql = "VARCHAR(27) NOT NULL DEFAULT ''"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+  index "coffee_id" {
+    fields = ["CoffeeID"]
+  }
+
+  sql_after = ["CREATE INDEX IF NOT EXISTS coffee_availability_coffee_id ON coffee_availability (coffee_id)"]
+}
+
+entity "Store" {
+  doc        = "Store is a coffee shop serving a menu of coffees"
+  collection = "Stores"
+  table      = "store"
+  memdb      = "Store"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Address" {
+    type   = "string"
+    column = "address"
+    json   = "address"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "City" {
+    type   = "string"
+    column = "city"
+    json   = "city"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Country" {
+    type   = "string"
+    column = "country"
+    json   = "country"
+    sql    = "VARCHAR(2) NOT NULL"
+  }
+  field "Latitude" {
+    type   = "float64"
+    column = "latitude"
+    json   = "latitude"
+    sql    = "DOUBLE PRECISION NOT NULL"
+  }
+  field "Longitude" {
+    type   = "float64"
+    column = "longitude"
+    json   = "longitude"
+    sql    = "DOUBLE PRECISION NOT NULL"
+  }
+  field "DistanceKm" {
+    doc    = "DistanceKm is the distance from the point of a nearby search"
+    type   = "float64"
+    column = "distance_km"
+    json   = "distance_km,omitempty"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+}
+
+entity "StoreCoffee" {
+  doc   = "StoreCoffee is a coffee on the menu of a store"
+  table = "store_coffee"
+  memdb = "StoreCoffee"
+
+  field "StoreID" {
+    type   = "int"
+    column = "store_id"
+    json   = "store_id"
+    sql    = "INT NOT NULL REFERENCES store(id) ON DELETE CASCADE"
+  }
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "coffee_id"
+    sql    = "INT NOT NULL REFERENCES coffee(id) ON DELETE CASCADE"
+  }
+
+  index "id" {
+    fields = ["StoreID", "CoffeeID"]
+    unique = true
+  }
+  index "store_id" {
+    fields = ["StoreID"]
+  }
+  index "coffee_id" {
+    fields = ["CoffeeID"]
+  }
+
+  constraints = ["PRIMARY KEY (store_id, coffee_id)"]
+  sql_after   = ["CREATE INDEX IF NOT EXISTS store_coffee_coffee_id ON store_coffee (coffee_id)"]
+}
+
+entity "Supplier" {
+  doc = <<EOT
+Supplier is the producer of one or more ingredients, with its origin and
+certifications
+EOT
+  collection = "Suppliers"
+  table      = "supplier"
+  memdb      = "Supplier"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Country" {
+    doc    = "Country is the ISO 3166-1 alpha-2 code of the country of origin"
+    type   = "string"
+    column = "country"
+    json   = "country"
+    sql    = "VARCHAR(2) NOT NULL"
+  }
+  field "Certifications" {
+    doc    = "Certifications are lower case labels, e.g. organic or fairtrade"
+    type   = "[]string"
+    column = "-"
+    json   = "certifications"
+  }
+  field "IngredientIDs" {
+    type   = "[]int"
+    column = "-"
+    json   = "ingredient_ids"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  # the certifications are stored comma separated
+  column "certifications" {
+    sql = "VARCHAR(255) NOT NULL DEFAULT ''"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+}
+
+entity "IngredientSupplier" {
+  doc   = "IngredientSupplier links an ingredient to its supplier"
+  table = "ingredient_supplier"
+  memdb = "IngredientSupplier"
+
+  field "IngredientID" {
+    type   = "int"
+    column = "ingredient_id"
+    json   = "ingredient_id"
+    sql    = "INT PRIMARY KEY REFERENCES ingredient(id) ON DELETE CASCADE"
+  }
+  field "SupplierID" {
+    type   = "int"
+    column = "supplier_id"
+    json   = "supplier_id"
+    sql    = "INT NOT NULL REFERENCES supplier(id) ON DELETE CASCADE"
+  }
+
+  index "id" {
+    doc    = "an ingredient has at most one supplier"
+    fields = ["IngredientID"]
+    unique = true
+  }
+  index "supplier_id" {
+    fields = ["SupplierID"]
+  }
+
+  sql_after = ["CREATE INDEX IF NOT EXISTS ingredient_supplier_supplier_id ON ingredient_supplier (supplier_id)"]
+}
+
+entity "Coupon" {
+  doc = <<EOT
+Coupon is a discount code redeemed on orders until it expires or reaches
+its usage limit
+EOT
+  collection = "Coupons"
+  table      = "coupon"
+  memdb      = "Coupon"
+
+  field "Code" {
+    doc    = "Code is upper case, e.g. WELCOME10"
+    type   = "string"
+    column = "code"
+    json   = "code"
+    sql    = "VARCHAR(32) PRIMARY KEY"
+  }
+  field "UUID" {
+    doc    = "UUID is a UUIDv7 assigned on insert with ENTITY_IDS=uuidv7, empty otherwise"
+    type   = "string"
+    column = "uuid"
+    json   = "uuid,omitempty"
+    sql    = "UUID UNIQUE"
+  }
+  field "Type" {
+    type   = "string"
+    column = "type"
+    json   = "type"
+    sql    = "VARCHAR(16) NOT NULL"
+  }
+  field "Value" {
+    type   = "float64"
+    column = "value"
+    json   = "value"
+    sql    = "NUMERIC(10,2) NOT NULL"
+  }
+  field "ExpiresAt" {
+    doc    = "ExpiresAt is when the coupon stops being redeemable, never when nil"
+    type   = "*time.Time"
+    column = "expires_at"
+    json   = "expires_at,omitempty"
+    sql    = "TIMESTAMPTZ"
+  }
+  field "UsageLimit" {
+    doc    = "UsageLimit is how often the coupon can be redeemed, unlimited when 0"
+    type   = "int"
+    column = "usage_limit"
+    json   = "usage_limit"
+    sql    = "INT NOT NULL DEFAULT 0"
+  }
+  field "Used" {
+    type   = "int"
+    column = "used"
+    json   = "used"
+    sql    = "INT NOT NULL DEFAULT 0"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  index "id" {
+    fields = ["Code"]
+    unique = true
+  }
+  index "uuid" {
+    fields        = ["UUID"]
+    unique        = true
+    allow_missing = true
+    uuid          = true
+  }
+}
+
+entity "OrderRecord" {
+  doc = <<EOT
+OrderRecord is an order the coffee-service created in the product-api,
+recorded locally for the admin statistics. Its ID is the product-api order
+ID and its total is after discounts.
+EOT
+  table = "order_record"
+  memdb = "OrderRecord"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "INT PRIMARY KEY"
+  }
+  field "UUID" {
+    doc    = "UUID is a UUIDv7 assigned on insert with ENTITY_IDS=uuidv7, empty otherwise"
+    type   = "string"
+    column = "uuid"
+    json   = "uuid,omitempty"
+    sql    = "UUID UNIQUE"
+  }
+  field "Status" {
+    type   = "string"
+    column = "status"
+    json   = "status"
+    sql    = "VARCHAR(16) NOT NULL"
+  }
+  field "Total" {
+    type   = "float64"
+    column = "total"
+    json   = "total"
+    sql    = "NUMERIC(10,2) NOT NULL"
+  }
+  field "CreatedAt" {
+    type   = "time.Time"
+    column = "created_at"
+    json   = "created_at"
+    sql    = "TIMESTAMPTZ NOT NULL"
+  }
+  field "Items" {
+    type   = "[]OrderRecordItem"
+    column = "-"
+    json   = "items"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+  index "uuid" {
+    fields        = ["UUID"]
+    unique        = true
+    allow_missing = true
+    uuid          = true
+  }
+
+  sql_after = ["CREATE INDEX IF NOT EXISTS order_record_created_at ON order_record (created_at)"]
+}
+
+entity "OrderRecordItem" {
+  doc = <<EOT
+OrderRecordItem is a quantity of a coffee in an order record, with its name
+and price when ordered
+EOT
+  table = "order_record_item"
+
+  field "OrderID" {
+    type   = "int"
+    column = "order_id"
+    json   = "-"
+    sql    = "INT NOT NULL REFERENCES order_record(id) ON DELETE CASCADE"
+  }
+  field "CoffeeID" {
+    type   = "int"
+    column = "coffee_id"
+    json   = "coffee_id"
+    sql    = "INT NOT NULL"
+  }
+  field "Name" {
+    type   = "string"
+    column = "name"
+    json   = "name"
+    sql    = "VARCHAR(255) NOT NULL"
+  }
+  field "Quantity" {
+    type   = "int"
+    column = "quantity"
+    json   = "quantity"
+    sql    = "INT NOT NULL"
+  }
+  field "Price" {
+    type   = "float64"
+    column = "price"
+    json   = "price"
+    sql    = "NUMERIC(10,2) NOT NULL"
+  }
+
+  sql_after = ["CREATE INDEX IF NOT EXISTS order_record_item_order_id ON order_record_item (order_id)"]
+}
+
+entity "User" {
+  doc   = "User is the profile of a user, keyed by the subject of their token"
+  table = "users"
+  memdb = "User"
+
+  field "Subject" {
+    type   = "string"
+    column = "subject"
+    json   = "subject"
+    sql    = "VARCHAR(255) PRIMARY KEY"
+  }
+  field "DisplayName" {
+    type   = "string"
+    column = "display_name"
+    json   = "display_name"
+    sql    = "VARCHAR(64) NOT NULL"
+  }
+  field "FavoriteMilk" {
+    doc = <<EOT
+FavoriteMilk is one of whole, skim, oat, almond or soy, no preference
+when empty
+EOT
+    type   = "string"
+    column = "favorite_milk"
+    json   = "favorite_milk"
+    sql    = "VARCHAR(16) NOT NULL DEFAULT ''"
+  }
+  field "DefaultStoreID" {
+    doc    = "DefaultStoreID is the store orders go to, none when nil"
+    type   = "*int"
+    column = "default_store_id"
+    json   = "default_store_id"
+    sql    = "INT REFERENCES store(id) ON DELETE SET NULL"
+  }
+  field "CreatedAt" {
+    type   = "string"
+    column = "created_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+  field "UpdatedAt" {
+    type   = "string"
+    column = "updated_at"
+    json   = "-"
+    sql    = "TIMESTAMP NOT NULL"
+  }
+
+  index "id" {
+    fields = ["Subject"]
+    unique = true
+  }
+}
+
+entity "PointsEntry" {
+  doc = <<EOT
+PointsEntry is an entry of the loyalty points ledger of a user, crediting
+positive points and debiting negative ones
+EOT
+  table = "points_entry"
+  memdb = "PointsEntry"
+
+  field "ID" {
+    type   = "int"
+    column = "id"
+    json   = "id"
+    sql    = "SERIAL PRIMARY KEY"
+  }
+  field "UUID" {
+    doc    = "UUID is a UUIDv7 assigned on insert with ENTITY_IDS=uuidv7, empty otherwise"
+    type   = "string"
+    column = "uuid"
+    json   = "uuid,omitempty"
+    sql    = "UUID UNIQUE"
+  }
+  field "UserID" {
+    type   = "int"
+    column = "user_id"
+    json   = "-"
+    sql    = "INT NOT NULL"
+  }
+  field "OrderID" {
+    type   = "int"
+    column = "order_id"
+    json   = "order_id"
+    sql    = "INT NOT NULL"
+  }
+  field "Points" {
+    type   = "int"
+    column = "points"
+    json   = "points"
+    sql    = "INT NOT NULL"
+  }
+  field "Reason" {
+    type   = "string"
+    column = "reason"
+    json   = "reason"
+    sql    = "VARCHAR(16) NOT NULL"
+  }
+  field "CreatedAt" {
+    type   = "time.Time"
+    column = "created_at"
+    json   = "created_at"
+    sql    = "TIMESTAMPTZ NOT NULL DEFAULT now()"
+  }
+
+  index "id" {
+    fields = ["ID"]
+    unique = true
+  }
+  index "uuid" {
+    fields        = ["UUID"]
+    unique        = true
+    allow_missing = true
+    uuid          = true
+  }
+  index "user_id" {
+    fields = ["UserID"]
+  }
+
+  sql_after = ["CREATE INDEX IF NOT EXISTS points_entry_user_id ON points_entry (user_id)"]
+}
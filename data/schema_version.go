@@ -0,0 +1,261 @@
+package data
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migration is a file of data/migrations, its version is the number its name
+// starts with
+type migration struct {
+	version int
+	name    string
+}
+
+// migrations are the embedded migrations in version order
+var migrations = mustMigrations()
+
+// SchemaVersion is the version of the schema the code expects, the version
+// of the latest migration in data/migrations. The in memory schema is
+// generated with the code from the same schema.hcl, so it is always at this
+// version.
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// unversionedSchema is the latest version predating the schema_version table
+// of migration 0018
+const unversionedSchema = 17
+
+// schemaObject is an object created by a migration, a relation or a column of
+// one, telling whether a database without schema_version has the migration
+type schemaObject struct {
+	// relation is the table or index created by the migration, or the table
+	// it alters
+	relation string
+	// column is the column added to relation, if any
+	column string
+	// rows is set for a migration which only inserts rows into relation
+	rows bool
+}
+
+// unversionedObjects are, by version, the last object created by each
+// migration predating schema_version
+var unversionedObjects = [unversionedSchema + 1]schemaObject{
+	1:  {relation: "coffee_ingredient"},
+	2:  {relation: "coffee", rows: true},
+	3:  {relation: "coffee_ingredient", column: "unit"},
+	4:  {relation: "coffee_name_trigrams"},
+	5:  {relation: "coffee_slug"},
+	6:  {relation: "coffee_translation"},
+	7:  {relation: "coffee_status"},
+	8:  {relation: "coffee_availability_coffee_id"},
+	9:  {relation: "store_coffee_coffee_id"},
+	10: {relation: "ingredient_supplier_supplier_id"},
+	11: {relation: "coupon"},
+	12: {relation: "points_entry_user_id"},
+	13: {relation: "users"},
+	14: {relation: "order_record_item_order_id"},
+	15: {relation: "sales_hour"},
+	16: {relation: "coffee_image"},
+	17: {relation: "job_kind_run_at"},
+}
+
+// ErrSchemaMismatch is returned at startup when the schema of the database is
+// not the one the code expects
+var ErrSchemaMismatch = errors.New("schema version does not match the code")
+
+// mustMigrations lists the embedded migrations, it panics when one is not
+// named after its version
+func mustMigrations() []migration {
+	names, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	list := make([]migration, 0, len(names))
+	for _, entry := range names {
+		version, err := strconv.Atoi(strings.SplitN(entry.Name(), "_", 2)[0])
+		if err != nil {
+			panic(fmt.Sprintf("data: migration %s is not named after its version", entry.Name()))
+		}
+		list = append(list, migration{version: version, name: entry.Name()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	return list
+}
+
+// SchemaVersion returns the version of the in memory schema, SchemaVersion
+func (r *InMemoryRepository) SchemaVersion(ctx context.Context) (int, error) {
+	return SchemaVersion, nil
+}
+
+// SchemaVersion returns the latest version recorded in the schema_version
+// table. A database without the table is at the version of the latest
+// migration whose objects it has, see unversionedObjects.
+func (r *PostgresRepository) SchemaVersion(ctx context.Context) (int, error) {
+	version := 0
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		version, err = schemaVersion(ctx, tx)
+		return err
+	})
+	return version, err
+}
+
+// schemaVersion reads the version of the schema within tx
+func schemaVersion(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	versioned := false
+	if err := txGet(ctx, tx, &versioned, "SELECT to_regclass('schema_version') IS NOT NULL"); err != nil {
+		return 0, err
+	}
+	if !versioned {
+		return unversionedSchemaVersion(ctx, tx)
+	}
+
+	version := 0
+	err := txGet(ctx, tx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+	return version, err
+}
+
+// unversionedSchemaVersion returns the version of a database without
+// schema_version, the latest migration whose objects it has. It fails with
+// ErrSchemaMismatch when the objects of an earlier migration are missing, its
+// schema is none of the versions then. The rows a migration inserted may have
+// been deleted since, they are only missing for the latest one.
+func unversionedSchemaVersion(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	version, missing := 0, 0
+	for v := 1; v <= unversionedSchema; v++ {
+		exists, err := objectExists(ctx, tx, unversionedObjects[v])
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case !exists && missing == 0 && !unversionedObjects[v].rows:
+			missing = v
+		case exists && missing != 0:
+			return 0, fmt.Errorf("%w: the database has the objects of migration %d but not of migration %d", ErrSchemaMismatch, v, missing)
+		case exists:
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// objectExists tells whether the object of a migration is in the database
+func objectExists(ctx context.Context, tx *sqlx.Tx, object schemaObject) (bool, error) {
+	exists := false
+	if err := txGet(ctx, tx, &exists, "SELECT to_regclass($1) IS NOT NULL", object.relation); err != nil || !exists {
+		return false, err
+	}
+
+	switch {
+	case object.column != "":
+		err := txGet(ctx, tx, &exists, `
+			SELECT EXISTS (SELECT 1 FROM information_schema.columns
+			               WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2)`,
+			object.relation, object.column)
+		return exists, err
+	case object.rows:
+		err := txGet(ctx, tx, &exists, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", object.relation))
+		return exists, err
+	}
+	return true, nil
+}
+
+// Migrate applies the embedded migrations newer than the schema of the
+// database in a single transaction, returning the names of the applied
+// migrations. The transaction holds an advisory lock, so instances starting
+// together apply them once.
+func (r *PostgresRepository) Migrate(ctx context.Context) ([]string, error) {
+	applied := []string{}
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := txExec(ctx, tx, "SELECT pg_advisory_xact_lock(hashtext('coffee-service/migrations'))"); err != nil {
+			return err
+		}
+		version, err := schemaVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if m.version <= version {
+				continue
+			}
+			statements, err := migrationFS.ReadFile(path.Join("migrations", m.name))
+			if err != nil {
+				return err
+			}
+			if _, err := txExec(ctx, tx, string(statements)); err != nil {
+				return fmt.Errorf("migration %s: %w", m.name, err)
+			}
+			applied = append(applied, m.name)
+
+			// the versions before the table can not be recorded
+			if m.version > unversionedSchema {
+				if version, err = schemaVersion(ctx, tx); err != nil {
+					return err
+				}
+				if version != m.version {
+					return fmt.Errorf("migration %s does not record version %d in schema_version", m.name, m.version)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// checkSchema fails with ErrSchemaMismatch unless the database is at
+// SchemaVersion. An older database is migrated first when migrate is set, a
+// newer one never is, the code would not know its shape.
+func (r *PostgresRepository) checkSchema(ctx context.Context, migrate bool) ([]string, error) {
+	version, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := schemaMismatch(version, migrate); err != nil || version == SchemaVersion {
+		return nil, err
+	}
+
+	return r.Migrate(ctx)
+}
+
+// schemaMismatch returns ErrSchemaMismatch unless a database at version can
+// be served, after the migrations when migrate is set
+func schemaMismatch(version int, migrate bool) error {
+	if version > SchemaVersion || (version < SchemaVersion && !migrate) {
+		return fmt.Errorf("%w: the database is at version %d, the code expects %d", ErrSchemaMismatch, version, SchemaVersion)
+	}
+	return nil
+}
+
+// CheckSchema opens a single connection to the database and returns the
+// version of its schema, failing with ErrSchemaMismatch when NewFromConfig
+// would refuse to start with it
+func CheckSchema(ctx context.Context, connection string, migrate bool) (int, error) {
+	db, err := sqlx.ConnectContext(ctx, driverName, connection)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	r := &PostgresRepository{db: db}
+	version, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return version, schemaMismatch(version, migrate)
+}
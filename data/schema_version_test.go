@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreNumberedInOrder(t *testing.T) {
+	require.NotEmpty(t, migrations)
+	for n, m := range migrations {
+		assert.Equal(t, n+1, m.version, m.name)
+	}
+	assert.Equal(t, migrations[len(migrations)-1].version, SchemaVersion)
+}
+
+func TestMigrationsRecordTheirVersion(t *testing.T) {
+	for _, m := range migrations {
+		if m.version <= unversionedSchema {
+			continue
+		}
+		statements, err := migrationFS.ReadFile("migrations/" + m.name)
+		require.NoError(t, err)
+		insert := fmt.Sprintf("INSERT INTO schema_version (version) VALUES (%d)", m.version)
+		assert.True(t, strings.Contains(string(statements), insert), "%s does not record its version", m.name)
+	}
+}
+
+func TestUnversionedObjectsAreCreatedByTheirMigration(t *testing.T) {
+	for _, m := range migrations {
+		if m.version > unversionedSchema {
+			continue
+		}
+		object := unversionedObjects[m.version]
+		require.NotEmpty(t, object.relation, m.name)
+		statements, err := migrationFS.ReadFile("migrations/" + m.name)
+		require.NoError(t, err)
+		name := object.relation
+		switch {
+		case object.column != "":
+			name = "ADD COLUMN IF NOT EXISTS " + object.column
+		case object.rows:
+			name = "INSERT INTO " + object.relation
+		}
+		assert.True(t, strings.Contains(string(statements), name), "%s does not create %s", m.name, name)
+	}
+}
+
+func TestSchemaMismatch(t *testing.T) {
+	assert.NoError(t, schemaMismatch(SchemaVersion, false))
+	assert.NoError(t, schemaMismatch(SchemaVersion-1, true))
+
+	err := schemaMismatch(SchemaVersion-1, false)
+	assert.True(t, errors.Is(err, ErrSchemaMismatch))
+	assert.Contains(t, err.Error(), fmt.Sprintf("the database is at version %d, the code expects %d", SchemaVersion-1, SchemaVersion))
+	// a newer database is never migrated
+	assert.True(t, errors.Is(schemaMismatch(SchemaVersion+1, true), ErrSchemaMismatch))
+}
+
+func TestInMemorySchemaIsAtTheVersionOfTheCode(t *testing.T) {
+	r, err := NewEmptyInMemoryDB()
+	require.NoError(t, err)
+
+	version, err := r.SchemaVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+}
@@ -0,0 +1,231 @@
+// Package search implements an in-process inverted index over the coffee
+// catalogue used to serve ranked full-text search.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// snippetRadius is the number of characters kept either side of the first
+// match when a field is shortened into a snippet
+const snippetRadius = 40
+
+// field is an indexed coffee attribute and its ranking boost
+type field struct {
+	name  string
+	boost float64
+	value func(c *entities.Coffee) string
+}
+
+var fields = []field{
+	{"name", 3, func(c *entities.Coffee) string { return c.Name }},
+	{"teaser", 2, func(c *entities.Coffee) string { return c.Teaser }},
+	{"description", 1, func(c *entities.Coffee) string { return c.Description }},
+}
+
+// Result is a ranked search hit
+type Result struct {
+	Coffee     entities.Coffee   `json:"coffee"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Index is an inverted index from terms to the coffees containing them. It
+// is safe for concurrent use.
+type Index struct {
+	mu sync.RWMutex
+	// postings maps a term to the boosted term frequency per coffee ID
+	postings map[string]map[int]float64
+	coffees  map[int]entities.Coffee
+}
+
+// NewIndex creates an empty Index
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[int]float64{},
+		coffees:  map[int]entities.Coffee{},
+	}
+}
+
+// Add indexes a coffee, replacing any previous version with the same ID
+func (i *Index) Add(c entities.Coffee) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(c.ID)
+
+	i.coffees[c.ID] = c
+	for _, f := range fields {
+		for _, term := range tokenize(f.value(&c)) {
+			if i.postings[term] == nil {
+				i.postings[term] = map[int]float64{}
+			}
+			i.postings[term][c.ID] += f.boost
+		}
+	}
+}
+
+// Remove drops a coffee from the index
+func (i *Index) Remove(id int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(id)
+}
+
+func (i *Index) remove(id int) {
+	if _, ok := i.coffees[id]; !ok {
+		return
+	}
+
+	delete(i.coffees, id)
+	for term, docs := range i.postings {
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(i.postings, term)
+		}
+	}
+}
+
+// Len returns the number of indexed coffees
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.coffees)
+}
+
+// Search returns up to limit coffees matching any term of the query, ranked
+// by TF-IDF with name matches weighted above teasers and descriptions.
+func (i *Index) Search(query string, limit int) []Result {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	terms := tokenize(query)
+	scores := map[int]float64{}
+	for _, term := range terms {
+		docs := i.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + float64(len(i.coffees))/float64(len(docs)))
+		for id, tf := range docs {
+			scores[id] += tf * idf
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		c := i.coffees[id]
+		results = append(results, Result{
+			Coffee:     c,
+			Score:      score,
+			Highlights: highlights(&c, terms),
+		})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Coffee.ID < results[b].Coffee.ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// tokenize lower cases text and splits it into letter/digit runs
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// highlights returns an HTML escaped snippet per matching field with the
+// matched words wrapped in <em> tags
+func highlights(c *entities.Coffee, terms []string) map[string]string {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	h := map[string]string{}
+	for _, f := range fields {
+		if snippet, ok := highlight(f.value(c), wanted); ok {
+			h[f.name] = snippet
+		}
+	}
+
+	return h
+}
+
+func highlight(text string, wanted map[string]bool) (string, bool) {
+	runes := []rune(text)
+
+	type span struct{ start, end int }
+	spans := make([]span, 0)
+	for start := 0; start < len(runes); {
+		if !unicode.IsLetter(runes[start]) && !unicode.IsDigit(runes[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+			end++
+		}
+		if wanted[strings.ToLower(string(runes[start:end]))] {
+			spans = append(spans, span{start, end})
+		}
+		start = end
+	}
+
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	from := spans[0].start - snippetRadius
+	if from < 0 {
+		from = 0
+	}
+	to := spans[0].end + snippetRadius
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+
+	pos := from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[pos:s.start])))
+		sb.WriteString("<em>")
+		sb.WriteString(html.EscapeString(string(runes[s.start:s.end])))
+		sb.WriteString("</em>")
+		pos = s.end
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:to])))
+
+	if to < len(runes) {
+		sb.WriteString("…")
+	}
+
+	return sb.String(), true
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupIndex() *Index {
+	i := NewIndex()
+	i.Add(entities.Coffee{ID: 1, Name: "Packer Spiced Latte", Teaser: "Packed with goodness to spice up your images"})
+	i.Add(entities.Coffee{ID: 2, Name: "Vaulatte", Teaser: "Nothing gives you a safe and secure feeling like a Vaulatte"})
+	i.Add(entities.Coffee{ID: 3, Name: "Nomadicano", Teaser: "Drink one today and you will want to schedule another latte"})
+	return i
+}
+
+func TestSearchRanksNameMatchesFirst(t *testing.T) {
+	results := setupIndex().Search("latte", 10)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Coffee.ID)
+	assert.Equal(t, 3, results[1].Coffee.ID)
+	assert.Greater(t, results[0].Score, results[1].Score)
+}
+
+func TestSearchHighlightsMatches(t *testing.T) {
+	results := setupIndex().Search("LATTE", 1)
+
+	assert.Equal(t, "Packer Spiced <em>Latte</em>", results[0].Highlights["name"])
+	assert.NotContains(t, results[0].Highlights, "teaser")
+}
+
+func TestSearchShortensLongFields(t *testing.T) {
+	i := NewIndex()
+	i.Add(entities.Coffee{ID: 1, Description: strings.Repeat("filler ", 20) + "<b>espresso</b> " + strings.Repeat("filler ", 20)})
+
+	snippet := i.Search("espresso", 1)[0].Highlights["description"]
+	assert.True(t, strings.HasPrefix(snippet, "…"))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+	assert.Contains(t, snippet, "&lt;b&gt;<em>espresso</em>&lt;/b&gt;")
+}
+
+func TestAddReplacesAndRemoveDrops(t *testing.T) {
+	i := setupIndex()
+
+	i.Add(entities.Coffee{ID: 1, Name: "Packer Mocha"})
+	assert.Len(t, i.Search("latte", 10), 1)
+	assert.Len(t, i.Search("mocha", 10), 1)
+
+	i.Remove(1)
+	assert.Empty(t, i.Search("mocha", 10))
+	assert.Equal(t, 2, i.Len())
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"sync"
+)
+
+type sessionKey struct{}
+
+// Session collects the positions of the writes made with a context, so the
+// client can be handed a session token and read its own writes afterwards.
+// It is safe for concurrent use.
+type Session struct {
+	mu    sync.Mutex
+	index uint64
+}
+
+// WithSession returns a context recording the positions of its writes in a
+// new Session
+func WithSession(ctx context.Context) (context.Context, *Session) {
+	session := &Session{}
+	return context.WithValue(ctx, sessionKey{}, session), session
+}
+
+// Index returns the Raft log index of the latest write of the session, 0 when
+// the backend has no log
+func (s *Session) Index() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// recordIndex records a write at the Raft log index in the session of the
+// context, if any
+func recordIndex(ctx context.Context, index uint64) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if index > session.index {
+		session.index = index
+	}
+}
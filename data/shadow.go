@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// ShadowOptions configure a ShadowRepository
+type ShadowOptions struct {
+	// Sample is the percentage of reads repeated against the shadow, every
+	// read is repeated when it is 0
+	Sample  float64
+	Logger  hclog.Logger
+	Metrics metrics.Sink
+}
+
+// ShadowRepository is a Repository verifying a shadow backend against the
+// primary one, e.g. the in memory backend against Postgres. Callers are only
+// served by the primary, successful reads are repeated against the shadow in
+// the background and mismatches are logged and counted. Writes only go to the
+// primary, the shadow is expected to be kept in sync by other means.
+//
+// Unlike the MigratingRepository, IDs are not translated, so the backends must
+// hold the same catalogue with the same IDs.
+type ShadowRepository struct {
+	Repository
+	shadow  Repository
+	sample  float64
+	compare *comparator
+}
+
+// NewShadow wraps primary to compare its reads with shadow
+func NewShadow(primary, shadow Repository, options ShadowOptions) *ShadowRepository {
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+	if options.Sample <= 0 {
+		options.Sample = 100
+	}
+	return &ShadowRepository{
+		Repository: primary,
+		shadow:     shadow,
+		sample:     options.Sample,
+		compare:    &comparator{prefix: "shadow", logger: options.Logger, metrics: options.Metrics},
+	}
+}
+
+// Unwrap returns the primary, which serves the optional capabilities alone
+func (r *ShadowRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Find returns all coffees from the primary
+func (r *ShadowRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	coffees, err := r.Repository.Find(ctx)
+	if err == nil && r.sampled() {
+		r.compare.coffees("Find", coffees, r.shadow.Find)
+	}
+	return coffees, err
+}
+
+// FindWhere returns the coffees matching expr from the primary
+func (r *ShadowRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindWhere(ctx, expr)
+	if err == nil && r.sampled() {
+		r.compare.coffees("FindWhere", coffees, func(ctx context.Context) (entities.Coffees, error) {
+			return r.shadow.FindWhere(ctx, expr)
+		})
+	}
+	return coffees, err
+}
+
+// FindByID returns a single coffee from the primary
+func (r *ShadowRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	coffee, err := r.Repository.FindByID(ctx, coffeeID)
+	if err == nil && r.sampled() {
+		r.compare.coffees("FindByID", entities.Coffees{*coffee}, func(ctx context.Context) (entities.Coffees, error) {
+			other, err := r.shadow.FindByID(ctx, coffeeID)
+			if err != nil {
+				return nil, err
+			}
+			return entities.Coffees{*other}, nil
+		})
+	}
+	return coffee, err
+}
+
+// FindRelated returns the related coffees from the primary
+func (r *ShadowRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindRelated(ctx, coffeeID, limit)
+	if err == nil && r.sampled() {
+		r.compare.coffees("FindRelated", coffees, func(ctx context.Context) (entities.Coffees, error) {
+			return r.shadow.FindRelated(ctx, coffeeID, limit)
+		})
+	}
+	return coffees, err
+}
+
+// FindIngredients returns the ingredients from the primary
+func (r *ShadowRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	ingredients, err := r.Repository.FindIngredients(ctx)
+	if err == nil && r.sampled() {
+		r.compare.ingredients("FindIngredients", ingredients, r.shadow.FindIngredients)
+	}
+	return ingredients, err
+}
+
+// sampled reports whether a read is repeated against the shadow
+func (r *ShadowRepository) sampled() bool {
+	return r.sample >= 100 || rand.Float64()*100 < r.sample
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// setupShadow shadows an in memory repository with another one holding the
+// same catalogue
+func setupShadow(t *testing.T) (*ShadowRepository, Repository, *metrics.PrometheusSink) {
+	primary, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	shadow, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	sink := metrics.NewPrometheusSink()
+	return NewShadow(primary, shadow, ShadowOptions{Logger: hclog.NewNullLogger(), Metrics: sink}), shadow, sink
+}
+
+func TestShadowComparesReads(t *testing.T) {
+	ctx := context.Background()
+	r, _, sink := setupShadow(t)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	_, err = r.FindByID(ctx, coffees[0].ID)
+	require.NoError(t, err)
+	_, err = r.FindIngredients(ctx)
+	require.NoError(t, err)
+
+	exported(t, sink, `coffee_service_shadow_comparisons_total{method="Find"} 1`)
+	exported(t, sink, `coffee_service_shadow_comparisons_total{method="FindByID"} 1`)
+	exported(t, sink, `coffee_service_shadow_comparisons_total{method="FindIngredients"} 1`)
+	assert.Never(t, func() bool {
+		rw := httptest.NewRecorder()
+		sink.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+		return strings.Contains(rw.Body.String(), "coffee_service_shadow_divergences_total")
+	}, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestShadowReportsMismatches(t *testing.T) {
+	ctx := context.Background()
+	r, shadow, sink := setupShadow(t)
+
+	// writes only go to the primary
+	coffee := &entities.Coffee{Name: "Rogue", Price: 300}
+	require.NoError(t, r.CreateCoffee(ctx, coffee))
+	_, err := shadow.FindByID(ctx, coffee.ID)
+	assert.Equal(t, ErrNotFound, err)
+
+	found, err := r.FindByID(ctx, coffee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Rogue", found.Name)
+	exported(t, sink, `coffee_service_shadow_divergences_total{method="FindByID"} 1`)
+
+	_, err = r.Find(ctx)
+	require.NoError(t, err)
+	exported(t, sink, `coffee_service_shadow_divergences_total{method="Find"} 1`)
+}
@@ -0,0 +1,450 @@
+package data
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// virtualNodes is the number of points each shard owns on the hash ring, more
+// points spread the coffees more evenly
+const virtualNodes = 64
+
+// ShardedRepository is a Repository partitioning coffees by ID across several
+// in memory instances, to demo horizontal partitioning without a database.
+// A consistent hash ring routes each coffee to the shard owning its ID, so
+// adding a shard only moves the coffees of its points on the ring. Reads of
+// many coffees are scattered to every shard and the results gathered.
+//
+// Ingredients are reference data every shard needs to name the ingredients of
+// its coffees, so they are replicated to all shards.
+//
+// Slugs are unique across the shards: a shard generating a slug checks the
+// others, and the coffee writes are serialized so that a slug found free is
+// still free once written.
+type ShardedRepository struct {
+	shards []*InMemoryRepository
+	ring   *ring
+	// sequences assigns coffee IDs across the shards
+	sequences sequences
+	// writes serializes the coffee writes, which generate slugs
+	writes sync.Mutex
+}
+
+// NewSharded creates count in memory instances and partitions the seeded
+// coffees across them
+func NewSharded(cfg *config.Config, count int) (*ShardedRepository, error) {
+	ctx := context.Background()
+	r := &ShardedRepository{ring: newRing(count, virtualNodes)}
+
+	for n := 0; n < count; n++ {
+		shard, err := NewInMemoryDB(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create shard %d: %w", n, err)
+		}
+		shard.(*InMemoryRepository).slugTakenElsewhere = r.slugTaken
+		r.shards = append(r.shards, shard.(*InMemoryRepository))
+	}
+
+	// every instance is seeded with the whole catalogue, each one keeps the
+	// coffees it owns
+	coffees, err := r.shards[0].Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer entities.PutCoffees(coffees)
+	for _, c := range coffees {
+		r.sequences.observe(Coffee, c.ID)
+		owner := r.ring.shard(c.ID)
+		for n, shard := range r.shards {
+			if n == owner {
+				continue
+			}
+			if err := shard.DeleteCoffee(ctx, c.ID); err != nil {
+				return nil, fmt.Errorf("unable to partition coffee %d: %w", c.ID, err)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// IsConnected reports whether every shard is connected
+func (r *ShardedRepository) IsConnected(ctx context.Context) (bool, error) {
+	for _, shard := range r.shards {
+		if ok, err := shard.IsConnected(ctx); !ok || err != nil {
+			return ok, err
+		}
+	}
+	return true, nil
+}
+
+// Find returns all coffees of every shard ordered by ID
+func (r *ShardedRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	return r.gather(ctx, r.shards, func(ctx context.Context, shard *InMemoryRepository) (entities.Coffees, error) {
+		return shard.Find(ctx)
+	})
+}
+
+// FindWhere returns the coffees matching expr ordered by ID. An expression
+// selecting a single ID is only sent to the shard owning it.
+func (r *ShardedRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	shards := r.shards
+	if index, args := indexedLookup(expr); index == "id" && len(args) == 1 {
+		shards = []*InMemoryRepository{r.owner(args[0].(int))}
+	}
+
+	return r.gather(ctx, shards, func(ctx context.Context, shard *InMemoryRepository) (entities.Coffees, error) {
+		return shard.FindWhere(ctx, expr)
+	})
+}
+
+// FindByID returns a single coffee from the shard owning it
+func (r *ShardedRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	return r.owner(coffeeID).FindByID(ctx, coffeeID)
+}
+
+// FindRelated returns up to limit coffees sharing the most ingredients with
+// coffeeID. Related coffees may live on any shard, so every coffee is
+// gathered and ranked as by a single instance.
+func (r *ShardedRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	if _, err := r.FindByID(ctx, coffeeID); err != nil {
+		return nil, err
+	}
+
+	coffees, err := r.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer entities.PutCoffees(coffees)
+
+	byID := make(map[int]entities.Coffee, len(coffees))
+	ingredientsByCoffee := make(map[int][]entities.CoffeeIngredients, len(coffees))
+	for _, c := range coffees {
+		byID[c.ID] = c
+		ingredientsByCoffee[c.ID] = c.Ingredients
+	}
+
+	ranked := rankRelated(coffeeID, ingredientsByCoffee)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	related := make(entities.Coffees, 0, len(ranked))
+	for _, c := range ranked {
+		related = append(related, byID[c.ID])
+	}
+	return related, nil
+}
+
+// FindBySlug returns the coffee with the slug from the shard holding it, the
+// slugs are unique across the shards
+func (r *ShardedRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	for _, shard := range r.shards {
+		coffee, err := shard.FindBySlug(ctx, slug)
+		if err != ErrNotFound {
+			return coffee, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateCoffee assigns the next coffee ID and creates the coffee in the shard
+// owning it
+func (r *ShardedRepository) CreateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	id := r.sequences.next(Coffee)
+	return r.owner(id).createCoffee(ctx, coffee, id, "")
+}
+
+// UpdateCoffee updates the coffee in the shard owning it
+func (r *ShardedRepository) UpdateCoffee(ctx context.Context, coffee *entities.Coffee) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	return r.owner(coffee.ID).UpdateCoffee(ctx, coffee)
+}
+
+// DeleteCoffee deletes the coffee from the shard owning it
+func (r *ShardedRepository) DeleteCoffee(ctx context.Context, coffeeID int) error {
+	return r.owner(coffeeID).DeleteCoffee(ctx, coffeeID)
+}
+
+// FindIngredients returns all ingredients, every shard holds all of them
+func (r *ShardedRepository) FindIngredients(ctx context.Context) (entities.Ingredients, error) {
+	return r.shards[0].FindIngredients(ctx)
+}
+
+// CreateIngredient creates the ingredient in the first shard, which assigns
+// its ID, and then replicates it to the others
+func (r *ShardedRepository) CreateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	if err := r.shards[0].CreateIngredient(ctx, ingredient); err != nil {
+		return err
+	}
+	for _, shard := range r.shards[1:] {
+		replica := *ingredient
+		if err := shard.createIngredient(ctx, &replica, ingredient.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateIngredient updates the ingredient in every shard
+func (r *ShardedRepository) UpdateIngredient(ctx context.Context, ingredient *entities.Ingredient) error {
+	for _, shard := range r.shards {
+		replica := *ingredient
+		if err := shard.UpdateIngredient(ctx, &replica); err != nil {
+			return err
+		}
+		*ingredient = replica
+	}
+	return nil
+}
+
+// DeleteIngredient deletes the ingredient from every shard
+func (r *ShardedRepository) DeleteIngredient(ctx context.Context, ingredientID int) error {
+	for _, shard := range r.shards {
+		if err := shard.DeleteIngredient(ctx, ingredientID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unwrap returns the first shard, which serves the optional capabilities
+// holding no coffees, e.g. stores, coupons or orders. Every shard is seeded
+// with them, the first one alone keeps their writes. The capabilities keyed by
+// coffee are routed to the shards owning the coffees below.
+func (r *ShardedRepository) Unwrap() Repository {
+	return r.shards[0]
+}
+
+// FindTranslations returns the translations of the coffees in coffeeIDs from
+// the shards owning them
+func (r *ShardedRepository) FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error) {
+	translations := entities.Translations{}
+	for n, ids := range r.byOwner(coffeeIDs) {
+		if len(ids) == 0 {
+			continue
+		}
+		found, err := r.shards[n].FindTranslations(ctx, ids, locales)
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, found...)
+	}
+	return translations, nil
+}
+
+// SetTranslation stores the translation in the shard owning its coffee
+func (r *ShardedRepository) SetTranslation(ctx context.Context, translation *entities.Translation) error {
+	return r.owner(translation.CoffeeID).SetTranslation(ctx, translation)
+}
+
+// DeleteTranslation removes the translation from the shard owning its coffee
+func (r *ShardedRepository) DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error {
+	return r.owner(coffeeID).DeleteTranslation(ctx, coffeeID, locale, field)
+}
+
+// FindImages returns the images of the coffees in coffeeIDs from the shards
+// owning them
+func (r *ShardedRepository) FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error) {
+	images := entities.CoffeeImages{}
+	for n, ids := range r.byOwner(coffeeIDs) {
+		if len(ids) == 0 {
+			continue
+		}
+		found, err := r.shards[n].FindImages(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, found...)
+	}
+	return images, nil
+}
+
+// SetImage stores the image in the shard owning its coffee
+func (r *ShardedRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	return r.owner(image.CoffeeID).SetImage(ctx, image)
+}
+
+// FindAvailability returns the availability rules of the coffees in coffeeIDs
+// from the shards owning them
+func (r *ShardedRepository) FindAvailability(ctx context.Context, coffeeIDs []int) (entities.AvailabilityRules, error) {
+	rules := entities.AvailabilityRules{}
+	for n, ids := range r.byOwner(coffeeIDs) {
+		if len(ids) == 0 {
+			continue
+		}
+		found, err := r.shards[n].FindAvailability(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, found...)
+	}
+	return rules, nil
+}
+
+// SetAvailability stores the availability rules in the shard owning the
+// coffee
+func (r *ShardedRepository) SetAvailability(ctx context.Context, coffeeID int, rules entities.AvailabilityRules) error {
+	return r.owner(coffeeID).SetAvailability(ctx, coffeeID, rules)
+}
+
+// FindStoreCoffees returns the IDs of the coffees on the menu of a store in ID
+// order. Each shard only keeps the menu entries of its own coffees, so they
+// are gathered from every shard.
+func (r *ShardedRepository) FindStoreCoffees(ctx context.Context, storeID int) ([]int, error) {
+	ids := []int{}
+	for _, shard := range r.shards {
+		found, err := shard.FindStoreCoffees(ctx, storeID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, found...)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// AggregateStats returns the statistics of the orders, recorded in the first
+// shard, counting the coffees of every shard
+func (r *ShardedRepository) AggregateStats(ctx context.Context, since time.Time, top int) (*entities.Stats, error) {
+	stats, err := r.shards[0].AggregateStats(ctx, since, top)
+	if err != nil {
+		return nil, err
+	}
+	for _, shard := range r.shards[1:] {
+		coffees, err := shard.Find(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats.Coffees += len(coffees)
+		entities.PutCoffees(coffees)
+	}
+	return stats, nil
+}
+
+// byOwner splits coffeeIDs by the index of the shard owning them
+func (r *ShardedRepository) byOwner(coffeeIDs []int) [][]int {
+	owned := make([][]int, len(r.shards))
+	for _, id := range coffeeIDs {
+		n := r.ring.shard(id)
+		owned[n] = append(owned[n], id)
+	}
+	return owned
+}
+
+// slugTaken reports whether a shard other than the owner of coffeeID has
+// another coffee with the slug, the owner checks its own coffees
+func (r *ShardedRepository) slugTaken(ctx context.Context, slug string, coffeeID int) (bool, error) {
+	owner := r.owner(coffeeID)
+	for _, shard := range r.shards {
+		if shard == owner {
+			continue
+		}
+		raw, err := shard.first(ctx, shard.db.Txn(false), Coffee, "slug", slug)
+		if err != nil || raw != nil {
+			return raw != nil, err
+		}
+	}
+	return false, nil
+}
+
+// owner returns the shard owning a coffee ID
+func (r *ShardedRepository) owner(coffeeID int) *InMemoryRepository {
+	return r.shards[r.ring.shard(coffeeID)]
+}
+
+// gather runs find against shards concurrently and merges the results in ID
+// order, as a single instance returns them
+func (r *ShardedRepository) gather(ctx context.Context, shards []*InMemoryRepository, find func(context.Context, *InMemoryRepository) (entities.Coffees, error)) (entities.Coffees, error) {
+	results := make([]entities.Coffees, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for n, shard := range shards {
+		wg.Add(1)
+		go func(n int, shard *InMemoryRepository) {
+			defer wg.Done()
+			results[n], errs[n] = find(ctx, shard)
+		}(n, shard)
+	}
+	wg.Wait()
+
+	coffees := entities.GetCoffees()
+	for n := range results {
+		if errs[n] == nil {
+			coffees = append(coffees, results[n]...)
+		}
+		entities.PutCoffees(results[n])
+	}
+	for _, err := range errs {
+		if err != nil {
+			entities.PutCoffees(coffees)
+			return nil, err
+		}
+	}
+
+	sort.Slice(coffees, func(i, j int) bool { return coffees[i].ID < coffees[j].ID })
+	return coffees, nil
+}
+
+// ring is a consistent hash ring mapping coffee IDs to shards
+type ring struct {
+	// points are the sorted hashes of the virtual nodes, owners the shard of
+	// each point
+	points []uint32
+	owners []int
+}
+
+// newRing places replicas virtual nodes of each shard on the ring
+func newRing(shards, replicas int) *ring {
+	type node struct {
+		point uint32
+		shard int
+	}
+	nodes := make([]node, 0, shards*replicas)
+	for s := 0; s < shards; s++ {
+		for v := 0; v < replicas; v++ {
+			nodes = append(nodes, node{point: hash(fmt.Sprintf("shard-%d-%d", s, v)), shard: s})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].point < nodes[j].point })
+
+	r := &ring{points: make([]uint32, len(nodes)), owners: make([]int, len(nodes))}
+	for n, node := range nodes {
+		r.points[n], r.owners[n] = node.point, node.shard
+	}
+	return r
+}
+
+// shard returns the shard owning id, the one of the first point at or after
+// the hash of id
+func (r *ring) shard(id int) int {
+	h := hash(strconv.Itoa(id))
+	n := sort.Search(len(r.points), func(n int) bool { return r.points[n] >= h })
+	if n == len(r.points) {
+		n = 0
+	}
+	return r.owners[n]
+}
+
+// hash places key on the ring. Like ketama it takes the first bytes of the
+// MD5 sum, faster hashes such as FNV cluster short keys like small IDs.
+func hash(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func setupSharded(t *testing.T, shards int) *ShardedRepository {
+	r, err := NewSharded(&config.Config{Logger: hclog.NewNullLogger()}, shards)
+	require.NoError(t, err)
+	return r
+}
+
+func TestShardedRepositoryConformance(t *testing.T) {
+	testRepositoryConformance(t, setupSharded(t, 3))
+}
+
+func TestShardedPartitionsCoffeesByID(t *testing.T) {
+	ctx := context.Background()
+	r := setupSharded(t, 3)
+
+	for n := 0; n < 20; n++ {
+		require.NoError(t, r.CreateCoffee(ctx, &entities.Coffee{Name: "Generated", Price: 200}))
+	}
+
+	total := 0
+	for n, shard := range r.shards {
+		coffees, err := shard.Find(ctx)
+		require.NoError(t, err)
+		assert.NotEmpty(t, coffees, "shard %d", n)
+		for _, c := range coffees {
+			assert.Equal(t, n, r.ring.shard(c.ID), "coffee %d", c.ID)
+		}
+		total += len(coffees)
+	}
+	assert.Equal(t, 26, total)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, coffees, 26)
+	for n := range coffees {
+		assert.Equal(t, n+1, coffees[n].ID)
+	}
+}
+
+func TestShardedSlugsAreUniqueAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	r := setupSharded(t, 3)
+
+	slugs := map[string]int{}
+	coffees := []*entities.Coffee{}
+	for n := 0; n < 20; n++ {
+		coffee := &entities.Coffee{Name: "Generated", Price: 200}
+		require.NoError(t, r.CreateCoffee(ctx, coffee))
+		assert.NotContains(t, slugs, coffee.Slug)
+		slugs[coffee.Slug] = coffee.ID
+		coffees = append(coffees, coffee)
+	}
+
+	// renaming to a taken name suffixes the slug, whichever shard holds it
+	renamed := coffees[len(coffees)-1]
+	renamed.Name = "Vaulatte"
+	require.NoError(t, r.UpdateCoffee(ctx, renamed))
+	assert.Equal(t, "vaulatte-2", renamed.Slug)
+
+	for slug, id := range slugs {
+		if id == renamed.ID {
+			continue
+		}
+		found, err := FindBySlug(ctx, r, slug)
+		require.NoError(t, err)
+		assert.Equal(t, id, found.ID)
+	}
+	found, err := FindBySlug(ctx, r, "vaulatte")
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.ID)
+}
+
+func TestShardedFindWhereByIDOnlyReadsTheOwner(t *testing.T) {
+	ctx := context.Background()
+	r := setupSharded(t, 3)
+
+	// a stray copy the router must not read
+	stray := (r.ring.shard(2) + 1) % len(r.shards)
+	require.NoError(t, r.shards[stray].createCoffee(ctx, &entities.Coffee{Name: "Stray", Price: 200}, 2, ""))
+
+	expr, err := filter.Parse("id=2")
+	require.NoError(t, err)
+	coffees, err := r.FindWhere(ctx, expr)
+	require.NoError(t, err)
+	require.Len(t, coffees, 1)
+	assert.Equal(t, "Vaulatte", coffees[0].Name)
+}
+
+func TestRingMovesFewKeysWhenAShardIsAdded(t *testing.T) {
+	before, after := newRing(3, virtualNodes), newRing(4, virtualNodes)
+
+	moved := 0
+	for id := 1; id <= 1000; id++ {
+		if from, to := before.shard(id), after.shard(id); from != to {
+			assert.Equal(t, 3, to, "key %d moved between existing shards", id)
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 100)
+	assert.Less(t, moved, 400)
+}
+
+func TestShardedRoutesTheCapabilitiesOfCoffeesToTheirShard(t *testing.T) {
+	ctx := context.Background()
+	r := setupSharded(t, 3)
+
+	ids := []int{1, 2, 3, 4, 5, 6}
+	for _, id := range ids {
+		require.NoError(t, SetTranslation(ctx, r, &entities.Translation{CoffeeID: id, Locale: "fr", Field: TranslationName, Value: "Café"}))
+		require.NoError(t, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: id, Size: ImageFull, URL: "/coffee.png", Width: 1200, Height: 800}))
+		require.NoError(t, SetAvailability(ctx, r, id, entities.AvailabilityRules{{From: "07:00", To: "11:00"}}))
+	}
+	translations, err := FindTranslations(ctx, r, ids, nil)
+	require.NoError(t, err)
+	assert.Len(t, translations, 6)
+	images, err := FindImages(ctx, r, ids)
+	require.NoError(t, err)
+	assert.Len(t, images, 6)
+	rules, err := FindAvailability(ctx, r, ids)
+	require.NoError(t, err)
+	assert.Len(t, rules, 6)
+	require.NoError(t, DeleteTranslation(ctx, r, 2, "fr", TranslationName))
+	assert.Equal(t, ErrNotFound, SetImage(ctx, r, &entities.CoffeeImage{CoffeeID: 42, Size: ImageFull, URL: "/missing.png", Width: 1, Height: 1}))
+
+	// the menus of the stores reference the coffees of every shard
+	menu, err := FindStoreCoffees(ctx, r, 1)
+	require.NoError(t, err)
+	assert.Equal(t, ids, menu)
+
+	stats, err := AggregateStats(ctx, r, time.Time{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Coffees)
+	coupons, err := FindCoupons(ctx, r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, coupons)
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// SimilarFinder is implemented by repositories with an index of the trigrams
+// of coffee names, finding similar names without reading every coffee
+type SimilarFinder interface {
+	FindSimilar(ctx context.Context, name string, threshold float64) (entities.Coffees, error)
+}
+
+// FindSimilar returns the coffees whose name has a NameSimilarity of at least
+// threshold to name, most similar first. A SimilarFinder looks them up in its
+// index, other repositories compare every coffee.
+func FindSimilar(ctx context.Context, r Repository, name string, threshold float64) (entities.Coffees, error) {
+	if finder, ok := r.(SimilarFinder); ok {
+		return finder.FindSimilar(ctx, name, threshold)
+	}
+
+	coffees, err := r.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer entities.PutCoffees(coffees)
+
+	similar := entities.Coffees{}
+	for _, c := range coffees {
+		if NameSimilarity(name, c.Name) >= threshold {
+			similar = append(similar, c)
+		}
+	}
+	sortBySimilarity(name, similar)
+	return similar, nil
+}
+
+// NormalizeName lower cases a coffee name and separates its words by single
+// spaces, dropping punctuation, so "Vaulatte!" and "  vaulatte" compare equal
+func NormalizeName(name string) string {
+	return strings.Join(nameWords(name), " ")
+}
+
+// NameSimilarity returns the share of trigrams two names have in common, from
+// 0 for names without common trigrams to 1 for names equal once normalized.
+// It matches the similarity function of the Postgres pg_trgm extension.
+func NameSimilarity(a, b string) float64 {
+	ta, tb := nameTrigrams(a), nameTrigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// nameWords splits a name into lower case words of letters and digits
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameTrigrams returns the set of trigrams of a name. Like pg_trgm every word
+// is padded with two spaces in front and one behind, so short words and word
+// starts count.
+func nameTrigrams(name string) map[string]bool {
+	trigrams := map[string]bool{}
+	for _, word := range nameWords(name) {
+		padded := []rune("  " + word + " ")
+		for n := 0; n+3 <= len(padded); n++ {
+			trigrams[string(padded[n:n+3])] = true
+		}
+	}
+	return trigrams
+}
+
+// sortBySimilarity orders coffees by their similarity to name, most similar
+// first and by ID among equals
+func sortBySimilarity(name string, coffees entities.Coffees) {
+	similarity := make(map[int]float64, len(coffees))
+	for _, c := range coffees {
+		similarity[c.ID] = NameSimilarity(name, c.Name)
+	}
+	sort.SliceStable(coffees, func(i, j int) bool {
+		if similarity[coffees[i].ID] != similarity[coffees[j].ID] {
+			return similarity[coffees[i].ID] > similarity[coffees[j].ID]
+		}
+		return coffees[i].ID < coffees[j].ID
+	})
+}
+
+// trigramIndex is a memdb indexer of the trigrams of a string field, each row
+// is indexed under every trigram of the field
+type trigramIndex struct {
+	Field string
+}
+
+// FromObject returns the trigrams of the field of obj
+func (t *trigramIndex) FromObject(obj interface{}) (bool, [][]byte, error) {
+	ok, value, err := (&memdb.StringFieldIndex{Field: t.Field}).FromObject(obj)
+	if !ok || err != nil {
+		return ok, nil, err
+	}
+
+	trigrams := nameTrigrams(strings.TrimSuffix(string(value), "\x00"))
+	if len(trigrams) == 0 {
+		return false, nil, nil
+	}
+	values := make([][]byte, 0, len(trigrams))
+	for trigram := range trigrams {
+		values = append(values, []byte(trigram+"\x00"))
+	}
+	return true, values, nil
+}
+
+// FromArgs returns the key of a single trigram
+func (t *trigramIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Field: t.Field}).FromArgs(args...)
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "vaulatte", NormalizeName("  Vaulatte!"))
+	assert.Equal(t, "packer spiced latte", NormalizeName("Packer-Spiced   LATTE"))
+	assert.Equal(t, "café 2", NormalizeName("Café #2"))
+	assert.Equal(t, "", NormalizeName(" -- "))
+}
+
+func TestNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, NameSimilarity("Vaulatte", "vaulatte!"))
+	assert.Equal(t, 0.7, NameSimilarity("Vaulatte", "Vaulate"))
+	assert.InDelta(t, 0.27, NameSimilarity("Vaulatte", "Oat Latte"), 0.01)
+	assert.Equal(t, 0.0, NameSimilarity("Vaulatte", "Mocha"))
+	assert.Equal(t, 0.0, NameSimilarity("Vaulatte", ""))
+}
+
+// testFindSimilar verifies a Repository holding the seed data finds the
+// coffees with names similar to a new name
+func testFindSimilar(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	similar, err := FindSimilar(ctx, r, "vaulate", 0.6)
+	require.NoError(t, err)
+	require.Len(t, similar, 1)
+	assert.Equal(t, "Vaulatte", similar[0].Name)
+	assert.NotEmpty(t, similar[0].Ingredients)
+
+	similar, err = FindSimilar(ctx, r, "Oat Milk Flat White", 0.6)
+	require.NoError(t, err)
+	assert.Empty(t, similar)
+
+	// most similar first
+	require.NoError(t, r.CreateCoffee(ctx, &entities.Coffee{Name: "Vaulatte Grande", Price: 200}))
+	similar, err = FindSimilar(ctx, r, "Vaulatte", 0.5)
+	require.NoError(t, err)
+	require.Len(t, similar, 2)
+	assert.Equal(t, "Vaulatte", similar[0].Name)
+	assert.Equal(t, "Vaulatte Grande", similar[1].Name)
+
+	// the index follows renames and deletes
+	similar[1].Name = "Cold Brew"
+	require.NoError(t, r.UpdateCoffee(ctx, &similar[1]))
+	require.NoError(t, r.DeleteCoffee(ctx, similar[0].ID))
+	similar, err = FindSimilar(ctx, r, "Vaulatte", 0.5)
+	require.NoError(t, err)
+	assert.Empty(t, similar)
+}
+
+func TestInMemoryFindSimilar(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testFindSimilar(t, r)
+}
+
+// allCoffees hides the SimilarFinder of a repository
+type allCoffees struct {
+	Repository
+}
+
+func TestFindSimilarComparesEveryCoffee(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testFindSimilar(t, allCoffees{r})
+}
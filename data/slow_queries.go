@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type slowQueriesKey struct{}
+
+// SlowQuery is a repository call which took at least the threshold of a
+// SlowQueryLog. Its arguments are sanitized, only numbers, booleans, times
+// and NULL are kept while strings, bytes and lists are reduced to their
+// length.
+type SlowQuery struct {
+	Query      string    `json:"query"`
+	Args       []string  `json:"args"`
+	DurationMs float64   `json:"duration_ms"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// SlowQueryLog keeps the last repository calls exceeding a threshold, the
+// oldest calls are dropped once it is full. It is safe for concurrent use.
+type SlowQueryLog struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	queries []SlowQuery
+	// next is the position of the next call in queries, which wraps around
+	// once the log is full
+	next int
+	full bool
+}
+
+// NewSlowQueryLog creates a log keeping the last size calls taking at least
+// threshold
+func NewSlowQueryLog(threshold time.Duration, size int) *SlowQueryLog {
+	return &SlowQueryLog{threshold: threshold, queries: make([]SlowQuery, size)}
+}
+
+// Queries returns the calls kept, the most recent first
+func (l *SlowQueryLog) Queries() []SlowQuery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.queries)
+	}
+	queries := make([]SlowQuery, 0, n)
+	for i := 1; i <= n; i++ {
+		queries = append(queries, l.queries[(l.next-i+len(l.queries))%len(l.queries)])
+	}
+	return queries
+}
+
+// Reset drops every call kept
+func (l *SlowQueryLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.queries = make([]SlowQuery, len(l.queries))
+	l.next, l.full = 0, false
+}
+
+// add keeps a call, replacing the oldest one when the log is full
+func (l *SlowQueryLog) add(query SlowQuery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.queries[l.next] = query
+	l.next = (l.next + 1) % len(l.queries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// slowQueries is the log of the slow calls made with a context, and the
+// trace they belong to
+type slowQueries struct {
+	log     *SlowQueryLog
+	traceID func(ctx context.Context) string
+}
+
+// WithSlowQueryLog returns a context keeping every repository call made with
+// it which exceeds the threshold of log. traceID returns the ID of the trace
+// of the call, it is only called for slow calls.
+func WithSlowQueryLog(ctx context.Context, log *SlowQueryLog, traceID func(ctx context.Context) string) context.Context {
+	return context.WithValue(ctx, slowQueriesKey{}, &slowQueries{log, traceID})
+}
+
+// recordSlowQuery keeps a call which took elapsed in the log of ctx, if any
+// and when it exceeds the threshold
+func recordSlowQuery(ctx context.Context, elapsed time.Duration, query string, args []interface{}) {
+	slow, ok := ctx.Value(slowQueriesKey{}).(*slowQueries)
+	if !ok || elapsed < slow.log.threshold {
+		return
+	}
+
+	sanitized := make([]string, len(args))
+	for n, arg := range args {
+		sanitized[n] = sanitizeArg(arg)
+	}
+	slow.log.add(SlowQuery{
+		Query:      strings.Join(strings.Fields(query), " "),
+		Args:       sanitized,
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+		TraceID:    slow.traceID(ctx),
+		Time:       time.Now().UTC(),
+	})
+}
+
+// sanitizeArg formats a query argument without revealing text, which may be
+// personal data, keeping only the values which identify rows or ranges
+func sanitizeArg(arg interface{}) string {
+	if t, ok := arg.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	v := reflect.ValueOf(arg)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "NULL"
+		}
+		return sanitizeArg(v.Elem().Interface())
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "NULL"
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(arg)
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("<%s len=%d>", v.Type(), v.Len())
+	default:
+		return fmt.Sprintf("<%s>", v.Type())
+	}
+}
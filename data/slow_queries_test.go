@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func TestSlowQueryLogKeepsTheLastCalls(t *testing.T) {
+	log := NewSlowQueryLog(0, 2)
+	assert.Empty(t, log.Queries())
+
+	for _, query := range []string{"first", "second", "third"} {
+		log.add(SlowQuery{Query: query})
+	}
+	queries := log.Queries()
+	require.Len(t, queries, 2)
+	assert.Equal(t, "third", queries[0].Query)
+	assert.Equal(t, "second", queries[1].Query)
+
+	log.Reset()
+	assert.Empty(t, log.Queries())
+}
+
+func TestSlowQueriesAreCorrelatedToTheirTrace(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	log := NewSlowQueryLog(0, 10)
+	ctx := WithSlowQueryLog(context.Background(), log, func(context.Context) string { return "4bf92f3577b34da6" })
+	_, err = r.FindByID(ctx, 1)
+	require.NoError(t, err)
+
+	queries := log.Queries()
+	require.NotEmpty(t, queries)
+	assert.Equal(t, "4bf92f3577b34da6", queries[0].TraceID)
+	assert.NotEmpty(t, queries[0].Query)
+
+	// calls below the threshold, or without a log, are not kept
+	log = NewSlowQueryLog(time.Hour, 10)
+	_, err = r.FindByID(WithSlowQueryLog(context.Background(), log, nil), 1)
+	require.NoError(t, err)
+	assert.Empty(t, log.Queries())
+}
+
+func TestSanitizeArg(t *testing.T) {
+	storeID := 2
+	var missing *int
+	for arg, expected := range map[interface{}]string{
+		nil:        "NULL",
+		42:         "42",
+		int64(7):   "7",
+		1.5:        "1.5",
+		true:       "true",
+		"auth0|42": "<string len=8>",
+		&storeID:   "2",
+		missing:    "NULL",
+		time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC): "2020-10-01T12:00:00Z",
+	} {
+		assert.Equal(t, expected, sanitizeArg(arg), arg)
+	}
+	assert.Equal(t, "<[]int64 len=3>", sanitizeArg([]int64{1, 2, 3}))
+	assert.Equal(t, "<[]uint8 len=4>", sanitizeArg([]byte("card")))
+}
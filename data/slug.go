@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// reservedSlugs are the static routes next to /coffees/{id}, which a coffee
+// with the same slug would never be reached at
+var reservedSlugs = map[string]bool{"trending": true, "suggest": true}
+
+// SlugFinder is implemented by repositories with an index of the coffee slugs
+type SlugFinder interface {
+	FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error)
+}
+
+// FindBySlug returns the coffee with the slug, or ErrNotFound. A SlugFinder
+// looks it up in its index, other repositories compare every coffee.
+func FindBySlug(ctx context.Context, r Repository, slug string) (*entities.Coffee, error) {
+	if finder, ok := r.(SlugFinder); ok {
+		return finder.FindBySlug(ctx, slug)
+	}
+
+	coffees, err := r.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer entities.PutCoffees(coffees)
+
+	for _, c := range coffees {
+		if c.Slug == slug {
+			coffee := c
+			return &coffee, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Slugify returns the URL safe slug of a coffee name, its words in lower case
+// ASCII letters and digits joined by hyphens, e.g. packer-spiced-latte.
+// Accents are dropped rather than the letters carrying them, a name without
+// letters or digits becomes coffee, and one of only digits is prefixed with
+// coffee- so it is never taken for an ID.
+func Slugify(name string) string {
+	folded := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(strings.ToLower(name)))
+	words := strings.FieldsFunc(folded, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+
+	slug := strings.Join(words, "-")
+	if slug == "" {
+		return "coffee"
+	}
+	if strings.Trim(slug, "0123456789-") == "" {
+		return "coffee-" + slug
+	}
+	return slug
+}
+
+// slugFor returns the slug of a coffee named name. Renaming a coffee only
+// changes its slug when the new name has a different slug, so a change of
+// case or punctuation keeps the links to it working. New slugs taken by
+// another coffee or reserved are suffixed with -2, -3 and so on until one is
+// free. previous is the coffee before a rename, nil on creation.
+func slugFor(previous *entities.Coffee, name string, taken func(slug string) (bool, error)) (string, error) {
+	base := Slugify(name)
+	if previous != nil && previous.Slug != "" && Slugify(previous.Name) == base {
+		return previous.Slug, nil
+	}
+
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = base + "-" + strconv.Itoa(n)
+		}
+		if reservedSlugs[slug] {
+			continue
+		}
+		used, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return slug, nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestSlugify(t *testing.T) {
+	for name, slug := range map[string]string{
+		"Packer Spiced Latte": "packer-spiced-latte",
+		"Vagrante espresso":   "vagrante-espresso",
+		"  Café -- Crème!! ":  "cafe-creme",
+		"Espresso'":           "espresso",
+		"#1":                  "coffee-1",
+		"2 4 6":               "coffee-2-4-6",
+		"☕":                   "coffee",
+	} {
+		assert.Equal(t, slug, Slugify(name), name)
+	}
+}
+
+// testSlugs verifies a Repository holding the seed data gives every coffee a
+// unique slug it can be found by
+func testSlugs(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	coffee, err := FindBySlug(ctx, r, "packer-spiced-latte")
+	require.NoError(t, err)
+	assert.Equal(t, 1, coffee.ID)
+	assert.NotEmpty(t, coffee.Ingredients)
+	_, err = FindBySlug(ctx, r, "unknown")
+	assert.Equal(t, ErrNotFound, err)
+
+	// taken and reserved slugs are suffixed
+	vaulatte := &entities.Coffee{Name: "VAULATTE!", Price: 200}
+	require.NoError(t, r.CreateCoffee(ctx, vaulatte))
+	assert.Equal(t, "vaulatte-2", vaulatte.Slug)
+	trending := &entities.Coffee{Name: "Trending", Price: 200}
+	require.NoError(t, r.CreateCoffee(ctx, trending))
+	assert.Equal(t, "trending-2", trending.Slug)
+
+	// a rename keeps the slug while the name slugifies the same
+	vaulatte.Name = "Vaulatte"
+	require.NoError(t, r.UpdateCoffee(ctx, vaulatte))
+	assert.Equal(t, "vaulatte-2", vaulatte.Slug)
+
+	vaulatte.Name = "Vault Latte"
+	require.NoError(t, r.UpdateCoffee(ctx, vaulatte))
+	assert.Equal(t, "vault-latte", vaulatte.Slug)
+	coffee, err = FindBySlug(ctx, r, "vault-latte")
+	require.NoError(t, err)
+	assert.Equal(t, vaulatte.ID, coffee.ID)
+	_, err = FindBySlug(ctx, r, "vaulatte-2")
+	assert.Equal(t, ErrNotFound, err)
+
+	// a freed slug is handed out again
+	another := &entities.Coffee{Name: "Vaulatte", Price: 200}
+	require.NoError(t, r.CreateCoffee(ctx, another))
+	assert.Equal(t, "vaulatte-2", another.Slug)
+}
+
+func TestInMemorySlugs(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testSlugs(t, r)
+}
+
+// anySlug hides the SlugFinder of a repository
+type anySlug struct {
+	Repository
+}
+
+func TestFindBySlugComparesEveryCoffee(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testSlugs(t, anySlug{r})
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// ErrSnapshotExpired is returned for reads from a snapshot which was released
+// or never pinned
+var ErrSnapshotExpired = errors.New("read snapshot expired")
+
+type readSnapshotKey struct{}
+
+// ReadSnapshot selects the in memory snapshot the reads of a request are
+// served from. Without a token the first list read pins a snapshot of the
+// database and names it with a new token, which later requests pass to read
+// the same snapshot. It is safe for concurrent use.
+type ReadSnapshot struct {
+	mu      sync.Mutex
+	token   string
+	expired bool
+}
+
+// WithReadSnapshot returns a context whose in memory reads are served from
+// the snapshot named by token, or which pins a new snapshot when token is
+// empty
+func WithReadSnapshot(ctx context.Context, token string) (context.Context, *ReadSnapshot) {
+	snapshot := &ReadSnapshot{token: token}
+	return context.WithValue(ctx, readSnapshotKey{}, snapshot), snapshot
+}
+
+// ReadSnapshotFromContext returns the snapshot attached to the context, or
+// nil when reads are served from the live database
+func ReadSnapshotFromContext(ctx context.Context) *ReadSnapshot {
+	snapshot, _ := ctx.Value(readSnapshotKey{}).(*ReadSnapshot)
+	return snapshot
+}
+
+// Token returns the token of the snapshot, empty when no snapshot was pinned
+func (s *ReadSnapshot) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// Expired reports whether a read failed as the snapshot was released
+func (s *ReadSnapshot) Expired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expired
+}
+
+// snapshotPins are the memdb snapshots pinned for ReadSnapshots. Each one is
+// released ttl after it was pinned, snapshots share the unchanged parts of the
+// database so pinning is cheap.
+type snapshotPins struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	pinned map[string]pinnedSnapshot
+	// order lists the tokens as they were pinned, which is the order they
+	// expire in as every snapshot is kept for the same ttl
+	order []string
+}
+
+// pinnedSnapshot is a memdb snapshot and the time it is released
+type pinnedSnapshot struct {
+	db      *memdb.MemDB
+	expires time.Time
+}
+
+// newSnapshotPins creates pins released after ttl
+func newSnapshotPins(ttl time.Duration) *snapshotPins {
+	return &snapshotPins{ttl: ttl, now: time.Now, pinned: map[string]pinnedSnapshot{}}
+}
+
+// pin keeps db until the ttl elapses and returns its token
+func (p *snapshotPins) pin(db *memdb.MemDB) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.release()
+	p.pinned[token] = pinnedSnapshot{db: db, expires: p.now().Add(p.ttl)}
+	p.order = append(p.order, token)
+	return token, nil
+}
+
+// get returns the snapshot named by token unless it was released
+func (p *snapshotPins) get(token string) (*memdb.MemDB, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.release()
+	pinned, ok := p.pinned[token]
+	return pinned.db, ok
+}
+
+// release drops the expired snapshots, the caller holds mu
+func (p *snapshotPins) release() {
+	now := p.now()
+	for len(p.order) > 0 && !now.Before(p.pinned[p.order[0]].expires) {
+		delete(p.pinned, p.order[0])
+		p.order = p.order[1:]
+	}
+}
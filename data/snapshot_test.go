@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupSnapshots(t *testing.T) *InMemoryRepository {
+	repository, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), SnapshotTTL: time.Minute})
+	require.NoError(t, err)
+	return repository.(*InMemoryRepository)
+}
+
+func TestListReadsPinASnapshot(t *testing.T) {
+	repository := setupSnapshots(t)
+
+	ctx, snapshot := WithReadSnapshot(context.Background(), "")
+	coffees, err := repository.Find(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, coffees)
+	assert.NotEmpty(t, snapshot.Token())
+	assert.False(t, snapshot.Expired())
+
+	ctx, snapshot = WithReadSnapshot(context.Background(), "")
+	_, err = repository.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, snapshot.Token(), "detail reads do not pin a snapshot")
+}
+
+func TestSnapshotReadsDoNotSeeLaterWrites(t *testing.T) {
+	repository := setupSnapshots(t)
+
+	ctx, snapshot := WithReadSnapshot(context.Background(), "")
+	listed, err := repository.Find(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, repository.DeleteCoffee(context.Background(), 2))
+	require.NoError(t, repository.CreateCoffee(context.Background(), &entities.Coffee{Name: "Late Latte", Price: 200}))
+
+	ctx, _ = WithReadSnapshot(context.Background(), snapshot.Token())
+	coffee, err := repository.FindByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, listed[1].Name, coffee.Name)
+
+	relisted, err := repository.Find(ctx)
+	require.NoError(t, err)
+	assert.Len(t, relisted, len(listed))
+
+	_, err = repository.FindByID(context.Background(), 2)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestExpiredSnapshotsAreReleased(t *testing.T) {
+	repository := setupSnapshots(t)
+	now := time.Now()
+	repository.pins.now = func() time.Time { return now }
+
+	ctx, snapshot := WithReadSnapshot(context.Background(), "")
+	_, err := repository.Find(ctx)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	ctx, expired := WithReadSnapshot(context.Background(), snapshot.Token())
+	_, err = repository.FindByID(ctx, 1)
+	assert.Equal(t, ErrSnapshotExpired, err)
+	assert.True(t, expired.Expired())
+	assert.Empty(t, repository.pins.pinned)
+	assert.Empty(t, repository.pins.order)
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// maxStatements caps the number of prepared statements kept. Queries built at
+// runtime, e.g. from filters, would otherwise grow the cache without bound,
+// further queries run without being prepared.
+const maxStatements = 64
+
+// statement runs a query with its arguments, prepared or not
+type statement interface {
+	SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, args ...interface{}) error
+}
+
+// unprepared runs a query without preparing it first
+type unprepared struct {
+	q     sqlx.QueryerContext
+	query string
+}
+
+// SelectContext runs the query returning rows
+func (u unprepared) SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	return sqlx.SelectContext(ctx, u.q, dest, u.query, args...)
+}
+
+// GetContext runs the query returning a single row
+func (u unprepared) GetContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	return sqlx.GetContext(ctx, u.q, dest, u.query, args...)
+}
+
+// statementCache prepares every query once and reuses the prepared statement
+// for later calls, so Postgres parses and plans hot queries only once per
+// connection. It records db.statement.prepare samples and db.statement.cache
+// counters by result: hit, miss or full.
+type statementCache struct {
+	db      *sqlx.DB
+	metrics metrics.Sink
+
+	mu         sync.Mutex
+	statements map[string]*sqlx.Stmt
+}
+
+// newStatementCache creates an empty statementCache preparing statements on db
+func newStatementCache(db *sqlx.DB, sink metrics.Sink) *statementCache {
+	return &statementCache{db: db, metrics: sink, statements: map[string]*sqlx.Stmt{}}
+}
+
+// get returns the prepared statement of query, preparing it on first use. It
+// returns nil when the cache is nil or full, the query then runs unprepared.
+func (c *statementCache) get(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	stmt, ok := c.statements[query]
+	full := len(c.statements) >= maxStatements
+	c.mu.Unlock()
+
+	if ok {
+		c.metrics.IncrCounter("db.statement.cache", 1, metrics.Label{Name: "result", Value: "hit"})
+		RecordCacheLookup(ctx, "statements", "", "hit")
+		return stmt, nil
+	}
+	if full {
+		c.metrics.IncrCounter("db.statement.cache", 1, metrics.Label{Name: "result", Value: "full"})
+		RecordCacheLookup(ctx, "statements", "", "full")
+		return nil, nil
+	}
+
+	start := time.Now()
+	stmt, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	metrics.MeasureSince(c.metrics, "db.statement.prepare", start)
+	c.metrics.IncrCounter("db.statement.cache", 1, metrics.Label{Name: "result", Value: "miss"})
+	RecordCacheLookup(ctx, "statements", "", "miss")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another request prepared the same query meanwhile
+	if existing, ok := c.statements[query]; ok {
+		stmt.Close()
+		return existing, nil
+	}
+	c.statements[query] = stmt
+
+	return stmt, nil
+}
+
+// close closes every prepared statement
+func (c *statementCache) close() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for query, stmt := range c.statements {
+		stmt.Close()
+		delete(c.statements, query)
+	}
+}
+
+// withStatement runs fn with the prepared statement of query, or with the
+// query unprepared when statements are not cached. Queries with a deadline run
+// in a transaction limiting them to the deadline. The time spent is recorded
+// in db.statement.execute samples labelled by whether the query was prepared.
+func (r *PostgresRepository) withStatement(ctx context.Context, query string, fn func(statement) error) (err error) {
+	defer func() { r.checkConnection(err) }()
+
+	db, statements := r.pool()
+	stmt, err := statements.get(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	prepared := metrics.Label{Name: "prepared", Value: strconv.FormatBool(stmt != nil)}
+	defer metrics.MeasureSince(r.metrics, "db.statement.execute", time.Now(), prepared)
+
+	if _, ok := statementTimeout(ctx); ok {
+		return r.inTx(ctx, func(tx *sqlx.Tx) error {
+			if stmt == nil {
+				return fn(unprepared{tx, query})
+			}
+			return fn(tx.StmtxContext(ctx, stmt))
+		})
+	}
+
+	if stmt == nil {
+		return fn(unprepared{db, query})
+	}
+	return fn(stmt)
+}
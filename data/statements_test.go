@@ -0,0 +1,18 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNilStatementCacheRunsQueriesUnprepared(t *testing.T) {
+	var c *statementCache
+
+	stmt, err := c.get(context.Background(), "SELECT * FROM coffee")
+
+	assert.NoError(t, err)
+	assert.Nil(t, stmt)
+	c.close()
+}
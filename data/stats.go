@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type queryStatsKey struct{}
+
+// QueryStats accumulates the number and total duration of the database
+// queries issued while serving a single request. It is safe for concurrent use.
+type QueryStats struct {
+	count    int64
+	duration int64
+}
+
+// WithQueryStats returns a context which collects the statistics of every
+// repository query made with it
+func WithQueryStats(ctx context.Context) (context.Context, *QueryStats) {
+	stats := &QueryStats{}
+	return context.WithValue(ctx, queryStatsKey{}, stats), stats
+}
+
+// QueryStatsFromContext returns the collector attached to the context, or
+// nil when statistics are not being collected
+func QueryStatsFromContext(ctx context.Context) *QueryStats {
+	stats, _ := ctx.Value(queryStatsKey{}).(*QueryStats)
+	return stats
+}
+
+// Count returns the number of queries recorded
+func (s *QueryStats) Count() int64 {
+	return atomic.LoadInt64(&s.count)
+}
+
+// Duration returns the total time spent in the recorded queries
+func (s *QueryStats) Duration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.duration))
+}
+
+// recordQuery adds a query started at start to the collector and debug trace
+// in ctx, if any, and keeps it in the slow query log of ctx when it is slow. The latency
+// injected into the query counts as its own.
+func recordQuery(ctx context.Context, start time.Time, query string, args ...interface{}) {
+	injectLatency(ctx)
+	elapsed := time.Since(start)
+	recordSlowQuery(ctx, elapsed, query, args)
+	recordDebugQuery(ctx, start, elapsed, query, args)
+
+	stats := QueryStatsFromContext(ctx)
+	if stats == nil {
+		return
+	}
+
+	atomic.AddInt64(&stats.count, 1)
+	atomic.AddInt64(&stats.duration, int64(elapsed))
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"errors"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// The publishing states of a coffee
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusRetired   = "retired"
+)
+
+// ErrInvalidStatus is returned for a coffee status other than draft,
+// published or retired
+var ErrInvalidStatus = errors.New("status must be draft, published or retired")
+
+// ErrInvalidTransition is returned when a coffee cannot move from its status
+// to the requested one
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transitions are the statuses a coffee can move to from each status. A draft
+// is published or abandoned, a published coffee is retired, and a retired one
+// goes back to draft to be reworked.
+var transitions = map[string][]string{
+	StatusDraft:     {StatusPublished, StatusRetired},
+	StatusPublished: {StatusRetired},
+	StatusRetired:   {StatusDraft},
+}
+
+// Transitions returns the statuses a coffee can move to from status
+func Transitions(status string) []string {
+	return transitions[status]
+}
+
+// initialStatus returns the status a coffee is created with, a draft unless
+// another one is requested
+func initialStatus(status string) (string, error) {
+	if status == "" {
+		return StatusDraft, nil
+	}
+	if _, ok := transitions[status]; !ok {
+		return "", ErrInvalidStatus
+	}
+	return status, nil
+}
+
+// nextStatus returns the status of an updated coffee. An empty status keeps
+// the previous one, any other has to be reachable from it.
+func nextStatus(previous, status string) (string, error) {
+	if status == "" || status == previous {
+		return previous, nil
+	}
+	if _, ok := transitions[status]; !ok {
+		return "", ErrInvalidStatus
+	}
+	for _, allowed := range transitions[previous] {
+		if allowed == status {
+			return status, nil
+		}
+	}
+	return "", ErrInvalidTransition
+}
+
+// publishedFilter selects the published coffees
+var publishedFilter = &filter.Comparison{Field: filter.Fields["status"], Op: filter.Eq, Value: StatusPublished}
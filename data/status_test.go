@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+func TestNextStatus(t *testing.T) {
+	for _, tc := range []struct {
+		previous, requested, next string
+		err                       error
+	}{
+		{StatusDraft, "", StatusDraft, nil},
+		{StatusDraft, StatusDraft, StatusDraft, nil},
+		{StatusDraft, StatusPublished, StatusPublished, nil},
+		{StatusDraft, StatusRetired, StatusRetired, nil},
+		{StatusPublished, StatusRetired, StatusRetired, nil},
+		{StatusPublished, StatusDraft, "", ErrInvalidTransition},
+		{StatusRetired, StatusPublished, "", ErrInvalidTransition},
+		{StatusRetired, StatusDraft, StatusDraft, nil},
+		{StatusDraft, "archived", "", ErrInvalidStatus},
+	} {
+		next, err := nextStatus(tc.previous, tc.requested)
+		assert.Equal(t, tc.err, err, "%s to %s", tc.previous, tc.requested)
+		assert.Equal(t, tc.next, next, "%s to %s", tc.previous, tc.requested)
+	}
+}
+
+// testStatuses verifies a Repository holding the seed data creates drafts and
+// only moves coffees between statuses along the allowed transitions
+func testStatuses(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	seeded, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPublished, seeded.Status)
+
+	draft := &entities.Coffee{Name: "Sentinel Cold Brew", Price: 300}
+	require.NoError(t, r.CreateCoffee(ctx, draft))
+	assert.Equal(t, StatusDraft, draft.Status)
+	assert.Equal(t, ErrInvalidStatus, r.CreateCoffee(ctx, &entities.Coffee{Name: "Archived Affogato", Price: 200, Status: "archived"}))
+
+	// an update without a status keeps it
+	draft.Price = 320
+	draft.Status = ""
+	require.NoError(t, r.UpdateCoffee(ctx, draft))
+	assert.Equal(t, StatusDraft, draft.Status)
+
+	draft.Status = StatusPublished
+	require.NoError(t, r.UpdateCoffee(ctx, draft))
+	assert.Equal(t, StatusPublished, draft.Status)
+
+	draft.Status = StatusDraft
+	assert.Equal(t, ErrInvalidTransition, r.UpdateCoffee(ctx, draft))
+	stored, err := r.FindByID(ctx, draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPublished, stored.Status)
+
+	stored.Status = StatusRetired
+	require.NoError(t, r.UpdateCoffee(ctx, stored))
+	expr, err := filter.Parse(`status="retired"`)
+	require.NoError(t, err)
+	retired, err := r.FindWhere(ctx, expr)
+	require.NoError(t, err)
+	require.Len(t, retired, 1)
+	assert.Equal(t, draft.ID, retired[0].ID)
+}
+
+func TestInMemoryStatuses(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testStatuses(t, r)
+}
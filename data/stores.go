@@ -0,0 +1,252 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// ErrStoresUnsupported is returned when reading the stores of a repository
+// which does not store them
+var ErrStoresUnsupported = errors.New("stores are not supported by this backend")
+
+// ErrInvalidLocation is returned for a nearby search outside the valid
+// latitudes and longitudes, or with a radius out of range
+var ErrInvalidLocation = errors.New("lat must be between -90 and 90, lon between -180 and 180 and radius_km above 0 and up to 20000")
+
+const (
+	// earthRadiusKm is the mean radius of the Earth used by the haversine
+	// formula
+	earthRadiusKm = 6371.0088
+	// maxRadiusKm is about half the circumference of the Earth, any larger
+	// radius holds every store
+	maxRadiusKm = 20000
+)
+
+// StoreFinder is implemented by repositories storing the coffee shops and the
+// coffees on the menu of each
+type StoreFinder interface {
+	// FindStores returns every store
+	FindStores(ctx context.Context) (entities.Stores, error)
+	// FindStore returns a store, or ErrNotFound
+	FindStore(ctx context.Context, storeID int) (*entities.Store, error)
+	// FindStoreCoffees returns the IDs of the coffees on the menu of a store,
+	// ErrNotFound when the store does not exist
+	FindStoreCoffees(ctx context.Context, storeID int) ([]int, error)
+	// FindStoresNear returns the stores within radiusKm of a point, nearest
+	// first, with their distance
+	FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (entities.Stores, error)
+}
+
+// FindStores returns every store of a StoreFinder, or ErrStoresUnsupported
+func FindStores(ctx context.Context, r Repository) (entities.Stores, error) {
+	var finder interface {
+		FindStores(ctx context.Context) (entities.Stores, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrStoresUnsupported
+	}
+	return finder.FindStores(ctx)
+}
+
+// FindStore returns a store of a StoreFinder, or ErrStoresUnsupported
+func FindStore(ctx context.Context, r Repository, storeID int) (*entities.Store, error) {
+	var finder interface {
+		FindStore(ctx context.Context, storeID int) (*entities.Store, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrStoresUnsupported
+	}
+	return finder.FindStore(ctx, storeID)
+}
+
+// FindStoreCoffees returns the IDs of the coffees on the menu of a store of a
+// StoreFinder, or ErrStoresUnsupported
+func FindStoreCoffees(ctx context.Context, r Repository, storeID int) ([]int, error) {
+	var finder interface {
+		FindStoreCoffees(ctx context.Context, storeID int) ([]int, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrStoresUnsupported
+	}
+	return finder.FindStoreCoffees(ctx, storeID)
+}
+
+// FindStoresNear validates a nearby search and returns the stores of a
+// StoreFinder within radiusKm of a point, nearest first
+func FindStoresNear(ctx context.Context, r Repository, lat, lon, radiusKm float64) (entities.Stores, error) {
+	var finder interface {
+		FindStoresNear(ctx context.Context, lat, lon, radiusKm float64) (entities.Stores, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrStoresUnsupported
+	}
+	// the negated comparisons reject NaN too
+	if !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) || !(radiusKm > 0 && radiusKm <= maxRadiusKm) {
+		return nil, ErrInvalidLocation
+	}
+	return finder.FindStoresNear(ctx, lat, lon, radiusKm)
+}
+
+// Haversine returns the great circle distance in kilometres between two
+// points given in degrees
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	radians := math.Pi / 180
+	dLat := (lat2 - lat1) * radians
+	dLon := (lon2 - lon1) * radians
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*radians)*math.Cos(lat2*radians)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// nearest keeps the stores within radiusKm of a point, setting their distance
+// and sorting them nearest first
+func nearest(stores entities.Stores, lat, lon, radiusKm float64) entities.Stores {
+	near := stores[:0]
+	for _, store := range stores {
+		store.DistanceKm = Haversine(lat, lon, store.Latitude, store.Longitude)
+		if store.DistanceKm <= radiusKm {
+			near = append(near, store)
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool { return near[i].DistanceKm < near[j].DistanceKm })
+	return near
+}
+
+type storeKey struct{}
+
+// WithStore returns a context whose menu reads are limited to the coffees of
+// a store
+func WithStore(ctx context.Context, storeID int) context.Context {
+	return context.WithValue(ctx, storeKey{}, storeID)
+}
+
+// StoreFromContext returns the store attached to the context, false when
+// menu reads are not limited to a store
+func StoreFromContext(ctx context.Context) (int, bool) {
+	storeID, ok := ctx.Value(storeKey{}).(int)
+	return storeID, ok
+}
+
+// StoreScopedRepository is a Repository limiting the reads of a context
+// carrying a store, see WithStore, to the coffees on the menu of that store.
+// An unknown store has an empty menu. Reads without a store, and repositories
+// without stores, are unchanged.
+type StoreScopedRepository struct {
+	Repository
+}
+
+// NewStoreScoped wraps repository to limit reads to the store of the context
+func NewStoreScoped(repository Repository) *StoreScopedRepository {
+	return &StoreScopedRepository{Repository: repository}
+}
+
+// Unwrap returns the wrapped repository
+func (r *StoreScopedRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+// Find returns the coffees of the store
+func (r *StoreScopedRepository) Find(ctx context.Context) (entities.Coffees, error) {
+	coffees, err := r.Repository.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.inStore(ctx, coffees)
+}
+
+// FindWhere returns the coffees of the store matching expr
+func (r *StoreScopedRepository) FindWhere(ctx context.Context, expr filter.Expr) (entities.Coffees, error) {
+	coffees, err := r.Repository.FindWhere(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	return r.inStore(ctx, coffees)
+}
+
+// FindByID returns a coffee of the store, or ErrNotFound
+func (r *StoreScopedRepository) FindByID(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+	coffee, err := r.Repository.FindByID(ctx, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	return r.storeCoffee(ctx, coffee)
+}
+
+// FindBySlug returns the coffee of the store with the slug, or ErrNotFound
+func (r *StoreScopedRepository) FindBySlug(ctx context.Context, slug string) (*entities.Coffee, error) {
+	coffee, err := FindBySlug(ctx, r.Repository, slug)
+	if err != nil {
+		return nil, err
+	}
+	return r.storeCoffee(ctx, coffee)
+}
+
+// FindRelated returns up to limit coffees of the store related to one of its
+// coffees. Every related coffee is ranked, so other stores' coffees never
+// shorten the list.
+func (r *StoreScopedRepository) FindRelated(ctx context.Context, coffeeID int, limit int) (entities.Coffees, error) {
+	if _, err := r.FindByID(ctx, coffeeID); err != nil {
+		return nil, err
+	}
+
+	ranked, err := r.Repository.FindRelated(ctx, coffeeID, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	related, err := r.inStore(ctx, ranked)
+	if err != nil {
+		return nil, err
+	}
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related, nil
+}
+
+// inStore keeps the coffees on the menu of the store of the context
+func (r *StoreScopedRepository) inStore(ctx context.Context, coffees entities.Coffees) (entities.Coffees, error) {
+	storeID, ok := StoreFromContext(ctx)
+	if !ok {
+		return coffees, nil
+	}
+
+	ids, err := FindStoreCoffees(ctx, r.Repository, storeID)
+	if err == ErrStoresUnsupported {
+		return coffees, nil
+	}
+	if err == ErrNotFound {
+		return entities.Coffees{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	menu := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		menu[id] = true
+	}
+
+	scoped := coffees[:0]
+	for _, coffee := range coffees {
+		if menu[coffee.ID] {
+			scoped = append(scoped, coffee)
+		}
+	}
+	return scoped, nil
+}
+
+// storeCoffee hides a coffee which is not on the menu of the store of the
+// context
+func (r *StoreScopedRepository) storeCoffee(ctx context.Context, coffee *entities.Coffee) (*entities.Coffee, error) {
+	scoped, err := r.inStore(ctx, entities.Coffees{*coffee})
+	if err != nil {
+		return nil, err
+	}
+	if len(scoped) == 0 {
+		return nil, ErrNotFound
+	}
+	return coffee, nil
+}
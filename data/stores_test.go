@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// testStores verifies a Repository holding the seed data serves the demo
+// stores and their menus
+func testStores(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	stores, err := FindStores(ctx, r)
+	require.NoError(t, err)
+	require.Len(t, stores, 3)
+	assert.Equal(t, "Market Street", stores[0].Name)
+	assert.Equal(t, "Amsterdam", stores[1].City)
+	assert.Equal(t, "GB", stores[2].Country)
+
+	store, err := FindStore(ctx, r, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "Shoreditch", store.Name)
+	assert.InDelta(t, 51.5226, store.Latitude, 0.0001)
+	assert.InDelta(t, -0.08, store.Longitude, 0.0001)
+	_, err = FindStore(ctx, r, 42)
+	assert.Equal(t, ErrNotFound, err)
+
+	ids, err := FindStoreCoffees(ctx, r, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4, 5}, ids)
+	_, err = FindStoreCoffees(ctx, r, 42)
+	assert.Equal(t, ErrNotFound, err)
+
+	// from Dam Square
+	near, err := FindStoresNear(ctx, r, 52.3731, 4.8926, 10)
+	require.NoError(t, err)
+	require.Len(t, near, 1)
+	assert.Equal(t, "Prinsengracht", near[0].Name)
+	assert.InDelta(t, 0.6, near[0].DistanceKm, 0.1)
+	near, err = FindStoresNear(ctx, r, 52.3731, 4.8926, 400)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Prinsengracht", "Shoreditch"}, storeNames(near))
+	assert.InDelta(t, 353.6, near[1].DistanceKm, 0.5)
+	near, err = FindStoresNear(ctx, r, 52.3731, 4.8926, 20000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Prinsengracht", "Shoreditch", "Market Street"}, storeNames(near))
+	near, err = FindStoresNear(ctx, r, 0, 0, 100)
+	require.NoError(t, err)
+	assert.Empty(t, near)
+	_, err = FindStoresNear(ctx, r, 91, 0, 10)
+	assert.Equal(t, ErrInvalidLocation, err)
+	_, err = FindStoresNear(ctx, r, 0, 0, 0)
+	assert.Equal(t, ErrInvalidLocation, err)
+
+	// a deleted coffee leaves every menu
+	require.NoError(t, r.DeleteCoffee(ctx, 3))
+	ids, err = FindStoreCoffees(ctx, r, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 6}, ids)
+}
+
+// storeNames returns the names of stores in order
+func storeNames(stores entities.Stores) []string {
+	names := make([]string, len(stores))
+	for n, store := range stores {
+		names[n] = store.Name
+	}
+	return names
+}
+
+func TestHaversine(t *testing.T) {
+	// London to Paris
+	assert.InDelta(t, 343.5, Haversine(51.5074, -0.1278, 48.8566, 2.3522), 0.5)
+	assert.Equal(t, 0.0, Haversine(37.7925, -122.3966, 37.7925, -122.3966))
+	// antipodes are half the circumference apart
+	assert.InDelta(t, math.Pi*earthRadiusKm, Haversine(0, 0, 0, 180), 0.001)
+}
+
+func TestInMemoryStores(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testStores(t, r)
+}
+
+func TestStoresPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testStores(t, NewStoreScoped(NewScheduled(NewPublished(NewChanges(r, 10)))))
+}
+
+func TestStoreScopedLimitsReadsToTheStore(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	menu := NewStoreScoped(r)
+	amsterdam := WithStore(context.Background(), 2)
+
+	coffees, err := menu.Find(amsterdam)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4, 5}, coffeeIDs(coffees))
+
+	expr, err := filter.Parse("price>=200")
+	require.NoError(t, err)
+	coffees, err = menu.FindWhere(amsterdam, expr)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 5}, coffeeIDs(coffees))
+
+	_, err = menu.FindByID(amsterdam, 1)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = menu.FindBySlug(amsterdam, "connectaccino")
+	assert.Equal(t, ErrNotFound, err)
+	coffee, err := menu.FindBySlug(amsterdam, "vaulatte")
+	require.NoError(t, err)
+	assert.Equal(t, 2, coffee.ID)
+
+	related, err := menu.FindRelated(amsterdam, 2, 2)
+	require.NoError(t, err)
+	assert.Len(t, related, 2)
+	assert.Subset(t, []int{3, 4, 5}, coffeeIDs(related))
+
+	// without a store every coffee is read, an unknown store has none
+	coffees, err = menu.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+	coffees, err = menu.Find(WithStore(context.Background(), 42))
+	require.NoError(t, err)
+	assert.Empty(t, coffees)
+}
+
+// storeless hides the StoreFinder of a repository
+type storeless struct {
+	Repository
+}
+
+func TestStoresNeedAStoreFinder(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	_, err = FindStores(ctx, storeless{r})
+	assert.Equal(t, ErrStoresUnsupported, err)
+
+	// menus are not scoped without stores
+	coffees, err := NewStoreScoped(storeless{r}).Find(WithStore(ctx, 2))
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+}
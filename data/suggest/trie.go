@@ -0,0 +1,160 @@
+// Package suggest implements the prefix trie backing coffee name
+// autocompletion.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Suggestion is a coffee whose name matches a prefix
+type Suggestion struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Popularity int64  `json:"popularity"`
+}
+
+type node struct {
+	children map[rune]*node
+	// ids holds the coffees whose name has a word starting with the path to
+	// this node, stored on every node so lookups don't walk the subtree
+	ids map[int]struct{}
+}
+
+func newNode() *node {
+	return &node{children: map[rune]*node{}, ids: map[int]struct{}{}}
+}
+
+// Trie indexes every word start of the coffee names so "lat" suggests
+// "Packer Spiced Latte". It is safe for concurrent use.
+type Trie struct {
+	mu         sync.RWMutex
+	root       *node
+	names      map[int]string
+	popularity map[int]int64
+}
+
+// NewTrie creates an empty Trie
+func NewTrie() *Trie {
+	return &Trie{
+		root:       newNode(),
+		names:      map[int]string{},
+		popularity: map[int]int64{},
+	}
+}
+
+// Add indexes a coffee name, replacing any previous name for the ID
+func (t *Trie) Add(id int, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.remove(id)
+
+	t.names[id] = name
+	for _, key := range keys(name) {
+		n := t.root
+		for _, r := range key {
+			child, ok := n.children[r]
+			if !ok {
+				child = newNode()
+				n.children[r] = child
+			}
+			child.ids[id] = struct{}{}
+			n = child
+		}
+	}
+}
+
+// Remove drops a coffee from the trie
+func (t *Trie) Remove(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.remove(id)
+	delete(t.popularity, id)
+}
+
+func (t *Trie) remove(id int) {
+	name, ok := t.names[id]
+	if !ok {
+		return
+	}
+
+	delete(t.names, id)
+	for _, key := range keys(name) {
+		n := t.root
+		for _, r := range key {
+			child, ok := n.children[r]
+			if !ok {
+				break
+			}
+			delete(child.ids, id)
+			if len(child.ids) == 0 {
+				delete(n.children, r)
+				break
+			}
+			n = child
+		}
+	}
+}
+
+// SetPopularity sets the ranking weight of a coffee
+func (t *Trie) SetPopularity(id int, popularity int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.popularity[id] = popularity
+}
+
+// Suggest returns up to limit coffees with a name word starting with prefix,
+// most popular first and alphabetically between equally popular coffees.
+func (t *Trie) Suggest(prefix string, limit int) []Suggestion {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n := t.root
+	for _, r := range strings.ToLower(prefix) {
+		child, ok := n.children[r]
+		if !ok {
+			return []Suggestion{}
+		}
+		n = child
+	}
+
+	suggestions := make([]Suggestion, 0, len(n.ids))
+	for id := range n.ids {
+		suggestions = append(suggestions, Suggestion{ID: id, Name: t.names[id], Popularity: t.popularity[id]})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Popularity != suggestions[j].Popularity {
+			return suggestions[i].Popularity > suggestions[j].Popularity
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions
+}
+
+// keys returns the lower cased name from each word start onwards
+func keys(name string) []string {
+	lower := []rune(strings.ToLower(name))
+
+	k := make([]string, 0)
+	for i, r := range lower {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if i == 0 || (!unicode.IsLetter(lower[i-1]) && !unicode.IsDigit(lower[i-1])) {
+			k = append(k, string(lower[i:]))
+		}
+	}
+
+	return k
+}
@@ -0,0 +1,62 @@
+package suggest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTrie() *Trie {
+	t := NewTrie()
+	t.Add(1, "Packer Spiced Latte")
+	t.Add(2, "Vaulatte")
+	t.Add(5, "Vagrante espresso")
+	t.Add(6, "Connectaccino")
+	return t
+}
+
+func TestSuggestMatchesWordPrefixes(t *testing.T) {
+	trie := setupTrie()
+
+	assert.Equal(t, []Suggestion{{ID: 5, Name: "Vagrante espresso"}, {ID: 2, Name: "Vaulatte"}}, trie.Suggest("va", 10))
+	assert.Equal(t, []Suggestion{{ID: 1, Name: "Packer Spiced Latte"}}, trie.Suggest("LAT", 10))
+	assert.Equal(t, []Suggestion{{ID: 5, Name: "Vagrante espresso"}}, trie.Suggest("vagrante e", 10))
+	assert.Empty(t, trie.Suggest("mocha", 10))
+}
+
+func TestSuggestRanksByPopularity(t *testing.T) {
+	trie := setupTrie()
+	trie.SetPopularity(2, 10)
+
+	s := trie.Suggest("va", 1)
+	assert.Len(t, s, 1)
+	assert.Equal(t, 2, s[0].ID)
+	assert.Equal(t, int64(10), s[0].Popularity)
+}
+
+func TestAddRenamesAndRemoveDrops(t *testing.T) {
+	trie := setupTrie()
+
+	trie.Add(2, "Vault Mocha")
+	assert.Len(t, trie.Suggest("vaul", 10), 1)
+	assert.Len(t, trie.Suggest("mo", 10), 1)
+	assert.Empty(t, trie.Suggest("vaulat", 10))
+
+	trie.Remove(2)
+	assert.Empty(t, trie.Suggest("vaul", 10))
+	assert.Len(t, trie.Suggest("va", 10), 1)
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	trie := NewTrie()
+	for i := 0; i < 10000; i++ {
+		trie.Add(i, fmt.Sprintf("Coffee %d Latte", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Suggest("coffee 99", 10)
+	}
+}
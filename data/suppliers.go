@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ErrSuppliersUnsupported is returned when reading the suppliers of a
+// repository which does not store them
+var ErrSuppliersUnsupported = errors.New("suppliers are not supported by this backend")
+
+// SupplierFinder is implemented by repositories storing the suppliers of the
+// ingredients
+type SupplierFinder interface {
+	// FindSuppliers returns every supplier with the IDs of the ingredients it
+	// supplies
+	FindSuppliers(ctx context.Context) (entities.Suppliers, error)
+	// FindIngredientSupplier returns the supplier of an ingredient, ErrNotFound
+	// when the ingredient has none
+	FindIngredientSupplier(ctx context.Context, ingredientID int) (*entities.Supplier, error)
+}
+
+// FindSuppliers returns the suppliers of a SupplierFinder holding a
+// certification, every supplier when certification is empty, or
+// ErrSuppliersUnsupported. Certifications are matched ignoring case.
+func FindSuppliers(ctx context.Context, r Repository, certification string) (entities.Suppliers, error) {
+	var finder interface {
+		FindSuppliers(ctx context.Context) (entities.Suppliers, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrSuppliersUnsupported
+	}
+
+	suppliers, err := finder.FindSuppliers(ctx)
+	if err != nil || certification == "" {
+		return suppliers, err
+	}
+	certification = strings.ToLower(certification)
+	certified := suppliers[:0]
+	for _, supplier := range suppliers {
+		if supplier.Certified(certification) {
+			certified = append(certified, supplier)
+		}
+	}
+	return certified, nil
+}
+
+// FindIngredientSupplier returns the supplier of an ingredient from a
+// SupplierFinder, or ErrSuppliersUnsupported
+func FindIngredientSupplier(ctx context.Context, r Repository, ingredientID int) (*entities.Supplier, error) {
+	var finder interface {
+		FindIngredientSupplier(ctx context.Context, ingredientID int) (*entities.Supplier, error)
+	}
+	if !As(r, &finder) {
+		return nil, ErrSuppliersUnsupported
+	}
+	return finder.FindIngredientSupplier(ctx, ingredientID)
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// testSuppliers verifies a Repository holding the seed data serves the demo
+// suppliers of the ingredients
+func testSuppliers(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	suppliers, err := FindSuppliers(ctx, r, "")
+	require.NoError(t, err)
+	require.Len(t, suppliers, 3)
+	assert.Equal(t, "Finca La Esperanza", suppliers[0].Name)
+	assert.Equal(t, "CO", suppliers[0].Country)
+	assert.Equal(t, []string{"organic", "fairtrade"}, suppliers[0].Certifications)
+	assert.Equal(t, []int{2, 5}, suppliers[1].IngredientIDs)
+	assert.Equal(t, []string{}, suppliers[2].Certifications)
+
+	suppliers, err = FindSuppliers(ctx, r, "Organic")
+	require.NoError(t, err)
+	require.Len(t, suppliers, 2)
+	assert.Equal(t, "Meadowbank Dairy", suppliers[1].Name)
+	suppliers, err = FindSuppliers(ctx, r, "fairtrade")
+	require.NoError(t, err)
+	require.Len(t, suppliers, 1)
+	suppliers, err = FindSuppliers(ctx, r, "kosher")
+	require.NoError(t, err)
+	assert.Empty(t, suppliers)
+
+	supplier, err := FindIngredientSupplier(ctx, r, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "Meadowbank Dairy", supplier.Name)
+	assert.Equal(t, []int{2, 5}, supplier.IngredientIDs)
+	// hot water has no supplier
+	_, err = FindIngredientSupplier(ctx, r, 3)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = FindIngredientSupplier(ctx, r, 42)
+	assert.Equal(t, ErrNotFound, err)
+
+	// a deleted ingredient leaves its supplier
+	require.NoError(t, r.DeleteIngredient(ctx, 4))
+	_, err = FindIngredientSupplier(ctx, r, 4)
+	assert.Equal(t, ErrNotFound, err)
+	suppliers, err = FindSuppliers(ctx, r, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{}, suppliers[2].IngredientIDs)
+}
+
+func TestInMemorySuppliers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testSuppliers(t, r)
+}
+
+func TestInMemorySuppliersAreCopies(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	supplier, err := FindIngredientSupplier(context.Background(), r, 1)
+	require.NoError(t, err)
+	supplier.Certifications[0] = "changed"
+
+	supplier, err = FindIngredientSupplier(context.Background(), r, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "organic", supplier.Certifications[0])
+}
+
+func TestSuppliersPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testSuppliers(t, NewStoreScoped(NewScheduled(NewPublished(NewChanges(r, 10)))))
+}
+
+func TestSuppliersUnsupported(t *testing.T) {
+	_, err := FindSuppliers(context.Background(), &MockRepository{}, "")
+	assert.Equal(t, ErrSuppliersUnsupported, err)
+	_, err = FindIngredientSupplier(context.Background(), &MockRepository{}, 1)
+	assert.Equal(t, ErrSuppliersUnsupported, err)
+}
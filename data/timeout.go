@@ -0,0 +1,41 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// statementTimeout returns the Postgres statement_timeout in milliseconds
+// matching the time remaining until the deadline of ctx, and false when ctx
+// has no deadline. It is at least 1ms, as 0 disables the timeout.
+func statementTimeout(ctx context.Context) (int64, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	remaining := time.Until(deadline)
+	ms := int64((remaining + time.Millisecond - 1) / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+
+	return ms, true
+}
+
+// setStatementTimeout limits the statements of tx to the time remaining until
+// the deadline of ctx, so Postgres aborts them once the request is abandoned.
+// The setting is local to the transaction and never leaks to other sessions
+// using the pooled connection.
+func setStatementTimeout(ctx context.Context, tx *sqlx.Tx) error {
+	ms, ok := statementTimeout(ctx)
+	if !ok {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT set_config('statement_timeout', $1, true)", strconv.FormatInt(ms, 10))
+	return err
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementTimeoutWithoutDeadline(t *testing.T) {
+	_, ok := statementTimeout(context.Background())
+
+	assert.False(t, ok)
+}
+
+func TestStatementTimeoutMatchesRemainingTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ms, ok := statementTimeout(ctx)
+
+	assert.True(t, ok)
+	assert.InDelta(t, 2000, ms, 50)
+}
+
+func TestStatementTimeoutNeverDisablesTimeout(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	ms, ok := statementTimeout(ctx)
+
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), ms)
+}
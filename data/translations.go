@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// The coffee fields which can be translated
+const (
+	TranslationName   = "name"
+	TranslationTeaser = "teaser"
+)
+
+// maxLocales bounds the fallback chain of an Accept-Language header
+const maxLocales = 16
+
+// ErrTranslationsUnsupported is returned when managing the translations of a
+// repository which does not store them
+var ErrTranslationsUnsupported = errors.New("translations are not supported by this backend")
+
+// ErrInvalidTranslation is returned for a translation of an unknown field or
+// locale, or without a value
+var ErrInvalidTranslation = errors.New("invalid translation")
+
+// Translator is implemented by repositories storing translations of the coffee
+// names and teasers, keyed by coffee, locale and field
+type Translator interface {
+	// FindTranslations returns the translations of the coffees in coffeeIDs
+	// into locales, or into every locale when locales is empty
+	FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error)
+	// SetTranslation creates or replaces a translation, ErrNotFound when the
+	// coffee does not exist
+	SetTranslation(ctx context.Context, translation *entities.Translation) error
+	// DeleteTranslation removes a translation, ErrNotFound when it does not
+	// exist
+	DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error
+}
+
+// FindTranslations returns the translations of the coffees in coffeeIDs from
+// a Translator, or ErrTranslationsUnsupported
+func FindTranslations(ctx context.Context, r Repository, coffeeIDs []int, locales []string) (entities.Translations, error) {
+	var translator interface {
+		FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error)
+	}
+	if !As(r, &translator) {
+		return nil, ErrTranslationsUnsupported
+	}
+	return translator.FindTranslations(ctx, coffeeIDs, locales)
+}
+
+// SetTranslation validates a translation and stores it in a Translator, its
+// locale is canonicalized first, e.g. fr_ca becomes fr-CA
+func SetTranslation(ctx context.Context, r Repository, translation *entities.Translation) error {
+	var translator interface {
+		SetTranslation(ctx context.Context, translation *entities.Translation) error
+	}
+	if !As(r, &translator) {
+		return ErrTranslationsUnsupported
+	}
+
+	locale, err := CanonicalLocale(translation.Locale)
+	if err != nil || !translatable(translation.Field) || strings.TrimSpace(translation.Value) == "" {
+		return ErrInvalidTranslation
+	}
+	translation.Locale = locale
+	return translator.SetTranslation(ctx, translation)
+}
+
+// DeleteTranslation removes a translation from a Translator
+func DeleteTranslation(ctx context.Context, r Repository, coffeeID int, locale, field string) error {
+	var translator interface {
+		DeleteTranslation(ctx context.Context, coffeeID int, locale, field string) error
+	}
+	if !As(r, &translator) {
+		return ErrTranslationsUnsupported
+	}
+
+	canonical, err := CanonicalLocale(locale)
+	if err != nil || !translatable(field) {
+		return ErrInvalidTranslation
+	}
+	return translator.DeleteTranslation(ctx, coffeeID, canonical, field)
+}
+
+// Localize replaces the names and teasers of coffees with their translations
+// into the first locale of the chain which has one, keeping the stored value
+// when none does. Repositories without translations leave coffees unchanged.
+func Localize(ctx context.Context, r Repository, coffees entities.Coffees, locales []string) error {
+	var translator interface {
+		FindTranslations(ctx context.Context, coffeeIDs []int, locales []string) (entities.Translations, error)
+	}
+	if !As(r, &translator) || len(locales) == 0 || len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(coffees))
+	for n := range coffees {
+		ids[n] = coffees[n].ID
+	}
+	translations, err := translator.FindTranslations(ctx, ids, locales)
+	if err != nil {
+		return err
+	}
+
+	rank := make(map[string]int, len(locales))
+	for n, locale := range locales {
+		rank[locale] = n
+	}
+	type key struct {
+		coffeeID int
+		field    string
+	}
+	best := map[key]entities.Translation{}
+	for _, t := range translations {
+		k := key{t.CoffeeID, t.Field}
+		if current, ok := best[k]; !ok || rank[t.Locale] < rank[current.Locale] {
+			best[k] = t
+		}
+	}
+
+	for n := range coffees {
+		if t, ok := best[key{coffees[n].ID, TranslationName}]; ok {
+			coffees[n].Name = t.Value
+		}
+		if t, ok := best[key{coffees[n].ID, TranslationTeaser}]; ok {
+			coffees[n].Teaser = t.Value
+		}
+	}
+	return nil
+}
+
+// LocaleChain returns the locales of an Accept-Language header in order of
+// preference, each followed by its more general parents, so
+// "fr-CA, en;q=0.5" falls back from fr-CA to fr and then to en. It is empty
+// for a missing or invalid header.
+func LocaleChain(acceptLanguage string) []string {
+	if acceptLanguage == "" {
+		return nil
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return nil
+	}
+
+	chain := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		// * accepts any language, which the stored values already are
+		if tag == language.Und || tag.String() == "mul" {
+			continue
+		}
+		for ; tag != language.Und; tag = tag.Parent() {
+			if locale := tag.String(); !seen[locale] {
+				seen[locale] = true
+				chain = append(chain, locale)
+			}
+		}
+		if len(chain) >= maxLocales {
+			return chain[:maxLocales]
+		}
+	}
+	return chain
+}
+
+// CanonicalLocale returns the BCP 47 form of a locale, e.g. fr-CA for fr_ca
+func CanonicalLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", err
+	}
+	if tag == language.Und {
+		return "", ErrInvalidTranslation
+	}
+	return tag.String(), nil
+}
+
+// translatable reports whether a coffee field can be translated
+func translatable(field string) bool {
+	return field == TranslationName || field == TranslationTeaser
+}
@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestLocaleChain(t *testing.T) {
+	assert.Equal(t, []string{"fr-CA", "fr", "en"}, LocaleChain("fr-CA, fr;q=0.9, en;q=0.5"))
+	assert.Equal(t, []string{"de", "fr"}, LocaleChain("fr;q=0.2, de, es;q=0"))
+	assert.Equal(t, []string{"pt-BR", "pt"}, LocaleChain("pt-br, *;q=0.1"))
+	assert.Empty(t, LocaleChain(""))
+	assert.Empty(t, LocaleChain("*"))
+	assert.Empty(t, LocaleChain("not a;;locale"))
+}
+
+func TestCanonicalLocale(t *testing.T) {
+	locale, err := CanonicalLocale("fr_ca")
+	require.NoError(t, err)
+	assert.Equal(t, "fr-CA", locale)
+
+	_, err = CanonicalLocale("und")
+	assert.Error(t, err)
+	_, err = CanonicalLocale("not-a-locale!")
+	assert.Error(t, err)
+}
+
+// testTranslations verifies a Repository holding the seed data stores the
+// translations of coffee fields and localizes coffees with them
+func testTranslations(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	for _, translation := range []entities.Translation{
+		{CoffeeID: 1, Locale: "fr", Field: TranslationName, Value: "Latte épicé Packer"},
+		{CoffeeID: 1, Locale: "fr_CA", Field: TranslationName, Value: "Latte épicé Packer, eh"},
+		{CoffeeID: 1, Locale: "fr", Field: TranslationTeaser, Value: "Le latte des artefacts"},
+		{CoffeeID: 2, Locale: "de", Field: TranslationName, Value: "Vaulatte, bitte"},
+	} {
+		translation := translation
+		require.NoError(t, SetTranslation(ctx, r, &translation))
+		assert.NotEmpty(t, translation.UpdatedAt)
+	}
+
+	// setting a translation again replaces it
+	updated := &entities.Translation{CoffeeID: 2, Locale: "de", Field: TranslationName, Value: "Vaulatte"}
+	require.NoError(t, SetTranslation(ctx, r, updated))
+
+	assert.Equal(t, ErrNotFound, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 42, Locale: "fr", Field: TranslationName, Value: "Inconnu"}))
+	assert.Equal(t, ErrInvalidTranslation, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: "price", Value: "1"}))
+	assert.Equal(t, ErrInvalidTranslation, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: TranslationName, Value: " "}))
+
+	translations, err := FindTranslations(ctx, r, []int{1, 2}, nil)
+	require.NoError(t, err)
+	require.Len(t, translations, 4)
+	assert.Equal(t, "fr", translations[0].Locale)
+	assert.Equal(t, TranslationName, translations[0].Field)
+	assert.Equal(t, "fr-CA", translations[2].Locale)
+	assert.Equal(t, "Vaulatte", translations[3].Value)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	defer entities.PutCoffees(coffees)
+	require.NoError(t, Localize(ctx, r, coffees, LocaleChain("fr-CA, de;q=0.5")))
+	assert.Equal(t, "Latte épicé Packer, eh", coffees[0].Name)
+	assert.Equal(t, "Le latte des artefacts", coffees[0].Teaser)
+	assert.Equal(t, "Vaulatte", coffees[1].Name)
+	assert.Equal(t, "Nomadicano", coffees[2].Name)
+
+	require.NoError(t, DeleteTranslation(ctx, r, 1, "fr-ca", TranslationName))
+	assert.Equal(t, ErrNotFound, DeleteTranslation(ctx, r, 1, "fr-CA", TranslationName))
+	coffee, err := r.FindByID(ctx, 1)
+	require.NoError(t, err)
+	coffees = entities.Coffees{*coffee}
+	require.NoError(t, Localize(ctx, r, coffees, LocaleChain("fr-CA")))
+	assert.Equal(t, "Latte épicé Packer", coffees[0].Name)
+
+	// translations are deleted with their coffee
+	require.NoError(t, r.DeleteCoffee(ctx, 2))
+	translations, err = FindTranslations(ctx, r, []int{2}, []string{"de"})
+	require.NoError(t, err)
+	assert.Empty(t, translations)
+}
+
+func TestInMemoryTranslations(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testTranslations(t, r)
+}
+
+func TestInMemoryTranslationsOfManyCoffees(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	ids := []int{}
+	for n := 0; n <= indexedHydrationLimit; n++ {
+		coffee := &entities.Coffee{Name: "Generated", Price: 200}
+		require.NoError(t, r.CreateCoffee(ctx, coffee))
+		ids = append(ids, coffee.ID)
+		require.NoError(t, SetTranslation(ctx, r, &entities.Translation{CoffeeID: coffee.ID, Locale: "it", Field: TranslationName, Value: "Generato"}))
+	}
+	require.NoError(t, SetTranslation(ctx, r, &entities.Translation{CoffeeID: 1, Locale: "it", Field: TranslationName, Value: "Latte speziato"}))
+
+	translations, err := FindTranslations(ctx, r, ids, []string{"it"})
+	require.NoError(t, err)
+	assert.Len(t, translations, len(ids))
+}
+
+func TestTranslationsPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testTranslations(t, NewChanges(r, 10))
+}
+
+// untranslated hides the Translator of a repository
+type untranslated struct {
+	Repository
+}
+
+func TestTranslationsNeedATranslator(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	translation := &entities.Translation{CoffeeID: 1, Locale: "fr", Field: TranslationName, Value: "Latte épicé Packer"}
+	assert.Equal(t, ErrTranslationsUnsupported, SetTranslation(ctx, untranslated{r}, translation))
+	_, err = FindTranslations(ctx, untranslated{r}, []int{1}, nil)
+	assert.Equal(t, ErrTranslationsUnsupported, err)
+
+	coffees, err := r.Find(ctx)
+	require.NoError(t, err)
+	require.NoError(t, Localize(ctx, untranslated{r}, coffees, []string{"fr"}))
+	assert.Equal(t, "Packer Spiced Latte", coffees[0].Name)
+}
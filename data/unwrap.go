@@ -0,0 +1,35 @@
+package data
+
+import "reflect"
+
+// Wrapper is implemented by the repositories decorating another one. The
+// optional capabilities of a repository, e.g. its images or coupons, are
+// looked up method by method through Unwrap, so a decorator only implements
+// the methods it changes and the others reach the repository it wraps.
+type Wrapper interface {
+	Unwrap() Repository
+}
+
+// As sets target, a pointer to an interface, to the first repository
+// implementing it, starting from r and following Unwrap, like errors.As for
+// errors. It reports whether one was found.
+func As(r Repository, target interface{}) bool {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Interface {
+		panic("data: As target must be a non-nil pointer to an interface")
+	}
+
+	want := value.Elem().Type()
+	for r != nil {
+		if reflect.TypeOf(r).Implements(want) {
+			value.Elem().Set(reflect.ValueOf(r))
+			return true
+		}
+		wrapper, ok := r.(Wrapper)
+		if !ok {
+			return false
+		}
+		r = wrapper.Unwrap()
+	}
+	return false
+}
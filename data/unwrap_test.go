@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// thumbnailRepository only changes how images are stored, every other
+// capability reaches the repository it wraps
+type thumbnailRepository struct {
+	Repository
+	stored []entities.CoffeeImage
+}
+
+func (r *thumbnailRepository) Unwrap() Repository {
+	return r.Repository
+}
+
+func (r *thumbnailRepository) SetImage(ctx context.Context, image *entities.CoffeeImage) error {
+	r.stored = append(r.stored, *image)
+	return SetImage(ctx, r.Repository, image)
+}
+
+func TestAsFindsTheCapabilityThroughUnwrap(t *testing.T) {
+	ctx := context.Background()
+	inner, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	thumbnails := &thumbnailRepository{Repository: inner}
+	r := NewPublished(NewChanges(thumbnails, 10))
+
+	var setter interface {
+		SetImage(ctx context.Context, image *entities.CoffeeImage) error
+	}
+	require.True(t, As(r, &setter))
+	assert.Equal(t, thumbnails, setter)
+	var finder interface {
+		FindImages(ctx context.Context, coffeeIDs []int) (entities.CoffeeImages, error)
+	}
+	require.True(t, As(r, &finder))
+	assert.Equal(t, inner, finder)
+
+	image := &entities.CoffeeImage{CoffeeID: 1, Size: ImageFull, URL: "/latte.png", Width: 1200, Height: 800}
+	require.NoError(t, SetImage(ctx, r, image))
+	assert.Len(t, thumbnails.stored, 1)
+	images, err := FindImages(ctx, r, []int{1})
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, "/latte.png", images[0].URL)
+
+	coupons, err := FindCoupons(ctx, r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, coupons)
+}
+
+func TestAsReportsAMissingCapability(t *testing.T) {
+	var store CouponStore
+	assert.False(t, As(NewPublished(&MockRepository{}), &store))
+	assert.Nil(t, store)
+
+	_, err := FindCoupons(context.Background(), NewChanges(&MockRepository{}, 10))
+	assert.Equal(t, ErrCouponsUnsupported, err)
+}
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// maxDisplayName is the length of the longest display name, in characters
+const maxDisplayName = 64
+
+// milks are the favorite milks of a profile
+var milks = []string{"whole", "skim", "oat", "almond", "soy"}
+
+var (
+	// ErrUsersUnsupported is returned when managing the profiles of a
+	// repository which does not store them
+	ErrUsersUnsupported = errors.New("user profiles are not supported by this backend")
+	// ErrInvalidUser is returned for a profile without a display name, with
+	// an unknown milk or default store
+	ErrInvalidUser = errors.New("invalid user profile")
+)
+
+// UserStore is implemented by repositories storing the profiles of users,
+// keyed by the subject of their token
+type UserStore interface {
+	// FindUser returns the profile of a user, or ErrNotFound
+	FindUser(ctx context.Context, subject string) (*entities.User, error)
+	// SaveUser inserts the profile of a user, or replaces it keeping the
+	// time it was created
+	SaveUser(ctx context.Context, user *entities.User) error
+}
+
+// FindUser returns the profile of a user of a UserStore, or
+// ErrUsersUnsupported
+func FindUser(ctx context.Context, r Repository, subject string) (*entities.User, error) {
+	var store interface {
+		FindUser(ctx context.Context, subject string) (*entities.User, error)
+	}
+	if !As(r, &store) {
+		return nil, ErrUsersUnsupported
+	}
+	return store.FindUser(ctx, subject)
+}
+
+// SaveUser validates the profile of a user and saves it in a UserStore. The
+// default store must be a store of the repository.
+func SaveUser(ctx context.Context, r Repository, user *entities.User) error {
+	var store interface {
+		SaveUser(ctx context.Context, user *entities.User) error
+	}
+	if !As(r, &store) {
+		return ErrUsersUnsupported
+	}
+	if err := validUser(user); err != nil {
+		return err
+	}
+	if user.DefaultStoreID != nil {
+		_, err := FindStore(ctx, r, *user.DefaultStoreID)
+		if err == ErrNotFound || err == ErrStoresUnsupported {
+			return ErrInvalidUser
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return store.SaveUser(ctx, user)
+}
+
+// validUser checks a profile, trimming its display name and lower casing
+// its milk
+func validUser(user *entities.User) error {
+	user.DisplayName = strings.TrimSpace(user.DisplayName)
+	user.FavoriteMilk = strings.ToLower(strings.TrimSpace(user.FavoriteMilk))
+	if user.Subject == "" || user.DisplayName == "" || utf8.RuneCountInString(user.DisplayName) > maxDisplayName {
+		return ErrInvalidUser
+	}
+	if strings.IndexFunc(user.DisplayName, unicode.IsControl) >= 0 {
+		return ErrInvalidUser
+	}
+	if user.FavoriteMilk == "" {
+		return nil
+	}
+	for _, milk := range milks {
+		if user.FavoriteMilk == milk {
+			return nil
+		}
+	}
+	return ErrInvalidUser
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testUsers verifies a Repository holding the seed stores saves the profiles
+// of users
+func testUsers(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	_, err := FindUser(ctx, r, "auth0|42")
+	assert.Equal(t, ErrNotFound, err)
+
+	storeID := 2
+	user := &entities.User{Subject: "auth0|42", DisplayName: " Gerry ", FavoriteMilk: "Oat", DefaultStoreID: &storeID}
+	require.NoError(t, SaveUser(ctx, r, user))
+	assert.Equal(t, "Gerry", user.DisplayName)
+	assert.Equal(t, "oat", user.FavoriteMilk)
+	assert.NotEmpty(t, user.CreatedAt)
+
+	found, err := FindUser(ctx, r, "auth0|42")
+	require.NoError(t, err)
+	assert.Equal(t, "Gerry", found.DisplayName)
+	require.NotNil(t, found.DefaultStoreID)
+	assert.Equal(t, 2, *found.DefaultStoreID)
+
+	// saving again replaces the profile
+	require.NoError(t, SaveUser(ctx, r, &entities.User{Subject: "auth0|42", DisplayName: "Gerry B"}))
+	found, err = FindUser(ctx, r, "auth0|42")
+	require.NoError(t, err)
+	assert.Equal(t, "Gerry B", found.DisplayName)
+	assert.Empty(t, found.FavoriteMilk)
+	assert.Nil(t, found.DefaultStoreID)
+	assert.Equal(t, user.CreatedAt, found.CreatedAt)
+
+	unknownStore := 99
+	for _, invalid := range []entities.User{
+		{Subject: "", DisplayName: "Gerry"},
+		{Subject: "1", DisplayName: "  "},
+		{Subject: "1", DisplayName: strings.Repeat("x", 65)},
+		{Subject: "1", DisplayName: "Ger\nry"},
+		{Subject: "1", DisplayName: "Gerry", FavoriteMilk: "camel"},
+		{Subject: "1", DisplayName: "Gerry", DefaultStoreID: &unknownStore},
+	} {
+		invalid := invalid
+		assert.Equal(t, ErrInvalidUser, SaveUser(ctx, r, &invalid), invalid)
+	}
+	_, err = FindUser(ctx, r, "1")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestInMemoryUsers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testUsers(t, r)
+}
+
+func TestInMemoryUsersAreCopies(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	storeID := 1
+	require.NoError(t, SaveUser(context.Background(), r, &entities.User{Subject: "1", DisplayName: "Gerry", DefaultStoreID: &storeID}))
+	storeID = 3
+	user, err := FindUser(context.Background(), r, "1")
+	require.NoError(t, err)
+	*user.DefaultStoreID = 3
+
+	user, err = FindUser(context.Background(), r, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, *user.DefaultStoreID)
+}
+
+func TestUsersPassThroughWrappers(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	testUsers(t, NewPublished(NewChanges(NewRemoteIngredients(r, nil), 10)))
+}
+
+func TestUsersUnsupported(t *testing.T) {
+	_, err := FindUser(context.Background(), &MockRepository{}, "1")
+	assert.Equal(t, ErrUsersUnsupported, err)
+	assert.Equal(t, ErrUsersUnsupported, SaveUser(context.Background(), &MockRepository{}, &entities.User{}))
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// newUUIDv7 returns a UUIDv7 of RFC 9562 created at t, its first 48 bits are
+// the Unix time in milliseconds so the UUIDs sort by creation time, the
+// other 74 bits besides the version and variant are random
+func newUUIDv7(t time.Time) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixNano()/int64(time.Millisecond)))
+	copy(b[:6], ms[2:])
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:]), nil
+}
+
+// entityUUID returns the UUID of a new coupon, order record or points entry
+// for the ENTITY_IDS setting ids, a UUIDv7 for uuidv7 and none otherwise
+func entityUUID(ids string) (string, error) {
+	if ids != config.UUIDv7IDs {
+		return "", nil
+	}
+	return newUUIDv7(time.Now())
+}
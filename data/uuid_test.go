@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// uuidv7 matches a UUID of version 7 and of the RFC 9562 variant
+var uuidv7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// testEntityUUIDs verifies a Repository with ENTITY_IDS=uuidv7 assigns new
+// coupons, order records and points entries a UUIDv7 they keep when updated
+func testEntityUUIDs(t *testing.T, r Repository) {
+	ctx := context.Background()
+
+	coupon := &entities.Coupon{Code: "UUID", Type: entities.CouponFixed, Value: 1}
+	require.NoError(t, CreateCoupon(ctx, r, coupon))
+	assert.Regexp(t, uuidv7, coupon.UUID)
+	created := coupon.UUID
+	coupon = &entities.Coupon{Code: "UUID", Type: entities.CouponFixed, Value: 2}
+	require.NoError(t, UpdateCoupon(ctx, r, coupon))
+	assert.Equal(t, created, coupon.UUID)
+	coupon, err := FindCoupon(ctx, r, "UUID")
+	require.NoError(t, err)
+	assert.Equal(t, created, coupon.UUID)
+
+	entry := &entities.PointsEntry{UserID: 7, OrderID: 30, Points: 10, Reason: entities.PointsEarned}
+	_, err = RecordPoints(ctx, r, entry)
+	require.NoError(t, err)
+	assert.Regexp(t, uuidv7, entry.UUID)
+	points, err := FindPoints(ctx, r, 7)
+	require.NoError(t, err)
+	require.Len(t, points.Ledger, 1)
+	assert.Equal(t, entry.UUID, points.Ledger[0].UUID)
+
+	order := &entities.OrderRecord{ID: 30, Status: entities.OrderCreated, Total: 3, CreatedAt: time.Now()}
+	require.NoError(t, RecordOrder(ctx, r, order))
+	assert.Regexp(t, uuidv7, order.UUID)
+	created = order.UUID
+	order = &entities.OrderRecord{ID: 30, Status: entities.OrderPaid, Total: 3, CreatedAt: time.Now()}
+	require.NoError(t, RecordOrder(ctx, r, order))
+	assert.Equal(t, created, order.UUID)
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	now := time.Now()
+	first, err := newUUIDv7(now)
+	require.NoError(t, err)
+	second, err := newUUIDv7(now)
+	require.NoError(t, err)
+	later, err := newUUIDv7(now.Add(time.Millisecond))
+	require.NoError(t, err)
+
+	assert.Regexp(t, uuidv7, first)
+	assert.NotEqual(t, first, second)
+	// the UUIDs of later milliseconds sort after
+	assert.Less(t, first, later)
+	assert.Less(t, second, later)
+
+	uuid, err := newUUIDv7(time.Unix(0, 0x017f22e279b0*int64(time.Millisecond)))
+	require.NoError(t, err)
+	assert.Equal(t, "017f22e2-79b0", uuid[:13])
+}
+
+func TestEntityUUIDNeedsUUIDv7IDs(t *testing.T) {
+	uuid, err := entityUUID(config.SerialIDs)
+	require.NoError(t, err)
+	assert.Empty(t, uuid)
+
+	uuid, err = entityUUID(config.UUIDv7IDs)
+	require.NoError(t, err)
+	assert.Regexp(t, uuidv7, uuid)
+}
+
+func TestInMemoryEntityUUIDs(t *testing.T) {
+	r, err := newInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), EntityIDs: config.UUIDv7IDs})
+	require.NoError(t, err)
+	require.NoError(t, r.load())
+
+	testEntityUUIDs(t, r)
+
+	// the rows are found by UUID
+	points, err := FindPoints(context.Background(), r, 7)
+	require.NoError(t, err)
+	raw, err := r.db.Txn(false).First(PointsEntry.String(), "uuid", points.Ledger[0].UUID)
+	require.NoError(t, err)
+	require.NotNil(t, raw)
+	assert.Equal(t, points.Ledger[0].ID, raw.(*entities.PointsEntry).ID)
+}
+
+func TestInMemorySerialIDsLeaveTheUUIDsEmpty(t *testing.T) {
+	r, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), EntityIDs: config.SerialIDs})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	coupon := &entities.Coupon{Code: "SERIAL", Type: entities.CouponFixed, Value: 1}
+	require.NoError(t, CreateCoupon(ctx, r, coupon))
+	assert.Empty(t, coupon.UUID)
+	entry := &entities.PointsEntry{UserID: 7, OrderID: 30, Points: 10, Reason: entities.PointsEarned}
+	_, err = RecordPoints(ctx, r, entry)
+	require.NoError(t, err)
+	assert.Empty(t, entry.UUID)
+	order := &entities.OrderRecord{ID: 30, Status: entities.OrderCreated, Total: 3, CreatedAt: time.Now()}
+	require.NoError(t, RecordOrder(ctx, r, order))
+	assert.Empty(t, order.UUID)
+}
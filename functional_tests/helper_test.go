@@ -19,7 +19,7 @@ import (
 func (api *V1APIFeature) initService() {
 	repo := data.MockRepository{}
 	repo.On("Find").Return(entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, nil)
-	api.svc = v1.NewCoffeeService(&repo, hclog.Default())
+	api.svc = v1.NewCoffeeService(&repo, nil, hclog.Default())
 }
 
 func (api *V1APIFeature) initHandlers() error {
@@ -28,7 +28,7 @@ func (api *V1APIFeature) initHandlers() error {
 
 	logger := hclog.Default()
 
-	api.svc = v1.NewCoffeeService(mockRepo, logger)
+	api.svc = v1.NewCoffeeService(mockRepo, nil, logger)
 
 	return nil
 }
@@ -1,34 +1,39 @@
 module github.com/hashicorp-demoapp/coffee-service
 
-go 1.14
+go 1.16
 
 require (
 	contrib.go.opencensus.io/integrations/ocsql v0.1.6
+	github.com/andybalholm/brotli v1.0.6
 	github.com/codahale/hdrhistogram v0.9.0 // indirect
 	github.com/cucumber/godog v0.10.0
 	github.com/cucumber/messages-go/v10 v10.0.3
 	github.com/fatih/color v1.9.0 // indirect
-	github.com/go-sql-driver/mysql v1.4.1 // indirect
-	github.com/google/go-cmp v0.4.0 // indirect
 	github.com/gorilla/mux v1.8.0
 	github.com/hashicorp-demoapp/go-hckit v0.0.1
 	github.com/hashicorp/go-hclog v0.14.1
 	github.com/hashicorp/go-memdb v1.2.1
 	github.com/hashicorp/go-uuid v1.0.2 // indirect
+	github.com/hashicorp/hcl v1.0.0
+	github.com/hashicorp/raft v1.1.2
+	github.com/jackc/pgconn v1.7.0
+	github.com/jackc/pgx/v4 v4.9.0
 	github.com/jmoiron/sqlx v1.2.0
-	github.com/lib/pq v1.2.0
 	github.com/mattn/go-colorable v0.1.6 // indirect
 	github.com/mattn/go-sqlite3 v1.11.0 // indirect
 	github.com/nicholasjackson/env v0.6.0
 	github.com/opentracing/opentracing-go v1.2.0
-	github.com/pkg/errors v0.8.1 // indirect
 	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.6.1
+	github.com/testcontainers/testcontainers-go v0.9.0
 	github.com/uber/jaeger-client-go v2.25.0+incompatible // indirect
 	github.com/uber/jaeger-lib v2.2.0+incompatible // indirect
+	github.com/vmihailenco/msgpack/v4 v4.3.12
 	go.opencensus.io v0.22.0 // indirect
-	go.uber.org/atomic v1.4.0 // indirect
-	google.golang.org/appengine v1.6.0 // indirect
+	golang.org/x/text v0.3.3
+	google.golang.org/grpc v1.33.2
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 // replace github.com/DerekStrickland/learn-consul-jaeger/go-hckit => /Users/derekstrickland/code/DerekStrickland/learn-consul-jaeger/go-hckit
@@ -0,0 +1,198 @@
+// Package images stores the uploaded coffee images as files and resizes them
+// into smaller variants in the background, for responsive pages choosing the
+// size which fits the screen.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PNG is the format of PNG images
+	PNG = "png"
+	// JPEG is the format of JPEG images
+	JPEG = "jpeg"
+)
+
+// MaxDimension is the widest and tallest image decoded, larger ones would
+// take too much memory once decoded
+const MaxDimension = 4096
+
+// jpegQuality is the quality of the JPEG variants
+const jpegQuality = 85
+
+// ErrUnsupportedFormat is returned when decoding an image which is neither
+// PNG nor JPEG
+var ErrUnsupportedFormat = errors.New("images must be PNG or JPEG")
+
+// ErrTooLarge is returned when decoding an image wider or taller than
+// MaxDimension
+var ErrTooLarge = fmt.Errorf("images must be at most %dx%d", MaxDimension, MaxDimension)
+
+// Image is an image file in a Store
+type Image struct {
+	Size   string
+	URL    string
+	Width  int
+	Height int
+}
+
+// Store keeps images as files in a directory and serves them under a URL
+// prefix
+type Store struct {
+	dir    string
+	prefix string
+}
+
+// NewStore creates a Store of the files in dir, created when missing, served
+// under prefix, e.g. /images/
+func NewStore(dir, prefix string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, prefix: "/" + strings.Trim(prefix, "/") + "/"}, nil
+}
+
+// Put writes the file name, a slash separated path, and returns its URL. The
+// file is written next to its final path and renamed, so it is never served
+// half written.
+func (s *Store) Put(name string, data []byte) (string, error) {
+	name = path.Clean("/" + name)[1:]
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", err
+	}
+
+	return s.prefix + name, nil
+}
+
+// Prefix returns the URL prefix the files are served under
+func (s *Store) Prefix() string {
+	return s.prefix
+}
+
+// Handler returns a handler serving the files of the store under its prefix,
+// without listing the directories
+func (s *Store) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.StripPrefix(strings.TrimSuffix(s.prefix, "/"), http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(rw, r)
+			return
+		}
+		// the names hold a hash of the content, so files never change
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(rw, r)
+	}))
+}
+
+// Decode decodes a PNG or JPEG image and returns its format
+func Decode(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, "", ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// Encode encodes an image in format, PNG or JPEG
+func Encode(img image.Image, format string) ([]byte, error) {
+	b := &bytes.Buffer{}
+	var err error
+	switch format {
+	case PNG:
+		err = png.Encode(b, img)
+	case JPEG:
+		err = jpeg.Encode(b, img, &jpeg.Options{Quality: jpegQuality})
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Extension returns the file extension of format, including the dot
+func Extension(format string) string {
+	if format == JPEG {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// Resize scales an image down to width keeping its aspect ratio, averaging
+// the pixels each pixel of the result covers. Images which are not wider are
+// returned as they are, images are never scaled up.
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if width <= 0 || bounds.Dx() <= width {
+		return img
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			resized.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return resized
+}
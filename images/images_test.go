@@ -0,0 +1,128 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkerboard returns a width x height image of black and white pixels
+func checkerboard(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+func TestResizeAveragesPixels(t *testing.T) {
+	resized := Resize(checkerboard(400, 300), 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 75), resized.Bounds())
+	r, g, b, a := resized.At(10, 10).RGBA()
+	assert.InDelta(t, 0x7fff, r, 0x100)
+	assert.Equal(t, r, g)
+	assert.Equal(t, r, b)
+	assert.Equal(t, uint32(0xffff), a)
+
+	// images are never scaled up
+	small := checkerboard(50, 50)
+	assert.Equal(t, small, Resize(small, 100))
+}
+
+func TestDecodeAndEncode(t *testing.T) {
+	for _, format := range []string{PNG, JPEG} {
+		data, err := Encode(checkerboard(20, 10), format)
+		require.NoError(t, err)
+
+		img, decoded, err := Decode(data)
+		require.NoError(t, err, format)
+		assert.Equal(t, format, decoded)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+	}
+
+	_, _, err := Decode([]byte("GIF89a, not really"))
+	assert.Equal(t, ErrUnsupportedFormat, err)
+	_, err = Encode(checkerboard(1, 1), "gif")
+	assert.Equal(t, ErrUnsupportedFormat, err)
+
+	huge, err := Encode(image.NewGray(image.Rect(0, 0, MaxDimension+1, 1)), PNG)
+	require.NoError(t, err)
+	_, _, err = Decode(huge)
+	assert.Equal(t, ErrTooLarge, err)
+}
+
+func TestStoreServesItsFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "images")
+	require.NoError(t, err)
+
+	url, err := store.Put("coffees/1/../1/a-full.png", []byte("png"))
+	require.NoError(t, err)
+	assert.Equal(t, "/images/coffees/1/a-full.png", url)
+	written, err := ioutil.ReadFile(filepath.Join(dir, "coffees", "1", "a-full.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(written))
+
+	rw := httptest.NewRecorder()
+	store.Handler().ServeHTTP(rw, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "png", rw.Body.String())
+	assert.Contains(t, rw.Header().Get("Cache-Control"), "immutable")
+
+	// directories are not listed
+	rw = httptest.NewRecorder()
+	store.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/images/coffees/1/", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestResizerStoresTheVariants(t *testing.T) {
+	store, err := NewStore(t.TempDir(), "/images/")
+	require.NoError(t, err)
+	resizer := NewResizer(Options{
+		Store:    store,
+		Variants: []Variant{{Size: "thumb", Width: 16}, {Size: "medium", Width: 48}},
+		Logger:   hclog.NewNullLogger(),
+	})
+
+	stored := make(chan []Image, 1)
+	require.NoError(t, resizer.Enqueue(Job{Name: "coffees/1/a", Image: checkerboard(32, 32), Format: PNG, Done: func(variants []Image) error {
+		stored <- variants
+		return nil
+	}}))
+	done := make(chan struct{})
+	close(done)
+	// the queued uploads are still resized once done is closed
+	resizer.Run(done)
+
+	select {
+	case variants := <-stored:
+		assert.Equal(t, []Image{
+			{Size: "thumb", URL: "/images/coffees/1/a-thumb.png", Width: 16, Height: 16},
+			{Size: "medium", URL: "/images/coffees/1/a-medium.png", Width: 32, Height: 32},
+		}, variants)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upload was not resized")
+	}
+}
+
+func TestResizerQueueIsBounded(t *testing.T) {
+	resizer := NewResizer(Options{Buffer: 1, Logger: hclog.NewNullLogger()})
+
+	require.NoError(t, resizer.Enqueue(Job{}))
+	assert.Equal(t, ErrQueueFull, resizer.Enqueue(Job{}))
+}
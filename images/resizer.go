@@ -0,0 +1,134 @@
+package images
+
+import (
+	"errors"
+	"image"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// defaultBuffer is the number of uploads waiting to be resized when the
+// Options do not set one
+const defaultBuffer = 32
+
+// ErrQueueFull is returned when queueing an upload while the Resizer has as
+// many waiting as its buffer holds
+var ErrQueueFull = errors.New("resize queue is full")
+
+// Variant is a size uploaded images are resized to
+type Variant struct {
+	Size  string
+	Width int
+}
+
+// Job asks for the variants of an uploaded image
+type Job struct {
+	// Name is the path of the variants in the Store, without the size and
+	// the extension, e.g. coffees/1/3f2a9c
+	Name   string
+	Image  image.Image
+	Format string
+	// Done is called with the variants once they are all stored
+	Done func(variants []Image) error
+}
+
+// Options configure a Resizer
+type Options struct {
+	Store    *Store
+	Variants []Variant
+	// Buffer is the number of uploads waiting to be resized
+	Buffer  int
+	Logger  hclog.Logger
+	Metrics metrics.Sink
+}
+
+// Resizer writes the variants of uploaded images to a Store in the
+// background, one upload at a time. Results are counted in images.resized
+// labelled with their result, success or error, and timed in
+// images.resize.duration.
+type Resizer struct {
+	options Options
+	jobs    chan Job
+}
+
+// NewResizer creates a Resizer, uploads are only resized once Run is called
+func NewResizer(options Options) *Resizer {
+	if options.Buffer <= 0 {
+		options.Buffer = defaultBuffer
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+	return &Resizer{options: options, jobs: make(chan Job, options.Buffer)}
+}
+
+// Enqueue queues an upload without blocking, ErrQueueFull when the buffer is
+// full
+func (r *Resizer) Enqueue(job Job) error {
+	select {
+	case r.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run resizes the queued uploads until done is closed, then resizes the
+// uploads still queued
+func (r *Resizer) Run(done <-chan struct{}) {
+	for {
+		select {
+		case job := <-r.jobs:
+			r.run(job)
+		case <-done:
+			for {
+				select {
+				case job := <-r.jobs:
+					r.run(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// run resizes an upload and reports the variants to its Done
+func (r *Resizer) run(job Job) {
+	start := time.Now()
+	err := r.resize(job)
+	metrics.MeasureSince(r.options.Metrics, "images.resize.duration", start)
+
+	result := "success"
+	if err != nil {
+		r.options.Logger.Error("Unable to resize image", "name", job.Name, "error", err)
+		result = "error"
+	} else {
+		r.options.Logger.Debug("Image resized", "name", job.Name, "duration", time.Since(start))
+	}
+	r.options.Metrics.IncrCounter("images.resized", 1, metrics.Label{Name: "result", Value: result})
+}
+
+// resize stores every variant of an upload, then calls its Done
+func (r *Resizer) resize(job Job) error {
+	variants := make([]Image, 0, len(r.options.Variants))
+	for _, variant := range r.options.Variants {
+		resized := Resize(job.Image, variant.Width)
+		data, err := Encode(resized, job.Format)
+		if err != nil {
+			return err
+		}
+		url, err := r.options.Store.Put(job.Name+"-"+variant.Size+Extension(job.Format), data)
+		if err != nil {
+			return err
+		}
+
+		bounds := resized.Bounds()
+		variants = append(variants, Image{Size: variant.Size, URL: url, Width: bounds.Dx(), Height: bounds.Dy()})
+	}
+
+	return job.Done(variants)
+}
@@ -0,0 +1,46 @@
+package ingredients
+
+import "sync"
+
+// maxBudget is the number of extra attempts the budget starts with and can
+// save up, so quiet periods still allow a few retries
+const maxBudget = 10
+
+// budget limits retries and hedged attempts to a percentage of the requests,
+// so a struggling upstream is not hit with a multiple of its usual load. Every
+// request deposits a fraction of a token and every extra attempt withdraws a
+// whole one.
+type budget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+// newBudget creates a budget allowing percent extra attempts per request
+func newBudget(percent float64) *budget {
+	return &budget{ratio: percent / 100, tokens: maxBudget}
+}
+
+// deposit credits the budget for a request
+func (b *budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > maxBudget {
+		b.tokens = maxBudget
+	}
+}
+
+// withdraw takes a token for an extra attempt, it returns false when the
+// budget is spent
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
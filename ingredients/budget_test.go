@@ -0,0 +1,35 @@
+package ingredients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetLimitsExtraAttempts(t *testing.T) {
+	b := newBudget(50)
+
+	for i := 0; i < maxBudget; i++ {
+		assert.True(t, b.withdraw())
+	}
+	assert.False(t, b.withdraw())
+
+	// two requests earn one extra attempt at 50%
+	b.deposit()
+	assert.False(t, b.withdraw())
+	b.deposit()
+	assert.True(t, b.withdraw())
+}
+
+func TestBudgetSavesUpToTheMaximum(t *testing.T) {
+	b := newBudget(100)
+	for i := 0; i < 100; i++ {
+		b.deposit()
+	}
+
+	withdrawn := 0
+	for b.withdraw() {
+		withdrawn++
+	}
+	assert.Equal(t, maxBudget, withdrawn)
+}
@@ -0,0 +1,161 @@
+// Package ingredients is the client of a remote ingredients service, used
+// when ingredient data is not owned by the coffee-service. Requests are
+// hedged and retried within a budget to cut the tail latency of the upstream.
+package ingredients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// Options configure a Client
+type Options struct {
+	// Address is the base URL of the ingredients service, e.g.
+	// http://ingredients:9090, its ingredients are read from /ingredients
+	Address string
+	// Timeout limits every attempt
+	Timeout time.Duration
+	// HedgeDelay is the time after which a second attempt is sent while the
+	// first one is still running, 0 disables hedging
+	HedgeDelay time.Duration
+	// Retries is the number of extra attempts after failed attempts and for
+	// hedging
+	Retries int
+	// RetryBudget is the percentage of requests which may make extra
+	// attempts, retries and hedges are skipped once it is spent
+	RetryBudget float64
+	// Client sends every attempt, it should not time out or retry requests
+	// on its own
+	Client  *http.Client
+	Metrics metrics.Sink
+}
+
+// Client reads ingredients from the ingredients service
+type Client struct {
+	options Options
+	budget  *budget
+}
+
+// attempt is the outcome of a single request to the ingredients service
+type attempt struct {
+	ingredients entities.Ingredients
+	err         error
+}
+
+// NewClient creates a Client
+func NewClient(options Options) *Client {
+	if options.Client == nil {
+		options.Client = clients.Default()
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+
+	return &Client{options: options, budget: newBudget(options.RetryBudget)}
+}
+
+// Find returns every ingredient. The request is sent again while the first
+// attempt is slower than the hedge delay, and after failed attempts, as long
+// as the retry budget allows. The first successful attempt wins and cancels
+// the others.
+func (c *Client) Find(ctx context.Context) (entities.Ingredients, error) {
+	defer metrics.MeasureSince(c.options.Metrics, "ingredients.request.duration", time.Now())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so the attempts still running when Find returns do not block
+	results := make(chan attempt, 1+c.options.Retries)
+	launched, running := 0, 0
+	launch := func(kind string) bool {
+		if launched > 0 {
+			if launched > c.options.Retries {
+				return false
+			}
+			if !c.budget.withdraw() {
+				c.options.Metrics.IncrCounter("ingredients.budget.exhausted", 1, metrics.Label{Name: "kind", Value: kind})
+				return false
+			}
+		}
+
+		c.options.Metrics.IncrCounter("ingredients.attempts", 1, metrics.Label{Name: "kind", Value: kind})
+		launched++
+		running++
+		go func() {
+			ingredients, err := c.fetch(ctx)
+			results <- attempt{ingredients: ingredients, err: err}
+		}()
+		return true
+	}
+
+	c.budget.deposit()
+	launch("primary")
+
+	var hedge <-chan time.Time
+	if c.options.HedgeDelay > 0 {
+		timer := time.NewTimer(c.options.HedgeDelay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var err error
+	for running > 0 {
+		select {
+		case result := <-results:
+			running--
+			if result.err == nil {
+				return result.ingredients, nil
+			}
+			err = result.err
+			if ctx.Err() == nil {
+				launch("retry")
+			}
+		case <-hedge:
+			hedge = nil
+			launch("hedge")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, err
+}
+
+// fetch makes a single attempt
+func (c *Client) fetch(ctx context.Context) (entities.Ingredients, error) {
+	if c.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.options.Address, "/")+"/ingredients", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", "application/json")
+
+	resp, err := c.options.Client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ingredients service returned %s", resp.Status)
+	}
+
+	ingredients := entities.Ingredients{}
+	if err := json.NewDecoder(resp.Body).Decode(&ingredients); err != nil {
+		return nil, err
+	}
+	return ingredients, nil
+}
@@ -0,0 +1,94 @@
+package ingredients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// upstream serves the ingredients, handle is called with the number of the
+// request before it responds
+func upstream(t *testing.T, handle func(n int32, rw http.ResponseWriter) bool) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ingredients", r.URL.Path)
+		if !handle(atomic.AddInt32(&requests, 1), rw) {
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`[{"id":1,"name":"Espresso","quantity":40,"unit":"ml"}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestClientFindsIngredients(t *testing.T) {
+	server, requests := upstream(t, func(int32, http.ResponseWriter) bool { return true })
+
+	c := NewClient(Options{Address: server.URL + "/", Timeout: time.Second})
+	ingredients, err := c.Find(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, entities.Ingredients{{ID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}}, ingredients)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestClientRetriesFailedAttempts(t *testing.T) {
+	server, requests := upstream(t, func(n int32, rw http.ResponseWriter) bool {
+		if n < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	})
+
+	c := NewClient(Options{Address: server.URL, Timeout: time.Second, Retries: 2, RetryBudget: 10})
+	_, err := c.Find(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+
+	// without retries left the last failure is returned
+	atomic.StoreInt32(requests, 0)
+	c = NewClient(Options{Address: server.URL, Timeout: time.Second, Retries: 1, RetryBudget: 10})
+	_, err = c.Find(context.Background())
+	assert.EqualError(t, err, "ingredients service returned 503 Service Unavailable")
+}
+
+func TestClientHedgesSlowAttempts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, requests := upstream(t, func(n int32, rw http.ResponseWriter) bool {
+		if n == 1 {
+			<-release
+		}
+		return true
+	})
+
+	c := NewClient(Options{Address: server.URL, Timeout: 5 * time.Second, HedgeDelay: 10 * time.Millisecond, Retries: 1, RetryBudget: 10})
+	ingredients, err := c.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, ingredients, 1)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+}
+
+func TestClientStopsRetryingWhenTheBudgetIsSpent(t *testing.T) {
+	server, requests := upstream(t, func(n int32, rw http.ResponseWriter) bool {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return false
+	})
+
+	c := NewClient(Options{Address: server.URL, Timeout: time.Second, Retries: 1, RetryBudget: 0})
+	for i := 0; i < maxBudget+5; i++ {
+		c.Find(context.Background())
+	}
+	// every request made one attempt, and the first ones one retry each
+	assert.Equal(t, int32(maxBudget+5+maxBudget), atomic.LoadInt32(requests))
+}
@@ -0,0 +1,62 @@
+// Package jobs runs background work through a queue, in memory or durable in
+// Postgres, so the workers of the service share one way of queueing,
+// retrying and giving up on their jobs whatever the queue is kept in.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// Memory queues jobs in memory, they are lost when the service stops
+	Memory = "memory"
+	// Postgres queues jobs in the job table, they survive restarts and are
+	// shared by every instance
+	Postgres = "postgres"
+)
+
+// ErrQueueFull is returned when enqueueing a job while the queue holds as
+// many jobs as it can
+var ErrQueueFull = errors.New("job queue is full")
+
+// Job is a unit of background work
+type Job struct {
+	ID int64
+	// Kind names the Handler of the job, e.g. notifications.confirmation
+	Kind    string
+	Payload json.RawMessage
+	// Attempts is the number of times the job was dequeued, including the
+	// current attempt
+	Attempts int
+}
+
+// Queue holds the jobs waiting to be handled
+type Queue interface {
+	// Enqueue queues a job of kind without blocking, ErrQueueFull when the
+	// queue can not take more
+	Enqueue(ctx context.Context, kind string, payload json.RawMessage) error
+	// Dequeue returns the next due job of one of kinds, waiting for one
+	// until ctx is done
+	Dequeue(ctx context.Context, kinds []string) (Job, error)
+	// Complete removes a dequeued job which was handled or gave up on
+	Complete(ctx context.Context, job Job) error
+	// Retry makes a dequeued job due again after delay
+	Retry(ctx context.Context, job Job, delay time.Duration) error
+	// Durable reports whether queued jobs survive the service stopping
+	Durable() bool
+}
+
+// Handler handles the jobs of a kind
+type Handler interface {
+	// Handle runs a job, a returned error is retried
+	Handle(ctx context.Context, job Job) error
+}
+
+// FailureHandler is implemented by handlers told about the jobs which failed
+// their last attempt, e.g. to keep them in a dead-letter log
+type FailureHandler interface {
+	Failed(job Job, err error)
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// pendingJob is a job of a MemoryQueue and the time it is due
+type pendingJob struct {
+	job Job
+	due time.Time
+}
+
+// MemoryQueue keeps jobs in memory, in the order they were queued. Jobs are
+// lost when the service stops, so once the ctx of Dequeue is done it returns
+// the pending jobs without waiting for their retry delay, which lets a Worker
+// make a last attempt at every one of them.
+type MemoryQueue struct {
+	mu      sync.Mutex
+	size    int
+	nextID  int64
+	pending []pendingJob
+	// changed is closed and replaced whenever a job is queued
+	changed chan struct{}
+	now     func() time.Time
+}
+
+// NewMemoryQueue creates a MemoryQueue holding up to size pending jobs
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{size: size, changed: make(chan struct{}), now: time.Now}
+}
+
+// Enqueue queues a job, ErrQueueFull when size jobs are pending
+func (q *MemoryQueue) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.size {
+		return ErrQueueFull
+	}
+	q.nextID++
+	q.push(pendingJob{job: Job{ID: q.nextID, Kind: kind, Payload: payload}, due: q.now()})
+	return nil
+}
+
+// Dequeue returns the first due job of kinds, waiting for one until ctx is
+// done. Once ctx is done the first pending job is returned even when it is
+// not due yet, or ctx.Err() when there is none.
+func (q *MemoryQueue) Dequeue(ctx context.Context, kinds []string) (Job, error) {
+	for {
+		q.mu.Lock()
+		stopping := ctx.Err() != nil
+		now := q.now()
+		var next time.Time
+		for n, p := range q.pending {
+			if !hasKind(kinds, p.job.Kind) {
+				continue
+			}
+			if stopping || !p.due.After(now) {
+				q.pending = append(q.pending[:n], q.pending[n+1:]...)
+				q.mu.Unlock()
+				p.job.Attempts++
+				return p.job, nil
+			}
+			if next.IsZero() || p.due.Before(next) {
+				next = p.due
+			}
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		if stopping {
+			return Job{}, ctx.Err()
+		}
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			wait = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-wait:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Complete forgets a job, dequeued jobs are no longer pending
+func (q *MemoryQueue) Complete(ctx context.Context, job Job) error {
+	return nil
+}
+
+// Retry queues a dequeued job again, due after delay. Retried jobs are queued
+// even when the queue is full, they were already accepted.
+func (q *MemoryQueue) Retry(ctx context.Context, job Job, delay time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.push(pendingJob{job: job, due: q.now().Add(delay)})
+	return nil
+}
+
+// Durable returns false, jobs are lost when the service stops
+func (q *MemoryQueue) Durable() bool {
+	return false
+}
+
+// push appends a pending job and wakes the waiting Dequeue calls, q.mu must
+// be held
+func (q *MemoryQueue) push(p pendingJob) {
+	q.pending = append(q.pending, p)
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// hasKind reports whether kinds holds kind
+func hasKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueueReturnsTheDueJobsOfTheKinds(t *testing.T) {
+	q := NewMemoryQueue(10)
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, "images", []byte(`{"id":1}`)))
+	require.NoError(t, q.Enqueue(ctx, "confirmations", []byte(`{"id":2}`)))
+
+	job, err := q.Dequeue(ctx, []string{"confirmations"})
+	require.NoError(t, err)
+	assert.Equal(t, Job{ID: 2, Kind: "confirmations", Payload: []byte(`{"id":2}`), Attempts: 1}, job)
+
+	// retried jobs wait for their delay, and count their attempts
+	require.NoError(t, q.Retry(ctx, job, 20*time.Millisecond))
+	start := time.Now()
+	job, err = q.Dequeue(ctx, []string{"confirmations"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(15*time.Millisecond))
+	assert.Equal(t, 2, job.Attempts)
+
+	// nothing is due for confirmations
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = q.Dequeue(cancelled, []string{"confirmations"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestMemoryQueueWakesWaitingDequeues(t *testing.T) {
+	q := NewMemoryQueue(10)
+	ctx := context.Background()
+
+	dequeued := make(chan Job, 1)
+	go func() {
+		job, _ := q.Dequeue(ctx, []string{"confirmations"})
+		dequeued <- job
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, q.Enqueue(ctx, "confirmations", []byte(`{}`)))
+
+	select {
+	case job := <-dequeued:
+		assert.Equal(t, "confirmations", job.Kind)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting dequeue was not woken")
+	}
+}
+
+func TestMemoryQueueIsBounded(t *testing.T) {
+	q := NewMemoryQueue(1)
+	ctx := context.Background()
+
+	require.NoError(t, q.Enqueue(ctx, "confirmations", []byte(`{}`)))
+	assert.Equal(t, ErrQueueFull, q.Enqueue(ctx, "confirmations", []byte(`{}`)))
+}
+
+func TestMemoryQueueReturnsPendingJobsOnceDone(t *testing.T) {
+	q := NewMemoryQueue(10)
+	require.NoError(t, q.Retry(context.Background(), Job{ID: 1, Kind: "confirmations", Attempts: 1}, time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job, err := q.Dequeue(ctx, []string{"confirmations"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Attempts)
+
+	_, err = q.Dequeue(ctx, []string{"confirmations"})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	// registers the pgx driver with database/sql
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// DefaultPollInterval is the delay between two looks for due jobs when
+	// the PostgresOptions do not set one
+	DefaultPollInterval = time.Second
+	// DefaultLease is how long a dequeued job is hidden from other workers
+	// when the PostgresOptions do not set one
+	DefaultLease = 5 * time.Minute
+)
+
+// PostgresOptions configure a PostgresQueue
+type PostgresOptions struct {
+	// PollInterval is the delay between two looks for due jobs while there
+	// are none
+	PollInterval time.Duration
+	// Lease is how long a dequeued job is hidden from the other workers. A
+	// job neither completed nor retried within its lease, e.g. because its
+	// instance stopped, is dequeued again. It must be longer than the
+	// Timeout of the workers.
+	Lease time.Duration
+}
+
+// PostgresQueue keeps jobs in the job table of data/migrations, shared by
+// every instance of the service. Dequeueing locks the first due job with
+// SKIP LOCKED, so concurrent workers never get the same job, and pushes its
+// due time past the lease instead of holding the lock.
+type PostgresQueue struct {
+	db      *sqlx.DB
+	options PostgresOptions
+}
+
+// NewPostgresQueue creates a PostgresQueue on db
+func NewPostgresQueue(db *sqlx.DB, options PostgresOptions) *PostgresQueue {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if options.Lease <= 0 {
+		options.Lease = DefaultLease
+	}
+	return &PostgresQueue{db: db, options: options}
+}
+
+// ConnectPostgres connects to the database at connection and creates a
+// PostgresQueue on it
+func ConnectPostgres(connection string, options PostgresOptions) (*PostgresQueue, error) {
+	db, err := sqlx.Connect("pgx", connection)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresQueue(db, options), nil
+}
+
+// Enqueue inserts a job due now, the table is never full
+func (q *PostgresQueue) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	_, err := q.db.ExecContext(ctx, "INSERT INTO job (kind, payload) VALUES ($1, $2)", kind, string(payload))
+	return err
+}
+
+// Dequeue leases the first due job of kinds, polling every PollInterval
+// until there is one or ctx is done
+func (q *PostgresQueue) Dequeue(ctx context.Context, kinds []string) (Job, error) {
+	for {
+		job := Job{}
+		var payload string
+		err := q.db.QueryRowContext(ctx, `
+			UPDATE job SET attempts=attempts+1, run_at=now()+$2::float8*interval '1 millisecond'
+			WHERE id=(
+				SELECT id FROM job WHERE kind=ANY($1) AND run_at<=now()
+				ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, kind, payload, attempts`,
+			kinds, float64(q.options.Lease.Milliseconds())).Scan(&job.ID, &job.Kind, &payload, &job.Attempts)
+		if err == nil {
+			job.Payload = json.RawMessage(payload)
+			return job, nil
+		}
+		if err != sql.ErrNoRows {
+			return Job{}, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		case <-time.After(q.options.PollInterval):
+		}
+	}
+}
+
+// Complete deletes a job
+func (q *PostgresQueue) Complete(ctx context.Context, job Job) error {
+	_, err := q.db.ExecContext(ctx, "DELETE FROM job WHERE id=$1", job.ID)
+	return err
+}
+
+// Retry makes a job due after delay, ending its lease
+func (q *PostgresQueue) Retry(ctx context.Context, job Job, delay time.Duration) error {
+	_, err := q.db.ExecContext(ctx, "UPDATE job SET run_at=now()+$2::float8*interval '1 millisecond' WHERE id=$1", job.ID, float64(delay.Milliseconds()))
+	return err
+}
+
+// Durable returns true, jobs survive the service stopping
+func (q *PostgresQueue) Durable() bool {
+	return true
+}
+
+// Close closes the connections to the database
+func (q *PostgresQueue) Close() error {
+	return q.db.Close()
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+const (
+	// DefaultBackoff is the delay before the first retry of a job when the
+	// WorkerOptions do not set one
+	DefaultBackoff = time.Second
+	// DefaultTimeout is the longest an attempt at a job takes when the
+	// WorkerOptions do not set one
+	DefaultTimeout = 30 * time.Second
+	// errorDelay is the delay before dequeueing again after the queue failed
+	errorDelay = time.Second
+)
+
+// WorkerOptions configure a Worker
+type WorkerOptions struct {
+	Queue Queue
+	// Handlers handle the jobs by kind, the Worker only dequeues their kinds
+	Handlers map[string]Handler
+	// Retries is the number of times a failed job is attempted again
+	Retries int
+	// Backoff is the delay before the first retry, doubled for every further
+	// retry
+	Backoff time.Duration
+	// Timeout is the longest a single attempt takes
+	Timeout time.Duration
+	Logger  hclog.Logger
+	Metrics metrics.Sink
+}
+
+// Worker handles the jobs of a queue one at a time, retrying failed ones.
+// Jobs failing every attempt are completed and reported to the Failed of
+// their handler when it is a FailureHandler. Attempts are counted in
+// jobs.handled, labelled with the kind of the job and the result, success,
+// retry or failure, and timed in jobs.duration.
+type Worker struct {
+	options WorkerOptions
+	kinds   []string
+}
+
+// NewWorker creates a Worker, jobs are only handled once Run is called
+func NewWorker(options WorkerOptions) *Worker {
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+
+	kinds := make([]string, 0, len(options.Handlers))
+	for kind := range options.Handlers {
+		kinds = append(kinds, kind)
+	}
+	return &Worker{options: options, kinds: kinds}
+}
+
+// Run handles jobs until done is closed. The jobs still queued in a queue
+// which is not Durable are then attempted once more, without retrying them.
+func (w *Worker) Run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	for {
+		job, err := w.options.Queue.Dequeue(ctx, w.kinds)
+		if err == nil {
+			// once done, the jobs a queue which is not durable returns get
+			// their last attempt
+			w.handle(job, !closed(done) || w.options.Queue.Durable())
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.options.Logger.Error("Unable to dequeue job", "error", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(errorDelay):
+		}
+	}
+}
+
+// handle makes an attempt at a job, and retries it when it fails and retry
+// is true and it has attempts left
+func (w *Worker) handle(job Job, retry bool) {
+	handler := w.options.Handlers[job.Kind]
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), w.options.Timeout)
+	err := w.attempt(ctx, handler, job)
+	cancel()
+	metrics.MeasureSince(w.options.Metrics, "jobs.duration", start, metrics.Label{Name: "kind", Value: job.Kind})
+
+	result := "success"
+	switch {
+	case err == nil:
+		w.options.Logger.Debug("Job handled", "kind", job.Kind, "id", job.ID, "attempt", job.Attempts)
+		err = w.options.Queue.Complete(context.Background(), job)
+	case retry && job.Attempts <= w.options.Retries:
+		result = "retry"
+		w.options.Logger.Warn("Job failed, retrying", "kind", job.Kind, "id", job.ID, "attempt", job.Attempts, "error", err)
+		err = w.options.Queue.Retry(context.Background(), job, w.options.Backoff<<(job.Attempts-1))
+	default:
+		result = "failure"
+		w.options.Logger.Error("Job failed", "kind", job.Kind, "id", job.ID, "attempts", job.Attempts, "error", err)
+		if failures, ok := handler.(FailureHandler); ok {
+			failures.Failed(job, err)
+		}
+		err = w.options.Queue.Complete(context.Background(), job)
+	}
+	if err != nil {
+		w.options.Logger.Error("Unable to update job", "kind", job.Kind, "id", job.ID, "error", err)
+	}
+	w.options.Metrics.IncrCounter("jobs.handled", 1, metrics.Label{Name: "kind", Value: job.Kind}, metrics.Label{Name: "result", Value: result})
+}
+
+// attempt runs the handler of a job, turning a panic into an error so one
+// bad job does not stop the worker
+func (w *Worker) attempt(ctx context.Context, handler Handler, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler.Handle(ctx, job)
+}
+
+// closed reports whether done is closed
+func closed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
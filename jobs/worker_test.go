@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHandler fails the first failures attempts of every job, and records
+// the jobs handled and failed
+type fakeHandler struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	handled  []Job
+	failed   []Job
+	panics   bool
+}
+
+func (h *fakeHandler) Handle(ctx context.Context, job Job) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.attempts++
+	if h.panics {
+		panic("bad payload")
+	}
+	if job.Attempts <= h.failures {
+		return errors.New("connection refused")
+	}
+	h.handled = append(h.handled, job)
+	return nil
+}
+
+func (h *fakeHandler) Failed(job Job, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failed = append(h.failed, job)
+}
+
+func (h *fakeHandler) done() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.handled) + len(h.failed)
+}
+
+// runUntil runs a worker of handler until it handled or gave up on count
+// jobs, then stops it
+func runUntil(t *testing.T, q Queue, handler *fakeHandler, retries, count int) {
+	w := NewWorker(WorkerOptions{
+		Queue:    q,
+		Handlers: map[string]Handler{"confirmations": handler},
+		Retries:  retries,
+		Backoff:  time.Millisecond,
+		Logger:   hclog.NewNullLogger(),
+	})
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(done)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.done() < count && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	<-stopped
+	require.Equal(t, count, handler.done())
+}
+
+func TestWorkerRetriesFailedJobs(t *testing.T) {
+	q := NewMemoryQueue(10)
+	require.NoError(t, q.Enqueue(context.Background(), "confirmations", []byte(`{}`)))
+	handler := &fakeHandler{failures: 2}
+
+	runUntil(t, q, handler, 2, 1)
+
+	assert.Equal(t, 3, handler.attempts)
+	require.Len(t, handler.handled, 1)
+	assert.Equal(t, 3, handler.handled[0].Attempts)
+	assert.Empty(t, handler.failed)
+}
+
+func TestWorkerReportsJobsFailingEveryAttempt(t *testing.T) {
+	q := NewMemoryQueue(10)
+	require.NoError(t, q.Enqueue(context.Background(), "confirmations", []byte(`{}`)))
+	handler := &fakeHandler{failures: 3}
+
+	runUntil(t, q, handler, 2, 1)
+
+	assert.Equal(t, 3, handler.attempts)
+	require.Len(t, handler.failed, 1)
+	assert.Equal(t, 3, handler.failed[0].Attempts)
+}
+
+func TestWorkerRecoversPanickingJobs(t *testing.T) {
+	q := NewMemoryQueue(10)
+	require.NoError(t, q.Enqueue(context.Background(), "confirmations", []byte(`{}`)))
+	handler := &fakeHandler{panics: true}
+
+	runUntil(t, q, handler, 0, 1)
+
+	assert.Len(t, handler.failed, 1)
+}
+
+func TestWorkerAttemptsQueuedJobsOnceWhenStopped(t *testing.T) {
+	q := NewMemoryQueue(10)
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, "confirmations", []byte(`{}`)))
+	require.NoError(t, q.Retry(ctx, Job{ID: 7, Kind: "confirmations", Attempts: 1}, time.Hour))
+	handler := &fakeHandler{failures: 1}
+
+	w := NewWorker(WorkerOptions{Queue: q, Handlers: map[string]Handler{"confirmations": handler}, Retries: 5, Logger: hclog.NewNullLogger()})
+	done := make(chan struct{})
+	close(done)
+	w.Run(done)
+
+	// the new job fails its only attempt, the retried one succeeds
+	assert.Equal(t, 2, handler.attempts)
+	assert.Len(t, handler.failed, 1)
+	assert.Len(t, handler.handled, 1)
+}
@@ -0,0 +1,201 @@
+// Package latency injects artificial latency into routes and repository
+// methods, so that demos of the observability tooling have slow requests to
+// look at.
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RepositoryPrefix starts the targets naming a repository method, e.g.
+// repository.FindByID, other targets are routes
+const RepositoryPrefix = "repository."
+
+// ErrInvalidRule is returned for a rule with a target which is neither a
+// route nor a repository method, or with a negative delay or jitter
+var ErrInvalidRule = errors.New("invalid latency rule")
+
+// Rule delays a target by Delay, plus or minus a random Jitter
+type Rule struct {
+	Delay  time.Duration
+	Jitter time.Duration
+}
+
+// ruleJSON is a Rule with its durations as strings, e.g. 800ms
+type ruleJSON struct {
+	Delay  string `json:"delay"`
+	Jitter string `json:"jitter,omitempty"`
+}
+
+// MarshalJSON writes the durations of a rule as strings
+func (r Rule) MarshalJSON() ([]byte, error) {
+	rule := ruleJSON{Delay: r.Delay.String()}
+	if r.Jitter > 0 {
+		rule.Jitter = r.Jitter.String()
+	}
+	return json.Marshal(rule)
+}
+
+// UnmarshalJSON reads the durations of a rule as strings
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	rule := ruleJSON{}
+	if err := json.Unmarshal(b, &rule); err != nil {
+		return err
+	}
+
+	delay, err := time.ParseDuration(rule.Delay)
+	if err != nil {
+		return err
+	}
+	r.Delay, r.Jitter = delay, 0
+	if rule.Jitter != "" {
+		if r.Jitter, err = time.ParseDuration(rule.Jitter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rules are the rules by target
+type Rules map[string]Rule
+
+// Validate checks the target, delay and jitter of every rule
+func (r Rules) Validate() error {
+	for target, rule := range r {
+		method := strings.TrimPrefix(target, RepositoryPrefix)
+		if (!strings.HasPrefix(target, "/") && (method == target || method == "")) || rule.Delay < 0 || rule.Jitter < 0 {
+			return fmt.Errorf("%w %s", ErrInvalidRule, target)
+		}
+	}
+	return nil
+}
+
+// Parse reads comma separated rules of a target, an equals sign, a delay and
+// optionally a tilde and a jitter, e.g.
+// /coffees=800ms,repository.FindByID=50ms~20ms
+func Parse(spec string) (Rules, error) {
+	rules := Rules{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w %s, expected target=delay", ErrInvalidRule, field)
+		}
+		durations := strings.SplitN(parts[1], "~", 2)
+		rule := Rule{}
+		var err error
+		if rule.Delay, err = time.ParseDuration(durations[0]); err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidRule, field, err)
+		}
+		if len(durations) == 2 {
+			if rule.Jitter, err = time.ParseDuration(durations[1]); err != nil {
+				return nil, fmt.Errorf("%w %s: %v", ErrInvalidRule, field, err)
+			}
+		}
+		rules[strings.TrimSpace(parts[0])] = rule
+	}
+	return rules, rules.Validate()
+}
+
+// Injector delays the targets of its rules, which can be replaced at
+// runtime. It is safe for concurrent use.
+type Injector struct {
+	mu         sync.RWMutex
+	rules      Rules
+	repository bool
+}
+
+// NewInjector creates an Injector with rules, which must be valid
+func NewInjector(rules Rules) *Injector {
+	i := &Injector{}
+	i.set(rules)
+	return i
+}
+
+// Rules returns a copy of the rules
+func (i *Injector) Rules() Rules {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	rules := Rules{}
+	for target, rule := range i.rules {
+		rules[target] = rule
+	}
+	return rules
+}
+
+// SetRules validates rules and replaces every rule with them
+func (i *Injector) SetRules(rules Rules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	i.set(rules)
+	return nil
+}
+
+// set replaces the rules with a copy of rules
+func (i *Injector) set(rules Rules) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.rules = Rules{}
+	i.repository = false
+	for target, rule := range rules {
+		i.rules[target] = rule
+		if strings.HasPrefix(target, RepositoryPrefix) {
+			i.repository = true
+		}
+	}
+}
+
+// Repository reports whether any rule targets a repository method, callers
+// can skip looking up the methods they are called from otherwise
+func (i *Injector) Repository() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.repository
+}
+
+// Wait sleeps for the delay of the first of targets with a rule, or until ctx
+// is done. It reports whether any target had a rule.
+func (i *Injector) Wait(ctx context.Context, targets ...string) bool {
+	i.mu.RLock()
+	rule, ok := Rule{}, false
+	for _, target := range targets {
+		if rule, ok = i.rules[target]; ok {
+			break
+		}
+	}
+	i.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	delay := rule.Delay
+	if rule.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(2*rule.Jitter)+1)) - rule.Jitter
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return true
+}
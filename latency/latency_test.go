@@ -0,0 +1,67 @@
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(" /coffees=800ms, repository.FindByID=50ms~20ms,")
+	require.NoError(t, err)
+	assert.Equal(t, Rules{
+		"/coffees":            {Delay: 800 * time.Millisecond},
+		"repository.FindByID": {Delay: 50 * time.Millisecond, Jitter: 20 * time.Millisecond},
+	}, rules)
+
+	rules, err = Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, spec := range []string{"/coffees", "/coffees=slow", "/coffees=1s~", "coffees=1s", "repository.=1s", "/coffees=-1s"} {
+		_, err := Parse(spec)
+		assert.True(t, errors.Is(err, ErrInvalidRule), spec)
+	}
+}
+
+func TestRulesJSON(t *testing.T) {
+	rules := Rules{}
+	require.NoError(t, json.Unmarshal([]byte(`{"/coffees":{"delay":"800ms","jitter":"200ms"},"repository.Find":{"delay":"1s"}}`), &rules))
+	assert.Equal(t, Rules{
+		"/coffees":        {Delay: 800 * time.Millisecond, Jitter: 200 * time.Millisecond},
+		"repository.Find": {Delay: time.Second},
+	}, rules)
+
+	body, err := json.Marshal(rules)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"/coffees":{"delay":"800ms","jitter":"200ms"},"repository.Find":{"delay":"1s"}}`, string(body))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"/coffees":{"delay":"slow"}}`), &rules))
+}
+
+func TestInjectorWaits(t *testing.T) {
+	i := NewInjector(Rules{"/coffees": {Delay: 20 * time.Millisecond, Jitter: 10 * time.Millisecond}})
+	assert.False(t, i.Repository())
+
+	start := time.Now()
+	assert.True(t, i.Wait(context.Background(), "/coffees/{id}", "/coffees"))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(10*time.Millisecond))
+
+	assert.False(t, i.Wait(context.Background(), "/search"))
+
+	// cancelled requests stop waiting
+	require.NoError(t, i.SetRules(Rules{"repository.Find": {Delay: time.Hour}}))
+	assert.True(t, i.Repository())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.True(t, i.Wait(ctx, "repository.Find"))
+	assert.Equal(t, Rules{"repository.Find": {Delay: time.Hour}}, i.Rules())
+
+	assert.True(t, errors.Is(i.SetRules(Rules{"coffees": {}}), ErrInvalidRule))
+	assert.Equal(t, Rules{"repository.Find": {Delay: time.Hour}}, i.Rules())
+}
@@ -0,0 +1,146 @@
+package locks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// consulPrefix is the Consul KV prefix of the lock keys
+const consulPrefix = "coffee-service/locks/"
+
+// ConsulOptions configure a ConsulLocker
+type ConsulOptions struct {
+	// Address of the Consul agent, like CONSUL_HTTP_ADDR the scheme may be
+	// omitted
+	Address string
+	// TTL of the sessions, at least 10s, renewed every half TTL
+	TTL    time.Duration
+	Client *http.Client
+}
+
+// ConsulLocker takes locks by acquiring a key under coffee-service/locks/
+// with a session of its own. The session is renewed until the lock is
+// unlocked, and Consul releases the key once it expires, e.g. because its
+// instance stopped. The lock is lost once the session can not be renewed or
+// the key is held by another session.
+type ConsulLocker struct {
+	options ConsulOptions
+}
+
+// NewConsulLocker creates a ConsulLocker
+func NewConsulLocker(options ConsulOptions) *ConsulLocker {
+	if !strings.Contains(options.Address, "://") {
+		options.Address = "http://" + options.Address
+	}
+	options.Address = strings.TrimSuffix(options.Address, "/")
+	if options.TTL <= 0 {
+		options.TTL = DefaultTTL
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	return &ConsulLocker{options: options}
+}
+
+// TryLock creates a session and acquires the key of name with it
+func (c *ConsulLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	holder, _ := os.Hostname()
+	session := struct {
+		ID string
+	}{}
+	err := c.do(ctx, http.MethodPut, "/v1/session/create", map[string]string{
+		"Name":      "coffee-service lock " + name,
+		"TTL":       c.options.TTL.String(),
+		"Behavior":  "release",
+		"LockDelay": "0s",
+	}, &session)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create consul session: %w", err)
+	}
+	destroy := func(ctx context.Context) error {
+		return c.do(ctx, http.MethodPut, "/v1/session/destroy/"+session.ID, nil, nil)
+	}
+
+	key := "/v1/kv/" + consulPrefix + url.PathEscape(name)
+	acquired := false
+	if err := c.do(ctx, http.MethodPut, key+"?acquire="+session.ID, holder, &acquired); err != nil {
+		destroy(ctx)
+		return nil, fmt.Errorf("unable to acquire consul lock: %w", err)
+	}
+	if !acquired {
+		destroy(ctx)
+		return nil, ErrLocked
+	}
+
+	check := func(ctx context.Context) error {
+		if err := c.do(ctx, http.MethodPut, "/v1/session/renew/"+session.ID, nil, nil); err != nil {
+			return err
+		}
+		entries := []struct {
+			Session string
+		}{}
+		if err := c.do(ctx, http.MethodGet, key, nil, &entries); err != nil {
+			return err
+		}
+		if len(entries) == 0 || entries[0].Session != session.ID {
+			return ErrLost
+		}
+		return nil
+	}
+	release := func(ctx context.Context) error {
+		if err := c.do(ctx, http.MethodPut, key+"?release="+session.ID, nil, nil); err != nil {
+			return err
+		}
+		return destroy(ctx)
+	}
+	return hold(c.options.TTL/2, c.options.TTL, check, release), nil
+}
+
+// do sends a request to the Consul API with body as JSON, and decodes the
+// response into out when it is not nil. A 404, e.g. of an expired session or
+// a released key, is ErrLost.
+func (c *ConsulLocker) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, c.options.Address+path, reader)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.options.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrLost
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected consul status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,146 @@
+package locks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul serves the session and KV endpoints used by ConsulLocker
+type fakeConsul struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	// holders maps the keys to the session holding them
+	holders map[string]string
+	next    int
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{sessions: map[string]bool{}, holders: map[string]string{}}
+}
+
+// expire ends a session as its TTL would, releasing its keys
+func (c *fakeConsul) expire(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, id)
+	for key, holder := range c.holders {
+		if holder == id {
+			delete(c.holders, key)
+		}
+	}
+}
+
+func (c *fakeConsul) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/v1/session/create":
+		c.next++
+		id := fmt.Sprintf("session-%d", c.next)
+		c.sessions[id] = true
+		json.NewEncoder(rw).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(path, "/v1/session/renew/"):
+		if !c.sessions[strings.TrimPrefix(path, "/v1/session/renew/")] {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Write([]byte(`[]`))
+	case strings.HasPrefix(path, "/v1/session/destroy/"):
+		delete(c.sessions, strings.TrimPrefix(path, "/v1/session/destroy/"))
+		rw.Write([]byte(`true`))
+	case strings.HasPrefix(path, "/v1/kv/"):
+		key := strings.TrimPrefix(path, "/v1/kv/")
+		holder, held := c.holders[key]
+		switch {
+		case r.Method == http.MethodGet && !held:
+			http.NotFound(rw, r)
+		case r.Method == http.MethodGet:
+			json.NewEncoder(rw).Encode([]map[string]string{{"Key": key, "Session": holder}})
+		case r.URL.Query().Get("acquire") != "":
+			id := r.URL.Query().Get("acquire")
+			acquired := c.sessions[id] && (!held || holder == id)
+			if acquired {
+				c.holders[key] = id
+			}
+			json.NewEncoder(rw).Encode(acquired)
+		case r.URL.Query().Get("release") != "":
+			released := holder == r.URL.Query().Get("release")
+			if released {
+				delete(c.holders, key)
+			}
+			json.NewEncoder(rw).Encode(released)
+		}
+	default:
+		http.NotFound(rw, r)
+	}
+}
+
+func TestConsulLockerLocksOnce(t *testing.T) {
+	consul := newFakeConsul()
+	server := httptest.NewServer(consul)
+	defer server.Close()
+	locker := NewConsulLocker(ConsulOptions{Address: strings.TrimPrefix(server.URL, "http://"), TTL: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	lock, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	_, err = locker.TryLock(ctx, "scheduler.reseed")
+	assert.Equal(t, ErrLocked, err)
+
+	// renewed past its TTL
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-lock.Lost():
+		t.Fatal("renewed lock is lost")
+	default:
+	}
+
+	require.NoError(t, lock.Unlock(ctx))
+	consul.mu.Lock()
+	assert.Empty(t, consul.holders)
+	assert.Empty(t, consul.sessions)
+	consul.mu.Unlock()
+
+	lock, err = locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock(ctx))
+}
+
+func TestConsulLockerDetectsStolenLocks(t *testing.T) {
+	consul := newFakeConsul()
+	server := httptest.NewServer(consul)
+	defer server.Close()
+	locker := NewConsulLocker(ConsulOptions{Address: server.URL, TTL: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	lock, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	// the session expires and another instance takes the key
+	consul.expire("session-1")
+	stolen, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("stolen lock is not lost")
+	}
+	lock.Unlock(ctx)
+
+	consul.mu.Lock()
+	assert.Equal(t, "session-2", consul.holders["coffee-service/locks/scheduler.reseed"])
+	consul.mu.Unlock()
+	require.NoError(t, stolen.Unlock(ctx))
+}
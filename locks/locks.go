@@ -0,0 +1,166 @@
+// Package locks provides locks shared by the instances of the service, kept
+// as Postgres advisory locks or Consul sessions, so work like the scheduled
+// jobs runs on a single instance at a time.
+package locks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// Postgres keeps locks as advisory locks of a dedicated connection
+	Postgres = "postgres"
+	// Consul keeps locks as keys acquired by a Consul session
+	Consul = "consul"
+	// DefaultTTL is how long a lock outlives an instance which stopped
+	// renewing it when the options of a Locker do not set one
+	DefaultTTL = 15 * time.Second
+)
+
+var (
+	// ErrLocked is returned by TryLock when another holder has the lock
+	ErrLocked = errors.New("lock is held by another instance")
+	// ErrLost is the error of a lock found released or held by another
+	// holder while it was held, e.g. after its session expired
+	ErrLost = errors.New("lock was lost")
+)
+
+// Locker takes named locks
+type Locker interface {
+	// TryLock takes the lock name without waiting for it, ErrLocked when
+	// another holder has it
+	TryLock(ctx context.Context, name string) (Lock, error)
+}
+
+// Lock is a lock taken by a Locker, checked until it is unlocked
+type Lock interface {
+	// Lost is closed once the lock is found lost, the work it guarded may
+	// then run elsewhere and should stop
+	Lost() <-chan struct{}
+	// Unlock releases the lock
+	Unlock(ctx context.Context) error
+}
+
+// heldLock is a Lock checked every interval until it is unlocked. It is lost
+// once a check returns ErrLost or no check succeeded for ttl.
+type heldLock struct {
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+	release  func(ctx context.Context) error
+}
+
+// hold checks a lock every interval with check, and releases it with release
+// when unlocked
+func hold(interval, ttl time.Duration, check, release func(ctx context.Context) error) *heldLock {
+	l := &heldLock{
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		release: release,
+	}
+	go l.keep(interval, ttl, check)
+	return l
+}
+
+// keep runs the checks until the lock is unlocked or lost
+func (l *heldLock) keep(interval, ttl time.Duration, check func(ctx context.Context) error) {
+	defer close(l.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	checked := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := check(ctx)
+		cancel()
+		switch {
+		case err == nil:
+			checked = time.Now()
+		case errors.Is(err, ErrLost), time.Since(checked) >= ttl:
+			l.lostOnce.Do(func() { close(l.lost) })
+			return
+		}
+	}
+}
+
+// Lost is closed once the lock is found lost
+func (l *heldLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock stops checking the lock and releases it
+func (l *heldLock) Unlock(ctx context.Context) error {
+	close(l.stop)
+	<-l.stopped
+	return l.release(ctx)
+}
+
+// MemoryLocker keeps locks in memory, they are only shared by the users of
+// the same MemoryLocker, e.g. a single instance or tests
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]*memoryLock
+}
+
+// NewMemoryLocker creates a MemoryLocker
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: map[string]*memoryLock{}}
+}
+
+// TryLock takes the lock name, ErrLocked while it is held
+func (m *MemoryLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.locks[name]; ok {
+		return nil, ErrLocked
+	}
+	l := &memoryLock{locker: m, name: name, lost: make(chan struct{})}
+	m.locks[name] = l
+	return l, nil
+}
+
+// Steal takes the lock name from its holder, whose lock is then lost, e.g.
+// to test the handling of stolen locks
+func (m *MemoryLocker) Steal(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.locks[name]; ok {
+		close(l.lost)
+	}
+	m.locks[name] = &memoryLock{locker: m, name: name, lost: make(chan struct{})}
+}
+
+// memoryLock is a lock of a MemoryLocker
+type memoryLock struct {
+	locker *MemoryLocker
+	name   string
+	lost   chan struct{}
+}
+
+func (l *memoryLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *memoryLock) Unlock(ctx context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	// a stolen lock belongs to its new holder
+	if l.locker.locks[l.name] == l {
+		delete(l.locker.locks, l.name)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+func TestMemoryLockerLocksOnce(t *testing.T) {
+	locker := NewMemoryLocker()
+	ctx := context.Background()
+
+	lock, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	_, err = locker.TryLock(ctx, "scheduler.reseed")
+	assert.Equal(t, ErrLocked, err)
+	other, err := locker.TryLock(ctx, "scheduler.cache_warm")
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock(ctx))
+
+	require.NoError(t, lock.Unlock(ctx))
+	lock, err = locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+
+	locker.Steal("scheduler.reseed")
+	select {
+	case <-lock.Lost():
+	default:
+		t.Fatal("stolen lock is not lost")
+	}
+	require.NoError(t, lock.Unlock(ctx))
+	_, err = locker.TryLock(ctx, "scheduler.reseed")
+	assert.Equal(t, ErrLocked, err)
+}
+
+func TestHeldLockIsLostWhenACheckFindsItLost(t *testing.T) {
+	released := false
+	l := hold(time.Millisecond, time.Hour, func(context.Context) error {
+		return ErrLost
+	}, func(context.Context) error {
+		released = true
+		return nil
+	})
+
+	select {
+	case <-l.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock is not lost")
+	}
+	require.NoError(t, l.Unlock(context.Background()))
+	assert.True(t, released)
+}
+
+func TestHeldLockIsLostWhenNotCheckedForItsTTL(t *testing.T) {
+	mu := sync.Mutex{}
+	failing := false
+	l := hold(time.Millisecond, 20*time.Millisecond, func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, func(context.Context) error { return nil })
+	defer l.Unlock(context.Background())
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-l.Lost():
+		t.Fatal("checked lock is lost")
+	default:
+	}
+
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	select {
+	case <-l.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock is not lost")
+	}
+}
+
+// recordingSink keeps the counters and gauges it receives
+type recordingSink struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	samples  map[string]int
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{counters: map[string]float64{}, gauges: map[string]float64{}, samples: map[string]int{}}
+}
+
+func key(name string, labels []metrics.Label) string {
+	for _, l := range labels {
+		name += "," + l.Name + "=" + l.Value
+	}
+	return name
+}
+
+func (s *recordingSink) IncrCounter(name string, value float64, labels ...metrics.Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[key(name, labels)] += value
+}
+
+func (s *recordingSink) SetGauge(name string, value float64, labels ...metrics.Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[key(name, labels)] = value
+}
+
+func (s *recordingSink) AddSample(name string, value float64, labels ...metrics.Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[key(name, labels)]++
+}
+
+func (s *recordingSink) counter(name string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[name]
+}
+
+func TestMeteredLockerRecordsLocks(t *testing.T) {
+	memory := NewMemoryLocker()
+	sink := newRecordingSink()
+	locker := NewMetered(memory, sink)
+	ctx := context.Background()
+
+	lock, err := locker.TryLock(ctx, "scheduler.reseed")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), sink.gauges["locks.held,lock=scheduler.reseed"])
+	_, err = locker.TryLock(ctx, "scheduler.reseed")
+	assert.Equal(t, ErrLocked, err)
+
+	memory.Steal("scheduler.reseed")
+	assert.Eventually(t, func() bool {
+		return sink.counter("locks.lost,lock=scheduler.reseed") == 1
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, lock.Unlock(ctx))
+
+	assert.Equal(t, float64(1), sink.counter("locks.acquire,lock=scheduler.reseed,result=acquired"))
+	assert.Equal(t, float64(1), sink.counter("locks.acquire,lock=scheduler.reseed,result=locked"))
+	assert.Equal(t, float64(0), sink.gauges["locks.held,lock=scheduler.reseed"])
+	assert.Equal(t, 1, sink.samples["locks.hold_duration,lock=scheduler.reseed"])
+}
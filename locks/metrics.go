@@ -0,0 +1,72 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// MeteredLocker is a Locker recording the locks of the Locker it wraps.
+// locks.acquire counts the attempts by lock name and result, which is
+// acquired, locked when another holder has the lock, or error. locks.held is
+// 1 while the instance holds a lock and locks.hold_duration samples how long
+// it held it in milliseconds. locks.lost counts the locks found lost, e.g.
+// stolen by another holder, while held.
+type MeteredLocker struct {
+	locker Locker
+	sink   metrics.Sink
+}
+
+// NewMetered wraps locker to record its locks in sink
+func NewMetered(locker Locker, sink metrics.Sink) *MeteredLocker {
+	return &MeteredLocker{locker: locker, sink: sink}
+}
+
+// TryLock takes the lock name from the wrapped Locker
+func (m *MeteredLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	label := metrics.Label{Name: "lock", Value: name}
+	lock, err := m.locker.TryLock(ctx, name)
+	result := "acquired"
+	switch {
+	case errors.Is(err, ErrLocked):
+		result = "locked"
+	case err != nil:
+		result = "error"
+	}
+	m.sink.IncrCounter("locks.acquire", 1, label, metrics.Label{Name: "result", Value: result})
+	if err != nil {
+		return nil, err
+	}
+
+	m.sink.SetGauge("locks.held", 1, label)
+	l := &meteredLock{Lock: lock, sink: m.sink, label: label, start: time.Now(), unlocked: make(chan struct{})}
+	go l.watch()
+	return l, nil
+}
+
+// meteredLock is a Lock of a MeteredLocker
+type meteredLock struct {
+	Lock
+	sink     metrics.Sink
+	label    metrics.Label
+	start    time.Time
+	unlocked chan struct{}
+}
+
+// watch counts the lock in locks.lost if it is lost before it is unlocked
+func (l *meteredLock) watch() {
+	select {
+	case <-l.Lost():
+		l.sink.IncrCounter("locks.lost", 1, l.label)
+	case <-l.unlocked:
+	}
+}
+
+func (l *meteredLock) Unlock(ctx context.Context) error {
+	close(l.unlocked)
+	l.sink.SetGauge("locks.held", 0, l.label)
+	metrics.MeasureSince(l.sink, "locks.hold_duration", l.start, l.label)
+	return l.Lock.Unlock(ctx)
+}
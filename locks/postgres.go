@@ -0,0 +1,107 @@
+package locks
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	// registers the pgx driver with database/sql
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresOptions configure a PostgresLocker
+type PostgresOptions struct {
+	// TTL is the longest a lock is considered held while its connection can
+	// not be checked, it is checked every half TTL
+	TTL time.Duration
+}
+
+// PostgresLocker takes session advisory locks, each on a connection of its
+// own held until the lock is unlocked. Postgres releases the lock when the
+// connection ends, e.g. because its instance stopped, and the lock is lost
+// once a check finds the connection gone or the lock released.
+type PostgresLocker struct {
+	db  *sqlx.DB
+	ttl time.Duration
+}
+
+// NewPostgresLocker creates a PostgresLocker on db
+func NewPostgresLocker(db *sqlx.DB, options PostgresOptions) *PostgresLocker {
+	if options.TTL <= 0 {
+		options.TTL = DefaultTTL
+	}
+	return &PostgresLocker{db: db, ttl: options.TTL}
+}
+
+// ConnectPostgres connects to the database at connection and creates a
+// PostgresLocker on it
+func ConnectPostgres(connection string, options PostgresOptions) (*PostgresLocker, error) {
+	db, err := sqlx.Connect("pgx", connection)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresLocker(db, options), nil
+}
+
+// TryLock takes the advisory lock of name with pg_try_advisory_lock
+func (p *PostgresLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := advisoryKey(name)
+	locked := false
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrLocked
+	}
+
+	check := func(ctx context.Context) error {
+		held := false
+		err := conn.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM pg_locks
+				WHERE locktype='advisory' AND pid=pg_backend_pid() AND granted
+				AND classid::bigint=$1 AND objid::bigint=$2 AND objsubid=1
+			)`, int64(uint64(key)>>32), int64(uint64(key)&0xffffffff)).Scan(&held)
+		switch {
+		case err != nil:
+			// the lock ends with the connection
+			return fmt.Errorf("%w: %v", ErrLost, err)
+		case !held:
+			return ErrLost
+		}
+		return nil
+	}
+	release := func(ctx context.Context) error {
+		defer conn.Close()
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+		if err != nil {
+			// discarded rather than returned to the pool still holding the
+			// lock
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		return err
+	}
+	return hold(p.ttl/2, p.ttl, check, release), nil
+}
+
+// Close closes the connections to the database
+func (p *PostgresLocker) Close() error {
+	return p.db.Close()
+}
+
+// advisoryKey returns the advisory lock key of name
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("coffee-service/" + name))
+	return int64(h.Sum64())
+}
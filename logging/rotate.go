@@ -0,0 +1,105 @@
+// Package logging provides the writers the coffee-service logs to.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer appending to a file which is rotated once it
+// grows past a maximum size. Rotated files are renamed with an increasing
+// suffix, path.1 being the most recent, and only the newest backups are kept.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending, rotating it once it grows past
+// maxSize bytes and keeping maxBackups rotated files
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first when p would grow the file
+// past the maximum size
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// open opens the current file, creating it when needed
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts every backup up by one, dropping the oldest, and moves the
+// current file to path.1
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(backup(f.path, f.maxBackups))
+	for n := f.maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(backup(f.path, n), backup(f.path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if f.maxBackups > 0 {
+		if err := os.Rename(f.path, backup(f.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// backup names the n-th rotated file
+func backup(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
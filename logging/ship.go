@@ -0,0 +1,304 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// Loki ships logs with the Loki push API, e.g. to
+	// http://loki:3100/loki/api/v1/push
+	Loki = "loki"
+	// OTLP ships logs with OTLP/HTTP JSON, e.g. to
+	// http://otel-collector:4318/v1/logs
+	OTLP = "otlp"
+)
+
+// shipAttempts is how often a batch is sent before it is dropped
+const shipAttempts = 3
+
+// Entry is a log line waiting to be shipped
+type Entry struct {
+	Time    time.Time
+	Name    string
+	Level   hclog.Level
+	Message string
+	Fields  map[string]string
+}
+
+// ShipperOptions configure a Shipper
+type ShipperOptions struct {
+	// Format is Loki or OTLP
+	Format   string
+	Endpoint string
+	// Level is the minimum level of the entries shipped
+	Level hclog.Level
+	// BatchSize is the most entries sent in a single request
+	BatchSize int
+	// Interval is the longest an entry waits before its batch is sent
+	Interval time.Duration
+	// Buffer is the number of entries held while a batch is sent. Entries
+	// are dropped, rather than blocking the service, while it is full.
+	Buffer int
+	Client *http.Client
+}
+
+// Shipper is an hclog.SinkAdapter batching log entries and pushing them to a
+// Loki or OTLP logs endpoint, so no log agent sidecar is needed
+type Shipper struct {
+	options ShipperOptions
+	encode  func(service string, entries []Entry) ([]byte, error)
+	entries chan Entry
+	dropped uint64
+}
+
+// NewShipper creates a Shipper, entries are only sent once Run is called
+func NewShipper(options ShipperOptions) (*Shipper, error) {
+	s := &Shipper{options: options, entries: make(chan Entry, options.Buffer)}
+
+	switch options.Format {
+	case Loki:
+		s.encode = encodeLoki
+	case OTLP:
+		s.encode = encodeOTLP
+	default:
+		return nil, fmt.Errorf("unknown log shipping format %q", options.Format)
+	}
+
+	return s, nil
+}
+
+// Accept queues an entry without blocking, dropping it when the buffer is
+// full
+func (s *Shipper) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
+	if level < s.options.Level {
+		return
+	}
+
+	fields := make(map[string]string, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = fmt.Sprint(args[i+1])
+	}
+
+	select {
+	case s.entries <- Entry{Time: time.Now(), Name: name, Level: level, Message: msg, Fields: fields}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Dropped returns the number of entries dropped so far
+func (s *Shipper) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Run sends batches of entries until done is closed, then sends the entries
+// still queued
+func (s *Shipper) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, s.options.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.send(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.options.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-done:
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+					if len(batch) >= s.options.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send pushes a batch, retrying with a growing delay, and drops it when
+// every attempt failed. Failures are not logged as they would be shipped
+// again.
+func (s *Shipper) send(batch []Entry) {
+	body, err := s.encode("coffee-service", batch)
+	if err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+
+	for attempt := 1; attempt <= shipAttempts; attempt++ {
+		resp, err := s.options.Client.Post(s.options.Endpoint, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+		}
+
+		if attempt < shipAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+
+	atomic.AddUint64(&s.dropped, uint64(len(batch)))
+}
+
+// encodeLoki encodes a Loki push request with a stream per level
+func encodeLoki(service string, entries []Entry) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	streams := map[hclog.Level]*stream{}
+	order := make([]hclog.Level, 0)
+	for _, e := range entries {
+		st, ok := streams[e.Level]
+		if !ok {
+			st = &stream{Stream: map[string]string{"service": service, "level": levelName(e.Level)}}
+			streams[e.Level] = st
+			order = append(order, e.Level)
+		}
+
+		line := map[string]string{"msg": e.Message}
+		if e.Name != "" {
+			line["logger"] = e.Name
+		}
+		for k, v := range e.Fields {
+			line[k] = v
+		}
+		d, err := json.Marshal(line)
+		if err != nil {
+			return nil, err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(d)})
+	}
+
+	push := struct {
+		Streams []*stream `json:"streams"`
+	}{Streams: make([]*stream, 0, len(order))}
+	for _, level := range order {
+		push.Streams = append(push.Streams, streams[level])
+	}
+
+	return json.Marshal(push)
+}
+
+// otlpAttribute is an OTLP key value pair holding a string
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// newOTLPAttribute creates a string attribute
+func newOTLPAttribute(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
+
+// encodeOTLP encodes an OTLP/HTTP JSON ExportLogsServiceRequest
+func encodeOTLP(service string, entries []Entry) ([]byte, error) {
+	type body struct {
+		StringValue string `json:"stringValue"`
+	}
+	type record struct {
+		TimeUnixNano   string          `json:"timeUnixNano"`
+		SeverityNumber int             `json:"severityNumber"`
+		SeverityText   string          `json:"severityText"`
+		Body           body            `json:"body"`
+		Attributes     []otlpAttribute `json:"attributes"`
+	}
+
+	records := make([]record, 0, len(entries))
+	for _, e := range entries {
+		attributes := make([]otlpAttribute, 0, len(e.Fields)+1)
+		if e.Name != "" {
+			attributes = append(attributes, newOTLPAttribute("logger", e.Name))
+		}
+		for k, v := range e.Fields {
+			attributes = append(attributes, newOTLPAttribute(k, v))
+		}
+
+		records = append(records, record{
+			TimeUnixNano:   strconv.FormatInt(e.Time.UnixNano(), 10),
+			SeverityNumber: severityNumber(e.Level),
+			SeverityText:   levelName(e.Level),
+			Body:           body{StringValue: e.Message},
+			Attributes:     attributes,
+		})
+	}
+
+	request := map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{newOTLPAttribute("service.name", service)},
+			},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]string{"name": service},
+				"logRecords": records,
+			}},
+		}},
+	}
+
+	return json.Marshal(request)
+}
+
+// levelName returns the upper case name of a level
+func levelName(level hclog.Level) string {
+	switch level {
+	case hclog.Trace:
+		return "TRACE"
+	case hclog.Debug:
+		return "DEBUG"
+	case hclog.Info:
+		return "INFO"
+	case hclog.Warn:
+		return "WARN"
+	case hclog.Error:
+		return "ERROR"
+	}
+	return "UNSPECIFIED"
+}
+
+// severityNumber maps a level to the OTLP severity number of its range
+func severityNumber(level hclog.Level) int {
+	switch level {
+	case hclog.Trace:
+		return 1
+	case hclog.Debug:
+		return 5
+	case hclog.Info:
+		return 9
+	case hclog.Warn:
+		return 13
+	case hclog.Error:
+		return 17
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupShipper(t *testing.T, format, endpoint string, buffer int) *Shipper {
+	s, err := NewShipper(ShipperOptions{
+		Format:    format,
+		Endpoint:  endpoint,
+		Level:     hclog.Info,
+		BatchSize: 10,
+		Interval:  time.Hour,
+		Buffer:    buffer,
+		Client:    http.DefaultClient,
+	})
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestShipperPushesToLoki(t *testing.T) {
+	requests := make(chan []byte, 1)
+	loki := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		d, _ := ioutil.ReadAll(r.Body)
+		requests <- d
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer loki.Close()
+
+	s := setupShipper(t, Loki, loki.URL, 10)
+	logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{Name: "coffee-service", Output: ioutil.Discard})
+	logger.RegisterSink(s)
+	logger.Debug("not shipped")
+	logger.Info("Repository initialized", "version", "v3")
+
+	done := make(chan struct{})
+	close(done)
+	s.Run(done)
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal(<-requests, &push))
+	require.Len(t, push.Streams, 1)
+	assert.Equal(t, map[string]string{"service": "coffee-service", "level": "INFO"}, push.Streams[0].Stream)
+	require.Len(t, push.Streams[0].Values, 1)
+	assert.JSONEq(t, `{"msg":"Repository initialized","logger":"coffee-service","version":"v3"}`, push.Streams[0].Values[0][1])
+}
+
+func TestShipperEncodesOTLP(t *testing.T) {
+	d, err := encodeOTLP("coffee-service", []Entry{{Time: time.Unix(1, 0), Level: hclog.Warn, Message: "slow query"}})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"resourceLogs":[{
+		"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"coffee-service"}}]},
+		"scopeLogs":[{"scope":{"name":"coffee-service"},"logRecords":[
+			{"timeUnixNano":"1000000000","severityNumber":13,"severityText":"WARN","body":{"stringValue":"slow query"},"attributes":[]}
+		]}]
+	}]}`, string(d))
+}
+
+func TestShipperDropsEntriesWhenFull(t *testing.T) {
+	s := setupShipper(t, Loki, "http://localhost:1", 2)
+
+	for i := 0; i < 5; i++ {
+		s.Accept("coffee-service", hclog.Info, "queued")
+	}
+	assert.Equal(t, uint64(3), s.Dropped())
+}
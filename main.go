@@ -1,12 +1,41 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
+	"github.com/hashicorp-demoapp/coffee-service/assets"
+	"github.com/hashicorp-demoapp/coffee-service/check"
+	"github.com/hashicorp-demoapp/coffee-service/clients"
 	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/crashreport"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/data/queue"
+	"github.com/hashicorp-demoapp/coffee-service/images"
+	"github.com/hashicorp-demoapp/coffee-service/jobs"
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+	"github.com/hashicorp-demoapp/coffee-service/locks"
+	"github.com/hashicorp-demoapp/coffee-service/logging"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+	"github.com/hashicorp-demoapp/coffee-service/notifications"
+	"github.com/hashicorp-demoapp/coffee-service/payments"
+	"github.com/hashicorp-demoapp/coffee-service/pressure"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+	"github.com/hashicorp-demoapp/coffee-service/receipts"
+	"github.com/hashicorp-demoapp/coffee-service/scheduler"
 	"github.com/hashicorp-demoapp/coffee-service/service"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+	"github.com/hashicorp-demoapp/coffee-service/slo"
+	"github.com/hashicorp-demoapp/coffee-service/spiffe"
 
 	"github.com/gorilla/mux"
 	hclog "github.com/hashicorp/go-hclog"
@@ -14,6 +43,10 @@ import (
 	// opentracing "github.com/opentracing/opentracing-go"
 )
 
+// configFile optionally names an HCL or YAML configuration file, values set
+// in the environment take precedence over it
+var configFile = flag.String("config", "", "HCL or YAML configuration file, overridden by environment variables")
+
 func main() {
 	// Lifecycle event
 	hclog.Default().Info("Starting coffee-service")
@@ -29,24 +62,300 @@ func main() {
 	// Lifecycle event
 	hclog.Default().Info("Finished parsing environment variables")
 
+	// subcommands run instead of the service
+	switch flag.Arg(0) {
+	case "check":
+		os.Exit(preflight(*configFile))
+	case "config":
+		os.Exit(describeConfig(*configFile, flag.Args()[1:]))
+	}
+
 	var cfg *config.Config
 	// Lifecycle event
-	hclog.Default().Info("Loading configuration from environment")
-	if cfg, err = config.NewFromEnv(); err != nil {
+	hclog.Default().Info("Loading configuration", "file", *configFile)
+	if cfg, err = config.NewFromFileAndEnv(*configFile); err != nil {
 		// Unrecoverable error
 		hclog.Default().Error("Error reading configuration", "error", err)
 		os.Exit(1)
 	}
 	// Lifecycle event
-	cfg.Logger.Info("Finished loading configuration from environment")
+	cfg.Logger.Info("Finished loading configuration")
+
+	if cfg.LogShipFormat != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing log shipping", "format", cfg.LogShipFormat, "endpoint", cfg.LogShipEndpoint)
+		client, err := clients.New(clients.FromConfig(cfg, "log-shipping"))
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize log shipping client", "error", err)
+			os.Exit(1)
+		}
+		shipper, err := logging.NewShipper(logging.ShipperOptions{
+			Format:    cfg.LogShipFormat,
+			Endpoint:  cfg.LogShipEndpoint,
+			Level:     cfg.LogLevel,
+			BatchSize: cfg.LogShipBatchSize,
+			Interval:  cfg.LogShipInterval,
+			Buffer:    cfg.LogShipBuffer,
+			Client:    client,
+		})
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize log shipping", "error", err)
+			os.Exit(1)
+		}
+		shipperDone := make(chan struct{})
+		defer close(shipperDone)
+		go shipper.Run(shipperDone)
+		cfg.Logger.(hclog.InterceptLogger).RegisterSink(shipper)
+		// Component initialized
+		cfg.Logger.Info("Log shipping initialized")
+	}
 
 	// Lifecycle event
 	cfg.Logger.Info("Initializing router")
 	router := mux.NewRouter()
+	// global and per route group middleware, the groups are enabled by
+	// MIDDLEWARE_<GROUP>
+	routes := service.NewRouterBuilder(router, cfg)
 
 	/*
 	   Configure middleware here
 	*/
+	// registered first so it logs the response sent to the client
+	if cfg.AccessLogFormat != "" {
+		// Lifecycle event
+		cfg.Logger.Info("Registering access log middleware", "format", cfg.AccessLogFormat, "file", cfg.AccessLogFile)
+		var accessLog io.Writer = os.Stdout
+		if cfg.AccessLogFile != "" {
+			file, err := logging.NewRotatingFile(cfg.AccessLogFile, int64(cfg.AccessLogMaxSize)<<20, cfg.AccessLogMaxBackups)
+			if err != nil {
+				// Unrecoverable error
+				cfg.Logger.Error("Unable to open access log", "error", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			accessLog = file
+		}
+		routes.UseGlobal("access_log", middleware.NewAccessLog(cfg.AccessLogFormat, accessLog))
+	}
+
+	// registered next so the access log reads the request ID from the
+	// response headers, and outside the envelope which adds the IDs itself
+	// Lifecycle event
+	cfg.Logger.Info("Registering request ID middleware")
+	routes.UseGlobal("request_id", middleware.NewRequestID())
+
+	// Component initialization
+	cfg.Logger.Info("Initializing metrics")
+	sinks := metrics.FanoutSink{}
+	if cfg.MetricsAddress != "" {
+		prometheus := metrics.NewPrometheusSink()
+		sinks = append(sinks, prometheus)
+
+		// Lifecycle event
+		cfg.Logger.Info("Starting metrics listener", "bind", cfg.MetricsAddress)
+		metricsRouter := http.NewServeMux()
+		metricsRouter.Handle("/metrics", prometheus)
+		go func() {
+			if err := http.ListenAndServe(cfg.MetricsAddress, metricsRouter); err != nil {
+				cfg.Logger.Error("Metrics listener stopped.", "error", err)
+			}
+		}()
+	}
+	if cfg.StatsdAddress != "" {
+		statsd, err := metrics.NewStatsdSink(cfg.StatsdAddress, cfg.StatsdFormat)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize StatsD metrics", "error", err)
+			os.Exit(1)
+		}
+		defer statsd.Close()
+		sinks = append(sinks, statsd)
+	}
+	cfg.Metrics = sinks
+	if len(sinks) > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering metrics middleware", "sinks", len(sinks))
+		routes.UseGlobal("metrics", middleware.NewMetrics(sinks))
+
+		if cfg.GCMetricsInterval > 0 {
+			// Lifecycle event
+			cfg.Logger.Info("Starting garbage collector metrics", "interval", cfg.GCMetricsInterval)
+			gcMetricsDone := make(chan struct{})
+			defer close(gcMetricsDone)
+			go metrics.NewRuntimeCollector(sinks).Run(cfg.GCMetricsInterval, gcMetricsDone)
+		}
+	}
+	// Component initialized
+	cfg.Logger.Info("Metrics initialized")
+
+	// registered before the route middleware so it counts every request,
+	// including those rejected by it
+	// Lifecycle event
+	cfg.Logger.Info("Registering drain middleware")
+	drainer := middleware.NewDrainer(sinks)
+	routes.UseGlobal("drain", drainer.Middleware())
+
+	if cfg.MirrorURL != "" {
+		// Lifecycle event
+		cfg.Logger.Info("Registering mirror middleware", "url", cfg.MirrorURL, "percent", cfg.MirrorPercent)
+		// the copies are fire and forget, a failed one is not worth retrying
+		options := clients.FromConfig(cfg, "mirror")
+		options.Retries = 0
+		mirrorClient, err := clients.New(options)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize mirror client", "error", err)
+			os.Exit(1)
+		}
+		mirrorURL, err := url.Parse(cfg.MirrorURL)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Invalid mirror URL", "error", err)
+			os.Exit(1)
+		}
+		routes.UseGlobal("mirror", middleware.NewMirror(mirrorURL, cfg.MirrorPercent, mirrorClient, sinks, cfg.Logger))
+	}
+
+	var sloTracker *slo.Tracker
+	if cfg.SLOWindow > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering SLO middleware", "window", cfg.SLOWindow, "availability", cfg.SLOAvailability, "latency", cfg.SLOLatency, "latency_target", cfg.SLOLatencyTarget)
+		sloTracker = slo.NewTracker(slo.Objectives{
+			Availability:  cfg.SLOAvailability,
+			Latency:       cfg.SLOLatency,
+			LatencyTarget: cfg.SLOLatencyTarget,
+		}, cfg.SLOWindow)
+		routes.UseGlobal("slo", middleware.NewSLO(sloTracker))
+	}
+
+	// registered next so it times the whole request
+	var liveness service.Liveness = alwaysLive{}
+	if cfg.WatchdogLimit > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering watchdog middleware", "limit", cfg.WatchdogLimit)
+		watchdog := middleware.NewWatchdog(cfg.WatchdogLimit, cfg.Logger)
+		routes.UseGlobal("watchdog", watchdog.Middleware())
+		watchdogDone := make(chan struct{})
+		defer close(watchdogDone)
+		go watchdog.Run(watchdogDone)
+		liveness = watchdog
+	}
+
+	if cfg.RequestTimeout > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering deadline middleware", "timeout", cfg.RequestTimeout)
+		routes.UseGlobal("deadline", middleware.NewDeadline(cfg.RequestTimeout))
+	}
+
+	// registered inside the metrics, SLO and watchdog middleware so they
+	// record the 500 of a panicking handler
+	var crashReporter middleware.CrashReporter
+	if cfg.CrashReportDSN != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing crash reporting")
+		client, err := clients.New(clients.FromConfig(cfg, "crash-reports"))
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize crash reporting client", "error", err)
+			os.Exit(1)
+		}
+		reporter, err := crashreport.NewReporter(cfg.CrashReportDSN, client)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize crash reporting", "error", err)
+			os.Exit(1)
+		}
+		reporterDone := make(chan struct{})
+		defer close(reporterDone)
+		go reporter.Run(reporterDone)
+		crashReporter = reporter
+		// Component initialized
+		cfg.Logger.Info("Crash reporting initialized")
+	}
+	// Lifecycle event
+	cfg.Logger.Info("Registering recovery middleware")
+	routes.UseGlobal("recovery", middleware.NewRecovery(cfg.Logger, sinks, crashReporter))
+
+	// registered before the middleware rewriting bodies so it compresses the
+	// final body
+	if cfg.Compression {
+		// Lifecycle event
+		cfg.Logger.Info("Registering compression middleware", "brotli_quality", cfg.BrotliQuality)
+		routes.UseGlobal("compression", middleware.NewCompression(cfg.BrotliQuality))
+	}
+
+	// registered first so it reports the headers after the envelope has
+	// buffered the whole response
+	if cfg.DBStatsHeaders {
+		// Lifecycle event
+		cfg.Logger.Info("Registering database statistics middleware")
+		routes.UseGlobal("dbstats", middleware.NewDBStats())
+	}
+
+	// registered next so the _debug section is added to the enveloped body
+	if cfg.DebugRequests {
+		// Lifecycle event
+		cfg.Logger.Info("Registering debug middleware")
+		routes.UseGlobal("debug", middleware.NewDebug(cfg.AuthToken))
+	}
+
+	var slowQueries *data.SlowQueryLog
+	if cfg.SlowQueryThreshold > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering slow query middleware", "threshold", cfg.SlowQueryThreshold, "limit", cfg.SlowQueryLimit)
+		slowQueries = data.NewSlowQueryLog(cfg.SlowQueryThreshold, cfg.SlowQueryLimit)
+		routes.UseGlobal("slow_queries", middleware.NewSlowQueries(slowQueries))
+	}
+
+	// like the database statistics it sets a header after the envelope has
+	// buffered the response
+	if cfg.SnapshotTTL > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering read snapshot middleware", "ttl", cfg.SnapshotTTL)
+		routes.UseGlobal("snapshot", middleware.NewSnapshot())
+	}
+
+	if cfg.StrictJSON {
+		// Lifecycle event
+		cfg.Logger.Info("Registering strict JSON middleware")
+		routes.UseGlobal("strict_json", middleware.NewStrictJSON())
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering store middleware")
+	routes.UseGlobal("store", middleware.NewStore())
+
+	// v1 keeps returning raw arrays for backwards compatibility
+	if cfg.ResponseEnvelope && cfg.Version != config.V1 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering response envelope middleware")
+		routes.UseGlobal("envelope", middleware.NewEnvelope(cfg.Version.String()))
+	}
+
+	// the spiffe route group middleware authorizes the identity attached here
+	if cfg.TLSClientCAFile != "" {
+		// Lifecycle event
+		cfg.Logger.Info("Registering peer identity middleware", "trust_domain", cfg.SPIFFETrustDomain)
+		routes.UseGlobal("peer_identity", middleware.NewPeerIdentity())
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing latency injector", "rules", cfg.LatencyRules)
+	// validated with the configuration
+	latencyRules, _ := latency.Parse(cfg.LatencyRules)
+	latencyInjector := latency.NewInjector(latencyRules)
+	// inside the group middleware, so traces include the latency and cached
+	// responses skip it
+	routes.Use("latency", middleware.NewLatency(latencyInjector))
+	// Component initialized
+	cfg.Logger.Info("Latency injector initialized")
+	healthRoutes := routes.Group(config.HealthRoutes)
+	coffeesRoutes := routes.Group(config.CoffeesRoutes)
+	searchRoutes := routes.Group(config.SearchRoutes)
+	adminRoutes := routes.Group(config.AdminRoutes)
+	ordersRoutes := routes.Group(config.OrdersRoutes)
 
 	// Lifecycle event
 	cfg.Logger.Info("Router initialized")
@@ -65,13 +374,209 @@ func main() {
 
 	// Lifecycle event
 	cfg.Logger.Info("Registering health handler")
-	router.Handle("/health", healthService).Methods("GET")
+	healthRoutes.Handle("/health", healthService).Methods("GET")
 	// Lifecycle event
 	cfg.Logger.Info("Health handler registered")
 
+	// Lifecycle event
+	cfg.Logger.Info("Registering liveness handler")
+	router.Handle("/health/live", service.NewLiveness(liveness, cfg.Logger)).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Liveness handler registered")
+
+	if sloTracker != nil {
+		// Lifecycle event
+		cfg.Logger.Info("Registering SLO handler")
+		adminRoutes.Handle("/admin/slo", service.NewSLO(sloTracker, cfg.Logger)).Methods("GET")
+		// Lifecycle event
+		cfg.Logger.Info("SLO handler registered")
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering latency handler")
+	adminRoutes.Handle("/admin/latency", service.NewLatency(latencyInjector, cfg.Logger)).Methods("GET", "PUT", "DELETE")
+	// Lifecycle event
+	cfg.Logger.Info("Latency handler registered")
+
+	// guarded by the authentication of the admin routes, see Validate
+	if cfg.MemoryPressureLimit > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering memory pressure handler", "limit_mb", cfg.MemoryPressureLimit)
+		adminRoutes.Handle("/admin/memory", service.NewMemory(pressure.NewMemory(cfg.MemoryPressureLimit), cfg.Logger)).Methods("GET", "POST", "DELETE")
+		// Lifecycle event
+		cfg.Logger.Info("Memory pressure handler registered")
+	}
+
+	// guarded by the authentication of the admin routes, see Validate
+	if cfg.CPUBurnCores > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering CPU burn handler", "cores", cfg.CPUBurnCores, "max_duration", cfg.CPUBurnMaxDuration)
+		adminRoutes.Handle("/admin/burn", service.NewBurn(pressure.NewCPU(cfg.CPUBurnCores, cfg.CPUBurnMaxDuration), cfg.Logger)).Methods("GET", "POST", "DELETE")
+		// Lifecycle event
+		cfg.Logger.Info("CPU burn handler registered")
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering drain handler")
+	adminRoutes.Handle("/admin/drain", service.NewDrain(drainer, cfg.Logger)).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Drain handler registered")
+
+	if slowQueries != nil {
+		// Lifecycle event
+		cfg.Logger.Info("Registering slow queries handler")
+		adminRoutes.Handle("/admin/slow-queries", service.NewSlowQueries(slowQueries, cfg.Logger)).Methods("GET", "DELETE")
+		// Lifecycle event
+		cfg.Logger.Info("Slow queries handler registered")
+	}
+
+	var base data.Repository
+	var replica *data.RaftRepository
+	if cfg.RaftNodeID != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing Raft replica", "id", cfg.RaftNodeID, "bind", cfg.RaftBindAddress)
+		replica, err = service.NewRaftRepository(cfg)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize Raft replica", "error", err)
+			os.Exit(1)
+		}
+		defer replica.Close()
+		base = replica
+		// Component initialized
+		cfg.Logger.Info("Raft replica initialized")
+
+		// Lifecycle event
+		cfg.Logger.Info("Registering Raft handler")
+		raftService := service.NewRaft(replica, cfg.Logger)
+		adminRoutes.Handle("/admin/raft", raftService).Methods("GET")
+		adminRoutes.Handle(data.RaftApplyPath, raftService).Methods("POST")
+		// Lifecycle event
+		cfg.Logger.Info("Raft handler registered")
+	}
+
+	if cfg.SessionTokens {
+		// Lifecycle event
+		cfg.Logger.Info("Registering session token middleware")
+		sessionClient, err := clients.New(clients.FromConfig(cfg, "session"))
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize session routing client", "error", err)
+			os.Exit(1)
+		}
+		// a nil *RaftRepository would be a Replica which is not nil
+		var sessionReplica middleware.Replica
+		if replica != nil {
+			sessionReplica = replica
+		}
+		routes.UseGlobal("session", middleware.NewSession(sessionReplica, sessionClient.Transport, cfg.Logger))
+	}
+
+	// Component initialization
+	cfg.Logger.Info(fmt.Sprintf("Initializing Repository version %s", cfg.Version))
+	repository, err := service.NewRepository(cfg, base)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize Repository", "error", err)
+		os.Exit(1)
+	}
+	// Component initialized
+	cfg.Logger.Info("Repository initialized")
+
+	if len(sinks) > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Recording repository metrics")
+		repository = data.NewMetered(repository, sinks)
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering readiness handler")
+	var dependencies []service.Dependency
+	if cfg.VaultAddress != "" || cfg.ConsulAddress != "" {
+		readinessClient, err := clients.New(clients.FromConfig(cfg, "readiness"))
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize readiness client", "error", err)
+			os.Exit(1)
+		}
+		if cfg.VaultAddress != "" {
+			dependencies = append(dependencies, service.Dependency{Name: "vault", Check: func(ctx context.Context) error {
+				return check.VaultHealth(ctx, readinessClient, cfg.VaultAddress)
+			}})
+		}
+		if cfg.ConsulAddress != "" {
+			dependencies = append(dependencies, service.Dependency{Name: "consul", Check: func(ctx context.Context) error {
+				return check.ConsulLeader(ctx, readinessClient, cfg.ConsulAddress)
+			}})
+		}
+	}
+	router.Handle("/health/ready", service.NewReadiness(repository, drainer, dependencies, cfg.Logger)).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Readiness handler registered")
+
+	if cfg.SeedScale > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Generating coffees", "count", cfg.SeedScale, "seed", cfg.SeedRandom, "mode", cfg.SeedMode)
+		created, err := data.SeedCoffees(context.Background(), repository, cfg.SeedScale, cfg.SeedRandom, cfg.SeedMode)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to generate coffees", "error", err)
+			os.Exit(1)
+		}
+		// Lifecycle event
+		cfg.Logger.Info("Generated coffees", "created", created)
+	}
+
+	// wrapped after the coffees are generated, consumers of the feed start
+	// from a full read. The search and suggest indexes follow the changes
+	// even when the feed keeps none.
+	// Lifecycle event
+	cfg.Logger.Info("Recording changes", "retention", cfg.ChangesRetention)
+	changes := data.NewChanges(repository, cfg.ChangesRetention)
+	repository = changes
+
+	// the public routes serve the menu of published coffees available now, of
+	// the store named by X-Store if any, the admin routes every coffee. The
+	// search and suggest indexes hold every published coffee regardless of
+	// availability and store.
+	published := data.NewPublished(repository)
+	menu := data.NewStoreScoped(data.NewScheduled(published))
+
+	// Component initialization
+	cfg.Logger.Info("Initializing popularity tracker", "file", cfg.PopularityFile)
+	tracker, err := popularity.NewTracker(cfg.PopularityFile, cfg.Logger)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize popularity tracker", "error", err)
+		os.Exit(1)
+	}
+	trackerDone := make(chan struct{})
+	defer close(trackerDone)
+	go tracker.Run(popularity.FlushInterval, trackerDone)
+	// Component initialized
+	cfg.Logger.Info("Popularity tracker initialized")
+
+	var simulator *queue.Simulator
+	if cfg.Baristas > 0 {
+		// Component initialization
+		cfg.Logger.Info("Initializing barista queue", "baristas", cfg.Baristas)
+		simulator = queue.NewSimulator(cfg.Baristas)
+		queueDone := make(chan struct{})
+		defer close(queueDone)
+		go simulator.Run(queue.RecomputeInterval, queueDone)
+		// Component initialized
+		cfg.Logger.Info("Barista queue initialized")
+	}
+
+	if cfg.FastJSON {
+		// Lifecycle event
+		cfg.Logger.Info("Registering fast JSON encoder")
+		encoding.Default.Register(encoding.FastJSON{})
+	}
+
 	// Component initialization
 	cfg.Logger.Info(fmt.Sprintf("Initializing CoffeeService version %s", cfg.Version))
-	coffeeService, err := service.NewCoffee(cfg)
+	coffeeService, err := service.NewCoffee(cfg, menu, tracker)
 	if err != nil {
 		// Unrecoverable error
 		cfg.Logger.Error("Unable to initialize CoffeeService", "error", err)
@@ -82,16 +587,560 @@ func main() {
 
 	// Lifecycle event
 	cfg.Logger.Info("Registering coffee handler")
-	router.Handle("/coffees", coffeeService).Methods("GET")
+	coffeesRoutes.Handle("/coffees", coffeeService).Methods("GET")
 	// Lifecycle event
 	cfg.Logger.Info("Coffee handler registered")
 
+	// Component initialization
+	cfg.Logger.Info("Initializing CreateService", "duplicate_similarity", cfg.DuplicateSimilarity)
+	createService := service.NewCreate(repository, cfg.DuplicateSimilarity, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("CreateService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering coffee creation handler")
+	coffeesRoutes.Handle("/coffees", createService).Methods("POST")
+	// Lifecycle event
+	cfg.Logger.Info("Coffee creation handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing DetailService")
+	detailService := service.NewDetail(menu, tracker, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("DetailService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering coffee detail handler")
+	coffeesRoutes.Handle("/coffees/{id:[0-9]+}", detailService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Coffee detail handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing TrendingService")
+	trendingService := service.NewTrending(menu, tracker, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("TrendingService initialized")
+
 	// Lifecycle event
-	cfg.Logger.Info("Starting service listener", "bind", cfg.BindAddress)
-	err = http.ListenAndServe(cfg.BindAddress, router)
+	cfg.Logger.Info("Registering trending coffees handler")
+	coffeesRoutes.Handle("/coffees/trending", trendingService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Trending coffees handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing RelatedService")
+	relatedService := service.NewRelated(menu, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("RelatedService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering related coffees handler")
+	coffeesRoutes.Handle("/coffees/{id:[0-9]+}/related", relatedService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Related coffees handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing SearchService")
+	searchIndex, err := service.NewSearchIndex(published)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to build search index", "error", err)
+		os.Exit(1)
+	}
+	changes.OnChange(service.IndexChanges(searchIndex, published, cfg.Logger))
+	searchService := service.NewSearch(searchIndex, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("SearchService initialized", "coffees", searchIndex.Len())
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering search handler")
+	searchRoutes.Handle("/search", searchService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Search handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing SuggestService")
+	suggestTrie, err := service.NewSuggestTrie(published, tracker)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to build suggest trie", "error", err)
+		os.Exit(1)
+	}
+	changes.OnChange(service.SuggestChanges(suggestTrie, published, tracker, cfg.Logger))
+	suggestService := service.NewSuggest(suggestTrie, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("SuggestService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering suggest handler")
+	coffeesRoutes.Handle("/coffees/suggest", suggestService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Suggest handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing StoresService")
+	storesService := service.NewStores(menu, cfg.Logger)
+	storeCoffeesService := service.NewStoreCoffees(menu, cfg.Logger)
+	nearbyStoresService := service.NewNearbyStores(menu, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("StoresService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering stores handlers")
+	coffeesRoutes.Handle("/stores", storesService).Methods("GET")
+	coffeesRoutes.Handle("/stores/nearby", nearbyStoresService).Methods("GET")
+	coffeesRoutes.Handle("/stores/{id:[0-9]+}/coffees", storeCoffeesService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Stores handlers registered")
+
+	if simulator != nil {
+		// Lifecycle event
+		cfg.Logger.Info("Registering queue handler")
+		coffeesRoutes.Handle("/queue", service.NewQueue(simulator, cfg.Logger)).Methods("GET")
+		// Lifecycle event
+		cfg.Logger.Info("Queue handler registered")
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing SuppliersService")
+	suppliersService := service.NewSuppliers(menu, cfg.Logger)
+	ingredientSupplierService := service.NewIngredientSupplier(menu, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("SuppliersService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering suppliers handlers")
+	coffeesRoutes.Handle("/suppliers", suppliersService).Methods("GET")
+	coffeesRoutes.Handle("/ingredients/{id:[0-9]+}/supplier", ingredientSupplierService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Suppliers handlers registered")
+
+	// registered after the static /coffees routes a slug would shadow, which
+	// no coffee is given as its slug
+	// Lifecycle event
+	cfg.Logger.Info("Registering coffee slug handler")
+	coffeesRoutes.Handle("/coffees/{id:[a-z0-9]+(?:-[a-z0-9]+)*}", detailService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Coffee slug handler registered")
+
+	if cfg.ChangesRetention > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Registering changes handler")
+		coffeesRoutes.Handle("/changes", service.NewChanges(changes, cfg.Logger)).Methods("GET")
+		// Lifecycle event
+		cfg.Logger.Info("Changes handler registered")
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing job queue", "queue", cfg.JobQueue)
+	// a nil queue leaves every worker its own in-memory queue
+	var jobQueue jobs.Queue
+	if cfg.JobQueue == jobs.Postgres {
+		postgresQueue, err := jobs.ConnectPostgres(cfg.ConnectionString, jobs.PostgresOptions{PollInterval: cfg.JobPollInterval})
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to connect to job queue", "error", err)
+			os.Exit(1)
+		}
+		defer postgresQueue.Close()
+		jobQueue = postgresQueue
+	}
+	// Component initialized
+	cfg.Logger.Info("Job queue initialized")
+
+	if cfg.ProductAPIAddress != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing OrdersService", "product_api", cfg.ProductAPIAddress)
+		options := clients.FromConfig(cfg, "product-api")
+		options.Timeout, options.Retries = cfg.ProductAPITimeout, cfg.ProductAPIRetries
+		client, err := clients.New(options)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize product-api client", "error", err)
+			os.Exit(1)
+		}
+		var payer *payments.Client
+		if cfg.PaymentsAddress != "" {
+			cfg.Logger.Info("Paying orders with the payments service", "payments", cfg.PaymentsAddress)
+			// authorizations carry an idempotency key, so they are retried
+			options := clients.FromConfig(cfg, "payments")
+			options.Timeout, options.Retries = cfg.PaymentsTimeout, cfg.PaymentsRetries
+			paymentsClient, err := clients.New(options)
+			if err != nil {
+				// Unrecoverable error
+				cfg.Logger.Error("Unable to initialize payments client", "error", err)
+				os.Exit(1)
+			}
+			payer = payments.NewClient(payments.Options{Address: cfg.PaymentsAddress, Client: paymentsClient})
+		}
+		var dispatcher *notifications.Dispatcher
+		if cfg.NotificationsProvider != "" {
+			cfg.Logger.Info("Sending order confirmations", "provider", cfg.NotificationsProvider, "dead_letter", cfg.NotificationsDeadLetter)
+			var notifier notifications.Notifier
+			switch cfg.NotificationsProvider {
+			case notifications.SMTP:
+				notifier = notifications.NewSMTP(cfg.NotificationsSMTPAddress, cfg.NotificationsSMTPFrom, cfg.NotificationsSMTPUsername, cfg.NotificationsSMTPPassword)
+			case notifications.Webhook:
+				// the dispatcher retries confirmations itself
+				options := clients.FromConfig(cfg, "notifications")
+				options.Retries = 0
+				webhookClient, err := clients.New(options)
+				if err != nil {
+					// Unrecoverable error
+					cfg.Logger.Error("Unable to initialize notifications client", "error", err)
+					os.Exit(1)
+				}
+				notifier = notifications.NewWebhook(cfg.NotificationsWebhookURL, webhookClient)
+			}
+			dispatcher, err = notifications.NewDispatcher(notifications.Options{
+				Notifier:   notifier,
+				Retries:    cfg.NotificationsRetries,
+				Buffer:     cfg.NotificationsBuffer,
+				DeadLetter: cfg.NotificationsDeadLetter,
+				Queue:      jobQueue,
+				Logger:     cfg.Logger,
+				Metrics:    sinks,
+			})
+			if err != nil {
+				// Unrecoverable error
+				cfg.Logger.Error("Unable to initialize order confirmations", "error", err)
+				os.Exit(1)
+			}
+			dispatcherDone := make(chan struct{})
+			defer close(dispatcherDone)
+			go dispatcher.Run(dispatcherDone)
+		}
+		var loyalty *service.Loyalty
+		if cfg.ProductAPITokenSecret != "" {
+			cfg.Logger.Info("Crediting loyalty points", "earn_rate", cfg.LoyaltyEarnRate, "point_value", cfg.LoyaltyPointValue)
+			loyalty = &service.Loyalty{
+				Secret:     []byte(cfg.ProductAPITokenSecret),
+				EarnRate:   cfg.LoyaltyEarnRate,
+				PointValue: cfg.LoyaltyPointValue,
+			}
+		}
+		productAPI := productapi.NewClient(productapi.Options{
+			Address: cfg.ProductAPIAddress,
+			Client:  client,
+		})
+		ordersService := service.NewOrders(productAPI, repository, payer, tracker, simulator, dispatcher, loyalty, cfg.Logger)
+		// Component initialized
+		cfg.Logger.Info("OrdersService initialized")
+
+		// Component initialization
+		cfg.Logger.Info("Initializing ReceiptService", "templates", cfg.ReceiptTemplates)
+		renderer, err := receipts.NewRenderer(cfg.ReceiptTemplates)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to parse receipt templates", "error", err)
+			os.Exit(1)
+		}
+		receiptService := service.NewReceipt(productAPI, repository, renderer, cfg.Logger)
+		// Component initialized
+		cfg.Logger.Info("ReceiptService initialized")
+
+		// Lifecycle event
+		cfg.Logger.Info("Registering orders handler")
+		ordersRoutes.Handle("/orders", ordersService).Methods("GET", "POST")
+		ordersRoutes.Handle("/orders/{id:[0-9]+}", ordersService).Methods("GET")
+		ordersRoutes.Handle("/orders/{id:[0-9]+}/receipt", receiptService).Methods("GET")
+		// Lifecycle event
+		cfg.Logger.Info("Orders handler registered")
+
+		if loyalty != nil {
+			// Component initialization
+			cfg.Logger.Info("Initializing PointsService")
+			pointsService := service.NewPoints(repository, loyalty, cfg.Logger)
+			// Component initialized
+			cfg.Logger.Info("PointsService initialized")
+
+			// Lifecycle event
+			cfg.Logger.Info("Registering points handler")
+			ordersRoutes.Handle("/me/points", pointsService).Methods("GET")
+			// Lifecycle event
+			cfg.Logger.Info("Points handler registered")
+		}
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing ExportService")
+	exportService := service.NewExport(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("ExportService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering export handler")
+	adminRoutes.Handle("/admin/export", exportService).Methods("GET", "HEAD")
+	adminRoutes.Handle("/admin/import", exportService).Methods("POST")
+	// Lifecycle event
+	cfg.Logger.Info("Export handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing BulkDeleteService")
+	bulkDeleteService := service.NewBulkDelete(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("BulkDeleteService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering bulk delete handler")
+	adminRoutes.Handle("/admin/coffees", bulkDeleteService).Methods("DELETE")
+	// Lifecycle event
+	cfg.Logger.Info("Bulk delete handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing TranslationsService")
+	translationsService := service.NewTranslations(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("TranslationsService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering translations handler")
+	adminRoutes.Handle("/admin/coffees/{id:[0-9]+}/translations", translationsService).Methods("GET")
+	adminRoutes.Handle("/admin/coffees/{id:[0-9]+}/translations/{locale}/{field}", translationsService).Methods("PUT", "DELETE")
+	// Lifecycle event
+	cfg.Logger.Info("Translations handler registered")
+
+	if cfg.ImageDir != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing ImagesService", "dir", cfg.ImageDir)
+		imageStore, err := images.NewStore(cfg.ImageDir, "/images/")
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize image store", "error", err)
+			os.Exit(1)
+		}
+		resizer := images.NewResizer(images.Options{Store: imageStore, Variants: service.ImageVariants, Logger: cfg.Logger, Metrics: sinks})
+		resizerDone := make(chan struct{})
+		defer close(resizerDone)
+		go resizer.Run(resizerDone)
+		imagesService := service.NewImages(repository, imageStore, resizer, cfg.Logger)
+		// Component initialized
+		cfg.Logger.Info("ImagesService initialized")
+
+		// Lifecycle event
+		cfg.Logger.Info("Registering images handlers")
+		adminRoutes.Handle("/admin/coffees/{id:[0-9]+}/image", imagesService).Methods("GET", "PUT")
+		coffeesRoutes.PathPrefix(imageStore.Prefix()).Handler(imageStore.Handler()).Methods("GET", "HEAD")
+		// Lifecycle event
+		cfg.Logger.Info("Images handlers registered")
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing static assets")
+	staticAssets, err := assets.New()
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to read static assets", "error", err)
+		os.Exit(1)
+	}
+	// Component initialized
+	cfg.Logger.Info("Static assets initialized", "assets", len(staticAssets.Manifest()))
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering static assets handler")
+	coffeesRoutes.PathPrefix(assets.Prefix).Handler(staticAssets).Methods("GET", "HEAD")
+	// Lifecycle event
+	cfg.Logger.Info("Static assets handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing admin CoffeeService")
+	adminCoffeeService, err := service.NewCoffee(cfg, repository, tracker)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize admin CoffeeService", "error", err)
+		os.Exit(1)
+	}
+	statusService := service.NewStatus(repository, cfg.Logger)
+	availabilityService := service.NewAvailability(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("Admin CoffeeService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering admin coffee handlers")
+	adminRoutes.Handle("/admin/coffees", adminCoffeeService).Methods("GET")
+	adminRoutes.Handle("/admin/coffees/{id:[0-9]+}/status", statusService).Methods("PUT")
+	adminRoutes.Handle("/admin/coffees/{id:[0-9]+}/availability", availabilityService).Methods("GET", "PUT")
+	// Lifecycle event
+	cfg.Logger.Info("Admin coffee handlers registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing CouponsService")
+	couponsService := service.NewCoupons(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("CouponsService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering coupons handler")
+	adminRoutes.Handle("/admin/coupons", couponsService).Methods("GET", "POST")
+	adminRoutes.Handle("/admin/coupons/{code}", couponsService).Methods("GET", "PUT", "DELETE")
+	// Lifecycle event
+	cfg.Logger.Info("Coupons handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing DashboardService")
+	dashboardService := service.NewDashboard(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("DashboardService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering dashboard handler")
+	// the aggregates scan every recorded order, at most once per 10 seconds
+	adminRoutes.Handle("/admin/stats", middleware.NewCache(10*time.Second, 0)(dashboardService)).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Dashboard handler registered")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing SalesService")
+	salesService := service.NewSales(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("SalesService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering sales handler")
+	adminRoutes.Handle("/admin/sales", salesService).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Sales handler registered")
+
+	if cfg.ProductAPITokenSecret != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing ProfileService")
+		profileService := service.NewProfile(repository, []byte(cfg.ProductAPITokenSecret), cfg.Logger)
+		// Component initialized
+		cfg.Logger.Info("ProfileService initialized")
+
+		// Lifecycle event
+		cfg.Logger.Info("Registering profile handler")
+		ordersRoutes.Handle("/me", profileService).Methods("GET", "PUT")
+		// Lifecycle event
+		cfg.Logger.Info("Profile handler registered")
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing scheduler", "reseed", cfg.ScheduleReseed, "cache_warm", cfg.ScheduleCacheWarm, "lock", cfg.SchedulerLock)
+	var schedulerLocker locks.Locker
+	switch cfg.SchedulerLock {
+	case locks.Postgres:
+		postgresLocker, err := locks.ConnectPostgres(cfg.ConnectionString, locks.PostgresOptions{TTL: cfg.SchedulerLockTTL})
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to connect to scheduler locks", "error", err)
+			os.Exit(1)
+		}
+		defer postgresLocker.Close()
+		schedulerLocker = postgresLocker
+	case locks.Consul:
+		consulClient, err := clients.New(clients.FromConfig(cfg, "locks"))
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize scheduler locks client", "error", err)
+			os.Exit(1)
+		}
+		schedulerLocker = locks.NewConsulLocker(locks.ConsulOptions{Address: cfg.ConsulAddress, TTL: cfg.SchedulerLockTTL, Client: consulClient})
+	}
+	if schedulerLocker != nil && len(sinks) > 0 {
+		schedulerLocker = locks.NewMetered(schedulerLocker, sinks)
+	}
+	jobScheduler := scheduler.New(scheduler.Options{Locker: schedulerLocker, Logger: cfg.Logger, Metrics: sinks})
+	// the expressions are validated with the configuration
+	if cfg.ScheduleReseed != "" {
+		jobScheduler.Add("reseed", cfg.ScheduleReseed, func(ctx context.Context) error {
+			created, err := data.SeedCoffees(ctx, repository, cfg.SeedScale, cfg.SeedRandom, cfg.SeedMode)
+			cfg.Logger.Info("Generated coffees", "created", created)
+			return err
+		})
+	}
+	if cfg.ScheduleCacheWarm != "" {
+		// requested through the router, like any client
+		jobScheduler.Add("cache_warm", cfg.ScheduleCacheWarm, service.NewCacheWarm(router, cfg.CacheWarmPaths))
+	}
+	schedulerDone := make(chan struct{})
+	defer close(schedulerDone)
+	go jobScheduler.Run(schedulerDone)
+	// Component initialized
+	cfg.Logger.Info("Scheduler initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering jobs handler")
+	adminRoutes.Handle("/admin/jobs", service.NewJobs(jobScheduler, cfg.Logger)).Methods("GET")
+	// Lifecycle event
+	cfg.Logger.Info("Jobs handler registered")
+
+	// registered last so the catalog includes every other route
+	// Lifecycle event
+	cfg.Logger.Info("Registering routes handler")
+	routesService := service.NewRoutes(cfg.Logger)
+	adminRoutes.Handle("/admin/routes", routesService).Methods("GET")
+	n, err := routesService.Load(routes)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to generate route catalog", "error", err)
+		os.Exit(1)
+	}
+	// Lifecycle event
+	cfg.Logger.Info("Routes handler registered", "routes", n)
+
+	if cfg.GRPCAddress != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing gRPC server")
+		grpcServer, stopGRPC := service.NewGRPCServer(repository, cfg.Logger)
+		defer stopGRPC()
+		// Component initialized
+		cfg.Logger.Info("gRPC server initialized")
+
+		// Lifecycle event
+		cfg.Logger.Info("Starting gRPC listener", "bind", cfg.GRPCAddress)
+		listener, err := net.Listen("tcp", cfg.GRPCAddress)
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to start gRPC listener.", "error", err)
+			os.Exit(1)
+		}
+
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				cfg.Logger.Error("gRPC server stopped.", "error", err)
+			}
+		}()
+	}
+
+	server := &http.Server{Addr: cfg.BindAddress, Handler: router}
+	if cfg.TLSCertFile != "" {
+		// Component initialization
+		cfg.Logger.Info("Initializing TLS", "client_ca", cfg.TLSClientCAFile, "trust_domain", cfg.SPIFFETrustDomain)
+		server.TLSConfig, err = spiffe.ServerConfig(spiffe.ServerOptions{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			ClientCAFile: cfg.TLSClientCAFile,
+			TrustDomain:  cfg.SPIFFETrustDomain,
+		})
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to initialize TLS", "error", err)
+			os.Exit(1)
+		}
+		// Component initialized
+		cfg.Logger.Info("TLS initialized")
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Starting service listener", "bind", cfg.BindAddress, "tls", server.TLSConfig != nil)
+	if server.TLSConfig != nil {
+		// the certificate is loaded into TLSConfig
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
 	if err != nil {
 		// Unrecoverable error
 		cfg.Logger.Error("Unable to start server.", "error", err)
 		os.Exit(1)
 	}
 }
+
+// alwaysLive is the liveness of the service when the watchdog is disabled
+type alwaysLive struct{}
+
+// Live always reports the service as live
+func (alwaysLive) Live() bool {
+	return true
+}
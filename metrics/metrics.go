@@ -0,0 +1,63 @@
+// Package metrics is the metrics abstraction used by the coffee-service.
+// Metrics are recorded against a Sink, which exports them to Prometheus,
+// StatsD or DogStatsD.
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// Label qualifies a metric, e.g. by route or status code
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sink receives metrics. Names are dot separated, e.g. http.requests, and
+// sinks translate them to the conventions of their backend.
+type Sink interface {
+	// IncrCounter adds value to a counter
+	IncrCounter(name string, value float64, labels ...Label)
+	// SetGauge sets a gauge to value
+	SetGauge(name string, value float64, labels ...Label)
+	// AddSample records an observation, e.g. a latency in milliseconds
+	AddSample(name string, value float64, labels ...Label)
+}
+
+// FanoutSink sends every metric to all of its sinks
+type FanoutSink []Sink
+
+// IncrCounter adds value to the counter in every sink
+func (f FanoutSink) IncrCounter(name string, value float64, labels ...Label) {
+	for _, s := range f {
+		s.IncrCounter(name, value, labels...)
+	}
+}
+
+// SetGauge sets the gauge in every sink
+func (f FanoutSink) SetGauge(name string, value float64, labels ...Label) {
+	for _, s := range f {
+		s.SetGauge(name, value, labels...)
+	}
+}
+
+// AddSample records the observation in every sink
+func (f FanoutSink) AddSample(name string, value float64, labels ...Label) {
+	for _, s := range f {
+		s.AddSample(name, value, labels...)
+	}
+}
+
+// MeasureSince records the milliseconds elapsed since start as a sample
+func MeasureSince(s Sink, name string, start time.Time, labels ...Label) {
+	s.AddSample(name, float64(time.Since(start).Microseconds())/1000, labels...)
+}
+
+// sortedLabels returns a copy of labels sorted by name, so the same labels
+// always identify the same series
+func sortedLabels(labels []Label) []Label {
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
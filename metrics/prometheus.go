@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// prometheusPrefix namespaces every metric exported to Prometheus
+const prometheusPrefix = "coffee_service_"
+
+// PrometheusSink keeps metrics in memory and serves them in the Prometheus
+// text exposition format. Samples are exported as summaries without
+// quantiles, i.e. a _sum and a _count series.
+type PrometheusSink struct {
+	mu       sync.Mutex
+	counters map[string]*series
+	gauges   map[string]*series
+	samples  map[string]*series
+}
+
+// series is a metric with a single set of labels
+type series struct {
+	name   string
+	labels []Label
+	value  float64
+	count  uint64
+}
+
+// NewPrometheusSink creates an empty PrometheusSink
+func NewPrometheusSink() *PrometheusSink {
+	return &PrometheusSink{
+		counters: map[string]*series{},
+		gauges:   map[string]*series{},
+		samples:  map[string]*series{},
+	}
+}
+
+// IncrCounter adds value to a counter
+func (p *PrometheusSink) IncrCounter(name string, value float64, labels ...Label) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.series(p.counters, name, labels).value += value
+}
+
+// SetGauge sets a gauge to value
+func (p *PrometheusSink) SetGauge(name string, value float64, labels ...Label) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.series(p.gauges, name, labels).value = value
+}
+
+// AddSample adds value to the sum of a summary
+func (p *PrometheusSink) AddSample(name string, value float64, labels ...Label) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.series(p.samples, name, labels)
+	s.value += value
+	s.count++
+}
+
+// series returns the series of name and labels, creating it when needed
+func (p *PrometheusSink) series(all map[string]*series, name string, labels []Label) *series {
+	name = prometheusName(name)
+	labels = sortedLabels(labels)
+	key := name + formatLabels(labels)
+
+	s, ok := all[key]
+	if !ok {
+		s = &series{name: name, labels: labels}
+		all[key] = s
+	}
+	return s
+}
+
+// ServeHTTP writes every metric in the text exposition format
+func (p *PrometheusSink) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	writeFamilies(&b, "counter", p.counters, func(s *series) {
+		fmt.Fprintf(&b, "%s_total%s %s\n", s.name, formatLabels(s.labels), formatValue(s.value))
+	})
+	writeFamilies(&b, "gauge", p.gauges, func(s *series) {
+		fmt.Fprintf(&b, "%s%s %s\n", s.name, formatLabels(s.labels), formatValue(s.value))
+	})
+	writeFamilies(&b, "summary", p.samples, func(s *series) {
+		fmt.Fprintf(&b, "%s_sum%s %s\n", s.name, formatLabels(s.labels), formatValue(s.value))
+		fmt.Fprintf(&b, "%s_count%s %d\n", s.name, formatLabels(s.labels), s.count)
+	})
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	rw.Write([]byte(b.String()))
+}
+
+// writeFamilies writes the TYPE line of every metric family followed by its
+// series, in a stable order
+func writeFamilies(b *strings.Builder, kind string, all map[string]*series, write func(*series)) {
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	family := ""
+	for _, key := range keys {
+		s := all[key]
+		if s.name != family {
+			family = s.name
+			name := family
+			if kind == "counter" {
+				name += "_total"
+			}
+			fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+		}
+		write(s)
+	}
+}
+
+// prometheusName converts a dot separated name to a Prometheus metric name
+func prometheusName(name string) string {
+	return prometheusPrefix + strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+// formatLabels formats labels as {name="value",...}
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", l.Name, strconv.Quote(l.Value)))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue formats a sample value without trailing zeros
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusSinkExposition(t *testing.T) {
+	p := NewPrometheusSink()
+	p.IncrCounter("http.requests", 1, Label{"status", "200"}, Label{"method", "GET"})
+	p.IncrCounter("http.requests", 2, Label{"method", "GET"}, Label{"status", "200"})
+	p.SetGauge("coffees", 6)
+	p.AddSample("http.request.duration", 1.5, Label{"method", "GET"})
+	p.AddSample("http.request.duration", 2.5, Label{"method", "GET"})
+
+	rw := httptest.NewRecorder()
+	p.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, `# TYPE coffee_service_http_requests_total counter
+coffee_service_http_requests_total{method="GET",status="200"} 3
+# TYPE coffee_service_coffees gauge
+coffee_service_coffees 6
+# TYPE coffee_service_http_request_duration summary
+coffee_service_http_request_duration_sum{method="GET"} 4
+coffee_service_http_request_duration_count{method="GET"} 2
+`, rw.Body.String())
+}
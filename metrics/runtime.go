@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+// pauseHistory is the number of recent pauses kept by runtime.MemStats
+const pauseHistory = 256
+
+// RuntimeCollector reports the heap and garbage collector statistics of the
+// Go runtime, e.g. to compare GC pauses with and without pooling
+type RuntimeCollector struct {
+	sink   Sink
+	lastGC uint32
+}
+
+// NewRuntimeCollector creates a RuntimeCollector reporting to s. Only the
+// pauses of collections after its creation are reported.
+func NewRuntimeCollector(s Sink) *RuntimeCollector {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return &RuntimeCollector{sink: s, lastGC: stats.NumGC}
+}
+
+// Collect reports the current heap and collector statistics, and the pause
+// of every collection since the last call. Pauses overwritten in the
+// runtime's history of pauses are lost.
+func (c *RuntimeCollector) Collect() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	c.sink.SetGauge("runtime.heap.alloc_bytes", float64(stats.HeapAlloc))
+	c.sink.SetGauge("runtime.heap.objects", float64(stats.HeapObjects))
+	c.sink.SetGauge("runtime.gc.count", float64(stats.NumGC))
+	c.sink.SetGauge("runtime.gc.pause_total_ms", float64(stats.PauseTotalNs)/1e6)
+
+	first := c.lastGC + 1
+	if stats.NumGC-c.lastGC > pauseHistory {
+		first = stats.NumGC - pauseHistory + 1
+	}
+	for n := first; n <= stats.NumGC; n++ {
+		c.sink.AddSample("runtime.gc.pause", float64(stats.PauseNs[(n+pauseHistory-1)%pauseHistory])/1e6)
+	}
+	c.lastGC = stats.NumGC
+}
+
+// Run collects every interval until done is closed
+func (c *RuntimeCollector) Run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.Collect()
+		}
+	}
+}
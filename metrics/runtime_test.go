@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pauseCount reads the number of GC pauses reported to p
+func pauseCount(t *testing.T, p *PrometheusSink) int {
+	rw := httptest.NewRecorder()
+	p.ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+
+	match := regexp.MustCompile(`coffee_service_runtime_gc_pause_count (\d+)`).FindStringSubmatch(rw.Body.String())
+	if match == nil {
+		t.Fatalf("no GC pauses reported in\n%s", rw.Body.String())
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+func TestRuntimeCollectorReportsGCPauses(t *testing.T) {
+	p := NewPrometheusSink()
+	c := NewRuntimeCollector(p)
+
+	runtime.GC()
+	runtime.GC()
+	c.Collect()
+
+	// collections may also run in the background
+	reported := pauseCount(t, p)
+	assert.GreaterOrEqual(t, reported, 2)
+
+	// pauses are only reported once
+	collected := c.lastGC
+	c.Collect()
+	assert.Equal(t, reported+int(c.lastGC-collected), pauseCount(t, p))
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// StatsD folds label values into the metric name
+	StatsD = "statsd"
+	// DogStatsD sends labels as DogStatsD tags
+	DogStatsD = "dogstatsd"
+)
+
+// statsdPrefix namespaces every metric sent to StatsD
+const statsdPrefix = "coffee_service."
+
+// StatsdSink pushes every metric to a StatsD or DogStatsD agent over UDP.
+// Writes never block, metrics are lost when the agent is unavailable.
+type StatsdSink struct {
+	conn   net.Conn
+	format string
+}
+
+// NewStatsdSink creates a StatsdSink sending to address in the StatsD or
+// DogStatsD format
+func NewStatsdSink(address, format string) (*StatsdSink, error) {
+	if format != StatsD && format != DogStatsD {
+		return nil, fmt.Errorf("unknown statsd format %q", format)
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsdSink{conn: conn, format: format}, nil
+}
+
+// IncrCounter sends a counter increment
+func (s *StatsdSink) IncrCounter(name string, value float64, labels ...Label) {
+	s.send(name, value, "c", labels)
+}
+
+// SetGauge sends a gauge value
+func (s *StatsdSink) SetGauge(name string, value float64, labels ...Label) {
+	s.send(name, value, "g", labels)
+}
+
+// AddSample sends a timing in milliseconds
+func (s *StatsdSink) AddSample(name string, value float64, labels ...Label) {
+	s.send(name, value, "ms", labels)
+}
+
+// Close closes the connection to the agent
+func (s *StatsdSink) Close() error {
+	return s.conn.Close()
+}
+
+// send writes a single metric as its own packet
+func (s *StatsdSink) send(name string, value float64, kind string, labels []Label) {
+	s.conn.Write([]byte(s.line(name, value, kind, labels)))
+}
+
+// line formats a single metric line
+func (s *StatsdSink) line(name string, value float64, kind string, labels []Label) string {
+	labels = sortedLabels(labels)
+	name = statsdPrefix + name
+
+	if s.format == DogStatsD {
+		line := fmt.Sprintf("%s:%s|%s", name, formatValue(value), kind)
+		if len(labels) == 0 {
+			return line
+		}
+
+		tags := make([]string, 0, len(labels))
+		for _, l := range labels {
+			tags = append(tags, l.Name+":"+l.Value)
+		}
+		return line + "|#" + strings.Join(tags, ",")
+	}
+
+	for _, l := range labels {
+		name += "." + statsdName(l.Value)
+	}
+	return fmt.Sprintf("%s:%s|%s", name, formatValue(value), kind)
+}
+
+// statsdName replaces every character which is not allowed in a StatsD name
+// segment with an underscore
+func statsdName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.Trim(value, "/"))
+
+	if name == "" {
+		return "_"
+	}
+	return name
+}
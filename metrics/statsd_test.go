@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdSinkFormats(t *testing.T) {
+	labels := []Label{{"status", "200"}, {"route", "/coffees/{id:[0-9]+}"}}
+
+	statsd := &StatsdSink{format: StatsD}
+	assert.Equal(t, "coffee_service.http.requests.coffees__id__0-9___.200:1|c", statsd.line("http.requests", 1, "c", labels))
+
+	dogstatsd := &StatsdSink{format: DogStatsD}
+	assert.Equal(t, "coffee_service.http.request.duration:1.25|ms|#route:/coffees/{id:[0-9]+},status:200", dogstatsd.line("http.request.duration", 1.25, "ms", labels))
+}
+
+func TestStatsdSinkSendsPackets(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer agent.Close()
+
+	s, err := NewStatsdSink(agent.LocalAddr().String(), DogStatsD)
+	require.NoError(t, err)
+	defer s.Close()
+
+	s.SetGauge("coffees", 6)
+
+	buf := make([]byte, 512)
+	agent.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := agent.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "coffee_service.coffees:6|g", string(buf[:n]))
+}
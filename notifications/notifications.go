@@ -0,0 +1,234 @@
+// Package notifications sends order confirmations to customers in the
+// background, by email or to a webhook, as jobs of the jobs package, keeping
+// those which could not be sent in a dead-letter log.
+package notifications
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/jobs"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+const (
+	// SMTP sends confirmations by email
+	SMTP = "smtp"
+	// Webhook posts confirmations as JSON to a URL
+	Webhook = "webhook"
+)
+
+const (
+	// RetryBackoff is the delay before the first retry of a confirmation,
+	// doubled for every further retry
+	RetryBackoff = time.Second
+	// AttemptTimeout is the longest a single attempt to send a confirmation
+	// takes
+	AttemptTimeout = 10 * time.Second
+)
+
+// ErrNoRecipient is returned by a Notifier for a confirmation it has no
+// recipient for, e.g. an email of an order without an email address. Such
+// confirmations are dropped rather than retried.
+var ErrNoRecipient = errors.New("confirmation has no recipient")
+
+//go:embed confirmation.txt
+var confirmationTemplate string
+
+// Item is a coffee of a confirmed order
+type Item struct {
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+// Confirmation is the confirmation of a paid order. The Subject and Body are
+// rendered by the Dispatcher.
+type Confirmation struct {
+	OrderID   int     `json:"order_id"`
+	Email     string  `json:"email,omitempty"`
+	PaymentID string  `json:"payment_id,omitempty"`
+	Items     []Item  `json:"items"`
+	Total     float64 `json:"total"`
+	Subject   string  `json:"subject"`
+	Body      string  `json:"body"`
+}
+
+// Notifier sends a confirmation through a provider
+type Notifier interface {
+	// Notify sends a confirmation, a returned error is retried unless it is
+	// ErrNoRecipient
+	Notify(ctx context.Context, confirmation Confirmation) error
+}
+
+// deadLetter is a line of the dead-letter log
+type deadLetter struct {
+	Time         time.Time    `json:"time"`
+	Attempts     int          `json:"attempts"`
+	Error        string       `json:"error"`
+	Confirmation Confirmation `json:"confirmation"`
+}
+
+// Kind is the kind of the jobs sending confirmations
+const Kind = "notifications.confirmation"
+
+// Options configure a Dispatcher
+type Options struct {
+	Notifier Notifier
+	// Queue keeps the confirmations waiting to be sent, an in memory queue
+	// of Buffer confirmations when nil
+	Queue jobs.Queue
+	// Retries is the number of times a failed confirmation is sent again
+	Retries int
+	// Buffer is the number of confirmations waiting to be sent in memory. A
+	// confirmation dispatched while it is full is dead lettered, rather than
+	// blocking the order.
+	Buffer int
+	// DeadLetter is the file confirmations which could not be sent are
+	// appended to as JSON lines, they are only logged when empty
+	DeadLetter string
+	Logger     hclog.Logger
+	Metrics    metrics.Sink
+}
+
+// Dispatcher renders the confirmations of orders, queues them as jobs and
+// sends them with its Notifier in the background, one at a time
+type Dispatcher struct {
+	options  Options
+	template *template.Template
+	backoff  time.Duration
+	now      func() time.Time
+
+	// mu serializes the writes to the dead-letter log
+	mu sync.Mutex
+}
+
+// NewDispatcher creates a Dispatcher, confirmations are only sent once Run is
+// called
+func NewDispatcher(options Options) (*Dispatcher, error) {
+	t, err := template.New("confirmation").Parse(confirmationTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if options.Queue == nil {
+		options.Queue = jobs.NewMemoryQueue(options.Buffer)
+	}
+
+	return &Dispatcher{
+		options:  options,
+		template: t,
+		backoff:  RetryBackoff,
+		now:      time.Now,
+	}, nil
+}
+
+// Dispatch renders a confirmation and queues it without blocking. It is safe
+// to call on a nil Dispatcher, which sends nothing.
+func (d *Dispatcher) Dispatch(confirmation Confirmation) {
+	if d == nil {
+		return
+	}
+
+	confirmation.Subject = fmt.Sprintf("Your HashiCups order %d", confirmation.OrderID)
+	body := &strings.Builder{}
+	if err := d.template.Execute(body, confirmation); err != nil {
+		d.deadLetter(confirmation, 0, err)
+		return
+	}
+	confirmation.Body = body.String()
+
+	payload, err := json.Marshal(confirmation)
+	if err != nil {
+		d.deadLetter(confirmation, 0, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), AttemptTimeout)
+	defer cancel()
+	err = d.options.Queue.Enqueue(ctx, Kind, payload)
+	if err == jobs.ErrQueueFull {
+		err = errors.New("dispatch queue is full")
+	}
+	if err != nil {
+		d.deadLetter(confirmation, 0, err)
+	}
+}
+
+// Run sends the queued confirmations until done is closed, then tries the
+// confirmations still queued in memory once, without retrying them
+func (d *Dispatcher) Run(done <-chan struct{}) {
+	jobs.NewWorker(jobs.WorkerOptions{
+		Queue:    d.options.Queue,
+		Handlers: map[string]jobs.Handler{Kind: d},
+		Retries:  d.options.Retries,
+		Backoff:  d.backoff,
+		Timeout:  AttemptTimeout,
+		Logger:   d.options.Logger,
+		Metrics:  d.options.Metrics,
+	}).Run(done)
+}
+
+// Handle sends the confirmation of a job, confirmations without a recipient
+// are dropped rather than retried
+func (d *Dispatcher) Handle(ctx context.Context, job jobs.Job) error {
+	confirmation := Confirmation{}
+	if err := json.Unmarshal(job.Payload, &confirmation); err != nil {
+		d.options.Logger.Error("Unable to decode order confirmation", "id", job.ID, "error", err)
+		return nil
+	}
+
+	err := d.options.Notifier.Notify(ctx, confirmation)
+	if errors.Is(err, ErrNoRecipient) {
+		d.options.Logger.Debug("Order confirmation not sent", "order_id", confirmation.OrderID, "reason", err)
+		return nil
+	}
+	if err == nil {
+		d.options.Logger.Debug("Order confirmation sent", "order_id", confirmation.OrderID, "attempt", job.Attempts)
+	}
+	return err
+}
+
+// Failed dead letters the confirmation of a job which failed every attempt
+func (d *Dispatcher) Failed(job jobs.Job, err error) {
+	confirmation := Confirmation{}
+	json.Unmarshal(job.Payload, &confirmation)
+	d.deadLetter(confirmation, job.Attempts, err)
+}
+
+// deadLetter logs a confirmation which could not be sent and appends it to
+// the dead-letter log
+func (d *Dispatcher) deadLetter(confirmation Confirmation, attempts int, cause error) {
+	d.options.Logger.Error("Order confirmation dead lettered", "order_id", confirmation.OrderID, "attempts", attempts, "error", cause)
+	if d.options.DeadLetter == "" {
+		return
+	}
+
+	line, err := json.Marshal(deadLetter{Time: d.now().UTC(), Attempts: attempts, Error: cause.Error(), Confirmation: confirmation})
+	if err != nil {
+		d.options.Logger.Error("Unable to encode dead lettered confirmation", "order_id", confirmation.OrderID, "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.options.DeadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		d.options.Logger.Error("Unable to open dead-letter log", "path", d.options.DeadLetter, "error", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		d.options.Logger.Error("Unable to write dead-letter log", "path", d.options.DeadLetter, "error", err)
+	}
+}
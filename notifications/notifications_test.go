@@ -0,0 +1,206 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/jobs"
+)
+
+// fakeNotifier records the confirmations it is sent, failing the first
+// failures attempts with err
+type fakeNotifier struct {
+	mu       sync.Mutex
+	sent     []Confirmation
+	attempts int
+	failures int
+	err      error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, confirmation Confirmation) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.attempts++
+	if n.attempts <= n.failures {
+		return n.err
+	}
+	n.sent = append(n.sent, confirmation)
+	return nil
+}
+
+func setupDispatcher(t *testing.T, notifier Notifier, buffer int) (*Dispatcher, string) {
+	dir, err := ioutil.TempDir("", "notifications")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d, err := NewDispatcher(Options{
+		Notifier:   notifier,
+		Retries:    2,
+		Buffer:     buffer,
+		DeadLetter: filepath.Join(dir, "dead-letter.log"),
+		Logger:     hclog.NewNullLogger(),
+	})
+	require.NoError(t, err)
+	d.backoff = time.Millisecond
+	return d, d.options.DeadLetter
+}
+
+// runUntilDrained runs the dispatcher until the confirmations queued are sent
+func runUntilDrained(d *Dispatcher) {
+	done := make(chan struct{})
+	close(done)
+	d.Run(done)
+}
+
+// runUntil runs the dispatcher, retrying failed confirmations, until cond
+// holds
+func runUntil(t *testing.T, d *Dispatcher, cond func() bool) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(done)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	<-stopped
+	require.True(t, cond())
+}
+
+func (n *fakeNotifier) sentCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.sent)
+}
+
+func testConfirmation() Confirmation {
+	return Confirmation{OrderID: 8, Email: "gerry@example.com", PaymentID: "4d3c", Items: []Item{{Name: "Vaulatte", Quantity: 2, Price: 200}}, Total: 400}
+}
+
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+
+	letters := []deadLetter{}
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		letter := deadLetter{}
+		require.NoError(t, json.Unmarshal([]byte(line), &letter))
+		letters = append(letters, letter)
+	}
+	return letters
+}
+
+func TestDispatcherRendersAndSendsConfirmations(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d, deadLetters := setupDispatcher(t, notifier, 10)
+
+	d.Dispatch(testConfirmation())
+	runUntilDrained(d)
+
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, "Your HashiCups order 8", notifier.sent[0].Subject)
+	assert.Contains(t, notifier.sent[0].Body, "Thank you for your order 8.")
+	assert.Contains(t, notifier.sent[0].Body, "  2 x Vaulatte")
+	assert.Contains(t, notifier.sent[0].Body, "Total: 400.00")
+	assert.Contains(t, notifier.sent[0].Body, "Payment: 4d3c")
+	assert.Empty(t, readDeadLetters(t, deadLetters))
+}
+
+func TestDispatcherRetriesFailedConfirmations(t *testing.T) {
+	notifier := &fakeNotifier{failures: 2, err: errors.New("connection refused")}
+	d, deadLetters := setupDispatcher(t, notifier, 10)
+
+	d.Dispatch(testConfirmation())
+	runUntil(t, d, func() bool { return notifier.sentCount() == 1 })
+
+	assert.Equal(t, 3, notifier.attempts)
+	assert.Len(t, notifier.sent, 1)
+	assert.Empty(t, readDeadLetters(t, deadLetters))
+}
+
+func TestDispatcherDeadLettersConfirmationsWhichFailEveryAttempt(t *testing.T) {
+	notifier := &fakeNotifier{failures: 3, err: errors.New("connection refused")}
+	d, deadLetters := setupDispatcher(t, notifier, 10)
+
+	d.Dispatch(testConfirmation())
+	runUntil(t, d, func() bool { return len(readDeadLetters(t, deadLetters)) == 1 })
+
+	assert.Equal(t, 3, notifier.attempts)
+	letters := readDeadLetters(t, deadLetters)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "connection refused", letters[0].Error)
+	assert.Equal(t, 8, letters[0].Confirmation.OrderID)
+	assert.Equal(t, "Your HashiCups order 8", letters[0].Confirmation.Subject)
+}
+
+func TestDispatcherDropsConfirmationsWithoutRecipient(t *testing.T) {
+	notifier := &fakeNotifier{failures: 1, err: ErrNoRecipient}
+	d, deadLetters := setupDispatcher(t, notifier, 10)
+
+	d.Dispatch(testConfirmation())
+	runUntilDrained(d)
+
+	assert.Equal(t, 1, notifier.attempts)
+	assert.Empty(t, readDeadLetters(t, deadLetters))
+}
+
+func TestDispatcherDeadLettersWhenTheQueueIsFull(t *testing.T) {
+	notifier := &fakeNotifier{}
+	d, deadLetters := setupDispatcher(t, notifier, 1)
+
+	d.Dispatch(testConfirmation())
+	second := testConfirmation()
+	second.OrderID = 9
+	d.Dispatch(second)
+
+	letters := readDeadLetters(t, deadLetters)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 0, letters[0].Attempts)
+	assert.Equal(t, 9, letters[0].Confirmation.OrderID)
+
+	runUntilDrained(d)
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, 8, notifier.sent[0].OrderID)
+}
+
+func TestNilDispatcherDispatchesNothing(t *testing.T) {
+	var d *Dispatcher
+	d.Dispatch(testConfirmation())
+}
+
+func TestDispatcherQueuesConfirmationsAsJobs(t *testing.T) {
+	queue := jobs.NewMemoryQueue(10)
+	d, err := NewDispatcher(Options{Notifier: &fakeNotifier{}, Queue: queue, Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	d.Dispatch(testConfirmation())
+
+	job, err := queue.Dequeue(context.Background(), []string{Kind})
+	require.NoError(t, err)
+	confirmation := Confirmation{}
+	require.NoError(t, json.Unmarshal(job.Payload, &confirmation))
+	assert.Equal(t, 8, confirmation.OrderID)
+	assert.Equal(t, "Your HashiCups order 8", confirmation.Subject)
+}
@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPNotifier emails confirmations to the address of the order as plain
+// text. A confirmation without an email address is ErrNoRecipient.
+type SMTPNotifier struct {
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+// NewSMTP creates an SMTPNotifier sending from from through the server at
+// address, a host:port. The server is authenticated with PLAIN when username
+// is set, which net/smtp only allows over TLS or to localhost.
+func NewSMTP(address, from, username, password string) *SMTPNotifier {
+	n := &SMTPNotifier{address: address, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(address)
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// Notify emails the confirmation. net/smtp does not take a context, the
+// attempt is bounded by the timeouts of the server instead.
+func (n *SMTPNotifier) Notify(ctx context.Context, confirmation Confirmation) error {
+	if confirmation.Email == "" {
+		return ErrNoRecipient
+	}
+	// a header injection would add recipients
+	if strings.ContainsAny(confirmation.Email, "\r\n") {
+		return fmt.Errorf("%w: invalid email address %q", ErrNoRecipient, confirmation.Email)
+	}
+
+	message := &strings.Builder{}
+	fmt.Fprintf(message, "From: %s\r\n", n.from)
+	fmt.Fprintf(message, "To: %s\r\n", confirmation.Email)
+	fmt.Fprintf(message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", confirmation.Subject))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(confirmation.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(n.address, n.auth, n.from, []string{confirmation.Email}, []byte(message.String()))
+}
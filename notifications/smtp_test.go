@@ -0,0 +1,89 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveSMTP accepts a single session on a local listener and returns its
+// address and the commands and message data received
+func serveSMTP(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		lines := []string{}
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ready")
+		data := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				received <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data && line == ".":
+				data = false
+				reply("250 queued")
+			case data:
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 localhost")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPEmailsConfirmations(t *testing.T) {
+	address, received := serveSMTP(t)
+
+	confirmation := testConfirmation()
+	confirmation.Subject = "Your HashiCups order 8"
+	confirmation.Body = "Thank you for your order 8.\n"
+	require.NoError(t, NewSMTP(address, "orders@hashicups.example", "", "").Notify(context.Background(), confirmation))
+
+	session := strings.Join(<-received, "\n")
+	assert.Contains(t, session, "MAIL FROM:<orders@hashicups.example>")
+	assert.Contains(t, session, "RCPT TO:<gerry@example.com>")
+	assert.Contains(t, session, "To: gerry@example.com\nSubject: Your HashiCups order 8\n")
+	assert.Contains(t, session, "\n\nThank you for your order 8.\n.\n")
+}
+
+func TestSMTPNeedsARecipient(t *testing.T) {
+	notifier := NewSMTP("127.0.0.1:25", "orders@hashicups.example", "", "")
+
+	confirmation := testConfirmation()
+	confirmation.Email = ""
+	assert.Equal(t, ErrNoRecipient, notifier.Notify(context.Background(), confirmation))
+
+	confirmation.Email = "gerry@example.com\r\nBcc: everyone@example.com"
+	assert.True(t, errors.Is(notifier.Notify(context.Background(), confirmation), ErrNoRecipient))
+}
@@ -0,0 +1,58 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+)
+
+// WebhookNotifier posts confirmations as JSON to a URL, with an
+// Idempotency-Key of confirmation-<order id> so that the receiver can ignore
+// a retried confirmation it already handled
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a WebhookNotifier posting to url with client
+func NewWebhook(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = clients.Default()
+	}
+
+	return &WebhookNotifier{url: url, client: client}
+}
+
+// Notify posts the confirmation, any status but 2xx is an error
+func (n *WebhookNotifier) Notify(ctx context.Context, confirmation Confirmation) error {
+	body, err := json.Marshal(confirmation)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(clients.IdempotencyKeyHeader, fmt.Sprintf("confirmation-%d", confirmation.OrderID))
+
+	resp, err := n.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		d, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %d %s: %s", resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(d)))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookPostsConfirmations(t *testing.T) {
+	received := Confirmation{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "confirmation-8", r.Header.Get("Idempotency-Key"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	confirmation := testConfirmation()
+	confirmation.Subject = "Your HashiCups order 8"
+	require.NoError(t, NewWebhook(server.URL+"/orders", nil).Notify(context.Background(), confirmation))
+	assert.Equal(t, confirmation, received)
+}
+
+func TestWebhookFailsOnUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewWebhook(server.URL, nil).Notify(context.Background(), testConfirmation())
+	assert.EqualError(t, err, "webhook returned 503 Service Unavailable: maintenance")
+}
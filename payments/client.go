@@ -0,0 +1,115 @@
+// Package payments is a typed client of the HashiCorp demo app payments
+// service, which authorizes the card payment of an order.
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+)
+
+// Card is the card an order is paid with
+type Card struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Number string `json:"number"`
+	Expiry string `json:"expiry"`
+	CV2    int    `json:"cv2"`
+}
+
+// Payment is an authorized payment
+type Payment struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// Error is returned when the payments service responds with an unexpected
+// status. A client error means the payment was declined.
+type Error struct {
+	Status int
+	Body   string
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return fmt.Sprintf("payments returned %d %s: %s", e.Status, http.StatusText(e.Status), e.Body)
+}
+
+// Options configure a Client
+type Options struct {
+	// Address is the base URL of the payments service, e.g.
+	// http://payments:8080
+	Address string
+	// Client sends the requests, its retry policy applies to authorizations
+	// as they carry an idempotency key
+	Client *http.Client
+}
+
+// Client authorizes payments. The trace of the calling request is propagated
+// to the payments service.
+type Client struct {
+	options Options
+}
+
+// NewClient creates a Client
+func NewClient(options Options) *Client {
+	if options.Client == nil {
+		options.Client = clients.Default()
+	}
+
+	return &Client{options: options}
+}
+
+// Authorize authorizes the payment of an order with a card. The payments
+// service authorizes a key at most once, so a retried or repeated
+// authorization of the same key is not charged twice.
+func (c *Client) Authorize(ctx context.Context, key string, card Card) (*Payment, error) {
+	body, err := json.Marshal(card)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		span := opentracing.GlobalTracer().StartSpan("payments POST /", opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+		defer span.Finish()
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.options.Address, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(clients.IdempotencyKeyHeader, key)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		opentracing.GlobalTracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	}
+
+	resp, err := c.options.Client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		d, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &Error{Status: resp.StatusCode, Body: strings.TrimSpace(string(d))}
+	}
+
+	payment := &Payment{}
+	if err := json.NewDecoder(resp.Body).Decode(payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
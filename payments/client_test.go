@@ -0,0 +1,67 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+)
+
+// newHTTPClient creates a shared client retrying requests, without delays
+func newHTTPClient(t *testing.T, retries int) *http.Client {
+	c, err := clients.New(clients.Options{Timeout: time.Second, Retries: retries, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	return c
+}
+
+var card = Card{Name: "Gerry", Type: "mastercard", Number: "1234-1234-1234-1234", Expiry: "10/22", CV2: 123}
+
+func TestClientRetriesAuthorizations(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "order-7", r.Header.Get("Idempotency-Key"))
+		received := Card{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		assert.Equal(t, card, received)
+
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte(`{"id":"4d3c","message":"Payment processed successfully"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 2)})
+	payment, err := c.Authorize(context.Background(), "order-7", card)
+	require.NoError(t, err)
+	assert.Equal(t, "4d3c", payment.ID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestClientReportsDeclinedPayments(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(rw, "Card declined", http.StatusPaymentRequired)
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 2)})
+	_, err := c.Authorize(context.Background(), "order-7", card)
+
+	declined, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusPaymentRequired, declined.Status)
+	assert.Equal(t, "Card declined", declined.Body)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
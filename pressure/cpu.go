@@ -0,0 +1,128 @@
+package pressure
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrBurnLimit is returned for a burn of more cores or for longer than the
+	// safety limits
+	ErrBurnLimit = errors.New("burn exceeds the CPU burn limits")
+	// ErrBurning is returned when starting a burn while another one runs
+	ErrBurning = errors.New("a CPU burn is already running")
+)
+
+// Burn is a running burn
+type Burn struct {
+	Cores int       `json:"cores"`
+	Until time.Time `json:"until"`
+}
+
+// CPU spins busy loops on demand, one burn at a time and within safety
+// limits. It is safe for concurrent use.
+type CPU struct {
+	cores       int
+	maxDuration time.Duration
+
+	mu      sync.Mutex
+	burning *Burn
+	stop    context.CancelFunc
+}
+
+// NewCPU creates a CPU burning at most cores for at most maxDuration
+func NewCPU(cores int, maxDuration time.Duration) *CPU {
+	return &CPU{cores: cores, maxDuration: maxDuration}
+}
+
+// Limits returns the most cores and longest duration of a burn
+func (c *CPU) Limits() (int, time.Duration) {
+	return c.cores, c.maxDuration
+}
+
+// Burn spins a busy loop on cores goroutines for duration in the background.
+// It returns ErrBurnLimit for more cores or longer than the limits, and
+// ErrBurning while another burn runs.
+func (c *CPU) Burn(cores int, duration time.Duration) (*Burn, error) {
+	if cores <= 0 || cores > c.cores || duration <= 0 || duration > c.maxDuration {
+		return nil, ErrBurnLimit
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.burning != nil {
+		return nil, ErrBurning
+	}
+
+	burn := &Burn{Cores: cores, Until: time.Now().Add(duration)}
+	ctx, stop := context.WithDeadline(context.Background(), burn.Until)
+	c.burning, c.stop = burn, stop
+
+	wg := sync.WaitGroup{}
+	wg.Add(cores)
+	for n := 0; n < cores; n++ {
+		go func() {
+			defer wg.Done()
+			spin(ctx)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		stop()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.burning == burn {
+			c.burning, c.stop = nil, nil
+		}
+	}()
+
+	copied := *burn
+	return &copied, nil
+}
+
+// Burning returns the running burn, nil when there is none
+func (c *CPU) Burning() *Burn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.burning == nil {
+		return nil
+	}
+	burn := *c.burning
+	return &burn
+}
+
+// Stop ends the running burn, if any, without waiting for its goroutines
+func (c *CPU) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	c.burning, c.stop = nil, nil
+}
+
+// spin keeps a core busy until ctx is done, checking it every so many
+// iterations
+func spin(ctx context.Context) {
+	x := uint64(1)
+	for {
+		select {
+		case <-ctx.Done():
+			atomic.StoreUint64(&sink, x)
+			return
+		default:
+		}
+		for n := 0; n < 100000; n++ {
+			x = x*6364136223846793005 + 1442695040888963407
+		}
+	}
+}
+
+// sink keeps the result of the busy loops, so they are not optimized away
+var sink uint64
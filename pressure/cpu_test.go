@@ -0,0 +1,43 @@
+package pressure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUBurnsWithinTheLimits(t *testing.T) {
+	c := NewCPU(2, time.Second)
+	assert.Nil(t, c.Burning())
+
+	for _, invalid := range []struct {
+		cores    int
+		duration time.Duration
+	}{
+		{0, time.Second},
+		{3, time.Second},
+		{1, 0},
+		{1, 2 * time.Second},
+	} {
+		_, err := c.Burn(invalid.cores, invalid.duration)
+		assert.Equal(t, ErrBurnLimit, err, invalid)
+	}
+
+	burn, err := c.Burn(1, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1, burn.Cores)
+	assert.Equal(t, burn, c.Burning())
+
+	_, err = c.Burn(1, 50*time.Millisecond)
+	assert.Equal(t, ErrBurning, err)
+
+	// the burn ends by itself
+	assert.Eventually(t, func() bool { return c.Burning() == nil }, time.Second, 10*time.Millisecond)
+
+	_, err = c.Burn(2, time.Second)
+	require.NoError(t, err)
+	c.Stop()
+	assert.Nil(t, c.Burning())
+}
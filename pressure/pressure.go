@@ -0,0 +1,76 @@
+// Package pressure allocates and retains memory, and burns CPU, on demand, so
+// demos can show how orchestrators enforce limits, kill containers running
+// out of memory and scale on memory or CPU usage.
+package pressure
+
+import (
+	"errors"
+	"runtime/debug"
+	"sync"
+)
+
+// chunk is the size of the blocks memory is allocated in, a megabyte
+const chunk = 1 << 20
+
+// pageSize is the stride pages are written with, so the operating system
+// backs every page of an allocation and the resident memory grows
+const pageSize = 4096
+
+// ErrLimit is returned when an allocation would retain more memory than the
+// hard cap
+var ErrLimit = errors.New("allocation exceeds the memory pressure limit")
+
+// Memory retains the memory allocated with it, up to a hard cap, until it is
+// released. It is safe for concurrent use.
+type Memory struct {
+	limit int
+
+	mu     sync.Mutex
+	chunks [][]byte
+}
+
+// NewMemory creates a Memory retaining at most limit megabytes
+func NewMemory(limit int) *Memory {
+	return &Memory{limit: limit}
+}
+
+// Limit returns the most megabytes retained
+func (m *Memory) Limit() int {
+	return m.limit
+}
+
+// Retained returns the megabytes retained
+func (m *Memory) Retained() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.chunks)
+}
+
+// Allocate retains megabytes more, or returns ErrLimit without allocating
+// anything when the retained memory would exceed the limit
+func (m *Memory) Allocate(megabytes int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if megabytes < 0 || len(m.chunks)+megabytes > m.limit {
+		return ErrLimit
+	}
+	for n := 0; n < megabytes; n++ {
+		b := make([]byte, chunk)
+		for i := 0; i < len(b); i += pageSize {
+			b[i] = 1
+		}
+		m.chunks = append(m.chunks, b)
+	}
+	return nil
+}
+
+// Release drops the retained memory and returns it to the operating system
+func (m *Memory) Release() {
+	m.mu.Lock()
+	m.chunks = nil
+	m.mu.Unlock()
+
+	debug.FreeOSMemory()
+}
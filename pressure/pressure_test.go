@@ -0,0 +1,26 @@
+package pressure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIsRetainedUpToTheLimit(t *testing.T) {
+	m := NewMemory(3)
+
+	require.NoError(t, m.Allocate(2))
+	assert.Equal(t, 2, m.Retained())
+
+	assert.Equal(t, ErrLimit, m.Allocate(2))
+	assert.Equal(t, 2, m.Retained())
+	assert.Equal(t, ErrLimit, m.Allocate(-1))
+
+	require.NoError(t, m.Allocate(1))
+	assert.Equal(t, 3, m.Retained())
+
+	m.Release()
+	assert.Equal(t, 0, m.Retained())
+	require.NoError(t, m.Allocate(3))
+}
@@ -0,0 +1,161 @@
+// Package productapi is a typed client of the HashiCorp demo app product-api,
+// which owns the orders while the coffee-service is carved out of it.
+package productapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Order is an order of the product-api. The coupon, the discount and the
+// loyalty points are only set by the coffee-service on the order it created,
+// the product-api does not store them.
+type Order struct {
+	ID    int         `json:"id"`
+	Items []OrderItem `json:"items,omitempty"`
+
+	Coupon         string  `json:"coupon,omitempty"`
+	Discount       float64 `json:"discount,omitempty"`
+	PointsRedeemed int     `json:"points_redeemed,omitempty"`
+	PointsEarned   int     `json:"points_earned,omitempty"`
+}
+
+// OrderItem is a quantity of a coffee in an order, only the coffee ID is
+// needed to create an order
+type OrderItem struct {
+	Coffee   entities.Coffee `json:"coffee"`
+	Quantity int             `json:"quantity"`
+}
+
+// Error is returned when the product-api responds with an unexpected status,
+// so callers can pass client errors on
+type Error struct {
+	Status int
+	Body   string
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return fmt.Sprintf("product-api returned %d %s: %s", e.Status, http.StatusText(e.Status), e.Body)
+}
+
+// Options configure a Client
+type Options struct {
+	// Address is the base URL of the product-api, e.g. http://product-api:9090
+	Address string
+	// Client sends the requests, its retry policy applies to reads only as
+	// writes are not idempotent
+	Client *http.Client
+}
+
+// Client calls the order endpoints of the product-api on behalf of a user,
+// identified by the token the product-api issued to them. The trace of the
+// calling request is propagated to the product-api.
+type Client struct {
+	options Options
+}
+
+// NewClient creates a Client
+func NewClient(options Options) *Client {
+	if options.Client == nil {
+		options.Client = clients.Default()
+	}
+
+	return &Client{options: options}
+}
+
+// Orders returns the orders of the user
+func (c *Client) Orders(ctx context.Context, token string) ([]Order, error) {
+	orders := []Order{}
+	if err := c.do(ctx, http.MethodGet, "/orders", token, nil, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Order returns a single order of the user
+func (c *Client) Order(ctx context.Context, token string, orderID int) (*Order, error) {
+	order := &Order{}
+	if err := c.do(ctx, http.MethodGet, "/orders/"+strconv.Itoa(orderID), token, nil, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CreateOrder creates an order of the items for the user
+func (c *Client) CreateOrder(ctx context.Context, token string, items []OrderItem) (*Order, error) {
+	order := &Order{}
+	if err := c.do(ctx, http.MethodPost, "/orders", token, items, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CancelOrder deletes an order of the user, e.g. to compensate for a failed
+// payment
+func (c *Client) CancelOrder(ctx context.Context, token string, orderID int) error {
+	return c.do(ctx, http.MethodDelete, "/orders/"+strconv.Itoa(orderID), token, nil, nil)
+}
+
+// do sends a request and decodes the response into out, the response is
+// discarded when out is nil
+func (c *Client) do(ctx context.Context, method, path, token string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		d, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(d)
+	}
+
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		span := opentracing.GlobalTracer().StartSpan("product-api "+method+" "+path, opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+		defer span.Finish()
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.options.Address, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Accept", "application/json")
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		opentracing.GlobalTracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	}
+
+	resp, err := c.options.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		d, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{Status: resp.StatusCode, Body: strings.TrimSpace(string(d))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
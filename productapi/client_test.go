@@ -0,0 +1,104 @@
+package productapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/clients"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// newHTTPClient creates a shared client retrying reads, without delays
+func newHTTPClient(t *testing.T, retries int) *http.Client {
+	c, err := clients.New(clients.Options{Timeout: time.Second, Retries: retries, RetryDelay: time.Millisecond})
+	require.NoError(t, err)
+	return c
+}
+
+func TestClientCreatesOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/orders", r.URL.Path)
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+
+		items := []OrderItem{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&items))
+		json.NewEncoder(rw).Encode(Order{ID: 7, Items: items})
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 0)})
+	order, err := c.CreateOrder(context.Background(), "token", []OrderItem{{Coffee: entities.Coffee{ID: 1}, Quantity: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, 7, order.ID)
+	assert.Equal(t, 1, order.Items[0].Coffee.ID)
+	assert.Equal(t, 2, order.Items[0].Quantity)
+}
+
+func TestClientRetriesReadsOnly(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 2)})
+	orders, err := c.Orders(context.Background(), "token")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{{ID: 1}}, orders)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	atomic.StoreInt32(&requests, 0)
+	_, err = c.CreateOrder(context.Background(), "token", nil)
+	assert.EqualError(t, err, "product-api returned 503 Service Unavailable: unavailable")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(rw, "Invalid token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 2)})
+	_, err := c.Order(context.Background(), "", 1)
+
+	upstream, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, upstream.Status)
+	assert.Equal(t, "Invalid token", upstream.Body)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestClientCancelsOrders(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.Equal(t, "/orders/7", r.URL.Path)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte(`"Deleted order"`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Options{Address: server.URL, Client: newHTTPClient(t, 2)})
+	require.NoError(t, c.CancelOrder(context.Background(), "token", 7))
+	// deleting is idempotent so it is retried
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
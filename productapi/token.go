@@ -0,0 +1,92 @@
+package productapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for a token which is malformed, not signed with
+// the secret, expired or without a user
+var ErrInvalidToken = errors.New("invalid product-api token")
+
+// tokenHeader is the header of a product-api token
+type tokenHeader struct {
+	Alg string `json:"alg"`
+}
+
+// Claims are the claims of a product-api token read by the coffee-service
+type Claims struct {
+	// Subject is the sub claim, or the user ID in decimal for tokens
+	// without one
+	Subject string `json:"sub"`
+	UserID  int    `json:"user_id"`
+	Expiry  int64  `json:"exp"`
+}
+
+// ParseToken returns the claims of a product-api token, verifying that the
+// token is an HS256 JWT signed with secret which has not expired at now and
+// identifies a user. The token is the Authorization header as passed on to
+// the product-api, with or without a Bearer prefix.
+func ParseToken(token string, secret []byte, now time.Time) (*Claims, error) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	header := tokenHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Expiry != 0 && !now.Before(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" && claims.UserID > 0 {
+		claims.Subject = strconv.Itoa(claims.UserID)
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// UserID returns the product-api user a token was issued to, see ParseToken
+func UserID(token string, secret []byte, now time.Time) (int, error) {
+	claims, err := ParseToken(token, secret, now)
+	if err != nil {
+		return 0, err
+	}
+	if claims.UserID <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return claims.UserID, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	d, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(d, v)
+}
@@ -0,0 +1,61 @@
+package productapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signToken creates an HS256 token of the claims
+func signToken(claims string, secret []byte) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestUserIDVerifiesTokens(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1600000000, 0)
+	token := signToken(`{"user_id":7,"username":"gerry","exp":1600000060}`, secret)
+
+	userID, err := UserID(token, secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, 7, userID)
+
+	userID, err = UserID("Bearer "+token, secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, 7, userID)
+
+	for name, token := range map[string]string{
+		"other secret": signToken(`{"user_id":7}`, []byte("other")),
+		"expired":      signToken(`{"user_id":7,"exp":1600000000}`, secret),
+		"no user":      signToken(`{"username":"gerry"}`, secret),
+		"malformed":    "token",
+		"tampered":     token[:len(token)-2] + "AA",
+	} {
+		_, err := UserID(token, secret, now)
+		assert.Equal(t, ErrInvalidToken, err, name)
+	}
+}
+
+func TestParseTokenReadsTheSubject(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1600000000, 0)
+
+	claims, err := ParseToken(signToken(`{"sub":"auth0|42"}`, secret), secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, "auth0|42", claims.Subject)
+	_, err = UserID(signToken(`{"sub":"auth0|42"}`, secret), secret, now)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// product-api tokens identify the user by ID alone
+	claims, err = ParseToken(signToken(`{"user_id":7}`, secret), secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims.Subject)
+}
@@ -0,0 +1,121 @@
+package receipts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// pageWidth and pageHeight are the size of an A4 page in points
+	pageWidth  = 595
+	pageHeight = 842
+	// margin is the distance of the text from the edges of a page
+	margin = 56
+	// fontSize and leading are the size and line height of the text
+	fontSize = 10
+	leading  = 14
+	// linesPerPage is the number of lines fitting between the margins
+	linesPerPage = (pageHeight - 2*margin) / leading
+)
+
+// pdfWriter writes the objects of a PDF document, recording their offsets for
+// the cross-reference table
+type pdfWriter struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int
+	err     error
+}
+
+// printf writes to the document, keeping the first error
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += n
+	p.err = err
+}
+
+// object writes the next object, numbered from 1
+func (p *pdfWriter) object(format string, args ...interface{}) {
+	p.offsets = append(p.offsets, p.offset)
+	p.printf("%d 0 obj\n", len(p.offsets))
+	p.printf(format, args...)
+	p.printf("\nendobj\n")
+}
+
+// writePDF writes a document of the lines in Courier, as many A4 pages as
+// they need. Characters outside of Windows-1252 are replaced by a question
+// mark.
+func writePDF(w io.Writer, lines []string) error {
+	pages := [][]string{}
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	p := &pdfWriter{w: bufio.NewWriter(w)}
+	p.printf("%%PDF-1.4\n")
+
+	// the catalog, page tree and font come first, every page is followed by
+	// its content stream
+	kids := make([]string, len(pages))
+	for n := range pages {
+		kids[n] = fmt.Sprintf("%d 0 R", 4+2*n)
+	}
+	p.object("<< /Type /Catalog /Pages 2 0 R >>")
+	p.object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for n, page := range pages {
+		p.object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 5+2*n)
+
+		content := &strings.Builder{}
+		fmt.Fprintf(content, "BT /F1 %d Tf %d TL %d %d Td\n", fontSize, leading, margin, pageHeight-margin)
+		for _, line := range page {
+			fmt.Fprintf(content, "(%s) Tj T*\n", escape(line))
+		}
+		content.WriteString("ET")
+		p.object("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String())
+	}
+
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+// escape encodes a line as a PDF string literal in Windows-1252
+func escape(line string) string {
+	escaped := &strings.Builder{}
+	for _, r := range line {
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			b = '?'
+		}
+		switch b {
+		case '\\', '(', ')':
+			escaped.WriteByte('\\')
+			escaped.WriteByte(b)
+		default:
+			if b < 0x20 || b > 0x7e {
+				fmt.Fprintf(escaped, "\\%03o", b)
+				continue
+			}
+			escaped.WriteByte(b)
+		}
+	}
+	return escaped.String()
+}
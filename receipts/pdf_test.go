@@ -0,0 +1,45 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `Vaulatte \(large\) \\ 2`, escape(`Vaulatte (large) \ 2`))
+	assert.Equal(t, `Caf\351 \200 ?`, escape("Café € ☕"))
+}
+
+func TestWritePDFCrossReferencesEveryObject(t *testing.T) {
+	lines := make([]string, 2*linesPerPage+1)
+	for n := range lines {
+		lines[n] = fmt.Sprintf("line %d", n)
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, writePDF(out, lines))
+	pdf := out.String()
+	assert.Contains(t, pdf, "/Count 3")
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(startxref[1])
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(pdf[xref:], "xref\n0 10\n"))
+
+	// every entry points at its object, 3 fixed ones and 2 per page
+	entries := strings.Split(pdf[xref:], "\n")[3:12]
+	for n, entry := range entries {
+		offset, err := strconv.Atoi(entry[:10])
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj\n", n+1)), entry)
+	}
+}
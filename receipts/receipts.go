@@ -0,0 +1,121 @@
+// Package receipts renders the receipt of an order as HTML or PDF from
+// templates embedded in the binary, which a directory on disk can override.
+package receipts
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	// HTMLTemplate is the name of the template rendering HTML receipts
+	HTMLTemplate = "receipt.html"
+	// TextTemplate is the name of the template rendering the lines of PDF
+	// receipts, laid out in a monospaced font
+	TextTemplate = "receipt.txt"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// funcs are the functions available to the templates
+var funcs = map[string]interface{}{
+	"money": func(amount float64) string { return fmt.Sprintf("%.2f", amount) },
+}
+
+// Line is a coffee of an order
+type Line struct {
+	Name     string
+	Quantity int
+	Price    float64
+	// Amount is the price of the quantity
+	Amount float64
+}
+
+// Receipt is the data the templates are rendered with
+type Receipt struct {
+	OrderID  int
+	IssuedAt time.Time
+	Lines    []Line
+	Total    float64
+}
+
+// NewReceipt returns the receipt of an order, computing the amount of every
+// line and the total
+func NewReceipt(orderID int, lines []Line, issuedAt time.Time) Receipt {
+	receipt := Receipt{OrderID: orderID, IssuedAt: issuedAt, Lines: lines}
+	for n := range receipt.Lines {
+		receipt.Lines[n].Amount = receipt.Lines[n].Price * float64(receipt.Lines[n].Quantity)
+		receipt.Total += receipt.Lines[n].Amount
+	}
+	return receipt
+}
+
+// Renderer renders receipts
+type Renderer struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// NewRenderer parses the embedded templates. A template of the same name in
+// dir, e.g. receipt.html, replaces the embedded one, dir is not read when
+// empty.
+func NewRenderer(dir string) (*Renderer, error) {
+	html, err := read(dir, HTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	text, err := read(dir, TextTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{}
+	if r.html, err = htmltemplate.New(HTMLTemplate).Funcs(funcs).Parse(html); err != nil {
+		return nil, err
+	}
+	if r.text, err = texttemplate.New(TextTemplate).Funcs(funcs).Parse(text); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// read returns a template from dir, or the embedded one when dir does not
+// have it
+func read(dir, name string) (string, error) {
+	if dir != "" {
+		raw, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(raw), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	raw, err := fs.ReadFile(embedded, "templates/"+name)
+	return string(raw), err
+}
+
+// HTML writes the receipt as an HTML page
+func (r *Renderer) HTML(w io.Writer, receipt Receipt) error {
+	return r.html.Execute(w, receipt)
+}
+
+// PDF writes the receipt as a PDF document of the lines of the text template
+func (r *Renderer) PDF(w io.Writer, receipt Receipt) error {
+	text := &strings.Builder{}
+	if err := r.text.Execute(text, receipt); err != nil {
+		return err
+	}
+	return writePDF(w, strings.Split(strings.TrimRight(text.String(), "\n"), "\n"))
+}
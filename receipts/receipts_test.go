@@ -0,0 +1,74 @@
+package receipts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipt() Receipt {
+	return NewReceipt(7, []Line{
+		{Name: "Vaulatte", Quantity: 2, Price: 200},
+		{Name: "<Nomadicano>", Quantity: 1, Price: 150.5},
+	}, time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC))
+}
+
+func TestNewReceiptComputesTotals(t *testing.T) {
+	receipt := testReceipt()
+
+	assert.Equal(t, 400.0, receipt.Lines[0].Amount)
+	assert.Equal(t, 150.5, receipt.Lines[1].Amount)
+	assert.Equal(t, 550.5, receipt.Total)
+}
+
+func TestRendererWritesHTML(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, renderer.HTML(out, testReceipt()))
+	assert.Contains(t, out.String(), "Order 7, issued 2020-10-01 12:00 UTC")
+	assert.Contains(t, out.String(), "<td>Vaulatte</td>")
+	assert.Contains(t, out.String(), "&lt;Nomadicano&gt;")
+	assert.Contains(t, out.String(), "550.50")
+}
+
+func TestRendererWritesPDF(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, renderer.PDF(out, testReceipt()))
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF-1.4\n")))
+	assert.Contains(t, out.String(), "(Vaulatte                              2     200.00     400.00) Tj")
+	assert.Contains(t, out.String(), "550.50")
+}
+
+func TestRendererTemplatesAreOverriddenFromDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "receipts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, HTMLTemplate), []byte("Thanks for order {{.OrderID}}, {{money .Total}}"), 0644))
+
+	renderer, err := NewRenderer(dir)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, renderer.HTML(out, testReceipt()))
+	assert.Equal(t, "Thanks for order 7, 550.50", out.String())
+
+	// the text template is still the embedded one
+	out.Reset()
+	require.NoError(t, renderer.PDF(out, testReceipt()))
+	assert.Contains(t, out.String(), "(HashiCups) Tj")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, TextTemplate), []byte("{{.Missing"), 0644))
+	_, err = NewRenderer(dir)
+	assert.Error(t, err)
+}
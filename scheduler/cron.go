@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCron is returned for an expression which is neither five cron
+// fields nor one of the @ shorthands
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+// shorthands are the @ expressions and the fields they stand for
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the range of values of a cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// 7 is Sunday as well as 0
+	{name: "day of week", min: 0, max: 7},
+}
+
+// maxSearch bounds the search for the next run, expressions like 0 0 30 2 *
+// never match
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Cron is a parsed cron expression, minute hour day-of-month month
+// day-of-week. Every field is *, a value, a range like 1-5, a list like
+// 1,15 and any of them stepped like */15 or 8-18/2. As with cron, a job
+// restricted by both days runs on the days matching either.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// ParseCron parses a cron expression
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if shorthand, ok := shorthands[strings.ToLower(spec)]; ok {
+		spec = shorthand
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: expected %d fields, got %d", ErrInvalidCron, expr, len(fields), len(parts))
+	}
+
+	sets := make([]uint64, len(fields))
+	for n, part := range parts {
+		set, err := parseField(part, fields[n])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidCron, expr, err)
+		}
+		sets[n] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &Cron{
+		expr:   strings.TrimSpace(expr),
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// String returns the expression the Cron was parsed from
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first minute after t matching the expression, in the
+// location of t, or the zero time when it never matches
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.Add(maxSearch)
+	for next.Before(limit) {
+		switch {
+		case !has(c.month, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !c.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !has(c.hour, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !has(c.minute, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day of month and day
+// of week fields
+func (c *Cron) matchesDay(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	switch {
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// parseField returns the set of values of a comma separated cron field as a
+// bit per value
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		rangeSpec, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangeSpec = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s step %q is not a positive number", f.name, item[i+1:])
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch i := strings.Index(rangeSpec, "-"); {
+		case rangeSpec == "*":
+		case i >= 0:
+			var err error
+			if low, err = parseValue(rangeSpec[:i], f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(rangeSpec[i+1:], f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s range %q is reversed", f.name, rangeSpec)
+			}
+		default:
+			value, err := parseValue(rangeSpec, f)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			// a stepped value runs to the end of the range, like cron
+			if step == 1 {
+				high = value
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a single value of a cron field
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %q is not a number from %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// has reports whether set holds v
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// a Thursday
+	from := time.Date(2020, 10, 1, 12, 34, 56, 0, time.UTC)
+	cases := map[string]time.Time{
+		"* * * * *":           time.Date(2020, 10, 1, 12, 35, 0, 0, time.UTC),
+		"*/15 * * * *":        time.Date(2020, 10, 1, 12, 45, 0, 0, time.UTC),
+		"0 3 * * *":           time.Date(2020, 10, 2, 3, 0, 0, 0, time.UTC),
+		"30 8-18/2 * * *":     time.Date(2020, 10, 1, 14, 30, 0, 0, time.UTC),
+		"0 0 1,15 * *":        time.Date(2020, 10, 15, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 1-5":         time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":           time.Date(2020, 10, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 0":           time.Date(2020, 10, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":          time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"5/20 * * * *":        time.Date(2020, 10, 1, 12, 45, 0, 0, time.UTC),
+		"@hourly":             time.Date(2020, 10, 1, 13, 0, 0, 0, time.UTC),
+		"@yearly":             time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		" @Daily ":            time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":          {},
+		"34 12 1 10 *":        time.Date(2021, 10, 1, 12, 34, 0, 0, time.UTC),
+		"0-59/30 12,13 * * *": time.Date(2020, 10, 1, 13, 0, 0, 0, time.UTC),
+	}
+	for expr, want := range cases {
+		t.Run(expr, func(t *testing.T) {
+			c, err := ParseCron(expr)
+			require.NoError(t, err)
+			assert.Equal(t, want, c.Next(from))
+		})
+	}
+}
+
+func TestCronNextKeepsLocation(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	c, err := ParseCron("0 3 * * *")
+	require.NoError(t, err)
+
+	next := c.Next(time.Date(2020, 10, 1, 12, 0, 0, 0, zone))
+	assert.Equal(t, time.Date(2020, 10, 2, 3, 0, 0, 0, zone), next)
+	assert.Equal(t, zone, next.Location())
+}
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@often"} {
+		_, err := ParseCron(expr)
+		assert.True(t, errors.Is(err, ErrInvalidCron), expr)
+	}
+}
@@ -0,0 +1,317 @@
+// Package scheduler runs recurring tasks of the service, e.g. reseeding the
+// generated coffees, on cron expressions from the configuration and keeps the
+// outcome of their last run for the admin routes.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/locks"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// DefaultLockHold is how long a run keeps its lock at least, so instances
+// whose clocks differ by less do not run the job again once it finished
+const DefaultLockHold = 30 * time.Second
+
+// Task is the work of a scheduled job, ctx is cancelled when the scheduler
+// stops
+type Task func(ctx context.Context) error
+
+// Status is the state of a scheduled job, as listed by /admin/jobs
+type Status struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	// Running is true while the job runs, a run is skipped while the
+	// previous one has not finished
+	Running bool `json:"running"`
+	// LastRun is when the last run started, nil until the job first runs
+	LastRun      *time.Time `json:"last_run"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	// LastError is the error of the last run, empty when it succeeded
+	LastError string `json:"last_error,omitempty"`
+	// LastSkipped is when a run was last skipped, because the previous run
+	// had not finished or another instance held the lock of the job
+	LastSkipped *time.Time `json:"last_skipped,omitempty"`
+	// NextRun is nil when the schedule never matches again
+	NextRun *time.Time `json:"next_run"`
+}
+
+// Options configure a Scheduler
+type Options struct {
+	// Locker locks every run of a job, so it runs on a single instance at a
+	// time, the jobs run on every instance when nil
+	Locker locks.Locker
+	// LockHold is how long a run keeps its lock at least, DefaultLockHold
+	// when 0
+	LockHold time.Duration
+	Logger   hclog.Logger
+	Metrics  metrics.Sink
+}
+
+// job is a task and its status
+type job struct {
+	cron   *Cron
+	task   Task
+	status Status
+	next   time.Time
+}
+
+// Scheduler runs tasks when their cron expression matches, in the local time
+// of the service. With a Locker, a run first takes the lock scheduler.<job>
+// and is skipped when another instance holds it. A run whose lock is lost
+// has its ctx cancelled and fails with locks.ErrLost. Every run is counted in
+// scheduler.runs, labelled with the job and the result, success, failure or
+// skipped, and timed in scheduler.duration.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	options Options
+	now     func() time.Time
+	// wake is signalled when a job is added while Run waits
+	wake chan struct{}
+}
+
+// New creates an empty Scheduler, jobs only run once Run is called
+func New(options Options) *Scheduler {
+	if options.LockHold <= 0 {
+		options.LockHold = DefaultLockHold
+	}
+	if options.Metrics == nil {
+		options.Metrics = metrics.FanoutSink{}
+	}
+	return &Scheduler{
+		jobs:    map[string]*job{},
+		options: options,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add schedules task as the job name on the cron expression expr
+func (s *Scheduler) Add(name, expr string, task Task) error {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %q is already scheduled", name)
+	}
+	j := &job{cron: cron, task: task, status: Status{Name: name, Schedule: cron.String()}}
+	j.setNext(cron.Next(s.now()))
+	s.jobs[name] = j
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Statuses returns the status of every job, sorted by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
+
+// Run starts the due jobs until done is closed, then cancels the running
+// ones and waits for them to return
+func (s *Scheduler) Run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := sync.WaitGroup{}
+	defer func() {
+		cancel()
+		running.Wait()
+	}()
+
+	for {
+		wait := s.start(ctx, &running)
+
+		var due <-chan time.Time
+		var timer *time.Timer
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-done:
+		case <-s.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if closed(done) {
+			return
+		}
+	}
+}
+
+// start runs the due jobs in the background, and returns the time until the
+// next job is due, or -1 when no job is due ever again
+func (s *Scheduler) start(ctx context.Context, running *sync.WaitGroup) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	wait := time.Duration(-1)
+	for name, j := range s.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+		if !j.next.After(now) {
+			if j.status.Running {
+				s.options.Logger.Warn("Skipping scheduled job, previous run still running", "job", name)
+				s.skipped(name, j, now)
+			} else {
+				j.status.Running = true
+				running.Add(1)
+				go func(name string, j *job) {
+					defer running.Done()
+					s.run(ctx, name, j)
+				}(name, j)
+			}
+			j.setNext(j.cron.Next(now))
+			if j.next.IsZero() {
+				continue
+			}
+		}
+		if until := j.next.Sub(now); wait < 0 || until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+// run runs the task of a job, under its lock if any, and records the
+// outcome in its status
+func (s *Scheduler) run(ctx context.Context, name string, j *job) {
+	start := s.now()
+	if s.options.Locker == nil {
+		s.options.Logger.Info("Running scheduled job", "job", name)
+		s.finish(name, j, start, s.attempt(ctx, j.task))
+		return
+	}
+
+	lock, err := s.options.Locker.TryLock(ctx, "scheduler."+name)
+	if errors.Is(err, locks.ErrLocked) {
+		s.options.Logger.Debug("Skipping scheduled job, running on another instance", "job", name)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.status.Running = false
+		s.skipped(name, j, start)
+		return
+	}
+	if err != nil {
+		s.finish(name, j, start, fmt.Errorf("unable to lock job: %w", err))
+		return
+	}
+
+	s.options.Logger.Info("Running scheduled job", "job", name)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lost := make(chan struct{})
+	go func() {
+		select {
+		case <-lock.Lost():
+			close(lost)
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+	err = s.attempt(runCtx, j.task)
+	select {
+	case <-lost:
+		err = locks.ErrLost
+	default:
+	}
+	s.finish(name, j, start, err)
+
+	// another instance whose clock is behind must not find the lock free
+	// and run the job again
+	select {
+	case <-ctx.Done():
+	case <-lost:
+	case <-time.After(start.Add(s.options.LockHold).Sub(s.now())):
+	}
+	if err := lock.Unlock(context.Background()); err != nil {
+		s.options.Logger.Error("Unable to unlock scheduled job", "job", name, "error", err)
+	}
+}
+
+// finish records the outcome of a run of a job which started at start
+func (s *Scheduler) finish(name string, j *job, start time.Time, err error) {
+	duration := s.now().Sub(start)
+	result := "success"
+	if err != nil {
+		result = "failure"
+		s.options.Logger.Error("Scheduled job failed", "job", name, "duration", duration, "error", err)
+	} else {
+		s.options.Logger.Info("Scheduled job finished", "job", name, "duration", duration)
+	}
+	metrics.MeasureSince(s.options.Metrics, "scheduler.duration", start, metrics.Label{Name: "job", Value: name})
+	s.options.Metrics.IncrCounter("scheduler.runs", 1, metrics.Label{Name: "job", Value: name}, metrics.Label{Name: "result", Value: result})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	j.status.LastRun = &start
+	j.status.LastDuration = duration.String()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+}
+
+// skipped records a run of a job skipped at t, s.mu must be held
+func (s *Scheduler) skipped(name string, j *job, t time.Time) {
+	j.status.LastSkipped = &t
+	s.options.Metrics.IncrCounter("scheduler.runs", 1, metrics.Label{Name: "job", Value: name}, metrics.Label{Name: "result", Value: "skipped"})
+}
+
+// attempt runs a task, turning a panic into an error so one bad run does not
+// stop the service
+func (s *Scheduler) attempt(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return task(ctx)
+}
+
+// setNext sets the time the job is next due, the zero time for never
+func (j *job) setNext(next time.Time) {
+	j.next = next
+	j.status.NextRun = nil
+	if !next.IsZero() {
+		j.status.NextRun = &next
+	}
+}
+
+// closed reports whether done is closed
+func closed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
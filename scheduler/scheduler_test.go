@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/locks"
+)
+
+// fakeClock is a settable now for a Scheduler
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func newTestScheduler(clock *fakeClock) *Scheduler {
+	s := New(Options{Logger: hclog.NewNullLogger()})
+	s.now = clock.Now
+	return s
+}
+
+func TestSchedulerRunsDueJobsAndRecordsTheirStatus(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)}
+	s := newTestScheduler(clock)
+	runs := map[string]int{}
+	mu := sync.Mutex{}
+	task := func(name string, err error) Task {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[name]++
+			return err
+		}
+	}
+	require.NoError(t, s.Add("reseed", "*/5 * * * *", task("reseed", nil)))
+	require.NoError(t, s.Add("cache_warm", "* * * * *", task("cache_warm", errors.New("connection refused"))))
+	require.Error(t, s.Add("reseed", "* * * * *", task("reseed", nil)))
+
+	statuses := s.Statuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "cache_warm", statuses[0].Name)
+	assert.Nil(t, statuses[0].LastRun)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC), *statuses[0].NextRun)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 5, 0, 0, time.UTC), *statuses[1].NextRun)
+
+	running := sync.WaitGroup{}
+	clock.Set(time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC))
+	wait := s.start(context.Background(), &running)
+	running.Wait()
+	assert.Equal(t, time.Minute, wait)
+	assert.Equal(t, map[string]int{"cache_warm": 1}, runs)
+
+	statuses = s.Statuses()
+	assert.False(t, statuses[0].Running)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC), *statuses[0].LastRun)
+	assert.Equal(t, "connection refused", statuses[0].LastError)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 2, 0, 0, time.UTC), *statuses[0].NextRun)
+	assert.Nil(t, statuses[1].LastRun)
+
+	clock.Set(time.Date(2020, 10, 1, 12, 5, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	running.Wait()
+	assert.Equal(t, map[string]int{"cache_warm": 2, "reseed": 1}, runs)
+	statuses = s.Statuses()
+	assert.Empty(t, statuses[1].LastError)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 10, 0, 0, time.UTC), *statuses[1].NextRun)
+}
+
+func TestSchedulerSkipsRunsWhileThePreviousOneRuns(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)}
+	s := newTestScheduler(clock)
+	release := make(chan struct{})
+	runs := 0
+	require.NoError(t, s.Add("reseed", "* * * * *", func(context.Context) error {
+		runs++
+		<-release
+		return nil
+	}))
+
+	running := sync.WaitGroup{}
+	clock.Set(time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	assert.True(t, s.Statuses()[0].Running)
+	clock.Set(time.Date(2020, 10, 1, 12, 2, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	close(release)
+	running.Wait()
+
+	assert.Equal(t, 1, runs)
+	status := s.Statuses()[0]
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 2, 0, 0, time.UTC), *status.LastSkipped)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 3, 0, 0, time.UTC), *status.NextRun)
+}
+
+func TestSchedulerRecoversPanickingJobs(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)}
+	s := newTestScheduler(clock)
+	require.NoError(t, s.Add("reseed", "* * * * *", func(context.Context) error {
+		panic("nil repository")
+	}))
+
+	running := sync.WaitGroup{}
+	clock.Set(time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	running.Wait()
+
+	assert.Equal(t, "job panicked: nil repository", s.Statuses()[0].LastError)
+}
+
+func TestSchedulerRunCancelsRunningJobsWhenStopped(t *testing.T) {
+	s := New(Options{Logger: hclog.NewNullLogger()})
+	started := make(chan struct{})
+	require.NoError(t, s.Add("reseed", "* * * * *", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	// due straight away rather than at the next minute
+	s.mu.Lock()
+	s.jobs["reseed"].setNext(time.Now())
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(done)
+		close(stopped)
+	}()
+	<-started
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+	assert.Equal(t, context.Canceled.Error(), s.Statuses()[0].LastError)
+}
+
+func TestSchedulerSkipsJobsLockedByAnotherInstance(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)}
+	locker := locks.NewMemoryLocker()
+	s := New(Options{Locker: locker, LockHold: time.Millisecond, Logger: hclog.NewNullLogger()})
+	s.now = clock.Now
+	runs := 0
+	require.NoError(t, s.Add("reseed", "* * * * *", func(context.Context) error {
+		runs++
+		return nil
+	}))
+
+	other, err := locker.TryLock(context.Background(), "scheduler.reseed")
+	require.NoError(t, err)
+	running := sync.WaitGroup{}
+	clock.Set(time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	running.Wait()
+	assert.Equal(t, 0, runs)
+	status := s.Statuses()[0]
+	assert.False(t, status.Running)
+	assert.Nil(t, status.LastRun)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC), *status.LastSkipped)
+
+	require.NoError(t, other.Unlock(context.Background()))
+	clock.Set(time.Date(2020, 10, 1, 12, 2, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	running.Wait()
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, time.Date(2020, 10, 1, 12, 2, 0, 0, time.UTC), *s.Statuses()[0].LastRun)
+
+	// the lock is released once the run is over
+	lock, err := locker.TryLock(context.Background(), "scheduler.reseed")
+	require.NoError(t, err)
+	lock.Unlock(context.Background())
+}
+
+func TestSchedulerCancelsRunsWhoseLockIsStolen(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)}
+	locker := locks.NewMemoryLocker()
+	s := New(Options{Locker: locker, LockHold: time.Millisecond, Logger: hclog.NewNullLogger()})
+	s.now = clock.Now
+	started := make(chan struct{})
+	require.NoError(t, s.Add("reseed", "* * * * *", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	running := sync.WaitGroup{}
+	clock.Set(time.Date(2020, 10, 1, 12, 1, 0, 0, time.UTC))
+	s.start(context.Background(), &running)
+	<-started
+	locker.Steal("scheduler.reseed")
+	running.Wait()
+
+	assert.Equal(t, locks.ErrLost.Error(), s.Statuses()[0].LastError)
+	// the lock stays with the instance which stole it
+	_, err := locker.TryLock(context.Background(), "scheduler.reseed")
+	assert.Equal(t, locks.ErrLocked, err)
+}
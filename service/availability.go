@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// AvailabilityService is an HTTP Handler managing the windows in which a
+// coffee is on the menu. GET lists the availability rules of a coffee and PUT
+// replaces them, an empty list makes the coffee always available.
+type AvailabilityService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewAvailability creates a new Availability handler
+func NewAvailability(repository data.Repository, l hclog.Logger) *AvailabilityService {
+	return &AvailabilityService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coffee availability route
+func (s *AvailabilityService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Availability", "method", r.Method)
+
+	coffeeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid coffee id", http.StatusBadRequest)
+		return
+	}
+
+	rules := entities.AvailabilityRules{}
+	switch r.Method {
+	case http.MethodPut:
+		if err := newDecoder(r, r.Body).Decode(&rules); err != nil {
+			invalidPayload(rw, "Invalid availability rules", err)
+			return
+		}
+		if err = data.SetAvailability(r.Context(), s.repository, coffeeID, rules); err == nil {
+			s.logger.Info("Availability set", "coffee_id", coffeeID, "rules", len(rules))
+		}
+	default:
+		if _, err = s.repository.FindByID(r.Context(), coffeeID); err == nil {
+			rules, err = data.FindAvailability(r.Context(), s.repository, []int{coffeeID})
+		}
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+		return
+	case data.ErrInvalidAvailability:
+		http.Error(rw, "Availability rules need days of mon to sun, from and to times as HH:MM and start and end dates as MM-DD", http.StatusBadRequest)
+		return
+	case data.ErrAvailabilityUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to manage availability", "method", r.Method, "coffee_id", coffeeID, "error", err)
+		http.Error(rw, "Unable to manage availability", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(rules)
+	if err != nil {
+		s.logger.Error("Unable to encode availability", "error", err)
+		http.Error(rw, "Unable to encode availability", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
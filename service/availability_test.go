@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func setupAvailabilityHandler(t *testing.T) *AvailabilityService {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return NewAvailability(repository, hclog.NewNullLogger())
+}
+
+func availabilityRequest(method, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/admin/coffees/"+id+"/availability", strings.NewReader(body))
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func TestAvailabilityIsSetAndListed(t *testing.T) {
+	handler := setupAvailabilityHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("PUT", "1", `[{"start":"09-22","end":"12-20"},{"days":["Sat"],"from":"07:00","to":"11:00"}]`))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), `"days":["sat"]`)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("GET", "1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"start":"09-22","end":"12-20"},{"days":["sat"],"from":"07:00","to":"11:00"}]`, rw.Body.String())
+
+	// an empty list makes the coffee always available
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("PUT", "1", `[]`))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("GET", "1", ""))
+	assert.Equal(t, `[]`, rw.Body.String())
+}
+
+func TestAvailabilityRejectsInvalidRules(t *testing.T) {
+	handler := setupAvailabilityHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("PUT", "1", `[{"from":"07:00"}]`))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("PUT", "1", `{"from":"07:00"}`))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("PUT", "42", `[]`))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, availabilityRequest("GET", "42", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
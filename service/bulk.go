@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+	"github.com/hashicorp-demoapp/coffee-service/spiffe"
+)
+
+// bulkDeleteResponse counts the coffees a bulk delete removed, or would remove
+// in a dry run
+type bulkDeleteResponse struct {
+	DryRun bool  `json:"dry_run"`
+	Count  int   `json:"count"`
+	IDs    []int `json:"ids"`
+}
+
+// BulkDeleteService is an HTTP Handler deleting every coffee matching the
+// filter query parameter. With dry_run=true it only counts them. Every delete
+// is written to the audit log.
+type BulkDeleteService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewBulkDelete creates a new BulkDelete handler
+func NewBulkDelete(repository data.Repository, l hclog.Logger) *BulkDeleteService {
+	return &BulkDeleteService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coffees route
+func (s *BulkDeleteService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle BulkDelete")
+
+	// a missing filter is rejected rather than deleting the whole catalogue
+	raw := r.URL.Query().Get("filter")
+	if raw == "" {
+		http.Error(rw, "filter is required", http.StatusBadRequest)
+		return
+	}
+	expr, err := filter.Parse(raw)
+	if err != nil {
+		s.logger.Debug("Invalid filter", "filter", raw, "error", err)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(rw, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ids, err := data.DeleteWhere(r.Context(), s.repository, expr, dryRun)
+	s.audit(r, raw, dryRun, ids, err)
+	if err != nil {
+		s.logger.Error("Unable to delete coffees", "filter", raw, "error", err)
+		http.Error(rw, "Unable to delete coffees", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(bulkDeleteResponse{DryRun: dryRun, Count: len(ids), IDs: ids})
+	if err != nil {
+		s.logger.Error("Unable to encode deleted coffees", "error", err)
+		http.Error(rw, "Unable to encode deleted coffees", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// audit logs who deleted which coffees, including the ones deleted before a
+// failure
+func (s *BulkDeleteService) audit(r *http.Request, raw string, dryRun bool, ids []int, err error) {
+	fields := []interface{}{
+		"audit", true,
+		"action", "bulk_delete",
+		"filter", raw,
+		"dry_run", dryRun,
+		"count", len(ids),
+		"ids", ids,
+		"remote_addr", r.RemoteAddr,
+	}
+	if tenant := r.Header.Get(middleware.TenantHeader); tenant != "" {
+		fields = append(fields, "tenant", tenant)
+	}
+	if id, ok := spiffe.FromContext(r.Context()); ok {
+		fields = append(fields, "spiffe_id", id.String())
+	}
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+
+	s.logger.Info("Bulk delete", fields...)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func setupBulkDeleteHandler(t *testing.T) (*BulkDeleteService, data.Repository, *bytes.Buffer) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	audit := &bytes.Buffer{}
+	l := hclog.New(&hclog.LoggerOptions{Output: audit, JSONFormat: true})
+	return NewBulkDelete(repository, l), repository, audit
+}
+
+func TestBulkDeleteDryRunCountsTheCoffees(t *testing.T) {
+	handler, repository, audit := setupBulkDeleteHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/coffees?filter=price%3C%3D150&dry_run=true", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"dry_run":true,"count":2,"ids":[3,4]}`, rw.Body.String())
+
+	coffees, err := repository.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(audit.Bytes(), &entry))
+	assert.Equal(t, "Bulk delete", entry["@message"])
+	assert.Equal(t, "price<=150", entry["filter"])
+	assert.Equal(t, true, entry["dry_run"])
+	assert.Equal(t, float64(2), entry["count"])
+}
+
+func TestBulkDeleteDeletesTheCoffees(t *testing.T) {
+	handler, repository, audit := setupBulkDeleteHandler(t)
+
+	r := httptest.NewRequest("DELETE", "/admin/coffees?filter=price%3C%3D150", nil)
+	r.Header.Set("X-Tenant-ID", "hashicups")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"dry_run":false,"count":2,"ids":[3,4]}`, rw.Body.String())
+
+	_, err := repository.FindByID(context.Background(), 3)
+	assert.Equal(t, data.ErrNotFound, err)
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(audit.Bytes(), &entry))
+	assert.Equal(t, "bulk_delete", entry["action"])
+	assert.Equal(t, false, entry["dry_run"])
+	assert.Equal(t, []interface{}{float64(3), float64(4)}, entry["ids"])
+	assert.Equal(t, "hashicups", entry["tenant"])
+	assert.NotEmpty(t, entry["remote_addr"])
+}
+
+func TestBulkDeleteRejectsInvalidRequests(t *testing.T) {
+	handler, repository, audit := setupBulkDeleteHandler(t)
+
+	for _, target := range []string{
+		"/admin/coffees",
+		"/admin/coffees?filter=price%3C",
+		"/admin/coffees?filter=colour%3Dred",
+		"/admin/coffees?filter=id%3D1&dry_run=maybe",
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("DELETE", target, nil))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, target)
+	}
+
+	coffees, err := repository.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+	assert.Empty(t, audit.String())
+}
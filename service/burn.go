@@ -0,0 +1,79 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/pressure"
+)
+
+// burnStatus is the running CPU burn, if any, and the limits of a burn
+type burnStatus struct {
+	Burning    *pressure.Burn `json:"burning"`
+	MaxCores   int            `json:"max_cores"`
+	MaxSeconds int            `json:"max_seconds"`
+}
+
+// BurnService is an HTTP Handler simulating CPU load. POST keeps the cores of
+// the query parameters busy for their seconds, GET reports the running burn
+// and DELETE stops it.
+type BurnService struct {
+	cpu    *pressure.CPU
+	logger hclog.Logger
+}
+
+// NewBurn creates a new Burn handler
+func NewBurn(cpu *pressure.CPU, l hclog.Logger) *BurnService {
+	return &BurnService{cpu, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/burn route
+func (s *BurnService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Burn", "method", r.Method)
+
+	maxCores, maxDuration := s.cpu.Limits()
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodPost:
+		cores, coresErr := strconv.Atoi(r.URL.Query().Get("cores"))
+		seconds, secondsErr := strconv.Atoi(r.URL.Query().Get("seconds"))
+		if coresErr != nil || secondsErr != nil {
+			http.Error(rw, "Burns need a number of cores and of seconds, e.g. ?cores=2&seconds=30", http.StatusBadRequest)
+			return
+		}
+
+		_, err := s.cpu.Burn(cores, time.Duration(seconds)*time.Second)
+		switch err {
+		case nil:
+		case pressure.ErrBurnLimit:
+			http.Error(rw, fmt.Sprintf("Burns run on 1 to %d cores for 1 to %d seconds", maxCores, int(maxDuration.Seconds())), http.StatusBadRequest)
+			return
+		default:
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		}
+		s.logger.Warn("CPU burn started", "cores", cores, "seconds", seconds)
+		status = http.StatusAccepted
+	case http.MethodDelete:
+		s.cpu.Stop()
+		s.logger.Info("CPU burn stopped")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := json.Marshal(burnStatus{Burning: s.cpu.Burning(), MaxCores: maxCores, MaxSeconds: int(maxDuration.Seconds())})
+	if err != nil {
+		s.logger.Error("Unable to encode burn", "error", err)
+		http.Error(rw, "Unable to encode burn", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
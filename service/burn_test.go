@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/pressure"
+)
+
+func TestBurnIsStartedAndStopped(t *testing.T) {
+	cpu := pressure.NewCPU(1, time.Minute)
+	s := NewBurn(cpu, hclog.NewNullLogger())
+	defer cpu.Stop()
+
+	for _, query := range []string{"", "cores=1", "cores=two&seconds=1", "cores=2&seconds=1", "cores=1&seconds=61", "cores=1&seconds=0"} {
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/burn?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, query)
+	}
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/burn?cores=1&seconds=30", nil))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"burning":{"cores":1,`)
+	assert.Contains(t, rw.Body.String(), `"max_cores":1,"max_seconds":60`)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/burn?cores=1&seconds=30", nil))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/burn", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/burn", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"burning":null,"max_cores":1,"max_seconds":60}`, rw.Body.String())
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+const (
+	// defaultChangesLimit is the number of changes returned when no limit is
+	// requested
+	defaultChangesLimit = 100
+	// maxChangesLimit caps the limit query parameter
+	maxChangesLimit = 1000
+)
+
+// changesResponse is a page of the change feed, cursor is passed as since to
+// read the next page
+type changesResponse struct {
+	Changes []data.Change `json:"changes"`
+	Cursor  uint64        `json:"cursor"`
+}
+
+// ChangesService is an HTTP Handler returning the writes recorded after a
+// cursor, so downstream caches can sync incrementally
+type ChangesService struct {
+	changes *data.ChangesRepository
+	logger  hclog.Logger
+}
+
+// NewChanges creates a new Changes handler
+func NewChanges(changes *data.ChangesRepository, l hclog.Logger) *ChangesService {
+	return &ChangesService{changes, l}
+}
+
+// ServeHTTP handles incoming requests for the changes route
+func (s *ChangesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Changes")
+
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			http.Error(rw, "since must be a cursor returned by /changes", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxChangesLimit {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxChangesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, cursor, err := s.changes.Since(since, limit)
+	if err == data.ErrCursorExpired {
+		http.Error(rw, "Cursor expired, resync from /coffees", http.StatusGone)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to read changes", "error", err)
+		http.Error(rw, "Unable to read changes", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(changesResponse{Changes: changes, Cursor: cursor})
+	if err != nil {
+		s.logger.Error("Unable to encode changes", "error", err)
+		http.Error(rw, "Unable to encode changes", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupChangesHandler(t *testing.T, retention int) (*ChangesService, *data.ChangesRepository) {
+	l := hclog.NewNullLogger()
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	changes := data.NewChanges(repository, retention)
+	return NewChanges(changes, l), changes
+}
+
+func TestChangesPagesThroughTheFeed(t *testing.T) {
+	handler, repository := setupChangesHandler(t, 100)
+	for _, name := range []string{"Feed Frappe", "Cursor Cold Brew", "Delta Doppio"} {
+		require.NoError(t, repository.CreateCoffee(context.Background(), &entities.Coffee{Name: name, Price: 200}))
+	}
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/changes?limit=2", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	page := struct {
+		Changes []struct {
+			Entity  string `json:"entity"`
+			Op      string `json:"op"`
+			Payload struct {
+				Name string `json:"name"`
+			} `json:"payload"`
+		} `json:"changes"`
+		Cursor uint64 `json:"cursor"`
+	}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &page))
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "coffee", page.Changes[0].Entity)
+	assert.Equal(t, "insert", page.Changes[0].Op)
+	assert.Equal(t, "Feed Frappe", page.Changes[0].Payload.Name)
+	assert.Equal(t, uint64(2), page.Cursor)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/changes?since=2", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &page))
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "Delta Doppio", page.Changes[0].Payload.Name)
+	assert.Equal(t, uint64(3), page.Cursor)
+}
+
+func TestChangesRejectsInvalidAndExpiredCursors(t *testing.T) {
+	handler, repository := setupChangesHandler(t, 1)
+	for n := 0; n < 3; n++ {
+		require.NoError(t, repository.CreateCoffee(context.Background(), &entities.Coffee{Name: "Expiring", Price: 200}))
+	}
+
+	for target, code := range map[string]int{
+		"/changes?since=abc":  http.StatusBadRequest,
+		"/changes?limit=5000": http.StatusBadRequest,
+		"/changes?since=1":    http.StatusGone,
+		"/changes?since=2":    http.StatusOK,
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, code, rw.Code, target)
+	}
+}
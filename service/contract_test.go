@@ -0,0 +1,144 @@
+package service
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// contractConnectionEnv names the environment variable holding a connection
+// string for a seeded products database. The Postgres contract tests are
+// skipped when it is unset.
+const contractConnectionEnv = "CONTRACT_CONNECTION_STRING"
+
+var updateContracts = flag.Bool("update-contracts", false, "rewrite the contract golden files")
+
+// contractBackends returns the repositories the contracts are verified
+// against, keyed by name
+func contractBackends(t *testing.T) map[string]data.Repository {
+	l := hclog.NewNullLogger()
+
+	memory, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	backends := map[string]data.Repository{"memdb": memory}
+
+	if connection := os.Getenv(contractConnectionEnv); connection != "" {
+		postgres, err := data.NewFromConfig(&config.Config{ConnectionString: connection, Logger: l})
+		require.NoError(t, err)
+		backends["postgres"] = postgres
+	}
+
+	return backends
+}
+
+// TestCoffeesContract asserts /coffees returns exactly the JSON shape the
+// HashiCups frontend-service expects, for every version and backend
+func TestCoffeesContract(t *testing.T) {
+	for name, repository := range contractBackends(t) {
+		for _, version := range []config.VersionKey{config.V1, config.V2, config.V3} {
+			cfg := &config.Config{Version: version, Logger: hclog.NewNullLogger()}
+			handler, err := NewCoffee(cfg, repository, nil)
+			require.NoError(t, err)
+
+			t.Run(fmt.Sprintf("%s/%s", name, version), func(t *testing.T) {
+				assertContract(t, "coffees", handler)
+			})
+
+			if version == config.V1 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%s/envelope", name, version), func(t *testing.T) {
+				assertContract(t, "coffees.envelope", middleware.NewEnvelope(version.String())(handler))
+			})
+		}
+	}
+}
+
+// assertContract compares the shape of the JSON served by handler with the
+// golden file testdata/contracts/<contract>.json
+func assertContract(t *testing.T, contract string, handler http.Handler) {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(rw, r)
+
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var body interface{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	got, err := shapeOf(body)
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "contracts", contract+".json")
+	if *updateContracts {
+		d, err := json.MarshalIndent(got, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(golden, append(d, '\n'), 0644))
+	}
+
+	d, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+
+	var expected interface{}
+	require.NoError(t, json.Unmarshal(d, &expected))
+	assert.Equal(t, expected, got)
+}
+
+// shapeOf replaces every JSON value with the name of its type. Arrays are
+// reduced to the shape shared by all of their non empty elements.
+func shapeOf(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		shape := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			s, err := shapeOf(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			shape[key] = s
+		}
+		return shape, nil
+	case []interface{}:
+		var element interface{}
+		for n, value := range v {
+			s, err := shapeOf(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", n, err)
+			}
+			if element != nil && !reflect.DeepEqual(element, s) {
+				return nil, fmt.Errorf("[%d]: shape %v differs from %v", n, s, element)
+			}
+			element = s
+		}
+		if element == nil {
+			return []interface{}{}, nil
+		}
+		return []interface{}{element}, nil
+	case string:
+		return "string", nil
+	case float64:
+		return "number", nil
+	case bool:
+		return "boolean", nil
+	case nil:
+		return "null", nil
+	}
+
+	return nil, fmt.Errorf("unexpected JSON value %T", v)
+}
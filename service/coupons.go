@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// CouponsService is an HTTP Handler managing the discount coupons redeemed on
+// orders. GET lists the coupons, or returns one by code, POST creates a coupon,
+// PUT replaces the type, value, expiry and usage limit of one and DELETE
+// removes it. The uses of a coupon are only counted by orders.
+type CouponsService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewCoupons creates a new Coupons handler
+func NewCoupons(repository data.Repository, l hclog.Logger) *CouponsService {
+	return &CouponsService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coupons routes
+func (s *CouponsService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Coupons", "method", r.Method)
+
+	code := mux.Vars(r)["code"]
+	var result interface{}
+	var err error
+	status := http.StatusOK
+	switch {
+	case r.Method == http.MethodPost || r.Method == http.MethodPut:
+		coupon := &entities.Coupon{}
+		if err := newDecoder(r, r.Body).Decode(coupon); err != nil {
+			invalidPayload(rw, "Invalid coupon", err)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if err = data.CreateCoupon(r.Context(), s.repository, coupon); err == nil {
+				s.logger.Info("Coupon created", "code", coupon.Code)
+				status = http.StatusCreated
+			}
+		} else {
+			coupon.Code = code
+			if err = data.UpdateCoupon(r.Context(), s.repository, coupon); err == nil {
+				s.logger.Info("Coupon updated", "code", coupon.Code)
+			}
+		}
+		result = coupon
+	case r.Method == http.MethodDelete:
+		if err = data.DeleteCoupon(r.Context(), s.repository, code); err == nil {
+			s.logger.Info("Coupon deleted", "code", code)
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case code != "":
+		result, err = data.FindCoupon(r.Context(), s.repository, code)
+	default:
+		result, err = data.FindCoupons(r.Context(), s.repository)
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Coupon not found", http.StatusNotFound)
+		return
+	case data.ErrInvalidCoupon:
+		http.Error(rw, "Coupons need a code of up to 32 letters, digits, - or _, a type of percent or fixed, a positive value of at most 100 percent and a usage limit of 0 or more", http.StatusBadRequest)
+		return
+	case data.ErrCouponExists:
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	case data.ErrCouponsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to manage coupons", "method", r.Method, "code", code, "error", err)
+		http.Error(rw, "Unable to manage coupons", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("Unable to encode coupons", "error", err)
+		http.Error(rw, "Unable to encode coupons", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
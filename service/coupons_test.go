@@ -0,0 +1,82 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func setupCouponsHandler(t *testing.T) *CouponsService {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return NewCoupons(repository, hclog.NewNullLogger())
+}
+
+func couponsRequest(method, code, body string) *http.Request {
+	path := "/admin/coupons"
+	if code != "" {
+		path += "/" + code
+	}
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	return mux.SetURLVars(r, map[string]string{"code": code})
+}
+
+func TestCouponsAreManaged(t *testing.T) {
+	s := setupCouponsHandler(t)
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("POST", "", `{"code":"spring-5","type":"fixed","value":5,"usage_limit":100,"expires_at":"2030-06-01T00:00:00Z"}`))
+	require.Equal(t, http.StatusCreated, rw.Code)
+	assert.JSONEq(t, `{"code":"SPRING-5","type":"fixed","value":5,"usage_limit":100,"used":0,"expires_at":"2030-06-01T00:00:00Z"}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("POST", "", `{"code":"SPRING-5","type":"fixed","value":5}`))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("PUT", "spring-5", `{"type":"percent","value":20}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"code":"SPRING-5","type":"percent","value":20,"usage_limit":0,"used":0}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("GET", "", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"code":"SPRING-5"`)
+	assert.Contains(t, rw.Body.String(), `"code":"WELCOME10"`)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("DELETE", "spring-5", ""))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("GET", "spring-5", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestCouponsRejectsInvalidCoupons(t *testing.T) {
+	s := setupCouponsHandler(t)
+
+	for _, body := range []string{`{"code":"FREE","type":"gift","value":5}`, `{"code":"FREE","type":"percent","value":150}`, `not json`} {
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, couponsRequest("POST", "", body))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+	}
+}
+
+func TestCouponsUnsupported(t *testing.T) {
+	s := NewCoupons(&data.MockRepository{}, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, couponsRequest("GET", "", ""))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// maxCoffeeSize is the largest coffee accepted for creation
+const maxCoffeeSize = 1 << 20
+
+// duplicate is an existing coffee whose name is similar to a new one
+type duplicate struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Similarity float64 `json:"similarity"`
+	Href       string  `json:"href"`
+}
+
+// duplicatesResponse lists the coffees a new coffee may duplicate
+type duplicatesResponse struct {
+	Error      string      `json:"error"`
+	Duplicates []duplicate `json:"duplicates"`
+	RequestID  string      `json:"request_id,omitempty"`
+	TraceID    string      `json:"trace_id,omitempty"`
+}
+
+// CreateService is an HTTP Handler creating a coffee. A coffee whose name is
+// similar to an existing one is rejected with 409 Conflict, linking to the
+// existing coffees, unless the request passes force=true.
+type CreateService struct {
+	repository data.Repository
+	similarity float64
+	logger     hclog.Logger
+}
+
+// NewCreate creates a new Create handler rejecting names with a similarity of
+// at least similarity to an existing coffee, or none when similarity is 0
+func NewCreate(repository data.Repository, similarity float64, l hclog.Logger) *CreateService {
+	return &CreateService{repository, similarity, l}
+}
+
+// ServeHTTP handles incoming requests for the coffee creation route
+func (s *CreateService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Create")
+
+	force := false
+	if raw := r.URL.Query().Get("force"); raw != "" {
+		var err error
+		if force, err = strconv.ParseBool(raw); err != nil {
+			http.Error(rw, "force must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	coffee := &entities.Coffee{}
+	if err := newDecoder(r, http.MaxBytesReader(rw, r.Body, maxCoffeeSize)).Decode(coffee); err != nil {
+		invalidPayload(rw, "Invalid coffee", err)
+		return
+	}
+	if err := coffee.Validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	coffee.ID, coffee.Stats = 0, nil
+
+	ingredients, err := s.repository.FindIngredients(r.Context())
+	if err != nil {
+		s.logger.Error("Unable to get ingredients from database", "error", err)
+		http.Error(rw, "Unable to get ingredients from database", http.StatusInternalServerError)
+		return
+	}
+	known := make(map[int]bool, len(ingredients))
+	for _, i := range ingredients {
+		known[i.ID] = true
+	}
+	for _, i := range coffee.Ingredients {
+		if !known[i.IngredientID] {
+			http.Error(rw, fmt.Sprintf("Unknown ingredient %d", i.IngredientID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// checked before the create rather than in the same transaction, so two
+	// concurrent requests can still create duplicates
+	if s.similarity > 0 && !force {
+		similar, err := data.FindSimilar(r.Context(), s.repository, coffee.Name, s.similarity)
+		if err != nil {
+			s.logger.Error("Unable to find similar coffees", "error", err)
+			http.Error(rw, "Unable to find similar coffees", http.StatusInternalServerError)
+			return
+		}
+		if len(similar) > 0 {
+			s.conflict(rw, r, coffee.Name, similar)
+			entities.PutCoffees(similar)
+			return
+		}
+		entities.PutCoffees(similar)
+	}
+
+	if err := s.repository.CreateCoffee(r.Context(), coffee); err != nil {
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to create coffee", "error", err)
+		http.Error(rw, "Unable to create coffee", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Created coffee", "id", coffee.ID, "name", coffee.Name, "force", force)
+
+	body, err := json.Marshal(coffee)
+	if err != nil {
+		s.logger.Error("Unable to encode coffee", "error", err)
+		http.Error(rw, "Unable to encode coffee", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Location", coffeeHref(coffee.ID))
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write(body)
+}
+
+// conflict rejects a name similar to the names of existing coffees, with a
+// link to each of them
+func (s *CreateService) conflict(rw http.ResponseWriter, r *http.Request, name string, similar entities.Coffees) {
+	response := duplicatesResponse{
+		Error:      fmt.Sprintf("%q is similar to existing coffees, pass force=true to create it anyway", name),
+		Duplicates: make([]duplicate, 0, len(similar)),
+		RequestID:  middleware.RequestID(r.Context()),
+		TraceID:    middleware.TraceID(r.Context()),
+	}
+	links := make([]string, 0, len(similar))
+	for _, c := range similar {
+		href := coffeeHref(c.ID)
+		response.Duplicates = append(response.Duplicates, duplicate{
+			ID:         c.ID,
+			Name:       c.Name,
+			Similarity: data.NameSimilarity(name, c.Name),
+			Href:       href,
+		})
+		links = append(links, fmt.Sprintf(`<%s>; rel="duplicate"`, href))
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		s.logger.Error("Unable to encode duplicates", "error", err)
+		http.Error(rw, "Unable to encode duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Link", strings.Join(links, ", "))
+	rw.WriteHeader(http.StatusConflict)
+	rw.Write(body)
+}
+
+// coffeeHref returns the path of a coffee
+func coffeeHref(id int) string {
+	return "/coffees/" + strconv.Itoa(id)
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/mocks"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func setupCreateHandler(t *testing.T, similarity float64) (*CreateService, data.Repository) {
+	l := hclog.NewNullLogger()
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	return NewCreate(repository, similarity, l), repository
+}
+
+func TestCreateCoffee(t *testing.T) {
+	handler, repository := setupCreateHandler(t, 0.6)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(
+		`{"name":"Oat Flat White","price":300,"ingredients":[{"ingredient_id":1,"quantity":40,"unit":"ml"}]}`)))
+	require.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.Equal(t, "/coffees/7", rw.Header().Get("Location"))
+
+	created := struct {
+		ID          int `json:"id"`
+		Ingredients []struct {
+			Name string `json:"name"`
+		} `json:"ingredients"`
+	}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &created))
+	assert.Equal(t, 7, created.ID)
+	require.Len(t, created.Ingredients, 1)
+	assert.Equal(t, "Espresso'", created.Ingredients[0].Name)
+
+	coffee, err := repository.FindByID(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Oat Flat White", coffee.Name)
+}
+
+func TestCreateRejectsDuplicateNames(t *testing.T) {
+	handler, repository := setupCreateHandler(t, 0.6)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"vaulate!","price":200}`)))
+	require.Equal(t, http.StatusConflict, rw.Code)
+	assert.Equal(t, `</coffees/2>; rel="duplicate"`, rw.Header().Get("Link"))
+
+	conflict := duplicatesResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &conflict))
+	assert.Contains(t, conflict.Error, "force=true")
+	require.Len(t, conflict.Duplicates, 1)
+	assert.Equal(t, duplicate{ID: 2, Name: "Vaulatte", Similarity: 0.7, Href: "/coffees/2"}, conflict.Duplicates[0])
+
+	coffees, err := repository.Find(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, coffees, 6)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees?force=true", strings.NewReader(`{"name":"vaulate!","price":200}`)))
+	assert.Equal(t, http.StatusCreated, rw.Code)
+}
+
+func TestCreateDuplicateNamesConflictIncludesTheRequestID(t *testing.T) {
+	handler, _ := setupCreateHandler(t, 0.6)
+
+	r := httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"vaulate!","price":200}`))
+	r.Header.Set(middleware.RequestIDHeader, "curl-42")
+	rw := httptest.NewRecorder()
+	middleware.NewRequestID()(handler).ServeHTTP(rw, r)
+	require.Equal(t, http.StatusConflict, rw.Code)
+
+	conflict := duplicatesResponse{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &conflict))
+	assert.Equal(t, "curl-42", conflict.RequestID)
+}
+
+func TestCreateWithoutDuplicateDetection(t *testing.T) {
+	handler, _ := setupCreateHandler(t, 0)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Vaulatte","price":200}`)))
+	assert.Equal(t, http.StatusCreated, rw.Code)
+}
+
+func TestCreateConflictsWithTakenKeys(t *testing.T) {
+	repository := &mocks.Repository{}
+	repository.FailOn("CreateCoffee", 1, fmt.Errorf("%w: coffee coffee_slug", data.ErrConflict))
+	handler := NewCreate(repository, 0, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Vaulatte","price":200}`)))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	assert.Equal(t, "a row with this key already exists: coffee coffee_slug\n", rw.Body.String())
+}
+
+func TestCreateRejectsUnknownFieldsWhenStrict(t *testing.T) {
+	handler, _ := setupCreateHandler(t, 0.6)
+	body := `{"name":"Cold Brew","price":300,"prize":300}`
+
+	rw := httptest.NewRecorder()
+	middleware.NewStrictJSON()(handler).ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "Invalid coffee, unknown field \"prize\"\n", rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, rw.Code)
+}
+
+func TestCreateRejectsInvalidCoffees(t *testing.T) {
+	handler, _ := setupCreateHandler(t, 0.6)
+
+	for target, body := range map[string]string{
+		"/coffees":             `{"name":`,
+		"/coffees?force=maybe": `{"name":"Cold Brew"}`,
+		"/coffees?force=true":  `{"name":" - ","price":200}`,
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+	}
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Cold Brew","price":0}`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "invalid coffee, price must be positive\n", rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Cold Brew","price":200,"image":"javascript:alert(1)"}`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "invalid coffee, image must be a path such as /packer.png or an http URL\n", rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Cold Brew","price":200,"ingredients":[{"ingredient_id":42}]}`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "Unknown ingredient 42\n", rw.Body.String())
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// topSellers is the number of coffees ranked by the dashboard
+const topSellers = 5
+
+// DashboardService is an HTTP Handler returning the aggregates of the admin
+// dashboard: the number of coffees, the orders recorded per status, the top
+// sellers and the revenue since midnight UTC. The repository computes them,
+// callers cache the response.
+type DashboardService struct {
+	repository data.Repository
+	logger     hclog.Logger
+	now        func() time.Time
+}
+
+// NewDashboard creates a new Dashboard handler
+func NewDashboard(repository data.Repository, l hclog.Logger) *DashboardService {
+	return &DashboardService{repository, l, time.Now}
+}
+
+// ServeHTTP handles incoming requests for the /admin/stats route
+func (s *DashboardService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Dashboard")
+
+	midnight := s.now().UTC().Truncate(24 * time.Hour)
+	stats, err := data.AggregateStats(r.Context(), s.repository, midnight, topSellers)
+	switch err {
+	case nil:
+	case data.ErrStatsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to aggregate stats", "error", err)
+		http.Error(rw, "Unable to aggregate stats", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(stats)
+	if err != nil {
+		s.logger.Error("Unable to encode stats", "error", err)
+		http.Error(rw, "Unable to encode stats", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
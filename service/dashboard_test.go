@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestDashboardAggregatesOrders(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, order := range []entities.OrderRecord{
+		{ID: 1, Status: entities.OrderPaid, Total: 400, CreatedAt: now.Add(-24 * time.Hour), Items: []entities.OrderRecordItem{{CoffeeID: 2, Name: "Vaulatte", Quantity: 2, Price: 200}}},
+		{ID: 2, Status: entities.OrderPaid, Total: 150, CreatedAt: now, Items: []entities.OrderRecordItem{{CoffeeID: 3, Name: "Nomadicano", Quantity: 1, Price: 150}}},
+		{ID: 3, Status: entities.OrderCancelled, Total: 150, CreatedAt: now, Items: []entities.OrderRecordItem{{CoffeeID: 3, Name: "Nomadicano", Quantity: 1, Price: 150}}},
+	} {
+		order := order
+		require.NoError(t, data.RecordOrder(context.Background(), repository, &order))
+	}
+	s := NewDashboard(repository, hclog.NewNullLogger())
+	s.now = func() time.Time { return now }
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/stats", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{
+		"coffees": 6,
+		"orders_by_status": {"paid": 2, "cancelled": 1},
+		"top_sellers": [{"coffee_id": 2, "name": "Vaulatte", "quantity": 2}, {"coffee_id": 3, "name": "Nomadicano", "quantity": 1}],
+		"revenue_today": 150
+	}`, rw.Body.String())
+}
+
+func TestDashboardUnsupported(t *testing.T) {
+	s := NewDashboard(&data.MockRepository{}, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/stats", nil))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
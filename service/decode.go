@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// unknownFieldPrefix starts the errors of a decoder rejecting a field, which
+// encoding/json does not give a type of its own
+const unknownFieldPrefix = `json: unknown field "`
+
+// newDecoder returns a decoder of the JSON payload of r read from body. It
+// rejects fields the payload type does not have when strict decoding is
+// enabled, see middleware.NewStrictJSON.
+func newDecoder(r *http.Request, body io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(body)
+	if middleware.StrictJSON(r.Context()) {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder
+}
+
+// invalidPayload fails a request with 400 and message because its payload
+// could not be decoded, naming the offending field when err rejected one
+func invalidPayload(rw http.ResponseWriter, message string, err error) {
+	if err != nil && strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		field := strings.TrimSuffix(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)
+		message = fmt.Sprintf("%s, unknown field %q", message, field)
+	}
+	http.Error(rw, message, http.StatusBadRequest)
+}
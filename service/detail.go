@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+)
+
+// DetailService is an HTTP Handler returning a single coffee, looked up by
+// its numeric ID or its slug. Every successful request counts as a view of
+// the coffee.
+type DetailService struct {
+	repository data.Repository
+	popularity *popularity.Tracker
+	logger     hclog.Logger
+}
+
+// NewDetail creates a new Detail handler
+func NewDetail(repository data.Repository, tracker *popularity.Tracker, l hclog.Logger) *DetailService {
+	return &DetailService{repository, tracker, l}
+}
+
+// ServeHTTP handles incoming requests for the api coffee route
+func (s *DetailService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Coffee")
+
+	coffee, err := findCoffee(r.Context(), s.repository, mux.Vars(r)["id"], data.LocaleChain(r.Header.Get("Accept-Language")))
+	if err == data.ErrNotFound {
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to get coffee from database", "error", err)
+		http.Error(rw, "Unable to get coffee from database", http.StatusInternalServerError)
+		return
+	}
+
+	s.popularity.RecordView(coffee.ID)
+	if r.URL.Query().Get("include") == "stats" {
+		stats := s.popularity.Stats(coffee.ID)
+		coffee.Stats = &stats
+	}
+
+	encoder := encoding.Default.Negotiate(r.Header.Get("Accept"), coffee)
+	body, err := encoder.Encode(coffee)
+	if err != nil {
+		s.logger.Error("Unable to encode coffee", "content_type", encoder.ContentType(), "error", err)
+		http.Error(rw, "Unable to encode coffee", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", encoder.ContentType())
+	rw.Header().Add("Vary", "Accept")
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Write(body)
+}
+
+// findCoffee returns the coffee with the numeric ID or the slug id, with its
+// translations for locales and its images
+func findCoffee(ctx context.Context, repository data.Repository, id string, locales []string) (*entities.Coffee, error) {
+	// slugs are never all digits, so anything else is a slug
+	var coffee *entities.Coffee
+	coffeeID, err := strconv.Atoi(id)
+	if err == nil {
+		coffee, err = repository.FindByID(ctx, coffeeID)
+	} else {
+		coffee, err = data.FindBySlug(ctx, repository, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	localized := entities.Coffees{*coffee}
+	if err := data.Localize(ctx, repository, localized, locales); err != nil {
+		return nil, fmt.Errorf("translations: %w", err)
+	}
+	if err := data.AttachImages(ctx, repository, localized); err != nil {
+		return nil, fmt.Errorf("images: %w", err)
+	}
+	return &localized[0], nil
+}
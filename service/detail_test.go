@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+)
+
+func setupDetailHandler(t *testing.T) (*DetailService, *data.MockRepository, *popularity.Tracker) {
+	c := &data.MockRepository{}
+	c.On("FindByID", 1).Return(&entities.Coffee{ID: 1, Name: "Test"}, nil)
+	c.On("FindByID", 42).Return(nil, data.ErrNotFound)
+	c.On("Find").Return(entities.Coffees{{ID: 1, Name: "Test", Slug: "test"}}, nil)
+
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	return NewDetail(c, tracker, hclog.Default()), c, tracker
+}
+
+func detailRequest(id string, query string) *http.Request {
+	r := httptest.NewRequest("GET", "/coffees/"+id+query, nil)
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func TestDetailReturnsCoffeeAndRecordsView(t *testing.T) {
+	s, _, tracker := setupDetailHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, detailRequest("1", ""))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", bd.Name)
+	assert.Nil(t, bd.Stats)
+	assert.Equal(t, int64(1), tracker.Stats(1).Views)
+}
+
+func TestDetailIncludesStatsWhenRequested(t *testing.T) {
+	s, _, _ := setupDetailHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, detailRequest("1", "?include=stats"))
+
+	bd := entities.Coffee{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), bd.Stats.Views)
+}
+
+func TestDetailReturnsNotFound(t *testing.T) {
+	s, _, tracker := setupDetailHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, detailRequest("42", ""))
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, int64(0), tracker.Stats(42).Views)
+}
+
+func TestDetailLooksUpSlugs(t *testing.T) {
+	s, _, tracker := setupDetailHandler(t)
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, detailRequest("test", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &bd))
+	assert.Equal(t, 1, bd.ID)
+	assert.Equal(t, "test", bd.Slug)
+	assert.Equal(t, int64(1), tracker.Stats(1).Views)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, detailRequest("unknown-coffee", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// defaultDrainTimeout is how long a drain waits for the requests in flight
+// without a timeout parameter, within the default termination grace period of
+// Kubernetes
+const defaultDrainTimeout = 25 * time.Second
+
+// drainInterval is how often a drain checks the requests in flight
+const drainInterval = 100 * time.Millisecond
+
+// drainStatus is the number of requests still in flight while draining
+type drainStatus struct {
+	Draining bool  `json:"draining"`
+	InFlight int64 `json:"in_flight"`
+}
+
+// DrainService is an HTTP Handler draining the service before it stops, e.g.
+// from a Kubernetes preStop hook. GET fails the readiness probe from then on
+// and waits for the other requests in flight to complete, for up to the
+// timeout query parameter, then reports how many are left.
+type DrainService struct {
+	drainer *middleware.Drainer
+	logger  hclog.Logger
+}
+
+// NewDrain creates a new Drain handler
+func NewDrain(drainer *middleware.Drainer, l hclog.Logger) *DrainService {
+	return &DrainService{drainer, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/drain route
+func (s *DrainService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Drain")
+
+	timeout := defaultDrainTimeout
+	if param := r.URL.Query().Get("timeout"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d < 0 {
+			http.Error(rw, "Drains need a timeout of 0 or more, e.g. ?timeout=20s", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+
+	if s.drainer.Drain() {
+		s.logger.Warn("Draining requests", "in_flight", s.drainer.InFlight(r.Context()))
+	}
+
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	inFlight := s.drainer.InFlight(r.Context())
+	for inFlight > 0 && time.Now().Before(deadline) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		inFlight = s.drainer.InFlight(r.Context())
+	}
+	if inFlight > 0 {
+		s.logger.Warn("Requests still in flight after draining", "in_flight", inFlight, "timeout", timeout)
+	}
+
+	body, err := json.Marshal(drainStatus{Draining: true, InFlight: inFlight})
+	if err != nil {
+		s.logger.Error("Unable to encode drain", "error", err)
+		http.Error(rw, "Unable to encode drain", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
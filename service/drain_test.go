@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func TestDrainWaitsForRequestsInFlight(t *testing.T) {
+	drainer := middleware.NewDrainer(metrics.FanoutSink{})
+	drain := drainer.Middleware()(NewDrain(drainer, hclog.NewNullLogger()))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := drainer.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+	<-started
+
+	time.AfterFunc(2*drainInterval, func() { close(release) })
+
+	rw := httptest.NewRecorder()
+	drain.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/drain", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.True(t, drainer.Draining())
+	status := drainStatus{}
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.Equal(t, drainStatus{Draining: true, InFlight: 0}, status)
+}
+
+func TestDrainReportsRequestsLeftAfterTimeout(t *testing.T) {
+	drainer := middleware.NewDrainer(metrics.FanoutSink{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	slow := drainer.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+	<-started
+
+	rw := httptest.NewRecorder()
+	NewDrain(drainer, hclog.NewNullLogger()).ServeHTTP(rw, httptest.NewRequest("GET", "/admin/drain?timeout=0s", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"draining":true,"in_flight":1}`, rw.Body.String())
+}
+
+func TestDrainRejectsInvalidTimeout(t *testing.T) {
+	drainer := middleware.NewDrainer(metrics.FanoutSink{})
+
+	rw := httptest.NewRecorder()
+	NewDrain(drainer, hclog.NewNullLogger()).ServeHTTP(rw, httptest.NewRequest("GET", "/admin/drain?timeout=soon", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.False(t, drainer.Draining())
+}
@@ -0,0 +1,237 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+const (
+	// ContentTypeJSON is the default response media type
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf is the media type of proto/coffee.proto encoded responses
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeMsgPack is the media type of MessagePack encoded responses
+	ContentTypeMsgPack = "application/msgpack"
+	// ContentTypeXML is the media type of XML encoded responses
+	ContentTypeXML = "application/xml"
+)
+
+// Encoder serializes response payloads to a single media type
+type Encoder interface {
+	ContentType() string
+	Encode(v interface{}) ([]byte, error)
+}
+
+// PayloadEncoder is an Encoder of only some payloads, like the entities with
+// a protobuf encoding
+type PayloadEncoder interface {
+	Encoder
+	// Encodes tells whether Encode supports v
+	Encodes(v interface{}) bool
+}
+
+// Registry resolves the Encoder to use for a request from its Accept header.
+type Registry struct {
+	mu       sync.RWMutex
+	encoders map[string]Encoder
+	fallback Encoder
+}
+
+// NewRegistry creates a Registry that negotiates between the given encoders,
+// using fallback when the client does not ask for anything supported.
+func NewRegistry(fallback Encoder, encoders ...Encoder) *Registry {
+	r := &Registry{encoders: map[string]Encoder{}, fallback: fallback}
+
+	r.Register(fallback)
+	for _, e := range encoders {
+		r.Register(e)
+	}
+
+	return r
+}
+
+// Register adds an encoder, replacing any encoder for the same media type,
+// including the fallback
+func (r *Registry) Register(e Encoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.encoders[e.ContentType()] = e
+	if r.fallback.ContentType() == e.ContentType() {
+		r.fallback = e
+	}
+}
+
+// Negotiate returns the registered encoder of v the Accept header prefers,
+// falling back to the default encoder when nothing better matches. Encoders
+// which do not support v are skipped, so a client asking for protobuf gets
+// JSON rather than an error for payloads without a protobuf encoding.
+func (r *Registry) Negotiate(accept string, v interface{}) Encoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	best := r.fallback
+	bestQ := 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+
+		e, ok := r.encoders[mediaType]
+		if !ok || q <= bestQ {
+			continue
+		}
+		if p, ok := e.(PayloadEncoder); ok && !p.Encodes(v) {
+			continue
+		}
+		best, bestQ = e, q
+	}
+
+	return best
+}
+
+// Default is the registry used by the coffee handlers
+var Default = NewRegistry(JSON{}, Protobuf{}, MsgPack{}, XML{}, JSONAPI{}, HAL{})
+
+// JSON encodes payloads with encoding/json
+type JSON struct{}
+
+// ContentType implements Encoder
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+// Encode implements Encoder
+func (JSON) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// jsonAppender is implemented by entities with a hand written JSON encoding
+type jsonAppender interface {
+	AppendJSON(b []byte) ([]byte, error)
+}
+
+// FastJSON encodes payloads implementing AppendJSON without reflection, and
+// any other payload with encoding/json. Its output is identical to JSON's.
+// Payloads are appended to buffers from the pool, see Recycle.
+type FastJSON struct{}
+
+// ContentType implements Encoder
+func (FastJSON) ContentType() string { return ContentTypeJSON }
+
+// Encode implements Encoder
+func (FastJSON) Encode(v interface{}) ([]byte, error) {
+	if a, ok := v.(jsonAppender); ok {
+		return a.AppendJSON(getBuffer())
+	}
+
+	return json.Marshal(v)
+}
+
+// buffers recycles encode buffers between responses, it holds pointers so
+// putting a buffer back does not allocate
+var buffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, minBufferSize)
+		return &b
+	},
+}
+
+// minBufferSize is the capacity of new encode buffers
+const minBufferSize = 4096
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() []byte {
+	return (*buffers.Get().(*[]byte))[:0]
+}
+
+// Recycle returns an encoded payload to the buffer pool once it has been
+// written, the payload must not be used afterwards. Any payload can be
+// recycled, whichever encoder returned it.
+func Recycle(b []byte) {
+	if cap(b) == 0 {
+		return
+	}
+
+	b = b[:0]
+	buffers.Put(&b)
+}
+
+// protoMarshaler is implemented by entities with a hand written protobuf encoding
+type protoMarshaler interface {
+	ToProto() ([]byte, error)
+}
+
+// Protobuf encodes payloads implementing ToProto
+type Protobuf struct{}
+
+// ContentType implements Encoder
+func (Protobuf) ContentType() string { return ContentTypeProtobuf }
+
+// Encodes implements PayloadEncoder
+func (Protobuf) Encodes(v interface{}) bool {
+	_, ok := v.(protoMarshaler)
+	return ok
+}
+
+// Encode implements Encoder
+func (Protobuf) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(protoMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T has no protobuf encoding", v)
+	}
+
+	return m.ToProto()
+}
+
+// MsgPack encodes payloads as MessagePack, reusing the json struct tags so
+// the field names match the JSON responses.
+type MsgPack struct{}
+
+// ContentType implements Encoder
+func (MsgPack) ContentType() string { return ContentTypeMsgPack }
+
+// Encode implements Encoder
+func (MsgPack) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseJSONTag(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// XML encodes payloads with encoding/xml, behind an XML declaration. The
+// entities name their elements like their JSON fields.
+type XML struct{}
+
+// ContentType implements Encoder
+func (XML) ContentType() string { return ContentTypeXML }
+
+// Encode implements Encoder
+func (XML) Encode(v interface{}) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
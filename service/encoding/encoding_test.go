@@ -0,0 +1,185 @@
+package encoding
+
+import (
+	"encoding/xml"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v4"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestNegotiateDefaultsToJSON(t *testing.T) {
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("", &entities.Coffees{}).ContentType())
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("*/*", &entities.Coffees{}).ContentType())
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("text/html", &entities.Coffees{}).ContentType())
+}
+
+func TestNegotiateSelectsRegisteredEncoder(t *testing.T) {
+	assert.Equal(t, ContentTypeProtobuf, Default.Negotiate("application/x-protobuf", &entities.Coffees{}).ContentType())
+	assert.Equal(t, ContentTypeMsgPack, Default.Negotiate("application/msgpack", &entities.Coffees{}).ContentType())
+	assert.Equal(t, ContentTypeXML, Default.Negotiate("application/xml", &entities.Coffees{}).ContentType())
+	assert.Equal(t, ContentTypeProtobuf, Default.Negotiate("application/json;q=0.5, application/x-protobuf", &entities.Coffees{}).ContentType())
+}
+
+func TestNegotiateHonoursQuality(t *testing.T) {
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("application/x-protobuf;q=0.2, application/json", &entities.Coffees{}).ContentType())
+}
+
+type plainText struct{}
+
+func (plainText) ContentType() string                  { return "text/plain" }
+func (plainText) Encode(v interface{}) ([]byte, error) { return []byte("ok"), nil }
+
+func TestRegisterAddsEncoder(t *testing.T) {
+	r := NewRegistry(JSON{})
+	assert.Equal(t, ContentTypeJSON, r.Negotiate("text/plain", &entities.Coffees{}).ContentType())
+
+	r.Register(plainText{})
+	assert.Equal(t, "text/plain", r.Negotiate("text/plain", &entities.Coffees{}).ContentType())
+}
+
+func TestNegotiateSkipsEncodersNotSupportingThePayload(t *testing.T) {
+	ingredients := &entities.Ingredients{}
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("application/x-protobuf", ingredients).ContentType())
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("application/hal+json", ingredients).ContentType())
+	assert.Equal(t, ContentTypeJSON, Default.Negotiate("application/vnd.api+json", ingredients).ContentType())
+	assert.Equal(t, ContentTypeMsgPack, Default.Negotiate("application/x-protobuf, application/msgpack;q=0.5", ingredients).ContentType())
+	assert.Equal(t, ContentTypeProtobuf, Default.Negotiate("application/x-protobuf", &entities.Coffee{}).ContentType())
+}
+
+func TestProtobufRejectsUnsupportedPayloads(t *testing.T) {
+	_, err := Protobuf{}.Encode(map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestMsgPackUsesJSONFieldNames(t *testing.T) {
+	d, err := MsgPack{}.Encode(&entities.Coffees{entities.Coffee{ID: 1, Name: "Latte", CreatedAt: "now"}})
+	assert.NoError(t, err)
+
+	bd := make([]map[string]interface{}, 0)
+	err = msgpack.Unmarshal(d, &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "Latte", bd[0]["name"])
+	assert.NotContains(t, bd[0], "CreatedAt")
+	assert.NotContains(t, bd[0], "created_at")
+}
+
+func TestXMLNamesElementsLikeJSON(t *testing.T) {
+	d, err := XML{}.Encode(&entities.Coffees{entities.Coffee{ID: 1, Name: "Latte <&>", CreatedAt: "now", Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Name: "Espresso"}}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, xml.Header+"<coffees><coffee><id>1</id><name>Latte &lt;&amp;&gt;</name><slug></slug><teaser></teaser>"+
+		"<description></description><price>0</price><image></image><status></status><ingredients><ingredient>"+
+		"<ingredient_id>1</ingredient_id><name>Espresso</name><quantity>0</quantity><unit></unit>"+
+		"</ingredient></ingredients></coffee></coffees>", string(d))
+}
+
+func TestXMLRoundTrips(t *testing.T) {
+	coffees := entities.Coffees{
+		{ID: 1, Name: "Latte", Price: 120.5, Status: "published", Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}}},
+		{ID: 2, Name: "Vaulatte", Ingredients: []entities.CoffeeIngredients{}, Stats: &entities.CoffeeStats{Views: 3, Orders: 1, Score: 0.5}},
+	}
+	d, err := XML{}.Encode(&coffees)
+	require.NoError(t, err)
+
+	decoded := entities.Coffees{}
+	require.NoError(t, xml.Unmarshal(d, &decoded))
+	assert.Equal(t, coffees[0], decoded[0])
+	// an empty list of ingredients has no elements to tell it from nil
+	coffees[1].Ingredients = nil
+	assert.Equal(t, coffees[1], decoded[1])
+
+	d, err = XML{}.Encode(&coffees[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(d), "<coffee><id>1</id>")
+
+	coffee := entities.Coffee{}
+	require.NoError(t, xml.Unmarshal(d, &coffee))
+	assert.Equal(t, coffees[0], coffee)
+}
+
+func TestRegisterReplacesFallback(t *testing.T) {
+	r := NewRegistry(JSON{})
+
+	r.Register(FastJSON{})
+	assert.Equal(t, FastJSON{}, r.Negotiate("", &entities.Coffees{}))
+	assert.Equal(t, FastJSON{}, r.Negotiate("application/json", &entities.Coffees{}))
+}
+
+func TestFastJSONMatchesJSON(t *testing.T) {
+	payloads := []interface{}{
+		&entities.Coffees{entities.Coffee{ID: 1, Name: "Latte <&>", Price: 120.5, Ingredients: []entities.CoffeeIngredients{{IngredientID: 1}}}},
+		&entities.Coffee{ID: 2, Name: "Vaulatte", Stats: &entities.CoffeeStats{Views: 1}},
+		map[string]string{"error": "not found"},
+	}
+
+	for _, payload := range payloads {
+		want, err := JSON{}.Encode(payload)
+		assert.NoError(t, err)
+
+		got, err := FastJSON{}.Encode(payload)
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+		Recycle(got)
+	}
+}
+
+func TestRecycledBuffersAreReused(t *testing.T) {
+	Recycle(nil)
+
+	b := append(getBuffer(), "coffee"...)
+	Recycle(b)
+	assert.Empty(t, getBuffer())
+}
+
+// benchmarkEncode encodes a list of 100 coffees with e, recycling the
+// payloads when recycle is true
+func benchmarkEncode(b *testing.B, e Encoder, recycle bool) {
+	coffees := make(entities.Coffees, 0, 100)
+	for i := 1; i <= cap(coffees); i++ {
+		coffees = append(coffees, entities.Coffee{
+			ID:          i,
+			Name:        "Terraspresso",
+			Teaser:      "Nothing kickstarts your day like a provision of Terraspresso",
+			Price:       150,
+			Image:       "/terraform.png",
+			Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}},
+		})
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body, err := e.Encode(&coffees)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if recycle {
+			Recycle(body)
+		}
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+}
+
+func BenchmarkJSON(b *testing.B) {
+	benchmarkEncode(b, JSON{}, false)
+}
+
+func BenchmarkFastJSON(b *testing.B) {
+	for _, recycle := range []bool{false, true} {
+		b.Run(fmt.Sprintf("recycle=%t", recycle), func(b *testing.B) {
+			benchmarkEncode(b, FastJSON{}, recycle)
+		})
+	}
+}
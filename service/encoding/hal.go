@@ -0,0 +1,127 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ContentTypeHAL is the media type of HAL documents
+const ContentTypeHAL = "application/hal+json"
+
+// HAL encodes coffees as HAL documents. A coffee links to itself and to its
+// related coffees and embeds its ingredients, which link to their supplier.
+// A list of coffees embeds them under coffees.
+type HAL struct{}
+
+// ContentType implements Encoder
+func (HAL) ContentType() string { return ContentTypeHAL }
+
+// Encodes implements PayloadEncoder
+func (HAL) Encodes(v interface{}) bool {
+	switch v.(type) {
+	case *entities.Coffees, *entities.Coffee:
+		return true
+	}
+	return false
+}
+
+// Encode implements Encoder
+func (HAL) Encode(v interface{}) ([]byte, error) {
+	switch payload := v.(type) {
+	case *entities.Coffees:
+		coffees := make([]halCoffee, 0, len(*payload))
+		for _, c := range *payload {
+			coffees = append(coffees, newHALCoffee(c))
+		}
+		return json.Marshal(halCoffees{Count: len(coffees), Embedded: halEmbeddedCoffees{Coffees: coffees}})
+	case *entities.Coffee:
+		return json.Marshal(newHALCoffee(*payload))
+	}
+
+	return nil, fmt.Errorf("%T has no HAL encoding", v)
+}
+
+// halLink is a link of a HAL resource
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halCoffees is a list of coffees
+type halCoffees struct {
+	Count    int                `json:"count"`
+	Embedded halEmbeddedCoffees `json:"_embedded"`
+}
+
+// halEmbeddedCoffees are the coffees embedded in a list
+type halEmbeddedCoffees struct {
+	Coffees []halCoffee `json:"coffees"`
+}
+
+// halCoffee is a coffee with its links and embedded ingredients
+type halCoffee struct {
+	ID          int                      `json:"id"`
+	Name        string                   `json:"name"`
+	Slug        string                   `json:"slug"`
+	Teaser      string                   `json:"teaser"`
+	Description string                   `json:"description"`
+	Price       float64                  `json:"price"`
+	Image       string                   `json:"image"`
+	Status      string                   `json:"status"`
+	Stats       *entities.CoffeeStats    `json:"stats,omitempty"`
+	Images      *entities.CoffeeImageSet `json:"images,omitempty"`
+	Links       map[string]halLink       `json:"_links"`
+	Embedded    halEmbeddedIngredients   `json:"_embedded"`
+}
+
+// halEmbeddedIngredients are the ingredients embedded in a coffee
+type halEmbeddedIngredients struct {
+	Ingredients []halIngredient `json:"ingredients"`
+}
+
+// halIngredient is an ingredient of a coffee with a link to its supplier
+type halIngredient struct {
+	IngredientID int                `json:"ingredient_id"`
+	Name         string             `json:"name"`
+	Quantity     int                `json:"quantity"`
+	Unit         string             `json:"unit"`
+	Links        map[string]halLink `json:"_links"`
+}
+
+// newHALCoffee returns the HAL resource of a coffee
+func newHALCoffee(c entities.Coffee) halCoffee {
+	self := "/coffees/" + strconv.Itoa(c.ID)
+	coffee := halCoffee{
+		ID:          c.ID,
+		Name:        c.Name,
+		Slug:        c.Slug,
+		Teaser:      c.Teaser,
+		Description: c.Description,
+		Price:       c.Price,
+		Image:       c.Image,
+		Status:      c.Status,
+		Stats:       c.Stats,
+		Images:      c.Images,
+		Links: map[string]halLink{
+			"self":    {Href: self},
+			"related": {Href: self + "/related"},
+		},
+		Embedded: halEmbeddedIngredients{Ingredients: make([]halIngredient, 0, len(c.Ingredients))},
+	}
+
+	for _, i := range c.Ingredients {
+		coffee.Embedded.Ingredients = append(coffee.Embedded.Ingredients, halIngredient{
+			IngredientID: i.IngredientID,
+			Name:         i.Name,
+			Quantity:     i.Quantity,
+			Unit:         i.Unit,
+			Links: map[string]halLink{
+				"supplier": {Href: "/ingredients/" + strconv.Itoa(i.IngredientID) + "/supplier"},
+			},
+		})
+	}
+
+	return coffee
+}
@@ -0,0 +1,47 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestHALLinksCoffeesAndEmbedsTheirIngredients(t *testing.T) {
+	d, err := HAL{}.Encode(&entities.Coffees{
+		{ID: 1, Name: "Latte", Price: 200, Status: "published", Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}}},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"count": 1,
+		"_embedded": {"coffees": [{
+			"id": 1, "name": "Latte", "slug": "", "teaser": "", "description": "", "price": 200, "image": "", "status": "published",
+			"_links": {"self": {"href": "/coffees/1"}, "related": {"href": "/coffees/1/related"}},
+			"_embedded": {"ingredients": [{
+				"ingredient_id": 1, "name": "Espresso", "quantity": 40, "unit": "ml",
+				"_links": {"supplier": {"href": "/ingredients/1/supplier"}}
+			}]}
+		}]}
+	}`, string(d))
+}
+
+func TestHALEncodesASingleCoffee(t *testing.T) {
+	d, err := HAL{}.Encode(&entities.Coffee{ID: 7, Name: "Cold Brew", Stats: &entities.CoffeeStats{Views: 3}})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"id": 7, "name": "Cold Brew", "slug": "", "teaser": "", "description": "", "price": 0, "image": "", "status": "",
+		"stats": {"views": 3, "orders": 0, "score": 0},
+		"_links": {"self": {"href": "/coffees/7"}, "related": {"href": "/coffees/7/related"}},
+		"_embedded": {"ingredients": []}
+	}`, string(d))
+}
+
+func TestHALRejectsUnsupportedPayloads(t *testing.T) {
+	_, err := HAL{}.Encode(map[string]string{})
+	assert.Error(t, err)
+	assert.Equal(t, ContentTypeHAL, Default.Negotiate("application/hal+json", &entities.Coffees{}).ContentType())
+}
@@ -0,0 +1,166 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ContentTypeJSONAPI is the media type of JSON:API documents
+const ContentTypeJSONAPI = "application/vnd.api+json"
+
+// JSONAPI encodes coffees as JSON:API documents. Each coffee is a coffees
+// resource related to its ingredients, which are included once in the
+// document however many coffees use them. The quantity and unit of an
+// ingredient in a coffee are the meta of the relationship.
+type JSONAPI struct{}
+
+// ContentType implements Encoder
+func (JSONAPI) ContentType() string { return ContentTypeJSONAPI }
+
+// Encodes implements PayloadEncoder
+func (JSONAPI) Encodes(v interface{}) bool {
+	switch v.(type) {
+	case *entities.Coffees, *entities.Coffee:
+		return true
+	}
+	return false
+}
+
+// Encode implements Encoder
+func (JSONAPI) Encode(v interface{}) ([]byte, error) {
+	d := &jsonAPIDocument{JSONAPI: jsonAPIVersion{Version: "1.0"}}
+
+	switch payload := v.(type) {
+	case *entities.Coffees:
+		resources := make([]jsonAPIResource, 0, len(*payload))
+		for _, c := range *payload {
+			resources = append(resources, d.coffee(c))
+		}
+		d.Data = resources
+	case *entities.Coffee:
+		d.Data = d.coffee(*payload)
+	default:
+		return nil, fmt.Errorf("%T has no JSON:API encoding", v)
+	}
+
+	return json.Marshal(d)
+}
+
+// jsonAPIDocument is a top level JSON:API document
+type jsonAPIDocument struct {
+	Data     interface{}       `json:"data"`
+	Included []jsonAPIResource `json:"included,omitempty"`
+	JSONAPI  jsonAPIVersion    `json:"jsonapi"`
+
+	// included holds the ingredients already included
+	included map[string]bool
+}
+
+// jsonAPIVersion is the version of JSON:API a document conforms to
+type jsonAPIVersion struct {
+	Version string `json:"version"`
+}
+
+// jsonAPIResource is a resource object
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    interface{}                    `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+	Meta          interface{}                    `json:"meta,omitempty"`
+}
+
+// jsonAPIRelationship is a to-many relationship of a resource
+type jsonAPIRelationship struct {
+	Data []jsonAPIIdentifier `json:"data"`
+}
+
+// jsonAPIIdentifier identifies a related resource
+type jsonAPIIdentifier struct {
+	Type string      `json:"type"`
+	ID   string      `json:"id"`
+	Meta interface{} `json:"meta,omitempty"`
+}
+
+// coffeeAttributes are the attributes of a coffees resource
+type coffeeAttributes struct {
+	Name        string                   `json:"name"`
+	Slug        string                   `json:"slug"`
+	Teaser      string                   `json:"teaser"`
+	Description string                   `json:"description"`
+	Price       float64                  `json:"price"`
+	Image       string                   `json:"image"`
+	Status      string                   `json:"status"`
+	Images      *entities.CoffeeImageSet `json:"images,omitempty"`
+}
+
+// coffeeMeta is the meta of a coffees resource, its popularity when asked for
+type coffeeMeta struct {
+	Stats *entities.CoffeeStats `json:"stats"`
+}
+
+// ingredientAttributes are the attributes of an ingredients resource
+type ingredientAttributes struct {
+	Name string `json:"name"`
+}
+
+// ingredientMeta is the meta of the relationship of a coffee to an
+// ingredient
+type ingredientMeta struct {
+	Quantity int    `json:"quantity"`
+	Unit     string `json:"unit"`
+}
+
+// coffee returns the resource of a coffee, including its ingredients in the
+// document
+func (d *jsonAPIDocument) coffee(c entities.Coffee) jsonAPIResource {
+	id := strconv.Itoa(c.ID)
+	resource := jsonAPIResource{
+		Type: "coffees",
+		ID:   id,
+		Attributes: coffeeAttributes{
+			Name:        c.Name,
+			Slug:        c.Slug,
+			Teaser:      c.Teaser,
+			Description: c.Description,
+			Price:       c.Price,
+			Image:       c.Image,
+			Status:      c.Status,
+			Images:      c.Images,
+		},
+		Links: map[string]string{"self": "/coffees/" + id},
+	}
+	if c.Stats != nil {
+		resource.Meta = coffeeMeta{Stats: c.Stats}
+	}
+
+	ingredients := jsonAPIRelationship{Data: make([]jsonAPIIdentifier, 0, len(c.Ingredients))}
+	for _, i := range c.Ingredients {
+		ingredientID := strconv.Itoa(i.IngredientID)
+		ingredients.Data = append(ingredients.Data, jsonAPIIdentifier{
+			Type: "ingredients",
+			ID:   ingredientID,
+			Meta: ingredientMeta{Quantity: i.Quantity, Unit: i.Unit},
+		})
+
+		if d.included[ingredientID] {
+			continue
+		}
+		if d.included == nil {
+			d.included = map[string]bool{}
+		}
+		d.included[ingredientID] = true
+		d.Included = append(d.Included, jsonAPIResource{
+			Type:       "ingredients",
+			ID:         ingredientID,
+			Attributes: ingredientAttributes{Name: i.Name},
+		})
+	}
+	resource.Relationships = map[string]jsonAPIRelationship{"ingredients": ingredients}
+
+	return resource
+}
@@ -0,0 +1,71 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestJSONAPIRelatesCoffeesToTheirIngredients(t *testing.T) {
+	espresso := entities.CoffeeIngredients{IngredientID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}
+	d, err := JSONAPI{}.Encode(&entities.Coffees{
+		{ID: 1, Name: "Latte", Price: 200, Status: "published", Ingredients: []entities.CoffeeIngredients{espresso, {IngredientID: 2, Name: "Semi Skimmed Milk", Quantity: 200, Unit: "ml"}}},
+		{ID: 2, Name: "Espresso", Price: 150, Ingredients: []entities.CoffeeIngredients{espresso}, Stats: &entities.CoffeeStats{Views: 3}},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"data": [
+			{
+				"type": "coffees",
+				"id": "1",
+				"attributes": {"name": "Latte", "slug": "", "teaser": "", "description": "", "price": 200, "image": "", "status": "published"},
+				"relationships": {"ingredients": {"data": [
+					{"type": "ingredients", "id": "1", "meta": {"quantity": 40, "unit": "ml"}},
+					{"type": "ingredients", "id": "2", "meta": {"quantity": 200, "unit": "ml"}}
+				]}},
+				"links": {"self": "/coffees/1"}
+			},
+			{
+				"type": "coffees",
+				"id": "2",
+				"attributes": {"name": "Espresso", "slug": "", "teaser": "", "description": "", "price": 150, "image": "", "status": ""},
+				"relationships": {"ingredients": {"data": [
+					{"type": "ingredients", "id": "1", "meta": {"quantity": 40, "unit": "ml"}}
+				]}},
+				"links": {"self": "/coffees/2"},
+				"meta": {"stats": {"views": 3, "orders": 0, "score": 0}}
+			}
+		],
+		"included": [
+			{"type": "ingredients", "id": "1", "attributes": {"name": "Espresso"}},
+			{"type": "ingredients", "id": "2", "attributes": {"name": "Semi Skimmed Milk"}}
+		],
+		"jsonapi": {"version": "1.0"}
+	}`, string(d))
+}
+
+func TestJSONAPIEncodesASingleCoffee(t *testing.T) {
+	d, err := JSONAPI{}.Encode(&entities.Coffee{ID: 7, Name: "Cold Brew"})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"data": {
+			"type": "coffees",
+			"id": "7",
+			"attributes": {"name": "Cold Brew", "slug": "", "teaser": "", "description": "", "price": 0, "image": "", "status": ""},
+			"relationships": {"ingredients": {"data": []}},
+			"links": {"self": "/coffees/7"}
+		},
+		"jsonapi": {"version": "1.0"}
+	}`, string(d))
+}
+
+func TestJSONAPIRejectsUnsupportedPayloads(t *testing.T) {
+	_, err := JSONAPI{}.Encode(map[string]string{})
+	assert.Error(t, err)
+	assert.Equal(t, ContentTypeJSONAPI, Default.Negotiate("application/vnd.api+json", &entities.Coffees{}).ContentType())
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// writeFailed answers a write failing with err because of the request rather
+// than the service, with 400 for an invalid entity and 409 for a key taken by
+// an existing row, reporting whether it did
+func writeFailed(rw http.ResponseWriter, err error) bool {
+	var invalid *entities.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(rw, invalid.Error(), http.StatusBadRequest)
+	case errors.Is(err, data.ErrConflict):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
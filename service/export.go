@@ -0,0 +1,128 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// maxSnapshotSize is the largest snapshot accepted for import
+const maxSnapshotSize = 32 << 20
+
+// exportETagLength is the number of hex digits of the snapshot hash in the
+// ETag of exports
+const exportETagLength = 32
+
+// defaultTenant is the tenant of requests without a tenant header
+const defaultTenant = "default"
+
+// ExportService is an HTTP Handler exporting the catalogue to a snapshot on
+// GET and importing a snapshot on POST, to move a tenant between backends.
+// The tenant is named by the X-Tenant-ID header, a snapshot is only imported
+// for the tenant it was exported for.
+type ExportService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewExport creates a new Export handler
+func NewExport(repository data.Repository, l hclog.Logger) *ExportService {
+	return &ExportService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin export and import routes
+func (s *ExportService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Export", "method", r.Method)
+
+	tenant := r.Header.Get(middleware.TenantHeader)
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+
+	var result interface{}
+	if r.Method == http.MethodPost {
+		snapshot := &data.Snapshot{}
+		if err := newDecoder(r, http.MaxBytesReader(rw, r.Body, maxSnapshotSize)).Decode(snapshot); err != nil {
+			invalidPayload(rw, "Invalid snapshot", err)
+			return
+		}
+		if snapshot.Tenant != tenant {
+			http.Error(rw, fmt.Sprintf("Snapshot of tenant %q can not be imported for tenant %q", snapshot.Tenant, tenant), http.StatusConflict)
+			return
+		}
+
+		imported, err := data.Import(r.Context(), s.repository, snapshot)
+		if writeFailed(rw, err) {
+			return
+		}
+		if err != nil {
+			s.logger.Error("Unable to import snapshot", "tenant", tenant, "error", err)
+			http.Error(rw, "Unable to import snapshot", http.StatusInternalServerError)
+			return
+		}
+		s.logger.Info("Imported snapshot", "tenant", tenant, "coffees_created", imported.CoffeesCreated, "coffees_updated", imported.CoffeesUpdated)
+		result = imported
+	} else {
+		s.download(rw, r, tenant)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("Unable to encode snapshot", "error", err)
+		http.Error(rw, "Unable to encode snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// download serves a snapshot of the tenant. The snapshot is written to a
+// temporary file and served with http.ServeContent, which answers Range and
+// If-Range requests, so an interrupted download resumes where it stopped. The
+// ETag is a hash of the snapshot, the same catalogue always gets the same one
+// and a resumed download restarts from scratch when the catalogue changed.
+func (s *ExportService) download(rw http.ResponseWriter, r *http.Request, tenant string) {
+	snapshot, err := data.Export(r.Context(), s.repository, tenant)
+	if err != nil {
+		s.logger.Error("Unable to export snapshot", "tenant", tenant, "error", err)
+		http.Error(rw, "Unable to export snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	file, err := ioutil.TempFile("", "coffee-export-*.json")
+	if err != nil {
+		s.logger.Error("Unable to create snapshot file", "error", err)
+		http.Error(rw, "Unable to export snapshot", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	if err := json.NewEncoder(io.MultiWriter(file, hash)).Encode(snapshot); err != nil {
+		s.logger.Error("Unable to encode snapshot", "error", err)
+		http.Error(rw, "Unable to encode snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": tenant + ".json"}))
+	rw.Header().Set("ETag", `"`+hex.EncodeToString(hash.Sum(nil))[:exportETagLength]+`"`)
+	// revalidated on every download, and never stored by the response cache
+	rw.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(rw, r, "", time.Time{}, file)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func setupExportHandler(t *testing.T) *ExportService {
+	l := hclog.NewNullLogger()
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	return NewExport(repository, l)
+}
+
+func TestExportImportsTheSnapshotOfItsTenant(t *testing.T) {
+	source, target := setupExportHandler(t), setupExportHandler(t)
+
+	r := httptest.NewRequest("GET", "/admin/export", nil)
+	r.Header.Set(middleware.TenantHeader, "hashicups")
+	rw := httptest.NewRecorder()
+	source.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	snapshot := &data.Snapshot{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), snapshot))
+	assert.Equal(t, "hashicups", snapshot.Tenant)
+	assert.NotEmpty(t, snapshot.Coffees)
+
+	r = httptest.NewRequest("POST", "/admin/import", bytes.NewReader(rw.Body.Bytes()))
+	r.Header.Set(middleware.TenantHeader, "hashicups")
+	rw = httptest.NewRecorder()
+	target.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	result := data.ImportResult{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &result))
+	assert.Equal(t, len(snapshot.Coffees), result.CoffeesUpdated)
+}
+
+func TestExportRejectsSnapshotsOfOtherTenants(t *testing.T) {
+	h := setupExportHandler(t)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/import", bytes.NewReader([]byte(`{"tenant":"hashicups"}`))))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/import", bytes.NewReader([]byte(`{`))))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func exportRequest(h *ExportService, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/admin/export", nil)
+	for n := 0; n+1 < len(headers); n += 2 {
+		r.Header.Set(headers[n], headers[n+1])
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestExportDownloadsResume(t *testing.T) {
+	h := setupExportHandler(t)
+
+	full := exportRequest(h)
+	require.Equal(t, http.StatusOK, full.Code)
+	etag := full.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "bytes", full.Header().Get("Accept-Ranges"))
+	assert.Equal(t, `attachment; filename=default.json`, full.Header().Get("Content-Disposition"))
+	// the same catalogue is always exported with the same ETag
+	assert.Equal(t, etag, exportRequest(h).Header().Get("ETag"))
+
+	rest := exportRequest(h, "Range", "bytes=100-", "If-Range", etag)
+	require.Equal(t, http.StatusPartialContent, rest.Code)
+	assert.Equal(t, full.Body.Bytes()[100:], rest.Body.Bytes())
+
+	assert.Equal(t, http.StatusNotModified, exportRequest(h, "If-None-Match", etag).Code)
+
+	// a changed catalogue is downloaded again from the start
+	stale := exportRequest(h, "Range", "bytes=100-", "If-Range", `"0123456789abcdef0123456789abcdef"`)
+	assert.Equal(t, http.StatusOK, stale.Code)
+	assert.Equal(t, full.Body.Bytes(), stale.Body.Bytes())
+}
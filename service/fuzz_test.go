@@ -0,0 +1,96 @@
+//go:build go1.18
+// +build go1.18
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	v1 "github.com/hashicorp-demoapp/coffee-service/service/v1"
+)
+
+// fuzzRouter routes every handler which parses query parameters, backed by
+// the in-memory repository
+func fuzzRouter(f *testing.F) *mux.Router {
+	l := hclog.NewNullLogger()
+
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	if err != nil {
+		f.Fatal(err)
+	}
+	tracker, err := popularity.NewTracker("", l)
+	if err != nil {
+		f.Fatal(err)
+	}
+	index, err := NewSearchIndex(repository)
+	if err != nil {
+		f.Fatal(err)
+	}
+	trie, err := NewSuggestTrie(repository, tracker)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/coffees", v1.NewCoffeeService(repository, tracker, l))
+	router.Handle("/coffees/trending", NewTrending(repository, tracker, l))
+	router.Handle("/coffees/suggest", NewSuggest(trie, l))
+	router.Handle("/coffees/{id:[0-9]+}", NewDetail(repository, tracker, l))
+	router.Handle("/coffees/{id:[0-9]+}/related", NewRelated(repository, l))
+	router.Handle("/search", NewSearch(index, l))
+
+	return router
+}
+
+func FuzzQueryParameters(f *testing.F) {
+	router := fuzzRouter(f)
+	paths := []string{
+		"/coffees",
+		"/coffees/trending",
+		"/coffees/suggest",
+		"/coffees/1",
+		"/coffees/1/related",
+		"/search",
+	}
+
+	for _, seed := range []string{
+		"filter=price<300 AND name~latte",
+		"filter=%28",
+		"include=stats",
+		"limit=3",
+		"limit=-1",
+		"limit=99999999999999999999",
+		"q=va&limit=2",
+		"q=%E2%9C%93",
+		"q&q=&limit=",
+	} {
+		f.Add(seed, "application/json")
+	}
+	f.Add("", "application/x-protobuf;q=0.5, application/msgpack")
+	f.Add("", "application/xml")
+	f.Add("", "application/vnd.api+json")
+	f.Add("", "application/hal+json")
+
+	f.Fuzz(func(t *testing.T, rawQuery string, accept string) {
+		for _, path := range paths {
+			r := httptest.NewRequest("GET", path, nil)
+			r.URL.RawQuery = rawQuery
+			r.Header.Set("Accept", accept)
+			rw := httptest.NewRecorder()
+
+			router.ServeHTTP(rw, r)
+
+			if rw.Code >= http.StatusInternalServerError {
+				t.Fatalf("GET %s?%s returned %d: %s", path, rawQuery, rw.Code, rw.Body.String())
+			}
+		}
+	})
+}
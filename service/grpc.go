@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// GRPCServiceName is the service name reported by the gRPC health service
+const GRPCServiceName = "coffee-service"
+
+// readinessInterval is how often the repository readiness is re-evaluated
+const readinessInterval = 5 * time.Second
+
+// NewGRPCServer creates a gRPC server exposing the CoffeeService of
+// proto/coffee.proto, grpc.health.v1.Health and server reflection. The health
+// status follows the readiness of the repository until the returned stop
+// function is called.
+func NewGRPCServer(repository data.Repository, l hclog.Logger) (*grpc.Server, func()) {
+	server := grpc.NewServer(grpc.CustomCodec(grpcCodec{}))
+	server.RegisterService(&coffeeServiceDesc, &GRPCCoffeeService{repository, l})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	// the first status is known before the server is returned, so clients
+	// never see the SERVING default of health.NewServer
+	updateReadiness(repository, healthServer, l)
+	done := make(chan struct{})
+	go watchReadiness(repository, healthServer, l, done)
+
+	stop := func() {
+		close(done)
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}
+
+	return server, stop
+}
+
+// watchReadiness polls the repository every readinessInterval until done is
+// closed
+func watchReadiness(repository data.Repository, healthServer *health.Server, l hclog.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(readinessInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			updateReadiness(repository, healthServer, l)
+		}
+	}
+}
+
+// updateReadiness mirrors the readiness of the repository into the health
+// server for both the overall server and the coffee-service. The check is
+// bounded by readinessTimeout, a hung database reports NOT_SERVING.
+func updateReadiness(repository data.Repository, healthServer *health.Server, l hclog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if ok, err := repository.IsConnected(ctx); !ok {
+		l.Error("Repository is not ready", "error", err)
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	healthServer.SetServingStatus("", status)
+	healthServer.SetServingStatus(GRPCServiceName, status)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+)
+
+// CoffeeServiceName is the full name of the CoffeeService of proto/coffee.proto
+const CoffeeServiceName = "coffeeservice.CoffeeService"
+
+// ListCoffeesRequest is the ListCoffeesRequest message of proto/coffee.proto
+type ListCoffeesRequest struct {
+	Filter         string
+	AcceptLanguage string
+}
+
+// GetCoffeeRequest is the GetCoffeeRequest message of proto/coffee.proto
+type GetCoffeeRequest struct {
+	ID             string
+	AcceptLanguage string
+}
+
+// ToProto encodes the request
+func (m *ListCoffeesRequest) ToProto() ([]byte, error) {
+	return appendStrings(nil, m.Filter, m.AcceptLanguage), nil
+}
+
+// FromProto decodes the request
+func (m *ListCoffeesRequest) FromProto(b []byte) error {
+	return consumeStrings(b, &m.Filter, &m.AcceptLanguage)
+}
+
+// ToProto encodes the request
+func (m *GetCoffeeRequest) ToProto() ([]byte, error) {
+	return appendStrings(nil, m.ID, m.AcceptLanguage), nil
+}
+
+// FromProto decodes the request
+func (m *GetCoffeeRequest) FromProto(b []byte) error {
+	return consumeStrings(b, &m.ID, &m.AcceptLanguage)
+}
+
+// appendStrings encodes fields as the string fields numbered from 1
+func appendStrings(b []byte, fields ...string) []byte {
+	for n, v := range fields {
+		if v == "" {
+			continue
+		}
+		b = protowire.AppendTag(b, protowire.Number(n+1), protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	}
+	return b
+}
+
+// consumeStrings decodes the string fields numbered from 1 into fields,
+// skipping unknown fields
+func consumeStrings(b []byte, fields ...*string) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ == protowire.BytesType && num >= 1 && int(num) <= len(fields) {
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			*fields[num-1] = v
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// wireMessage is a message encoded by hand rather than generated by protoc
+type wireMessage interface {
+	ToProto() ([]byte, error)
+	FromProto([]byte) error
+}
+
+// grpcCodec encodes the messages of CoffeeService by hand, and every other
+// message, like those of the health service, with the proto codec of gRPC
+type grpcCodec struct{}
+
+var protoCodec = encoding.GetCodec("proto")
+
+// Marshal encodes v
+func (grpcCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(wireMessage); ok {
+		return m.ToProto()
+	}
+	return protoCodec.Marshal(v)
+}
+
+// Unmarshal decodes data into v
+func (grpcCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(wireMessage); ok {
+		return m.FromProto(data)
+	}
+	return protoCodec.Unmarshal(data, v)
+}
+
+// Name is the content subtype of the codec, the messages are protobuf
+func (grpcCodec) Name() string {
+	return protoCodec.Name()
+}
+
+// String is Name, for grpc.CustomCodec
+func (c grpcCodec) String() string {
+	return c.Name()
+}
+
+// coffeeServer is the CoffeeService of proto/coffee.proto
+type coffeeServer interface {
+	ListCoffees(context.Context, *ListCoffeesRequest) (*entities.Coffees, error)
+	GetCoffee(context.Context, *GetCoffeeRequest) (*entities.Coffee, error)
+}
+
+// GRPCCoffeeService serves CoffeeService from the repository, the same way
+// the REST handlers serve GET /coffees and GET /coffees/{id}
+type GRPCCoffeeService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// ListCoffees returns the coffees matching the filter of the request,
+// translated for its languages
+func (s *GRPCCoffeeService) ListCoffees(ctx context.Context, req *ListCoffeesRequest) (*entities.Coffees, error) {
+	var coffees entities.Coffees
+	var err error
+	if req.Filter != "" {
+		expr, parseErr := filter.Parse(req.Filter)
+		if parseErr != nil {
+			return nil, status.Error(codes.InvalidArgument, parseErr.Error())
+		}
+		coffees, err = s.repository.FindWhere(ctx, expr)
+	} else {
+		coffees, err = s.repository.Find(ctx)
+	}
+	if err == nil {
+		err = data.Localize(ctx, s.repository, coffees, data.LocaleChain(req.AcceptLanguage))
+	}
+	if err != nil {
+		s.logger.Error("Unable to get coffees from database", "error", err)
+		return nil, status.Error(codes.Internal, "Unable to get coffees from database")
+	}
+	return &coffees, nil
+}
+
+// GetCoffee returns the coffee with the ID or slug of the request
+func (s *GRPCCoffeeService) GetCoffee(ctx context.Context, req *GetCoffeeRequest) (*entities.Coffee, error) {
+	coffee, err := findCoffee(ctx, s.repository, req.ID, data.LocaleChain(req.AcceptLanguage))
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "Coffee not found")
+	}
+	if err != nil {
+		s.logger.Error("Unable to get coffee from database", "error", err)
+		return nil, status.Error(codes.Internal, "Unable to get coffee from database")
+	}
+	return coffee, nil
+}
+
+// coffeeServiceDesc describes CoffeeService as protoc-gen-go-grpc would
+var coffeeServiceDesc = grpc.ServiceDesc{
+	ServiceName: CoffeeServiceName,
+	HandlerType: (*coffeeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCoffees",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &ListCoffeesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(coffeeServer).ListCoffees(ctx, req.(*ListCoffeesRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + CoffeeServiceName + "/ListCoffees"}, handler)
+			},
+		},
+		{
+			MethodName: "GetCoffee",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &GetCoffeeRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(coffeeServer).GetCoffee(ctx, req.(*GetCoffeeRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + CoffeeServiceName + "/GetCoffee"}, handler)
+			},
+		},
+	},
+	Metadata: "proto/coffee.proto",
+}
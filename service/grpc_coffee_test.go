@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+	v1 "github.com/hashicorp-demoapp/coffee-service/service/v1"
+)
+
+func setupGRPCCoffeeService(t *testing.T) (data.Repository, *grpc.ClientConn) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, data.SetTranslation(ctx, repository, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: data.TranslationName, Value: "Latte épicé Packer"}))
+	require.NoError(t, data.SetImage(ctx, repository, &entities.CoffeeImage{CoffeeID: 1, Size: data.ImageThumb, URL: "/images/1/thumb.png", Width: 200, Height: 200}))
+
+	server, stop := NewGRPCServer(repository, hclog.NewNullLogger())
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcCodec{})),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		stop()
+	})
+
+	return repository, conn
+}
+
+// jsonBody encodes v as the REST handlers do
+func jsonBody(t *testing.T, v interface{}) string {
+	body, err := encoding.Default.Negotiate("application/json", v).Encode(v)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestGRPCListCoffeesMatchesREST(t *testing.T) {
+	repository, conn := setupGRPCCoffeeService(t)
+
+	for _, query := range []struct{ filter, language string }{
+		{},
+		{filter: "price > 100", language: "fr"},
+	} {
+		coffees := entities.Coffees{}
+		err := conn.Invoke(context.Background(), "/"+CoffeeServiceName+"/ListCoffees", &ListCoffeesRequest{Filter: query.filter, AcceptLanguage: query.language}, &coffees)
+		require.NoError(t, err)
+
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		if query.filter != "" {
+			r.URL.RawQuery = "filter=" + query.filter
+		}
+		r.Header.Set("Accept-Language", query.language)
+		rw := httptest.NewRecorder()
+		v1.NewCoffeeService(repository, nil, hclog.NewNullLogger()).ServeHTTP(rw, r)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.NotEmpty(t, coffees)
+		assert.Equal(t, rw.Body.String(), jsonBody(t, &coffees), query)
+	}
+}
+
+func TestGRPCGetCoffeeMatchesREST(t *testing.T) {
+	repository, conn := setupGRPCCoffeeService(t)
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2"} {
+		coffee := entities.Coffee{}
+		err := conn.Invoke(context.Background(), "/"+CoffeeServiceName+"/GetCoffee", &GetCoffeeRequest{ID: id, AcceptLanguage: "fr"}, &coffee)
+		require.NoError(t, err)
+
+		r := detailRequest(id, "")
+		r.Header.Set("Accept-Language", "fr")
+		rw := httptest.NewRecorder()
+		NewDetail(repository, tracker, hclog.NewNullLogger()).ServeHTTP(rw, r)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, rw.Body.String(), jsonBody(t, &coffee), id)
+	}
+
+	coffee := entities.Coffee{}
+	require.NoError(t, conn.Invoke(context.Background(), "/"+CoffeeServiceName+"/GetCoffee", &GetCoffeeRequest{ID: "1", AcceptLanguage: "fr"}, &coffee))
+	assert.Equal(t, "Latte épicé Packer", coffee.Name)
+	assert.Equal(t, "/images/1/thumb.png", coffee.Images.Thumb)
+}
+
+func TestGRPCCoffeeServiceErrors(t *testing.T) {
+	_, conn := setupGRPCCoffeeService(t)
+
+	err := conn.Invoke(context.Background(), "/"+CoffeeServiceName+"/GetCoffee", &GetCoffeeRequest{ID: "42"}, &entities.Coffee{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	err = conn.Invoke(context.Background(), "/"+CoffeeServiceName+"/ListCoffees", &ListCoffeesRequest{Filter: "price >"}, &entities.Coffees{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/mocks"
+)
+
+func setupGRPCServer(t *testing.T, connected bool, err error) (healthpb.HealthClient, func()) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(connected, err)
+	return serveGRPC(t, c)
+}
+
+func serveGRPC(t *testing.T, repository data.Repository) (healthpb.HealthClient, func()) {
+	server, stop := NewGRPCServer(repository, hclog.NewNullLogger())
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+
+	conn, dialErr := grpc.DialContext(
+		context.Background(),
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, dialErr)
+
+	return healthpb.NewHealthClient(conn), func() {
+		conn.Close()
+		stop()
+	}
+}
+
+func TestGRPCHealthServingWhenRepositoryConnected(t *testing.T) {
+	client, stop := setupGRPCServer(t, true, nil)
+	defer stop()
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: GRPCServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestGRPCHealthNotServingWhenRepositoryDisconnected(t *testing.T) {
+	client, stop := setupGRPCServer(t, false, errors.New("connection refused"))
+	defer stop()
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+func TestGRPCHealthNotServingWhenRepositoryHangs(t *testing.T) {
+	client, stop := serveGRPC(t, &mocks.Repository{IsConnectedFunc: func(ctx context.Context) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}})
+	defer stop()
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: GRPCServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
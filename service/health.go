@@ -1,10 +1,16 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
 )
 
 // TODO: Move this to hckit.
@@ -23,3 +29,187 @@ func NewHealth(l hclog.Logger) *HealthService {
 func (h *HealthService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(rw, "%s", "ok")
 }
+
+// Liveness reports whether the service is still making progress
+type Liveness interface {
+	Live() bool
+}
+
+// LivenessService is an HTTP Handler for liveness probes, failing once the
+// Liveness reports the service as stuck
+type LivenessService struct {
+	liveness Liveness
+	logger   hclog.Logger
+}
+
+// NewLiveness creates a new Liveness handler
+func NewLiveness(liveness Liveness, l hclog.Logger) *LivenessService {
+	return &LivenessService{liveness, l}
+}
+
+// ServeHTTP implements the handler interface
+func (h *LivenessService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !h.liveness.Live() {
+		h.logger.Error("Liveness probe failed")
+		http.Error(rw, "stuck", http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintf(rw, "%s", "ok")
+}
+
+// Draining reports whether the service is draining its requests before it
+// stops
+type Draining interface {
+	Draining() bool
+}
+
+// readinessTimeout bounds every dependency check of the readiness probe, below
+// the one second the kubelet waits for a probe by default
+const readinessTimeout = 800 * time.Millisecond
+
+// The statuses of the readiness probe and its dependencies
+const (
+	// ReadyStatus is a ready service whose dependencies all passed
+	ReadyStatus = "ready"
+	// DegradedStatus is a ready service with a failed dependency which is not
+	// critical
+	DegradedStatus = "degraded"
+	// NotReadyStatus is a service with a failed critical dependency
+	NotReadyStatus = "not_ready"
+	// DrainingStatus is a service draining its requests
+	DrainingStatus = "draining"
+
+	// PassStatus is a dependency check which succeeded
+	PassStatus = "pass"
+	// FailStatus is a dependency check which failed or timed out
+	FailStatus = "fail"
+)
+
+// Dependency is a service the coffee-service depends on, checked by every
+// readiness probe
+type Dependency struct {
+	Name string
+	// Critical dependencies make the service not ready when they fail, the
+	// others only degrade it
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// DependencyStatus is the outcome of the check of a dependency
+type DependencyStatus struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Critical   bool    `json:"critical"`
+	Message    string  `json:"message,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Readiness is the response of the readiness probe
+type Readiness struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// ReadinessService is an HTTP Handler for readiness probes, checking the
+// repository and the other dependencies concurrently and responding with the
+// status of each. It fails while the repository is not connected, e.g. while
+// it re-establishes its connections after a database failover, or another
+// critical dependency fails, and once the service is draining.
+type ReadinessService struct {
+	dependencies []Dependency
+	draining     Draining
+	logger       hclog.Logger
+}
+
+// NewReadiness creates a new Readiness handler checking the repository as the
+// critical database dependency, followed by dependencies
+func NewReadiness(repository data.Repository, draining Draining, dependencies []Dependency, l hclog.Logger) *ReadinessService {
+	database := Dependency{Name: "database", Critical: true, Check: func(ctx context.Context) error {
+		ok, err := repository.IsConnected(ctx)
+		if !ok && err == nil {
+			err = fmt.Errorf("not connected")
+		}
+		return err
+	}}
+	return &ReadinessService{append([]Dependency{database}, dependencies...), draining, l}
+}
+
+// ServeHTTP implements the handler interface
+func (h *ReadinessService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	readiness := Readiness{Status: DrainingStatus, Dependencies: []DependencyStatus{}}
+	if !h.draining.Draining() {
+		readiness = h.check(r.Context())
+	}
+
+	status := http.StatusOK
+	if readiness.Status == NotReadyStatus || readiness.Status == DrainingStatus {
+		status = http.StatusServiceUnavailable
+	}
+	if readiness.Status != ReadyStatus && readiness.Status != DrainingStatus {
+		h.logger.Error("Readiness probe failed", "status", readiness.Status, "dependencies", readiness.Dependencies)
+	}
+
+	d, err := json.Marshal(readiness)
+	if err != nil {
+		h.logger.Error("Unable to encode readiness", "error", err)
+		http.Error(rw, "Unable to encode readiness", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	rw.Write(d)
+}
+
+// check runs the checks of every dependency concurrently, each bounded by
+// readinessTimeout
+func (h *ReadinessService) check(ctx context.Context) Readiness {
+	readiness := Readiness{Status: ReadyStatus, Dependencies: make([]DependencyStatus, len(h.dependencies))}
+
+	var wg sync.WaitGroup
+	for n, dependency := range h.dependencies {
+		wg.Add(1)
+		go func(n int, dependency Dependency) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+
+			// checks ignoring their context still only delay the probe by
+			// readinessTimeout
+			start := time.Now()
+			result := make(chan error, 1)
+			go func() { result <- dependency.Check(ctx) }()
+			var err error
+			select {
+			case err = <-result:
+			case <-ctx.Done():
+				err = fmt.Errorf("timed out after %s", readinessTimeout)
+			}
+			status := DependencyStatus{
+				Name:       dependency.Name,
+				Status:     PassStatus,
+				Critical:   dependency.Critical,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				status.Status = FailStatus
+				status.Message = err.Error()
+			}
+			readiness.Dependencies[n] = status
+		}(n, dependency)
+	}
+	wg.Wait()
+
+	for _, dependency := range readiness.Dependencies {
+		switch {
+		case dependency.Status == PassStatus:
+		case dependency.Critical:
+			readiness.Status = NotReadyStatus
+		case readiness.Status == ReadyStatus:
+			readiness.Status = DegradedStatus
+		}
+	}
+	return readiness
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func serveReadiness(t *testing.T, repository data.Repository, draining Draining, dependencies ...Dependency) (int, Readiness) {
+	rw := httptest.NewRecorder()
+	NewReadiness(repository, draining, dependencies, hclog.NewNullLogger()).ServeHTTP(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	readiness := Readiness{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &readiness))
+	return rw.Code, readiness
+}
+
+func TestReadinessIsOKWhenRepositoryConnected(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(true, nil)
+
+	status, readiness := serveReadiness(t, c, middleware.NewDrainer(metrics.FanoutSink{}))
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ReadyStatus, readiness.Status)
+	require.Len(t, readiness.Dependencies, 1)
+	assert.Equal(t, "database", readiness.Dependencies[0].Name)
+	assert.Equal(t, PassStatus, readiness.Dependencies[0].Status)
+	assert.True(t, readiness.Dependencies[0].Critical)
+}
+
+func TestReadinessFailsWhileRepositoryReconnects(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(false, data.ErrReconnecting)
+
+	status, readiness := serveReadiness(t, c, middleware.NewDrainer(metrics.FanoutSink{}))
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, NotReadyStatus, readiness.Status)
+	assert.Equal(t, FailStatus, readiness.Dependencies[0].Status)
+	assert.Equal(t, data.ErrReconnecting.Error(), readiness.Dependencies[0].Message)
+}
+
+func TestReadinessFailsOnceDraining(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(true, nil)
+	drainer := middleware.NewDrainer(metrics.FanoutSink{})
+	drainer.Drain()
+
+	status, readiness := serveReadiness(t, c, drainer)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, DrainingStatus, readiness.Status)
+	assert.Empty(t, readiness.Dependencies)
+}
+
+func TestReadinessIsDegradedByFailedDependencies(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(true, nil)
+	vault := Dependency{Name: "vault", Check: func(ctx context.Context) error {
+		return errors.New("vault is sealed")
+	}}
+	consul := Dependency{Name: "consul", Check: func(ctx context.Context) error { return nil }}
+
+	status, readiness := serveReadiness(t, c, middleware.NewDrainer(metrics.FanoutSink{}), vault, consul)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, DegradedStatus, readiness.Status)
+	require.Len(t, readiness.Dependencies, 3)
+	assert.Equal(t, DependencyStatus{Name: "vault", Status: FailStatus, Message: "vault is sealed"}, withoutDuration(readiness.Dependencies[1]))
+	assert.Equal(t, DependencyStatus{Name: "consul", Status: PassStatus}, withoutDuration(readiness.Dependencies[2]))
+}
+
+func TestReadinessChecksDependenciesConcurrentlyWithATimeout(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("IsConnected").Return(true, nil)
+	block := make(chan struct{})
+	defer close(block)
+	stuck := Dependency{Name: "consul", Critical: true, Check: func(ctx context.Context) error {
+		// ignores its context
+		<-block
+		return nil
+	}}
+	slow := Dependency{Name: "vault", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	start := time.Now()
+	status, readiness := serveReadiness(t, c, middleware.NewDrainer(metrics.FanoutSink{}), stuck, slow)
+
+	assert.Less(t, int64(time.Since(start)), int64(2*readinessTimeout))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, NotReadyStatus, readiness.Status)
+	assert.Equal(t, "timed out after 800ms", readiness.Dependencies[1].Message)
+	assert.Equal(t, FailStatus, readiness.Dependencies[2].Status)
+}
+
+// withoutDuration clears the duration of a status so it can be compared
+func withoutDuration(status DependencyStatus) DependencyStatus {
+	status.DurationMs = 0
+	return status
+}
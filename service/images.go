@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/images"
+)
+
+// maxImageBytes is the largest image upload
+const maxImageBytes = 5 << 20
+
+// ImageVariants are the sizes uploaded images are resized to, the upload
+// itself is the full size
+var ImageVariants = []images.Variant{
+	{Size: data.ImageThumb, Width: 160},
+	{Size: data.ImageMedium, Width: 480},
+}
+
+// ImagesService is an HTTP Handler managing the image of a coffee. PUT
+// uploads a PNG or JPEG image as the full size and queues its resizing into
+// the ImageVariants, answering 202 with the sizes available so far. GET lists
+// the sizes of the image.
+type ImagesService struct {
+	repository data.Repository
+	store      *images.Store
+	resizer    *images.Resizer
+	logger     hclog.Logger
+}
+
+// NewImages creates a new Images handler
+func NewImages(repository data.Repository, store *images.Store, resizer *images.Resizer, l hclog.Logger) *ImagesService {
+	return &ImagesService{repository, store, resizer, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coffee image route
+func (s *ImagesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Images", "method", r.Method)
+
+	coffeeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid coffee id", http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodPut:
+		upload, readErr := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxImageBytes))
+		if readErr != nil {
+			http.Error(rw, "Images must be at most "+strconv.Itoa(maxImageBytes>>20)+" MiB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		img, format, decodeErr := images.Decode(upload)
+		if decodeErr == images.ErrUnsupportedFormat {
+			http.Error(rw, decodeErr.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if decodeErr != nil {
+			http.Error(rw, "Invalid image: "+decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var full *entities.CoffeeImage
+		if full, err = s.upload(r.Context(), coffeeID, upload, img, format); err == nil {
+			result, status = data.ImageSet(entities.CoffeeImages{*full}), http.StatusAccepted
+		}
+	default:
+		if _, err = s.repository.FindByID(r.Context(), coffeeID); err == nil {
+			result, err = data.FindImages(r.Context(), s.repository, []int{coffeeID})
+		}
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+		return
+	case data.ErrImagesUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	case images.ErrQueueFull:
+		http.Error(rw, "Too many images waiting to be resized", http.StatusServiceUnavailable)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to manage images", "method", r.Method, "coffee_id", coffeeID, "error", err)
+		http.Error(rw, "Unable to manage images", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("Unable to encode images", "error", err)
+		http.Error(rw, "Unable to encode images", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
+
+// upload stores the uploaded image as the full size of the image of a coffee
+// and queues its resizing. Files are named after a hash of their content, so
+// a new upload gets new URLs which caches have never seen.
+func (s *ImagesService) upload(ctx context.Context, coffeeID int, upload []byte, img image.Image, format string) (*entities.CoffeeImage, error) {
+	if _, err := s.repository.FindByID(ctx, coffeeID); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(upload)
+	name := "coffees/" + strconv.Itoa(coffeeID) + "/" + hex.EncodeToString(sum[:6])
+	url, err := s.store.Put(name+"-"+data.ImageFull+images.Extension(format), upload)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	full := &entities.CoffeeImage{CoffeeID: coffeeID, Size: data.ImageFull, URL: url, Width: bounds.Dx(), Height: bounds.Dy()}
+	if err := data.SetImage(ctx, s.repository, full); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Image uploaded", "coffee_id", coffeeID, "url", url, "width", full.Width, "height", full.Height)
+
+	err = s.resizer.Enqueue(images.Job{Name: name, Image: img, Format: format, Done: func(variants []images.Image) error {
+		return s.setVariants(coffeeID, url, variants)
+	}})
+	return full, err
+}
+
+// setVariants stores the variants resized from the full image at url, unless
+// a newer upload replaced it meanwhile
+func (s *ImagesService) setVariants(coffeeID int, url string, variants []images.Image) error {
+	ctx := context.Background()
+	current, err := data.FindImages(ctx, s.repository, []int{coffeeID})
+	if err != nil {
+		return err
+	}
+	replaced := true
+	for _, stored := range current {
+		if stored.Size == data.ImageFull && stored.URL == url {
+			replaced = false
+		}
+	}
+	if replaced {
+		s.logger.Debug("Image replaced before it was resized", "coffee_id", coffeeID, "url", url)
+		return nil
+	}
+
+	for _, variant := range variants {
+		resized := &entities.CoffeeImage{CoffeeID: coffeeID, Size: variant.Size, URL: variant.URL, Width: variant.Width, Height: variant.Height}
+		if err := data.SetImage(ctx, s.repository, resized); err != nil {
+			return err
+		}
+	}
+	s.logger.Info("Image variants stored", "coffee_id", coffeeID, "variants", len(variants))
+	return nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/images"
+)
+
+func setupImagesHandler(t *testing.T) (*ImagesService, *images.Resizer, data.Repository) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	store, err := images.NewStore(t.TempDir(), "/images/")
+	require.NoError(t, err)
+	resizer := images.NewResizer(images.Options{Store: store, Variants: ImageVariants, Logger: hclog.NewNullLogger()})
+
+	return NewImages(repository, store, resizer, hclog.NewNullLogger()), resizer, repository
+}
+
+func imagesRequest(method, id string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, "/admin/coffees/"+id+"/image", bytes.NewReader(body))
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	b := &bytes.Buffer{}
+	require.NoError(t, png.Encode(b, image.NewGray(image.Rect(0, 0, width, height))))
+	return b.Bytes()
+}
+
+// resizeQueued resizes the uploads queued so far
+func resizeQueued(resizer *images.Resizer) {
+	done := make(chan struct{})
+	close(done)
+	resizer.Run(done)
+}
+
+func TestImagesAreUploadedAndResized(t *testing.T) {
+	handler, resizer, repository := setupImagesHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("PUT", "1", pngImage(t, 1200, 800)))
+	require.Equal(t, http.StatusAccepted, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), `"full":"/images/coffees/1/`)
+	assert.NotContains(t, rw.Body.String(), `"thumb"`)
+
+	resizeQueued(resizer)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("GET", "1", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	for _, size := range []string{`"thumb"`, `"medium"`, `"full"`} {
+		assert.Contains(t, rw.Body.String(), size)
+	}
+
+	// the details of the coffee carry the srcset
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+	rw = httptest.NewRecorder()
+	NewDetail(repository, tracker, hclog.NewNullLogger()).ServeHTTP(rw, detailRequest("1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Regexp(t, `"srcset":"\S+-thumb.png 160w, \S+-medium.png 480w, \S+-full.png 1200w"`, rw.Body.String())
+}
+
+func TestImagesRejectsInvalidUploads(t *testing.T) {
+	handler, _, _ := setupImagesHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("PUT", "1", []byte("not an image")))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("PUT", "1", pngImage(t, images.MaxDimension+1, 1)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("PUT", "1", make([]byte, maxImageBytes+1)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+}
+
+func TestImagesOfMissingCoffeeNotFound(t *testing.T) {
+	handler, _, _ := setupImagesHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("PUT", "42", pngImage(t, 10, 10)))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, imagesRequest("GET", "42", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/scheduler"
+)
+
+// CacheWarmUserAgent is the User-Agent of the requests of the cache warm
+// job, so access logs tell them apart
+const CacheWarmUserAgent = "coffee-service-cache-warm"
+
+// JobsService is an HTTP Handler listing the scheduled jobs, with their
+// schedule, last and next run and the error of the last run
+type JobsService struct {
+	scheduler *scheduler.Scheduler
+	logger    hclog.Logger
+}
+
+// NewJobs creates a new Jobs handler
+func NewJobs(s *scheduler.Scheduler, l hclog.Logger) *JobsService {
+	return &JobsService{s, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/jobs route
+func (s *JobsService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Jobs")
+
+	body, err := json.Marshal(s.scheduler.Statuses())
+	if err != nil {
+		s.logger.Error("Unable to encode jobs", "error", err)
+		http.Error(rw, "Unable to encode jobs", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// NewCacheWarm returns a scheduled task requesting every path from handler,
+// e.g. the router, so the cache middleware of their routes holds a fresh
+// response. The requests carry no Accept, Accept-Language nor X-Store
+// header and fill the cache entry of the clients sending none.
+func NewCacheWarm(handler http.Handler, paths []string) scheduler.Task {
+	return func(ctx context.Context) error {
+		for _, path := range paths {
+			r, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+			if err != nil {
+				return err
+			}
+			r.Header.Set("User-Agent", CacheWarmUserAgent)
+
+			rw := &discardResponse{header: http.Header{}, status: http.StatusOK}
+			handler.ServeHTTP(rw, r)
+			if rw.status >= http.StatusBadRequest {
+				return fmt.Errorf("GET %s returned %d", path, rw.status)
+			}
+		}
+		return nil
+	}
+}
+
+// discardResponse is a ResponseWriter keeping only the status
+type discardResponse struct {
+	header http.Header
+	status int
+	wrote  bool
+}
+
+func (d *discardResponse) Header() http.Header {
+	return d.header
+}
+
+func (d *discardResponse) WriteHeader(status int) {
+	if !d.wrote {
+		d.status, d.wrote = status, true
+	}
+}
+
+func (d *discardResponse) Write(b []byte) (int, error) {
+	d.wrote = true
+	return len(b), nil
+}
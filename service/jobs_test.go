@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/scheduler"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func TestJobsListsScheduledJobs(t *testing.T) {
+	s := scheduler.New(scheduler.Options{Logger: hclog.NewNullLogger()})
+	require.NoError(t, s.Add("reseed", "0 3 * * *", func(context.Context) error { return nil }))
+	require.NoError(t, s.Add("cache_warm", "*/5 * * * *", func(context.Context) error { return nil }))
+
+	rw := httptest.NewRecorder()
+	NewJobs(s, hclog.NewNullLogger()).ServeHTTP(rw, httptest.NewRequest("GET", "/admin/jobs", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	jobs := []map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &jobs))
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "cache_warm", jobs[0]["name"])
+	assert.Equal(t, "*/5 * * * *", jobs[0]["schedule"])
+	assert.Nil(t, jobs[0]["last_run"])
+	next, err := time.Parse(time.RFC3339, jobs[0]["next_run"].(string))
+	require.NoError(t, err)
+	assert.True(t, next.After(time.Now()))
+	assert.Equal(t, "reseed", jobs[1]["name"])
+}
+
+func TestCacheWarmFillsTheResponseCache(t *testing.T) {
+	requests := 0
+	handler := middleware.NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, CacheWarmUserAgent, r.UserAgent())
+		rw.Write([]byte(`[]`))
+	}))
+
+	warm := NewCacheWarm(handler, []string{"/coffees"})
+	require.NoError(t, warm(context.Background()))
+	require.Equal(t, 1, requests)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Equal(t, "HIT", rw.Header().Get(middleware.CacheHeader))
+	assert.Equal(t, 1, requests)
+}
+
+func TestCacheWarmReportsFailedRequests(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Unable to list coffees", http.StatusInternalServerError)
+	})
+
+	err := NewCacheWarm(handler, []string{"/coffees"})(context.Background())
+	assert.EqualError(t, err, "GET /coffees returned 500")
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+// LatencyService is an HTTP Handler for the latency injected into routes and
+// repository methods. GET returns the rules by target, PUT replaces them all
+// and DELETE removes them.
+type LatencyService struct {
+	injector *latency.Injector
+	logger   hclog.Logger
+}
+
+// NewLatency creates a new Latency handler
+func NewLatency(injector *latency.Injector, l hclog.Logger) *LatencyService {
+	return &LatencyService{injector, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/latency route
+func (s *LatencyService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Latency", "method", r.Method)
+
+	switch r.Method {
+	case http.MethodPut:
+		rules := latency.Rules{}
+		if err := newDecoder(r, r.Body).Decode(&rules); err != nil {
+			invalidPayload(rw, "Invalid latency rules", err)
+			return
+		}
+		if err := s.injector.SetRules(rules); err != nil {
+			http.Error(rw, "Latency rules need a target of a route, e.g. /coffees, or of a repository method, e.g. repository.FindByID, and a delay and jitter of 0 or more", http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("Latency rules replaced", "rules", len(rules))
+	case http.MethodDelete:
+		s.injector.SetRules(latency.Rules{})
+		s.logger.Info("Latency rules removed")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := json.Marshal(s.injector.Rules())
+	if err != nil {
+		s.logger.Error("Unable to encode latency rules", "error", err)
+		http.Error(rw, "Unable to encode latency rules", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
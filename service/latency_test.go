@@ -0,0 +1,41 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+func TestLatencyRulesAreReplacedAtRuntime(t *testing.T) {
+	injector := latency.NewInjector(latency.Rules{"/coffees": {Delay: 800 * time.Millisecond}})
+	s := NewLatency(injector, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/latency", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"/coffees":{"delay":"800ms"}}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("PUT", "/admin/latency", strings.NewReader(`{"repository.FindByID":{"delay":"50ms","jitter":"20ms"}}`)))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"repository.FindByID":{"delay":"50ms","jitter":"20ms"}}`, rw.Body.String())
+	assert.Equal(t, latency.Rules{"repository.FindByID": {Delay: 50 * time.Millisecond, Jitter: 20 * time.Millisecond}}, injector.Rules())
+
+	for _, body := range []string{`{"coffees":{"delay":"1s"}}`, `{"/coffees":{"delay":"-1s"}}`, `{"/coffees":{"delay":"slow"}}`} {
+		rw = httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("PUT", "/admin/latency", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+	}
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/latency", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Empty(t, injector.Rules())
+}
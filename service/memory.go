@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/pressure"
+)
+
+// memoryReport is the memory retained for the simulation and the memory of
+// the process, in megabytes
+type memoryReport struct {
+	RetainedMB  int    `json:"retained_mb"`
+	LimitMB     int    `json:"limit_mb"`
+	HeapAllocMB uint64 `json:"heap_alloc_mb"`
+	SysMB       uint64 `json:"sys_mb"`
+}
+
+// MemoryService is an HTTP Handler simulating memory pressure. POST retains
+// the megabytes of the mb query parameter more, up to the hard cap, DELETE
+// releases everything retained and GET reports the memory in use.
+type MemoryService struct {
+	memory *pressure.Memory
+	logger hclog.Logger
+}
+
+// NewMemory creates a new Memory handler
+func NewMemory(memory *pressure.Memory, l hclog.Logger) *MemoryService {
+	return &MemoryService{memory, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/memory route
+func (s *MemoryService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Memory", "method", r.Method)
+
+	switch r.Method {
+	case http.MethodPost:
+		mb, err := strconv.Atoi(r.URL.Query().Get("mb"))
+		if err != nil || mb <= 0 {
+			http.Error(rw, "Memory is allocated with a positive number of megabytes, e.g. ?mb=256", http.StatusBadRequest)
+			return
+		}
+		if err := s.memory.Allocate(mb); err != nil {
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		}
+		s.logger.Warn("Memory retained", "mb", mb, "retained_mb", s.memory.Retained())
+	case http.MethodDelete:
+		s.memory.Release()
+		s.logger.Info("Memory released")
+	}
+
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	body, err := json.Marshal(memoryReport{
+		RetainedMB:  s.memory.Retained(),
+		LimitMB:     s.memory.Limit(),
+		HeapAllocMB: stats.HeapAlloc >> 20,
+		SysMB:       stats.Sys >> 20,
+	})
+	if err != nil {
+		s.logger.Error("Unable to encode memory report", "error", err)
+		http.Error(rw, "Unable to encode memory report", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
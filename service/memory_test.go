@@ -0,0 +1,50 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/pressure"
+)
+
+func TestMemoryIsRetainedAndReleased(t *testing.T) {
+	s := NewMemory(pressure.NewMemory(4), hclog.NewNullLogger())
+	report := func(rw *httptest.ResponseRecorder) memoryReport {
+		r := memoryReport{}
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &r))
+		return r
+	}
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/memory?mb=3", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 3, report(rw).RetainedMB)
+	assert.Equal(t, 4, report(rw).LimitMB)
+
+	// the hard cap
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/memory?mb=2", nil))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	for _, mb := range []string{"", "0", "-1", "lots"} {
+		rw = httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("POST", "/admin/memory?mb="+mb, nil))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, mb)
+	}
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/memory", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 0, report(rw).RetainedMB)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/memory", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 0, report(rw).RetainedMB)
+}
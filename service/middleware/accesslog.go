@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CombinedFormat is the Apache combined log format followed by the
+	// latency, trace ID and tenant
+	CombinedFormat = "combined"
+	// JSONFormat writes every access as a JSON object on its own line, with
+	// the request ID as well
+	JSONFormat = "json"
+)
+
+// TenantHeader identifies the tenant a request is made for
+const TenantHeader = "X-Tenant-ID"
+
+// accessEntry is a single access log record
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Referer    string    `json:"referer"`
+	UserAgent  string    `json:"user_agent"`
+	TraceID    string    `json:"trace_id"`
+	Tenant     string    `json:"tenant"`
+	RequestID  string    `json:"request_id"`
+}
+
+// NewAccessLog returns middleware writing an access log entry to w for every
+// request once its response has been sent, in CombinedFormat or JSONFormat
+func NewAccessLog(format string, w io.Writer) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	write := func(e accessEntry) {
+		var line []byte
+		if format == JSONFormat {
+			line, _ = json.Marshal(e)
+			line = append(line, '\n')
+		} else {
+			line = []byte(e.combined())
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(line)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			write(accessEntry{
+				Time:       start,
+				RemoteAddr: host,
+				Method:     r.Method,
+				URI:        r.RequestURI,
+				Protocol:   r.Proto,
+				Status:     sw.status,
+				Bytes:      sw.bytes,
+				LatencyMs:  float64(time.Since(start).Microseconds()) / 1000,
+				Referer:    r.Referer(),
+				UserAgent:  r.UserAgent(),
+				TraceID:    traceID(r.Header),
+				Tenant:     r.Header.Get(TenantHeader),
+				RequestID:  sw.Header().Get(RequestIDHeader),
+			})
+		})
+	}
+}
+
+// combined formats the entry in the Apache combined log format, followed by
+// the latency in milliseconds, the trace ID and the tenant
+func (e accessEntry) combined() string {
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s \"%s\" \"%s\" %.3f \"%s\" \"%s\"\n",
+		e.RemoteAddr,
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Protocol,
+		e.Status,
+		orDash(fmt.Sprint(e.Bytes), e.Bytes == 0),
+		orDash(e.Referer, e.Referer == ""),
+		orDash(e.UserAgent, e.UserAgent == ""),
+		e.LatencyMs,
+		orDash(e.TraceID, e.TraceID == ""),
+		orDash(e.Tenant, e.Tenant == ""),
+	)
+}
+
+// orDash returns "-", the combined format placeholder for a missing value,
+// when missing is set
+func orDash(value string, missing bool) string {
+	if missing {
+		return "-"
+	}
+	return value
+}
+
+// traceID reads the trace ID propagated by W3C Trace Context, Jaeger or B3
+// headers. Jaeger clients may URL encode their header.
+func traceID(header http.Header) string {
+	if parent := strings.Split(header.Get("traceparent"), "-"); len(parent) == 4 {
+		return parent[1]
+	}
+	if uber, err := url.QueryUnescape(header.Get("uber-trace-id")); err == nil && uber != "" {
+		return strings.SplitN(uber, ":", 2)[0]
+	}
+	return header.Get("X-B3-TraceId")
+}
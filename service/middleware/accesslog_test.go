@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveLogged(format string) string {
+	var log bytes.Buffer
+	handler := NewAccessLog(format, &log)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+		rw.Write([]byte("short and stout"))
+	}))
+
+	r := httptest.NewRequest("GET", "/coffees?filter=price<200", nil)
+	r.RemoteAddr = "10.0.0.7:51234"
+	r.Header.Set("User-Agent", "curl/7.68.0")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set(TenantHeader, "hashicups")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	return log.String()
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	line := serveLogged(CombinedFormat)
+
+	pattern := `^10\.0\.0\.7 - - \[[^\]]+\] "GET /coffees\?filter=price<200 HTTP/1\.1" 418 15 "-" "curl/7\.68\.0" [0-9.]+ "4bf92f3577b34da6a3ce929d0e0e4736" "hashicups"\n$`
+	assert.Regexp(t, regexp.MustCompile(pattern), line)
+}
+
+func TestAccessLogJSONFormat(t *testing.T) {
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(serveLogged(JSONFormat)), &entry))
+
+	assert.Equal(t, "10.0.0.7", entry["remote_addr"])
+	assert.Equal(t, 418.0, entry["status"])
+	assert.Equal(t, 15.0, entry["bytes"])
+	assert.Equal(t, "curl/7.68.0", entry["user_agent"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.Equal(t, "hashicups", entry["tenant"])
+	assert.Contains(t, entry, "latency_ms")
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// NewAuth returns middleware rejecting requests which do not carry token in
+// an `Authorization: Bearer <token>` header
+func NewAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !bearerAuthorized(r, token) {
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// bearerAuthorized reports whether r carries token in an
+// `Authorization: Bearer <token>` header, never when token is empty
+func bearerAuthorized(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	bearer := strings.TrimPrefix(header, "Bearer ")
+	return token != "" && bearer != header && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
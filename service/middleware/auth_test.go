@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRequiresBearerToken(t *testing.T) {
+	handler := NewAuth("s3cret")(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	for header, status := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set("Authorization", header)
+		handler.ServeHTTP(rw, r)
+
+		assert.Equal(t, status, rw.Code, header)
+	}
+}
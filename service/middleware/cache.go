@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// CacheHeader reports whether a response was served from the cache, HIT,
+// STALE or MISS, or BYPASS when the cached response is older than the write
+// of the session token of the request
+const CacheHeader = "X-Cache"
+
+// NewCache returns middleware caching successful GET responses for ttl, keyed
+// by URL, Accept, Accept-Language and X-Store headers. Once a response is
+// older than ttl it is still served for up to stale while a single background
+// request refreshes it, i.e. stale-while-revalidate. A stale of 0 disables
+// revalidation. Responses carry the policy in Cache-Control and the age of
+// cached responses in Age. Requests whose session token, see NewSession, is
+// newer than the cached response are served and cached again by next.
+// Responses which already carry a Cache-Control header keep it and are not
+// cached.
+func NewCache(ttl, stale time.Duration) func(http.Handler) http.Handler {
+	return newResponseCache(ttl, stale).middleware
+}
+
+// cachedResponse is a response body with the headers set by the handler,
+// without those of the outer middleware
+type cachedResponse struct {
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	refreshing bool
+}
+
+// freshness of a cached response
+type freshness int
+
+const (
+	missing freshness = iota
+	fresh
+	stale
+	// bypass is a response older than the session of the request
+	bypass
+)
+
+// String returns the name of the freshness, as reported in debug traces
+func (f freshness) String() string {
+	switch f {
+	case fresh:
+		return "hit"
+	case stale:
+		return "stale"
+	case bypass:
+		return "bypass"
+	}
+	return "miss"
+}
+
+// responseCache holds responses until they are older than ttl and stale
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	stale   time.Duration
+	entries map[string]*cachedResponse
+	now     func() time.Time
+	// refresh runs revalidations, in the background outside of tests
+	refresh func(func())
+}
+
+// newResponseCache creates an empty responseCache
+func newResponseCache(ttl, stale time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		stale:   stale,
+		entries: map[string]*cachedResponse{},
+		now:     time.Now,
+		refresh: func(fn func()) { go fn() },
+	}
+}
+
+// middleware serves cached responses and caches the responses of next
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		key := r.URL.String() + "\n" + r.Header.Get("Accept") + "\n" + r.Header.Get("Accept-Language") + "\n" + r.Header.Get(StoreHeader)
+		token, _ := sessionToken(r)
+		cached, state, revalidate := c.get(key, token.Time)
+		data.RecordCacheLookup(r.Context(), "response", r.URL.RequestURI(), state.String())
+		if state == fresh || state == stale {
+			for name, values := range cached.header {
+				rw.Header()[name] = values
+			}
+			rw.Header().Set("Cache-Control", c.cacheControl())
+			rw.Header().Set("Age", strconv.FormatInt(int64(c.now().Sub(cached.stored)/time.Second), 10))
+			if state == stale {
+				rw.Header().Set(CacheHeader, "STALE")
+			} else {
+				rw.Header().Set(CacheHeader, "HIT")
+			}
+			rw.WriteHeader(http.StatusOK)
+			rw.Write(cached.body)
+
+			if revalidate {
+				c.refresh(func() { c.revalidate(key, next, r) })
+			}
+			return
+		}
+
+		if state == bypass {
+			rw.Header().Set(CacheHeader, "BYPASS")
+		} else {
+			rw.Header().Set(CacheHeader, "MISS")
+		}
+		// the headers of the outer middleware, e.g. X-Request-ID, describe
+		// this request and are not served to the next ones
+		outer := rw.Header().Clone()
+		bw := &bufferedWriter{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+
+		// handlers setting their own policy, e.g. static files, are not cached
+		if bw.status == http.StatusOK && rw.Header().Get("Cache-Control") == "" {
+			c.set(key, handlerHeader(outer, rw.Header()), bw.body.Bytes())
+			rw.Header().Set("Cache-Control", c.cacheControl())
+		}
+		rw.WriteHeader(bw.status)
+		rw.Write(bw.body.Bytes())
+	})
+}
+
+// revalidate replays r through next to replace the stale response cached for
+// key. The request is detached from the client, which already has its
+// response. A failed refresh keeps the stale response until it expires.
+func (c *responseCache) revalidate(key string, next http.Handler, r *http.Request) {
+	rw := &refreshWriter{header: http.Header{}, status: http.StatusOK}
+	next.ServeHTTP(rw, r.WithContext(detachedContext{r.Context()}))
+
+	if rw.status == http.StatusOK {
+		c.set(key, rw.header, rw.body.Bytes())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.refreshing = false
+	}
+}
+
+// cacheControl is the Cache-Control header describing the policy of the cache
+func (c *responseCache) cacheControl() string {
+	value := fmt.Sprintf("max-age=%d", int64(c.ttl/time.Second))
+	if c.stale > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", int64(c.stale/time.Second))
+	}
+	return value
+}
+
+// get returns the response cached for key and its freshness, bypass when it
+// was stored before after. revalidate is true for the first request to find
+// a stale response, it refreshes it.
+func (c *responseCache) get(key string, after time.Time) (cached cachedResponse, state freshness, revalidate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return cachedResponse{}, missing, false
+	}
+	if entry.stored.Before(after) {
+		return cachedResponse{}, bypass, false
+	}
+
+	age := c.now().Sub(entry.stored)
+	switch {
+	case age <= c.ttl:
+		return *entry, fresh, false
+	case age <= c.ttl+c.stale:
+		revalidate = !entry.refreshing
+		entry.refreshing = true
+		return *entry, stale, revalidate
+	}
+
+	delete(c.entries, key)
+	return cachedResponse{}, missing, false
+}
+
+// set caches a response, dropping the entries too old to be served
+func (c *responseCache) set(key string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, entry := range c.entries {
+		if now.Sub(entry.stored) > c.ttl+c.stale {
+			delete(c.entries, k)
+		}
+	}
+
+	header = header.Clone()
+	header.Del(CacheHeader)
+	c.entries[key] = &cachedResponse{header: header, body: append([]byte(nil), body...), stored: now}
+}
+
+// handlerHeader returns the headers of header which the handler added or
+// changed since they were outer
+func handlerHeader(outer, header http.Header) http.Header {
+	set := http.Header{}
+	for name, values := range header {
+		if !equalValues(outer[name], values) {
+			set[name] = values
+		}
+	}
+	return set
+}
+
+// equalValues reports whether two header values are the same
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshWriter captures the response of a revalidation, which has no client
+// to write to
+type refreshWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the headers set by the handler
+func (w *refreshWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader records the status code
+func (w *refreshWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// Write buffers the body
+func (w *refreshWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// detachedContext keeps the values of a request context, such as the route
+// variables, without its deadline and cancellation
+type detachedContext struct {
+	context.Context
+}
+
+// Deadline reports that there is no deadline
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns nil, the context is never cancelled
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil, the context is never cancelled
+func (detachedContext) Err() error {
+	return nil
+}
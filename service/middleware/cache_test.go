@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheServesRepeatedRequests(t *testing.T) {
+	calls := 0
+	handler := NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"call":%d}`, calls)
+	}))
+
+	get := func(accept, language, store string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set("Accept", accept)
+		r.Header.Set("Accept-Language", language)
+		r.Header.Set(StoreHeader, store)
+		handler.ServeHTTP(rw, r)
+		return rw
+	}
+
+	first := get("application/json", "", "")
+	assert.Equal(t, "MISS", first.Header().Get(CacheHeader))
+
+	second := get("application/json", "", "")
+	assert.Equal(t, "HIT", second.Header().Get(CacheHeader))
+	assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	// a different representation, language or store is cached separately
+	assert.Equal(t, "MISS", get("application/msgpack", "", "").Header().Get(CacheHeader))
+	assert.Equal(t, "MISS", get("application/json", "fr", "").Header().Get(CacheHeader))
+	assert.Equal(t, "MISS", get("application/json", "", "2").Header().Get(CacheHeader))
+	assert.Equal(t, 4, calls)
+}
+
+func TestCacheHitsKeepTheRequestID(t *testing.T) {
+	handler := NewRequestID()(NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(rw, `{"coffees":[]}`)
+	})))
+
+	get := func(id string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(RequestIDHeader, id)
+		handler.ServeHTTP(rw, r)
+		return rw
+	}
+
+	assert.Equal(t, "curl-1", get("curl-1").Header().Get(RequestIDHeader))
+	hit := get("curl-2")
+	assert.Equal(t, "HIT", hit.Header().Get(CacheHeader))
+	assert.Equal(t, "curl-2", hit.Header().Get(RequestIDHeader))
+	assert.Equal(t, []string{"curl-2"}, hit.Header().Values(RequestIDHeader))
+	assert.Equal(t, "application/json", hit.Header().Get("Content-Type"))
+}
+
+func TestCacheBypassesResponsesOlderThanTheSession(t *testing.T) {
+	calls := 0
+	handler := NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(rw, `{"call":%d}`, calls)
+	}))
+
+	get := func(token string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(SessionHeader, token)
+		handler.ServeHTTP(rw, r)
+		return rw
+	}
+
+	assert.Equal(t, "MISS", get("").Header().Get(CacheHeader))
+	token := SessionToken{Time: time.Now()}.String()
+
+	bypassed := get(token)
+	assert.Equal(t, "BYPASS", bypassed.Header().Get(CacheHeader))
+	assert.Equal(t, `{"call":2}`, bypassed.Body.String())
+
+	// the response served to the session is cached for everyone
+	assert.Equal(t, "HIT", get(token).Header().Get(CacheHeader))
+	assert.Equal(t, "HIT", get("").Header().Get(CacheHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheSkipsErrors(t *testing.T) {
+	calls := 0
+	handler := NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheSkipsResponsesWithTheirOwnPolicy(t *testing.T) {
+	calls := 0
+	handler := NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		fmt.Fprint(rw, "packer")
+	}))
+
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/static/packer.png", nil))
+		assert.Equal(t, "MISS", rw.Header().Get(CacheHeader))
+		assert.Equal(t, "public, max-age=31536000, immutable", rw.Header().Get("Cache-Control"))
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheServesStaleResponsesWhileRevalidating(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(rw, `{"call":%d}`, calls)
+	})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newResponseCache(10*time.Second, time.Minute)
+	cache.now = func() time.Time { return now }
+	refreshes := []func(){}
+	cache.refresh = func(fn func()) { refreshes = append(refreshes, fn) }
+	handler := cache.middleware(next)
+
+	get := func() *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+		return rw
+	}
+
+	first := get()
+	assert.Equal(t, "MISS", first.Header().Get(CacheHeader))
+	assert.Equal(t, "max-age=10, stale-while-revalidate=60", first.Header().Get("Cache-Control"))
+	assert.Empty(t, first.Header().Get("Age"))
+
+	now = now.Add(5 * time.Second)
+	hit := get()
+	assert.Equal(t, "HIT", hit.Header().Get(CacheHeader))
+	assert.Equal(t, "5", hit.Header().Get("Age"))
+
+	// past the ttl the stale response is served and a single refresh starts
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		stale := get()
+		assert.Equal(t, "STALE", stale.Header().Get(CacheHeader))
+		assert.Equal(t, "15", stale.Header().Get("Age"))
+		assert.Equal(t, `{"call":1}`, stale.Body.String())
+	}
+	assert.Len(t, refreshes, 1)
+
+	refreshes[0]()
+	refreshed := get()
+	assert.Equal(t, "HIT", refreshed.Header().Get(CacheHeader))
+	assert.Equal(t, "0", refreshed.Header().Get("Age"))
+	assert.Equal(t, `{"call":2}`, refreshed.Body.String())
+
+	// past the stale window the response is fetched again
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "MISS", get().Header().Get(CacheHeader))
+	assert.Equal(t, 3, calls)
+}
+
+func TestCacheKeepsStaleResponseWhenRevalidationFails(t *testing.T) {
+	status := http.StatusOK
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(status)
+		fmt.Fprint(rw, "coffees")
+	})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newResponseCache(time.Second, time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.refresh = func(fn func()) { fn() }
+	handler := cache.middleware(next)
+
+	get := func() *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+		return rw
+	}
+
+	get()
+	status = http.StatusServiceUnavailable
+	now = now.Add(2 * time.Second)
+
+	for i := 0; i < 2; i++ {
+		stale := get()
+		assert.Equal(t, http.StatusOK, stale.Code)
+		assert.Equal(t, "STALE", stale.Header().Get(CacheHeader))
+		assert.Equal(t, "coffees", stale.Body.String())
+	}
+}
+
+func TestDetachedContextIgnoresCancellation(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	ctx := detachedContext{parent}
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	assert.Equal(t, "value", ctx.Value(key{}))
+}
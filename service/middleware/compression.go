@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// The content codings of compressed responses
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// NewCompression returns middleware compressing JSON responses with brotli,
+// at brotliQuality from 0 to 11, or gzip, whichever the Accept-Encoding
+// header of the request prefers, brotli when it accepts both equally.
+// Responses of other media types, already encoded, served in byte ranges or
+// without a body pass through untouched, the ranges would not match the
+// compressed body. JSON responses carry Vary: Accept-Encoding either way.
+func NewCompression(brotliQuality int) func(http.Handler) http.Handler {
+	c := &compression{
+		brotli: sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(nil, brotliQuality) }},
+		gzip:   sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }},
+	}
+	return c.middleware
+}
+
+// compression holds the encoders between responses, they allocate large
+// windows which are worth reusing
+type compression struct {
+	brotli sync.Pool
+	gzip   sync.Pool
+}
+
+// middleware compresses the responses of next
+func (c *compression) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+		if r.Method == http.MethodHead {
+			encoding = ""
+		}
+
+		cw := &compressWriter{ResponseWriter: rw, compression: c, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter picks whether to compress a response once its headers are
+// written, then compresses the body written through it
+type compressWriter struct {
+	http.ResponseWriter
+	compression *compression
+	// encoding is the coding the client prefers, empty when it accepts none
+	encoding    string
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+// WriteHeader starts compressing JSON responses and sends the status code
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if !jsonMediaType(w.Header().Get("Content-Type")) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	bodyless := status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified
+	if w.encoding == "" || bodyless || w.Header().Get("Content-Encoding") != "" || rangedResponse(w.Header()) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.Header().Del("Content-Length")
+	switch w.encoding {
+	case Brotli:
+		encoder := w.compression.brotli.Get().(*brotli.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	case Gzip:
+		encoder := w.compression.gzip.Get().(*gzip.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses the body when the response is compressed
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.encoder.Write(b)
+}
+
+// close flushes the compressed body and returns the encoder to its pool
+func (w *compressWriter) close() {
+	if w.encoder == nil {
+		return
+	}
+	w.encoder.Close()
+	switch encoder := w.encoder.(type) {
+	case *brotli.Writer:
+		w.compression.brotli.Put(encoder)
+	case *gzip.Writer:
+		w.compression.gzip.Put(encoder)
+	}
+	w.encoder = nil
+}
+
+// negotiateEncoding returns the content coding of the Accept-Encoding header
+// values with the highest quality, brotli first on a tie, or an empty string
+// when neither brotli nor gzip is accepted
+func negotiateEncoding(values []string) string {
+	qualities := map[string]float64{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := cut(strings.TrimSpace(part), ";")
+			q := 1.0
+			if name, v, ok := cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = parsed
+				}
+			}
+			qualities[strings.ToLower(strings.TrimSpace(coding))] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{Brotli, Gzip} {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// cut slices s around the first sep, like strings.Cut of later Go versions
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// jsonMediaType reports whether a Content-Type is JSON, including the
+// +json media types of HAL and JSON:API
+func jsonMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// compressed serves body as contentType through the compression middleware
+func compressed(contentType string, body []byte) http.Handler {
+	return NewCompression(4)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", contentType)
+		rw.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		rw.Write(body)
+	}))
+}
+
+func compressedRequest(handler http.Handler, method, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/coffees", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestCompressionDecodesToTheOriginalBody(t *testing.T) {
+	body := []byte(`[` + strings.Repeat(`{"name":"Packer Spiced Latte","price":350},`, 50) + `{}]`)
+	handler := compressed("application/json", body)
+
+	rw := compressedRequest(handler, "GET", "gzip, deflate, br")
+	require.Equal(t, Brotli, rw.Header().Get("Content-Encoding"))
+	assert.Empty(t, rw.Header().Get("Content-Length"))
+	assert.Equal(t, "Accept-Encoding", rw.Header().Get("Vary"))
+	assert.Less(t, rw.Body.Len(), len(body))
+	decoded, err := ioutil.ReadAll(brotli.NewReader(rw.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, decoded)
+
+	rw = compressedRequest(handler, "GET", "gzip")
+	require.Equal(t, Gzip, rw.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rw.Body)
+	require.NoError(t, err)
+	decoded, err = ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, body, decoded)
+
+	// the pooled encoders are reset between responses
+	rw = compressedRequest(handler, "GET", "br")
+	decoded, err = ioutil.ReadAll(brotli.NewReader(rw.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, decoded)
+}
+
+func TestCompressionSkipsOtherResponses(t *testing.T) {
+	json := compressed("application/hal+json", []byte(`{"name":"Vaulatte"}`))
+
+	rw := compressedRequest(json, "GET", "")
+	assert.Empty(t, rw.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rw.Header().Get("Vary"))
+	assert.Equal(t, `{"name":"Vaulatte"}`, rw.Body.String())
+
+	rw = compressedRequest(json, "HEAD", "br")
+	assert.Empty(t, rw.Header().Get("Content-Encoding"))
+
+	ranged := NewCompression(4)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Accept-Ranges", "bytes")
+		rw.Write([]byte(`{"tenant":"default"}`))
+	}))
+	rw = compressedRequest(ranged, "GET", "br")
+	assert.Empty(t, rw.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"tenant":"default"}`, rw.Body.String())
+
+	pdf := compressed("application/pdf", []byte("%PDF-1.4"))
+	rw = compressedRequest(pdf, "GET", "br")
+	assert.Empty(t, rw.Header().Get("Content-Encoding"))
+	assert.Empty(t, rw.Header().Get("Vary"))
+	assert.Equal(t, "%PDF-1.4", rw.Body.String())
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   Gzip,
+		"br":                     Brotli,
+		"gzip, br":               Brotli,
+		"br;q=0.5, gzip":         Gzip,
+		"br;q=0, gzip;q=0":       "",
+		"*":                      Brotli,
+		"*;q=0.5, gzip":          Gzip,
+		"deflate, GZIP ; q=0.8 ": Gzip,
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, negotiateEncoding([]string{header}), header)
+	}
+}
+
+// BenchmarkCompression compresses the catalogue of 1,000 generated coffees,
+// reporting the size of the payload and its ratio to the uncompressed JSON
+func BenchmarkCompression(b *testing.B) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(b, err)
+	require.NoError(b, data.GenerateCoffees(context.Background(), repository, 1000, 1))
+	coffees, err := repository.Find(context.Background())
+	require.NoError(b, err)
+	body, err := json.Marshal(coffees)
+	require.NoError(b, err)
+
+	for _, c := range []struct {
+		encoding string
+		quality  int
+	}{{"identity", 0}, {Gzip, 0}, {Brotli, 0}, {Brotli, 5}, {Brotli, 9}} {
+		name := "encoding=" + c.encoding
+		if c.encoding == Brotli {
+			name += fmt.Sprintf(",quality=%d", c.quality)
+		}
+		b.Run(name, func(b *testing.B) {
+			handler := NewCompression(c.quality)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Content-Type", "application/json")
+				rw.Write(body)
+			}))
+			r := httptest.NewRequest("GET", "/coffees", nil)
+			r.Header.Set("Accept-Encoding", c.encoding)
+
+			var size int
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, r)
+				size = rw.Body.Len()
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+			b.ReportMetric(float64(size)/float64(len(body)), "ratio")
+		})
+	}
+}
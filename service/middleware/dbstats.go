@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+const (
+	// DBQueryCountHeader reports the number of database queries made
+	DBQueryCountHeader = "X-DB-Query-Count"
+	// DBDurationHeader reports the time spent in database queries in milliseconds
+	DBDurationHeader = "X-DB-Duration-Ms"
+)
+
+// NewDBStats returns middleware that collects the repository queries made
+// while serving a request and reports them in the X-DB-Query-Count and
+// X-DB-Duration-Ms response headers. It is intended for debugging, e.g. to
+// observe N+1 query patterns.
+func NewDBStats() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx, stats := data.WithQueryStats(r.Context())
+			next.ServeHTTP(&statsWriter{ResponseWriter: rw, stats: stats}, r.WithContext(ctx))
+		})
+	}
+}
+
+// statsWriter adds the query statistics headers just before the response
+// headers are sent
+type statsWriter struct {
+	http.ResponseWriter
+	stats       *data.QueryStats
+	wroteHeader bool
+}
+
+// WriteHeader sets the statistics headers and sends the status code
+func (w *statsWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(DBQueryCountHeader, strconv.FormatInt(w.stats.Count(), 10))
+		w.Header().Set(DBDurationHeader, strconv.FormatFloat(float64(w.stats.Duration().Microseconds())/1000, 'f', 3, 64))
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the headers if the handler has not done so yet
+func (w *statsWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
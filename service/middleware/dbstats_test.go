@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func TestDBStatsReportsRepositoryQueries(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	h := NewDBStats()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, err := repository.FindByID(r.Context(), 1)
+		require.NoError(t, err)
+		rw.Write([]byte("ok"))
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/1", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotEqual(t, "0", rw.Header().Get(DBQueryCountHeader))
+	assert.NotEmpty(t, rw.Header().Get(DBDurationHeader))
+}
+
+func TestDBStatsReportsZeroWithoutQueries(t *testing.T) {
+	h := NewDBStats()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/42", nil))
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "0", rw.Header().Get(DBQueryCountHeader))
+	assert.Equal(t, "0.000", rw.Header().Get(DBDurationHeader))
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// NewDeadline returns middleware giving the context of every request a
+// deadline of timeout. Repository queries are aborted once it has passed,
+// the handler still writes the response.
+func NewDeadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlineSetsContextDeadline(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	handler := NewDeadline(time.Second)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+
+	assert.True(t, ok)
+	assert.WithinDuration(t, start.Add(time.Second), deadline, 100*time.Millisecond)
+}
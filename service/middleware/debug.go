@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// DebugParameter is the query parameter asking for the debug trace of a
+// request, e.g. ?debug=true
+const DebugParameter = "debug"
+
+// NewDebug returns middleware adding a _debug section to the JSON responses
+// of requests passing ?debug=true, with the repository queries made, the
+// cache lookups and their timings, see data.DebugTrace. Debug requests must
+// carry token in an `Authorization: Bearer <token>` header like the admin
+// routes, they fail with 401 otherwise.
+//
+// Objects get a _debug member, arrays are wrapped in an object holding them
+// in data, other media types and responses served in byte ranges pass
+// through untouched.
+func NewDebug(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if debug, _ := strconv.ParseBool(r.URL.Query().Get(DebugParameter)); !debug {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			if !bearerAuthorized(r, token) {
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ctx, trace := data.WithDebugTrace(r.Context())
+			bw := &bufferedWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(bw, r.WithContext(ctx))
+
+			body, ok := withDebug(rw.Header().Get("Content-Type"), bw.body.Bytes(), trace.Report())
+			if !ok || rangedResponse(rw.Header()) {
+				rw.WriteHeader(bw.status)
+				rw.Write(bw.body.Bytes())
+				return
+			}
+
+			rw.Header().Del("Content-Length")
+			rw.WriteHeader(bw.status)
+			rw.Write(body)
+		})
+	}
+}
+
+// withDebug adds report to a JSON body, returning false when the body is not
+// a JSON object or array
+func withDebug(contentType string, body []byte, report data.DebugReport) ([]byte, bool) {
+	if !jsonMediaType(contentType) {
+		return nil, false
+	}
+	d, err := json.Marshal(report)
+	if err != nil {
+		return nil, false
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) < 2 {
+		return nil, false
+	}
+	// the members of objects are kept in order by splicing the section in
+	switch body[0] {
+	case '{':
+		members := bytes.TrimSpace(body[1 : len(body)-1])
+		out := append([]byte(nil), '{')
+		if len(members) > 0 {
+			out = append(append(out, members...), ',')
+		}
+		out = append(append(append(out, `"_debug":`...), d...), '}')
+		return out, true
+	case '[':
+		out := append(append([]byte(`{"data":`), body...), `,"_debug":`...)
+		return append(append(out, d...), '}'), true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// debugRequest is a GET of target with the admin token
+func debugRequest(target string) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	return r
+}
+
+func TestDebugAddsTheTraceToObjectsAndArrays(t *testing.T) {
+	body := `{"id":1,"name":"Packer Spiced Latte"}`
+	handler := NewDebug("secret")(NewCache(time.Minute, 0)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(body))
+	})))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, debugRequest("/coffees/1?debug=true"))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	response := struct {
+		ID    int              `json:"id"`
+		Name  string           `json:"name"`
+		Debug data.DebugReport `json:"_debug"`
+	}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, "Packer Spiced Latte", response.Name)
+	assert.Equal(t, []data.DebugCacheLookup{{Cache: "response", Key: "/coffees/1?debug=true", Result: "miss"}}, response.Debug.Caches)
+
+	// the second request is served by the cache
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, debugRequest("/coffees/1?debug=true"))
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, "hit", response.Debug.Caches[0].Result)
+
+	body = `[{"id":1}]`
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, debugRequest("/coffees?debug=true"))
+	wrapped := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &wrapped))
+	assert.JSONEq(t, body, string(wrapped["data"]))
+	assert.Contains(t, wrapped, "_debug")
+}
+
+func TestDebugRequiresTheToken(t *testing.T) {
+	handler := NewDebug("secret")(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{}`))
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees?debug=true", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	// requests without the parameter are served as is, token or not
+	for _, r := range []*http.Request{httptest.NewRequest("GET", "/coffees", nil), debugRequest("/coffees?debug=false")} {
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `{}`, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, debugRequest("/coffees?debug=1"))
+	assert.Contains(t, rw.Body.String(), `{"_debug":{"duration_ms":`)
+}
+
+func TestDebugPassesOtherMediaTypesThrough(t *testing.T) {
+	handler := NewDebug("secret")(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte("ok"))
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, debugRequest("/health?debug=true"))
+	assert.Equal(t, "ok", rw.Body.String())
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// drainerKey marks the context of the requests counted by a Drainer
+type drainerKey struct{}
+
+// Drainer counts the requests in flight, reported in the
+// http.requests.in_flight gauge, so that a service being stopped can wait for
+// them to complete. Once draining it fails the readiness probe, so no new
+// requests are routed to it, and asks the clients to close their keep alive
+// connections.
+type Drainer struct {
+	sink metrics.Sink
+
+	inflight int64
+	draining int32
+}
+
+// NewDrainer creates a Drainer reporting the requests in flight to sink
+func NewDrainer(sink metrics.Sink) *Drainer {
+	return &Drainer{sink: sink}
+}
+
+// Middleware returns middleware counting every request for as long as its
+// handler runs
+func (d *Drainer) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			d.sink.SetGauge("http.requests.in_flight", float64(atomic.AddInt64(&d.inflight, 1)))
+			defer func() {
+				d.sink.SetGauge("http.requests.in_flight", float64(atomic.AddInt64(&d.inflight, -1)))
+			}()
+
+			if d.Draining() {
+				rw.Header().Set("Connection", "close")
+			}
+
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), drainerKey{}, d)))
+		})
+	}
+}
+
+// Drain starts draining, it reports false when the Drainer was already
+// draining
+func (d *Drainer) Drain() bool {
+	return atomic.CompareAndSwapInt32(&d.draining, 0, 1)
+}
+
+// Draining reports whether Drain was called
+func (d *Drainer) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// InFlight returns the number of requests in flight, other than the request
+// of ctx when it is counted itself
+func (d *Drainer) InFlight(ctx context.Context) int64 {
+	n := atomic.LoadInt64(&d.inflight)
+	if ctx.Value(drainerKey{}) == d {
+		n--
+	}
+	return n
+}
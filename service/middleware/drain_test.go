@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+func TestDrainerCountsRequestsInFlight(t *testing.T) {
+	d := NewDrainer(metrics.FanoutSink{})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := d.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// the request does not count itself
+		assert.Equal(t, int64(0), d.InFlight(r.Context()))
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+		close(done)
+	}()
+	<-started
+
+	r := httptest.NewRequest("GET", "/admin/drain", nil)
+	assert.Equal(t, int64(1), d.InFlight(r.Context()))
+
+	close(release)
+	<-done
+	assert.Equal(t, int64(0), d.InFlight(r.Context()))
+}
+
+func TestDrainerClosesConnectionsOnceDraining(t *testing.T) {
+	d := NewDrainer(metrics.FanoutSink{})
+	handler := d.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Empty(t, rw.Header().Get("Connection"))
+
+	assert.True(t, d.Drain())
+	assert.False(t, d.Drain())
+	assert.True(t, d.Draining())
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Equal(t, "close", rw.Header().Get("Connection"))
+}
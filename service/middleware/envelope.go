@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Envelope is the response shape expected by the HashiCups frontend
+type Envelope struct {
+	Data   json.RawMessage `json:"data"`
+	Meta   EnvelopeMeta    `json:"meta"`
+	Errors []EnvelopeError `json:"errors"`
+}
+
+// EnvelopeMeta describes the payload carried in Data
+type EnvelopeMeta struct {
+	Version string `json:"version"`
+	Count   *int   `json:"count,omitempty"`
+}
+
+// EnvelopeError describes a failed request, with the request and trace IDs
+// correlating it with the logs and traces when known
+type EnvelopeError struct {
+	Status    int    `json:"status"`
+	Title     string `json:"title"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+}
+
+// NewEnvelope returns middleware that wraps JSON responses in an Envelope.
+// Successful JSON bodies become Data, error responses become Errors, and
+// other media types (protobuf, msgpack, plain text) and responses served in
+// byte ranges pass through untouched.
+func NewEnvelope(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			bw := &bufferedWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(bw, r)
+
+			body, ok := envelope(r.Context(), version, bw.status, rw.Header().Get("Content-Type"), bw.body.Bytes())
+			if !ok || rangedResponse(rw.Header()) {
+				rw.WriteHeader(bw.status)
+				rw.Write(bw.body.Bytes())
+				return
+			}
+
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Header().Del("Content-Length")
+			rw.Header().Del("X-Content-Type-Options")
+			rw.WriteHeader(bw.status)
+			rw.Write(body)
+		})
+	}
+}
+
+// envelope builds the enveloped body, returning false when the response
+// should be passed through as is.
+func envelope(ctx context.Context, version string, status int, contentType string, body []byte) ([]byte, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	e := Envelope{Data: json.RawMessage("null"), Meta: EnvelopeMeta{Version: version}, Errors: []EnvelopeError{}}
+
+	switch {
+	case status >= http.StatusBadRequest:
+		e.Errors = append(e.Errors, EnvelopeError{
+			Status:    status,
+			Title:     http.StatusText(status),
+			Detail:    strings.TrimSpace(string(body)),
+			RequestID: RequestID(ctx),
+			TraceID:   TraceID(ctx),
+		})
+	case mediaType == "application/json":
+		e.Data = json.RawMessage(body)
+
+		items := []json.RawMessage{}
+		if json.Unmarshal(body, &items) == nil {
+			count := len(items)
+			e.Meta.Count = &count
+		}
+	default:
+		return nil, false
+	}
+
+	d, err := json.Marshal(e)
+	if err != nil {
+		return nil, false
+	}
+
+	return d, true
+}
+
+// rangedResponse reports whether a handler serves the response in byte
+// ranges, e.g. with http.ServeContent, which rewriting the body would break
+func rangedResponse(header http.Header) bool {
+	return header.Get("Accept-Ranges") == "bytes" || header.Get("Content-Range") != ""
+}
+
+// bufferedWriter captures the status and body written by a handler so
+// middleware can rewrite them before they reach the client.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// Write buffers the body
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveEnveloped(h http.HandlerFunc) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	NewEnvelope("v2")(h).ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	return rw
+}
+
+func TestEnvelopeWrapsJSONData(t *testing.T) {
+	rw := serveEnveloped(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`[{"id":1},{"id":2}]`))
+	})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	e := Envelope{}
+	err := json.Unmarshal(rw.Body.Bytes(), &e)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1},{"id":2}]`, string(e.Data))
+	assert.Equal(t, "v2", e.Meta.Version)
+	assert.Equal(t, 2, *e.Meta.Count)
+	assert.Empty(t, e.Errors)
+}
+
+func TestEnvelopeWrapsErrors(t *testing.T) {
+	rw := serveEnveloped(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+	})
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	e := Envelope{}
+	err := json.Unmarshal(rw.Body.Bytes(), &e)
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(e.Data))
+	assert.Len(t, e.Errors, 1)
+	assert.Equal(t, http.StatusNotFound, e.Errors[0].Status)
+	assert.Equal(t, "Coffee not found", e.Errors[0].Detail)
+}
+
+func TestEnvelopePassesThroughRangedResponses(t *testing.T) {
+	rw := serveEnveloped(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Accept-Ranges", "bytes")
+		rw.Write([]byte(`{"tenant":"default"}`))
+	})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, `{"tenant":"default"}`, rw.Body.String())
+}
+
+func TestEnvelopePassesThroughOtherMediaTypes(t *testing.T) {
+	rw := serveEnveloped(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		rw.Write([]byte{0x0a, 0x00})
+	})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, []byte{0x0a, 0x00}, rw.Body.Bytes())
+}
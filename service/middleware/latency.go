@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+// NewLatency returns middleware delaying requests by the rule of their route,
+// matched by its path template or the request path, and their repository
+// calls by the rules of the repository methods. The rules are read from
+// injector on every request, so they can be changed at runtime.
+func NewLatency(injector *latency.Injector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			targets := []string{r.URL.Path}
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					targets = []string{template, r.URL.Path}
+				}
+			}
+			injector.Wait(r.Context(), targets...)
+
+			next.ServeHTTP(rw, r.WithContext(data.WithLatency(r.Context(), injector)))
+		})
+	}
+}
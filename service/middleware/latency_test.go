@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/latency"
+)
+
+func TestLatencyDelaysRoutes(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	injector := latency.NewInjector(latency.Rules{"/coffees/{id:[0-9]+}": {Delay: 20 * time.Millisecond}})
+
+	router := mux.NewRouter()
+	router.Use(NewLatency(injector))
+	router.HandleFunc("/coffees/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		_, err := repository.FindByID(r.Context(), 1)
+		require.NoError(t, err)
+	})
+	router.HandleFunc("/coffees", func(rw http.ResponseWriter, r *http.Request) {
+		_, err := repository.Find(r.Context())
+		require.NoError(t, err)
+	})
+	elapsed := func(path string) time.Duration {
+		start := time.Now()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		return time.Since(start)
+	}
+
+	assert.GreaterOrEqual(t, int64(elapsed("/coffees/1")), int64(20*time.Millisecond))
+	assert.Less(t, int64(elapsed("/coffees")), int64(20*time.Millisecond))
+
+	// rules match the request path too, and repository methods
+	require.NoError(t, injector.SetRules(latency.Rules{"/coffees/2": {Delay: 20 * time.Millisecond}, "repository.Find": {Delay: 20 * time.Millisecond}}))
+	assert.Less(t, int64(elapsed("/coffees/1")), int64(20*time.Millisecond))
+	assert.GreaterOrEqual(t, int64(elapsed("/coffees/2")), int64(20*time.Millisecond))
+	assert.GreaterOrEqual(t, int64(elapsed("/coffees")), int64(20*time.Millisecond))
+}
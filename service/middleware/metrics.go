@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// NewMetrics returns middleware counting requests in http.requests and
+// recording their latency in http.request.duration, labelled with the route
+// template, method and status code
+func NewMetrics(sink metrics.Sink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+
+			labels := []metrics.Label{
+				{Name: "route", Value: routeTemplate(r)},
+				{Name: "method", Value: r.Method},
+				{Name: "status", Value: strconv.Itoa(sw.status)},
+			}
+			sink.IncrCounter("http.requests", 1, labels...)
+			metrics.MeasureSince(sink, "http.request.duration", start, labels...)
+		})
+	}
+}
+
+// routeTemplate returns the path template of the route matching r, so
+// requests to /coffees/1 and /coffees/2 are counted together
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// MirrorHeader marks the copies of requests sent to the shadow instance, which
+// does not mirror them again
+const MirrorHeader = "X-Mirrored"
+
+// maxMirrorBody is the largest request body copied to the shadow instance,
+// requests with larger bodies are not mirrored
+const maxMirrorBody = 1 << 20
+
+// maxMirrorInFlight is the most copies waiting for the shadow instance at
+// once, requests arriving while it is reached are not mirrored so a slow
+// shadow can not pile up goroutines
+const maxMirrorInFlight = 64
+
+// NewMirror returns middleware sending a copy of percent of the incoming
+// requests to the shadow instance at target with client, e.g. to try a new
+// version under real traffic. The copies keep the method, path, query,
+// headers and body of the requests and are sent in the background once the
+// request is served, their responses are discarded. They are counted in
+// mirror.requests labelled with their result: sent, failed or dropped.
+func NewMirror(target *url.URL, percent float64, client *http.Client, sink metrics.Sink, l hclog.Logger) func(http.Handler) http.Handler {
+	inFlight := make(chan struct{}, maxMirrorInFlight)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(MirrorHeader) != "" || (percent < 100 && rand.Float64()*100 >= percent) {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			// the handler consumes the body, so it is buffered to be replayed
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				buffered, err := ioutil.ReadAll(io.LimitReader(r.Body, maxMirrorBody+1))
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(buffered), r.Body), r.Body}
+				if err != nil || len(buffered) > maxMirrorBody {
+					sink.IncrCounter("mirror.requests", 1, metrics.Label{Name: "result", Value: "dropped"})
+					next.ServeHTTP(rw, r)
+					return
+				}
+				body = buffered
+			}
+			mirror, err := mirrorRequest(target, r, body)
+			next.ServeHTTP(rw, r)
+			if err != nil {
+				l.Error("Unable to mirror request", "path", r.URL.Path, "error", err)
+				sink.IncrCounter("mirror.requests", 1, metrics.Label{Name: "result", Value: "dropped"})
+				return
+			}
+
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				sink.IncrCounter("mirror.requests", 1, metrics.Label{Name: "result", Value: "dropped"})
+				return
+			}
+			go func() {
+				defer func() { <-inFlight }()
+
+				result := "sent"
+				resp, err := client.Do(mirror)
+				if err != nil {
+					l.Debug("Unable to send mirrored request", "url", mirror.URL.String(), "error", err)
+					result = "failed"
+				} else {
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				sink.IncrCounter("mirror.requests", 1, metrics.Label{Name: "result", Value: result})
+			}()
+		})
+	}
+}
+
+// mirrorRequest returns the copy of r sent to target. It is detached from the
+// context of r, which is cancelled once r is served.
+func mirrorRequest(target *url.URL, r *http.Request, body []byte) (*http.Request, error) {
+	u := *target
+	u.Path = strings.TrimSuffix(target.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	mirror, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	mirror.Header = r.Header.Clone()
+	mirror.Header.Set(MirrorHeader, "true")
+	return mirror, nil
+}
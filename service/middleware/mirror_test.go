@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// mirrored is a request received by the shadow instance
+type mirrored struct {
+	method, uri, body, auth, mark string
+}
+
+// setupShadow returns a shadow instance handing the requests it receives to
+// the returned channel
+func setupShadow(t *testing.T) (*url.URL, chan mirrored) {
+	received := make(chan mirrored, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- mirrored{r.Method, r.URL.RequestURI(), string(body), r.Header.Get("Authorization"), r.Header.Get(MirrorHeader)}
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+	t.Cleanup(shadow.Close)
+
+	target, err := url.Parse(shadow.URL + "/shadow")
+	require.NoError(t, err)
+	return target, received
+}
+
+func TestMirrorReplaysRequestsToTheShadow(t *testing.T) {
+	target, received := setupShadow(t)
+	handler := NewMirror(target, 100, http.DefaultClient, metrics.FanoutSink{}, hclog.NewNullLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		rw.Write(body)
+	}))
+
+	r := httptest.NewRequest("POST", "/coffees?dry_run=true", strings.NewReader(`{"name":"Mocha"}`))
+	r.Header.Set("Authorization", "Bearer token")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+
+	// the caller is served by the handler whatever the shadow answers
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, `{"name":"Mocha"}`, rw.Body.String())
+
+	select {
+	case m := <-received:
+		assert.Equal(t, mirrored{"POST", "/shadow/coffees?dry_run=true", `{"name":"Mocha"}`, "Bearer token", "true"}, m)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not mirrored")
+	}
+}
+
+func TestMirrorSkipsMirroredRequests(t *testing.T) {
+	target, received := setupShadow(t)
+	handler := NewMirror(target, 100, http.DefaultClient, metrics.FanoutSink{}, hclog.NewNullLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(MirrorHeader, "true")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	select {
+	case m := <-received:
+		t.Fatalf("mirrored request %v was mirrored again", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirrorDropsLargeBodies(t *testing.T) {
+	target, received := setupShadow(t)
+	sink := metrics.NewPrometheusSink()
+	var served int
+	handler := NewMirror(target, 100, http.DefaultClient, sink, hclog.NewNullLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		served = len(body)
+	}))
+
+	body := strings.Repeat("x", maxMirrorBody+1)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/coffees", strings.NewReader(body)))
+
+	// the handler still reads the whole body
+	assert.Equal(t, len(body), served)
+	scrape := httptest.NewRecorder()
+	sink.ServeHTTP(scrape, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, scrape.Body.String(), `coffee_service_mirror_requests_total{result="dropped"} 1`)
+	select {
+	case m := <-received:
+		t.Fatalf("request %s %s with a large body was mirrored", m.method, m.uri)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NewRateLimit returns middleware allowing perSecond requests every second,
+// with bursts of up to perSecond requests, across all clients. Requests over
+// the limit are rejected with 429 Too Many Requests.
+func NewRateLimit(perSecond int) func(http.Handler) http.Handler {
+	bucket := &tokenBucket{rate: float64(perSecond), capacity: float64(perSecond), tokens: float64(perSecond), now: time.Now}
+	bucket.last = bucket.now()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !bucket.take() {
+				rw.Header().Set("Retry-After", "1")
+				http.Error(rw, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// tokenBucket refills rate tokens every second up to capacity
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// take removes a token from the bucket, returning false when it is empty
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
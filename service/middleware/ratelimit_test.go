@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketRefills(t *testing.T) {
+	now := time.Now()
+	b := &tokenBucket{rate: 2, capacity: 2, tokens: 2, last: now, now: func() time.Time { return now }}
+
+	assert.True(t, b.take())
+	assert.True(t, b.take())
+	assert.False(t, b.take())
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, b.take())
+	assert.False(t, b.take())
+
+	// never refills past the capacity
+	now = now.Add(time.Hour)
+	assert.True(t, b.take())
+	assert.True(t, b.take())
+	assert.False(t, b.take())
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/crashreport"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// maxPanicFrames bounds the stack reported for a panic
+const maxPanicFrames = 64
+
+// CrashReporter receives the reports of recovered panics, e.g. a
+// crashreport.Reporter
+type CrashReporter interface {
+	Report(report crashreport.Report)
+}
+
+// problem is an RFC 7807 problem details response
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+}
+
+// NewRecovery returns middleware recovering the panics of handlers. A panic
+// is logged with its stack and the request, counted in http.panics and sent
+// to reporter when it is not nil, and the request fails with a 500
+// application/problem+json response. When the handler already started its
+// response the connection is aborted instead, so the client does not take a
+// partial response for a complete one.
+func NewRecovery(l hclog.Logger, sink metrics.Sink, reporter CrashReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				route := routeTemplate(r)
+				requestID, traceID := RequestID(r.Context()), TraceID(r.Context())
+				l.Error("Handler panicked", "panic", p, "method", r.Method, "url", r.URL.String(), "route", route, "request_id", requestID, "trace_id", traceID, "stack", string(debug.Stack()))
+				sink.IncrCounter("http.panics", 1, metrics.Label{Name: "route", Value: route}, metrics.Label{Name: "method", Value: r.Method})
+				if reporter != nil {
+					reporter.Report(crashreport.Report{
+						Time:      time.Now(),
+						Type:      fmt.Sprintf("%T", p),
+						Value:     fmt.Sprint(p),
+						Frames:    panicFrames(),
+						Method:    r.Method,
+						URL:       r.URL.String(),
+						Route:     route,
+						RequestID: requestID,
+						TraceID:   traceID,
+					})
+				}
+
+				if sw.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				body, _ := json.Marshal(problem{
+					Type:      "about:blank",
+					Title:     http.StatusText(http.StatusInternalServerError),
+					Status:    http.StatusInternalServerError,
+					Detail:    "The request failed unexpectedly",
+					Instance:  r.URL.Path,
+					RequestID: requestID,
+					TraceID:   traceID,
+				})
+				rw.Header().Del("Content-Length")
+				rw.Header().Set("Content-Type", "application/problem+json")
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write(body)
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// panicFrames returns the stack of the panic being recovered, from the
+// function which panicked outwards, without the runtime functions raising
+// it. It must be called by the deferred function recovering the panic.
+func panicFrames() []crashreport.Frame {
+	pcs := make([]uintptr, maxPanicFrames)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []crashreport.Frame
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		if panicking && (len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.")) {
+			stack = append(stack, crashreport.Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		} else if strings.HasPrefix(frame.Function, "runtime.gopanic") {
+			panicking = true
+		}
+		if !more {
+			return stack
+		}
+	}
+}
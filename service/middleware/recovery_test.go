@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/crashreport"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
+)
+
+// reports records the crash reports it receives
+type reports []crashreport.Report
+
+func (r *reports) Report(report crashreport.Report) {
+	*r = append(*r, report)
+}
+
+func panickingHandler(rw http.ResponseWriter, r *http.Request) {
+	var coffees map[int]string
+	coffees[1] = "Vaulatte"
+}
+
+func TestRecoveryRespondsWithAProblem(t *testing.T) {
+	sink := metrics.NewPrometheusSink()
+	received := &reports{}
+	handler := NewRequestID()(NewRecovery(hclog.NewNullLogger(), sink, received)(http.HandlerFunc(panickingHandler)))
+
+	r := httptest.NewRequest("GET", "/coffees/1", nil)
+	r.Header.Set(RequestIDHeader, "curl-42")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Equal(t, "application/problem+json", rw.Header().Get("Content-Type"))
+	p := problem{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &p))
+	assert.Equal(t, problem{
+		Type:      "about:blank",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "The request failed unexpectedly",
+		Instance:  "/coffees/1",
+		RequestID: "curl-42",
+	}, p)
+
+	scrape := httptest.NewRecorder()
+	sink.ServeHTTP(scrape, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, scrape.Body.String(), `coffee_service_http_panics_total{method="GET",route="unknown"} 1`)
+
+	require.Len(t, *received, 1)
+	report := (*received)[0]
+	assert.Equal(t, "runtime.plainError", report.Type)
+	assert.Equal(t, "assignment to entry in nil map", report.Value)
+	assert.Equal(t, "curl-42", report.RequestID)
+	require.NotEmpty(t, report.Frames)
+	assert.Equal(t, "github.com/hashicorp-demoapp/coffee-service/service/middleware.panickingHandler", report.Frames[0].Function)
+}
+
+func TestRecoveryAbortsStartedResponses(t *testing.T) {
+	handler := NewRecovery(hclog.NewNullLogger(), metrics.FanoutSink{}, nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte(`[{"id":1}`))
+		panic("encoder failed")
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+	})
+}
+
+func TestRecoveryPassesResponsesThrough(t *testing.T) {
+	received := &reports{}
+	handler := NewRecovery(hclog.NewNullLogger(), metrics.FanoutSink{}, received)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+	assert.Equal(t, http.StatusTeapot, rw.Code)
+	assert.Empty(t, *received)
+}
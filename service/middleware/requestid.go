@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader carries the ID of a request, propagated from the client
+// when it sets one and returned with every response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID propagated from a client,
+// longer ones are replaced
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the request and trace IDs
+type requestIDKey struct{}
+
+// requestIDs are the IDs correlating a request with its logs and traces
+type requestIDs struct {
+	request string
+	trace   string
+}
+
+// NewRequestID returns middleware identifying every request by the
+// X-Request-ID it was sent with, or a random ID when it has none, and
+// returning the ID in the response header. The request ID and the trace ID
+// propagated in the request headers are available through RequestID and
+// TraceID. Plain text error responses, e.g. from http.Error, end with both IDs
+// so a failed request can be correlated with the logs and traces.
+func NewRequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ids := requestIDs{request: r.Header.Get(RequestIDHeader), trace: traceID(r.Header)}
+			if !validRequestID(ids.request) {
+				ids.request = newRequestID()
+			}
+			rw.Header().Set(RequestIDHeader, ids.request)
+
+			ew := &errorWriter{ResponseWriter: rw}
+			next.ServeHTTP(ew, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, ids)))
+
+			if ew.plainError {
+				fmt.Fprintf(rw, "request_id: %s\n", ids.request)
+				if ids.trace != "" {
+					fmt.Fprintf(rw, "trace_id: %s\n", ids.trace)
+				}
+			}
+		})
+	}
+}
+
+// RequestID returns the ID of the request of ctx, empty outside of the
+// request ID middleware
+func RequestID(ctx context.Context) string {
+	ids, _ := ctx.Value(requestIDKey{}).(requestIDs)
+	return ids.request
+}
+
+// TraceID returns the trace ID propagated with the request of ctx, empty when
+// the request is not traced
+func TraceID(ctx context.Context) string {
+	ids, _ := ctx.Value(requestIDKey{}).(requestIDs)
+	return ids.trace
+}
+
+// validRequestID reports whether a request ID sent by a client is safe to
+// echo in headers, bodies and logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune("-_.:", c) && (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128 bit request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorWriter records whether a handler sent a plain text error response,
+// whose length is no longer known once the IDs are appended
+type errorWriter struct {
+	http.ResponseWriter
+	plainError  bool
+	wroteHeader bool
+}
+
+// WriteHeader records whether the response is a plain text error and sends
+// the status code
+func (w *errorWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status >= http.StatusBadRequest && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+			w.plainError = true
+			w.Header().Del("Content-Length")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the body
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
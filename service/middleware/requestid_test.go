@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveWithRequestID(r *http.Request, h http.HandlerFunc) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	NewRequestID()(h).ServeHTTP(rw, r)
+	return rw
+}
+
+func TestRequestIDIsGeneratedWhenMissing(t *testing.T) {
+	var id string
+	rw := serveWithRequestID(httptest.NewRequest("GET", "/coffees", nil), func(rw http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+	})
+
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{32}$`), id)
+	assert.Equal(t, id, rw.Header().Get(RequestIDHeader))
+}
+
+func TestRequestIDIsPropagatedWithTheTraceID(t *testing.T) {
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(RequestIDHeader, "curl-42")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	var id, trace string
+	rw := serveWithRequestID(r, func(rw http.ResponseWriter, r *http.Request) {
+		id, trace = RequestID(r.Context()), TraceID(r.Context())
+	})
+
+	assert.Equal(t, "curl-42", id)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace)
+	assert.Equal(t, "curl-42", rw.Header().Get(RequestIDHeader))
+}
+
+func TestRequestIDReplacesUnsafeIDs(t *testing.T) {
+	for _, unsafe := range []string{"a b", "<script>", strings.Repeat("a", maxRequestIDLength+1)} {
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(RequestIDHeader, unsafe)
+
+		rw := serveWithRequestID(r, func(rw http.ResponseWriter, r *http.Request) {})
+
+		assert.NotEqual(t, unsafe, rw.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestIDEndsPlainTextErrorsWithTheIDs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(RequestIDHeader, "curl-42")
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rw := serveWithRequestID(r, func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Equal(t, "Unable to get coffees from database\nrequest_id: curl-42\ntrace_id: 4bf92f3577b34da6a3ce929d0e0e4736\n", rw.Body.String())
+}
+
+func TestRequestIDLeavesOtherResponsesUntouched(t *testing.T) {
+	rw := serveWithRequestID(httptest.NewRequest("GET", "/coffees", nil), func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusConflict)
+		rw.Write([]byte(`{"error":"duplicate"}`))
+	})
+	assert.Equal(t, `{"error":"duplicate"}`, rw.Body.String())
+
+	rw = serveWithRequestID(httptest.NewRequest("GET", "/coffees", nil), func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte("ok"))
+	})
+	assert.Equal(t, "ok", rw.Body.String())
+}
+
+func TestRequestIDIsAddedToEnvelopeErrors(t *testing.T) {
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(RequestIDHeader, "curl-42")
+
+	rw := serveWithRequestID(r, NewEnvelope("v2")(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+	})).ServeHTTP)
+
+	e := Envelope{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &e))
+	require.Len(t, e.Errors, 1)
+	assert.Equal(t, "Coffee not found", e.Errors[0].Detail)
+	assert.Equal(t, "curl-42", e.Errors[0].RequestID)
+	assert.Empty(t, e.Errors[0].TraceID)
+}
+
+func TestAccessLogJSONFormatIncludesTheRequestID(t *testing.T) {
+	var log strings.Builder
+	handler := NewAccessLog(JSONFormat, &log)(NewRequestID()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})))
+
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(RequestIDHeader, "curl-42")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(log.String()), &entry))
+	assert.Equal(t, "curl-42", entry["request_id"])
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// SessionHeader carries the session token handed out after a write, reads
+// passing it back see that write
+const SessionHeader = "X-Session-Token"
+
+// SessionRoutedHeader marks a read a replica routed to the primary because it
+// had not applied the write of its session token yet. The primary serves it
+// locally whatever its token.
+const SessionRoutedHeader = "X-Session-Routed"
+
+// SessionToken is the position of the latest write of a client: the time of
+// the write and, with Raft, its index in the log
+type SessionToken struct {
+	Time  time.Time
+	Index uint64
+}
+
+// String encodes the token as <unix nanoseconds>.<index>
+func (t SessionToken) String() string {
+	return strconv.FormatInt(t.Time.UnixNano(), 10) + "." + strconv.FormatUint(t.Index, 10)
+}
+
+// ParseSessionToken decodes a token encoded by SessionToken.String
+func ParseSessionToken(value string) (SessionToken, error) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return SessionToken{}, fmt.Errorf("invalid session token %q", value)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return SessionToken{}, fmt.Errorf("invalid session token %q", value)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return SessionToken{}, fmt.Errorf("invalid session token %q", value)
+	}
+	return SessionToken{Time: time.Unix(0, nanos), Index: index}, nil
+}
+
+// sessionToken returns the session token of a request, false without a
+// valid one
+func sessionToken(r *http.Request) (SessionToken, bool) {
+	value := r.Header.Get(SessionHeader)
+	if value == "" {
+		return SessionToken{}, false
+	}
+	token, err := ParseSessionToken(value)
+	return token, err == nil
+}
+
+// Replica is a copy of the catalogue which may lag behind its primary, e.g. a
+// data.RaftRepository
+type Replica interface {
+	// AppliedIndex returns the index of the last write visible locally
+	AppliedIndex() uint64
+	// Primary returns the HTTP base URL of the primary, false when this
+	// replica is the primary or none is known
+	Primary() (string, bool)
+}
+
+// NewSession returns middleware giving clients read-your-writes consistency.
+// Successful writes answer with a session token in X-Session-Token, which
+// clients send on their later reads:
+//
+//   - a replica which has not applied the write of the token yet proxies the
+//     read to its primary with transport, when replica is not nil
+//   - the response cache skips the responses stored before the write, see
+//     NewCache
+//
+// Reads passing an invalid token fail with 400.
+func NewSession(replica Replica, transport http.RoundTripper, l hclog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				ctx, session := data.WithSession(r.Context())
+				next.ServeHTTP(&sessionWriter{ResponseWriter: rw, session: session}, r.WithContext(ctx))
+				return
+			}
+
+			if r.Header.Get(SessionHeader) == "" {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			token, err := ParseSessionToken(r.Header.Get(SessionHeader))
+			if err != nil {
+				http.Error(rw, "Invalid session token", http.StatusBadRequest)
+				return
+			}
+
+			if replica == nil || r.Header.Get(SessionRoutedHeader) != "" || replica.AppliedIndex() >= token.Index {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			primary, ok := replica.Primary()
+			if !ok {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			target, err := url.Parse(primary)
+			if err != nil {
+				l.Error("Invalid primary address", "address", primary, "error", err)
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			l.Debug("Routing read to the primary", "primary", primary, "index", token.Index, "applied", replica.AppliedIndex())
+			proxy := httputil.NewSingleHostReverseProxy(target)
+			proxy.Transport = transport
+			r = r.Clone(r.Context())
+			r.Header.Set(SessionRoutedHeader, "primary")
+			rw.Header().Set(SessionRoutedHeader, "primary")
+			proxy.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// sessionWriter adds the session token to successful writes just before the
+// response headers are sent
+type sessionWriter struct {
+	http.ResponseWriter
+	session     *data.Session
+	wroteHeader bool
+}
+
+// WriteHeader sets the session header and sends the status code
+func (w *sessionWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if status < http.StatusMultipleChoices {
+		w.Header().Set(SessionHeader, SessionToken{Time: time.Now(), Index: w.session.Index()}.String())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the headers if the handler has not done so yet
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReplica has applied the log up to applied and follows primary
+type fakeReplica struct {
+	applied uint64
+	primary string
+}
+
+func (f *fakeReplica) AppliedIndex() uint64 { return f.applied }
+
+func (f *fakeReplica) Primary() (string, bool) { return f.primary, f.primary != "" }
+
+func TestSessionTokenRoundTrip(t *testing.T) {
+	token := SessionToken{Time: time.Unix(1700000000, 42), Index: 7}
+
+	parsed, err := ParseSessionToken(token.String())
+	require.NoError(t, err)
+	assert.True(t, token.Time.Equal(parsed.Time))
+	assert.Equal(t, token.Index, parsed.Index)
+
+	for _, invalid := range []string{"", "42", "x.1", "1.x", "1.-1"} {
+		_, err := ParseSessionToken(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSessionHandsOutTokensForSuccessfulWrites(t *testing.T) {
+	status := http.StatusCreated
+	handler := NewSession(nil, nil, hclog.NewNullLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(status)
+	}))
+
+	before := time.Now()
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", nil))
+	token, err := ParseSessionToken(rw.Header().Get(SessionHeader))
+	require.NoError(t, err)
+	assert.False(t, token.Time.Before(before))
+	assert.Zero(t, token.Index)
+
+	status = http.StatusBadRequest
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", nil))
+	assert.Empty(t, rw.Header().Get(SessionHeader))
+
+	// reads hand out no token
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Empty(t, rw.Header().Get(SessionHeader))
+}
+
+func TestSessionRoutesReadsOfALaggingReplicaToThePrimary(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "primary", r.Header.Get(SessionRoutedHeader))
+		rw.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	replica := &fakeReplica{applied: 5, primary: primary.URL}
+	handler := NewSession(replica, http.DefaultTransport, hclog.NewNullLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("replica"))
+	}))
+
+	get := func(token string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(SessionHeader, token)
+		for n := 0; n < len(headers); n += 2 {
+			r.Header.Set(headers[n], headers[n+1])
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		return rw
+	}
+
+	rw := get(SessionToken{Time: time.Now(), Index: 6}.String())
+	assert.Equal(t, "primary", rw.Body.String())
+	assert.Equal(t, "primary", rw.Header().Get(SessionRoutedHeader))
+
+	assert.Equal(t, "replica", get(SessionToken{Time: time.Now(), Index: 5}.String()).Body.String())
+	assert.Equal(t, "replica", get("").Body.String())
+	// routed reads are never routed again
+	assert.Equal(t, "replica", get(SessionToken{Time: time.Now(), Index: 6}.String(), SessionRoutedHeader, "primary").Body.String())
+
+	// the primary serves its own reads
+	replica.primary = ""
+	assert.Equal(t, "replica", get(SessionToken{Time: time.Now(), Index: 6}.String()).Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get("invalid").Code)
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/slo"
+)
+
+// NewSLO returns middleware recording every request in tracker, by method
+// and route template, e.g. GET /coffees/{id:[0-9]+}
+func NewSLO(tracker *slo.Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+
+			tracker.Record(r.Method+" "+routeTemplate(r), sw.status, time.Since(start))
+		})
+	}
+}
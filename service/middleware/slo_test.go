@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/slo"
+)
+
+func TestSLORecordsRouteTemplate(t *testing.T) {
+	tracker := slo.NewTracker(slo.Objectives{Availability: 99, Latency: time.Second, LatencyTarget: 99}, time.Hour)
+
+	router := mux.NewRouter()
+	router.Use(NewSLO(tracker))
+	router.HandleFunc("/coffees/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "2" {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees/2", nil))
+
+	report := tracker.Report()
+	require.Len(t, report.Endpoints, 1)
+	assert.Equal(t, "GET /coffees/{id:[0-9]+}", report.Endpoints[0].Endpoint)
+	assert.Equal(t, uint64(2), report.Endpoints[0].Requests)
+	assert.InDelta(t, 50, report.Endpoints[0].Availability.SLI, 0.001)
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// NewSlowQueries returns middleware keeping the repository calls made while
+// serving a request which exceed the threshold of log. The calls carry the
+// trace ID of the span of the request, or the one propagated in its headers
+// when it is not traced, so they can be found in the tracing backend.
+func NewSlowQueries(log *data.SlowQueryLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx := data.WithSlowQueryLog(r.Context(), log, func(ctx context.Context) string {
+				return spanTraceID(ctx, r.Header)
+			})
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// spanTraceID returns the trace ID of the span in ctx, injected the way it
+// is propagated downstream, or the one propagated in header
+func spanTraceID(ctx context.Context, header http.Header) string {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		carrier := http.Header{}
+		if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(carrier)); err == nil {
+			if id := traceID(carrier); id != "" {
+				return id
+			}
+		}
+	}
+	return traceID(header)
+}
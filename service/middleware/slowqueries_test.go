@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func TestSlowQueriesCarryTheTraceID(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	log := data.NewSlowQueryLog(0, 10)
+	h := NewSlowQueries(log)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, err := repository.FindByID(r.Context(), 1)
+		require.NoError(t, err)
+	}))
+
+	for header, value := range map[string]string{
+		"traceparent":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"uber-trace-id": "4bf92f3577b34da6a3ce929d0e0e4736%3A00f067aa0ba902b7%3A0%3A1",
+	} {
+		log.Reset()
+		r := httptest.NewRequest("GET", "/coffees/1", nil)
+		r.Header.Set(header, value)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		queries := log.Queries()
+		require.NotEmpty(t, queries, header)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", queries[0].TraceID, header)
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// SnapshotHeader carries the token of the in memory snapshot a list read was
+// served from, requests passing it back are served from the same snapshot
+const SnapshotHeader = "X-Snapshot-Token"
+
+// NewSnapshot returns middleware serving the reads of a request from the in
+// memory snapshot named by its X-Snapshot-Token header. Without the header a
+// list read pins a new snapshot and reports its token in the same header.
+// Requests passing a released token fail with 410 Gone, so a client never
+// mixes reads of different snapshots.
+func NewSnapshot() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx, snapshot := data.WithReadSnapshot(r.Context(), r.Header.Get(SnapshotHeader))
+			next.ServeHTTP(&snapshotWriter{ResponseWriter: rw, snapshot: snapshot}, r.WithContext(ctx))
+		})
+	}
+}
+
+// snapshotWriter adds the snapshot token just before the response headers
+// are sent, or replaces the response once the snapshot expired
+type snapshotWriter struct {
+	http.ResponseWriter
+	snapshot    *data.ReadSnapshot
+	wroteHeader bool
+	expired     bool
+}
+
+// WriteHeader sets the snapshot header and sends the status code
+func (w *snapshotWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.snapshot.Expired() {
+		w.expired = true
+		http.Error(w.ResponseWriter, "Snapshot expired, list the coffees again", http.StatusGone)
+		return
+	}
+	if token := w.snapshot.Token(); token != "" {
+		w.Header().Set(SnapshotHeader, token)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the headers if the handler has not done so yet, the body of a
+// read from an expired snapshot is dropped
+func (w *snapshotWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.expired {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func TestSnapshotServesReadsFromThePinnedSnapshot(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), SnapshotTTL: time.Minute})
+	require.NoError(t, err)
+
+	handler := NewSnapshot()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var err error
+		if r.URL.Path == "/coffees" {
+			_, err = repository.Find(r.Context())
+		} else {
+			_, err = repository.FindByID(r.Context(), 2)
+		}
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Write([]byte("ok"))
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	token := rw.Header().Get(SnapshotHeader)
+	require.NotEmpty(t, token)
+
+	require.NoError(t, repository.DeleteCoffee(context.Background(), 2))
+
+	r := httptest.NewRequest("GET", "/coffees/2", nil)
+	r.Header.Set(SnapshotHeader, token)
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, token, rw.Header().Get(SnapshotHeader))
+
+	// detail reads do not pin
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/2", nil))
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Empty(t, rw.Header().Get(SnapshotHeader))
+
+	r = httptest.NewRequest("GET", "/coffees/2", nil)
+	r.Header.Set(SnapshotHeader, "released")
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusGone, rw.Code)
+	assert.Equal(t, "Snapshot expired, list the coffees again\n", rw.Body.String())
+}
+
+func TestSnapshotIgnoresRepositoriesWithoutSnapshots(t *testing.T) {
+	handler := NewSnapshot()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, rw.Header().Get(SnapshotHeader))
+	assert.Equal(t, "ok", rw.Body.String())
+}
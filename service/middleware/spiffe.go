@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hashicorp-demoapp/coffee-service/spiffe"
+)
+
+// NewPeerIdentity returns middleware attaching the SPIFFE ID of the client
+// certificate to the request context, see spiffe.FromContext. Requests of
+// clients without an SVID pass without one.
+func NewPeerIdentity() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if id, ok := spiffe.FromRequest(r); ok {
+				r = r.WithContext(spiffe.NewContext(r.Context(), id))
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// NewSPIFFE returns middleware rejecting requests of peers without a SPIFFE
+// ID with 401, and of peers whose ID matches none of the allowed patterns with
+// 403. NewPeerIdentity must run first.
+func NewSPIFFE(allowed []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			id, ok := spiffe.FromContext(r.Context())
+			if !ok {
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			for _, pattern := range allowed {
+				if id.Matches(pattern) {
+					next.ServeHTTP(rw, r)
+					return
+				}
+			}
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/spiffe"
+)
+
+// peerRequest is a request of a client which presented an SVID for id
+func peerRequest(id string) *http.Request {
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	uri, _ := url.Parse(id)
+	leaf := &x509.Certificate{URIs: []*url.URL{uri}, KeyUsage: x509.KeyUsageDigitalSignature}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+	return r
+}
+
+func TestPeerIdentityAttachesTheSPIFFEID(t *testing.T) {
+	var got spiffe.ID
+	handler := NewPeerIdentity()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got, _ = spiffe.FromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), peerRequest("spiffe://example.org/ns/default/sa/web"))
+	assert.Equal(t, "spiffe://example.org/ns/default/sa/web", got.String())
+}
+
+func TestSPIFFEAuthorizesAllowedIDs(t *testing.T) {
+	handler := NewPeerIdentity()(NewSPIFFE([]string{"spiffe://example.org/ns/default/sa/*"})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, peerRequest("spiffe://example.org/ns/default/sa/web"))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, peerRequest("spiffe://example.org/ns/payments/sa/web"))
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// StoreHeader names the store whose menu a request reads
+const StoreHeader = "X-Store"
+
+// NewStore returns middleware limiting the menu reads of a request to the
+// store named by its X-Store header, see data.WithStore. Requests without the
+// header read the whole menu, and a header which is not a store ID is
+// rejected with 400.
+func NewStore() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Add("Vary", StoreHeader)
+
+			raw := r.Header.Get(StoreHeader)
+			if raw == "" {
+				next.ServeHTTP(rw, r)
+				return
+			}
+			storeID, err := strconv.Atoi(raw)
+			if err != nil || storeID < 1 {
+				http.Error(rw, "X-Store must be a store ID", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(rw, r.WithContext(data.WithStore(r.Context(), storeID)))
+		})
+	}
+}
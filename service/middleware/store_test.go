@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func TestStoreScopesTheContext(t *testing.T) {
+	var storeID int
+	var scoped bool
+	handler := NewStore()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		storeID, scoped = data.StoreFromContext(r.Context())
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+	assert.False(t, scoped)
+	assert.Equal(t, StoreHeader, rw.Header().Get("Vary"))
+
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(StoreHeader, "2")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, scoped)
+	assert.Equal(t, 2, storeID)
+}
+
+func TestStoreRejectsInvalidHeaders(t *testing.T) {
+	handler := NewStore()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called")
+	}))
+
+	for _, header := range []string{"market-street", "0", "-1"} {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(StoreHeader, header)
+		handler.ServeHTTP(rw, r)
+		assert.Equal(t, http.StatusBadRequest, rw.Code, header)
+	}
+}
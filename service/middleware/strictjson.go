@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// strictJSONKey marks the context of the requests whose payloads are decoded
+// strictly
+type strictJSONKey struct{}
+
+// NewStrictJSON returns middleware marking every request so its JSON payload
+// is decoded strictly, see StrictJSON
+func NewStrictJSON() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), strictJSONKey{}, true)))
+		})
+	}
+}
+
+// StrictJSON reports whether the payload of the request of ctx must be
+// rejected when it holds fields the handler does not know
+func StrictJSON(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictJSONKey{}).(bool)
+	return strict
+}
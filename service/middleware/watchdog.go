@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// WatchdogInterval is how often the watchdog looks for stuck requests
+const WatchdogInterval = time.Second
+
+// maxStackDump bounds the size of the goroutine dump logged by the watchdog
+const maxStackDump = 8 << 20
+
+// Watchdog tracks the requests in flight and marks the service as no longer
+// live once a request has been running for longer than a hard limit, e.g.
+// because its handler deadlocked. The service stays failed so the
+// orchestrator restarts it.
+type Watchdog struct {
+	limit  time.Duration
+	logger hclog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	next     uint64
+	inflight map[uint64]inflightRequest
+
+	stuck int32
+}
+
+// inflightRequest is a request the watchdog is waiting on
+type inflightRequest struct {
+	method  string
+	path    string
+	started time.Time
+}
+
+// NewWatchdog creates a Watchdog failing once a request runs for longer
+// than limit
+func NewWatchdog(limit time.Duration, l hclog.Logger) *Watchdog {
+	return &Watchdog{
+		limit:    limit,
+		logger:   l,
+		now:      time.Now,
+		inflight: map[uint64]inflightRequest{},
+	}
+}
+
+// Middleware returns middleware registering every request with the watchdog
+// for as long as its handler runs
+func (w *Watchdog) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			id := w.start(r)
+			defer w.finish(id)
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Live reports false once a request exceeded the limit
+func (w *Watchdog) Live() bool {
+	return atomic.LoadInt32(&w.stuck) == 0
+}
+
+// Run checks for stuck requests every WatchdogInterval until done is closed
+func (w *Watchdog) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(WatchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// start registers a request and returns its ID
+func (w *Watchdog) start(r *http.Request) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.next++
+	w.inflight[w.next] = inflightRequest{method: r.Method, path: r.URL.Path, started: w.now()}
+	return w.next
+}
+
+// finish removes a request once its handler returned
+func (w *Watchdog) finish(id uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inflight, id)
+}
+
+// check fails the watchdog when any request exceeded the limit, logging the
+// stuck requests and a dump of every goroutine the first time
+func (w *Watchdog) check() {
+	if !w.Live() {
+		return
+	}
+
+	now := w.now()
+	stuck := make([]inflightRequest, 0)
+
+	w.mu.Lock()
+	for _, r := range w.inflight {
+		if now.Sub(r.started) > w.limit {
+			stuck = append(stuck, r)
+		}
+	}
+	w.mu.Unlock()
+
+	if len(stuck) == 0 || !atomic.CompareAndSwapInt32(&w.stuck, 0, 1) {
+		return
+	}
+
+	for _, r := range stuck {
+		w.logger.Error("Request exceeded the watchdog limit", "method", r.method, "path", r.path, "elapsed", now.Sub(r.started), "limit", w.limit)
+	}
+	w.logger.Error("Liveness failed, dumping goroutines", "stacks", stackDump())
+}
+
+// stackDump returns the stack traces of every goroutine
+func stackDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDump {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWatchdog(now *time.Time) *Watchdog {
+	w := NewWatchdog(10*time.Second, hclog.NewNullLogger())
+	w.now = func() time.Time { return *now }
+	return w
+}
+
+func TestWatchdogIgnoresCompletedRequests(t *testing.T) {
+	now := time.Now()
+	w := setupWatchdog(&now)
+
+	handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		now = now.Add(time.Minute)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+
+	w.check()
+	assert.True(t, w.Live())
+}
+
+func TestWatchdogFailsOnStuckRequest(t *testing.T) {
+	now := time.Now()
+	w := setupWatchdog(&now)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+	<-started
+
+	now = now.Add(5 * time.Second)
+	w.check()
+	assert.True(t, w.Live())
+
+	now = now.Add(10 * time.Second)
+	w.check()
+	assert.False(t, w.Live())
+
+	// the service stays failed once the request completes
+	close(release)
+	w.check()
+	assert.False(t, w.Live())
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/data/queue"
+	"github.com/hashicorp-demoapp/coffee-service/notifications"
+	"github.com/hashicorp-demoapp/coffee-service/payments"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+)
+
+// PaymentIDHeader carries the ID of the payment of a created order
+const PaymentIDHeader = "X-Payment-ID"
+
+var (
+	// errPaymentRequired is returned for an order without a card while
+	// orders are paid
+	errPaymentRequired = errors.New("payment card required")
+	// errPaymentFailed wraps the failures to reach the payments service, as
+	// opposed to a declined payment
+	errPaymentFailed = errors.New("payment failed")
+	// errPointsFailed wraps the failures to record loyalty points, as
+	// opposed to an insufficient balance
+	errPointsFailed = errors.New("unable to redeem loyalty points")
+)
+
+// orderRequest is the body creating an order paid with a card, the items
+// alone are accepted while orders are not paid. The email address receives
+// the confirmation of the order, the coupon and the loyalty points discount
+// it.
+type orderRequest struct {
+	Items   []productapi.OrderItem `json:"items"`
+	Payment *payments.Card         `json:"payment"`
+	Email   string                 `json:"email"`
+	Coupon  string                 `json:"coupon"`
+	Points  int                    `json:"points"`
+}
+
+// OrdersService is an HTTP Handler delegating orders to the product-api,
+// while coffees are served locally. The Authorization header is passed on
+// as the product-api authenticates its users itself. Every item of a created
+// order counts as an order of its coffee, and joins the barista queue with
+// its total quantity, and its confirmation is sent in the background.
+//
+// With a payments client, creating an order is a saga: the order is created
+// in the product-api, then its payment is authorized, and the order is
+// cancelled again when the payment fails.
+//
+// A coupon is redeemed before the order is created, counting a use of it in
+// the repository, and released again when the order fails. As the product-api
+// knows nothing of coupons the discount is only returned with the order and
+// taken off the total of its confirmation.
+//
+// Every created order is recorded in the repository for the admin
+// statistics, as cancelled when its saga failed.
+//
+// With loyalty points, the user identified by the token earns points on every
+// paid order and redeems them as a discount. The points redeemed are debited
+// from the ledger once the order is created, in a single transaction which
+// fails on an insufficient balance, and credited back when the order is then
+// cancelled. The points earned are credited once the order is paid. Every
+// entry of the ledger carries the ID of its order.
+type OrdersService struct {
+	client     *productapi.Client
+	repository data.Repository
+	payments   *payments.Client
+	popularity *popularity.Tracker
+	queue      *queue.Simulator
+	dispatcher *notifications.Dispatcher
+	loyalty    *Loyalty
+	logger     hclog.Logger
+	now        func() time.Time
+}
+
+// NewOrders creates a new Orders handler, payer is nil when orders are not
+// paid, simulator is nil when the barista queue is disabled and dispatcher is
+// nil when no confirmations are sent and loyalty is nil without loyalty points
+func NewOrders(client *productapi.Client, repository data.Repository, payer *payments.Client, tracker *popularity.Tracker, simulator *queue.Simulator, dispatcher *notifications.Dispatcher, loyalty *Loyalty, l hclog.Logger) *OrdersService {
+	return &OrdersService{client, repository, payer, tracker, simulator, dispatcher, loyalty, l, time.Now}
+}
+
+// ServeHTTP handles incoming requests for the api orders routes
+func (s *OrdersService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Orders", "method", r.Method)
+
+	token := r.Header.Get("Authorization")
+	var result interface{}
+	var err error
+
+	switch {
+	case r.Method == http.MethodPost:
+		request := orderRequest{}
+		if err := decodeOrder(r, &request); err != nil {
+			invalidPayload(rw, "Invalid order", err)
+			return
+		}
+		if s.payments != nil && request.Payment == nil {
+			http.Error(rw, "Invalid order, "+errPaymentRequired.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Points < 0 {
+			http.Error(rw, "Invalid order, points must not be negative", http.StatusBadRequest)
+			return
+		}
+		userID := 0
+		if s.loyalty != nil {
+			// a user without a valid token earns no points
+			if userID, err = s.loyalty.userID(r); err != nil && request.Points > 0 {
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		} else if request.Points > 0 {
+			http.Error(rw, "Invalid order, loyalty points are disabled", http.StatusBadRequest)
+			return
+		}
+		var coupon *entities.Coupon
+		if request.Coupon != "" {
+			coupon, err = data.RedeemCoupon(r.Context(), s.repository, request.Coupon, s.now())
+			if !s.writeCouponError(rw, err) {
+				return
+			}
+		}
+		var order *productapi.Order
+		if order, err = s.client.CreateOrder(r.Context(), token, request.Items); err == nil {
+			if coupon != nil {
+				order.Coupon = coupon.Code
+				order.Discount = coupon.Discount(subtotal(order))
+			}
+			// the points are debited with the record of the order, and
+			// credited with the record of its outcome
+			if err = s.recordOrder(r.Context(), order, entities.OrderCreated, s.redeemPoints(order, userID, request.Points)); err == nil {
+				err = s.pay(r.Context(), rw, order, request.Payment)
+				s.settleOrder(r.Context(), order, userID, err)
+			} else {
+				s.recordOrder(r.Context(), order, entities.OrderCancelled, nil)
+			}
+			if err != nil {
+				s.cancel(r.Context(), token, order, err)
+			}
+		}
+		if err != nil && coupon != nil {
+			s.releaseCoupon(r.Context(), coupon.Code)
+		}
+		if err == nil {
+			if coupon != nil {
+				s.logger.Info("Coupon redeemed", "order_id", order.ID, "code", coupon.Code)
+			}
+			confirmation := notifications.Confirmation{OrderID: order.ID, Email: request.Email, PaymentID: rw.Header().Get(PaymentIDHeader)}
+			quantity := 0
+			for _, item := range order.Items {
+				s.popularity.RecordOrder(item.Coffee.ID)
+				quantity += item.Quantity
+				confirmation.Items = append(confirmation.Items, notifications.Item{Name: item.Coffee.Name, Quantity: item.Quantity, Price: item.Coffee.Price})
+			}
+			confirmation.Total = subtotal(order) - order.Discount
+			s.queue.RecordOrder(quantity)
+			s.dispatcher.Dispatch(confirmation)
+		}
+		result = order
+	case mux.Vars(r)["id"] != "":
+		orderID, convErr := strconv.Atoi(mux.Vars(r)["id"])
+		if convErr != nil {
+			http.Error(rw, "Invalid order id", http.StatusBadRequest)
+			return
+		}
+		result, err = s.client.Order(r.Context(), token, orderID)
+	default:
+		result, err = s.client.Orders(r.Context(), token)
+	}
+
+	var upstream *productapi.Error
+	if errors.As(err, &upstream) && upstream.Status < http.StatusInternalServerError {
+		http.Error(rw, upstream.Body, upstream.Status)
+		return
+	}
+	var declined *payments.Error
+	if errors.As(err, &declined) {
+		http.Error(rw, declined.Body, http.StatusPaymentRequired)
+		return
+	}
+	if errors.Is(err, data.ErrInsufficientPoints) {
+		http.Error(rw, "Invalid order, "+err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errPointsFailed) {
+		s.logger.Error("Unable to redeem loyalty points", "error", err)
+		http.Error(rw, "Unable to redeem loyalty points", http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, errPaymentFailed) {
+		s.logger.Error("Unable to authorize payment", "error", err)
+		http.Error(rw, "Unable to reach payments", http.StatusBadGateway)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to delegate orders to product-api", "method", r.Method, "error", err)
+		http.Error(rw, "Unable to reach product-api", http.StatusBadGateway)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("Unable to encode orders", "error", err)
+		http.Error(rw, "Unable to encode orders", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// pay authorizes the payment of a created order, keyed by the order so that
+// it is charged at most once, and sets its ID on the response
+func (s *OrdersService) pay(ctx context.Context, rw http.ResponseWriter, order *productapi.Order, card *payments.Card) error {
+	if s.payments == nil {
+		return nil
+	}
+
+	payment, err := s.payments.Authorize(ctx, fmt.Sprintf("order-%d", order.ID), *card)
+	if err == nil {
+		s.logger.Info("Order paid", "order_id", order.ID, "payment_id", payment.ID)
+		rw.Header().Set(PaymentIDHeader, payment.ID)
+		return nil
+	}
+
+	var declined *payments.Error
+	if errors.As(err, &declined) && declined.Status < http.StatusInternalServerError {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errPaymentFailed, err)
+}
+
+// cancel cancels a created order whose points or payment failed with err,
+// the compensating step of the saga
+func (s *OrdersService) cancel(ctx context.Context, token string, order *productapi.Order, err error) {
+	// the order is cancelled even when the request was, keeping its trace
+	cancelCtx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+	if cancelErr := s.client.CancelOrder(cancelCtx, token, order.ID); cancelErr != nil {
+		s.logger.Error("Unable to cancel a failed order, it must be cancelled by hand", "order_id", order.ID, "order_error", err, "error", cancelErr)
+		return
+	}
+	s.logger.Info("Failed order cancelled", "order_id", order.ID, "error", err)
+}
+
+// redeemPoints takes up to points loyalty points of the user off the order,
+// no more than it still costs after its coupon, and returns the entry
+// debiting them
+func (s *OrdersService) redeemPoints(order *productapi.Order, userID, points int) []*entities.PointsEntry {
+	if points == 0 {
+		return nil
+	}
+
+	points, discount := s.loyalty.redeemable(points, subtotal(order)-order.Discount)
+	if points == 0 {
+		return nil
+	}
+	order.PointsRedeemed = points
+	order.Discount += discount
+	return []*entities.PointsEntry{{UserID: userID, OrderID: order.ID, Points: -points, Reason: entities.PointsRedeemed}}
+}
+
+// settleOrder records the outcome of an order whose points are debited:
+// cancelled with the redeemed points refunded when its payment failed with
+// err, paid with the points it earned otherwise. The order stands when its
+// outcome can not be recorded.
+func (s *OrdersService) settleOrder(ctx context.Context, order *productapi.Order, userID int, err error) {
+	status, entries := entities.OrderCreated, []*entities.PointsEntry(nil)
+	switch {
+	case err != nil:
+		status = entities.OrderCancelled
+		if order.PointsRedeemed > 0 {
+			entries = append(entries, &entities.PointsEntry{UserID: userID, OrderID: order.ID, Points: order.PointsRedeemed, Reason: entities.PointsRefunded})
+		}
+	case s.payments != nil:
+		status = entities.OrderPaid
+	}
+	earned := 0
+	if err == nil && s.loyalty != nil && userID != 0 {
+		if earned = s.loyalty.earned(subtotal(order) - order.Discount); earned > 0 {
+			entries = append(entries, &entities.PointsEntry{UserID: userID, OrderID: order.ID, Points: earned, Reason: entities.PointsEarned})
+		}
+	}
+	if len(entries) == 0 && status == entities.OrderCreated {
+		return
+	}
+
+	if err := s.recordOrder(ctx, order, status, entries); err != nil {
+		s.logger.Error("Unable to record the outcome of an order, its loyalty points must be corrected by hand", "order_id", order.ID, "user_id", userID, "status", status, "error", err)
+		return
+	}
+	if earned > 0 {
+		order.PointsEarned = earned
+	}
+}
+
+// writeCouponError writes the response for a coupon which can not be
+// redeemed, it returns true when err is nil and the order can go on
+func (s *OrdersService) writeCouponError(rw http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case data.ErrNotFound:
+		http.Error(rw, "Invalid order, coupon not found", http.StatusBadRequest)
+	case data.ErrCouponExpired, data.ErrCouponExhausted:
+		http.Error(rw, "Invalid order, "+err.Error(), http.StatusConflict)
+	case data.ErrCouponsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+	default:
+		s.logger.Error("Unable to redeem coupon", "error", err)
+		http.Error(rw, "Unable to redeem coupon", http.StatusInternalServerError)
+	}
+	return false
+}
+
+// releaseCoupon gives back the use of a coupon redeemed by a failed order
+func (s *OrdersService) releaseCoupon(ctx context.Context, code string) {
+	// the coupon is released even when the request was cancelled
+	releaseCtx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+	if err := data.ReleaseCoupon(releaseCtx, s.repository, code); err != nil {
+		s.logger.Error("Unable to release the coupon of a failed order", "code", code, "error", err)
+		return
+	}
+	s.logger.Info("Coupon released after a failed order", "code", code)
+}
+
+// recordOrder records a created order with status for the admin statistics,
+// along with its loyalty points entries in one transaction. An order without
+// entries stands when it can not be recorded.
+func (s *OrdersService) recordOrder(ctx context.Context, order *productapi.Order, status string, entries []*entities.PointsEntry) error {
+	record := &entities.OrderRecord{ID: order.ID, Status: status, Total: subtotal(order) - order.Discount, CreatedAt: s.now()}
+	for _, item := range order.Items {
+		record.Items = append(record.Items, entities.OrderRecordItem{CoffeeID: item.Coffee.ID, Name: item.Coffee.Name, Quantity: item.Quantity, Price: item.Coffee.Price})
+	}
+
+	// the record is kept even when the request was cancelled
+	recordCtx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+	err := data.RecordOrderPoints(recordCtx, s.repository, record, entries)
+	switch {
+	case err == nil:
+		return nil
+	case len(entries) == 0:
+		if err != data.ErrStatsUnsupported {
+			s.logger.Error("Unable to record order", "order_id", order.ID, "error", err)
+		}
+		return nil
+	case err == data.ErrInsufficientPoints:
+		return err
+	}
+	return fmt.Errorf("%w: %v", errPointsFailed, err)
+}
+
+// subtotal returns the price of the items of an order, before discounts
+func subtotal(order *productapi.Order) float64 {
+	total := 0.0
+	for _, item := range order.Items {
+		total += item.Coffee.Price * float64(item.Quantity)
+	}
+	return total
+}
+
+// decodeOrder reads an order, either the items alone as the product-api
+// expects or an orderRequest carrying a card
+func decodeOrder(r *http.Request, request *orderRequest) error {
+	raw := json.RawMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		return newDecoder(r, bytes.NewReader(raw)).Decode(&request.Items)
+	}
+	return newDecoder(r, bytes.NewReader(raw)).Decode(request)
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/data/queue"
+	"github.com/hashicorp-demoapp/coffee-service/notifications"
+	"github.com/hashicorp-demoapp/coffee-service/payments"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func setupOrdersHandler(t *testing.T, productAPI http.HandlerFunc) (*OrdersService, *popularity.Tracker) {
+	server := httptest.NewServer(productAPI)
+	t.Cleanup(server.Close)
+
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	client := productapi.NewClient(productapi.Options{Address: server.URL})
+	return NewOrders(client, repository, nil, tracker, queue.NewSimulator(1), nil, nil, hclog.NewNullLogger()), tracker
+}
+
+// setupPaidOrdersHandler is setupOrdersHandler paying the orders with the
+// payments service served by paymentsAPI
+func setupPaidOrdersHandler(t *testing.T, productAPI, paymentsAPI http.HandlerFunc) (*OrdersService, *popularity.Tracker) {
+	s, tracker := setupOrdersHandler(t, productAPI)
+
+	server := httptest.NewServer(paymentsAPI)
+	t.Cleanup(server.Close)
+	s.payments = payments.NewClient(payments.Options{Address: server.URL})
+
+	return s, tracker
+}
+
+const paidOrder = `{"items":[{"coffee":{"id":2},"quantity":1}],"payment":{"name":"Gerry","type":"mastercard","number":"1234-1234-1234-1234","expiry":"10/22","cv2":123}}`
+
+// productAPIOrders creates order 8 and counts its cancellations
+func productAPIOrders(t *testing.T, cancelled *int32) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /orders":
+			rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2},"quantity":1}]}`))
+		case "DELETE /orders/8":
+			atomic.AddInt32(cancelled, 1)
+			rw.Write([]byte(`"Deleted order"`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}
+}
+
+func TestOrdersArePaid(t *testing.T) {
+	var cancelled int32
+	s, tracker := setupPaidOrdersHandler(t, productAPIOrders(t, &cancelled), func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "order-8", r.Header.Get("Idempotency-Key"))
+		rw.Write([]byte(`{"id":"4d3c","message":"Payment processed successfully"}`))
+	})
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/orders", strings.NewReader(paidOrder)))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "4d3c", rw.Header().Get(PaymentIDHeader))
+	assert.Equal(t, int64(1), tracker.Stats(2).Orders)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&cancelled))
+	stats, err := data.AggregateStats(context.Background(), s.repository, time.Time{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{entities.OrderPaid: 1}, stats.OrdersByStatus)
+
+	// the items alone are not enough
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/orders", strings.NewReader(`[{"coffee":{"id":2},"quantity":1}]`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestOrdersAreCancelledWhenPaymentsFail(t *testing.T) {
+	for status, expected := range map[int]int{
+		http.StatusBadRequest:          http.StatusPaymentRequired,
+		http.StatusInternalServerError: http.StatusBadGateway,
+	} {
+		var cancelled int32
+		s, tracker := setupPaidOrdersHandler(t, productAPIOrders(t, &cancelled), func(rw http.ResponseWriter, r *http.Request) {
+			http.Error(rw, "Card declined", status)
+		})
+
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("POST", "/orders", strings.NewReader(paidOrder)))
+		assert.Equal(t, expected, rw.Code, status)
+		assert.Empty(t, rw.Header().Get(PaymentIDHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled), status)
+		assert.Equal(t, int64(0), tracker.Stats(2).Orders, status)
+		stats, err := data.AggregateStats(context.Background(), s.repository, time.Time{}, 5)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{entities.OrderCancelled: 1}, stats.OrdersByStatus, status)
+	}
+}
+
+func TestOrdersSendConfirmations(t *testing.T) {
+	var cancelled int32
+	s, _ := setupPaidOrdersHandler(t, productAPIOrders(t, &cancelled), func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(`{"id":"4d3c","message":"Payment processed successfully"}`))
+	})
+	confirmations := make(chan notifications.Confirmation, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		confirmation := notifications.Confirmation{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&confirmation))
+		confirmations <- confirmation
+	}))
+	defer webhook.Close()
+	dispatcher, err := notifications.NewDispatcher(notifications.Options{
+		Notifier: notifications.NewWebhook(webhook.URL, nil),
+		Buffer:   1,
+		Logger:   hclog.NewNullLogger(),
+	})
+	require.NoError(t, err)
+	s.dispatcher = dispatcher
+
+	order := strings.Replace(paidOrder, `{"items"`, `{"email":"gerry@example.com","items"`, 1)
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("POST", "/orders", strings.NewReader(order)))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	done := make(chan struct{})
+	close(done)
+	dispatcher.Run(done)
+	confirmation := <-confirmations
+	assert.Equal(t, 8, confirmation.OrderID)
+	assert.Equal(t, "gerry@example.com", confirmation.Email)
+	assert.Equal(t, "4d3c", confirmation.PaymentID)
+	assert.Contains(t, confirmation.Body, "Thank you for your order 8.")
+}
+
+func TestOrdersRedeemCoupons(t *testing.T) {
+	var cancelled int32
+	declined := false
+	s, _ := setupPaidOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /orders":
+			rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2,"price":150},"quantity":2}]}`))
+		case "DELETE /orders/8":
+			atomic.AddInt32(&cancelled, 1)
+			rw.Write([]byte(`"Deleted order"`))
+		}
+	}, func(rw http.ResponseWriter, r *http.Request) {
+		if declined {
+			http.Error(rw, "Card declined", http.StatusBadRequest)
+			return
+		}
+		rw.Write([]byte(`{"id":"4d3c","message":"Payment processed successfully"}`))
+	})
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, data.CreateCoupon(context.Background(), s.repository, &entities.Coupon{Code: "ONCE", Type: entities.CouponFixed, Value: 50, UsageLimit: 1}))
+	require.NoError(t, data.CreateCoupon(context.Background(), s.repository, &entities.Coupon{Code: "UNTIL2030", Type: entities.CouponPercent, Value: 20, ExpiresAt: &expiresAt}))
+	withCoupon := func(code string) *http.Request {
+		return httptest.NewRequest("POST", "/orders", strings.NewReader(strings.Replace(paidOrder, `{"items"`, `{"coupon":"`+code+`","items"`, 1)))
+	}
+
+	// a declined payment gives the use back
+	declined = true
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("once"))
+	assert.Equal(t, http.StatusPaymentRequired, rw.Code)
+	coupon, err := data.FindCoupon(context.Background(), s.repository, "ONCE")
+	require.NoError(t, err)
+	assert.Equal(t, 0, coupon.Used)
+
+	declined = false
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("once"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	order := productapi.Order{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &order))
+	assert.Equal(t, "ONCE", order.Coupon)
+	assert.Equal(t, 50.0, order.Discount)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("ONCE"))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	s.now = func() time.Time { return expiresAt }
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("UNTIL2030"))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	s.now = time.Now
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("UNKNOWN"))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withCoupon("WELCOME10"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"discount":30`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+}
+
+func TestOrdersRedeemAndEarnPoints(t *testing.T) {
+	var cancelled int32
+	declined := false
+	s, _ := setupPaidOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /orders":
+			rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2,"price":150},"quantity":2}]}`))
+		case "DELETE /orders/8":
+			atomic.AddInt32(&cancelled, 1)
+			rw.Write([]byte(`"Deleted order"`))
+		}
+	}, func(rw http.ResponseWriter, r *http.Request) {
+		if declined {
+			http.Error(rw, "Card declined", http.StatusBadRequest)
+			return
+		}
+		rw.Write([]byte(`{"id":"4d3c","message":"Payment processed successfully"}`))
+	})
+	s.loyalty = testLoyalty
+	_, err := data.RecordPoints(context.Background(), s.repository, &entities.PointsEntry{UserID: 1, OrderID: 1, Points: 500, Reason: entities.PointsEarned})
+	require.NoError(t, err)
+	withPoints := func(points string) *http.Request {
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(strings.Replace(paidOrder, `{"items"`, `{"coupon":"WELCOME10","points":`+points+`,"items"`, 1)))
+		r.Header.Set("Authorization", userToken(`{"user_id":1}`))
+		return r
+	}
+	balance := func() int {
+		points, err := data.FindPoints(context.Background(), s.repository, 1)
+		require.NoError(t, err)
+		return points.Balance
+	}
+
+	// a declined payment refunds the points
+	declined = true
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, withPoints("200"))
+	assert.Equal(t, http.StatusPaymentRequired, rw.Code)
+	assert.Equal(t, 500, balance())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+
+	// 200 points take 20 off the 270 left after WELCOME10, 250 are earned
+	declined = false
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withPoints("200"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	order := productapi.Order{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &order))
+	assert.Equal(t, 50.0, order.Discount)
+	assert.Equal(t, 200, order.PointsRedeemed)
+	assert.Equal(t, 250, order.PointsEarned)
+	assert.Equal(t, 550, balance())
+
+	// an insufficient balance cancels the order
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withPoints("600"))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&cancelled))
+	assert.Equal(t, 550, balance())
+	coupon, err := data.FindCoupon(context.Background(), s.repository, "WELCOME10")
+	require.NoError(t, err)
+	assert.Equal(t, 1, coupon.Used)
+
+	// redeeming points needs a user
+	r := withPoints("10")
+	r.Header.Set("Authorization", "token")
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	s.loyalty = nil
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, withPoints("10"))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+// failingLedger is an in memory repository failing to record orders with
+// their points
+type failingLedger struct {
+	*data.InMemoryRepository
+}
+
+func (failingLedger) RecordOrderPoints(ctx context.Context, order *entities.OrderRecord, entries []*entities.PointsEntry) error {
+	return errors.New("connection reset by peer")
+}
+
+func TestOrdersDebitNoPointsWhenTheOrderIsNotRecorded(t *testing.T) {
+	var cancelled int32
+	s, _ := setupPaidOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /orders":
+			rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2,"price":150},"quantity":2}]}`))
+		case "DELETE /orders/8":
+			atomic.AddInt32(&cancelled, 1)
+			rw.Write([]byte(`"Deleted order"`))
+		}
+	}, func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("an order whose points failed is paid")
+	})
+	s.repository = failingLedger{s.repository.(*data.InMemoryRepository)}
+	s.loyalty = testLoyalty
+	_, err := data.RecordPoints(context.Background(), s.repository, &entities.PointsEntry{UserID: 1, OrderID: 1, Points: 500, Reason: entities.PointsEarned})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(strings.Replace(paidOrder, `{"items"`, `{"points":200,"items"`, 1)))
+	r.Header.Set("Authorization", userToken(`{"user_id":1}`))
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+
+	points, err := data.FindPoints(context.Background(), s.repository, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 500, points.Balance)
+	assert.Len(t, points.Ledger, 1)
+	stats, err := data.AggregateStats(context.Background(), s.repository, time.Time{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{entities.OrderCancelled: 1}, stats.OrdersByStatus)
+}
+
+func TestOrdersDelegatesToProductAPI(t *testing.T) {
+	s, tracker := setupOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		switch r.Method + " " + r.URL.Path {
+		case "GET /orders/7":
+			rw.Write([]byte(`{"id":7}`))
+		case "POST /orders":
+			rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2},"quantity":1}]}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/orders/7", nil), map[string]string{"id": "7"})
+	r.Header.Set("Authorization", "token")
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"id":7}`, rw.Body.String())
+
+	r = httptest.NewRequest("POST", "/orders", strings.NewReader(`[{"coffee":{"id":2},"quantity":1}]`))
+	r.Header.Set("Authorization", "token")
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, int64(1), tracker.Stats(2).Orders)
+	s.queue.Recompute()
+	assert.Equal(t, 1, s.queue.Status().OpenOrders)
+}
+
+func TestOrdersRejectUnknownFieldsWhenStrict(t *testing.T) {
+	s, _ := setupOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	handler := middleware.NewStrictJSON()(s)
+
+	for body, field := range map[string]string{
+		`[{"coffee":{"id":2},"quantity":1,"size":"large"}]`:         "size",
+		`{"items":[{"coffee":{"id":2},"quantity":1}],"tip":"2.00"}`: "tip",
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("POST", "/orders", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Equal(t, "Invalid order, unknown field \""+field+"\"\n", rw.Body.String())
+	}
+}
+
+func TestOrdersPassesOnClientErrors(t *testing.T) {
+	s, _ := setupOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "Invalid token", http.StatusUnauthorized)
+	})
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "Invalid token\n", rw.Body.String())
+}
+
+func TestOrdersReportsUnavailableProductAPI(t *testing.T) {
+	s, _ := setupOrdersHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "database unavailable", http.StatusInternalServerError)
+	})
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, http.StatusBadGateway, rw.Code)
+}
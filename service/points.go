@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+)
+
+// Loyalty configures the loyalty points users earn on their orders and
+// redeem as discounts
+type Loyalty struct {
+	// Secret verifies the product-api tokens identifying the users
+	Secret []byte
+	// EarnRate is the points earned per unit of currency paid
+	EarnRate float64
+	// PointValue is the amount of currency a point takes off an order
+	PointValue float64
+}
+
+// userID returns the user of the product-api token of a request
+func (l *Loyalty) userID(r *http.Request) (int, error) {
+	return productapi.UserID(r.Header.Get("Authorization"), l.Secret, time.Now())
+}
+
+// redeemable returns the points, up to requested, worth at most amount, and
+// the discount they give rounded to cents
+func (l *Loyalty) redeemable(requested int, amount float64) (int, float64) {
+	if amount <= 0 {
+		return 0, 0
+	}
+	points := requested
+	if needed := int(math.Ceil(amount / l.PointValue)); needed < points {
+		points = needed
+	}
+	return points, math.Round(math.Min(float64(points)*l.PointValue, amount)*100) / 100
+}
+
+// earned returns the points earned on an order paying amount
+func (l *Loyalty) earned(amount float64) int {
+	return int(math.Floor(amount * l.EarnRate))
+}
+
+// PointsService is an HTTP Handler returning the loyalty points balance and
+// ledger of the user authenticated by the product-api token of the request
+type PointsService struct {
+	repository data.Repository
+	loyalty    *Loyalty
+	logger     hclog.Logger
+}
+
+// NewPoints creates a new Points handler
+func NewPoints(repository data.Repository, loyalty *Loyalty, l hclog.Logger) *PointsService {
+	return &PointsService{repository, loyalty, l}
+}
+
+// ServeHTTP handles incoming requests for the /me/points route
+func (s *PointsService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Points")
+
+	userID, err := s.loyalty.userID(r)
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	points, err := data.FindPoints(r.Context(), s.repository, userID)
+	switch err {
+	case nil:
+	case data.ErrPointsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to find points", "user_id", userID, "error", err)
+		http.Error(rw, "Unable to find points", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(points)
+	if err != nil {
+		s.logger.Error("Unable to encode points", "error", err)
+		http.Error(rw, "Unable to encode points", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
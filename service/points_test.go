@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// testLoyalty earns a point per unit paid, each worth 0.1
+var testLoyalty = &Loyalty{Secret: []byte("secret"), EarnRate: 1, PointValue: 0.1}
+
+// userToken signs a product-api token of the user with the secret of
+// testLoyalty
+func userToken(claims string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, testLoyalty.Secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestLoyaltyRedeemsNoMoreThanTheOrder(t *testing.T) {
+	points, discount := testLoyalty.redeemable(100, 300)
+	assert.Equal(t, 100, points)
+	assert.Equal(t, 10.0, discount)
+
+	points, discount = testLoyalty.redeemable(100, 2.55)
+	assert.Equal(t, 26, points)
+	assert.Equal(t, 2.55, discount)
+
+	points, _ = testLoyalty.redeemable(100, 0)
+	assert.Equal(t, 0, points)
+
+	assert.Equal(t, 299, testLoyalty.earned(299.99))
+}
+
+func TestPointsReturnsTheLedgerOfTheUser(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	_, err = data.RecordPoints(context.Background(), repository, &entities.PointsEntry{UserID: 1, OrderID: 8, Points: 300, Reason: entities.PointsEarned})
+	require.NoError(t, err)
+	s := NewPoints(repository, testLoyalty, hclog.NewNullLogger())
+
+	r := httptest.NewRequest("GET", "/me/points", nil)
+	r.Header.Set("Authorization", userToken(`{"user_id":1}`))
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"user_id":1,"balance":300,"ledger":[{"id":1,"order_id":8,"points":300,"reason":"earned"`)
+
+	r.Header.Set("Authorization", userToken(`{"user_id":2}`))
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"user_id":2,"balance":0,"ledger":[]}`, rw.Body.String())
+
+	r.Header.Set("Authorization", "token")
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "Bearer", rw.Header().Get("WWW-Authenticate"))
+}
+
+func TestPointsUnsupported(t *testing.T) {
+	s := NewPoints(&data.MockRepository{}, testLoyalty, hclog.NewNullLogger())
+
+	r := httptest.NewRequest("GET", "/me/points", nil)
+	r.Header.Set("Authorization", userToken(`{"user_id":1}`))
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+)
+
+// ProfileService is an HTTP Handler for the profile of the user identified
+// by the subject of the product-api token of the request. GET returns the
+// profile and PUT replaces it, creating it the first time.
+type ProfileService struct {
+	repository data.Repository
+	secret     []byte
+	logger     hclog.Logger
+}
+
+// NewProfile creates a new Profile handler verifying tokens with secret
+func NewProfile(repository data.Repository, secret []byte, l hclog.Logger) *ProfileService {
+	return &ProfileService{repository, secret, l}
+}
+
+// ServeHTTP handles incoming requests for the /me route
+func (s *ProfileService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Profile", "method", r.Method)
+
+	claims, err := productapi.ParseToken(r.Header.Get("Authorization"), s.secret, time.Now())
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var user *entities.User
+	if r.Method == http.MethodPut {
+		user = &entities.User{}
+		if err := newDecoder(r, r.Body).Decode(user); err != nil {
+			invalidPayload(rw, "Invalid profile", err)
+			return
+		}
+		user.Subject = claims.Subject
+		if err = data.SaveUser(r.Context(), s.repository, user); err == nil {
+			s.logger.Info("Profile saved", "subject", user.Subject)
+		}
+	} else {
+		user, err = data.FindUser(r.Context(), s.repository, claims.Subject)
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Profile not found", http.StatusNotFound)
+		return
+	case data.ErrInvalidUser:
+		http.Error(rw, "Profiles need a display name of up to 64 characters, a favorite milk of whole, skim, oat, almond or soy if any, and an existing default store if any", http.StatusBadRequest)
+		return
+	case data.ErrUsersUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to manage profile", "method", r.Method, "subject", claims.Subject, "error", err)
+		http.Error(rw, "Unable to manage profile", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(user)
+	if err != nil {
+		s.logger.Error("Unable to encode profile", "error", err)
+		http.Error(rw, "Unable to encode profile", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func setupProfileHandler(t *testing.T) *ProfileService {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return NewProfile(repository, testLoyalty.Secret, hclog.NewNullLogger())
+}
+
+// profileRequest is a request of the user with subject sub
+func profileRequest(method, sub, body string) *http.Request {
+	r := httptest.NewRequest(method, "/me", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+userToken(`{"sub":"`+sub+`"}`))
+	return r
+}
+
+func TestProfileIsSavedPerSubject(t *testing.T) {
+	s := setupProfileHandler(t)
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, profileRequest("GET", "auth0|42", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	// the subject comes from the token alone
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, profileRequest("PUT", "auth0|42", `{"subject":"auth0|7","display_name":"Gerry","favorite_milk":"oat","default_store_id":2}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"subject":"auth0|42","display_name":"Gerry","favorite_milk":"oat","default_store_id":2}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, profileRequest("GET", "auth0|42", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"subject":"auth0|42","display_name":"Gerry","favorite_milk":"oat","default_store_id":2}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, profileRequest("GET", "auth0|7", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestProfileRejectsInvalidProfiles(t *testing.T) {
+	s := setupProfileHandler(t)
+
+	for _, body := range []string{`{"display_name":""}`, `{"display_name":"Gerry","default_store_id":99}`, `not json`} {
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, profileRequest("PUT", "auth0|42", body))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, body)
+	}
+}
+
+func TestProfileNeedsAToken(t *testing.T) {
+	s := setupProfileHandler(t)
+
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("Authorization", "token")
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "Bearer", rw.Header().Get("WWW-Authenticate"))
+}
+
+func TestProfileUnsupported(t *testing.T) {
+	s := NewProfile(&data.MockRepository{}, testLoyalty.Secret, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, profileRequest("GET", "auth0|42", ""))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/queue"
+)
+
+// QueueService is an HTTP Handler reporting the simulated barista queue, the
+// open orders and how long a new order waits before a barista starts it
+type QueueService struct {
+	simulator *queue.Simulator
+	logger    hclog.Logger
+}
+
+// NewQueue creates a new Queue handler
+func NewQueue(simulator *queue.Simulator, l hclog.Logger) *QueueService {
+	return &QueueService{simulator, l}
+}
+
+// ServeHTTP handles incoming requests for the api queue route
+func (s *QueueService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Queue")
+
+	body, err := json.Marshal(s.simulator.Status())
+	if err != nil {
+		s.logger.Error("Unable to encode queue status", "error", err)
+		http.Error(rw, "Unable to encode queue status", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/queue"
+)
+
+func TestQueueReportsTheWaitTime(t *testing.T) {
+	simulator := queue.NewSimulator(1)
+	simulator.RecordOrder(2)
+	simulator.Recompute()
+	handler := NewQueue(simulator, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/queue", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	status := queue.Status{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.Equal(t, 1, status.OpenOrders)
+	assert.Equal(t, 1, status.Baristas)
+	assert.InDelta(t, 180, status.WaitSeconds, 1)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// maxRaftCommandSize is the largest write accepted from another replica
+const maxRaftCommandSize = 1 << 20
+
+// RaftService is an HTTP Handler reporting the Raft status of the replica on
+// GET and applying the writes other replicas forward to the leader on POST
+type RaftService struct {
+	replica *data.RaftRepository
+	logger  hclog.Logger
+}
+
+// NewRaft creates a new Raft handler
+func NewRaft(replica *data.RaftRepository, l hclog.Logger) *RaftService {
+	return &RaftService{replica, l}
+}
+
+// ServeHTTP handles incoming requests for the admin raft routes
+func (s *RaftService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Raft", "method", r.Method)
+
+	if r.Method == http.MethodPost {
+		command, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxRaftCommandSize))
+		if err != nil {
+			http.Error(rw, "Invalid command", http.StatusBadRequest)
+			return
+		}
+
+		result, err := s.replica.Forwarded(command)
+		if errors.Is(err, data.ErrInvalidCommand) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == data.ErrNotLeader {
+			// the leader changed while the write was forwarded
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Unable to apply forwarded write", "error", err)
+			http.Error(rw, "Unable to apply write", http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(result)
+		return
+	}
+
+	body, err := json.Marshal(s.replica.Status())
+	if err != nil {
+		s.logger.Error("Unable to encode Raft status", "error", err)
+		http.Error(rw, "Unable to encode Raft status", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
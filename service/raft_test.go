@@ -0,0 +1,87 @@
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// setupRaftHandler starts a single replica, which elects itself leader
+func setupRaftHandler(t *testing.T) *RaftService {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	l := hclog.NewNullLogger()
+	replica, err := data.NewRaft(&config.Config{Logger: l}, data.RaftOptions{
+		NodeID:      "coffee-0",
+		BindAddress: address,
+		Peers:       []data.RaftPeer{{ID: "coffee-0", Address: address, HTTPAddress: "http://127.0.0.1:9090"}},
+		Logger:      l,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { replica.Close() })
+
+	require.Eventually(t, func() bool { return replica.Status().State == "Leader" }, 10*time.Second, 10*time.Millisecond)
+	return NewRaft(replica, l)
+}
+
+func TestRaftReportsStatusAndAppliesForwardedWrites(t *testing.T) {
+	handler := setupRaftHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", data.RaftApplyPath, strings.NewReader(`{"op":"CreateCoffee","coffee":{"name":"Forwarded Flat White","price":300}}`)))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"id":7`)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", data.RaftApplyPath, strings.NewReader(`{"op":"DeleteCoffee","id":42}`)))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"error":"not found"`)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/raft", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	status := data.RaftStatus{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.Equal(t, "coffee-0", status.ID)
+	assert.Equal(t, "Leader", status.State)
+	assert.Equal(t, "coffee-0", status.Leader)
+	assert.GreaterOrEqual(t, status.AppliedIndex, uint64(3))
+}
+
+func TestRaftRejectsInvalidForwardedWrites(t *testing.T) {
+	handler := setupRaftHandler(t)
+
+	for _, command := range []string{
+		`{"op":"UpdateCoffee"}`,
+		`{"op":"CreateCoffee"}`,
+		`{"op":"CreateIngredient","coffee":{"name":"Misplaced"}}`,
+		`{"op":"DeleteIngredient"}`,
+		`{"op":"DropTables","id":1}`,
+		`{"op":`,
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("POST", data.RaftApplyPath, strings.NewReader(command)))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, command)
+	}
+
+	// the replica still applies valid writes
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("POST", data.RaftApplyPath, strings.NewReader(`{"op":"DeleteCoffee","id":1}`)))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.NotContains(t, rw.Body.String(), `"error"`)
+}
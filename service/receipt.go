@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+	"github.com/hashicorp-demoapp/coffee-service/receipts"
+)
+
+// ReceiptService is an HTTP Handler rendering the receipt of an order of the
+// product-api, as a PDF document when ?format=pdf or an Accept header of
+// application/pdf asks for one, as an HTML page otherwise. The receipt is
+// streamed as it renders.
+type ReceiptService struct {
+	client     *productapi.Client
+	repository data.Repository
+	renderer   *receipts.Renderer
+	logger     hclog.Logger
+	now        func() time.Time
+}
+
+// NewReceipt creates a new Receipt handler. The names and prices of the
+// coffees are read from the repository, falling back to those of the order
+// for coffees which are gone.
+func NewReceipt(client *productapi.Client, repository data.Repository, renderer *receipts.Renderer, l hclog.Logger) *ReceiptService {
+	return &ReceiptService{client, repository, renderer, l, time.Now}
+}
+
+// ServeHTTP handles incoming requests for the api order receipt route
+func (s *ReceiptService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Receipt")
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid order id", http.StatusBadRequest)
+		return
+	}
+
+	order, err := s.client.Order(r.Context(), r.Header.Get("Authorization"), orderID)
+	var upstream *productapi.Error
+	if errors.As(err, &upstream) && upstream.Status < http.StatusInternalServerError {
+		http.Error(rw, upstream.Body, upstream.Status)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to get order from product-api", "order_id", orderID, "error", err)
+		http.Error(rw, "Unable to reach product-api", http.StatusBadGateway)
+		return
+	}
+
+	lines := make([]receipts.Line, len(order.Items))
+	for n, item := range order.Items {
+		coffee, err := s.repository.FindByID(r.Context(), item.Coffee.ID)
+		if err != nil && err != data.ErrNotFound {
+			s.logger.Error("Unable to get coffee from database", "coffee_id", item.Coffee.ID, "error", err)
+			http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+			return
+		}
+		if coffee == nil {
+			coffee = &item.Coffee
+		}
+		lines[n] = receipts.Line{Name: coffee.Name, Quantity: item.Quantity, Price: coffee.Price}
+	}
+	receipt := receipts.NewReceipt(order.ID, lines, s.now())
+
+	// the headers are sent with the first write, a failure after it can only
+	// be logged
+	rw.Header().Add("Vary", "Accept")
+	if r.URL.Query().Get("format") == "pdf" || strings.Contains(r.Header.Get("Accept"), "application/pdf") {
+		rw.Header().Set("Content-Type", "application/pdf")
+		rw.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="receipt-%d.pdf"`, order.ID))
+		err = s.renderer.PDF(rw, receipt)
+	} else {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = s.renderer.HTML(rw, receipt)
+	}
+	if err != nil {
+		s.logger.Error("Unable to render receipt", "order_id", orderID, "error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/productapi"
+	"github.com/hashicorp-demoapp/coffee-service/receipts"
+)
+
+func setupReceiptHandler(t *testing.T, productAPI http.HandlerFunc) *ReceiptService {
+	server := httptest.NewServer(productAPI)
+	t.Cleanup(server.Close)
+
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	renderer, err := receipts.NewRenderer("")
+	require.NoError(t, err)
+
+	client := productapi.NewClient(productapi.Options{Address: server.URL})
+	s := NewReceipt(client, repository, renderer, hclog.NewNullLogger())
+	s.now = func() time.Time { return time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC) }
+	return s
+}
+
+func receiptRequest(id, query string) *http.Request {
+	r := httptest.NewRequest("GET", "/orders/"+id+"/receipt"+query, nil)
+	r.Header.Set("Authorization", "token")
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+// productAPIOrder serves order 8, of two Vaulattes and a coffee the
+// coffee-service does not have
+func productAPIOrder(t *testing.T) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		if r.URL.Path != "/orders/8" {
+			http.Error(rw, "Order not found", http.StatusNotFound)
+			return
+		}
+		rw.Write([]byte(`{"id":8,"items":[{"coffee":{"id":2,"name":"Stale","price":1},"quantity":2},{"coffee":{"id":99,"name":"Retired Roast","price":120},"quantity":1}]}`))
+	}
+}
+
+func TestReceiptIsRenderedAsHTML(t *testing.T) {
+	s := setupReceiptHandler(t, productAPIOrder(t))
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, receiptRequest("8", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+	// the local coffee wins over the one of the order
+	assert.Contains(t, rw.Body.String(), "<td>Vaulatte</td>")
+	assert.Contains(t, rw.Body.String(), "<td>Retired Roast</td>")
+	assert.Contains(t, rw.Body.String(), "520.00")
+}
+
+func TestReceiptIsRenderedAsPDF(t *testing.T) {
+	s := setupReceiptHandler(t, productAPIOrder(t))
+
+	for _, r := range []*http.Request{receiptRequest("8", "?format=pdf"), receiptRequest("8", "")} {
+		if r.URL.RawQuery == "" {
+			r.Header.Set("Accept", "application/pdf")
+		}
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, r)
+		require.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "application/pdf", rw.Header().Get("Content-Type"))
+		assert.Equal(t, `inline; filename="receipt-8.pdf"`, rw.Header().Get("Content-Disposition"))
+		assert.True(t, bytes.HasPrefix(rw.Body.Bytes(), []byte("%PDF-")))
+	}
+}
+
+func TestReceiptPassesProductAPIErrorsOn(t *testing.T) {
+	s := setupReceiptHandler(t, productAPIOrder(t))
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, receiptRequest("9", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	s = setupReceiptHandler(t, func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "boom", http.StatusInternalServerError)
+	})
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, receiptRequest("8", ""))
+	assert.Equal(t, http.StatusBadGateway, rw.Code)
+}
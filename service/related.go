@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+)
+
+const (
+	// defaultRelatedLimit is the number of related coffees returned when no
+	// limit is requested
+	defaultRelatedLimit = 3
+	// maxRelatedLimit caps the limit query parameter
+	maxRelatedLimit = 20
+)
+
+// RelatedService is an HTTP Handler returning the coffees that share the most
+// ingredients with a given coffee
+type RelatedService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewRelated creates a new Related handler
+func NewRelated(repository data.Repository, l hclog.Logger) *RelatedService {
+	return &RelatedService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api coffees related route
+func (s *RelatedService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Related Coffees")
+
+	coffeeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.logger.Error("Unable to parse coffee id", "error", err)
+		http.Error(rw, "Invalid coffee id", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultRelatedLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxRelatedLimit {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxRelatedLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	coffees, err := s.repository.FindRelated(r.Context(), coffeeID, limit)
+	if err == data.ErrNotFound {
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to get related coffees from database", "error", err)
+		http.Error(rw, "Unable to get related coffees from database", http.StatusInternalServerError)
+		return
+	}
+	if err := data.Localize(r.Context(), s.repository, coffees, data.LocaleChain(r.Header.Get("Accept-Language"))); err != nil {
+		s.logger.Error("Unable to get coffee translations from database", "error", err)
+		http.Error(rw, "Unable to get related coffees from database", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Debug(fmt.Sprintf("Found %d related coffees", len(coffees)))
+
+	encoder := encoding.Default.Negotiate(r.Header.Get("Accept"), &coffees)
+	body, err := encoder.Encode(&coffees)
+	if err != nil {
+		s.logger.Error("Unable to encode coffees", "content_type", encoder.ContentType(), "error", err)
+		http.Error(rw, "Unable to encode coffees", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", encoder.ContentType())
+	rw.Header().Add("Vary", "Accept")
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Write(body)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupRelatedHandler(t *testing.T, id string, query string) (*RelatedService, *data.MockRepository, *httptest.ResponseRecorder, *http.Request) {
+	c := &data.MockRepository{}
+
+	r := httptest.NewRequest("GET", "/coffees/"+id+"/related"+query, nil)
+	r = mux.SetURLVars(r, map[string]string{"id": id})
+
+	return NewRelated(c, hclog.Default()), c, httptest.NewRecorder(), r
+}
+
+func TestRelatedReturnsCoffees(t *testing.T) {
+	s, c, rw, r := setupRelatedHandler(t, "1", "")
+	c.On("FindRelated", 1, defaultRelatedLimit).Return(entities.Coffees{entities.Coffee{ID: 2, Name: "Test"}}, nil)
+
+	s.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffees{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Len(t, bd, 1)
+	assert.Equal(t, 2, bd[0].ID)
+}
+
+func TestRelatedHonoursLimit(t *testing.T) {
+	s, c, rw, r := setupRelatedHandler(t, "1", "?limit=5")
+	c.On("FindRelated", 1, 5).Return(entities.Coffees{}, nil)
+
+	s.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	c.AssertExpectations(t)
+}
+
+func TestRelatedRejectsInvalidLimit(t *testing.T) {
+	s, _, rw, r := setupRelatedHandler(t, "1", "?limit=500")
+
+	s.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestRelatedReturnsNotFound(t *testing.T) {
+	s, c, rw, r := setupRelatedHandler(t, "42", "")
+	c.On("FindRelated", 42, defaultRelatedLimit).Return(nil, data.ErrNotFound)
+
+	s.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
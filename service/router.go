@@ -0,0 +1,178 @@
+package service
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+// RouterBuilder creates groups of routes sharing the middleware enabled for
+// the group in the configuration, so behaviours can be toggled per group
+// without code changes.
+type RouterBuilder struct {
+	router     *mux.Router
+	enabled    map[string][]string
+	middleware map[string]func(group string) mux.MiddlewareFunc
+	order      []string
+	// inner is the middleware of every group, inside the enabled middleware
+	inner  []mux.MiddlewareFunc
+	logger hclog.Logger
+
+	// the names of the middleware applied, for the route catalog
+	global     []string
+	innerNames []string
+	groups     map[*mux.Route]routeGroup
+}
+
+// routeGroup is a group of routes created by the builder
+type routeGroup struct {
+	name       string
+	middleware []string
+}
+
+// RouteInfo describes a route registered with the router
+type RouteInfo struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	// Group is the route group, empty for routes outside any group
+	Group      string   `json:"group,omitempty"`
+	Middleware []string `json:"middleware"`
+	// Auth lists the middleware a request must be authorized by, empty for
+	// public routes
+	Auth []string `json:"auth"`
+}
+
+// NewRouterBuilder creates a RouterBuilder adding route groups to router,
+// with every middleware supported by the configuration available
+func NewRouterBuilder(router *mux.Router, cfg *config.Config) *RouterBuilder {
+	b := &RouterBuilder{
+		router:     router,
+		enabled:    cfg.RouteMiddleware,
+		middleware: map[string]func(group string) mux.MiddlewareFunc{},
+		logger:     cfg.Logger,
+		groups:     map[*mux.Route]routeGroup{},
+	}
+
+	// registration order is the order middleware wraps the handlers, rejected
+	// requests never reach the cache and cached responses are still traced
+	b.Register(config.TracingMiddleware, middleware.NewTracing())
+	b.Register(config.SPIFFEMiddleware, middleware.NewSPIFFE(cfg.SPIFFEAllowedIDs))
+	b.Register(config.AuthMiddleware, middleware.NewAuth(cfg.AuthToken))
+	b.Register(config.RateLimitMiddleware, middleware.NewRateLimit(cfg.RateLimit))
+	b.RegisterPerGroup(config.CacheMiddleware, func(group string) mux.MiddlewareFunc {
+		policy := cfg.CachePolicy(group)
+		return middleware.NewCache(policy.TTL, policy.Stale)
+	})
+
+	return b
+}
+
+// Register makes middleware available to route groups under name. Groups
+// apply their middleware in registration order, outermost first.
+func (b *RouterBuilder) Register(name string, mw func(http.Handler) http.Handler) {
+	b.RegisterPerGroup(name, func(string) mux.MiddlewareFunc { return mw })
+}
+
+// RegisterPerGroup is Register for middleware configured per route group,
+// create is called for every group enabling it
+func (b *RouterBuilder) RegisterPerGroup(name string, create func(group string) mux.MiddlewareFunc) {
+	if _, ok := b.middleware[name]; !ok {
+		b.order = append(b.order, name)
+	}
+	b.middleware[name] = create
+}
+
+// UseGlobal wraps every route of the router in mw, outside the middleware of
+// the groups. The name is reported by the route catalog.
+func (b *RouterBuilder) UseGlobal(name string, mw func(http.Handler) http.Handler) {
+	b.router.Use(mw)
+	b.global = append(b.global, name)
+}
+
+// Use wraps the routes of every group created afterwards in mw, inside the
+// middleware enabled for the group
+func (b *RouterBuilder) Use(name string, mw func(http.Handler) http.Handler) {
+	b.inner = append(b.inner, mw)
+	b.innerNames = append(b.innerNames, name)
+}
+
+// Group returns a router for the routes of a group, wrapped in the middleware
+// enabled for the group
+func (b *RouterBuilder) Group(name string) *mux.Router {
+	route := b.router.NewRoute()
+	group := route.Subrouter()
+
+	enabled := map[string]bool{}
+	for _, mw := range b.enabled[name] {
+		enabled[mw] = true
+	}
+
+	var applied []string
+	for _, mw := range b.order {
+		if !enabled[mw] {
+			continue
+		}
+		// Lifecycle event
+		b.logger.Info("Registering route group middleware", "group", name, "middleware", mw)
+		group.Use(b.middleware[mw](name))
+		applied = append(applied, mw)
+	}
+	for _, mw := range b.inner {
+		group.Use(mw)
+	}
+	b.groups[route] = routeGroup{name: name, middleware: append(applied, b.innerNames...)}
+
+	return group
+}
+
+// Catalog returns every route registered with the router so far, sorted by
+// path, with the middleware applied to it outermost first. Middleware
+// wrapping a single handler is not included.
+func (b *RouterBuilder) Catalog() ([]RouteInfo, error) {
+	catalog := []RouteInfo{}
+	err := b.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		// the routes of the groups only hold their subrouter
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		info := RouteInfo{Path: path, Methods: []string{}, Auth: []string{}}
+		// routes without methods match every method
+		if methods, err := route.GetMethods(); err == nil {
+			info.Methods = methods
+		}
+
+		info.Middleware = append([]string{}, b.global...)
+		for _, ancestor := range ancestors {
+			group, ok := b.groups[ancestor]
+			if !ok {
+				continue
+			}
+			info.Group = group.name
+			info.Middleware = append(info.Middleware, group.middleware...)
+			for _, mw := range group.middleware {
+				if mw == config.AuthMiddleware || mw == config.SPIFFEMiddleware {
+					info.Auth = append(info.Auth, mw)
+				}
+			}
+		}
+
+		catalog = append(catalog, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(catalog, func(i, j int) bool { return catalog[i].Path < catalog[j].Path })
+	return catalog, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/service/middleware"
+)
+
+func TestRouterBuilderAppliesMiddlewarePerGroup(t *testing.T) {
+	cfg := &config.Config{
+		RouteMiddleware: map[string][]string{
+			config.CoffeesRoutes: {config.CacheMiddleware},
+			config.SearchRoutes:  {config.AuthMiddleware},
+		},
+		AuthToken: "s3cret",
+		CacheTTL:  time.Minute,
+		Logger:    hclog.NewNullLogger(),
+	}
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	router := mux.NewRouter()
+	b := NewRouterBuilder(router, cfg)
+	b.Group(config.HealthRoutes).Handle("/health", ok).Methods("GET")
+	b.Group(config.CoffeesRoutes).Handle("/coffees", ok).Methods("GET")
+	b.Group(config.SearchRoutes).Handle("/search", ok).Methods("GET")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+		return rw
+	}
+
+	health := get("/health")
+	assert.Equal(t, http.StatusOK, health.Code)
+	assert.Empty(t, health.Header().Get(middleware.CacheHeader))
+
+	coffees := get("/coffees")
+	assert.Equal(t, http.StatusOK, coffees.Code)
+	assert.Equal(t, "MISS", coffees.Header().Get(middleware.CacheHeader))
+
+	assert.Equal(t, http.StatusUnauthorized, get("/search").Code)
+	assert.Equal(t, http.StatusNotFound, get("/nope").Code)
+}
+
+func TestRouterBuilderAppliesCachePolicyPerGroup(t *testing.T) {
+	cfg := &config.Config{
+		RouteMiddleware: map[string][]string{
+			config.CoffeesRoutes: {config.CacheMiddleware},
+			config.SearchRoutes:  {config.CacheMiddleware},
+		},
+		CacheTTL: time.Minute,
+		CachePolicies: map[string]config.CachePolicy{
+			config.CoffeesRoutes: {TTL: 10 * time.Second, Stale: time.Minute},
+		},
+		Logger: hclog.NewNullLogger(),
+	}
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	router := mux.NewRouter()
+	b := NewRouterBuilder(router, cfg)
+	b.Group(config.CoffeesRoutes).Handle("/coffees", ok).Methods("GET")
+	b.Group(config.SearchRoutes).Handle("/search", ok).Methods("GET")
+
+	cacheControl := func(path string) string {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+		return rw.Header().Get("Cache-Control")
+	}
+
+	assert.Equal(t, "max-age=10, stale-while-revalidate=60", cacheControl("/coffees"))
+	assert.Equal(t, "max-age=60", cacheControl("/search"))
+}
+
+func TestRouterBuilderCatalogsRoutes(t *testing.T) {
+	cfg := &config.Config{
+		RouteMiddleware: map[string][]string{
+			config.AdminRoutes: {config.TracingMiddleware, config.AuthMiddleware},
+		},
+		AuthToken: "s3cret",
+		Logger:    hclog.NewNullLogger(),
+	}
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+	router := mux.NewRouter()
+	b := NewRouterBuilder(router, cfg)
+	b.UseGlobal("request_id", middleware.NewRequestID())
+	b.Group(config.CoffeesRoutes).Handle("/coffees/{id:[0-9]+}", ok).Methods("GET")
+	b.Use("latency", func(next http.Handler) http.Handler { return next })
+	b.Group(config.AdminRoutes).Handle("/admin/coupons", ok).Methods("GET", "POST")
+	router.Handle("/health/live", ok)
+
+	catalog, err := b.Catalog()
+	require.NoError(t, err)
+	assert.Equal(t, []RouteInfo{
+		{Path: "/admin/coupons", Methods: []string{"GET", "POST"}, Group: config.AdminRoutes, Middleware: []string{"request_id", "tracing", "auth", "latency"}, Auth: []string{"auth"}},
+		{Path: "/coffees/{id:[0-9]+}", Methods: []string{"GET"}, Group: config.CoffeesRoutes, Middleware: []string{"request_id"}, Auth: []string{}},
+		{Path: "/health/live", Methods: []string{}, Middleware: []string{"request_id"}, Auth: []string{}},
+	}, catalog)
+}
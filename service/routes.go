@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// RoutesService is an HTTP Handler listing the routes of the service, with
+// the middleware applied to them and the authorization they require
+type RoutesService struct {
+	catalog []byte
+	logger  hclog.Logger
+}
+
+// NewRoutes creates a new routes handler. The catalog is generated by Load
+// once every route is registered.
+func NewRoutes(l hclog.Logger) *RoutesService {
+	return &RoutesService{catalog: []byte("[]"), logger: l}
+}
+
+// Load generates the catalog from the routes registered with builder, and
+// returns the number of routes
+func (s *RoutesService) Load(builder *RouterBuilder) (int, error) {
+	catalog, err := builder.Catalog()
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := json.Marshal(catalog)
+	if err != nil {
+		return 0, err
+	}
+	s.catalog = body
+	return len(catalog), nil
+}
+
+// ServeHTTP handles incoming requests for the admin routes route
+func (s *RoutesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Routes")
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(s.catalog)
+}
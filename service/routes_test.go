@@ -0,0 +1,40 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func TestRoutesListsTheRoutesIncludingItself(t *testing.T) {
+	router := mux.NewRouter()
+	b := NewRouterBuilder(router, &config.Config{Logger: hclog.NewNullLogger()})
+	admin := b.Group(config.AdminRoutes)
+	admin.Handle("/admin/drain", http.NotFoundHandler()).Methods("GET")
+
+	c := NewRoutes(hclog.NewNullLogger())
+	admin.Handle("/admin/routes", c).Methods("GET")
+	n, err := c.Load(b)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/routes", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	catalog := []RouteInfo{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &catalog))
+	require.Len(t, catalog, 2)
+	assert.Equal(t, "/admin/drain", catalog[0].Path)
+	assert.Equal(t, "/admin/routes", catalog[1].Path)
+	assert.Equal(t, config.AdminRoutes, catalog[1].Group)
+}
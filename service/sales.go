@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// granularities are the buckets of a sales time series by name
+var granularities = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// salesSeries is the response of the /admin/sales route
+type salesSeries struct {
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Granularity string                 `json:"granularity"`
+	Buckets     []entities.SalesBucket `json:"buckets"`
+}
+
+// SalesService is an HTTP Handler returning the orders and revenue recorded
+// per hour or per day as a time series, for dashboards to chart
+type SalesService struct {
+	repository data.Repository
+	logger     hclog.Logger
+	now        func() time.Time
+}
+
+// NewSales creates a new Sales handler
+func NewSales(repository data.Repository, l hclog.Logger) *SalesService {
+	return &SalesService{repository, l, time.Now}
+}
+
+// ServeHTTP handles incoming requests for the /admin/sales route. The from
+// and to query parameters are RFC 3339 times or Unix milliseconds, the way
+// Grafana passes ${__from} and ${__to}, and default to the last 24 hours.
+// The granularity is hour, the default, or day.
+func (s *SalesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Sales")
+
+	query := r.URL.Query()
+	series := salesSeries{To: s.now().UTC(), Granularity: "hour"}
+	if v := query.Get("granularity"); v != "" {
+		series.Granularity = v
+	}
+	granularity, ok := granularities[series.Granularity]
+	to, toErr := parseSalesTime(query.Get("to"), series.To)
+	from, fromErr := parseSalesTime(query.Get("from"), to.Add(-24*time.Hour))
+	if !ok || toErr != nil || fromErr != nil {
+		http.Error(rw, "Sales need from and to as RFC 3339 times or Unix milliseconds, and a granularity of hour or day", http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := data.SalesSeries(r.Context(), s.repository, from, to, granularity)
+	switch err {
+	case nil:
+	case data.ErrInvalidSalesRange:
+		http.Error(rw, "Sales need from before to, at most 2208 buckets apart", http.StatusBadRequest)
+		return
+	case data.ErrStatsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to find sales", "from", from, "to", to, "error", err)
+		http.Error(rw, "Unable to find sales", http.StatusInternalServerError)
+		return
+	}
+	series.From = buckets[0].Time
+	series.To = to.UTC()
+	series.Buckets = buckets
+
+	body, err := json.Marshal(series)
+	if err != nil {
+		s.logger.Error("Unable to encode sales", "error", err)
+		http.Error(rw, "Unable to encode sales", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// parseSalesTime reads an RFC 3339 time or Unix milliseconds, def when empty
+func parseSalesTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
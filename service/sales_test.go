@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupSalesHandler(t *testing.T) *SalesService {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	now := time.Date(2020, 10, 1, 12, 30, 0, 0, time.UTC)
+	for _, order := range []entities.OrderRecord{
+		{ID: 1, Status: entities.OrderPaid, Total: 150, CreatedAt: now.Add(-time.Hour)},
+		{ID: 2, Status: entities.OrderPaid, Total: 200, CreatedAt: now},
+		{ID: 3, Status: entities.OrderCancelled, Total: 150, CreatedAt: now},
+	} {
+		order := order
+		require.NoError(t, data.RecordOrder(context.Background(), repository, &order))
+	}
+
+	s := NewSales(repository, hclog.NewNullLogger())
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSalesReturnsATimeSeries(t *testing.T) {
+	s := setupSalesHandler(t)
+
+	// 1601553600000 is 2020-10-01T12:00:00Z
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/sales?from=2020-10-01T10:15:00Z&to=1601553600001&granularity=hour", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{
+		"from": "2020-10-01T10:00:00Z",
+		"to": "2020-10-01T12:00:00.001Z",
+		"granularity": "hour",
+		"buckets": [
+			{"time": "2020-10-01T10:00:00Z", "orders": 0, "revenue": 0},
+			{"time": "2020-10-01T11:00:00Z", "orders": 1, "revenue": 150},
+			{"time": "2020-10-01T12:00:00Z", "orders": 1, "revenue": 200}
+		]
+	}`, rw.Body.String())
+
+	// the last 24 hours by default
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/sales", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"from":"2020-09-30T12:00:00Z"`)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/sales?granularity=day&from=2020-09-30T00:00:00Z", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `{"time":"2020-10-01T00:00:00Z","orders":2,"revenue":350}`)
+}
+
+func TestSalesRejectsInvalidRanges(t *testing.T) {
+	s := setupSalesHandler(t)
+
+	for _, query := range []string{
+		"granularity=minute",
+		"from=yesterday",
+		"to=2020-10-01",
+		"from=2020-10-02T00:00:00Z",
+		"from=2019-10-01T00:00:00Z",
+	} {
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/sales?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, query)
+	}
+}
+
+func TestSalesUnsupported(t *testing.T) {
+	s := NewSales(&data.MockRepository{}, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/sales", nil))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
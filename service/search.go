@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/search"
+)
+
+const (
+	// defaultSearchLimit is the number of results returned when no limit is requested
+	defaultSearchLimit = 10
+	// maxSearchLimit caps the limit query parameter
+	maxSearchLimit = 50
+)
+
+// NewSearchIndex builds the full-text index from the coffees in the repository
+func NewSearchIndex(repository data.Repository) (*search.Index, error) {
+	coffees, err := repository.Find(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	index := search.NewIndex()
+	for _, coffee := range coffees {
+		index.Add(coffee)
+	}
+
+	return index, nil
+}
+
+// IndexChanges returns a subscriber of the changes feed keeping the index up to
+// date with the coffees of repository, the repository it was built from
+func IndexChanges(index *search.Index, repository data.Repository, l hclog.Logger) func(data.Change) {
+	return func(change data.Change) {
+		coffee, err := changedCoffee(repository, change)
+		switch {
+		case err != nil:
+			l.Error("Unable to update search index", "coffee_id", change.ID, "error", err)
+		case coffee != nil:
+			index.Add(*coffee)
+		case change.Entity == data.ChangeCoffee:
+			index.Remove(change.ID)
+		}
+	}
+}
+
+// changedCoffee returns the coffee of a change as repository serves it, or
+// nil when it serves it no more, e.g. once deleted or unpublished. Changes of
+// other entities return nil too.
+func changedCoffee(repository data.Repository, change data.Change) (*entities.Coffee, error) {
+	if change.Entity != data.ChangeCoffee || change.Op == data.ChangeDelete {
+		return nil, nil
+	}
+	coffee, err := repository.FindByID(context.Background(), change.ID)
+	if err == data.ErrNotFound {
+		return nil, nil
+	}
+	return coffee, err
+}
+
+// SearchService is an HTTP Handler for ranked full-text search
+type SearchService struct {
+	index  *search.Index
+	logger hclog.Logger
+}
+
+// NewSearch creates a new Search handler
+func NewSearch(index *search.Index, l hclog.Logger) *SearchService {
+	return &SearchService{index, l}
+}
+
+// ServeHTTP handles incoming requests for the api search route
+func (s *SearchService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Search")
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(rw, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxSearchLimit {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results := s.index.Search(query, limit)
+	s.logger.Debug(fmt.Sprintf("Found %d search results", len(results)))
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		s.logger.Error("Unable to convert search results to JSON", "error", err)
+		http.Error(rw, "Unable to convert search results to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(resultsJSON)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/search"
+)
+
+func setupSearchHandler(t *testing.T) *SearchService {
+	c := &data.MockRepository{}
+	c.On("Find").Return(entities.Coffees{
+		entities.Coffee{ID: 1, Name: "Packer Spiced Latte"},
+		entities.Coffee{ID: 2, Name: "Nomadicano"},
+	}, nil)
+
+	index, err := NewSearchIndex(c)
+	require.NoError(t, err)
+
+	return NewSearch(index, hclog.Default())
+}
+
+func TestSearchReturnsRankedResults(t *testing.T) {
+	s := setupSearchHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/search?q=latte", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := []search.Result{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Len(t, bd, 1)
+	assert.Equal(t, 1, bd[0].Coffee.ID)
+	assert.Equal(t, "Packer Spiced <em>Latte</em>", bd[0].Highlights["name"])
+}
+
+func TestSearchRequiresQuery(t *testing.T) {
+	s := setupSearchHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/search", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestNewSearchIndexReturnsRepositoryErrors(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("Find").Return(nil, errors.New("boom"))
+
+	_, err := NewSearchIndex(c)
+	assert.Error(t, err)
+}
+
+func searchIDs(t *testing.T, s *SearchService, query string) []int {
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/search?q="+query, nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	results := []search.Result{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &results))
+	ids := []int{}
+	for _, result := range results {
+		ids = append(ids, result.Coffee.ID)
+	}
+	return ids
+}
+
+func TestSearchFollowsWrites(t *testing.T) {
+	l := hclog.NewNullLogger()
+	memory, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	changes := data.NewChanges(memory, 0)
+	published := data.NewPublished(changes)
+
+	index, err := NewSearchIndex(published)
+	require.NoError(t, err)
+	changes.OnChange(IndexChanges(index, published, l))
+	s := NewSearch(index, l)
+
+	rw := httptest.NewRecorder()
+	NewCreate(changes, 0, l).ServeHTTP(rw, httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Oat Flat White","price":300}`)))
+	require.Equal(t, http.StatusCreated, rw.Code)
+	created := entities.Coffee{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &created))
+	// drafts are not on the menu
+	assert.Empty(t, searchIDs(t, s, "oat"))
+
+	rw = httptest.NewRecorder()
+	NewStatus(changes, l).ServeHTTP(rw, statusPut(strconv.Itoa(created.ID), `{"status":"published"}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, []int{created.ID}, searchIDs(t, s, "oat"))
+
+	rw = httptest.NewRecorder()
+	NewBulkDelete(changes, l).ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/coffees?filter=price%3D300", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, searchIDs(t, s, "oat"))
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
+	"github.com/hashicorp-demoapp/coffee-service/clients"
 	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/ingredients"
 	v1 "github.com/hashicorp-demoapp/coffee-service/service/v1"
 	v2 "github.com/hashicorp-demoapp/coffee-service/service/v2"
 	v3 "github.com/hashicorp-demoapp/coffee-service/service/v3"
@@ -20,37 +24,122 @@ type CoffeeService struct {
 	logger     hclog.Logger
 }
 
-// NewCoffee is a factory method that returns a configured handler for the
-// configured ServiceVersion
-func NewCoffee(cfg *config.Config) (http.Handler, error) {
+// NewRaftRepository starts the Raft replica of the in memory backend named by
+// RAFT_NODE_ID
+func NewRaftRepository(cfg *config.Config) (*data.RaftRepository, error) {
+	peers, err := data.ParseRaftPeers(cfg.RaftPeers)
+	if err != nil {
+		return nil, err
+	}
+	client, err := clients.New(clients.FromConfig(cfg, "raft"))
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewRaft(cfg, data.RaftOptions{
+		NodeID:      cfg.RaftNodeID,
+		BindAddress: cfg.RaftBindAddress,
+		Peers:       peers,
+		Client:      client,
+		AuthToken:   cfg.AuthToken,
+		Logger:      cfg.Logger,
+	})
+}
+
+// NewRepository is a factory method that returns the data.Repository backing
+// the configured ServiceVersion. A non nil base replaces the backend of the
+// version, e.g. a RaftRepository.
+func NewRepository(cfg *config.Config, base data.Repository) (data.Repository, error) {
 	var repository data.Repository
 	var err error
 
 	cfg.Logger.Debug(fmt.Sprintf("Resolving repository for version %v", cfg.Version))
-	if cfg.Version == config.V1 || cfg.Version == config.V2 {
-		cfg.Logger.Debug("Loading Postgres")
-		if repository, err = data.NewFromConfig(cfg); err != nil {
-			cfg.Logger.Debug(fmt.Sprintf("Error loading postgres %+v", err))
+	if base != nil {
+		cfg.Logger.Debug("Using the configured base repository")
+		repository = base
+	} else {
+		cfg.Logger.Debug("Loading backend", "backend", cfg.Backend())
+		if repository, err = data.NewBackend(cfg, cfg.Backend()); err != nil {
+			cfg.Logger.Debug("Error loading backend", "backend", cfg.Backend(), "error", err)
+			return nil, err
+		}
+	}
+
+	if cfg.DBReadRetries > 0 || cfg.DBWriteRetries > 0 {
+		cfg.Logger.Debug("Retrying transient repository errors", "reads", cfg.DBReadRetries, "writes", cfg.DBWriteRetries)
+		repository = data.NewRetrying(repository, data.RetryingOptions{
+			Reads:   data.RetryPolicy{Retries: cfg.DBReadRetries, Backoff: cfg.DBRetryBackoff, MaxBackoff: cfg.DBRetryMaxBackoff, Budget: cfg.DBRetryBudget},
+			Writes:  data.RetryPolicy{Retries: cfg.DBWriteRetries, Backoff: cfg.DBRetryBackoff, MaxBackoff: cfg.DBRetryMaxBackoff, Budget: cfg.DBRetryBudget},
+			Logger:  cfg.Logger,
+			Metrics: cfg.Metrics,
+		})
+	}
+
+	if cfg.MigrationBackend != "" {
+		cfg.Logger.Debug("Migrating to a new backend", "from", cfg.Backend(), "to", cfg.MigrationBackend, "read", cfg.MigrationRead)
+		target, err := data.NewBackend(cfg, cfg.MigrationBackend)
+		if err != nil {
 			return nil, err
 		}
-	} else if cfg.Version == config.V3 {
-		fmt.Printf("==> MEMORY\n")
-		cfg.Logger.Debug("Loading in memory db")
-		if repository, err = data.NewInMemoryDB(cfg); err != nil {
-			cfg.Logger.Debug(fmt.Sprintf("Error loading in memory db %+v", err))
+		if repository, err = data.NewMigrating(context.Background(), repository, target, data.MigratingOptions{
+			ReadNew: cfg.MigrationRead == "new",
+			Logger:  cfg.Logger,
+			Metrics: cfg.Metrics,
+		}); err != nil {
 			return nil, err
 		}
 	}
 
+	if cfg.ShadowBackend != "" {
+		cfg.Logger.Debug("Comparing reads with a shadow backend", "backend", cfg.ShadowBackend, "sample", cfg.ShadowSample)
+		shadow, err := data.NewBackend(cfg, cfg.ShadowBackend)
+		if err != nil {
+			return nil, err
+		}
+		repository = data.NewShadow(repository, shadow, data.ShadowOptions{
+			Sample:  cfg.ShadowSample,
+			Logger:  cfg.Logger,
+			Metrics: cfg.Metrics,
+		})
+	}
+
+	// applied last, ingredients come from the remote service whatever the
+	// backend
+	if cfg.IngredientsAddress != "" {
+		cfg.Logger.Debug("Reading ingredients from the ingredients service", "address", cfg.IngredientsAddress)
+		// the ingredients client times out, retries and hedges attempts itself
+		options := clients.FromConfig(cfg, "ingredients")
+		options.Timeout, options.Retries = 0, 0
+		client, err := clients.New(options)
+		if err != nil {
+			return nil, err
+		}
+		repository = data.NewRemoteIngredients(repository, ingredients.NewClient(ingredients.Options{
+			Address:     cfg.IngredientsAddress,
+			Timeout:     cfg.IngredientsTimeout,
+			HedgeDelay:  cfg.IngredientsHedge,
+			Retries:     cfg.IngredientsRetries,
+			RetryBudget: cfg.IngredientsBudget,
+			Client:      client,
+			Metrics:     cfg.Metrics,
+		}))
+	}
+
+	return repository, nil
+}
+
+// NewCoffee is a factory method that returns a configured handler for the
+// configured ServiceVersion
+func NewCoffee(cfg *config.Config, repository data.Repository, tracker *popularity.Tracker) (http.Handler, error) {
 	cfg.Logger.Debug(fmt.Sprintf("Resolving service for version %v", cfg.Version))
 	var handler http.Handler
 	switch cfg.Version {
 	case config.V1:
-		handler = v1.NewCoffeeService(repository, cfg.Logger)
+		handler = v1.NewCoffeeService(repository, tracker, cfg.Logger)
 	case config.V2:
-		handler = v2.NewCoffeeService(repository, cfg.Logger)
+		handler = v2.NewCoffeeService(repository, tracker, cfg.Logger)
 	case config.V3:
-		handler = v3.NewCoffeeService(repository, cfg.Logger)
+		handler = v3.NewCoffeeService(repository, tracker, cfg.Logger)
 	}
 
 	return handler, nil
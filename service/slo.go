@@ -0,0 +1,37 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/slo"
+)
+
+// SLOService is an HTTP Handler reporting the SLIs, burn rates and remaining
+// error budgets of every endpoint
+type SLOService struct {
+	tracker *slo.Tracker
+	logger  hclog.Logger
+}
+
+// NewSLO creates a new SLO handler
+func NewSLO(tracker *slo.Tracker, l hclog.Logger) *SLOService {
+	return &SLOService{tracker, l}
+}
+
+// ServeHTTP handles incoming requests for the admin slo route
+func (s *SLOService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle SLO")
+
+	body, err := json.Marshal(s.tracker.Report())
+	if err != nil {
+		s.logger.Error("Unable to encode SLO report", "error", err)
+		http.Error(rw, "Unable to encode SLO report", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// SlowQueriesService is an HTTP Handler for the repository calls kept by the
+// slow query log. GET returns them, the most recent first, and DELETE drops
+// them to start a debugging session afresh.
+type SlowQueriesService struct {
+	log    *data.SlowQueryLog
+	logger hclog.Logger
+}
+
+// NewSlowQueries creates a new SlowQueries handler
+func NewSlowQueries(log *data.SlowQueryLog, l hclog.Logger) *SlowQueriesService {
+	return &SlowQueriesService{log, l}
+}
+
+// ServeHTTP handles incoming requests for the /admin/slow-queries route
+func (s *SlowQueriesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle SlowQueries", "method", r.Method)
+
+	if r.Method == http.MethodDelete {
+		s.log.Reset()
+		s.logger.Info("Slow queries reset")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := json.Marshal(s.log.Queries())
+	if err != nil {
+		s.logger.Error("Unable to encode slow queries", "error", err)
+		http.Error(rw, "Unable to encode slow queries", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+func TestSlowQueriesAreListedAndReset(t *testing.T) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	log := data.NewSlowQueryLog(0, 10)
+	ctx := data.WithSlowQueryLog(context.Background(), log, func(context.Context) string { return "4bf92f3577b34da6" })
+	_, err = repository.FindByID(ctx, 1)
+	require.NoError(t, err)
+	s := NewSlowQueries(log, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/slow-queries", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	queries := []data.SlowQuery{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &queries))
+	require.NotEmpty(t, queries)
+	assert.Equal(t, "4bf92f3577b34da6", queries[0].TraceID)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("DELETE", "/admin/slow-queries", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/admin/slow-queries", nil))
+	assert.JSONEq(t, `[]`, rw.Body.String())
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// statusRequest is the body moving a coffee to another status
+type statusRequest struct {
+	Status string `json:"status"`
+}
+
+// StatusService is an HTTP Handler moving a coffee through the publishing
+// workflow, e.g. publishing a draft. The repository rejects a status which is
+// not reachable from the current one.
+type StatusService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewStatus creates a new Status handler
+func NewStatus(repository data.Repository, l hclog.Logger) *StatusService {
+	return &StatusService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coffee status route
+func (s *StatusService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Status")
+
+	coffeeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid coffee id", http.StatusBadRequest)
+		return
+	}
+	request := statusRequest{}
+	if err := newDecoder(r, r.Body).Decode(&request); err != nil || request.Status == "" {
+		invalidPayload(rw, "Invalid status", err)
+		return
+	}
+
+	coffee, err := s.repository.FindByID(r.Context(), coffeeID)
+	if err == nil {
+		previous := coffee.Status
+		coffee.Status = request.Status
+		if err = s.repository.UpdateCoffee(r.Context(), coffee); err == data.ErrInvalidTransition {
+			allowed := strings.Join(data.Transitions(previous), ", ")
+			http.Error(rw, fmt.Sprintf("A %s coffee can only become %s", previous, allowed), http.StatusConflict)
+			return
+		}
+		if err == nil {
+			s.logger.Info("Coffee status changed", "coffee_id", coffeeID, "from", previous, "to", coffee.Status)
+		}
+	}
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Coffee not found", http.StatusNotFound)
+		return
+	case data.ErrInvalidStatus:
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to change coffee status", "coffee_id", coffeeID, "error", err)
+		http.Error(rw, "Unable to change coffee status", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(coffee)
+	if err != nil {
+		s.logger.Error("Unable to encode coffee", "error", err)
+		http.Error(rw, "Unable to encode coffee", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
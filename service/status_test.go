@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/mocks"
+)
+
+func setupStatusHandler(t *testing.T) (*StatusService, data.Repository, *entities.Coffee) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	draft := &entities.Coffee{Name: "Sentinel Cold Brew", Price: 300}
+	require.NoError(t, repository.CreateCoffee(context.Background(), draft))
+	return NewStatus(repository, hclog.NewNullLogger()), repository, draft
+}
+
+func statusPut(id, body string) *http.Request {
+	r := httptest.NewRequest("PUT", "/admin/coffees/"+id+"/status", strings.NewReader(body))
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func TestStatusPublishesDrafts(t *testing.T) {
+	handler, repository, draft := setupStatusHandler(t)
+	menu := data.NewPublished(repository)
+	id := strconv.Itoa(draft.ID)
+
+	_, err := menu.FindByID(context.Background(), draft.ID)
+	assert.Equal(t, data.ErrNotFound, err)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut(id, `{"status":"published"}`))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), `"status":"published"`)
+
+	published, err := menu.FindByID(context.Background(), draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Sentinel Cold Brew", published.Name)
+}
+
+func TestStatusRejectsInvalidTransitions(t *testing.T) {
+	handler, _, draft := setupStatusHandler(t)
+	id := strconv.Itoa(draft.ID)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut("1", `{"status":"draft"}`))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	assert.Contains(t, rw.Body.String(), "A published coffee can only become retired")
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut(id, `{"status":"archived"}`))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut(id, `{}`))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut("42", `{"status":"published"}`))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestStatusRejectsInvalidCoffees(t *testing.T) {
+	// a coffee stored before prices were required
+	repository := &mocks.Repository{
+		FindByIDFunc: func(ctx context.Context, coffeeID int) (*entities.Coffee, error) {
+			return &entities.Coffee{ID: coffeeID, Name: "Legacy Latte", Status: data.StatusDraft}, nil
+		},
+		UpdateCoffeeFunc: func(ctx context.Context, coffee *entities.Coffee) error {
+			return coffee.Validate()
+		},
+	}
+	handler := NewStatus(repository, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, statusPut("1", `{"status":"published"}`))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "invalid coffee, price must be positive\n", rw.Body.String())
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+)
+
+// StoresService is an HTTP Handler listing the coffee shops
+type StoresService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewStores creates a new Stores handler
+func NewStores(repository data.Repository, l hclog.Logger) *StoresService {
+	return &StoresService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api stores route
+func (s *StoresService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Stores")
+
+	stores, err := data.FindStores(r.Context(), s.repository)
+	if err == data.ErrStoresUnsupported {
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to get stores from database", "error", err)
+		http.Error(rw, "Unable to get stores from database", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(stores)
+	if err != nil {
+		s.logger.Error("Unable to encode stores", "error", err)
+		http.Error(rw, "Unable to encode stores", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// StoreCoffeesService is an HTTP Handler returning the menu of a coffee shop
+type StoreCoffeesService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewStoreCoffees creates a new StoreCoffees handler. The repository limits
+// the reads of a context carrying a store to its coffees, e.g. a
+// data.StoreScopedRepository.
+func NewStoreCoffees(repository data.Repository, l hclog.Logger) *StoreCoffeesService {
+	return &StoreCoffeesService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api store coffees route
+func (s *StoreCoffeesService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Store Coffees")
+
+	storeID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid store id", http.StatusBadRequest)
+		return
+	}
+
+	// the store of the path wins over an X-Store header
+	ctx := data.WithStore(r.Context(), storeID)
+	_, err = data.FindStore(ctx, s.repository, storeID)
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Store not found", http.StatusNotFound)
+		return
+	case data.ErrStoresUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to get store from database", "store_id", storeID, "error", err)
+		http.Error(rw, "Unable to get store from database", http.StatusInternalServerError)
+		return
+	}
+
+	coffees, err := s.repository.Find(ctx)
+	if err != nil {
+		s.logger.Error("Unable to get coffees from database", "store_id", storeID, "error", err)
+		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+		return
+	}
+	if err := data.Localize(ctx, s.repository, coffees, data.LocaleChain(r.Header.Get("Accept-Language"))); err != nil {
+		s.logger.Error("Unable to get coffee translations from database", "error", err)
+		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Debug(fmt.Sprintf("Found %d coffees in store %d", len(coffees), storeID))
+
+	encoder := encoding.Default.Negotiate(r.Header.Get("Accept"), &coffees)
+	body, err := encoder.Encode(&coffees)
+	if err != nil {
+		s.logger.Error("Unable to encode coffees", "content_type", encoder.ContentType(), "error", err)
+		http.Error(rw, "Unable to encode coffees", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", encoder.ContentType())
+	rw.Header().Add("Vary", "Accept")
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Write(body)
+}
+
+// defaultNearbyRadiusKm is the radius of a nearby search which does not
+// request one
+const defaultNearbyRadiusKm = 25
+
+// NearbyStoresService is an HTTP Handler returning the coffee shops around a
+// point, nearest first
+type NearbyStoresService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewNearbyStores creates a new NearbyStores handler
+func NewNearbyStores(repository data.Repository, l hclog.Logger) *NearbyStoresService {
+	return &NearbyStoresService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api nearby stores route
+func (s *NearbyStoresService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Nearby Stores")
+
+	query := r.URL.Query()
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(query.Get("lon"), 64)
+	radius, radiusErr := float64(defaultNearbyRadiusKm), error(nil)
+	if raw := query.Get("radius_km"); raw != "" {
+		radius, radiusErr = strconv.ParseFloat(raw, 64)
+	}
+	if latErr != nil || lonErr != nil || radiusErr != nil {
+		http.Error(rw, "lat and lon are required, radius_km is optional, all as decimal numbers", http.StatusBadRequest)
+		return
+	}
+
+	stores, err := data.FindStoresNear(r.Context(), s.repository, lat, lon, radius)
+	switch err {
+	case nil:
+	case data.ErrInvalidLocation:
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	case data.ErrStoresUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to get nearby stores from database", "error", err)
+		http.Error(rw, "Unable to get stores from database", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Debug(fmt.Sprintf("Found %d stores within %g km", len(stores), radius))
+
+	body, err := json.Marshal(stores)
+	if err != nil {
+		s.logger.Error("Unable to encode stores", "error", err)
+		http.Error(rw, "Unable to encode stores", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
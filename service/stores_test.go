@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupStoresMenu(t *testing.T) data.Repository {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return data.NewStoreScoped(repository)
+}
+
+func TestStoresAreListed(t *testing.T) {
+	handler := NewStores(setupStoresMenu(t), hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/stores", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	stores := entities.Stores{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stores))
+	require.Len(t, stores, 3)
+	assert.Equal(t, "Market Street", stores[0].Name)
+	assert.Equal(t, "San Francisco", stores[0].City)
+}
+
+func storeCoffeesRequest(id string) *http.Request {
+	r := httptest.NewRequest("GET", "/stores/"+id+"/coffees", nil)
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func TestStoreCoffeesServesTheMenuOfAStore(t *testing.T) {
+	handler := NewStoreCoffees(setupStoresMenu(t), hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, storeCoffeesRequest("3"))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	coffees := entities.Coffees{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &coffees))
+	names := []string{}
+	for _, c := range coffees {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"Packer Spiced Latte", "Nomadicano", "Connectaccino"}, names)
+
+	// the path wins over the header
+	r := storeCoffeesRequest("2")
+	r = r.WithContext(data.WithStore(r.Context(), 3))
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &coffees))
+	assert.Len(t, coffees, 4)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, storeCoffeesRequest("42"))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestNearbyStoresAreSortedByDistance(t *testing.T) {
+	handler := NewNearbyStores(setupStoresMenu(t), hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/stores/nearby?lat=52.3731&lon=4.8926&radius_km=400", nil))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	stores := entities.Stores{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stores))
+	require.Len(t, stores, 2)
+	assert.Equal(t, "Prinsengracht", stores[0].Name)
+	assert.Equal(t, "Shoreditch", stores[1].Name)
+	assert.Less(t, stores[0].DistanceKm, stores[1].DistanceKm)
+
+	// the default radius holds the nearest store only
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/stores/nearby?lat=52.3731&lon=4.8926", nil))
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stores))
+	assert.Len(t, stores, 1)
+
+	for _, query := range []string{"lon=4.8926", "lat=52.3&lon=east", "lat=52.3&lon=4.8&radius_km=-1", "lat=-91&lon=0"} {
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/stores/nearby?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rw.Code, query)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/data/suggest"
+)
+
+const (
+	// defaultSuggestLimit is the number of suggestions returned when no limit is requested
+	defaultSuggestLimit = 5
+	// maxSuggestLimit caps the limit query parameter
+	maxSuggestLimit = 20
+)
+
+// NewSuggestTrie builds the autocomplete trie from the coffees in the
+// repository, ranking suggestions by the order counts of the tracker
+func NewSuggestTrie(repository data.Repository, tracker *popularity.Tracker) (*suggest.Trie, error) {
+	coffees, err := repository.Find(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	trie := suggest.NewTrie()
+	for _, coffee := range coffees {
+		trie.Add(coffee.ID, coffee.Name)
+		trie.SetPopularity(coffee.ID, tracker.Stats(coffee.ID).Orders)
+	}
+
+	tracker.OnChange(func(coffeeID int, stats entities.CoffeeStats) {
+		trie.SetPopularity(coffeeID, stats.Orders)
+	})
+
+	return trie, nil
+}
+
+// SuggestChanges returns a subscriber of the changes feed keeping the trie up
+// to date with the coffees of repository, the repository it was built from
+func SuggestChanges(trie *suggest.Trie, repository data.Repository, tracker *popularity.Tracker, l hclog.Logger) func(data.Change) {
+	return func(change data.Change) {
+		coffee, err := changedCoffee(repository, change)
+		switch {
+		case err != nil:
+			l.Error("Unable to update suggest trie", "coffee_id", change.ID, "error", err)
+		case coffee != nil:
+			trie.Add(coffee.ID, coffee.Name)
+			trie.SetPopularity(coffee.ID, tracker.Stats(coffee.ID).Orders)
+		case change.Entity == data.ChangeCoffee:
+			trie.Remove(change.ID)
+		}
+	}
+}
+
+// SuggestService is an HTTP Handler for coffee name autocompletion
+type SuggestService struct {
+	trie   *suggest.Trie
+	logger hclog.Logger
+}
+
+// NewSuggest creates a new Suggest handler
+func NewSuggest(trie *suggest.Trie, l hclog.Logger) *SuggestService {
+	return &SuggestService{trie, l}
+}
+
+// ServeHTTP handles incoming requests for the api coffees suggest route
+func (s *SuggestService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Suggest")
+
+	prefix := r.URL.Query().Get("q")
+	if prefix == "" {
+		http.Error(rw, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSuggestLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxSuggestLimit {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxSuggestLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	suggestionsJSON, err := json.Marshal(s.trie.Suggest(prefix, limit))
+	if err != nil {
+		s.logger.Error("Unable to convert suggestions to JSON", "error", err)
+		http.Error(rw, "Unable to convert suggestions to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(suggestionsJSON)
+}
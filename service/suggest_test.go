@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/data/suggest"
+)
+
+func setupSuggestHandler(t *testing.T) *SuggestService {
+	c := &data.MockRepository{}
+	c.On("Find").Return(entities.Coffees{
+		entities.Coffee{ID: 2, Name: "Vaulatte"},
+		entities.Coffee{ID: 5, Name: "Vagrante espresso"},
+		entities.Coffee{ID: 6, Name: "Connectaccino"},
+	}, nil)
+
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+	tracker.RecordOrder(2)
+
+	trie, err := NewSuggestTrie(c, tracker)
+	require.NoError(t, err)
+
+	return NewSuggest(trie, hclog.Default())
+}
+
+func TestSuggestReturnsMatchingNames(t *testing.T) {
+	s := setupSuggestHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/suggest?q=va&limit=1", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := []suggest.Suggestion{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, []suggest.Suggestion{{ID: 2, Name: "Vaulatte", Popularity: 1}}, bd)
+}
+
+func TestSuggestRequiresQuery(t *testing.T) {
+	s := setupSuggestHandler(t)
+	rw := httptest.NewRecorder()
+
+	s.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/suggest", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestSuggestFollowsWrites(t *testing.T) {
+	l := hclog.NewNullLogger()
+	memory, err := data.NewInMemoryDB(&config.Config{Logger: l})
+	require.NoError(t, err)
+	changes := data.NewChanges(memory, 0)
+	published := data.NewPublished(changes)
+	tracker, err := popularity.NewTracker("", l)
+	require.NoError(t, err)
+
+	trie, err := NewSuggestTrie(published, tracker)
+	require.NoError(t, err)
+	changes.OnChange(SuggestChanges(trie, published, tracker, l))
+
+	coffee := &entities.Coffee{Name: "Oat Flat White", Price: 300, Status: data.StatusPublished}
+	require.NoError(t, changes.CreateCoffee(context.Background(), coffee))
+	assert.Len(t, trie.Suggest("oat", 5), 1)
+
+	coffee.Name = "Soy Flat White"
+	require.NoError(t, changes.UpdateCoffee(context.Background(), coffee))
+	assert.Empty(t, trie.Suggest("oat", 5))
+	assert.Len(t, trie.Suggest("soy", 5), 1)
+
+	require.NoError(t, changes.DeleteCoffee(context.Background(), coffee.ID))
+	assert.Empty(t, trie.Suggest("soy", 5))
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// SuppliersService is an HTTP Handler listing the suppliers of the
+// ingredients, limited by ?certified= to those holding a certification
+type SuppliersService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewSuppliers creates a new Suppliers handler
+func NewSuppliers(repository data.Repository, l hclog.Logger) *SuppliersService {
+	return &SuppliersService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api suppliers route
+func (s *SuppliersService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Suppliers")
+
+	certification := r.URL.Query().Get("certified")
+	suppliers, err := data.FindSuppliers(r.Context(), s.repository, certification)
+	if err == data.ErrSuppliersUnsupported {
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Unable to get suppliers from database", "error", err)
+		http.Error(rw, "Unable to get suppliers from database", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Debug(fmt.Sprintf("Found %d suppliers", len(suppliers)), "certified", certification)
+
+	body, err := json.Marshal(suppliers)
+	if err != nil {
+		s.logger.Error("Unable to encode suppliers", "error", err)
+		http.Error(rw, "Unable to encode suppliers", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
+
+// IngredientSupplierService is an HTTP Handler returning the supplier of an
+// ingredient
+type IngredientSupplierService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewIngredientSupplier creates a new IngredientSupplier handler
+func NewIngredientSupplier(repository data.Repository, l hclog.Logger) *IngredientSupplierService {
+	return &IngredientSupplierService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the api ingredient supplier route
+func (s *IngredientSupplierService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Ingredient Supplier")
+
+	ingredientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid ingredient id", http.StatusBadRequest)
+		return
+	}
+
+	supplier, err := data.FindIngredientSupplier(r.Context(), s.repository, ingredientID)
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, "Supplier not found", http.StatusNotFound)
+		return
+	case data.ErrSuppliersUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		s.logger.Error("Unable to get supplier from database", "ingredient_id", ingredientID, "error", err)
+		http.Error(rw, "Unable to get supplier from database", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(supplier)
+	if err != nil {
+		s.logger.Error("Unable to encode supplier", "error", err)
+		http.Error(rw, "Unable to encode supplier", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func setupSuppliers(t *testing.T) data.Repository {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return repository
+}
+
+func TestSuppliersAreFilteredByCertification(t *testing.T) {
+	handler := NewSuppliers(setupSuppliers(t), hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/suppliers", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	suppliers := entities.Suppliers{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &suppliers))
+	assert.Len(t, suppliers, 3)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/suppliers?certified=fairtrade", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	suppliers = entities.Suppliers{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &suppliers))
+	require.Len(t, suppliers, 1)
+	assert.Equal(t, "Finca La Esperanza", suppliers[0].Name)
+	assert.Equal(t, []int{1}, suppliers[0].IngredientIDs)
+}
+
+func TestSuppliersUnsupported(t *testing.T) {
+	handler := NewSuppliers(&data.MockRepository{}, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/suppliers", nil))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
+
+func ingredientSupplierRequest(id string) *http.Request {
+	r := httptest.NewRequest("GET", "/ingredients/"+id+"/supplier", nil)
+	return mux.SetURLVars(r, map[string]string{"id": id})
+}
+
+func TestIngredientSupplierIsReturned(t *testing.T) {
+	handler := NewIngredientSupplier(setupSuppliers(t), hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, ingredientSupplierRequest("2"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	supplier := entities.Supplier{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &supplier))
+	assert.Equal(t, "Meadowbank Dairy", supplier.Name)
+	assert.Equal(t, "GB", supplier.Country)
+	assert.Equal(t, []string{"organic"}, supplier.Certifications)
+
+	// hot water has no supplier
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, ingredientSupplierRequest("3"))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, ingredientSupplierRequest("milk"))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// translationRequest is the body setting a translation
+type translationRequest struct {
+	Value string `json:"value"`
+}
+
+// TranslationsService is an HTTP Handler managing the translations of the
+// coffee names and teasers. GET lists the translations of a coffee, PUT sets
+// the translation of a field into a locale and DELETE removes it.
+type TranslationsService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewTranslations creates a new Translations handler
+func NewTranslations(repository data.Repository, l hclog.Logger) *TranslationsService {
+	return &TranslationsService{repository, l}
+}
+
+// ServeHTTP handles incoming requests for the admin coffee translations routes
+func (s *TranslationsService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Translations", "method", r.Method)
+
+	vars := mux.Vars(r)
+	coffeeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(rw, "Invalid coffee id", http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	notFound := "Coffee not found"
+	switch r.Method {
+	case http.MethodPut:
+		request := translationRequest{}
+		if err := newDecoder(r, r.Body).Decode(&request); err != nil {
+			invalidPayload(rw, "Invalid translation", err)
+			return
+		}
+		translation := &entities.Translation{CoffeeID: coffeeID, Locale: vars["locale"], Field: vars["field"], Value: request.Value}
+		if err = data.SetTranslation(r.Context(), s.repository, translation); err == nil {
+			s.logger.Info("Translation set", "coffee_id", coffeeID, "locale", translation.Locale, "field", translation.Field)
+		}
+		result = translation
+	case http.MethodDelete:
+		notFound = "Translation not found"
+		if err = data.DeleteTranslation(r.Context(), s.repository, coffeeID, vars["locale"], vars["field"]); err == nil {
+			s.logger.Info("Translation deleted", "coffee_id", coffeeID, "locale", vars["locale"], "field", vars["field"])
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		if _, err = s.repository.FindByID(r.Context(), coffeeID); err == nil {
+			result, err = data.FindTranslations(r.Context(), s.repository, []int{coffeeID}, nil)
+		}
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		http.Error(rw, notFound, http.StatusNotFound)
+		return
+	case data.ErrInvalidTranslation:
+		http.Error(rw, "Translations need a valid locale, a field of name or teaser and a value", http.StatusBadRequest)
+		return
+	case data.ErrTranslationsUnsupported:
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	default:
+		if writeFailed(rw, err) {
+			return
+		}
+		s.logger.Error("Unable to manage translations", "method", r.Method, "coffee_id", coffeeID, "error", err)
+		http.Error(rw, "Unable to manage translations", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("Unable to encode translations", "error", err)
+		http.Error(rw, "Unable to encode translations", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+)
+
+func setupTranslationsHandler(t *testing.T) (*TranslationsService, data.Repository) {
+	repository, err := data.NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+
+	return NewTranslations(repository, hclog.NewNullLogger()), repository
+}
+
+func translationsRequest(method, id, locale, field, body string) *http.Request {
+	path := "/admin/coffees/" + id + "/translations"
+	vars := map[string]string{"id": id}
+	if locale != "" {
+		path += "/" + locale + "/" + field
+		vars["locale"], vars["field"] = locale, field
+	}
+	return mux.SetURLVars(httptest.NewRequest(method, path, strings.NewReader(body)), vars)
+}
+
+func TestTranslationsAreSetListedAndDeleted(t *testing.T) {
+	handler, _ := setupTranslationsHandler(t)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("PUT", "1", "fr_ca", "name", `{"value":"Latte épicé Packer"}`))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), `"locale":"fr-CA"`)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("GET", "1", "", "", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.Contains(t, rw.Body.String(), `"value":"Latte épicé Packer"`)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("DELETE", "1", "fr-CA", "name", ""))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("DELETE", "1", "fr-CA", "name", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Contains(t, rw.Body.String(), "Translation not found")
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("GET", "1", "", "", ""))
+	assert.JSONEq(t, `[]`, rw.Body.String())
+}
+
+func TestTranslationsRejectInvalidRequests(t *testing.T) {
+	handler, _ := setupTranslationsHandler(t)
+
+	for name, r := range map[string]*http.Request{
+		"field":  translationsRequest("PUT", "1", "fr", "price", `{"value":"1"}`),
+		"locale": translationsRequest("PUT", "1", "not-a-locale!", "name", `{"value":"Latte"}`),
+		"value":  translationsRequest("PUT", "1", "fr", "name", `{"value":""}`),
+		"body":   translationsRequest("PUT", "1", "fr", "name", `{`),
+	} {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		assert.Equal(t, http.StatusBadRequest, rw.Code, name)
+	}
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("PUT", "42", "fr", "name", `{"value":"Inconnu"}`))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Contains(t, rw.Body.String(), "Coffee not found")
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("GET", "42", "", "", ""))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestTranslationsNeedATranslator(t *testing.T) {
+	handler := NewTranslations(&data.MockRepository{}, hclog.NewNullLogger())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, translationsRequest("PUT", "1", "fr", "name", `{"value":"Latte"}`))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
+
+func TestCoffeesAreLocalizedByAcceptLanguage(t *testing.T) {
+	_, repository := setupTranslationsHandler(t)
+	require.NoError(t, data.SetTranslation(context.Background(), repository, &entities.Translation{CoffeeID: 1, Locale: "fr", Field: data.TranslationName, Value: "Latte épicé Packer"}))
+
+	list, err := NewCoffee(&config.Config{Version: config.V3, Logger: hclog.NewNullLogger()}, repository, nil)
+	require.NoError(t, err)
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+	detail := NewDetail(repository, tracker, hclog.NewNullLogger())
+
+	for name, handler := range map[string]http.Handler{"list": list, "detail": detail} {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1", nil), map[string]string{"id": "1"})
+		r.Header.Set("Accept-Language", "fr-CA, en;q=0.5")
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		require.Equal(t, http.StatusOK, rw.Code, name)
+		assert.Contains(t, rw.Body.String(), `"name":"Latte épicé Packer"`, name)
+		assert.Contains(t, rw.Header()["Vary"], "Accept-Language", name)
+
+		r = mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1", nil), map[string]string{"id": "1"})
+		r.Header.Set("Accept-Language", "de")
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		assert.Contains(t, rw.Body.String(), `"name":"Packer Spiced Latte"`, name)
+	}
+}
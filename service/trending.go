@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
+)
+
+const (
+	// defaultTrendingLimit is the number of coffees returned when no limit is requested
+	defaultTrendingLimit = 5
+	// maxTrendingLimit caps the limit query parameter
+	maxTrendingLimit = 20
+)
+
+// TrendingService is an HTTP Handler returning the coffees with the highest
+// time decayed popularity scores
+type TrendingService struct {
+	repository data.Repository
+	popularity *popularity.Tracker
+	logger     hclog.Logger
+}
+
+// NewTrending creates a new Trending handler
+func NewTrending(repository data.Repository, tracker *popularity.Tracker, l hclog.Logger) *TrendingService {
+	return &TrendingService{repository, tracker, l}
+}
+
+// ServeHTTP handles incoming requests for the api coffees trending route
+func (s *TrendingService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Handle Trending Coffees")
+
+	limit := defaultTrendingLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxTrendingLimit {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxTrendingLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	coffees := make(entities.Coffees, 0, limit)
+	for _, id := range s.popularity.Trending(limit) {
+		coffee, err := s.repository.FindByID(r.Context(), id)
+		if err == data.ErrNotFound {
+			// counters can outlive a deleted coffee
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Unable to get coffee from database", "error", err)
+			http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+			return
+		}
+
+		coffees = append(coffees, *coffee)
+	}
+	if err := data.Localize(r.Context(), s.repository, coffees, data.LocaleChain(r.Header.Get("Accept-Language"))); err != nil {
+		s.logger.Error("Unable to get coffee translations from database", "error", err)
+		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+		return
+	}
+	s.popularity.Annotate(coffees)
+
+	encoder := encoding.Default.Negotiate(r.Header.Get("Accept"), &coffees)
+	body, err := encoder.Encode(&coffees)
+	if err != nil {
+		s.logger.Error("Unable to encode coffees", "content_type", encoder.ContentType(), "error", err)
+		http.Error(rw, "Unable to encode coffees", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", encoder.ContentType())
+	rw.Header().Add("Vary", "Accept")
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Write(body)
+}
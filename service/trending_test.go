@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+)
+
+func TestTrendingReturnsCoffeesByScore(t *testing.T) {
+	c := &data.MockRepository{}
+	c.On("FindByID", 1).Return(&entities.Coffee{ID: 1, Name: "Viewed"}, nil)
+	c.On("FindByID", 2).Return(&entities.Coffee{ID: 2, Name: "Ordered"}, nil)
+	c.On("FindByID", 3).Return(nil, data.ErrNotFound)
+
+	tracker, err := popularity.NewTracker("", hclog.NewNullLogger())
+	require.NoError(t, err)
+	tracker.RecordView(1)
+	tracker.RecordOrder(2)
+	tracker.RecordOrder(3)
+
+	rw := httptest.NewRecorder()
+	NewTrending(c, tracker, hclog.Default()).ServeHTTP(rw, httptest.NewRequest("GET", "/coffees/trending", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffees{}
+	err = json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Len(t, bd, 2)
+	assert.Equal(t, "Ordered", bd[0].Name)
+	assert.Equal(t, int64(1), bd[0].Stats.Orders)
+	assert.Equal(t, "Viewed", bd[1].Name)
+}
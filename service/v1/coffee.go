@@ -7,17 +7,23 @@ import (
 	hclog "github.com/hashicorp/go-hclog"
 
 	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/filter"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
 )
 
 // CoffeeService is the service implementation for this microservice.
 type CoffeeService struct {
 	repository data.Repository
+	popularity *popularity.Tracker
 	logger     hclog.Logger
 }
 
 // NewCoffeeService is a factory method that returns a new instance of the CoffeeService.
-func NewCoffeeService(repository data.Repository, l hclog.Logger) *CoffeeService {
-	return &CoffeeService{repository, l}
+// The popularity tracker is optional and only used when stats are requested.
+func NewCoffeeService(repository data.Repository, tracker *popularity.Tracker, l hclog.Logger) *CoffeeService {
+	return &CoffeeService{repository, tracker, l}
 }
 
 // ServeHTTP handles incoming requests for the api coffees route
@@ -25,18 +31,48 @@ func (c *CoffeeService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// Flow of control
 	c.logger.Debug("Handle Coffees")
 
-	coffees, err := c.repository.Find()
+	var coffees entities.Coffees
+	var err error
+	if raw := r.URL.Query().Get("filter"); raw != "" {
+		expr, parseErr := filter.Parse(raw)
+		if parseErr != nil {
+			c.logger.Debug("Invalid filter", "filter", raw, "error", parseErr)
+			http.Error(rw, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		coffees, err = c.repository.FindWhere(r.Context(), expr)
+	} else {
+		coffees, err = c.repository.Find(r.Context())
+	}
 	if err != nil {
 		c.logger.Error("Unable to get coffees from database", "error", err)
 		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+		return
+	}
+	if err := data.Localize(r.Context(), c.repository, coffees, data.LocaleChain(r.Header.Get("Accept-Language"))); err != nil {
+		entities.PutCoffees(coffees)
+		c.logger.Error("Unable to get coffee translations from database", "error", err)
+		http.Error(rw, "Unable to get coffees from database", http.StatusInternalServerError)
+		return
 	}
 	c.logger.Debug(fmt.Sprintf("Found %d coffees", len(coffees)))
 
-	coffeesJSON, err := coffees.ToJSON()
+	if r.URL.Query().Get("include") == "stats" && c.popularity != nil {
+		c.popularity.Annotate(coffees)
+	}
+
+	encoder := encoding.Default.Negotiate(r.Header.Get("Accept"), &coffees)
+	body, err := encoder.Encode(&coffees)
+	entities.PutCoffees(coffees)
 	if err != nil {
-		c.logger.Error("Unable to convert coffees to JSON", "error", err)
-		http.Error(rw, "Unable to convert coffees to JSON", http.StatusInternalServerError)
+		c.logger.Error("Unable to encode coffees", "content_type", encoder.ContentType(), "error", err)
+		http.Error(rw, "Unable to encode coffees", http.StatusInternalServerError)
+		return
 	}
 
-	rw.Write(coffeesJSON)
+	rw.Header().Set("Content-Type", encoder.ContentType())
+	rw.Header().Add("Vary", "Accept")
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Write(body)
+	encoding.Recycle(body)
 }
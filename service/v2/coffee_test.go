@@ -8,8 +8,12 @@ import (
 
 	"github.com/hashicorp-demoapp/coffee-service/data"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/data/popularity"
+	"github.com/hashicorp-demoapp/coffee-service/service/encoding"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vmihailenco/msgpack/v4"
 )
 
 func setupCoffeeHandler(t *testing.T) (*CoffeeService, *httptest.ResponseRecorder, *http.Request) {
@@ -18,7 +22,7 @@ func setupCoffeeHandler(t *testing.T) (*CoffeeService, *httptest.ResponseRecorde
 
 	l := hclog.Default()
 
-	return &CoffeeService{c, l}, httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
+	return &CoffeeService{c, nil, l}, httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
 }
 
 func TestCoffeesReturnsCoffees(t *testing.T) {
@@ -32,3 +36,73 @@ func TestCoffeesReturnsCoffees(t *testing.T) {
 	err := json.Unmarshal(rw.Body.Bytes(), &bd)
 	assert.NoError(t, err)
 }
+
+func TestCoffeesReturnsProtobufWhenAccepted(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	r.Header.Set("Accept", encoding.ContentTypeProtobuf)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, encoding.ContentTypeProtobuf, rw.Header().Get("Content-Type"))
+
+	bd := entities.Coffees{}
+	err := bd.FromProto(rw.Body.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", bd[0].Name)
+}
+
+func TestCoffeesReturnsMsgPackWhenAccepted(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	r.Header.Set("Accept", encoding.ContentTypeMsgPack)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, encoding.ContentTypeMsgPack, rw.Header().Get("Content-Type"))
+
+	bd := make([]map[string]interface{}, 0)
+	err := msgpack.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", bd[0]["name"])
+}
+
+func TestCoffeesAppliesFilter(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("GET", "/coffees?filter=price%3C300%20AND%20name~latte", nil)
+
+	c.repository.(*data.MockRepository).On("FindWhere", mock.Anything).Return(entities.Coffees{entities.Coffee{ID: 2, Name: "Vaulatte"}}, nil)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffees{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bd[0].ID)
+}
+
+func TestCoffeesRejectsInvalidFilter(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("GET", "/coffees?filter=password%3Dsecret", nil)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestCoffeesIncludesStatsWhenRequested(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	c.popularity, _ = popularity.NewTracker("", hclog.NewNullLogger())
+	c.popularity.RecordView(1)
+
+	c.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees?include=stats", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffees{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), bd[0].Stats.Views)
+}
@@ -0,0 +1,194 @@
+// Package slo tracks availability and latency service level indicators per
+// endpoint over a rolling window, and the burn rate of their error budgets.
+package slo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// buckets is the number of buckets the window is split into, the window
+// rolls forward one bucket at a time
+const buckets = 60
+
+// ShortWindowRatio divides the window into the short window the short burn
+// rate is measured over, e.g. 5 minutes of a 1 hour window
+const ShortWindowRatio = 12
+
+// Objectives are the targets of every endpoint
+type Objectives struct {
+	// Availability is the percentage of requests which must not fail with a
+	// server error
+	Availability float64
+	// Latency is the time a request must complete within
+	Latency time.Duration
+	// LatencyTarget is the percentage of requests which must complete within
+	// Latency
+	LatencyTarget float64
+}
+
+// bucket counts the requests of an endpoint in a slice of the window
+type bucket struct {
+	start    time.Time
+	requests uint64
+	errors   uint64
+	slow     uint64
+}
+
+// Tracker records requests per endpoint and reports their SLIs against the
+// objectives over a rolling window
+type Tracker struct {
+	objectives Objectives
+	window     time.Duration
+	width      time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*[buckets]bucket
+}
+
+// NewTracker creates a Tracker measuring SLIs over window
+func NewTracker(objectives Objectives, window time.Duration) *Tracker {
+	return &Tracker{
+		objectives: objectives,
+		window:     window,
+		width:      window / buckets,
+		now:        time.Now,
+		endpoints:  map[string]*[buckets]bucket{},
+	}
+}
+
+// Record counts a request to endpoint, a status of 500 or above counts
+// against availability and a latency above the objective against latency
+func (t *Tracker) Record(endpoint string, status int, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all, ok := t.endpoints[endpoint]
+	if !ok {
+		all = &[buckets]bucket{}
+		t.endpoints[endpoint] = all
+	}
+
+	start := t.now().Truncate(t.width)
+	b := &all[(start.UnixNano()/int64(t.width))%buckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+	if latency > t.objectives.Latency {
+		b.slow++
+	}
+}
+
+// Report is the state of every endpoint's SLOs
+type Report struct {
+	Window string `json:"window"`
+	// Latency is the latency objective the latency SLI is measured against
+	Latency   string     `json:"latency"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is the state of the SLOs of a single endpoint
+type Endpoint struct {
+	Endpoint     string `json:"endpoint"`
+	Requests     uint64 `json:"requests"`
+	Availability SLO    `json:"availability"`
+	Latency      SLO    `json:"latency"`
+}
+
+// SLO is an SLI compared to its target. A burn rate of 1 spends the error
+// budget exactly over the window, the short burn rate is measured over the
+// short window to detect fast burns.
+type SLO struct {
+	// SLI is the percentage of good requests
+	SLI                  float64 `json:"sli"`
+	Target               float64 `json:"target"`
+	BurnRate             float64 `json:"burn_rate"`
+	ShortBurnRate        float64 `json:"short_burn_rate"`
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+}
+
+// Report computes the SLOs of every endpoint a request was recorded for in
+// the window, sorted by endpoint
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	since := now.Truncate(t.width).Add(-t.window + t.width)
+	shortSince := now.Truncate(t.width).Add(-t.window/ShortWindowRatio + t.width)
+
+	report := Report{
+		Window:    t.window.String(),
+		Latency:   t.objectives.Latency.String(),
+		Endpoints: make([]Endpoint, 0, len(t.endpoints)),
+	}
+
+	for name, all := range t.endpoints {
+		var window, short bucket
+		for _, b := range all {
+			if b.start.Before(since) {
+				continue
+			}
+			window.add(b)
+			if !b.start.Before(shortSince) {
+				short.add(b)
+			}
+		}
+		if window.requests == 0 {
+			continue
+		}
+
+		report.Endpoints = append(report.Endpoints, Endpoint{
+			Endpoint:     name,
+			Requests:     window.requests,
+			Availability: newSLO(t.objectives.Availability, window.requests, window.errors, short.requests, short.errors),
+			Latency:      newSLO(t.objectives.LatencyTarget, window.requests, window.slow, short.requests, short.slow),
+		})
+	}
+
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+	})
+
+	return report
+}
+
+// add adds the counts of o to b
+func (b *bucket) add(o bucket) {
+	b.requests += o.requests
+	b.errors += o.errors
+	b.slow += o.slow
+}
+
+// newSLO computes an SLO from the bad requests in the window and in the
+// short window
+func newSLO(target float64, requests, bad, shortRequests, shortBad uint64) SLO {
+	rate := burnRate(target, requests, bad)
+	return SLO{
+		SLI:                  100 * float64(requests-bad) / float64(requests),
+		Target:               target,
+		BurnRate:             rate,
+		ShortBurnRate:        burnRate(target, shortRequests, shortBad),
+		ErrorBudgetRemaining: 100 * (1 - rate),
+	}
+}
+
+// burnRate is the rate the error budget is spent at, relative to spending it
+// exactly over the window
+func burnRate(target float64, requests, bad uint64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	budget := 1 - target/100
+	if budget <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(requests) / budget
+}
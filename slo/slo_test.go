@@ -0,0 +1,72 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTracker(now *time.Time) *Tracker {
+	t := NewTracker(Objectives{Availability: 99, Latency: 100 * time.Millisecond, LatencyTarget: 90}, time.Hour)
+	t.now = func() time.Time { return *now }
+	return t
+}
+
+func TestReportComputesBurnRates(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := setupTracker(&now)
+
+	for i := 0; i < 98; i++ {
+		tracker.Record("GET /coffees", 200, 10*time.Millisecond)
+	}
+	tracker.Record("GET /coffees", 500, 10*time.Millisecond)
+	tracker.Record("GET /coffees", 200, time.Second)
+
+	report := tracker.Report()
+	require.Len(t, report.Endpoints, 1)
+
+	e := report.Endpoints[0]
+	assert.Equal(t, "GET /coffees", e.Endpoint)
+	assert.Equal(t, uint64(100), e.Requests)
+	assert.InDelta(t, 99, e.Availability.SLI, 0.001)
+	assert.InDelta(t, 1, e.Availability.BurnRate, 0.001)
+	assert.InDelta(t, 0, e.Availability.ErrorBudgetRemaining, 0.001)
+	assert.InDelta(t, 99, e.Latency.SLI, 0.001)
+	assert.InDelta(t, 0.1, e.Latency.BurnRate, 0.001)
+	assert.InDelta(t, 90, e.Latency.ErrorBudgetRemaining, 0.001)
+}
+
+func TestReportMeasuresShortWindow(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := setupTracker(&now)
+
+	for i := 0; i < 99; i++ {
+		tracker.Record("GET /coffees", 200, 10*time.Millisecond)
+	}
+	now = now.Add(30 * time.Minute)
+	tracker.Record("GET /coffees", 503, 10*time.Millisecond)
+
+	e := tracker.Report().Endpoints[0]
+	assert.InDelta(t, 1, e.Availability.BurnRate, 0.001)
+	assert.InDelta(t, 100, e.Availability.ShortBurnRate, 0.001)
+}
+
+func TestReportForgetsRequestsOutsideWindow(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := setupTracker(&now)
+
+	tracker.Record("GET /coffees", 500, 10*time.Millisecond)
+	tracker.Record("GET /search", 200, 10*time.Millisecond)
+	now = now.Add(59 * time.Minute)
+	tracker.Record("GET /search", 200, 10*time.Millisecond)
+	now = now.Add(2 * time.Minute)
+
+	report := tracker.Report()
+	require.Len(t, report.Endpoints, 1)
+	assert.Equal(t, "GET /search", report.Endpoints[0].Endpoint)
+	assert.Equal(t, uint64(1), report.Endpoints[0].Requests)
+	assert.Equal(t, "1h0m0s", report.Window)
+	assert.Equal(t, "100ms", report.Latency)
+}
@@ -0,0 +1,34 @@
+package spiffe
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextKey is the key of the peer ID in a context
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the ID of the peer
+func NewContext(ctx context.Context, id ID) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID of the peer, if it presented an SVID
+func FromContext(ctx context.Context) (ID, bool) {
+	id, ok := ctx.Value(contextKey{}).(ID)
+	return id, ok
+}
+
+// FromRequest returns the ID of the verified client certificate of r, if the
+// client presented one
+func FromRequest(r *http.Request) (ID, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ID{}, false
+	}
+
+	id, err := FromCertificate(r.TLS.VerifiedChains[0][0])
+	if err != nil {
+		return ID{}, false
+	}
+	return id, true
+}
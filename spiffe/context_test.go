@@ -0,0 +1,31 @@
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextCarriesTheID(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	id := ID{TrustDomain: "example.org", Path: "/web"}
+	got, ok := FromContext(NewContext(context.Background(), id))
+	assert.True(t, ok)
+	assert.Equal(t, id, got)
+}
+
+func TestFromRequestIgnoresCertificatesWhichAreNotSVIDs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	_, ok := FromRequest(r)
+	assert.False(t, ok)
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{KeyUsage: x509.KeyUsageDigitalSignature}}}}
+	_, ok = FromRequest(r)
+	assert.False(t, ok)
+}
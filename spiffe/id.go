@@ -0,0 +1,104 @@
+// Package spiffe validates the SPIFFE identities of the services calling the
+// coffee-service over mutual TLS, e.g. with certificates issued by the Consul
+// Connect CA or SPIRE, and carries them in the request context so handlers
+// can authorize their callers.
+package spiffe
+
+import (
+	"crypto/x509"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// scheme prefixes every SPIFFE ID
+const scheme = "spiffe://"
+
+// ID is a SPIFFE ID, e.g. spiffe://example.org/ns/default/sa/web
+type ID struct {
+	TrustDomain string
+	Path        string
+}
+
+// String returns the ID as a URI
+func (id ID) String() string {
+	return scheme + id.TrustDomain + id.Path
+}
+
+// Matches reports whether the ID matches pattern, a SPIFFE ID in which *
+// matches a single segment, e.g. spiffe://*.consul/ns/default/dc/*/svc/web
+func (id ID) Matches(pattern string) bool {
+	ok, _ := path.Match(pattern, id.String())
+	return ok
+}
+
+// ParseID parses a SPIFFE ID following the SPIFFE ID specification, the
+// trust domain and path may only contain letters, digits, dots, dashes and
+// underscores, and the trust domain is lower case
+func ParseID(s string) (ID, error) {
+	if !strings.HasPrefix(s, scheme) {
+		return ID{}, fmt.Errorf("spiffe ID %q must start with %s", s, scheme)
+	}
+
+	rest := s[len(scheme):]
+	id := ID{TrustDomain: rest}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		id.TrustDomain, id.Path = rest[:i], rest[i:]
+	}
+
+	if id.TrustDomain == "" {
+		return ID{}, fmt.Errorf("spiffe ID %q has no trust domain", s)
+	}
+	for _, c := range id.TrustDomain {
+		if !isLower(c) && !isDigit(c) && !strings.ContainsRune(".-_", c) {
+			return ID{}, fmt.Errorf("spiffe ID %q has an invalid trust domain", s)
+		}
+	}
+
+	if id.Path != "" {
+		for _, segment := range strings.Split(id.Path[1:], "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return ID{}, fmt.Errorf("spiffe ID %q has an empty or relative path segment", s)
+			}
+			for _, c := range segment {
+				if !isLower(c) && !isUpper(c) && !isDigit(c) && !strings.ContainsRune(".-_", c) {
+					return ID{}, fmt.Errorf("spiffe ID %q has an invalid path", s)
+				}
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// FromCertificate returns the SPIFFE ID of an X509-SVID. It fails unless the
+// certificate is a leaf certificate for signing with a single SPIFFE ID, as
+// required by the X509-SVID specification.
+func FromCertificate(cert *x509.Certificate) (ID, error) {
+	if len(cert.URIs) != 1 {
+		return ID{}, fmt.Errorf("certificate of %q must have exactly one URI SAN, found %d", cert.Subject.CommonName, len(cert.URIs))
+	}
+	id, err := ParseID(cert.URIs[0].String())
+	if err != nil {
+		return ID{}, err
+	}
+	if id.Path == "" {
+		return ID{}, fmt.Errorf("spiffe ID %s of a workload must have a path", id)
+	}
+
+	if cert.IsCA {
+		return ID{}, fmt.Errorf("certificate of %s must not be a CA", id)
+	}
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return ID{}, fmt.Errorf("certificate of %s must allow digital signatures", id)
+	}
+	if cert.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 {
+		return ID{}, fmt.Errorf("certificate of %s must not sign certificates or CRLs", id)
+	}
+
+	return id, nil
+}
+
+func isLower(c rune) bool { return c >= 'a' && c <= 'z' }
+func isUpper(c rune) bool { return c >= 'A' && c <= 'Z' }
+func isDigit(c rune) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,78 @@
+package spiffe
+
+import (
+	"crypto/x509"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("spiffe://7f3c.consul/ns/default/dc/dc1/svc/web")
+	require.NoError(t, err)
+	assert.Equal(t, ID{TrustDomain: "7f3c.consul", Path: "/ns/default/dc/dc1/svc/web"}, id)
+	assert.Equal(t, "spiffe://7f3c.consul/ns/default/dc/dc1/svc/web", id.String())
+
+	id, err = ParseID("spiffe://example.org")
+	require.NoError(t, err)
+	assert.Equal(t, ID{TrustDomain: "example.org"}, id)
+}
+
+func TestParseIDRejectsInvalidIDs(t *testing.T) {
+	for _, s := range []string{
+		"https://example.org/web",
+		"spiffe://",
+		"spiffe:///web",
+		"spiffe://Example.org/web",
+		"spiffe://example.org:8443/web",
+		"spiffe://user@example.org/web",
+		"spiffe://example.org/",
+		"spiffe://example.org//web",
+		"spiffe://example.org/../web",
+		"spiffe://example.org/web?x=1",
+	} {
+		_, err := ParseID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestIDMatches(t *testing.T) {
+	id := ID{TrustDomain: "7f3c.consul", Path: "/ns/default/dc/dc1/svc/web"}
+
+	assert.True(t, id.Matches("spiffe://7f3c.consul/ns/default/dc/dc1/svc/web"))
+	assert.True(t, id.Matches("spiffe://*.consul/ns/default/dc/*/svc/web"))
+	assert.False(t, id.Matches("spiffe://*.consul/ns/default/dc/*/svc/api"))
+	assert.False(t, id.Matches("spiffe://7f3c.consul/*"), "* does not match several segments")
+}
+
+func TestFromCertificate(t *testing.T) {
+	uri, _ := url.Parse("spiffe://example.org/web")
+	leaf := &x509.Certificate{URIs: []*url.URL{uri}, KeyUsage: x509.KeyUsageDigitalSignature}
+
+	id, err := FromCertificate(leaf)
+	require.NoError(t, err)
+	assert.Equal(t, ID{TrustDomain: "example.org", Path: "/web"}, id)
+
+	ca := *leaf
+	ca.IsCA = true
+	_, err = FromCertificate(&ca)
+	assert.EqualError(t, err, "certificate of spiffe://example.org/web must not be a CA")
+
+	signing := *leaf
+	signing.KeyUsage |= x509.KeyUsageCertSign
+	_, err = FromCertificate(&signing)
+	assert.Error(t, err)
+
+	none := *leaf
+	none.URIs = nil
+	_, err = FromCertificate(&none)
+	assert.Error(t, err)
+
+	trustDomain, _ := url.Parse("spiffe://example.org")
+	domain := *leaf
+	domain.URIs = []*url.URL{trustDomain}
+	_, err = FromCertificate(&domain)
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package spiffe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// ServerOptions configure the TLS listener of the service
+type ServerOptions struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key of the
+	// service
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle of the CAs client certificates are verified
+	// with, e.g. the Consul Connect CA roots or the SPIRE trust bundle. Client
+	// certificates are not requested when empty.
+	ClientCAFile string
+	// TrustDomain is the trust domain client certificates must be X509-SVIDs
+	// of, any certificate issued by the CAs is accepted when empty
+	TrustDomain string
+}
+
+// ServerConfig returns the tls.Config of the listener. Clients may connect
+// without a certificate, route groups requiring an identity reject their
+// requests, while a certificate which is not a valid SVID of the trust domain
+// fails the handshake.
+func ServerConfig(options ServerOptions) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if options.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := ioutil.ReadFile(options.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA file: %w", err)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", options.ClientCAFile)
+	}
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if options.TrustDomain != "" {
+		tlsConfig.VerifyPeerCertificate = VerifyPeer(options.TrustDomain)
+	}
+
+	return tlsConfig, nil
+}
+
+// VerifyPeer returns a tls.Config VerifyPeerCertificate callback accepting
+// verified client certificates only when they are X509-SVIDs of trustDomain
+func VerifyPeer(trustDomain string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		// clients without a certificate
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+			return nil
+		}
+
+		id, err := FromCertificate(verifiedChains[0][0])
+		if err != nil {
+			return err
+		}
+		if id.TrustDomain != trustDomain {
+			return fmt.Errorf("spiffe ID %s is not in trust domain %s", id, trustDomain)
+		}
+		return nil
+	}
+}
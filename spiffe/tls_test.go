@@ -0,0 +1,173 @@
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates for the tests, like the Consul Connect CA or
+// SPIRE would
+type testCA struct {
+	t    *testing.T
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	dir, err := ioutil.TempDir("", "spiffe")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	ca := &testCA{t: t, dir: dir}
+	ca.cert, ca.key = ca.issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	})
+	return ca
+}
+
+// issue signs template with the CA, or self signs it while the CA has no
+// certificate yet
+func (ca *testCA) issue(template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(ca.t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(ca.t, err)
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(ca.t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(ca.t, err)
+	return cert, key
+}
+
+// svid issues a client X509-SVID for id
+func (ca *testCA) svid(id string) tls.Certificate {
+	uri, err := url.Parse(id)
+	require.NoError(ca.t, err)
+
+	cert, key := ca.issue(&x509.Certificate{
+		URIs:        []*url.URL{uri},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+}
+
+// files writes the server certificate and key and the CA bundle, it returns
+// their paths
+func (ca *testCA) files() (string, string, string) {
+	cert, key := ca.issue(&x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(ca.t, err)
+
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(ca.dir, name)
+		require.NoError(ca.t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+		return path
+	}
+	return write("server.pem", "CERTIFICATE", cert.Raw), write("server-key.pem", "EC PRIVATE KEY", keyDER), write("ca.pem", "CERTIFICATE", ca.cert.Raw)
+}
+
+// serve starts a TLS server with the ServerConfig of the options, it
+// responds with the SPIFFE ID of the client
+func serve(t *testing.T, options ServerOptions) *httptest.Server {
+	tlsConfig, err := ServerConfig(options)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if id, ok := FromRequest(r); ok {
+			rw.Write([]byte(id.String()))
+		}
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get requests the server presenting certificates, it returns the body
+func get(ca *testCA, server *httptest.Server, certificates ...tls.Certificate) (string, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServerConfigAcceptsSVIDsOfTheTrustDomain(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := ca.files()
+	server := serve(t, ServerOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, TrustDomain: "example.org"})
+
+	body, err := get(ca, server, ca.svid("spiffe://example.org/ns/default/sa/web"))
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://example.org/ns/default/sa/web", body)
+
+	body, err = get(ca, server)
+	require.NoError(t, err)
+	assert.Equal(t, "", body, "clients without a certificate have no identity")
+}
+
+func TestServerConfigRejectsOtherCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := ca.files()
+	server := serve(t, ServerOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, TrustDomain: "example.org"})
+
+	_, err := get(ca, server, ca.svid("spiffe://other.org/web"))
+	assert.Error(t, err, "SVIDs of other trust domains fail the handshake")
+
+	_, err = get(ca, server, newTestCA(t).svid("spiffe://example.org/web"))
+	assert.Error(t, err, "SVIDs of other CAs fail the handshake")
+}
+
+func TestServerConfigFailsForInvalidFiles(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile, _ := ca.files()
+
+	_, err := ServerConfig(ServerOptions{CertFile: certFile, KeyFile: "/does/not/exist.pem"})
+	assert.Error(t, err)
+
+	_, err = ServerConfig(ServerOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile})
+	assert.Error(t, err)
+}